@@ -0,0 +1,148 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAuthFailed is what logging in (see AuthHandler) fails with, once the client has used up all
+// of its tries.
+var ErrAuthFailed = errors.New("telnet: authentication failed")
+
+const (
+	defaultAuthTries      = 3
+	defaultUsernamePrompt = "login: "
+	defaultPasswordPrompt = "Password: "
+	defaultLoginIncorrect = "Login incorrect\r\n"
+)
+
+// An AuthHandler has each client (of a Server) log in, before the Server's handler is run. (See
+// Server.AuthHandler, and WithAuthHandler.) For example:
+//
+//	server := telnet.NewServer(":5555",
+//		telnet.WithHandler(handler),
+//		telnet.WithAuthHandler(&telnet.AuthHandler{
+//			Authenticate: func(ctx context.Context, conn *telnet.Conn, username string, password string) error {
+//				return users.Check(username, password)
+//			},
+//			Delay: time.Second,
+//		}),
+//	)
+//
+// The client is asked for a username (with the UsernamePrompt), and then for a password (with the
+// PasswordPrompt); with the client not echoing what is typed while the password is (see
+// Conn.ReadPassword). And then Authenticate is called with them.
+//
+// If Authenticate returns nil, then the client is logged in; and the handler is run. (Conn.User
+// is then the username; for the handler to know who logged in.) Otherwise, Incorrect is sent, and
+// (after the Delay) the client is asked again; until it has had Tries tries. Then the connection
+// is closed (with CloseAuthFailed as the reason given to OnDisconnect); and the handler is never
+// run.
+type AuthHandler struct {
+	// Authenticate checks the username, and password, the client gave; and returns nil if they
+	// are right. (Or an error saying why not; which is only logged.) 'ctx' is done once the
+	// connection is closed.
+	Authenticate func(ctx context.Context, conn *Conn, username string, password string) error
+
+	// UsernamePrompt, PasswordPrompt, and Incorrect are what is sent to ask for the username, and
+	// the password, and to say they were not right. If empty, they are "login: ", "Password: ",
+	// and "Login incorrect\r\n".
+	UsernamePrompt string
+	PasswordPrompt string
+	Incorrect      string
+
+	// Tries is how many tries the client gets; 3, if it is zero. And Delay is how long to wait
+	// after a wrong try (before asking again); so that guessing passwords is slow.
+	Tries int
+	Delay time.Duration
+
+	// Timeout, if not zero, is how long the client has to log in; after which the connection is
+	// closed (with CloseAuthFailed).
+	Timeout time.Duration
+}
+
+// login has the client (at the other end of 'conn') log in; and returns the username it logged in
+// with. Or why it did not; such as ErrAuthFailed, once it has used up all of its tries.
+func (auth *AuthHandler) login(ctx context.Context, conn *Conn) (string, error) {
+	tries := auth.Tries
+	if tries <= 0 {
+		tries = defaultAuthTries
+	}
+
+	if 0 < auth.Timeout {
+		previous := conn.currentReadDeadline()
+		conn.SetReadDeadline(time.Now().Add(auth.Timeout))
+		defer conn.SetReadDeadline(previous)
+	}
+
+	for try := 1; try <= tries; try++ {
+		if _, err := conn.Write([]byte(stringOr(auth.UsernamePrompt, defaultUsernamePrompt))); nil != err {
+			return "", err
+		}
+		username, err := conn.ReadLine()
+		if nil != err {
+			return "", err
+		}
+
+		if _, err := conn.Write([]byte(stringOr(auth.PasswordPrompt, defaultPasswordPrompt))); nil != err {
+			return "", err
+		}
+		password, err := conn.ReadPassword()
+		if nil != err {
+			return "", err
+		}
+		if _, err := conn.Write([]byte("\r\n")); nil != err {
+			return "", err
+		}
+
+		err = ErrAuthFailed
+		if nil != auth.Authenticate {
+			err = auth.Authenticate(ctx, conn, username, password)
+		}
+		if nil == err {
+			conn.logger.Debugf("Logged in %q (from %q), on try #%d.", username, conn.RemoteAddr(), try)
+			return username, nil
+		}
+		conn.logger.Debugf("Did not log in %q (from %q), on try #%d: %v", username, conn.RemoteAddr(), try, err)
+
+		if _, err := conn.Write([]byte(stringOr(auth.Incorrect, defaultLoginIncorrect))); nil != err {
+			return "", err
+		}
+
+		if try < tries && 0 < auth.Delay {
+			timer := time.NewTimer(auth.Delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return "", ctx.Err()
+			}
+		}
+	}
+
+	return "", ErrAuthFailed
+}
+
+// stringOr returns 's'; or, if it is empty, 'otherwise'.
+func stringOr(s string, otherwise string) string {
+	if "" == s {
+		return otherwise
+	}
+	return s
+}
+
+// User returns the username the client logged in with (see Server.AuthHandler); or "" if it did
+// not log in.
+func (clientConn *Conn) User() string {
+	clientConn.userMutex.Lock()
+	defer clientConn.userMutex.Unlock()
+
+	return clientConn.user
+}
+
+func (clientConn *Conn) setUser(user string) {
+	clientConn.userMutex.Lock()
+	clientConn.user = user
+	clientConn.userMutex.Unlock()
+}
@@ -0,0 +1,196 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"time"
+
+	"testing"
+)
+
+// testAuthServer serves (on a listener of its own) with 'auth'; and the handler says hello to whoever
+// logged in. The reason each connection was closed (as OnDisconnect is told) is sent to 'reasons'.
+func testAuthServer(t *testing.T, auth *AuthHandler, reasons chan<- CloseReason) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := NewServer("",
+		WithBanner("Welcome\r\n"),
+		WithAuthHandler(auth),
+		WithContextHandler(testContextHandler(func(ctx context.Context, conn *Conn) {
+			conn.SendLine("Hello, " + conn.User() + "!")
+			conn.ReadLine()
+		})),
+		WithOnDisconnect(func(conn *Conn, reason CloseReason, msg string) {
+			reasons <- reason
+		}),
+	)
+	go server.Serve(listener)
+
+	return listener.Addr().String()
+}
+
+func TestServerAuthHandler(t *testing.T) {
+
+	reasons := make(chan CloseReason, 1)
+	var tries []string
+	addr := testAuthServer(t, &AuthHandler{
+		Authenticate: func(ctx context.Context, conn *Conn, username string, password string) error {
+			tries = append(tries, username+":"+password)
+			if "joe" != username || "secret" != password {
+				return errors.New("wrong password")
+			}
+			return nil
+		},
+	}, reasons)
+
+	conn, err := DialTo(addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	var recorder testTapRecorder
+	tap := recorder.tap()
+	tap.Event = func(event TapEvent) {
+		if OptEcho == event.Option && Inbound == event.Direction {
+			recorder.events = append(recorder.events, event.String())
+		}
+	}
+	conn.SetTap(tap)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	logIn := func(username string, password string) {
+		t.Helper()
+
+		if _, err := conn.Expect(ctx, regexp.MustCompile(`login: $`)); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		conn.SendLine(username)
+		if _, err := conn.Expect(ctx, regexp.MustCompile(`Password: $`)); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		conn.SendLine(password)
+	}
+
+	logIn("joe", "guess")
+	if _, err := conn.Expect(ctx, regexp.MustCompile(`Login incorrect\r\n`)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	logIn("joe", "secret")
+	match, err := conn.Expect(ctx, regexp.MustCompile(`Hello, (\w*)!`))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "joe", match.Groups[0]; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := []string{"joe:guess", "joe:secret"}, tries; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (The server offered ECHO before each password was typed; so that the client would not echo
+	// it. This client refused; so there was nothing for the server to stop, after.)
+	if expected, actual := []string{"IAC WILL ECHO (inbound)", "IAC WILL ECHO (inbound)"}, recorder.events; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServerAuthHandlerFailed(t *testing.T) {
+
+	tests := []struct {
+		Auth     *AuthHandler
+		Username string
+		Expected int
+	}{
+		{
+			// (Nothing is right, without Authenticate.)
+			Auth:     &AuthHandler{},
+			Expected: 3,
+		},
+		{
+			Auth: &AuthHandler{
+				Authenticate: func(ctx context.Context, conn *Conn, username string, password string) error {
+					return errors.New("wrong password")
+				},
+				UsernamePrompt: "Username: ",
+				Tries:          2,
+				Delay:          10 * time.Millisecond,
+			},
+			Username: "Username: ",
+			Expected: 2,
+		},
+	}
+
+	for testNumber, test := range tests {
+		reasons := make(chan CloseReason, 1)
+		addr := testAuthServer(t, test.Auth, reasons)
+
+		conn, err := DialTo(addr)
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+		prompt := regexp.MustCompile(regexp.QuoteMeta(stringOr(test.Username, defaultUsernamePrompt)) + `$`)
+		var prompts int
+		for {
+			if _, err := conn.Expect(ctx, prompt, regexp.MustCompile(`Password: $`)); nil != err {
+				break
+			}
+			prompts++
+			conn.SendLine("joe")
+		}
+		cancel()
+		conn.Close()
+
+		// (The username, and the password, prompts; once for each try.)
+		if expected, actual := 2*test.Expected, prompts; expected != actual {
+			t.Errorf("For test #%d, expected %d prompts, but actually got %d.", testNumber, expected, actual)
+		}
+
+		select {
+		case reason := <-reasons:
+			if expected, actual := CloseAuthFailed, reason; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Errorf("For test #%d, expected OnDisconnect to be called, but it actually was not.", testNumber)
+		}
+	}
+}
+
+func TestServerAuthHandlerTimeout(t *testing.T) {
+
+	reasons := make(chan CloseReason, 1)
+	addr := testAuthServer(t, &AuthHandler{Timeout: 50 * time.Millisecond}, reasons)
+
+	conn, err := DialTo(addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	// (It is read from; but nothing is typed.)
+	go conn.ReadLine()
+
+	select {
+	case reason := <-reasons:
+		if expected, actual := CloseAuthFailed, reason; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("Expected OnDisconnect to be called, but it actually was not.")
+	}
+}
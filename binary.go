@@ -0,0 +1,136 @@
+package telnet
+
+// NewlinePolicy is what a Conn does with the newlines (and carriage returns) in its data; while
+// the data is not BINARY (per RFC 856). (See Conn.SetNewlinePolicy.)
+type NewlinePolicy int
+
+const (
+	// NewlineRaw leaves the data as it is; whatever is written is sent as-is (only escaped), and
+	// whatever is received is read as-is. (This is the default.)
+	NewlineRaw NewlinePolicy = iota
+
+	// NewlineNVT translates the data to (and from) that of the NVT (per RFC 854); in which a
+	// newline is CR LF, and a carriage return (on its own) is CR NUL. That is, a LF that is written
+	// (that does not come right after a CR) is sent as CR LF, and a CR that is written (that does
+	// not come right before a LF) is sent as CR NUL; and a CR NUL that is received is read as just
+	// the CR.
+	//
+	// It only applies to what is sent while BINARY is not enabled on our side; and to what is
+	// received while it is not enabled on the peer's side. (BINARY data is left as it is.)
+	NewlineNVT
+)
+
+// String returns the name of the NewlinePolicy; such as "NVT".
+func (policy NewlinePolicy) String() string {
+	switch policy {
+	case NewlineRaw:
+		return "raw"
+	case NewlineNVT:
+		return "NVT"
+	default:
+		return "unknown"
+	}
+}
+
+// SetNewlinePolicy sets what the Conn does with the newlines (and carriage returns) in its data,
+// while it is not BINARY. (See NewlinePolicy.) For example:
+//
+//	conn.SetNewlinePolicy(telnet.NewlineNVT)
+//
+//	conn.Write([]byte("one\ntwo\n")) // Sent as "one\r\ntwo\r\n".
+//
+// And, once BINARY has been negotiated (see SetBinaryMode), the data is sent (and read) as-is; so
+// that, for example, a file sent over the Conn is not corrupted by the translation. (A Server (or
+// Dialer) sets it to its NewlinePolicy.)
+func (clientConn *Conn) SetNewlinePolicy(policy NewlinePolicy) {
+	clientConn.newlinePolicy.Store(int32(policy))
+}
+
+// NewlinePolicy returns what the Conn does with the newlines in its data. (See SetNewlinePolicy.)
+func (clientConn *Conn) NewlinePolicy() NewlinePolicy {
+	return NewlinePolicy(clientConn.newlinePolicy.Load())
+}
+
+// SetBinaryMode asks for (if 'binary' is true) or against (if it is false) BINARY transmission
+// (RFC 856), in both directions; i.e., it sends IAC WILL BINARY and IAC DO BINARY (or IAC WONT
+// BINARY and IAC DONT BINARY). (Whether the peer agreed is what Binary returns.)
+//
+// It does not wait for the peer to answer; see RequestEnableLocal, and RequestEnableRemote, for
+// that. (And, for the peer to be able to ask for BINARY itself, see SetOptionPolicy.)
+func (clientConn *Conn) SetBinaryMode(binary bool) error {
+	if err := clientConn.negotiator.request(OptBinary, true, binary); nil != err {
+		return err
+	}
+	return clientConn.negotiator.request(OptBinary, false, binary)
+}
+
+// Binary returns whether BINARY transmission is enabled on our side (i.e., what is sent is
+// BINARY), and on the peer's side (i.e., what is received is BINARY).
+func (clientConn *Conn) Binary() (sending bool, receiving bool) {
+	return clientConn.OptionEnabled(OptBinary)
+}
+
+// translatingWritten returns whether what is written is translated to the NVT's newlines. (See
+// NewlineNVT.)
+func (clientConn *Conn) translatingWritten() bool {
+	if NewlineNVT != clientConn.NewlinePolicy() {
+		return false
+	}
+	sending, _ := clientConn.Binary()
+	return !sending
+}
+
+// translateWritten returns 'p' with its newlines (and carriage returns) translated to the NVT's.
+// (The transcodeMutex must be held.)
+func (clientConn *Conn) translateWritten(p []byte) []byte {
+	translated := make([]byte, 0, len(p)+len(p)/16+1)
+
+	for _, b := range p {
+		pendingCR := clientConn.writtenCR
+		clientConn.writtenCR = '\r' == b
+
+		switch {
+		case '\n' == b && !pendingCR:
+			translated = append(translated, '\r', '\n')
+		case '\n' != b && pendingCR:
+			translated = append(translated, 0, b)
+		default:
+			translated = append(translated, b)
+		}
+	}
+
+	return translated
+}
+
+// readTranslated reads (TELNET) data from the internalDataReader; with each CR NUL read as just
+// the CR, if it is being translated from the NVT's. (See NewlineNVT.) (The readMutex must be held.)
+func (clientConn *Conn) readTranslated(p []byte) (n int, err error) {
+	for {
+		n, err = clientConn.dataReader.Read(p)
+		if NewlineNVT != clientConn.NewlinePolicy() {
+			clientConn.readCR = false
+			return n, err
+		}
+		if _, receiving := clientConn.Binary(); receiving {
+			clientConn.readCR = false
+			return n, err
+		}
+
+		k := 0
+		for _, b := range p[:n] {
+			pendingCR := clientConn.readCR
+			clientConn.readCR = '\r' == b
+
+			if 0 == b && pendingCR {
+				continue
+			}
+			p[k] = b
+			k++
+		}
+
+		// (If all that was read was the NUL of a CR NUL, then there is nothing to return yet.)
+		if 0 < k || 0 == n || nil != err {
+			return k, err
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package telnet
+
+import (
+	"io"
+	"time"
+
+	"testing"
+)
+
+func TestConnNewlineNVTWrite(t *testing.T) {
+
+	tests := []struct {
+		Policy   NewlinePolicy
+		Writes   []string
+		Expected string
+	}{
+		{
+			Policy:   NewlineRaw,
+			Writes:   []string{"a\nb\rc"},
+			Expected: "a\nb\rc",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\nb"},
+			Expected: "a\r\nb",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\r\nb"},
+			Expected: "a\r\nb",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\rb"},
+			Expected: "a\r\x00b",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\r\rb\n\n"},
+			Expected: "a\r\x00\r\x00b\r\n\r\n",
+		},
+		{
+			// (A CR LF split across writes.)
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\r", "\nb"},
+			Expected: "a\r\nb",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"a\r", "b"},
+			Expected: "a\r\x00b",
+		},
+		{
+			Policy:   NewlineNVT,
+			Writes:   []string{"\xff\n"},
+			Expected: "\xff\xff\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+		conn.SetNewlinePolicy(test.Policy)
+
+		written := make(chan error, 1)
+		go func() {
+			for _, s := range test.Writes {
+				if n, err := conn.Write([]byte(s)); nil != err || len(s) != n {
+					written <- err
+					return
+				}
+			}
+			written <- nil
+		}()
+
+		if expected, actual := test.Expected, string(testReadExactly(t, remote, len(test.Expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if err := <-written; nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+	}
+}
+
+func TestConnNewlineNVTRead(t *testing.T) {
+
+	tests := []struct {
+		Policy   NewlinePolicy
+		Sends    []string
+		Expected string
+	}{
+		{
+			Policy:   NewlineRaw,
+			Sends:    []string{"a\r\x00b\r\nc"},
+			Expected: "a\r\x00b\r\nc",
+		},
+		{
+			Policy:   NewlineNVT,
+			Sends:    []string{"a\r\x00b\r\nc"},
+			Expected: "a\rb\r\nc",
+		},
+		{
+			// (A CR NUL split across what is received.)
+			Policy:   NewlineNVT,
+			Sends:    []string{"a\r", "\x00", "b"},
+			Expected: "a\rb",
+		},
+		{
+			// (A NUL that does not come right after a CR is left alone.)
+			Policy:   NewlineNVT,
+			Sends:    []string{"a\x00b"},
+			Expected: "a\x00b",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		conn.SetNewlinePolicy(test.Policy)
+
+		for _, s := range test.Sends {
+			send([]byte(s)...)
+		}
+
+		p := make([]byte, len(test.Expected))
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		if _, err := io.ReadFull(conn, p); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := test.Expected, string(p); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnSetBinaryMode(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetNewlinePolicy(NewlineNVT)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.SetBinaryMode(true)
+	}()
+	if expected, actual := string([]byte{IAC, WILL, OptBinary, IAC, DO, OptBinary}), string(testReadExactly(t, remote, 6)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	remote.Write([]byte{IAC, DO, OptBinary, IAC, WILL, OptBinary})
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if sending, receiving := conn.Binary(); sending && receiving {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected BINARY to be enabled, but it actually was not.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// (BINARY data is sent as-is.)
+	go conn.Write([]byte("a\nb\rc"))
+	if expected, actual := "a\nb\rc", string(testReadExactly(t, remote, 5)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnBinaryRead(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	conn.SetNewlinePolicy(NewlineNVT)
+	conn.SetOptionPolicy(OptBinary, OptionPolicyAccept)
+
+	// (Once the peer sends BINARY, what it sends is read as-is.)
+	send(IAC, WILL, OptBinary)
+	send('a', '\r', 0, 'b')
+
+	p := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "a\r\x00b", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
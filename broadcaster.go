@@ -0,0 +1,350 @@
+package telnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBroadcastQueueSize = 64
+	defaultBroadcastTimeout   = 10 * time.Second
+)
+
+// ErrBroadcastOverflow is what a member is removed with (see Broadcaster.OnRemove), when its queue
+// is full, and the Broadcaster's Overflow is OverflowRemove.
+var ErrBroadcastOverflow = errors.New("telnet: broadcast queue full")
+
+// OverflowPolicy is what a Broadcaster does with a member that has too many messages waiting to
+// be written to it (i.e., a member whose queue is full); such as because the client is slow.
+type OverflowPolicy int
+
+const (
+	// OverflowSkip skips the message, for that member; which is then flagged as lagging
+	// (see Broadcaster.Skipped). This is the default.
+	OverflowSkip OverflowPolicy = iota
+
+	// OverflowRemove removes the member.
+	OverflowRemove
+)
+
+// String returns "skip" or "remove".
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowSkip:
+		return "skip"
+	case OverflowRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Broadcaster writes the same (data) messages to many Conns (its members); such as for a chat
+// room, or a MUD.
+//
+// For example:
+//
+//	var room telnet.Broadcaster
+//
+//	func (handler chatHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader) {
+//		conn := w.(*telnet.Conn)
+//
+//		room.Add(conn)
+//		defer room.Remove(conn)
+//
+//		room.Printf("* %s joined.\r\n", conn.RemoteAddr())
+//
+//		//...
+//	}
+//
+// Each message is escaped once, and then written to every member at the same time; each member
+// having a queue of its own, so that a slow member does not hold up the others. (So Write returns
+// before the message has been written to the members.) The messages are written to each member
+// in the order they were broadcast.
+//
+// A member is removed (automatically) once its Conn is closed, or if writing to it fails; or
+// times out (see Timeout).
+//
+// The zero Broadcaster has no members, and is ready to use. A Broadcaster can be used from many
+// goroutines at the same time; and members can be added (and removed) at any time, including
+// while a message is being broadcast.
+type Broadcaster struct {
+	// QueueSize is how many messages there can be waiting to be written to a member, before
+	// Overflow kicks in. The default is 64.
+	QueueSize int
+
+	// Timeout is how long writing a message to a member can take, before the member is
+	// removed. The default is 10 seconds.
+	Timeout time.Duration
+
+	// Overflow is what to do with a member whose queue is full. The default is OverflowSkip.
+	Overflow OverflowPolicy
+
+	// OnRemove, if not nil, is called when a member is removed automatically (but not when it
+	// is removed with Remove); with why.
+	OnRemove func(conn *Conn, err error)
+
+	mutex   sync.Mutex
+	members map[*Conn]*internalBroadcastMember
+}
+
+type internalBroadcastMember struct {
+	queue   chan []byte
+	removed chan struct{}
+	skipped int
+}
+
+// Add adds 'conn' as a member; if it was not one already.
+//
+// A member only gets the messages broadcast after it was added.
+func (broadcaster *Broadcaster) Add(conn *Conn) {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	if _, ok := broadcaster.members[conn]; ok {
+		return
+	}
+
+	size := broadcaster.QueueSize
+	if size <= 0 {
+		size = defaultBroadcastQueueSize
+	}
+
+	member := &internalBroadcastMember{
+		queue:   make(chan []byte, size),
+		removed: make(chan struct{}),
+	}
+
+	if nil == broadcaster.members {
+		broadcaster.members = map[*Conn]*internalBroadcastMember{}
+	}
+	broadcaster.members[conn] = member
+
+	conn.spawn(func() {
+		broadcaster.deliver(conn, member)
+	})
+}
+
+// Remove removes 'conn' as a member; if it was one. Any messages still waiting to be written to
+// it are not written.
+func (broadcaster *Broadcaster) Remove(conn *Conn) {
+	broadcaster.remove(conn, nil, nil)
+}
+
+// Len returns how many members there are.
+func (broadcaster *Broadcaster) Len() int {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	return len(broadcaster.members)
+}
+
+// Skipped returns how many messages were skipped for the member 'conn', because its queue was
+// full (see OverflowSkip). A member that has had any skipped is lagging; i.e., it is not keeping up.
+//
+// If 'conn' is not a member, then Skipped returns zero.
+func (broadcaster *Broadcaster) Skipped(conn *Conn) int {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	member, ok := broadcaster.members[conn]
+	if !ok {
+		return 0
+	}
+
+	return member.skipped
+}
+
+// Write broadcasts 'p' (as TELNET data) to all the members.
+//
+// Write does not wait for 'p' to be written to the members; and so never fails.
+func (broadcaster *Broadcaster) Write(p []byte) (int, error) {
+	if len(p) <= 0 {
+		return 0, nil
+	}
+
+	escaped := make([]byte, 0, len(p)+len(p)/8)
+	for _, b := range p {
+		escaped = appendEscaped(escaped, b)
+	}
+
+	var overflowed map[*Conn]*internalBroadcastMember
+
+	broadcaster.mutex.Lock()
+	for conn, member := range broadcaster.members {
+		select {
+		case member.queue <- escaped:
+		default:
+			if OverflowRemove == broadcaster.Overflow {
+				if nil == overflowed {
+					overflowed = map[*Conn]*internalBroadcastMember{}
+				}
+				overflowed[conn] = member
+				continue
+			}
+			member.skipped++
+		}
+	}
+	broadcaster.mutex.Unlock()
+
+	for conn, member := range overflowed {
+		broadcaster.remove(conn, member, ErrBroadcastOverflow)
+	}
+
+	return len(p), nil
+}
+
+// Printf broadcasts (like Write does) a message, formatted like fmt.Printf does, to all the members.
+func (broadcaster *Broadcaster) Printf(format string, a ...interface{}) (int, error) {
+	return fmt.Fprintf(broadcaster, format, a...)
+}
+
+// deliver writes the messages queued for 'member' to 'conn', one after another; until it is
+// removed.
+func (broadcaster *Broadcaster) deliver(conn *Conn, member *internalBroadcastMember) {
+
+	timeout := broadcaster.Timeout
+	if timeout <= 0 {
+		timeout = defaultBroadcastTimeout
+	}
+
+	for {
+		select {
+		case <-member.removed:
+			return
+		case <-conn.done:
+			broadcaster.remove(conn, member, net.ErrClosed)
+			return
+		case p := <-member.queue:
+			if err := conn.writeEscaped(p, timeout); nil != err {
+				conn.logger.Debugf("Removing broadcast member, as writing to it failed: %v", err)
+				broadcaster.remove(conn, member, err)
+				return
+			}
+		}
+	}
+}
+
+// remove removes 'conn' as a member; but only if it is still 'member' (unless 'member' is nil).
+//
+// If 'err' is not nil, then the member was removed automatically, and OnRemove is called with it.
+func (broadcaster *Broadcaster) remove(conn *Conn, member *internalBroadcastMember, err error) {
+	broadcaster.mutex.Lock()
+	current, ok := broadcaster.members[conn]
+	if !ok || (nil != member && member != current) {
+		broadcaster.mutex.Unlock()
+		return
+	}
+	delete(broadcaster.members, conn)
+	close(current.removed)
+	broadcaster.mutex.Unlock()
+
+	if fn := broadcaster.OnRemove; nil != fn && nil != err {
+		fn(conn, err)
+	}
+}
+
+// writeEscaped writes 'p' (which has already been escaped) to the peer, as-is; as fast as the
+// rate limit allows (but, even if it is non-blocking, waiting for it), and giving up once 'timeout'
+// has passed.
+func (clientConn *Conn) writeEscaped(p []byte, timeout time.Duration) error {
+	if clientConn.isClosed() {
+		return ErrClosed
+	}
+
+	w := clientConn.dataWriter
+
+	remaining := len(p)
+	w.pending.Add(int64(remaining))
+	defer func() {
+		w.pending.Add(-int64(remaining))
+	}()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// (The write deadline of the Conn still applies, if it is sooner; and is put back afterwards.)
+	deadline := time.Now().Add(timeout)
+	if previous := w.limiter.writeDeadline(); !previous.IsZero() && previous.Before(deadline) {
+		deadline = previous
+	}
+	if deadliner, ok := clientConn.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		deadliner.SetWriteDeadline(deadline)
+		defer func() {
+			deadliner.SetWriteDeadline(w.limiter.writeDeadline())
+		}()
+	}
+
+	for 0 < len(p) {
+		k, err := w.limiter.take(len(p), deadline, true, func(available int) int {
+			return escapedPrefixLen(p, available)
+		})
+		if nil != err {
+			return err
+		}
+
+		if err := w.writeWire(p[:k], deadline); nil != err {
+			return err
+		}
+
+		remaining -= k
+		w.pending.Add(-int64(k))
+		p = p[k:]
+	}
+
+	return nil
+}
+
+// writeWire writes 'p' (which has already been escaped) to the wrapped io.Writer, as-is, and flushes
+// it; a buffer's worth at a time (like write does), with 'deadline'. The mutex must be held.
+func (w *internalDataWriter) writeWire(p []byte, deadline time.Time) error {
+	for 0 < len(p) {
+		k := escapedPrefixLen(p, w.wrapped.Size())
+		if k <= 0 {
+			// (An escaped IAC is never split; even if it does not fit.)
+			k = escapedPrefixLen(p, 2)
+		}
+
+		w.wireMutex.Lock()
+		w.timeout.deadline = deadline
+		_, err := w.wrapped.Write(p[:k])
+		if nil == err {
+			err = w.wrapped.Flush()
+		}
+		w.timeout.deadline = time.Time{}
+		w.unlockWire()
+		if nil != err {
+			return err
+		}
+
+		p = p[k:]
+	}
+
+	return nil
+}
+
+// escapedPrefixLen returns how many bytes (from the start) of 'p' (which has already been escaped)
+// fit in 'available' bytes; without splitting an escaped IAC. (If 'available' is negative, then all
+// of 'p' fits.)
+func escapedPrefixLen(p []byte, available int) int {
+	if available < 0 || len(p) <= available {
+		return len(p)
+	}
+
+	k := 0
+	for k < len(p) {
+		size := 1
+		if IAC == p[k] {
+			size = 2
+		}
+		if available < k+size {
+			break
+		}
+		k += size
+	}
+
+	return k
+}
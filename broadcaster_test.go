@@ -0,0 +1,288 @@
+package telnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testRemoved collects the members a Broadcaster removes (automatically), and why.
+type testRemoved struct {
+	mutex   sync.Mutex
+	removed map[*Conn]error
+	done    chan struct{}
+}
+
+func newTestRemoved() *testRemoved {
+	return &testRemoved{
+		removed: map[*Conn]error{},
+		done:    make(chan struct{}, 16),
+	}
+}
+
+func (removed *testRemoved) onRemove(conn *Conn, err error) {
+	removed.mutex.Lock()
+	removed.removed[conn] = err
+	removed.mutex.Unlock()
+
+	removed.done <- struct{}{}
+}
+
+func (removed *testRemoved) wait(t *testing.T, conn *Conn) error {
+	t.Helper()
+
+	deadline := time.After(time.Second)
+	for {
+		removed.mutex.Lock()
+		err, ok := removed.removed[conn]
+		removed.mutex.Unlock()
+		if ok {
+			return err
+		}
+
+		select {
+		case <-removed.done:
+		case <-deadline:
+			t.Fatalf("Expected the member to be removed, but it was not.")
+		}
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+
+	var broadcaster Broadcaster
+
+	var remotes []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, remote := testPipe(t)
+		broadcaster.Add(conn)
+		broadcaster.Add(conn)
+		remotes = append(remotes, remote)
+	}
+
+	if expected, actual := 3, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d members, but actually got %d.", expected, actual)
+	}
+
+	broadcaster.Printf("hello %s\r\n", "\xffworld")
+	broadcaster.Write([]byte("bye\r\n"))
+
+	expected := "hello \xff\xffworld\r\nbye\r\n"
+	for i, remote := range remotes {
+		if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+			t.Errorf("For member #%d, expected %q, but actually got %q.", i, expected, actual)
+		}
+	}
+}
+
+func TestBroadcasterRemove(t *testing.T) {
+
+	var broadcaster Broadcaster
+
+	kept, keptRemote := testPipe(t)
+	removed, removedRemote := testPipe(t)
+
+	broadcaster.Add(kept)
+	broadcaster.Add(removed)
+	broadcaster.Remove(removed)
+	broadcaster.Remove(removed)
+
+	if expected, actual := 1, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d members, but actually got %d.", expected, actual)
+	}
+
+	broadcaster.Printf("hello\r\n")
+
+	if expected, actual := "hello\r\n", string(testReadExactly(t, keptRemote, 7)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	removedRemote.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, err := removedRemote.Read(make([]byte, 1)); 0 < n || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the removed member not to get anything, but actually got: %d, (%T) %v", n, err, err)
+	}
+}
+
+func TestBroadcasterClosed(t *testing.T) {
+
+	removed := newTestRemoved()
+	broadcaster := Broadcaster{OnRemove: removed.onRemove}
+
+	conn, _ := testPipe(t)
+	broadcaster.Add(conn)
+
+	conn.Close()
+
+	if err := removed.wait(t, conn); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := 0, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d members, but actually got %d.", expected, actual)
+	}
+}
+
+func TestBroadcasterTimeout(t *testing.T) {
+
+	removed := newTestRemoved()
+	broadcaster := Broadcaster{
+		Timeout:  50 * time.Millisecond,
+		OnRemove: removed.onRemove,
+	}
+
+	slow, _ := testPipe(t) // What is written to it is never read.
+	fast, fastRemote := testPipe(t)
+
+	broadcaster.Add(slow)
+	broadcaster.Add(fast)
+
+	broadcaster.Printf("hello\r\n")
+
+	// The slow member does not hold up the fast one.
+	if expected, actual := "hello\r\n", string(testReadExactly(t, fastRemote, 7)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if err := removed.wait(t, slow); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected a timeout, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := 1, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d members, but actually got %d.", expected, actual)
+	}
+}
+
+func TestBroadcasterOverflow(t *testing.T) {
+
+	tests := []struct {
+		Overflow        OverflowPolicy
+		ExpectedRemoved bool
+	}{
+		{
+			Overflow:        OverflowSkip,
+			ExpectedRemoved: false,
+		},
+		{
+			Overflow:        OverflowRemove,
+			ExpectedRemoved: true,
+		},
+	}
+
+	for testNumber, test := range tests {
+		removed := newTestRemoved()
+		broadcaster := Broadcaster{
+			QueueSize: 2,
+			Overflow:  test.Overflow,
+			OnRemove:  removed.onRemove,
+		}
+
+		slow, _ := testPipe(t) // What is written to it is never read.
+		broadcaster.Add(slow)
+
+		// (One is being written; two are queued up.)
+		for i := 0; i < 5; i++ {
+			broadcaster.Printf("message #%d\r\n", i)
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if !test.ExpectedRemoved {
+			if expected, actual := 2, broadcaster.Skipped(slow); expected != actual {
+				t.Errorf("For test #%d, expected %d skipped, but actually got %d.", testNumber, expected, actual)
+			}
+			if expected, actual := 1, broadcaster.Len(); expected != actual {
+				t.Errorf("For test #%d, expected %d members, but actually got %d.", testNumber, expected, actual)
+			}
+			continue
+		}
+
+		if err := removed.wait(t, slow); ErrBroadcastOverflow != err {
+			t.Errorf("For test #%d, expected ErrBroadcastOverflow, but actually got: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := 0, broadcaster.Len(); expected != actual {
+			t.Errorf("For test #%d, expected %d members, but actually got %d.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestBroadcasterConcurrent(t *testing.T) {
+
+	var broadcaster Broadcaster
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for j := 0; j < 50; j++ {
+				conn, _ := testPipe(t)
+				broadcaster.Add(conn)
+				broadcaster.Printf("message #%d\r\n", j)
+				broadcaster.Remove(conn)
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	if expected, actual := 0, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d members, but actually got %d.", expected, actual)
+	}
+}
+
+// testBenchmarkConn is an (in memory) connection, that throws away what is written to it.
+type testBenchmarkConn struct {
+	written *sync.WaitGroup
+}
+
+func (testBenchmarkConn) Read(p []byte) (int, error) { return 0, net.ErrClosed }
+func (conn testBenchmarkConn) Write(p []byte) (int, error) {
+	if nil != conn.written {
+		conn.written.Done()
+	}
+	return len(p), nil
+}
+func (testBenchmarkConn) Close() error         { return nil }
+func (testBenchmarkConn) LocalAddr() net.Addr  { return nil }
+func (testBenchmarkConn) RemoteAddr() net.Addr { return nil }
+
+const testBenchmarkMembers = 1000
+
+var testBenchmarkMessage = []byte(strings.Repeat("The \xffquick brown fox jumps over the lazy dog. ", 4) + "\r\n")
+
+// BenchmarkBroadcaster broadcasts to 1000 members; escaping each message once.
+func BenchmarkBroadcaster(b *testing.B) {
+
+	var written sync.WaitGroup
+
+	broadcaster := Broadcaster{QueueSize: 1}
+	for i := 0; i < testBenchmarkMembers; i++ {
+		broadcaster.Add(newConn(testBenchmarkConn{written: &written}, nil))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		written.Add(testBenchmarkMembers)
+		broadcaster.Write(testBenchmarkMessage)
+		written.Wait()
+	}
+}
+
+// BenchmarkBroadcastLoop writes to 1000 Conns, one after another; escaping each message once
+// for each Conn.
+func BenchmarkBroadcastLoop(b *testing.B) {
+
+	var conns []*Conn
+	for i := 0; i < testBenchmarkMembers; i++ {
+		conns = append(conns, newConn(testBenchmarkConn{}, nil))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, conn := range conns {
+			conn.Write(testBenchmarkMessage)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package telnet
+
+import (
+	"io"
+)
+
+// copyChunkSize is how much ReadFrom (and WriteTo) reads at a time.
+const copyChunkSize = 64 * 1024
+
+// ReadFrom writes the data read from 'r' (until io.EOF, or an error) to the peer; escaped, like
+// Write does. It returns how many bytes (of data) it wrote. (It returns nil, not io.EOF, once 'r'
+// is done.)
+//
+// ReadFrom makes Conn fit the io.ReaderFrom interface; so that, for example:
+//
+//	io.Copy(conn, file)
+//
+// ... reads the file in large chunks; and only flushes once the file has (for now) run out of
+// what it can give right away (i.e., once a read returns less than a whole chunk), rather than
+// after every chunk. So a large transfer goes out a (full) buffer at a time; and something that
+// trickles in (such as what is typed at a terminal) still goes out as soon as it comes in.
+//
+// (While data is being transcoded, see Charset, or a WriteFilter is set, or newlines are being
+// translated, see NewlineNVT, it is written a chunk at a time, with Write.)
+func (clientConn *Conn) ReadFrom(r io.Reader) (n int64, err error) {
+	return copyChunks(r, clientConn.writeChunk, func() error {
+		return clientConn.closedErr(clientConn.dataWriter.flush())
+	})
+}
+
+// writeChunk writes (TELNET) data, for ReadFrom; straight to the internalDataWriter (see
+// internalDataWriter.writeChunk), unless it is to be transcoded (or filtered), in which case it
+// is written as Write would.
+func (clientConn *Conn) writeChunk(p []byte, flush bool) (n int, err error) {
+	if clientConn.isClosed() {
+		return 0, ErrClosed
+	}
+
+	queued, err := clientConn.dataWriter.admit(escapedLen(p))
+	if nil != err {
+		return 0, err
+	}
+
+	clientConn.transcodeMutex.Lock()
+	clientConn.dataWriter.highWater.unqueue(queued)
+	plain := 0 == len(clientConn.writeFilters) && nil == clientConn.transcodedCharset() && 0 == len(clientConn.untranscoded) && !clientConn.translatingWritten()
+	if !plain {
+		clientConn.transcodeMutex.Unlock()
+		return clientConn.writeData(p)
+	}
+	defer clientConn.transcodeMutex.Unlock()
+
+	n, err = clientConn.dataWriter.writeChunk(p, flush)
+	return n, clientConn.closedErr(err)
+}
+
+// WriteTo writes the data read from the Conn (until io.EOF, or an error) to 'w'; reading it in
+// large chunks. It returns how many bytes (of data) it wrote. (It returns nil, not io.EOF, once the
+// peer is done.)
+//
+// WriteTo makes Conn fit the io.WriterTo interface; so that, for example:
+//
+//	io.Copy(file, conn)
+//
+// ... reads the data in chunks of 64 KiB; each of which is written to 'w' as soon as it is read
+// (i.e., once what the peer has sent, so far, has been read).
+func (clientConn *Conn) WriteTo(w io.Writer) (n int64, err error) {
+	return copyChunksTo(w, clientConn.Read)
+}
+
+// ReadFrom writes the data read from 'r' (until io.EOF, or an error); escaped, like Write does. It
+// flushes once 'r' has (for now) run out of what it can give right away; rather than after every
+// chunk it reads. (See Conn.ReadFrom.)
+//
+// ReadFrom makes DataWriter fit the io.ReaderFrom interface.
+func (writer *DataWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	return copyChunks(r, writer.writer.writeChunk, writer.writer.flush)
+}
+
+// WriteTo writes the (un-escaped) TELNET data to 'w'; reading it in large chunks. (See
+// Conn.WriteTo.)
+//
+// WriteTo makes DataReader fit the io.WriterTo interface.
+func (reader *DataReader) WriteTo(w io.Writer) (n int64, err error) {
+	return copyChunksTo(w, reader.reader.Read)
+}
+
+// copyChunks reads from 'r' (until io.EOF, or an error) a chunk at a time; and writes each chunk
+// with 'write'. Which is only to flush once a read returns less than a whole chunk (or an error);
+// if it has to, as the read after a whole chunk returned nothing, 'flush' is what flushes.
+func copyChunks(r io.Reader, write func(p []byte, flush bool) (int, error), flush func() error) (n int64, err error) {
+	buffer := make([]byte, copyChunkSize)
+	flushed := true
+
+	for {
+		m, readErr := r.Read(buffer)
+		if 0 < m {
+			flushed = m < len(buffer) || nil != readErr
+
+			k, err := write(buffer[:m], flushed)
+			n += int64(k)
+			if nil != err {
+				return n, err
+			}
+			if k < m {
+				return n, io.ErrShortWrite
+			}
+		}
+
+		if nil == readErr {
+			continue
+		}
+
+		if !flushed {
+			if err := flush(); nil != err {
+				return n, err
+			}
+		}
+		if io.EOF == readErr {
+			return n, nil
+		}
+		return n, readErr
+	}
+}
+
+// copyChunksTo reads with 'read' (until io.EOF, or an error) a chunk at a time; and writes each
+// chunk to 'w'.
+func copyChunksTo(w io.Writer, read func(p []byte) (int, error)) (n int64, err error) {
+	buffer := make([]byte, copyChunkSize)
+
+	for {
+		m, readErr := read(buffer)
+		if 0 < m {
+			k, err := w.Write(buffer[:m])
+			n += int64(k)
+			if nil != err {
+				return n, err
+			}
+			if k < m {
+				return n, io.ErrShortWrite
+			}
+		}
+
+		if nil == readErr {
+			continue
+		}
+		if io.EOF == readErr {
+			return n, nil
+		}
+		return n, readErr
+	}
+}
@@ -0,0 +1,167 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testEscaped returns 'p' escaped; i.e., with each IAC doubled.
+func testEscaped(p []byte) []byte {
+	return bytes.ReplaceAll(p, []byte{IAC}, []byte{IAC, IAC})
+}
+
+func TestDataWriterReadFrom(t *testing.T) {
+
+	tests := []struct {
+		Size  int
+		Every int
+	}{
+		{Size: 0},
+		{Size: 5},
+		{Size: copyChunkSize},
+		{Size: 3*copyChunkSize + 17},
+		{Size: 3*copyChunkSize + 17, Every: 1},
+		{Size: 200 * 1024, Every: 1000},
+	}
+
+	for testNumber, test := range tests {
+		p := testPayload(test.Size, test.Every)
+
+		var buffer bytes.Buffer
+		writer := NewDataWriter(&buffer)
+
+		// (So that it is read, rather than written out with WriteTo.)
+		n, err := writer.ReadFrom(struct{ io.Reader }{bytes.NewReader(p)})
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := int64(len(p)), n; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := testEscaped(p), buffer.Bytes(); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %d bytes, but actually got %d (which were not the same).", testNumber, len(expected), len(actual))
+			continue
+		}
+	}
+}
+
+func TestDataWriterReadFromFlushes(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	source, sink := io.Pipe()
+	writer := NewDataWriter(local)
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := writer.ReadFrom(source)
+		copied <- err
+	}()
+
+	// (What trickles in goes out as soon as it comes in; before the source is done.)
+	go sink.Write([]byte("hello\xff"))
+	if expected, actual := "hello\xff\xff", string(testReadExactly(t, remote, 7)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	sink.Close()
+	select {
+	case err := <-copied:
+		if nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected ReadFrom to return, but actually it did not.")
+	}
+}
+
+func TestDataReaderWriteTo(t *testing.T) {
+
+	p := testPayload(3*copyChunkSize+17, 1000)
+
+	// (The commands are filtered out.)
+	wire := append([]byte{IAC, WILL, OptEcho}, testEscaped(p[:100])...)
+	wire = append(wire, IAC, NOP)
+	wire = append(wire, testEscaped(p[100:])...)
+	wire = append(wire, IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE)
+
+	var buffer bytes.Buffer
+	n, err := NewDataReader(bytes.NewReader(wire)).WriteTo(&buffer)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := int64(len(p)), n; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := p, buffer.Bytes(); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %d bytes, but actually got %d (which were not the same).", len(expected), len(actual))
+	}
+}
+
+func TestConnReadFrom(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	p := testPayload(3*copyChunkSize+17, 1000)
+
+	copied := make(chan int64, 1)
+	go func() {
+		n, err := io.Copy(conn, struct{ io.Reader }{bytes.NewReader(p)})
+		if nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		copied <- n
+	}()
+
+	expected := testEscaped(p)
+	remote.SetReadDeadline(time.Now().Add(3 * time.Second))
+	actual := make([]byte, len(expected))
+	if _, err := io.ReadFull(remote, actual); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %d bytes, but actually got %d (which were not the same).", len(expected), len(actual))
+	}
+	if expected, actual := int64(len(p)), <-copied; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+}
+
+func TestConnWriteTo(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+
+	conn := newConn(local, nil)
+	p := testPayload(3*copyChunkSize+17, 1000)
+
+	go func() {
+		remote.Write(append(testEscaped(p[:100]), IAC, NOP))
+		remote.Write(testEscaped(p[100:]))
+		remote.Close()
+	}()
+
+	var buffer bytes.Buffer
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, err := io.Copy(&buffer, conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := int64(len(p)), n; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := p, buffer.Bytes(); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %d bytes, but actually got %d (which were not the same).", len(expected), len(actual))
+	}
+}
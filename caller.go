@@ -13,6 +13,8 @@ package telnet
 //
 // The Reader's Read method "un-escapes" TELNET (and TELNETS) data, and filters
 // out TELNET (and TELNETS) command sequences.
+//
+// (For a caller that is given the *Conn itself, and a context.Context, see ContextCaller.)
 type Caller interface {
 	CallTELNET(Context, Writer, Reader)
 }
@@ -0,0 +1,90 @@
+package telnet
+
+// SetEcho sets whether we do the echoing (of what the peer types); rather than the peer doing it
+// itself. I.e., it offers (sends a WILL for) ECHO, if 'enabled' is true; and stops (sends a WONT
+// for) it, if 'enabled' is false. This is for servers; as it is the client that echoes, unless
+// the server does. For example, for reading a password:
+//
+//	conn.SetEcho(true) // (The client stops echoing; but nothing is echoed back to it.)
+//	password, err := conn.ReadLine()
+//	conn.SetEcho(false)
+//
+// (See ReadPassword; which does that.)
+//
+// It does not wait for the peer to answer. (The peer's answer is received while the Conn is being
+// read; such as by the ReadLine that comes after it.) Whether we are doing the echoing, once the
+// peer agrees to it, is what Echoing returns.
+//
+// (Having the Conn do the echoing is up to the caller; i.e., writing back what is read.)
+func (clientConn *Conn) SetEcho(enabled bool) error {
+	return clientConn.negotiator.request(OptEcho, true, enabled)
+}
+
+// Echoing returns whether we are doing the echoing (of what the peer types); i.e., whether the
+// ECHO option is enabled on our side. (See SetEcho.)
+func (clientConn *Conn) Echoing() bool {
+	local, _ := clientConn.OptionEnabled(OptEcho)
+	return local
+}
+
+// ReadPassword reads a line (like ReadLine does); with the peer not echoing it. For example:
+//
+//	fmt.Fprint(conn, "Password: ")
+//	password, err := conn.ReadPassword()
+//	fmt.Fprint(conn, "\r\n")
+//
+// I.e., unless we are already doing the echoing (see Echoing), it offers ECHO (see SetEcho) before
+// reading the line; so that the client stops echoing. And, once the line is read, it stops ECHO
+// again. (Nothing is echoed back; not even the end of the line. So, as in the example, what is
+// written next usually starts with a new line.)
+//
+// If the peer refuses ECHO, then it still reads the line; but the peer echoes it.
+func (clientConn *Conn) ReadPassword() (string, error) {
+	echoing := clientConn.Echoing()
+	if !echoing {
+		if err := clientConn.SetEcho(true); nil != err {
+			return "", err
+		}
+	}
+
+	line, err := clientConn.ReadLine()
+
+	if !echoing {
+		if disableErr := clientConn.SetEcho(false); nil == err {
+			err = disableErr
+		}
+	}
+
+	return line, err
+}
+
+// EnableCharacterMode asks the peer for "character at a time" mode; i.e., offers (sends a WILL
+// for) SUPPRESS-GO-AHEAD, asks the peer to (sends a DO for) SUPPRESS-GO-AHEAD too, and offers ECHO
+// (see SetEcho). This is for servers; such as ones with line editing, or full screen programs,
+// that have to get each key as it is typed (rather than a line at a time), and do the echoing
+// themselves. For example:
+//
+//	if err := conn.EnableCharacterMode(); nil != err {
+//		return err
+//	}
+//
+// It does not wait for the peer to answer. (The peer's answers are received while the Conn is
+// being read; see WaitForNegotiations, for waiting on them from some other goroutine.) Whether
+// the peer agreed to it is what CharacterMode returns.
+func (clientConn *Conn) EnableCharacterMode() error {
+	if err := clientConn.negotiator.request(OptSuppressGoAhead, true, true); nil != err {
+		return err
+	}
+	if err := clientConn.negotiator.request(OptSuppressGoAhead, false, true); nil != err {
+		return err
+	}
+
+	return clientConn.SetEcho(true)
+}
+
+// CharacterMode returns whether the Conn is in "character at a time" mode; i.e., whether both
+// ECHO and SUPPRESS-GO-AHEAD are enabled on our side. (See EnableCharacterMode.)
+func (clientConn *Conn) CharacterMode() bool {
+	suppressGoAhead, _ := clientConn.OptionEnabled(OptSuppressGoAhead)
+	return suppressGoAhead && clientConn.Echoing()
+}
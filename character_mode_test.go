@@ -0,0 +1,98 @@
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnReadPassword(t *testing.T) {
+
+	tests := []struct {
+		Answer   []byte
+		Expected []byte // (What is sent once the line is read.)
+	}{
+		{
+			Answer:   []byte{IAC, DO, OptEcho},
+			Expected: []byte{IAC, WONT, OptEcho},
+		},
+		{
+			// (The peer refuses ECHO; so there is nothing to stop.)
+			Answer:   []byte{IAC, DONT, OptEcho},
+			Expected: nil,
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+		defer local.Close()
+		defer remote.Close()
+
+		conn := newConn(local, nil)
+
+		type result struct {
+			password string
+			err      error
+		}
+		results := make(chan result, 1)
+		go func() {
+			password, err := conn.ReadPassword()
+			results <- result{password, err}
+		}()
+
+		if expected, actual := []byte{IAC, WILL, OptEcho}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			continue
+		}
+
+		remote.Write(append(append([]byte(nil), test.Answer...), "secret\r\n"...))
+
+		if nil != test.Expected {
+			if expected, actual := test.Expected, testReadExactly(t, remote, len(test.Expected)); !bytes.Equal(expected, actual) {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		}
+
+		select {
+		case result := <-results:
+			if nil != result.err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, result.err, result.err)
+			}
+			if expected, actual := "secret", result.password; expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("For test #%d, expected ReadPassword to return, but actually it did not.", testNumber)
+		}
+	}
+}
+
+func TestConnEnableCharacterMode(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	sent := testReadLater(remote, 9)
+	if err := conn.EnableCharacterMode(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []byte{IAC, WILL, OptSuppressGoAhead, IAC, DO, OptSuppressGoAhead, IAC, WILL, OptEcho}, <-sent; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	if conn.CharacterMode() {
+		t.Errorf("Expected to not be in character mode yet, but actually it was.")
+	}
+
+	remote.Write([]byte{IAC, DO, OptSuppressGoAhead, IAC, WILL, OptSuppressGoAhead, IAC, DO, OptEcho})
+	for deadline := time.Now().Add(3 * time.Second); !conn.CharacterMode() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if !conn.CharacterMode() {
+		t.Errorf("Expected to be in character mode, but actually it was not.")
+	}
+	if !conn.Echoing() {
+		t.Errorf("Expected to be echoing, but actually it was not.")
+	}
+}
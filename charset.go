@@ -0,0 +1,366 @@
+package telnet
+
+import (
+	"golang.org/x/text/encoding"
+
+	"fmt"
+	"unicode/utf8"
+)
+
+// Charset is a single-byte character set; such as Latin-1, or Windows-1252 (CP1252). It is what
+// the fallback charset has to be. (See SetFallbackCharset.)
+//
+// The charmaps of golang.org/x/text/encoding/charmap are Charsets; so, for example:
+//
+//	conn.SetFallbackCharset(charmap.Windows1252)
+//
+// Any other (single-byte) golang.org/x/text/encoding Encoding can be made into one with
+// EncodingCharset.
+//
+// (It is byte by byte, rather than an Encoding's Transformers, as the data is transcoded
+// between the TELNET commands; which can come in the middle of what a multi-byte encoding
+// would need to see whole.)
+type Charset interface {
+	// DecodeByte returns the rune that 'b' is.
+	DecodeByte(b byte) rune
+
+	// EncodeRune returns the byte that 'r' is; and false if it cannot be encoded.
+	EncodeRune(r rune) (b byte, ok bool)
+}
+
+// Latin1 is the ISO 8859-1 charset; where each byte is the Unicode code point of the same value.
+var Latin1 Charset = internalLatin1{}
+
+type internalLatin1 struct{}
+
+func (internalLatin1) DecodeByte(b byte) rune {
+	return rune(b)
+}
+
+func (internalLatin1) EncodeRune(r rune) (byte, bool) {
+	if r < 0 || 0xff < r {
+		return 0, false
+	}
+	return byte(r), true
+}
+
+func (internalLatin1) String() string {
+	return "ISO-8859-1"
+}
+
+// EncodingCharset returns 'enc' as a Charset; 'enc' has to be a single-byte encoding. (If 'enc'
+// is already a Charset, such as a golang.org/x/text/encoding/charmap Charmap, then it is
+// returned as is.) For example:
+//
+//	conn.SetFallbackCharset(telnet.EncodingCharset(myEncoding))
+//
+// Each byte is decoded (once) with the Encoding's Decoder. A byte that does not decode to a
+// (single) rune is decoded as U+FFFD; and a rune that no byte decodes to cannot be encoded.
+func EncodingCharset(enc encoding.Encoding) Charset {
+	if charset, ok := enc.(Charset); ok {
+		return charset
+	}
+
+	charset := &internalEncodingCharset{
+		encodes: map[rune]byte{},
+		name:    fmt.Sprintf("%T", enc),
+	}
+	if stringer, ok := enc.(fmt.Stringer); ok {
+		charset.name = stringer.String()
+	}
+
+	decoder := enc.NewDecoder()
+	for i := range charset.decodes {
+		r := utf8.RuneError
+		if decoded, err := decoder.Bytes([]byte{byte(i)}); nil == err && 1 == utf8.RuneCount(decoded) {
+			r, _ = utf8.DecodeRune(decoded)
+		}
+
+		charset.decodes[i] = r
+		if _, taken := charset.encodes[r]; utf8.RuneError != r && !taken {
+			charset.encodes[r] = byte(i)
+		}
+	}
+
+	return charset
+}
+
+// internalEncodingCharset is an encoding.Encoding, as a Charset; from EncodingCharset.
+type internalEncodingCharset struct {
+	decodes [256]rune
+	encodes map[rune]byte
+	name    string
+}
+
+func (charset *internalEncodingCharset) DecodeByte(b byte) rune {
+	return charset.decodes[b]
+}
+
+func (charset *internalEncodingCharset) EncodeRune(r rune) (byte, bool) {
+	b, ok := charset.encodes[r]
+	return b, ok
+}
+
+func (charset *internalEncodingCharset) String() string {
+	return charset.name
+}
+
+// charsetUnencodable is what is sent for a rune that the charset cannot encode.
+const charsetUnencodable = '?'
+
+// SetFallbackCharset sets the charset that is used when no charset has been negotiated (with the
+// CHARSET option); if 'charset' is nil, then it is Latin-1 (see Latin1). It is what the bytes that
+// are not (valid) UTF-8 are decoded with (by the InvalidUTF8Fallback policy); and, once
+// SetFallbackTranscoding is on, what all the data is transcoded from (and to).
+func (clientConn *Conn) SetFallbackCharset(charset Charset) {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	clientConn.fallbackCharset = charset
+}
+
+// SetFallbackTranscoding sets whether, while no charset has been negotiated (with the CHARSET
+// option; see SetNegotiatedCharset), the data is in the fallback charset (see SetFallbackCharset);
+// rather than in UTF-8. This is for (legacy) clients that never negotiate CHARSET, but send (say)
+// Latin-1, or CP1252. For example:
+//
+//	conn.SetFallbackCharset(charmap.Windows1252)
+//	conn.SetFallbackTranscoding(true)
+//
+// While it is on, what is read (with Read, and everything else that reads data) is transcoded
+// from the fallback charset to UTF-8; and what is written (with Write) is transcoded from UTF-8
+// to the fallback charset. (The TELNET commands, and the escaping of IAC, are not affected; they
+// are on the layer below.) A rune that the fallback charset cannot encode is sent as a '?'.
+//
+// The default is off. (See also Server.FallbackCharset.)
+func (clientConn *Conn) SetFallbackTranscoding(on bool) {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	clientConn.fallbackTranscoding = on
+}
+
+// SetNegotiatedCharset sets the charset that was agreed on with the peer (with the CHARSET option),
+// by its name (Ex: "UTF-8"); and, if it is not UTF-8, the Charset for it. From then on the fallback
+// charset is not transcoded from (or to); the negotiated one is instead, unless 'charset' is nil.
+//
+// It can be called at any time; including part way through the session. (A rune that was written
+// part of, before, is still sent whole.)
+func (clientConn *Conn) SetNegotiatedCharset(name string, charset Charset) {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	clientConn.negotiatedCharset = charset
+	clientConn.negotiatedCharsetName = name
+	clientConn.charsetNegotiated = true
+}
+
+// Charset returns the name of the charset that the data is in (as far as the Conn knows): the one
+// negotiated (see SetNegotiatedCharset); otherwise the fallback charset, if SetFallbackTranscoding
+// is on; and otherwise "UTF-8".
+func (clientConn *Conn) Charset() string {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	switch {
+	case clientConn.charsetNegotiated:
+		return clientConn.negotiatedCharsetName
+	case clientConn.fallbackTranscoding:
+		return charsetName(clientConn.fallback())
+	default:
+		return "UTF-8"
+	}
+}
+
+// charsetName returns the name of 'charset'; if it has one.
+func charsetName(charset Charset) string {
+	if stringer, ok := charset.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", charset)
+}
+
+// fallback returns the fallback charset. (The textMutex must be held.)
+func (clientConn *Conn) fallback() Charset {
+	if nil == clientConn.fallbackCharset {
+		return Latin1
+	}
+	return clientConn.fallbackCharset
+}
+
+// transcodedCharset returns the charset that the data is transcoded from (and to); or nil, if
+// it is not.
+func (clientConn *Conn) transcodedCharset() Charset {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	switch {
+	case clientConn.charsetNegotiated:
+		return clientConn.negotiatedCharset
+	case clientConn.fallbackTranscoding:
+		return clientConn.fallback()
+	default:
+		return nil
+	}
+}
+
+// readData reads (TELNET) data; transcoded to UTF-8, if it is being, and passed through the
+// ReadFilters (see AddReadFilter). (The readMutex must be held.)
+func (clientConn *Conn) readData(p []byte) (n int, err error) {
+	if clientConn.isClosed() {
+		return 0, clientConn.closedReadErr()
+	}
+
+	if clientConn.hasReadFilters() {
+		return clientConn.readFiltered(p)
+	}
+	return clientConn.readTranscodedData(p)
+}
+
+// readTranscodedData reads (TELNET) data; transcoded to UTF-8, if it is being. (The readMutex
+// must be held.)
+func (clientConn *Conn) readTranscodedData(p []byte) (n int, err error) {
+	defer func() {
+		err = clientConn.readErr(err)
+	}()
+
+	if 0 < len(clientConn.transcoded) {
+		return clientConn.readTranscoded(p), nil
+	}
+
+	charset := clientConn.transcodedCharset()
+	if nil == charset {
+		return clientConn.readTranslated(p)
+	}
+
+	var buffer [256]byte
+	size := len(buffer)
+	if len(p) < size {
+		size = len(p)
+	}
+
+	n, err = clientConn.readTranslated(buffer[:size])
+
+	var encoded [utf8.UTFMax]byte
+	for _, b := range buffer[:n] {
+		m := utf8.EncodeRune(encoded[:], charset.DecodeByte(b))
+		clientConn.transcoded = append(clientConn.transcoded, encoded[:m]...)
+	}
+
+	return clientConn.readTranscoded(p), err
+}
+
+// readTranscoded reads (into 'p') what has been transcoded (to UTF-8), but not read yet.
+func (clientConn *Conn) readTranscoded(p []byte) int {
+	n := copy(p, clientConn.transcoded)
+	clientConn.transcoded = clientConn.transcoded[n:]
+	if len(clientConn.transcoded) <= 0 {
+		clientConn.transcoded = nil
+	}
+
+	return n
+}
+
+// writeData writes (TELNET) data; transcoded from UTF-8, if it is being.
+func (clientConn *Conn) writeData(p []byte) (n int, err error) {
+	if clientConn.isClosed() {
+		return 0, ErrClosed
+	}
+	defer func() {
+		err = clientConn.closedErr(err)
+	}()
+
+	queued, err := clientConn.dataWriter.admit(escapedLen(p))
+	if nil != err {
+		return 0, err
+	}
+
+	clientConn.transcodeMutex.Lock()
+	defer clientConn.transcodeMutex.Unlock()
+	clientConn.dataWriter.highWater.unqueue(queued)
+
+	translating := clientConn.translatingWritten()
+	if 0 == len(clientConn.writeFilters) && !translating {
+		return clientConn.writeTranscoded(p)
+	}
+
+	// (The WriteFilters, and the newline translation, take all of 'p'; so, even if writing fails,
+	// none of it is to be written again.)
+	data := clientConn.filterWritten(p)
+	if translating {
+		data = clientConn.translateWritten(data)
+	}
+	if _, err := clientConn.writeTranscoded(data); nil != err {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// writeTranscoded writes (TELNET) data; transcoded from UTF-8, if it is being. (The transcodeMutex
+// must be held.)
+func (clientConn *Conn) writeTranscoded(p []byte) (n int, err error) {
+	charset := clientConn.transcodedCharset()
+	if nil == charset && 0 == len(clientConn.untranscoded) {
+		return clientConn.dataWriter.Write(p)
+	}
+
+	// (The start of a rune, written before, comes first.)
+	data := p
+	started := len(clientConn.untranscoded)
+	if 0 < started {
+		data = append(clientConn.untranscoded, p...)
+		clientConn.untranscoded = nil
+	}
+
+	if nil == charset {
+		if m, err := clientConn.dataWriter.Write(data); nil != err {
+			return clientConn.untranscodedTaken(data, started, m), err
+		}
+		return len(p), nil
+	}
+
+	// (ends is, for each encoded byte, where the rune it was encoded from ends in 'data'.)
+	encoded := make([]byte, 0, len(data))
+	ends := make([]int, 0, len(data))
+	for end := 0; end < len(data); {
+		if !utf8.FullRune(data[end:]) {
+			// (The rest of the rune comes in the next Write.)
+			clientConn.untranscoded = append([]byte(nil), data[end:]...)
+			break
+		}
+
+		r, size := utf8.DecodeRune(data[end:])
+		end += size
+
+		b, ok := charset.EncodeRune(r)
+		if !ok {
+			b = charsetUnencodable
+		}
+		encoded = append(encoded, b)
+		ends = append(ends, end)
+	}
+
+	if m, err := clientConn.dataWriter.Write(encoded); nil != err {
+		// (What was not taken, including the start of a rune at the end, is to be written again.)
+		clientConn.untranscoded = nil
+		taken := 0
+		if 0 < m {
+			taken = ends[m-1]
+		}
+		return clientConn.untranscodedTaken(data, started, taken), err
+	}
+	return len(p), nil
+}
+
+// untranscodedTaken returns how many bytes of 'p' were taken, when 'taken' of 'data' were (where
+// 'data' is the 'started' bytes of the start of a rune, written before, followed by 'p'). If the
+// start of that rune was not taken, then it is kept (for the next Write) again. (The
+// transcodeMutex must be held.)
+func (clientConn *Conn) untranscodedTaken(data []byte, started int, taken int) int {
+	if taken < started {
+		clientConn.untranscoded = append([]byte(nil), data[taken:started]...)
+		return 0
+	}
+
+	return taken - started
+}
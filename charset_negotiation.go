@@ -0,0 +1,235 @@
+package telnet
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// The CHARSET (RFC 2066) subnegotiation commands.
+const (
+	charsetREQUEST         = 1
+	charsetACCEPTED        = 2
+	charsetREJECTED        = 3
+	charsetTTABLEIS        = 4
+	charsetTTABLEREJECTED  = 5
+	charsetTTABLEACK       = 6
+	charsetTTABLENAK       = 7
+	charsetSeparator       = ';'
+	charsetTTABLEPrefix    = "[TTABLE]"
+	charsetTTABLEPrefixLen = len(charsetTTABLEPrefix) + 1 // (And the version.)
+)
+
+// A NamedCharset is a charset, with the name that it is negotiated by (with the CHARSET option);
+// such as "UTF-8", or "ISO-8859-1". (Which are the IANA names; see RFC 2066.) For example:
+//
+//	cp437 := telnet.NamedCharset{Name: "IBM437", Charset: charmap.CodePage437}
+//
+// A nil Charset is UTF-8; i.e., the data is not transcoded.
+type NamedCharset struct {
+	Name    string
+	Charset Charset
+}
+
+var (
+	// CharsetUTF8 is UTF-8; which the data is in, as-is.
+	CharsetUTF8 = NamedCharset{Name: "UTF-8"}
+
+	// CharsetLatin1 is ISO 8859-1 (Latin-1). (See Latin1.)
+	CharsetLatin1 = NamedCharset{Name: "ISO-8859-1", Charset: Latin1}
+)
+
+// RequestCharset offers (i.e., sends a WILL for) the CHARSET option (RFC 2066); and, once it is
+// enabled (on either side), asks the peer to agree to one of 'charsets' (most preferred first).
+// This is for servers; so that a client that can, agrees to (say) UTF-8, and one that can not, to
+// Latin-1. For example:
+//
+//	conn.SetFallbackCharset(charmap.CodePage437)
+//	conn.SetFallbackTranscoding(true)
+//
+//	err := conn.RequestCharset(telnet.CharsetUTF8, telnet.CharsetLatin1)
+//
+// Once the peer agrees to one of them, it is what the data is transcoded from (and to); see
+// SetNegotiatedCharset. So the handlers always read (and write) UTF-8. If the peer does not agree
+// to any of them (or does not do CHARSET at all), then the fallback charset is what applies (see
+// SetFallbackTranscoding); as it does until the peer agrees to one. (See Charset.)
+//
+// A REQUEST from the peer, while waiting on the answer to ours, is rejected (as RFC 2066 has the
+// server do); otherwise it is answered (as with AcceptCharset).
+//
+// (It registers an OptionHandler for CHARSET; replacing any that was registered before.)
+func (clientConn *Conn) RequestCharset(charsets ...NamedCharset) error {
+	return clientConn.RegisterOption(OptCharset, &internalCharset{
+		charsets: append([]NamedCharset(nil), charsets...),
+		request:  true,
+	})
+}
+
+// AcceptCharset agrees to the CHARSET option (RFC 2066), if the peer offers (or asks for) it; and
+// answers the peer asking it to agree to a charset with the first of the (peer's) charsets that is
+// one of 'charsets'. This is for clients. For example:
+//
+//	err := conn.AcceptCharset(telnet.CharsetUTF8, telnet.CharsetLatin1)
+//
+// The names are matched without regard to case. Once a charset is agreed to, it is what the data
+// is transcoded from (and to); see SetNegotiatedCharset. If none of them are asked for, then the
+// peer is told so (with REJECTED).
+//
+// (It registers an OptionHandler for CHARSET; replacing any that was registered before.)
+func (clientConn *Conn) AcceptCharset(charsets ...NamedCharset) error {
+	return clientConn.RegisterOption(OptCharset, &internalCharset{
+		charsets: append([]NamedCharset(nil), charsets...),
+	})
+}
+
+// internalCharset is the (CHARSET) OptionHandler that RequestCharset (and AcceptCharset) registers.
+type internalCharset struct {
+	charsets []NamedCharset
+	request  bool // (Whether to ask the peer to agree to one of the charsets.)
+
+	mutex  sync.Mutex
+	sender OptionSender
+
+	// requested is whether a REQUEST was sent (since CHARSET was enabled); and waiting is whether
+	// its answer has not been received yet.
+	requested bool
+	waiting   bool
+}
+
+func (charset *internalCharset) Register(sender OptionSender) OptionSupport {
+	charset.mutex.Lock()
+	charset.sender = sender
+	charset.mutex.Unlock()
+
+	return OptionSupport{
+		Local:        true,
+		Remote:       true,
+		RequestLocal: charset.request,
+	}
+}
+
+func (charset *internalCharset) LocalChanged(enabled bool) {
+	charset.changed(enabled)
+}
+
+func (charset *internalCharset) RemoteChanged(enabled bool) {
+	charset.changed(enabled)
+}
+
+// changed sends the REQUEST once CHARSET is enabled (on either side); if it is to.
+func (charset *internalCharset) changed(enabled bool) {
+	charset.mutex.Lock()
+	sender := charset.sender
+	if nil == sender {
+		charset.mutex.Unlock()
+		return
+	}
+	local, remote := sender.Conn().OptionEnabled(OptCharset)
+	if !local && !remote {
+		charset.requested, charset.waiting = false, false
+	}
+	send := enabled && charset.request && !charset.requested
+	if send {
+		charset.requested, charset.waiting = true, true
+	}
+	charset.mutex.Unlock()
+
+	if !send {
+		return
+	}
+
+	payload := []byte{charsetREQUEST}
+	for _, named := range charset.charsets {
+		payload = append(append(payload, charsetSeparator), named.Name...)
+	}
+	sender.SendSubnegotiation(payload)
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB CHARSET REQUEST [ "[TTABLE]" <version> ] <sep> <charset> <sep> <charset> ... IAC SE
+//	IAC SB CHARSET ACCEPTED <charset> IAC SE
+//	IAC SB CHARSET REJECTED IAC SE
+//	IAC SB CHARSET TTABLE-IS ... IAC SE
+//
+// (A translation table is never agreed to; it is answered with TTABLE-REJECTED.)
+func (charset *internalCharset) Subnegotiation(payload []byte) {
+	if len(payload) < 1 {
+		return
+	}
+
+	charset.mutex.Lock()
+	sender := charset.sender
+	charset.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+
+	switch payload[0] {
+	case charsetREQUEST:
+		charset.receiveRequest(sender, payload[1:])
+	case charsetACCEPTED:
+		charset.mutex.Lock()
+		waiting := charset.waiting
+		charset.waiting = false
+		charset.mutex.Unlock()
+
+		if named, ok := charset.find(string(payload[1:])); waiting && ok {
+			sender.Conn().SetNegotiatedCharset(named.Name, named.Charset)
+		}
+	case charsetREJECTED:
+		charset.mutex.Lock()
+		charset.waiting = false
+		charset.mutex.Unlock()
+	case charsetTTABLEIS:
+		sender.SendSubnegotiation([]byte{charsetTTABLEREJECTED})
+	}
+}
+
+// receiveRequest answers the peer asking to agree to one of the charsets in 'p' (i.e., what comes
+// after the REQUEST).
+func (charset *internalCharset) receiveRequest(sender OptionSender, p []byte) {
+	charset.mutex.Lock()
+	waiting := charset.waiting
+	charset.mutex.Unlock()
+
+	if waiting {
+		// (Both sides asked at once; our REQUEST is the one that is answered.)
+		sender.SendSubnegotiation([]byte{charsetREJECTED})
+		return
+	}
+
+	if bytes.HasPrefix(p, []byte(charsetTTABLEPrefix)) && charsetTTABLEPrefixLen <= len(p) {
+		p = p[charsetTTABLEPrefixLen:]
+	}
+	if len(p) < 2 {
+		sender.SendSubnegotiation([]byte{charsetREJECTED})
+		return
+	}
+
+	separator := p[0]
+	for _, name := range bytes.Split(p[1:], []byte{separator}) {
+		named, ok := charset.find(string(name))
+		if !ok {
+			continue
+		}
+
+		// (With the name as the peer sent it. What is written after it is in the charset.)
+		sender.Conn().SetNegotiatedCharset(named.Name, named.Charset)
+		sender.SendSubnegotiation(append([]byte{charsetACCEPTED}, name...))
+		return
+	}
+
+	sender.SendSubnegotiation([]byte{charsetREJECTED})
+}
+
+// find returns the one of the charsets that is named 'name' (without regard to case).
+func (charset *internalCharset) find(name string) (NamedCharset, bool) {
+	for _, named := range charset.charsets {
+		if strings.EqualFold(named.Name, name) {
+			return named, true
+		}
+	}
+	return NamedCharset{}, false
+}
@@ -0,0 +1,97 @@
+package telnet
+
+import (
+	"time"
+
+	"testing"
+)
+
+func TestConnRequestCharset(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RequestCharset(CharsetUTF8, CharsetLatin1)
+	}()
+
+	if expected, actual := string([]byte{IAC, WILL, OptCharset}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	remote.Write([]byte{IAC, DO, OptCharset})
+
+	request := string(append(append([]byte{IAC, SB, OptCharset, charsetREQUEST}, ";UTF-8;ISO-8859-1"...), IAC, SE))
+	if expected, actual := request, string(testReadExactly(t, remote, len(request))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	remote.Write(append(append([]byte{IAC, SB, OptCharset, charsetACCEPTED}, "ISO-8859-1"...), IAC, SE))
+	for deadline := time.Now().Add(3 * time.Second); "ISO-8859-1" != conn.Charset() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if expected, actual := "ISO-8859-1", conn.Charset(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (What is written is then transcoded.)
+	sent := testReadLater(remote, 4)
+	conn.Write([]byte("café"))
+	if expected, actual := "caf\xe9", string(<-sent); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnAcceptCharset(t *testing.T) {
+
+	tests := []struct {
+		Request         string
+		Expected        []byte
+		ExpectedCharset string
+	}{
+		{
+			Request:         " KOI8-R utf-8",
+			Expected:        append([]byte{charsetACCEPTED}, "utf-8"...),
+			ExpectedCharset: "UTF-8",
+		},
+		{
+			Request:         "[TTABLE]\x01;ISO-8859-1;UTF-8",
+			Expected:        append([]byte{charsetACCEPTED}, "ISO-8859-1"...),
+			ExpectedCharset: "ISO-8859-1",
+		},
+		{
+			Request:         ";KOI8-R",
+			Expected:        []byte{charsetREJECTED},
+			ExpectedCharset: "UTF-8",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+		conn.SetNegotiatedCharset("UTF-8", nil)
+
+		if err := conn.AcceptCharset(CharsetUTF8, CharsetLatin1); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		remote.Write([]byte{IAC, WILL, OptCharset})
+		if expected, actual := string([]byte{IAC, DO, OptCharset}), string(testReadExactly(t, remote, 3)); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		remote.Write(append(append([]byte{IAC, SB, OptCharset, charsetREQUEST}, test.Request...), IAC, SE))
+
+		expected := string(append(append([]byte{IAC, SB, OptCharset}, test.Expected...), IAC, SE))
+		if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedCharset, conn.Charset(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package telnet
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+
+	"io"
+
+	"testing"
+)
+
+// testCP1252 is (a little of) the Windows-1252 charset; which differs from Latin-1 in (some of)
+// 0x80 to 0x9F.
+type testCP1252 struct{}
+
+func (testCP1252) DecodeByte(b byte) rune {
+	if 0x80 == b {
+		return '€'
+	}
+	return rune(b)
+}
+
+func (testCP1252) EncodeRune(r rune) (byte, bool) {
+	switch {
+	case '€' == r:
+		return 0x80, true
+	case 0 <= r && r <= 0xff:
+		return byte(r), true
+	default:
+		return 0, false
+	}
+}
+
+func TestConnFallbackTranscodingRead(t *testing.T) {
+
+	tests := []struct {
+		Charset  Charset
+		Sends    [][]byte
+		Expected string
+	}{
+		{
+			Sends:    [][]byte{[]byte("caf\xe9\r\n")},
+			Expected: "café\r\n",
+		},
+		{
+			// (The IAC processing happens before transcoding; so an escaped IAC is a 'ÿ'.)
+			Sends:    [][]byte{{'a', IAC, IAC, IAC, NOP, 0xe9}},
+			Expected: "aÿé",
+		},
+		{
+			Charset:  testCP1252{},
+			Sends:    [][]byte{{'5', 0x80}, {' ', 0xe9}},
+			Expected: "5€ é",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		conn.SetFallbackCharset(test.Charset)
+		conn.SetFallbackTranscoding(true)
+
+		for _, p := range test.Sends {
+			send(p...)
+		}
+
+		// (Read into a buffer that is too short for all of it; and for some of the runes.)
+		var actual []byte
+		var buffer [1]byte
+		for len(actual) < len(test.Expected) {
+			n, err := conn.Read(buffer[:])
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+				break
+			}
+			actual = append(actual, buffer[:n]...)
+		}
+
+		if expected := test.Expected; expected != string(actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnFallbackTranscodingLine(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	conn.SetFallbackTranscoding(true)
+
+	send([]byte("Andr\xe9\r\n")...)
+
+	// The higher-level APIs get the transcoded data too.
+	line, err := conn.ReadLine()
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "André", line; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// Once a charset is negotiated, the fallback charset is no longer transcoded from.
+	conn.SetNegotiatedCharset("UTF-8", nil)
+	send([]byte("Andr\xc3\xa9\r\n")...)
+
+	line, err = conn.ReadLine()
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "André", line; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnFallbackTranscodingWrite(t *testing.T) {
+
+	tests := []struct {
+		Charset  Charset
+		Writes   []string
+		Expected string
+	}{
+		{
+			Writes:   []string{"café"},
+			Expected: "caf\xe9",
+		},
+		{
+			// (An 'ÿ' is 0xFF in Latin-1; so it is escaped, like an IAC.)
+			Writes:   []string{"ÿ!"},
+			Expected: "\xff\xff!",
+		},
+		{
+			// (What Latin-1 does not have is sent as a '?'.)
+			Writes:   []string{"5€"},
+			Expected: "5?",
+		},
+		{
+			Charset:  testCP1252{},
+			Writes:   []string{"5€"},
+			Expected: "5\x80",
+		},
+		{
+			// (The runes are split up, across writes.)
+			Writes:   []string{"caf\xc3", "\xa9 \xe2", "\x82", "\xac"},
+			Expected: "caf\xe9 ?",
+		},
+	}
+
+	for testNumber, test := range tests {
+		var c testBufferConn
+		conn := newConn(&c, nil)
+		conn.SetFallbackCharset(test.Charset)
+		conn.SetFallbackTranscoding(true)
+
+		for _, s := range test.Writes {
+			n, err := io.WriteString(conn, s)
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			}
+			if expected, actual := len(s), n; expected != actual {
+				t.Errorf("For test #%d, expected %d byte(s) to be written, but actually got %d.", testNumber, expected, actual)
+			}
+		}
+		conn.dataWriter.flush()
+
+		if expected, actual := test.Expected, c.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnNegotiatedCharsetPartWayThroughRune(t *testing.T) {
+
+	var c testBufferConn
+	conn := newConn(&c, nil)
+	conn.SetFallbackTranscoding(true)
+
+	io.WriteString(conn, "caf\xc3")
+
+	// (The rune that was written part of, before the charset was negotiated, is still sent whole.)
+	conn.SetNegotiatedCharset("UTF-8", nil)
+	io.WriteString(conn, "\xa9!")
+	conn.dataWriter.flush()
+
+	if expected, actual := "caf\xc3\xa9!", c.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnCharset(t *testing.T) {
+
+	var c testBufferConn
+	conn := newConn(&c, nil)
+
+	if expected, actual := "UTF-8", conn.Charset(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	conn.SetFallbackTranscoding(true)
+	if expected, actual := "ISO-8859-1", conn.Charset(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	conn.SetNegotiatedCharset("KOI8-R", nil)
+	if expected, actual := "KOI8-R", conn.Charset(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+// testEncoding is an encoding.Encoding that is not (also) a Charset.
+type testEncoding struct {
+	encoding.Encoding
+}
+
+func TestEncodingCharset(t *testing.T) {
+
+	if expected, actual := Charset(charmap.Windows1252), EncodingCharset(charmap.Windows1252); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	tests := []struct {
+		Encoding encoding.Encoding
+		Byte     byte
+		Rune     rune
+	}{
+		{
+			Encoding: charmap.Windows1252,
+			Byte:     'a',
+			Rune:     'a',
+		},
+		{
+			Encoding: charmap.Windows1252,
+			Byte:     0x80,
+			Rune:     '€',
+		},
+		{
+			Encoding: charmap.CodePage437,
+			Byte:     0xdb,
+			Rune:     '█',
+		},
+		{
+			Encoding: charmap.KOI8R,
+			Byte:     0xc1,
+			Rune:     'а',
+		},
+	}
+
+	for testNumber, test := range tests {
+		charset := EncodingCharset(testEncoding{test.Encoding})
+
+		if expected, actual := test.Rune, charset.DecodeByte(test.Byte); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		b, ok := charset.EncodeRune(test.Rune)
+		if !ok {
+			t.Errorf("For test #%d, expected %q to be encodable, but it was not.", testNumber, test.Rune)
+			continue
+		}
+		if expected, actual := test.Byte, b; expected != actual {
+			t.Errorf("For test #%d, expected 0x%02x, but actually got 0x%02x.", testNumber, expected, actual)
+			continue
+		}
+
+		if _, ok := charset.EncodeRune('あ'); ok {
+			t.Errorf("For test #%d, expected %q not to be encodable, but it was.", testNumber, 'あ')
+			continue
+		}
+	}
+}
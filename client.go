@@ -2,86 +2,76 @@ package telnet
 
 
 import (
+	"context"
 	"crypto/tls"
 )
 
 
 func DialAndCall(caller Caller) error {
-	conn, err := Dial()
-	if nil != err {
-		return err
-	}
-
-	client := &Client{Caller:caller}
-
-	return client.Call(conn)
+	return DialToAndCall("", caller)
 }
 
 
 func DialToAndCall(srvAddr string, caller Caller) error {
-	conn, err := DialTo(srvAddr)
-	if nil != err {
-		return err
-	}
-
 	client := &Client{Caller:caller}
 
-	return client.Call(conn)
+	return client.dialAndCall(context.Background(), srvAddr, nil)
 }
 
 
 func DialAndCallTLS(caller Caller, tlsConfig *tls.Config) error {
-	conn, err := DialTLS(tlsConfig)
-	if nil != err {
-		return err
-	}
+	return DialToAndCallTLS("", caller, tlsConfig)
+}
 
+func DialToAndCallTLS(srvAddr string, caller Caller, tlsConfig *tls.Config) error {
 	client := &Client{Caller:caller}
 
-	return client.Call(conn)
+	return client.dialAndCall(context.Background(), srvAddr, []Option{withTLS(tlsConfig)})
 }
 
-func DialToAndCallTLS(srvAddr string, caller Caller, tlsConfig *tls.Config) error {
-	conn, err := DialToTLS(srvAddr, tlsConfig)
+
+// dialAndCall dials 'srvAddr' (with a Dialer configured by 'options'; see NewDialer), and then
+// calls the caller with the connection (see CallContext).
+func (client *Client) dialAndCall(ctx context.Context, srvAddr string, options []Option) error {
+	conn, err := NewDialer(options...).DialToContext(ctx, srvAddr)
 	if nil != err {
 		return err
 	}
 
-	client := &Client{Caller:caller}
-
-	return client.Call(conn)
+	return client.CallContext(ctx, conn)
 }
 
 
 type Client struct {
 	Caller Caller
 
-	Logger Logger
-}
+	// ContextCaller (if not nil) is called instead of Caller. (See CallContext.)
+	ContextCaller ContextCaller
 
+	Logger Logger
 
-func (client *Client) Call(conn *Conn) error {
-
-	logger := client.logger()
-
-
-	caller := client.Caller
-	if nil == caller {
-		logger.Debug("Defaulted caller to StandardCaller.")
-		caller = StandardCaller
-	}
-
+	// Dialer (if not nil) is what DialTo (and DialToContext) dial with. (See NewDialer.)
+	Dialer *Dialer
 
-	var ctx Context = NewContext().InjectLogger(logger)
+	// TerminalTypes are what the Conns dialed (with DialTo) tell the server their terminal type
+	// is; most preferred first. (See Conn.SetTerminalTypes.) If it is empty, then "UNKNOWN" is.
+	TerminalTypes []string
 
-	var w Writer = conn
-	var r Reader = conn
+	// Width and Height are the size of the terminal that the Conns dialed (with DialTo) tell the
+	// server. (See Conn.SetWindowSize.) If they are zero, then 80 and 24 are.
+	Width  int
+	Height int
 
-	caller.CallTELNET(ctx, w, r)
-	conn.Close()
+	// Environment is the (environment) variables (such as "USER") that the Conns dialed (with
+	// DialTo) tell the server, if it asks for them. (See Conn.SetEnvironment.) If it is empty,
+	// then NEW-ENVIRON is refused.
+	Environment map[string]string
+}
 
 
-	return nil
+// Call calls the caller with 'conn'; and then closes it. (It is CallContext, that is never cancelled.)
+func (client *Client) Call(conn *Conn) error {
+	return client.CallContext(context.Background(), conn)
 }
 
 
@@ -0,0 +1,125 @@
+package telnet
+
+import (
+	"context"
+)
+
+// The size of the terminal that a Client tells the server, if it is not told otherwise.
+const (
+	clientDefaultWidth  = 80
+	clientDefaultHeight = 24
+)
+
+// DialTo makes a (un-secure, unless the Dialer has a TLSConfig) TELNET client connection to
+// 'addr'; with the Client's negotiation profile in place. So that it can just be read from, and
+// written to; without the option negotiation of the server (such as a router, or a BBS) stalling
+// it. For example:
+//
+//	client := &telnet.Client{
+//		TerminalTypes: []string{"ANSI", "VT100"},
+//		Width:         132,
+//		Height:        50,
+//	}
+//
+//	conn, err := client.DialTo("bbs.example.net:23")
+//	if nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	defer conn.Close()
+//
+// The profile is what is (usually) expected of an interactive TELNET client:
+//
+// SUPPRESS-GO-AHEAD is agreed to, on both sides (if the server asks for, or offers, it).
+//
+// ECHO is agreed to, on the server's side; i.e., the server can do the echoing. (It is refused
+// on ours.)
+//
+// TERMINAL-TYPE is agreed to; and each time the server asks for the terminal type, it is told
+// (the next one of) the TerminalTypes. (See Conn.SetTerminalTypes.)
+//
+// NEW-ENVIRON is agreed to, if there is an Environment; and the server is told (those it asks
+// for of) the variables in it. (See Conn.SetEnvironment.)
+//
+// NAWS is offered; and, once the server agrees to it, it is told the Width and Height. (See
+// Conn.SetWindowSize. Which is also how to tell the server, later, that the terminal was resized.)
+//
+// Any other option is refused; i.e., a DO is answered with a WONT, and a WILL with a DONT.
+//
+// (The OptionHandlers of the Dialer take the place of the profile, for the options that they are
+// for.)
+//
+// The server's negotiations are answered while the Conn is being read; so what is written first
+// does not have to wait on them.
+func (client *Client) DialTo(addr string) (*Conn, error) {
+	return client.DialToContext(context.Background(), addr)
+}
+
+// DialToContext is like DialTo; except that it gives up (dialing) once 'ctx' is done.
+func (client *Client) DialToContext(ctx context.Context, addr string) (*Conn, error) {
+	dialer := client.Dialer
+	if nil == dialer {
+		dialer = &Dialer{Logger: client.Logger}
+	}
+
+	conn, err := dialer.dialTo(ctx, "tcp", addr)
+	if nil != err {
+		return nil, err
+	}
+
+	if err := client.negotiate(conn, dialer.OptionHandlers); nil != err {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// negotiate puts the Client's negotiation profile in place on 'conn'; except for the options that
+// are in 'handlers' (which were already registered, by the Dialer).
+func (client *Client) negotiate(conn *Conn, handlers OptionHandlers) error {
+	registered := func(option byte) bool {
+		_, ok := handlers[option]
+		return ok
+	}
+
+	if !registered(OptSuppressGoAhead) {
+		if err := conn.RegisterOption(OptSuppressGoAhead, SimpleOption(OptionSupport{Local: true, Remote: true})); nil != err {
+			return err
+		}
+	}
+
+	if !registered(OptEcho) {
+		if err := conn.RegisterOption(OptEcho, SimpleOption(OptionSupport{Remote: true})); nil != err {
+			return err
+		}
+	}
+
+	if !registered(OptTerminalType) {
+		if err := conn.SetTerminalTypes(client.TerminalTypes...); nil != err {
+			return err
+		}
+	}
+
+	if !registered(OptNewEnviron) && 0 < len(client.Environment) {
+		if err := conn.SetEnvironment(client.Environment); nil != err {
+			return err
+		}
+	}
+
+	if !registered(OptNAWS) {
+		width, height := client.Width, client.Height
+		if width <= 0 {
+			width = clientDefaultWidth
+		}
+		if height <= 0 {
+			height = clientDefaultHeight
+		}
+
+		if err := conn.SetWindowSize(width, height); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestClientDialTo(t *testing.T) {
+
+	tests := []struct {
+		Client   Client
+		Expected []byte
+	}{
+		{
+			Client: Client{},
+			Expected: []byte{
+				IAC, WILL, OptSuppressGoAhead,
+				IAC, DO, OptSuppressGoAhead,
+				IAC, DO, OptEcho,
+				IAC, WILL, OptTerminalType,
+				IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE,
+				IAC, WONT, 200,
+				IAC, DONT, 201,
+				IAC, WONT, OptEcho,
+				IAC, SB, OptTerminalType, terminalTypeIS, 'U', 'N', 'K', 'N', 'O', 'W', 'N', IAC, SE,
+			},
+		},
+		{
+			Client: Client{TerminalTypes: []string{"ANSI", "VT100"}, Width: 132, Height: 50},
+			Expected: []byte{
+				IAC, WILL, OptSuppressGoAhead,
+				IAC, DO, OptSuppressGoAhead,
+				IAC, DO, OptEcho,
+				IAC, WILL, OptTerminalType,
+				IAC, SB, OptNAWS, 0, 132, 0, 50, IAC, SE,
+				IAC, WONT, 200,
+				IAC, DONT, 201,
+				IAC, WONT, OptEcho,
+				IAC, SB, OptTerminalType, terminalTypeIS, 'A', 'N', 'S', 'I', IAC, SE,
+			},
+		},
+	}
+
+	for testNumber, test := range tests {
+		received := make(chan []byte, 1)
+		listener := testListen(t, func(c net.Conn) {
+			c.SetDeadline(time.Now().Add(3 * time.Second))
+
+			// (The client offers NAWS, as soon as it connects.)
+			p := make([]byte, 3)
+			io.ReadFull(c, p)
+			if expected, actual := string([]byte{IAC, WILL, OptNAWS}), string(p); expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+
+			c.Write([]byte{
+				IAC, DO, OptSuppressGoAhead,
+				IAC, WILL, OptSuppressGoAhead,
+				IAC, WILL, OptEcho,
+				IAC, DO, OptTerminalType,
+				IAC, DO, OptNAWS,
+				IAC, DO, 200,
+				IAC, WILL, 201,
+				IAC, DO, OptEcho,
+				IAC, SB, OptTerminalType, terminalTypeSEND, IAC, SE,
+			})
+
+			p = make([]byte, len(test.Expected))
+			io.ReadFull(c, p)
+			received <- p
+		})
+
+		conn, err := test.Client.DialTo(listener.Addr().String())
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		go io.Copy(io.Discard, conn)
+
+		select {
+		case p := <-received:
+			if expected, actual := string(test.Expected), string(p); expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Errorf("For test #%d, expected the server to receive the answers, but actually it did not.", testNumber)
+		}
+
+		if expected, actual := "ECHO(local=no remote=yes) SUPPRESS-GO-AHEAD(local=yes remote=yes) TERMINAL-TYPE(local=yes remote=no) NAWS(local=yes remote=no)", conn.OptionStates().String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		conn.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package telnet
+
+// CloseReason is why a connection was closed. It is stamped (once) when the connection is closed
+// (see Conn.CloseWithReason); and is what Server.OnDisconnect, Trace.Closed, and Metrics.Closed
+// are given.
+type CloseReason int
+
+const (
+	CloseUnknown               CloseReason = iota // Why the connection was closed is not known.
+	CloseUserRequested                            // The user asked for the connection to be closed; such as by logging out.
+	CloseLineTooLong                              // The client sent a line longer than the MaxLineLength of its InputLimits.
+	CloseSubnegotiationFlood                      // The client sent more subnegotiations than its InputLimits allow.
+	CloseInputFlood                               // The client sent more bytes than its InputLimits allow.
+	CloseIdleTimeout                              // The connection was idle for too long. (Reading then returns ErrIdleTimeout; see SetSessionTimeouts.)
+	ClosePeerClosed                               // The peer closed the connection (cleanly).
+	CloseConnectionReset                          // The connection was lost abnormally; such as by being reset. (See ErrConnectionReset.)
+	CloseProtocolError                            // The peer did not follow the TELNET protocol; such as by closing part way through a command.
+	CloseSlowClient                               // The peer did not read what was sent to it fast enough. (See SetWriteTimeout.)
+	CloseServerShutdown                           // The server was shut down.
+	ClosePolicyRejected                           // The server turned the connection away; such as because its WorkerPool was full (see PoolOverflowReject).
+	CloseDeadPeer                                 // Nothing was received from the peer for too long. (Reading then returns a *DeadPeerError; see SetKeepalive.)
+	CloseAuthFailed                               // The client did not log in; such as by using up its tries. (See Server.AuthHandler.)
+	CloseSessionTimeout                           // The connection was open for longer than its MaxSessionDuration. (See SetSessionTimeouts.)
+	CloseHandlerPanic                             // The handler (or one of the Server's hooks, such as OnConnect) panicked. (The panic is logged.)
+	CloseTLSHandshakeFailed                       // The TLS handshake (of a TELNETS connection) failed; before the connection was served.
+	CloseSubnegotiationTooLong                    // The client sent a subnegotiation longer than the MaxSubnegotiationLength of its InputLimits.
+)
+
+// String returns the name of the CloseReason; such as "user requested".
+func (reason CloseReason) String() string {
+	switch reason {
+	case CloseUnknown:
+		return "unknown"
+	case CloseUserRequested:
+		return "user requested"
+	case CloseLineTooLong:
+		return "line too long"
+	case CloseSubnegotiationFlood:
+		return "subnegotiation flood"
+	case CloseInputFlood:
+		return "input flood"
+	case CloseIdleTimeout:
+		return "idle timeout"
+	case ClosePeerClosed:
+		return "peer closed"
+	case CloseConnectionReset:
+		return "connection reset"
+	case CloseProtocolError:
+		return "protocol error"
+	case CloseSlowClient:
+		return "slow client"
+	case CloseServerShutdown:
+		return "server shutdown"
+	case ClosePolicyRejected:
+		return "policy rejected"
+	case CloseDeadPeer:
+		return "dead peer"
+	case CloseAuthFailed:
+		return "auth failed"
+	case CloseSessionTimeout:
+		return "session timeout"
+	case CloseHandlerPanic:
+		return "handler panic"
+	case CloseTLSHandshakeFailed:
+		return "tls handshake failed"
+	case CloseSubnegotiationTooLong:
+		return "subnegotiation too long"
+	default:
+		return "unknown"
+	}
+}
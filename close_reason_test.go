@@ -0,0 +1,319 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCloseReasonString(t *testing.T) {
+
+	tests := []struct {
+		Reason   CloseReason
+		Expected string
+	}{
+		{
+			Reason:   CloseUnknown,
+			Expected: "unknown",
+		},
+		{
+			Reason:   CloseUserRequested,
+			Expected: "user requested",
+		},
+		{
+			Reason:   CloseSlowClient,
+			Expected: "slow client",
+		},
+		{
+			Reason:   CloseServerShutdown,
+			Expected: "server shutdown",
+		},
+		{
+			Reason:   ClosePolicyRejected,
+			Expected: "policy rejected",
+		},
+		{
+			Reason:   CloseDeadPeer,
+			Expected: "dead peer",
+		},
+		{
+			Reason:   CloseAuthFailed,
+			Expected: "auth failed",
+		},
+		{
+			Reason:   CloseSessionTimeout,
+			Expected: "session timeout",
+		},
+		{
+			Reason:   CloseHandlerPanic,
+			Expected: "handler panic",
+		},
+		{
+			Reason:   CloseTLSHandshakeFailed,
+			Expected: "tls handshake failed",
+		},
+		{
+			Reason:   CloseSubnegotiationTooLong,
+			Expected: "subnegotiation too long",
+		},
+		{
+			Reason:   CloseReason(200),
+			Expected: "unknown",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, test.Reason.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestConnCloseWithReason(t *testing.T) {
+
+	conn, _ := testPipe(t)
+
+	if reason, msg := conn.CloseReason(); CloseUnknown != reason || "" != msg {
+		t.Errorf("Expected no close reason, but actually got: %v %q", reason, msg)
+	}
+
+	if err := conn.CloseWithReason(CloseUserRequested, "bye"); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	conn.Close()
+
+	if reason, msg := conn.CloseReason(); CloseUserRequested != reason || "bye" != msg {
+		t.Errorf("Expected the first close reason to be kept, but actually got: %v %q", reason, msg)
+	}
+}
+
+type testCloseHandler struct{}
+
+func (testCloseHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	w.(*Conn).CloseWithReason(CloseUserRequested, "user requested")
+}
+
+func TestServerOnDisconnect(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	type disconnect struct {
+		Reason CloseReason
+		Msg    string
+	}
+	disconnects := make(chan disconnect, 1)
+
+	server := &Server{
+		Handler: testCloseHandler{},
+		OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+			disconnects <- disconnect{Reason: reason, Msg: msg}
+		},
+	}
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	select {
+	case actual := <-disconnects:
+		if expected := (disconnect{Reason: CloseUserRequested, Msg: "user requested"}); expected != actual {
+			t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for OnDisconnect to be called.")
+	}
+}
+
+func TestServerCloseReasons(t *testing.T) {
+
+	tests := []struct {
+		Name        string
+		InputLimits InputLimits
+		Handler     testContextHandler
+		Client      func(client net.Conn)
+		Expected    CloseReason
+	}{
+		{
+			Name: "user requested",
+			Handler: func(ctx context.Context, conn *Conn) {
+				conn.CloseWithReason(CloseUserRequested, "logged out")
+
+				// (The first reason wins.)
+				conn.CloseWithReason(CloseSlowClient, "too slow")
+			},
+			Expected: CloseUserRequested,
+		},
+		{
+			Name: "peer closed",
+			Handler: func(ctx context.Context, conn *Conn) {
+				io.Copy(io.Discard, conn)
+			},
+			Client: func(client net.Conn) {
+				client.Write([]byte("bye\r\n"))
+				client.Close()
+			},
+			Expected: ClosePeerClosed,
+		},
+		{
+			Name: "protocol error",
+			Handler: func(ctx context.Context, conn *Conn) {
+				io.Copy(io.Discard, conn)
+			},
+			Client: func(client net.Conn) {
+				client.Write([]byte{'h', 'i', IAC})
+				client.Close()
+			},
+			Expected: CloseProtocolError,
+		},
+		{
+			Name:        "line too long",
+			InputLimits: InputLimits{MaxLineLength: 4, DisconnectOnLongLine: true},
+			Handler: func(ctx context.Context, conn *Conn) {
+				// (As a line editor would.)
+				line, err := conn.ReadLine()
+				if nil == err && 4 < len(line) {
+					conn.LineTooLong()
+				}
+			},
+			Client: func(client net.Conn) {
+				client.Write([]byte("much too long\r\n"))
+			},
+			Expected: CloseLineTooLong,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.Name, func(t *testing.T) {
+			disconnects := make(chan CloseReason, 2)
+			var metrics testMetrics
+
+			server := &Server{
+				ContextHandler: test.Handler,
+				InputLimits:    test.InputLimits,
+				Metrics:        &metrics,
+				OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+					disconnects <- reason
+				},
+			}
+			client := testServe(t, server)
+			if nil != test.Client {
+				test.Client(client)
+			}
+
+			testExpectCloseReason(t, disconnects, &metrics, test.Expected)
+		})
+	}
+}
+
+func TestServerCloseReasonPolicyRejected(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	disconnects := make(chan CloseReason, 2)
+	var metrics testMetrics
+
+	handler := testPoolHandler{started: make(chan struct{}, 8)}
+	server := &Server{
+		Handler: handler,
+		WorkerPool: &WorkerPool{
+			Size:        1,
+			QueueLength: 1,
+			Overflow:    PoolOverflowReject,
+		},
+		Metrics: &metrics,
+		OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+			disconnects <- reason
+		},
+	}
+	go server.Serve(listener)
+
+	// (The 1st is served, and the 2nd is queued; so the 3rd is rejected.)
+	testDial(t, listener)
+	<-handler.started
+	testDial(t, listener)
+	testWaitForPoolStats(t, server, func(stats PoolStats) bool { return 1 == stats.Queued })
+
+	metrics.mutex.Lock()
+	metrics.events = nil
+	metrics.mutex.Unlock()
+
+	testDial(t, listener)
+
+	testExpectCloseReason(t, disconnects, &metrics, ClosePolicyRejected)
+}
+
+func TestServerCloseReasonHandlerPanic(t *testing.T) {
+
+	disconnects := make(chan CloseReason, 2)
+	var metrics testMetrics
+
+	served := make(chan *Conn, 1)
+	server := &Server{
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			served <- conn
+			panic("boom")
+		}),
+		Metrics: &metrics,
+		OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+			disconnects <- reason
+		},
+	}
+	testServe(t, server)
+
+	testExpectCloseReason(t, disconnects, &metrics, CloseHandlerPanic)
+
+	conn := <-served
+	select {
+	case <-conn.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for Done to be closed.")
+	}
+	if reason, msg := conn.CloseReason(); CloseHandlerPanic != reason || "panic: boom" != msg {
+		t.Errorf("Expected %v (%q), but actually got %v (%q).", CloseHandlerPanic, "panic: boom", reason, msg)
+	}
+}
+
+// testExpectCloseReason checks that OnDisconnect (which sends to 'disconnects'), and the Metrics,
+// were told of 1 connection being closed; and that it was for 'expected'.
+func testExpectCloseReason(t *testing.T, disconnects chan CloseReason, metrics *testMetrics, expected CloseReason) {
+	t.Helper()
+
+	select {
+	case actual := <-disconnects:
+		if expected != actual {
+			t.Errorf("Expected OnDisconnect to be given %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for OnDisconnect to be called.")
+	}
+
+	expectedEvents := []string{"opened", "closed: " + expected.String()}
+	for begin := time.Now(); time.Since(begin) < time.Second && len(metrics.Events()) < len(expectedEvents); time.Sleep(5 * time.Millisecond) {
+	}
+	// (And only once.)
+	time.Sleep(20 * time.Millisecond)
+	if actual := metrics.Events(); !testEqualStrings(expectedEvents, actual) {
+		t.Errorf("Expected the metrics to be told %q, but actually got %q.", expectedEvents, actual)
+	}
+	select {
+	case actual := <-disconnects:
+		t.Errorf("Expected OnDisconnect to be called once, but it was called again; with %v.", actual)
+	default:
+	}
+}
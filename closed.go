@@ -0,0 +1,61 @@
+package telnet
+
+import (
+	"net"
+)
+
+// ErrClosed is returned by a Conn's Write (and the other methods that send something) once the
+// Conn has been closed; and by its Read (and the other methods that read something). It is
+// net.ErrClosed; i.e., errors.Is(err, net.ErrClosed) is true for it.
+var ErrClosed error = internalClosedError{}
+
+type internalClosedError struct{}
+
+func (internalClosedError) Error() string {
+	return "telnet: use of closed connection"
+}
+
+func (internalClosedError) Unwrap() error {
+	return net.ErrClosed
+}
+
+// isClosed returns whether the Conn has been closed.
+func (clientConn *Conn) isClosed() bool {
+	select {
+	case <-clientConn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closedErr returns ErrClosed instead of 'err', if the Conn has been closed; so that what reading
+// (or writing) returns, once the Conn is closed, is always the same; no matter what the underlying
+// connection returns.
+//
+// (Except for the errors that say why the Conn itself closed the connection; such as ErrInputFlood,
+// or ErrWriteTimeout.)
+func (clientConn *Conn) closedErr(err error) error {
+	switch err {
+	case nil, ErrInputFlood, ErrSubnegotiationFlood, ErrSubnegotiationTooLong, ErrWriteTimeout:
+		return err
+	}
+
+	if clientConn.isClosed() {
+		return ErrClosed
+	}
+	return err
+}
+
+// writeCommand writes 'p' (which is NOT escaped) to the peer. (See internalDataWriter.writeCommand.)
+func (clientConn *Conn) writeCommand(p []byte) error {
+	if clientConn.isClosed() {
+		return ErrClosed
+	}
+
+	err := clientConn.closedErr(clientConn.dataWriter.writeCommand(p))
+	if nil == err {
+		clientConn.tapCommands(p)
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package telnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestConnAfterClose(t *testing.T) {
+
+	tests := []struct {
+		Name string
+		Do   func(conn *Conn) error
+	}{
+		{
+			Name: "Read",
+			Do: func(conn *Conn) error {
+				var buffer [16]byte
+				_, err := conn.Read(buffer[:])
+				return err
+			},
+		},
+		{
+			Name: "Peek",
+			Do: func(conn *Conn) error {
+				_, err := conn.Peek(1)
+				return err
+			},
+		},
+		{
+			Name: "ReadRune",
+			Do: func(conn *Conn) error {
+				_, _, err := conn.ReadRune()
+				return err
+			},
+		},
+		{
+			Name: "ReadLine",
+			Do: func(conn *Conn) error {
+				_, err := conn.ReadLine()
+				return err
+			},
+		},
+		{
+			Name: "Write",
+			Do: func(conn *Conn) error {
+				_, err := conn.Write([]byte("hello"))
+				return err
+			},
+		},
+		{
+			Name: "SendCommand",
+			Do: func(conn *Conn) error {
+				return conn.SendCommand(NOP)
+			},
+		},
+		{
+			Name: "SendSubnegotiation",
+			Do: func(conn *Conn) error {
+				return conn.SendSubnegotiation(OptNAWS, []byte{0, 80, 0, 24})
+			},
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+		go io.Copy(io.Discard, remote)
+
+		conn := newConn(local, nil)
+		conn.Close()
+
+		// (The same, every time.)
+		for i := 0; i < 2; i++ {
+			err := test.Do(conn)
+			if ErrClosed != err {
+				t.Errorf("For test #%d (%s), expected %v, but actually got: (%T) %v", testNumber, test.Name, ErrClosed, err, err)
+			}
+			if !errors.Is(err, net.ErrClosed) {
+				t.Errorf("For test #%d (%s), expected the error to be net.ErrClosed, but actually was not: (%T) %v", testNumber, test.Name, err, err)
+			}
+		}
+
+		remote.Close()
+	}
+}
+
+func TestConnCloseConcurrently(t *testing.T) {
+
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		local, remote := net.Pipe()
+		go io.Copy(io.Discard, remote)
+		go func() {
+			for {
+				if _, err := remote.Write([]byte{'a', IAC, IAC, IAC, NOP, '\r', '\n'}); nil != err {
+					return
+				}
+			}
+		}()
+
+		conn := newConn(local, nil)
+		conn.SetWriteCoalescing(time.Millisecond, 0)
+
+		var wait sync.WaitGroup
+		errs := make(chan error, 64)
+
+		for j := 0; j < 4; j++ {
+			wait.Add(2)
+			go func() {
+				defer wait.Done()
+				var buffer [8]byte
+				for {
+					if _, err := conn.Read(buffer[:]); nil != err {
+						errs <- err
+						return
+					}
+				}
+			}()
+			go func() {
+				defer wait.Done()
+				for {
+					if _, err := conn.Write([]byte("hello\xff")); nil != err {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+
+		time.Sleep(time.Millisecond)
+
+		closes := make(chan error, 4)
+		for j := 0; j < 4; j++ {
+			go func() {
+				closes <- conn.Close()
+			}()
+		}
+		first := <-closes
+		for j := 1; j < 4; j++ {
+			if err := <-closes; first != err {
+				t.Errorf("For #%d, expected every Close to return %v, but actually got: (%T) %v", i, first, err, err)
+			}
+		}
+
+		wait.Wait()
+		close(errs)
+		for err := range errs {
+			if ErrClosed != err {
+				t.Errorf("For #%d, expected %v, but actually got: (%T) %v", i, ErrClosed, err, err)
+			}
+		}
+
+		remote.Close()
+	}
+
+	// (Nothing is left running, once the connections are closed.)
+	var actual int
+	for begin := time.Now(); time.Since(begin) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if actual = runtime.NumGoroutine(); actual <= baseline {
+			break
+		}
+	}
+	if actual > baseline {
+		t.Errorf("Expected (at most) %d goroutine(s), but actually got %d.", baseline, actual)
+	}
+}
@@ -0,0 +1,102 @@
+package telnet
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrInvalidCommand is what SendCommand returns for a code that is not a TELNET command on its
+	// own; i.e., for SB and SE (see SendSubnegotiation), WILL, WONT, DO, and DONT (see SendOption),
+	// and IAC (which, doubled, is the data byte 255; see Write).
+	ErrInvalidCommand = errors.New("telnet: not a TELNET command on its own")
+
+	// ErrInvalidVerb is what SendOption returns for a verb other than WILL, WONT, DO, or DONT.
+	ErrInvalidVerb = errors.New("telnet: not an option negotiation verb")
+)
+
+// TELNET command codes, as defined in RFC 854. (And EOR, as defined in RFC 885; and EOF, SUSP,
+// and ABORT, as defined in RFC 1184.)
+//
+// Each of these is sent on the wire prefixed by IAC ("interpret as command").
+// So, for example, to send an "Are You There" to the peer, the bytes:
+//
+//	[]byte{IAC, AYT}
+//
+// ... (i.e., []byte{255, 246}) are sent.
+//
+// Note that these are TELNET commands, and should not be confused with
+// (ANSI) terminal codes.
+const (
+	EOF   byte = 236 // End of File; the peer's (or the user's) end-of-file character. (Such as Ctrl-D, at a UNIX terminal.)
+	SUSP  byte = 237 // Suspend the current process; the peer's (or the user's) suspend character. (Such as Ctrl-Z.)
+	ABORT byte = 238 // Abort Process; abort the current process, and kill the job. (Such as Ctrl-\.)
+	EOR   byte = 239 // End of Record; sent only once the END-OF-RECORD option is agreed to. (Such as to mark a prompt.)
+	SE    byte = 240 // End of subnegotiation parameters.
+	NOP   byte = 241 // No operation.
+	DM    byte = 242 // Data Mark; the data stream portion of a Synch.
+	BRK   byte = 243 // NVT character BRK ("break").
+	IP    byte = 244 // Interrupt Process.
+	AO    byte = 245 // Abort Output.
+	AYT   byte = 246 // Are You There.
+	EC    byte = 247 // Erase Character.
+	EL    byte = 248 // Erase Line.
+	GA    byte = 249 // Go Ahead.
+	SB    byte = 250 // Begin subnegotiation.
+	WILL  byte = 251 // Sender wants to begin (or confirms it is) performing an option.
+	WONT  byte = 252 // Sender refuses to begin (or wants to stop) performing an option.
+	DO    byte = 253 // Sender asks the peer to begin (or confirms the peer is) performing an option.
+	DONT  byte = 254 // Sender asks the peer to stop (or confirms the peer is no longer) performing an option.
+	IAC   byte = 255 // Interpret As Command.
+)
+
+// CommandName returns the conventional (RFC 854) name of the TELNET command code 'cmd',
+// such as "AYT" or "WILL".
+//
+// If 'cmd' is not a known TELNET command code, then its decimal value is returned instead.
+func CommandName(cmd byte) string {
+	switch cmd {
+	case EOF:
+		return "EOF"
+	case SUSP:
+		return "SUSP"
+	case ABORT:
+		return "ABORT"
+	case EOR:
+		return "EOR"
+	case SE:
+		return "SE"
+	case NOP:
+		return "NOP"
+	case DM:
+		return "DM"
+	case BRK:
+		return "BRK"
+	case IP:
+		return "IP"
+	case AO:
+		return "AO"
+	case AYT:
+		return "AYT"
+	case EC:
+		return "EC"
+	case EL:
+		return "EL"
+	case GA:
+		return "GA"
+	case SB:
+		return "SB"
+	case WILL:
+		return "WILL"
+	case WONT:
+		return "WONT"
+	case DO:
+		return "DO"
+	case DONT:
+		return "DONT"
+	case IAC:
+		return "IAC"
+	default:
+		return strconv.Itoa(int(cmd))
+	}
+}
@@ -0,0 +1,78 @@
+package telnet
+
+import (
+	"strconv"
+)
+
+// A CommandEvent is a TELNET command, received from the peer, that nothing in the Conn handled.
+// (See OnUnhandledCommand.)
+type CommandEvent struct {
+	// Verb is the TELNET command (such as AYT); which is WILL, or DO, for an option negotiation,
+	// and SB for a subnegotiation.
+	Verb byte
+
+	// Option is the option of an option negotiation, or of a subnegotiation; and Payload is the
+	// (un-escaped) payload of a subnegotiation.
+	Option  byte
+	Payload []byte
+}
+
+// String returns the command as the peer sent it; such as "IAC AYT", "IAC WILL 200", or
+// "IAC SB 150 <12 bytes> IAC SE".
+func (event CommandEvent) String() string {
+	return commandString(event.Verb, event.Option, event.Payload)
+}
+
+// commandString returns what the peer would see, for the TELNET command; such as "IAC DO ECHO", or
+// "IAC SB NAWS <4 bytes> IAC SE".
+func commandString(cmd byte, option byte, payload []byte) string {
+	s := "IAC " + CommandName(cmd)
+	switch cmd {
+	case WILL, WONT, DO, DONT:
+		s += " " + OptionName(option)
+	case SB:
+		s += " " + OptionName(option) + " <" + strconv.Itoa(len(payload)) + " bytes> IAC SE"
+	}
+	return s
+}
+
+// OnUnhandledCommand registers 'fn' to be called for each TELNET command received from the peer
+// that nothing in the Conn handled; rather than it being dropped. This replaces any function
+// previously registered. Registering nil stops the calls. For example:
+//
+//	conn.OnUnhandledCommand(func(event telnet.CommandEvent) {
+//		log.Printf("%v sent %v", conn.RemoteAddr(), event)
+//	})
+//
+// This is for logging (or debugging) what a peer sends that the Conn does not know what to do
+// with; and for implementing an option that the library does not know of, without registering an
+// OptionHandler for it. What is unhandled is:
+//
+// A command (other than an option negotiation, or a subnegotiation); when there is no OnCommand,
+// and it is not relayed (see ProxyHandler).
+//
+// A WILL, or a DO, that the Conn refused; such as because no OptionHandler is registered for the
+// option (and the OptionPolicy is to refuse it). It is still answered, as always; 'fn' is told
+// of it after the answer is sent.
+//
+// A subnegotiation for an option that no OptionHandler is registered for; and that is not
+// relayed.
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnUnhandledCommand(fn func(event CommandEvent)) {
+	clientConn.commandMutex.Lock()
+	clientConn.unhandledHandler = fn
+	clientConn.commandMutex.Unlock()
+}
+
+// unhandled calls what OnUnhandledCommand registered (if anything) with the command.
+func (clientConn *Conn) unhandled(verb byte, option byte, payload []byte) {
+	clientConn.commandMutex.RLock()
+	fn := clientConn.unhandledHandler
+	clientConn.commandMutex.RUnlock()
+
+	if nil != fn {
+		fn(CommandEvent{Verb: verb, Option: option, Payload: payload})
+	}
+}
@@ -0,0 +1,80 @@
+package telnet
+
+import (
+	"io"
+
+	"testing"
+)
+
+func TestCommandEventString(t *testing.T) {
+
+	tests := []struct {
+		Event    CommandEvent
+		Expected string
+	}{
+		{
+			Event:    CommandEvent{Verb: AYT},
+			Expected: "IAC AYT",
+		},
+		{
+			Event:    CommandEvent{Verb: 200},
+			Expected: "IAC 200",
+		},
+		{
+			Event:    CommandEvent{Verb: WILL, Option: OptEcho},
+			Expected: "IAC WILL ECHO",
+		},
+		{
+			Event:    CommandEvent{Verb: SB, Option: 150, Payload: []byte("Core.Hello")},
+			Expected: "IAC SB 150 <10 bytes> IAC SE",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, test.Event.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestConnOnUnhandledCommand(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	conn.SetOptionPolicy(OptSuppressGoAhead, OptionPolicyAccept)
+
+	var events []string
+	conn.OnUnhandledCommand(func(event CommandEvent) {
+		events = append(events, event.String())
+	})
+
+	read := func() {
+		t.Helper()
+
+		send('.')
+		if _, err := io.ReadFull(conn, make([]byte, 1)); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+
+	send(IAC, AYT)
+	send(IAC, WILL, 200)
+	send(IAC, DO, OptSuppressGoAhead)
+	send(IAC, WONT, OptEcho)
+	send(IAC, SB, 150, 'h', 'i', IAC, SE)
+	read()
+
+	expected := []string{"IAC AYT", "IAC WILL 200", "IAC SB 150 <2 bytes> IAC SE"}
+	if actual := events; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (Once there is an OnCommand, it handles the commands.)
+	conn.OnCommand(func(cmd byte) {})
+	send(IAC, AYT)
+	read()
+
+	if actual := events; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
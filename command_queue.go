@@ -0,0 +1,145 @@
+package telnet
+
+import (
+	"sync"
+)
+
+// commandQueueLength is how many TELNET commands can be waiting to be sent (see
+// internalCommandQueue); after which sending another waits for there to be room.
+const commandQueueLength = 32
+
+// internalCommandQueue is the (high priority) queue of TELNET commands (such as the answers to
+// option negotiations, IAC NOP keepalives, and the answers to IAC AYT) that are waiting to be sent;
+// so that they are not stuck behind (possibly megabytes of) data that is still waiting to be sent,
+// such as to a slow peer.
+//
+// Data is written to the wire a buffer's worth at a time (see internalDataWriter.write); and, in
+// between, whatever commands are queued are sent, ahead of the rest of the data. (Data is only ever
+// split between whole bytes, i.e., never inside an escaped IAC; and a command, such as a whole
+// subnegotiation, is never split.)
+//
+// So a command is sent after whatever data was written before it (i.e., by a Write that had
+// returned); even if that data was still buffered (such as because of SetWriteCoalescing). But it
+// only waits for the flush that is in progress (i.e., for up to a buffer's worth of data, 4096
+// bytes, to be sent); not for the rest of the data that is waiting to be sent, nor for the rate
+// limit (see SetOutputRateLimit). The data is still sent in the order it was written in; commands
+// do not change that, nor hold it up (other than by however long they take to send).
+//
+// The backpressure is on what sends the commands, not on the queue: once commandQueueLength
+// commands are waiting to be sent, sending another waits for there to be room; which is once the
+// peer has read (enough of) what was sent before them. (And each command that is sent waits for it
+// to have been.)
+//
+// Whichever goroutine can lock the wireMutex sends what is queued; either the one sending the
+// command, or (once it is done with the wire) the one writing data. (See sendCommands.)
+type internalCommandQueue struct {
+	mutex    sync.Mutex
+	commands []*internalQueuedCommand
+
+	// room has a token for each command that can be queued.
+	room chan struct{}
+}
+
+// internalQueuedCommand is a command that is waiting to be sent; what sending it returned is sent
+// to 'sent' (once it has been).
+//
+// 'then' (if not nil) is called (with the wireMutex held) once the command has been sent; before
+// anything after it is. (Such as to start compressing what is sent after it; see OfferCompression.)
+type internalQueuedCommand struct {
+	p    []byte
+	then func()
+	sent chan error
+}
+
+func newCommandQueue() *internalCommandQueue {
+	return &internalCommandQueue{
+		room: make(chan struct{}, commandQueueLength),
+	}
+}
+
+// push queues 'p' (and 'then'); waiting for there to be room first, if need be.
+func (queue *internalCommandQueue) push(p []byte, then func()) *internalQueuedCommand {
+	queue.room <- struct{}{}
+
+	command := &internalQueuedCommand{p: p, then: then, sent: make(chan error, 1)}
+
+	queue.mutex.Lock()
+	queue.commands = append(queue.commands, command)
+	queue.mutex.Unlock()
+
+	return command
+}
+
+// waiting returns whether there are commands waiting to be sent.
+func (queue *internalCommandQueue) waiting() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	return 0 < len(queue.commands)
+}
+
+// take takes (out of the queue) the commands that are waiting to be sent; which makes room for more.
+func (queue *internalCommandQueue) take() []*internalQueuedCommand {
+	queue.mutex.Lock()
+	commands := queue.commands
+	queue.commands = nil
+	queue.mutex.Unlock()
+
+	for range commands {
+		<-queue.room
+	}
+	return commands
+}
+
+// unlockWire unlocks the wireMutex; and then sends whatever commands were queued while it was locked.
+func (w *internalDataWriter) unlockWire() {
+	w.wireMutex.Unlock()
+	w.sendCommands()
+}
+
+// sendCommands sends the commands that are waiting to be sent; unless the wireMutex is locked, in
+// which case whatever has it locked sends them once it unlocks it. (See unlockWire.)
+func (w *internalDataWriter) sendCommands() {
+	for w.commands.waiting() {
+		if !w.wireMutex.TryLock() {
+			return
+		}
+		w.sendQueued()
+		w.wireMutex.Unlock()
+	}
+}
+
+// sendQueued sends the commands that are waiting to be sent; after whatever (data) is buffered.
+// The wireMutex must be held.
+func (w *internalDataWriter) sendQueued() {
+	commands := w.commands.take()
+	if len(commands) <= 0 {
+		return
+	}
+
+	errs := make([]error, len(commands))
+	for k, command := range commands {
+		_, errs[k] = w.wrapped.Write(command.p)
+		if nil == command.then {
+			continue
+		}
+
+		// (What comes after the command is not to be sent together with it.)
+		if nil == errs[k] {
+			errs[k] = w.wrapped.Flush()
+		}
+		if nil == errs[k] {
+			command.then()
+		}
+	}
+
+	w.coalescer.stop()
+	err := w.wrapped.Flush()
+
+	for k, command := range commands {
+		if nil == errs[k] {
+			errs[k] = err
+		}
+		command.sent <- errs[k]
+	}
+}
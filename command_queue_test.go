@@ -0,0 +1,197 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testThrottledReader reads from 'conn' (a chunk at a time, with a pause in between; like a slow
+// peer), until it sees (not escaped) 'command'. It sends how much data it had read before then.
+func testThrottledReader(conn net.Conn, command []byte) <-chan int {
+	found := make(chan int, 1)
+
+	go func() {
+		var received []byte
+		p := make([]byte, 1024)
+		for {
+			n, err := conn.Read(p)
+			received = append(received, p[:n]...)
+			if k := bytes.Index(received, command); 0 <= k {
+				found <- k
+				return
+			}
+			if nil != err {
+				found <- -1
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	return found
+}
+
+func TestConnCommandAheadOfData(t *testing.T) {
+
+	const size = 10 * 1024 * 1024
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	go io.Copy(io.Discard, conn)
+
+	// (The data never has an IAC in it; so the only one is the answer to the DO.)
+	answered := testThrottledReader(remote, []byte{IAC, WONT, OptEcho})
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(bytes.Repeat([]byte{'x'}, size))
+		written <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	asked := time.Now()
+	go remote.Write([]byte{IAC, DO, OptEcho})
+
+	select {
+	case before := <-answered:
+		if before < 0 {
+			t.Fatalf("Expected the answer to be sent, but it was not.")
+		}
+		if elapsed := time.Since(asked); 500*time.Millisecond < elapsed {
+			t.Errorf("Expected the answer to be sent promptly, but it took %v.", elapsed)
+		}
+		if 1024*1024 < before {
+			t.Errorf("Expected the answer to be sent ahead of (most of) the data, but %d bytes were sent before it.", before)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the answer to be sent.")
+	}
+
+	select {
+	case <-written:
+		t.Errorf("Expected the data to still be being written, but it was not.")
+	default:
+	}
+}
+
+func TestConnCommandsBetweenData(t *testing.T) {
+
+	const (
+		writes   = 50
+		commands = 200
+	)
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+
+	// (The data has IACs in it; none of which is split by a command.)
+	chunk := bytes.Repeat([]byte{'a', IAC, 'b', IAC, IAC, 'c'}, 1000)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			if _, err := conn.Write(chunk); nil != err {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < commands; i++ {
+			if err := conn.SendCommand(NOP); nil != err {
+				return
+			}
+		}
+	}()
+
+	expected := bytes.Repeat(chunk, writes)
+	var data []byte
+	nops := 0
+
+	p := make([]byte, 1)
+	read := func() byte {
+		remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.ReadFull(remote, p); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		return p[0]
+	}
+	for len(data) < len(expected) || nops < commands {
+		b := read()
+		if IAC != b {
+			data = append(data, b)
+			continue
+		}
+
+		switch b = read(); b {
+		case IAC:
+			data = append(data, IAC)
+		case NOP:
+			nops++
+		default:
+			t.Fatalf("Expected IAC IAC, or IAC NOP, but actually got IAC %d; after %d bytes of data.", b, len(data))
+		}
+	}
+	wg.Wait()
+
+	if !bytes.Equal(expected, data) {
+		t.Errorf("Expected the data to be sent in order, but it was not.")
+	}
+	if expected, actual := commands, nops; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+}
+
+func TestEscapedPrefixLen(t *testing.T) {
+
+	tests := []struct {
+		P         []byte
+		Available int
+		Expected  int
+	}{
+		{
+			P:         []byte("abc"),
+			Available: -1,
+			Expected:  3,
+		},
+		{
+			P:         []byte("abc"),
+			Available: 2,
+			Expected:  2,
+		},
+		{
+			P:         []byte{'a', IAC, IAC, 'b'},
+			Available: 2,
+			Expected:  1,
+		},
+		{
+			P:         []byte{'a', IAC, IAC, 'b'},
+			Available: 3,
+			Expected:  3,
+		},
+		{
+			P:         []byte{IAC, IAC, 'b'},
+			Available: 1,
+			Expected:  0,
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, escapedPrefixLen(test.P, test.Available); expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package telnet
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// inflaterBufferSize is how much (compressed) data is read from the connection at a time; and how
+// much is decompressed at a time. (While the peer is compressing; see AcceptCompression.)
+const inflaterBufferSize = 4096
+
+// OfferCompression offers (i.e., sends a WILL for) MCCP (the Mud Client Compression Protocol);
+// both version 2 (COMPRESS2), for compressing what is sent to the peer, and version 3 (COMPRESS3),
+// for the peer compressing what it sends. This is for servers (such as a MUD); it cuts down on the
+// bandwidth (of text, usually by quite a lot). For example:
+//
+//	if err := conn.OfferCompression(); nil != err {
+//		return err
+//	}
+//
+// Once the peer agrees to COMPRESS2, what is sent after the IAC SB COMPRESS2 IAC SE (that it is
+// then sent) is compressed (with zlib); TELNET commands included. And once the peer agrees to
+// COMPRESS3, what it sends after the IAC SB COMPRESS3 IAC SE (that it then sends) is decompressed.
+// Either way, the handlers keep reading (and writing) the data as it is; it is all done by the Conn,
+// as the data is sent (and received). (See Compressing.) If the peer disables COMPRESS2 (i.e.,
+// sends a DONT for it), then the compressed stream is ended; and what is sent after is not
+// compressed.
+//
+// (It registers the OptionHandlers for COMPRESS2 and COMPRESS3; replacing any that were registered
+// before.)
+func (clientConn *Conn) OfferCompression() error {
+	support := OptionSupport{Local: true, RequestLocal: true}
+
+	if err := clientConn.RegisterOption(OptCompress2, &internalCompression{option: OptCompress2, support: support}); nil != err {
+		return err
+	}
+	return clientConn.RegisterOption(OptCompress3, &internalCompression{option: OptCompress3, support: support})
+}
+
+// AcceptCompression agrees to MCCP (the Mud Client Compression Protocol), if the peer offers it
+// (i.e., sends a WILL for it); version 2 (COMPRESS2), for the peer compressing what it sends, and
+// version 3 (COMPRESS3), for compressing what is sent to the peer. This is for clients (such as of
+// a MUD).
+//
+// Once the peer sends IAC SB COMPRESS2 IAC SE, what it sends after it is decompressed (with
+// zlib); until the compressed stream ends, after which what it sends is not compressed anymore.
+// And once COMPRESS3 is agreed to, IAC SB COMPRESS3 IAC SE is sent; and what is sent after it is
+// compressed. Either way, Read keeps returning the data as it is. (See Compressing.)
+//
+// (It registers the OptionHandlers for COMPRESS2 and COMPRESS3; replacing any that were registered
+// before.)
+func (clientConn *Conn) AcceptCompression() error {
+	support := OptionSupport{Remote: true}
+
+	if err := clientConn.RegisterOption(OptCompress2, &internalCompression{option: OptCompress2, support: support}); nil != err {
+		return err
+	}
+	return clientConn.RegisterOption(OptCompress3, &internalCompression{option: OptCompress3, support: support})
+}
+
+// Compressing returns whether what is read is being decompressed ('reading'); i.e., whether the
+// peer is compressing what it sends. And whether what is written is being compressed ('writing').
+// (See OfferCompression, and AcceptCompression.)
+func (clientConn *Conn) Compressing() (reading bool, writing bool) {
+	return clientConn.inflating.Load(), clientConn.deflater.compressing.Load()
+}
+
+// internalCompression is the (COMPRESS2, or COMPRESS3) OptionHandler that OfferCompression (and
+// AcceptCompression) registers.
+//
+// With COMPRESS2, the side that has the option enabled compresses what it sends; and with
+// COMPRESS3, it is the other way around.
+type internalCompression struct {
+	option  byte // (OptCompress2, or OptCompress3.)
+	support OptionSupport
+
+	mutex  sync.Mutex
+	sender OptionSender
+}
+
+func (compression *internalCompression) Register(sender OptionSender) OptionSupport {
+	compression.mutex.Lock()
+	compression.sender = sender
+	compression.mutex.Unlock()
+
+	return compression.support
+}
+
+func (compression *internalCompression) LocalChanged(enabled bool) {
+	if OptCompress2 == compression.option {
+		compression.compress(enabled)
+	}
+}
+
+func (compression *internalCompression) RemoteChanged(enabled bool) {
+	if OptCompress3 == compression.option {
+		compression.compress(enabled)
+	}
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB COMPRESS2 IAC SE
+//	IAC SB COMPRESS3 IAC SE
+//
+// ... which (from the side that is to compress) says that what is sent after it is compressed.
+func (compression *internalCompression) Subnegotiation(payload []byte) {
+	compression.mutex.Lock()
+	sender := compression.sender
+	compression.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	conn := sender.Conn()
+
+	local, remote := conn.OptionEnabled(compression.option)
+	if (OptCompress2 == compression.option && !remote) || (OptCompress3 == compression.option && !local) {
+		return
+	}
+	conn.inflate()
+}
+
+// compress starts (by sending IAC SB <option> IAC SE), or stops, compressing what is sent.
+func (compression *internalCompression) compress(enabled bool) {
+	compression.mutex.Lock()
+	sender := compression.sender
+	compression.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	conn := sender.Conn()
+
+	if !enabled {
+		if err := conn.writeCommandThen(nil, conn.deflater.stop); nil != err {
+			conn.logger.Debugf("Problem ending the compressed stream: %v", err)
+		}
+		return
+	}
+
+	if err := conn.writeCommandThen([]byte{IAC, SB, compression.option, IAC, SE}, conn.deflater.start); nil != err {
+		conn.logger.Debugf("Problem starting to compress: %v", err)
+	}
+}
+
+// writeCommandThen is writeCommand; but also calls 'then' once 'p' has been sent, before anything
+// after it is. (See internalDataWriter.writeCommandThen.)
+func (clientConn *Conn) writeCommandThen(p []byte, then func()) error {
+	if clientConn.isClosed() {
+		return ErrClosed
+	}
+
+	err := clientConn.closedErr(clientConn.dataWriter.writeCommandThen(p, then))
+	if nil == err {
+		clientConn.tapCommands(p)
+	}
+	return err
+}
+
+// inflate has what is read after what has been read so far be decompressed; until the compressed
+// stream ends. (The readMutex must be held; as it is, since this is only called while reading.)
+func (clientConn *Conn) inflate() {
+	r := clientConn.dataReader
+
+	// (Whatever came after the IAC SE, that has been read already, is the start of the compressed
+	// stream.)
+	source := r.wrapped
+	if inflater, ok := source.(*internalInflater); ok && inflater.ended && nil == inflater.err {
+		source = inflater.remaining()
+	}
+	if buffered := r.buffered.Buffered(); 0 < buffered {
+		leftover, _ := r.buffered.Peek(buffered)
+		source = io.MultiReader(bytes.NewReader(append([]byte(nil), leftover...)), source)
+	}
+
+	inflater := newInflater(source, clientConn.done, &clientConn.inflating)
+	clientConn.spawn(inflater.run)
+
+	r.wrapped = inflater
+	r.buffered.Reset(inflater)
+	clientConn.inflating.Store(true)
+	clientConn.logger.Debugf("Decompressing what %q sends.", clientConn.RemoteAddr())
+}
+
+// internalInflater decompresses (zlib) what it reads from 'source'; until the compressed stream
+// ends, after which it reads from 'source' as it is.
+//
+// The decompressing is done by a goroutine of its own (see run); which is handed what is read from
+// 'source' (by Read), and hands back what it decompressed. This is so that an error reading from
+// 'source' (such as a timeout; see SetReadDeadline) is returned by Read without it getting to the
+// decompressing; which could not pick up where it left off, if it did.
+type internalInflater struct {
+	source io.Reader
+	done   <-chan struct{}
+	active *atomic.Bool // (Set to false once the compressed stream ends.)
+
+	input  chan []byte // (Read hands what it read to the goroutine.)
+	events chan internalInflaterEvent
+
+	// buffer is what is read into; which the goroutine is done with once it is 'hungry' again.
+	buffer []byte
+	hungry bool
+
+	// output is what has been decompressed, that has not been read yet.
+	output []byte
+
+	// ended is whether the compressed stream ended; 'rest' is what came after it (that was read
+	// from 'source' already), and 'err' (if not nil) is why it ended, if it did not end properly.
+	ended bool
+	rest  []byte
+	err   error
+}
+
+// internalInflaterEvent is what the goroutine doing the decompressing hands back: what it
+// decompressed, that it needs more input, or that the compressed stream ended.
+type internalInflaterEvent struct {
+	output []byte
+	hungry bool
+	ended  bool
+	rest   []byte
+	err    error
+}
+
+func newInflater(source io.Reader, done <-chan struct{}, active *atomic.Bool) *internalInflater {
+	return &internalInflater{
+		source: source,
+		done:   done,
+		active: active,
+		input:  make(chan []byte),
+		events: make(chan internalInflaterEvent),
+		buffer: make([]byte, inflaterBufferSize),
+	}
+}
+
+func (inflater *internalInflater) Read(p []byte) (int, error) {
+	for {
+		if 0 < len(inflater.output) {
+			n := copy(p, inflater.output)
+			inflater.output = inflater.output[n:]
+			return n, nil
+		}
+
+		if inflater.ended {
+			if nil != inflater.err {
+				return 0, inflater.err
+			}
+			if 0 < len(inflater.rest) {
+				n := copy(p, inflater.rest)
+				inflater.rest = inflater.rest[n:]
+				return n, nil
+			}
+			return inflater.source.Read(p)
+		}
+
+		if inflater.hungry {
+			n, err := inflater.source.Read(inflater.buffer)
+			if n <= 0 {
+				if nil == err {
+					continue
+				}
+				return 0, err
+			}
+
+			// (If there was an error too, then the next read returns it again.)
+			inflater.hungry = false
+			select {
+			case inflater.input <- inflater.buffer[:n]:
+			case <-inflater.done:
+				return 0, ErrClosed
+			}
+			continue
+		}
+
+		select {
+		case event := <-inflater.events:
+			inflater.output = event.output
+			inflater.hungry = event.hungry
+			if event.ended {
+				inflater.ended = true
+				inflater.rest = event.rest
+				if io.EOF != event.err {
+					inflater.err = event.err
+				}
+				inflater.active.Store(false)
+			}
+		case <-inflater.done:
+			return 0, ErrClosed
+		}
+	}
+}
+
+// remaining returns what comes after the compressed stream (once it has ended).
+func (inflater *internalInflater) remaining() io.Reader {
+	if len(inflater.rest) <= 0 {
+		return inflater.source
+	}
+	return io.MultiReader(bytes.NewReader(inflater.rest), inflater.source)
+}
+
+// run decompresses what Read hands it; until the compressed stream ends (or the Conn is closed).
+func (inflater *internalInflater) run() {
+	input := &internalInflaterInput{inflater: inflater}
+
+	decompressor, err := zlib.NewReader(input)
+	if nil != err {
+		inflater.send(internalInflaterEvent{ended: true, rest: input.rest(), err: err})
+		return
+	}
+	defer decompressor.Close()
+
+	buffer := make([]byte, inflaterBufferSize)
+	for {
+		n, err := decompressor.Read(buffer)
+		if 0 < n && !inflater.send(internalInflaterEvent{output: append([]byte(nil), buffer[:n]...)}) {
+			return
+		}
+		if nil != err {
+			inflater.send(internalInflaterEvent{ended: true, rest: input.rest(), err: err})
+			return
+		}
+	}
+}
+
+// send hands 'event' back to Read; and returns false if the Conn was closed first.
+func (inflater *internalInflater) send(event internalInflaterEvent) bool {
+	select {
+	case inflater.events <- event:
+		return true
+	case <-inflater.done:
+		return false
+	}
+}
+
+// internalInflaterInput is what the decompressing reads the compressed stream from (a byte at a
+// time, as it is an io.ByteReader; so that it does not read past the end of it). It asks Read for
+// more, each time it runs out.
+type internalInflaterInput struct {
+	inflater *internalInflater
+	p        []byte
+}
+
+func (input *internalInflaterInput) ReadByte() (byte, error) {
+	if err := input.fill(); nil != err {
+		return 0, err
+	}
+
+	b := input.p[0]
+	input.p = input.p[1:]
+	return b, nil
+}
+
+func (input *internalInflaterInput) Read(p []byte) (int, error) {
+	if err := input.fill(); nil != err {
+		return 0, err
+	}
+
+	n := copy(p, input.p)
+	input.p = input.p[n:]
+	return n, nil
+}
+
+// fill waits for Read to hand it more of the compressed stream, if it has run out.
+func (input *internalInflaterInput) fill() error {
+	for len(input.p) <= 0 {
+		if !input.inflater.send(internalInflaterEvent{hungry: true}) {
+			return ErrClosed
+		}
+
+		select {
+		case input.p = <-input.inflater.input:
+		case <-input.inflater.done:
+			return ErrClosed
+		}
+	}
+
+	return nil
+}
+
+// rest returns (a copy of) what is left of what Read handed it; i.e., what came after the
+// compressed stream.
+func (input *internalInflaterInput) rest() []byte {
+	return append([]byte(nil), input.p...)
+}
+
+// internalDeflater is what the wire buffer flushes to; which compresses (zlib) what is sent, once
+// it is started, until it is stopped. (It is only used with the wireMutex held.)
+//
+// Each flush is compressed (and then flushed, with a zlib "sync flush") by itself; so that the
+// peer can decompress what it has been sent, without waiting for more.
+type internalDeflater struct {
+	writer      io.Writer
+	compressor  *zlib.Writer
+	compressing atomic.Bool
+
+	// err (if not nil) is why writing the compressed stream failed; after which it can not be
+	// picked up where it left off.
+	err error
+}
+
+func (deflater *internalDeflater) Write(p []byte) (int, error) {
+	if nil == deflater.compressor {
+		return deflater.writer.Write(p)
+	}
+	if nil != deflater.err {
+		return 0, deflater.err
+	}
+
+	// (What was given to the compressor is taken; even if sending it fails. Sending it again
+	// would have the peer decompress it twice.)
+	if _, err := deflater.compressor.Write(p); nil != err {
+		deflater.err = err
+		return len(p), err
+	}
+	if err := deflater.compressor.Flush(); nil != err {
+		deflater.err = err
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// start starts compressing what is sent. (The wireMutex must be held.)
+func (deflater *internalDeflater) start() {
+	if nil != deflater.compressor {
+		return
+	}
+
+	deflater.compressor = zlib.NewWriter(deflater.writer)
+	deflater.err = nil
+	deflater.compressing.Store(true)
+}
+
+// stop ends the compressed stream; what is sent after is not compressed. (The wireMutex must be
+// held; and whatever was buffered flushed already.)
+func (deflater *internalDeflater) stop() {
+	if nil == deflater.compressor {
+		return
+	}
+
+	if nil == deflater.err {
+		deflater.compressor.Close()
+	}
+	deflater.compressor = nil
+	deflater.compressing.Store(false)
+}
@@ -0,0 +1,232 @@
+package telnet
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"testing"
+)
+
+// testCompressed returns 'p' compressed (with zlib); with the compressed stream ended, if 'end' is
+// true.
+func testCompressed(t *testing.T, p []byte, end bool) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	compressor := zlib.NewWriter(&buffer)
+	compressor.Write(p)
+
+	var err error
+	if end {
+		err = compressor.Close()
+	} else {
+		err = compressor.Flush()
+	}
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	return buffer.Bytes()
+}
+
+func TestConnOfferCompression(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.OfferCompression()
+	}()
+
+	if expected, actual := string([]byte{IAC, WILL, OptCompress2, IAC, WILL, OptCompress3}), string(testReadExactly(t, remote, 6)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	remote.Write([]byte{IAC, DO, OptCompress2})
+	if expected, actual := string([]byte{IAC, SB, OptCompress2, IAC, SE}), string(testReadExactly(t, remote, 5)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (What is sent after the IAC SE is compressed; TELNET commands included.)
+	go func() {
+		conn.Write([]byte("hello\xff"))
+		conn.SendCommand(NOP)
+	}()
+
+	remote.SetReadDeadline(time.Now().Add(3 * time.Second))
+	wire := bufio.NewReader(remote)
+	decompressor, err := zlib.NewReader(wire)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p := make([]byte, 9)
+	if _, err := io.ReadFull(decompressor, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello\xff\xff"+string([]byte{IAC, NOP}), string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if _, writing := conn.Compressing(); !writing {
+		t.Errorf("Expected to be compressing, but actually was not.")
+	}
+
+	// (Once the peer disables it, the compressed stream is ended; after the answer.)
+	remote.Write([]byte{IAC, DONT, OptCompress2})
+
+	p, err = io.ReadAll(decompressor)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := string([]byte{IAC, WONT, OptCompress2}), string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	go conn.Write([]byte("plain"))
+	p = make([]byte, 5)
+	if _, err := io.ReadFull(wire, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "plain", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if _, writing := conn.Compressing(); writing {
+		t.Errorf("Expected to not be compressing, but actually was.")
+	}
+}
+
+func TestConnAcceptCompression(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	go io.Copy(io.Discard, remote)
+
+	conn := newConn(local, nil)
+	if err := conn.AcceptCompression(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (The start of the compressed stream comes along with the IAC SE; and the rest of it, in a
+	// read of its own, after a timeout.)
+	compressed := testCompressed(t, []byte("hello, \xff\xff"+string([]byte{IAC, NOP})+"world"), true)
+	sends := [][]byte{
+		append([]byte{IAC, WILL, OptCompress2, 'a', IAC, SB, OptCompress2, IAC, SE}, compressed[:4]...),
+		append(compressed[4:], "!\r\n"...),
+	}
+	go remote.Write(sends[0])
+
+	p := make([]byte, 1)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "a", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 64)); !os.IsTimeout(err) {
+		t.Fatalf("Expected a timeout, but actually got: (%T) %v", err, err)
+	}
+	if reading, _ := conn.Compressing(); !reading {
+		t.Errorf("Expected to be decompressing, but actually was not.")
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	go remote.Write(sends[1])
+
+	expected := "hello, \xffworld!\r\n"
+	p = make([]byte, len(expected))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if actual := string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if reading, _ := conn.Compressing(); reading {
+		t.Errorf("Expected to not be decompressing, but actually was.")
+	}
+}
+
+func TestConnCompressionBetweenConns(t *testing.T) {
+
+	type result struct {
+		received string
+		reading  bool
+		writing  bool
+	}
+	results := make(chan result, 1)
+
+	listener := testListen(t, func(c net.Conn) {
+		server := newConn(c, nil)
+		if err := server.OfferCompression(); nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		server.SetReadDeadline(time.Now().Add(3 * time.Second))
+		p := make([]byte, len("ping"))
+		io.ReadFull(server, p)
+
+		reading, writing := server.Compressing()
+		results <- result{received: string(p), reading: reading, writing: writing}
+
+		server.Write([]byte("pong"))
+		io.Copy(io.Discard, server)
+	})
+
+	c, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	client := newConn(c, nil)
+	defer client.Close()
+
+	if err := client.AcceptCompression(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, len("pong"))
+		client.SetReadDeadline(time.Now().Add(3 * time.Second))
+		io.ReadFull(client, p)
+		received <- p
+	}()
+
+	// (The client compresses what it sends once COMPRESS3 is agreed to.)
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, writing := client.Compressing(); writing {
+			break
+		}
+	}
+
+	client.Write([]byte("ping"))
+	select {
+	case actual := <-results:
+		if expected := (result{received: "ping", reading: true, writing: true}); expected != actual {
+			t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the server to receive it, but actually it did not.")
+	}
+
+	select {
+	case p := <-received:
+		if expected, actual := "pong", string(p); expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the client to receive it, but actually it did not.")
+	}
+
+	if reading, writing := client.Compressing(); !reading || !writing {
+		t.Errorf("Expected the client to be compressing both ways, but actually got (reading: %t, writing: %t).", reading, writing)
+	}
+}
@@ -0,0 +1,123 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// TestConnConcurrentWrites has several goroutines writing (IAC heavy) data to a Conn, while others
+// send subnegotiations, and commands, on it; and checks that the peer gets each Write whole, and each
+// command whole (and never in the middle of an escaped IAC). (It is mostly of use with -race.)
+func TestConnConcurrentWrites(t *testing.T) {
+
+	const (
+		writers   = 4
+		writes    = 16
+		senders   = 4
+		sends     = 32
+		writeSize = 3 * 4096
+	)
+
+	conn, remote := testPipe(t)
+	peer := newConn(remote, nil)
+
+	var events []CommandEvent
+	peer.OnUnhandledCommand(func(event CommandEvent) {
+		event.Payload = append([]byte(nil), event.Payload...)
+		events = append(events, event)
+	})
+
+	// (Each writer's data is IAC, and its own letter, over and over; so that a Write that was split,
+	// or had something sent into the middle of it, does not match any of them.)
+	messages := make([][]byte, writers)
+	for writer := range messages {
+		messages[writer] = bytes.Repeat([]byte{IAC, 'a' + byte(writer)}, writeSize/2)
+	}
+	subnegotiation := []byte{'p', 'i', 'n', 'g', IAC, 's', 'e'}
+
+	received := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, writers*writes*writeSize+1)
+		peer.SetReadDeadline(time.Now().Add(10 * time.Second))
+		n, _ := io.ReadFull(peer, p)
+		received <- p[:n]
+	}()
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < writers; writer++ {
+		wg.Add(1)
+		go func(message []byte) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				if _, err := conn.Write(message); nil != err {
+					t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+					return
+				}
+			}
+		}(messages[writer])
+	}
+	for sender := 0; sender < senders; sender++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < sends; i++ {
+				if err := conn.SendSubnegotiation(150, subnegotiation); nil != err {
+					t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+					return
+				}
+				if err := conn.SendCommand(NOP); nil != err {
+					t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// (So that the peer has read past the last of the commands.)
+	if _, err := conn.Write([]byte{'.'}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	data := <-received
+	if expected, actual := writers*writes*writeSize+1, len(data); expected != actual {
+		t.Fatalf("Expected %d bytes of data, but actually got %d.", expected, actual)
+	}
+
+	counts := make([]int, writers)
+	for offset := 0; offset+writeSize <= len(data); offset += writeSize {
+		chunk := data[offset : offset+writeSize]
+		writer := int(chunk[1] - 'a')
+		if writer < 0 || writers <= writer || !bytes.Equal(messages[writer], chunk) {
+			t.Fatalf("Expected the data at offset %d to be a whole Write, but actually it was not.", offset)
+		}
+		counts[writer]++
+	}
+	for writer, count := range counts {
+		if expected, actual := writes, count; expected != actual {
+			t.Errorf("For writer #%d, expected %d Writes, but actually got %d.", writer, expected, actual)
+		}
+	}
+
+	var subnegotiations, nops int
+	for eventNumber, event := range events {
+		switch {
+		case SB == event.Verb && 150 == event.Option && bytes.Equal(subnegotiation, event.Payload):
+			subnegotiations++
+		case NOP == event.Verb:
+			nops++
+		default:
+			t.Errorf("For event #%d, did not expect %v.", eventNumber, event)
+		}
+	}
+	if expected, actual := senders*sends, subnegotiations; expected != actual {
+		t.Errorf("Expected %d subnegotiations, but actually got %d.", expected, actual)
+	}
+	if expected, actual := senders*sends, nops; expected != actual {
+		t.Errorf("Expected %d NOPs, but actually got %d.", expected, actual)
+	}
+}
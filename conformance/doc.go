@@ -0,0 +1,61 @@
+/*
+Package conformance provides a harness for checking (end-to-end) that a telnet.Conn gets along with
+real TELNET implementations; such as the BSD telnet client, PuTTY (plink), and busybox telnetd.
+
+The external program is run (with os/exec) on a pseudo-terminal; so that it can be typed into, and
+resized, as it would be by a user at a terminal. And what goes over the wire is recorded; so that
+it can be asserted (such as that a NAWS subnegotiation had the new size, in the right byte order; or
+that the 255s in some binary data were escaped).
+
+# Client
+
+Here is an example usage, against an external telnet client:
+
+	func TestTelnetClient(t *testing.T) {
+
+		path := conformance.Require(t, "telnet")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, err := conformance.ServeClient(ctx, nil, path)
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		defer session.Close()
+
+		session.Conn.RegisterOption(telnet.OptNAWS, conformance.Willing(false, true))
+
+		scenario := conformance.Scenario{
+			Name: "telnet",
+			Steps: []conformance.Step{
+				conformance.Negotiate(telnet.OptNAWS, false),
+				conformance.Resize(100, 40),
+				conformance.SendData([]byte{'a', 255, 'b'}),
+				conformance.Interrupt(),
+			},
+		}
+
+		if err := scenario.Run(ctx, session); nil != err {
+			t.Error(err)
+		}
+	}
+
+(Require skips the test if the program is not installed.)
+
+# Server
+
+And against an external telnet server, it is much the same; but with DialServer. For example:
+
+	session, err := conformance.DialServer(ctx, nil, "127.0.0.1:2323", path, "telnetd", "-F", "-p", "2323", "-l", "/bin/cat")
+
+# Running
+
+The conformance tests of this package (for the programs above) are behind the "conformance" build
+tag; as they need the programs installed, and a pseudo-terminal. To run them:
+
+	go test -tags conformance ./conformance
+
+(Pseudo-terminals are only supported on Linux; elsewhere, OpenPTY returns ErrPTYUnsupported.)
+*/
+package conformance
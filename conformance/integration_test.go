@@ -0,0 +1,126 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"testing"
+)
+
+// testConformanceTimeout is how long each of the conformance tests has; for the external program to
+// start, and for each of the steps.
+const testConformanceTimeout = 15 * time.Second
+
+// testFreeAddr returns a (loopback) address, with a port that is (most likely) free; for an external
+// telnet server to listen on.
+func testFreeAddr(t *testing.T) (string, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String(), strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestConformanceTelnetClient(t *testing.T) {
+
+	path := Require(t, "telnet")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testConformanceTimeout)
+	defer cancel()
+
+	session, err := ServeClient(ctx, nil, path)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer session.Close()
+
+	session.Conn.RegisterOption(telnet.OptNAWS, Willing(false, true))
+
+	scenario := Scenario{
+		Name: "telnet",
+		Steps: []Step{
+			Expect("Connected to"),
+			Negotiate(telnet.OptNAWS, false),
+			Resize(100, 40),
+			Resize(300, 255),
+			SendData([]byte{'a', 255, 'b', 255, 255, 'c'}),
+			Type("hello world\r"),
+			ReceiveLine("hello world"),
+			Interrupt(),
+		},
+	}
+
+	if err := scenario.Run(ctx, session); nil != err {
+		t.Errorf("%v\n\nThe program showed:\n%s", err, session.Program.Output())
+	}
+}
+
+func TestConformancePuTTYClient(t *testing.T) {
+
+	path := Require(t, "plink")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testConformanceTimeout)
+	defer cancel()
+
+	session, err := ServeClient(ctx, nil, path, "-telnet", "-batch", "-P", "{port}", "{host}")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer session.Close()
+
+	session.Conn.RegisterOption(telnet.OptNAWS, Willing(false, true))
+
+	scenario := Scenario{
+		Name: "plink",
+		Steps: []Step{
+			Negotiate(telnet.OptNAWS, false),
+			Resize(100, 40),
+			SendData([]byte{'a', 255, 'b'}),
+			Type("hello world\r"),
+			ReceiveLine("hello world"),
+		},
+	}
+
+	if err := scenario.Run(ctx, session); nil != err {
+		t.Errorf("%v\n\nThe program showed:\n%s", err, session.Program.Output())
+	}
+}
+
+func TestConformanceBusyboxTelnetd(t *testing.T) {
+
+	path := Require(t, "busybox")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testConformanceTimeout)
+	defer cancel()
+
+	addr, port := testFreeAddr(t)
+
+	session, err := DialServer(ctx, nil, addr, path, "telnetd", "-F", "-p", port, "-b", "127.0.0.1", "-l", "/bin/cat")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer session.Close()
+
+	scenario := Scenario{
+		Name: "busybox telnetd",
+		Steps: []Step{
+			SetWindowSize(100, 40),
+			SendData([]byte("hello world\r\n")),
+			Receive([]byte("hello world")),
+		},
+	}
+
+	if err := scenario.Run(ctx, session); nil != err {
+		t.Errorf("%v\n\nThe program showed:\n%s", err, session.Program.Output())
+	}
+}
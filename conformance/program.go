@@ -0,0 +1,210 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ErrNotInstalled is returned by LookPath when none of the programs it was given is installed.
+var ErrNotInstalled = errors.New("conformance: program not installed")
+
+// interruptCharacter is what typing Ctrl-C sends to the terminal.
+const interruptCharacter = 0x03
+
+// LookPath returns the path of the first of the programs 'names' (such as "telnet", or "busybox")
+// that is installed; i.e., that exec.LookPath finds. If none of them is, then it returns
+// ErrNotInstalled.
+func LookPath(names ...string) (string, error) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); nil == err {
+			return path, nil
+		}
+	}
+
+	return "", ErrNotInstalled
+}
+
+// Require returns the path of the first of the programs 'names' that is installed (see LookPath);
+// or, if none of them is, then it skips the test. (So that a conformance test does not fail where
+// the programs it runs against are not installed.) For example:
+//
+//	path := conformance.Require(t, "telnet")
+func Require(t testing.TB, names ...string) string {
+	t.Helper()
+
+	path, err := LookPath(names...)
+	if nil != err {
+		t.Skipf("Skipping; none of %q is installed.", names)
+	}
+	return path
+}
+
+// Program is an external program (such as a telnet client, or telnetd), running on a pseudo-terminal
+// (see PTY); as it would for a user at a terminal. (See Start.)
+//
+// What it shows (i.e., what it writes to the terminal) is kept; see Output, and Expect.
+type Program struct {
+	Cmd *exec.Cmd
+	PTY *PTY
+
+	mutex  sync.Mutex
+	output strings.Builder
+
+	// changed is closed (and replaced) whenever there is more output; or once there will not be.
+	changed chan struct{}
+	ended   bool
+
+	exited  chan struct{}
+	waitErr error
+}
+
+// Start runs the program 'path' (with the arguments 'args') on a (new) pseudo-terminal. (Close it
+// when done with it.)
+func Start(path string, args ...string) (*Program, error) {
+	pty, err := OpenPTY()
+	if nil != err {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(cmd.Environ(), "TERM=xterm")
+	attachPTY(cmd, pty.Slave)
+
+	if err := cmd.Start(); nil != err {
+		pty.Close()
+		return nil, err
+	}
+
+	// (So that reading the Master fails once the program has exited; rather than wait forever.)
+	pty.Slave.Close()
+
+	program := &Program{
+		Cmd:     cmd,
+		PTY:     pty,
+		changed: make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+
+	go program.read()
+	go func() {
+		program.waitErr = cmd.Wait()
+		close(program.exited)
+	}()
+
+	return program, nil
+}
+
+// read keeps what the program shows; until the pseudo-terminal is closed.
+func (program *Program) read() {
+	p := make([]byte, 4096)
+	for {
+		n, err := program.PTY.Master.Read(p)
+
+		program.mutex.Lock()
+		program.output.Write(p[:n])
+		if nil != err {
+			program.ended = true
+		}
+		close(program.changed)
+		program.changed = make(chan struct{})
+		program.mutex.Unlock()
+
+		if nil != err {
+			return
+		}
+	}
+}
+
+// Write types 'p' into the program; as a user would, at its terminal.
+func (program *Program) Write(p []byte) (int, error) {
+	return program.PTY.Master.Write(p)
+}
+
+// Type types 's' into the program. (It is like Write.)
+func (program *Program) Type(s string) error {
+	_, err := program.Write([]byte(s))
+	return err
+}
+
+// Interrupt types Ctrl-C into the program. (Which a telnet client sends, to the server, as an
+// IAC IP; or as the byte itself, depending on its mode.)
+func (program *Program) Interrupt() error {
+	_, err := program.Write([]byte{interruptCharacter})
+	return err
+}
+
+// Resize resizes the program's terminal; as a user resizing their terminal window would.
+func (program *Program) Resize(width int, height int) error {
+	return program.PTY.Resize(width, height)
+}
+
+// Output returns what the program has shown (i.e., written to its terminal) so far.
+func (program *Program) Output() string {
+	program.mutex.Lock()
+	defer program.mutex.Unlock()
+
+	return program.output.String()
+}
+
+// Expect waits for the program to show 's' (i.e., for its Output to have 's' in it). It returns the
+// error of 'ctx', if that is done first; or an error that says what the Output was, if the program
+// shows nothing more (such as because it exited) before then.
+func (program *Program) Expect(ctx context.Context, s string) error {
+	for {
+		program.mutex.Lock()
+		output, ended, changed := program.output.String(), program.ended, program.changed
+		program.mutex.Unlock()
+
+		if strings.Contains(output, s) {
+			return nil
+		}
+		if ended {
+			return &internalExpectError{expected: s, output: output}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Exited returns a channel that is closed once the program has exited.
+func (program *Program) Exited() <-chan struct{} {
+	return program.exited
+}
+
+// Wait waits for the program to exit; and returns what exec.Cmd.Wait returned.
+func (program *Program) Wait() error {
+	<-program.exited
+	return program.waitErr
+}
+
+// Close kills the program (unless it has exited already), waits for it to exit, and closes its
+// pseudo-terminal.
+func (program *Program) Close() error {
+	select {
+	case <-program.exited:
+	default:
+		program.Cmd.Process.Kill()
+		<-program.exited
+	}
+
+	// (The Slave was closed once the program was started.)
+	return program.PTY.Master.Close()
+}
+
+type internalExpectError struct {
+	expected string
+	output   string
+}
+
+func (err *internalExpectError) Error() string {
+	return "conformance: expected the program to show " + strconv.Quote(err.expected) + ", but it only showed " + strconv.Quote(err.output)
+}
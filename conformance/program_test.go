@@ -0,0 +1,78 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"testing"
+)
+
+// testStart starts 'script' (with sh) on a pseudo-terminal; or skips the test, if there is no sh,
+// or pseudo-terminals are not supported.
+func testStart(t *testing.T, script string) *Program {
+	t.Helper()
+
+	sh := Require(t, "sh")
+
+	program, err := Start(sh, "-c", script)
+	if errors.Is(err, ErrPTYUnsupported) {
+		t.Skipf("Skipping; %v.", err)
+	}
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	return program
+}
+
+func TestProgramResize(t *testing.T) {
+
+	Require(t, "stty")
+	program := testStart(t, "stty size; read line; stty size")
+	defer program.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := program.Expect(ctx, "24 80"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := program.Resize(100, 40); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := program.Type("\r"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := program.Expect(ctx, "40 100"); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
+
+func TestProgramExpectExited(t *testing.T) {
+
+	program := testStart(t, "echo fig")
+	defer program.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := program.Expect(ctx, "fig"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	err := program.Expect(ctx, "grape")
+	if nil == err {
+		t.Fatalf("Expected an error, but did not actually get one.")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the error to not be (that of) the context, but actually got: (%T) %v", err, err)
+	}
+
+	select {
+	case <-program.Exited():
+	case <-ctx.Done():
+		t.Errorf("Expected the program to have exited, but it did not.")
+	}
+}
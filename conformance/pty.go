@@ -0,0 +1,55 @@
+package conformance
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrPTYUnsupported is returned by OpenPTY on operating systems that it does not (yet) know how to
+// open a pseudo-terminal on.
+var ErrPTYUnsupported = errors.New("conformance: pseudo-terminals not supported")
+
+// PTY is a pseudo-terminal; which an external program (such as a telnet client) is run on, so that
+// it behaves as it would for a user at a terminal. (See Start.)
+//
+// What is written to the Master is what the user types; and what is read from it is what the
+// program shows. The Slave is what the program is given as its terminal. (Start closes the Slave,
+// once the program has been started with it.)
+type PTY struct {
+	Master *os.File
+	Slave  *os.File
+}
+
+// OpenPTY opens a (new) pseudo-terminal; which is 80 columns wide, and 24 rows high. (Close it when
+// done with it.)
+//
+// It returns ErrPTYUnsupported on operating systems it does not know how to open one on.
+func OpenPTY() (*PTY, error) {
+	master, slave, err := openPTY()
+	if nil != err {
+		return nil, err
+	}
+
+	pty := &PTY{Master: master, Slave: slave}
+	if err := pty.Resize(80, 24); nil != err {
+		pty.Close()
+		return nil, err
+	}
+
+	return pty, nil
+}
+
+// Resize sets the size of the pseudo-terminal; which (such as for a telnet client that is doing
+// NAWS) the program running on it is told of, with a SIGWINCH.
+func (pty *PTY) Resize(width int, height int) error {
+	return resizePTY(pty.Master, width, height)
+}
+
+// Close closes both ends of the pseudo-terminal.
+func (pty *PTY) Close() error {
+	err := pty.Slave.Close()
+	if masterErr := pty.Master.Close(); nil == err {
+		err = masterErr
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package conformance
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY opens /dev/ptmx; and the slave (under /dev/pts) that goes with it.
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); nil != err {
+		master.Close()
+		return nil, nil, err
+	}
+
+	var number uint32
+	if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); nil != err {
+		master.Close()
+		return nil, nil, err
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(number), 10), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if nil != err {
+		master.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+func resizePTY(master *os.File, width int, height int) error {
+	size := struct {
+		rows, columns, x, y uint16
+	}{
+		rows:    uint16(height),
+		columns: uint16(width),
+	}
+
+	return ioctl(master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&size)))
+}
+
+// attachPTY has 'cmd' run (in a session of its own) with 'slave' as its (controlling) terminal.
+func attachPTY(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+	}
+}
+
+// ioctl calls the ioctl 'request' on 'file'. (Without using file.Fd(); which would put the file into
+// blocking mode, and so have Close not stop a Read that is in progress.)
+func ioctl(file *os.File, request uintptr, arg uintptr) error {
+	raw, err := file.SyscallConn()
+	if nil != err {
+		return err
+	}
+
+	var errno syscall.Errno
+	if err := raw.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	}); nil != err {
+		return err
+	}
+	if 0 != errno {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package conformance
+
+import (
+	"os"
+	"os/exec"
+)
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, ErrPTYUnsupported
+}
+
+func resizePTY(master *os.File, width int, height int) error {
+	return ErrPTYUnsupported
+}
+
+func attachPTY(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+}
@@ -0,0 +1,243 @@
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"fmt"
+	"time"
+)
+
+// pollInterval is how often a Step that waits for the state of the Conn to change checks it.
+const pollInterval = 5 * time.Millisecond
+
+// Step is one step of a Scenario; such as resizing the terminal of the external client, and waiting
+// for the NAWS subnegotiation that should cause. (See the functions that return a Step; such as
+// Resize, and SendData.)
+type Step struct {
+	Name string
+	Run  func(ctx context.Context, session *Session) error
+}
+
+// Scenario is a script (of Steps) to run, on a Session; to check that our Conn, and the external
+// telnet client (or server), get along. For example:
+//
+//	scenario := conformance.Scenario{
+//		Name: "resize",
+//		Steps: []conformance.Step{
+//			conformance.Negotiate(telnet.OptNAWS, false),
+//			conformance.Resize(100, 40),
+//		},
+//	}
+//
+//	if err := scenario.Run(ctx, session); nil != err {
+//		t.Error(err)
+//	}
+type Scenario struct {
+	Name  string
+	Steps []Step
+}
+
+// Run runs each of the Steps (in order) on 'session'; and stops at the first one that fails, with
+// an error that says which one it was.
+func (scenario Scenario) Run(ctx context.Context, session *Session) error {
+	for k, step := range scenario.Steps {
+		if err := step.Run(ctx, session); nil != err {
+			return &internalStepError{scenario: scenario.Name, step: k, name: step.Name, err: err}
+		}
+	}
+
+	return nil
+}
+
+type internalStepError struct {
+	scenario string
+	step     int
+	name     string
+	err      error
+}
+
+func (err *internalStepError) Error() string {
+	return fmt.Sprintf("conformance: scenario %q, step #%d (%s): %v", err.scenario, err.step, err.name, err.err)
+}
+
+func (err *internalStepError) Unwrap() error {
+	return err.err
+}
+
+// Negotiate returns a Step that asks the peer to enable 'option' (on its side if 'local' is false;
+// i.e., sends a DO for it, and otherwise a WILL); and waits for it to agree. (An OptionHandler
+// has to have been registered for 'option'; see telnet.Conn.RequestEnableRemote, and Willing.)
+func Negotiate(option byte, local bool) Step {
+	return Step{
+		Name: "negotiate " + telnet.OptionName(option),
+		Run: func(ctx context.Context, session *Session) error {
+			if local {
+				return session.Conn.RequestEnableLocal(ctx, option)
+			}
+			return session.Conn.RequestEnableRemote(ctx, option)
+		},
+	}
+}
+
+// Willing returns an OptionHandler that is willing to perform an option (if 'local'), and to have
+// the peer perform it (if 'remote'); but does nothing else with it. (Such as for Negotiate; for an
+// option that is only being checked to be negotiated.) For example:
+//
+//	session.Conn.RegisterOption(telnet.OptNAWS, conformance.Willing(false, true))
+func Willing(local bool, remote bool) telnet.OptionHandler {
+	return internalWilling{local: local, remote: remote}
+}
+
+type internalWilling struct {
+	local  bool
+	remote bool
+}
+
+func (willing internalWilling) Register(telnet.OptionSender) telnet.OptionSupport {
+	return telnet.OptionSupport{Local: willing.local, Remote: willing.remote}
+}
+
+func (internalWilling) LocalChanged(bool) {}
+
+func (internalWilling) RemoteChanged(bool) {}
+
+func (internalWilling) Subnegotiation([]byte) {}
+
+// WaitForOption returns a Step that waits for 'option' to be enabled (or not) on each side, as
+// 'local' and 'remote' say; as the Conn sees it. (Such as for what the peer asks for by itself.)
+func WaitForOption(option byte, local bool, remote bool) Step {
+	return Step{
+		Name: "wait for " + telnet.OptionName(option),
+		Run: func(ctx context.Context, session *Session) error {
+			for {
+				actualLocal, actualRemote := session.Conn.OptionEnabled(option)
+				if local == actualLocal && remote == actualRemote {
+					return nil
+				}
+
+				select {
+				case <-time.After(pollInterval):
+				case <-ctx.Done():
+					return fmt.Errorf("expected %s to be (local: %t, remote: %t), but it was (local: %t, remote: %t): %w", telnet.OptionName(option), local, remote, actualLocal, actualRemote, ctx.Err())
+				}
+			}
+		},
+	}
+}
+
+// Resize returns a Step that resizes the terminal of the external client; and waits for the client
+// to send the (NAWS) subnegotiation for the new size. (NAWS has to have been negotiated; see
+// Negotiate.)
+func Resize(width int, height int) Step {
+	return Step{
+		Name: fmt.Sprintf("resize to %dx%d", width, height),
+		Run: func(ctx context.Context, session *Session) error {
+			if err := session.Program.Resize(width, height); nil != err {
+				return err
+			}
+
+			payload := []byte{byte(width >> 8), byte(width), byte(height >> 8), byte(height)}
+			return session.Wire.WaitFromPeer(ctx, Subnegotiation(telnet.OptNAWS, payload))
+		},
+	}
+}
+
+// SetWindowSize returns a Step that sets the size of our (the Conn's) terminal (see
+// telnet.Conn.SetWindowSize); and waits for the (NAWS) subnegotiation for it to have gone over the
+// wire. (This is for when the external program is a telnet server; so that it is told the size.)
+func SetWindowSize(width int, height int) Step {
+	return Step{
+		Name: fmt.Sprintf("set the window size to %dx%d", width, height),
+		Run: func(ctx context.Context, session *Session) error {
+			if err := session.Conn.SetWindowSize(width, height); nil != err {
+				return err
+			}
+
+			payload := []byte{byte(width >> 8), byte(width), byte(height >> 8), byte(height)}
+			return session.Wire.WaitToPeer(ctx, Subnegotiation(telnet.OptNAWS, payload))
+		},
+	}
+}
+
+// SendData returns a Step that writes 'data' to the Conn; and waits for it to have gone over the
+// wire, escaped. (So 'data' with 255s in it checks the escaping.)
+func SendData(data []byte) Step {
+	return Step{
+		Name: fmt.Sprintf("send %d bytes of data", len(data)),
+		Run: func(ctx context.Context, session *Session) error {
+			if _, err := session.Conn.Write(data); nil != err {
+				return err
+			}
+
+			return session.Wire.WaitToPeer(ctx, Escape(data))
+		},
+	}
+}
+
+// Type returns a Step that types 's' into the external program. (Such as a line, ending with "\r";
+// which a telnet client sends as CR LF, or CR NUL.)
+func Type(s string) Step {
+	return Step{
+		Name: fmt.Sprintf("type %q", s),
+		Run: func(ctx context.Context, session *Session) error {
+			return session.Program.Type(s)
+		},
+	}
+}
+
+// Expect returns a Step that waits for the external program to show 's'. (See Program.Expect.)
+func Expect(s string) Step {
+	return Step{
+		Name: fmt.Sprintf("expect %q", s),
+		Run: func(ctx context.Context, session *Session) error {
+			return session.Program.Expect(ctx, s)
+		},
+	}
+}
+
+// ReceiveLine returns a Step that waits for the Conn to receive (i.e., read) a line of data; and
+// checks that it is 'expected'. (A line ends with a CR LF, a LF, or a CR NUL; which is not part of
+// it. See Session.Received.)
+func ReceiveLine(expected string) Step {
+	return Step{
+		Name: fmt.Sprintf("receive the line %q", expected),
+		Run: func(ctx context.Context, session *Session) error {
+			line, err := session.receiveLine(ctx)
+			if nil != err {
+				return err
+			}
+
+			if expected != line {
+				return fmt.Errorf("expected the line %q, but actually got %q", expected, line)
+			}
+			return nil
+		},
+	}
+}
+
+// Receive returns a Step that waits for the Conn to receive (i.e., read) the data 'expected'. (See
+// Session.Received.)
+func Receive(expected []byte) Step {
+	return Step{
+		Name: fmt.Sprintf("receive %q", expected),
+		Run: func(ctx context.Context, session *Session) error {
+			return session.receive(ctx, expected)
+		},
+	}
+}
+
+// Interrupt returns a Step that types Ctrl-C into the external client; and waits for the client
+// to send it, as an IAC IP (or, depending on its mode, as the byte itself).
+func Interrupt() Step {
+	return Step{
+		Name: "interrupt",
+		Run: func(ctx context.Context, session *Session) error {
+			if err := session.Program.Interrupt(); nil != err {
+				return err
+			}
+
+			return session.Wire.WaitFromPeer(ctx, []byte{telnet.IAC, telnet.IP}, []byte{interruptCharacter})
+		},
+	}
+}
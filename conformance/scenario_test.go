@@ -0,0 +1,169 @@
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testSession returns a Session with our (server) Conn, talking to our own (client) Conn; rather
+// than to an external program. (So it has no Program.)
+func testSession(t *testing.T) (*Session, *telnet.Conn) {
+	t.Helper()
+
+	listener, err := Listen()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	served := make(chan *telnet.Conn, 1)
+	server := &telnet.Server{ContextHandler: internalHandOver{served: served}}
+	go server.Serve(listener)
+
+	session := &Session{closers: []func() error{listener.Close}}
+
+	client, err := telnet.DialTo(listener.Addr().String())
+	if nil != err {
+		session.Close()
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	session.closers = append(session.closers, client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	select {
+	case session.Conn = <-served:
+	case <-ctx.Done():
+		session.Close()
+		t.Fatalf("Timed out waiting for the connection to be served.")
+	}
+	if session.Wire, err = listener.Next(ctx); nil != err {
+		session.Close()
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	session.startReading()
+
+	return session, client
+}
+
+func TestScenario(t *testing.T) {
+
+	session, client := testSession(t)
+	defer session.Close()
+
+	session.Conn.RegisterOption(telnet.OptNAWS, Willing(false, true))
+	client.RegisterOption(telnet.OptNAWS, Willing(true, false))
+
+	var mutex sync.Mutex
+	var received []byte
+	go func() {
+		p := make([]byte, 1024)
+		for {
+			n, err := client.Read(p)
+			mutex.Lock()
+			received = append(received, p[:n]...)
+			mutex.Unlock()
+			if nil != err {
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data := []byte{'a', 255, 'b', 255, 255, 'c'}
+
+	scenario := Scenario{
+		Name: "loopback",
+		Steps: []Step{
+			Negotiate(telnet.OptNAWS, false),
+			WaitForOption(telnet.OptNAWS, false, true),
+			SendData(data),
+			{
+				Name: "send a line",
+				Run: func(context.Context, *Session) error {
+					_, err := client.Write([]byte("hello world\r\nsecond line\r\x00"))
+					return err
+				},
+			},
+			ReceiveLine("hello world"),
+			ReceiveLine("second line"),
+			{
+				Name: "send binary data",
+				Run: func(context.Context, *Session) error {
+					_, err := client.Write([]byte{1, 255, 2})
+					return err
+				},
+			},
+			Receive([]byte{1, 255, 2}),
+		},
+	}
+
+	if err := scenario.Run(ctx, session); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := session.Wire.WaitToPeer(ctx, Negotiation(telnet.DO, telnet.OptNAWS)); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	for {
+		mutex.Lock()
+		actual := append([]byte(nil), received...)
+		mutex.Unlock()
+
+		if bytes.Equal(data, actual) {
+			break
+		}
+		select {
+		case <-time.After(pollInterval):
+			continue
+		case <-ctx.Done():
+			t.Errorf("Expected %v, but actually got %v.", data, actual)
+		}
+		break
+	}
+}
+
+func TestScenarioStepError(t *testing.T) {
+
+	failure := errors.New("kiwi")
+
+	scenario := Scenario{
+		Name: "failing",
+		Steps: []Step{
+			{
+				Name: "succeed",
+				Run:  func(context.Context, *Session) error { return nil },
+			},
+			{
+				Name: "fail",
+				Run:  func(context.Context, *Session) error { return failure },
+			},
+			{
+				Name: "never run",
+				Run: func(context.Context, *Session) error {
+					t.Errorf("Did not expect the step after the failing one to be run, but it was.")
+					return nil
+				},
+			},
+		},
+	}
+
+	err := scenario.Run(context.Background(), &Session{})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the error to be that of the step, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := `scenario "failing", step #1 (fail): kiwi`, err.Error(); !strings.Contains(actual, expected) {
+		t.Errorf("Expected the error to have %q in it, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,358 @@
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialRetryInterval is how often DialServer tries to connect to the external server, while it is
+// (still) starting up.
+const dialRetryInterval = 20 * time.Millisecond
+
+// errNotReading is returned by ReceiveLine, and Receive, on a Session that is not reading its Conn.
+var errNotReading = errors.New("conformance: the Session is not reading the Conn")
+
+// Session is our Conn, talking to an external telnet client (or server); which is what a Scenario
+// is run on. (See ServeClient, and DialServer.)
+//
+// The Session reads the Conn (so that it answers option negotiations; see telnet.Conn.Read), and
+// keeps what data it received; see Received, and the ReceiveLine and Receive Steps. (Unless the
+// Conn is being served by a handler; in which case it is the handler that reads it.)
+type Session struct {
+	// Program is the external telnet client (or server).
+	Program *Program
+
+	// Conn is our end of the connection; and Wire is what went over it.
+	Conn *telnet.Conn
+	Wire *Wire
+
+	mutex    sync.Mutex
+	reading  bool
+	received []byte
+	consumed int
+	readErr  error
+
+	// changed is closed (and replaced) whenever more is received; or once nothing more will be.
+	changed chan struct{}
+
+	closers []func() error
+}
+
+// Received returns (a copy of) the data that the Conn has received (i.e., read) so far.
+func (session *Session) Received() []byte {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return append([]byte(nil), session.received...)
+}
+
+// Close closes the Conn; and (then) closes the Program, and whatever else the Session was made with.
+func (session *Session) Close() error {
+	var err error
+	if nil != session.Conn {
+		err = session.Conn.Close()
+	}
+
+	for k := len(session.closers) - 1; 0 <= k; k-- {
+		if closeErr := session.closers[k](); nil == err {
+			err = closeErr
+		}
+	}
+	session.closers = nil
+
+	return err
+}
+
+// startReading has the Session read its Conn; until reading it fails (such as because it was
+// closed).
+func (session *Session) startReading() {
+	session.mutex.Lock()
+	session.reading = true
+	session.changed = make(chan struct{})
+	session.mutex.Unlock()
+
+	go func() {
+		p := make([]byte, 1024)
+		for {
+			n, err := session.Conn.Read(p)
+
+			session.mutex.Lock()
+			session.received = append(session.received, p[:n]...)
+			if nil != err {
+				session.readErr = err
+			}
+			close(session.changed)
+			session.changed = make(chan struct{})
+			session.mutex.Unlock()
+
+			if nil != err {
+				return
+			}
+		}
+	}()
+}
+
+// receiveLine waits for a line (of what was received, after what was waited for before); and
+// returns it, without what it ended with.
+func (session *Session) receiveLine(ctx context.Context) (string, error) {
+	p, err := session.await(ctx, "a line", func(p []byte) int {
+		end := -1
+		if k := bytes.IndexByte(p, '\n'); 0 <= k {
+			end = k + 1
+		}
+		if k := bytes.Index(p, []byte{'\r', 0}); 0 <= k && (end < 0 || k+2 < end) {
+			end = k + 2
+		}
+		return end
+	})
+	if nil != err {
+		return "", err
+	}
+
+	for _, suffix := range [][]byte{{'\r', '\n'}, {'\n'}, {'\r', 0}} {
+		if bytes.HasSuffix(p, suffix) {
+			p = p[:len(p)-len(suffix)]
+			break
+		}
+	}
+	return string(p), nil
+}
+
+// receive waits for 'expected' (in what was received, after what was waited for before).
+func (session *Session) receive(ctx context.Context, expected []byte) error {
+	_, err := session.await(ctx, fmt.Sprintf("%q", expected), func(p []byte) int {
+		k := bytes.Index(p, expected)
+		if k < 0 {
+			return -1
+		}
+		return k + len(expected)
+	})
+	return err
+}
+
+// await waits for 'end' to find where what it is waiting for (i.e., 'what') ends, in what was
+// received (after what was waited for before); and returns it, up to there.
+func (session *Session) await(ctx context.Context, what string, end func([]byte) int) ([]byte, error) {
+	for {
+		session.mutex.Lock()
+		if !session.reading {
+			session.mutex.Unlock()
+			return nil, errNotReading
+		}
+		p := session.received[session.consumed:]
+		if n := end(p); 0 <= n {
+			session.consumed += n
+			session.mutex.Unlock()
+			return p[:n], nil
+		}
+		readErr, changed := session.readErr, session.changed
+		session.mutex.Unlock()
+
+		if nil != readErr {
+			return nil, fmt.Errorf("expected to receive %s, but only got %q: %w", what, p, readErr)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("expected to receive %s, but only got %q: %w", what, p, ctx.Err())
+		}
+	}
+}
+
+// ServeClient runs the external telnet client 'path' (with the arguments 'args', followed by the
+// host, and the port, to connect to) on a pseudo-terminal; and serves the connection it makes with
+// 'server'. It returns once the client has connected (or 'ctx' is done, or the client exited). For
+// example:
+//
+//	session, err := conformance.ServeClient(ctx, nil, conformance.Require(t, "telnet"))
+//	if nil != err {
+//		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+//	}
+//	defer session.Close()
+//
+// (For a client that takes the host, and the port, some other way, put "{host}", and "{port}", in
+// 'args'; in which case they are replaced, rather than appended. Such as for PuTTY:
+// "-telnet", "-P", "{port}", "{host}".)
+//
+// The Session's Conn is the one 'server' is serving. (ServeClient sets the ContextHandler of
+// 'server'; to one that hands the Conn over to the Session, and then calls the ContextHandler, or
+// Handler, that 'server' had, if any. Otherwise the Session reads the Conn.) If 'server' is nil,
+// then a (plain) telnet.Server is used.
+func ServeClient(ctx context.Context, server *telnet.Server, path string, args ...string) (*Session, error) {
+	if nil == server {
+		server = &telnet.Server{}
+	}
+
+	listener, err := Listen()
+	if nil != err {
+		return nil, err
+	}
+
+	var handler telnet.ContextHandler
+	switch {
+	case nil != server.ContextHandler:
+		handler = server.ContextHandler
+	case nil != server.Handler:
+		handler = telnet.AdaptHandler(server.Handler)
+	}
+
+	served := make(chan *telnet.Conn, 1)
+	server.ContextHandler = internalHandOver{served: served, handler: handler}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.Serve(listener)
+		close(stopped)
+	}()
+
+	session := &Session{}
+	session.closers = append(session.closers, func() error {
+		err := listener.Close()
+		<-stopped
+		return err
+	})
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if nil != err {
+		session.Close()
+		return nil, err
+	}
+
+	program, err := Start(path, clientArgs(args, host, port)...)
+	if nil != err {
+		session.Close()
+		return nil, err
+	}
+	session.Program = program
+	session.closers = append(session.closers, program.Close)
+
+	select {
+	case session.Conn = <-served:
+		if nil == handler {
+			session.startReading()
+		}
+	case <-program.Exited():
+		session.Close()
+		return nil, &internalExpectError{expected: "a connection", output: program.Output()}
+	case <-ctx.Done():
+		session.Close()
+		return nil, ctx.Err()
+	}
+
+	if session.Wire, err = listener.Next(ctx); nil != err {
+		session.Close()
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// clientArgs returns the arguments to run an external telnet client with; which are 'args', with
+// "{host}", and "{port}", in them replaced (with 'host', and 'port'). Or, if there are none of those
+// in them, then 'args' followed by 'host', and 'port'.
+func clientArgs(args []string, host string, port string) []string {
+	replacer := strings.NewReplacer("{host}", host, "{port}", port)
+
+	replaced := false
+	result := make([]string, len(args))
+	for k, arg := range args {
+		result[k] = replacer.Replace(arg)
+		replaced = replaced || result[k] != arg
+	}
+
+	if !replaced {
+		result = append(result, host, port)
+	}
+	return result
+}
+
+// internalHandOver is the ContextHandler that ServeClient has the telnet.Server use.
+type internalHandOver struct {
+	served  chan *telnet.Conn
+	handler telnet.ContextHandler
+}
+
+func (handOver internalHandOver) ServeTELNET(ctx context.Context, conn *telnet.Conn) {
+	select {
+	case handOver.served <- conn:
+	default:
+		// (Only the first connection is the Session's.)
+		return
+	}
+
+	if nil != handOver.handler {
+		handOver.handler.ServeTELNET(ctx, conn)
+		return
+	}
+	<-conn.Done()
+}
+
+// DialServer runs the external telnet server 'path' (such as telnetd, with the arguments 'args';
+// which have to have it listen on 'addr') on a pseudo-terminal; and dials it (at 'addr') with
+// 'dialer', through a Relay (so that what goes over the wire is recorded). It returns once it has
+// connected; retrying until the server is listening (or 'ctx' is done, or the server exited). For
+// example:
+//
+//	busybox := conformance.Require(t, "busybox")
+//
+//	session, err := conformance.DialServer(ctx, nil, "127.0.0.1:2323", busybox, "telnetd", "-F", "-p", "2323", "-l", "/bin/cat")
+//
+// If 'dialer' is nil, then a (plain) telnet.Dialer is used.
+func DialServer(ctx context.Context, dialer *telnet.Dialer, addr string, path string, args ...string) (*Session, error) {
+	if nil == dialer {
+		dialer = &telnet.Dialer{}
+	}
+
+	program, err := Start(path, args...)
+	if nil != err {
+		return nil, err
+	}
+	session := &Session{Program: program}
+	session.closers = append(session.closers, program.Close)
+
+	// (Wait for the server to be listening; so that the Relay does not find it is not.)
+	for {
+		probe, err := net.DialTimeout("tcp", addr, time.Second)
+		if nil == err {
+			probe.Close()
+			break
+		}
+
+		select {
+		case <-time.After(dialRetryInterval):
+		case <-program.Exited():
+			session.Close()
+			return nil, &internalExpectError{expected: "to listen on " + addr, output: program.Output()}
+		case <-ctx.Done():
+			session.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	relay, err := NewRelay(addr)
+	if nil != err {
+		session.Close()
+		return nil, err
+	}
+	session.closers = append(session.closers, relay.Close)
+
+	if session.Conn, err = dialer.DialToContext(ctx, relay.Addr); nil != err {
+		session.Close()
+		return nil, err
+	}
+	session.startReading()
+	if session.Wire, err = relay.Next(ctx); nil != err {
+		session.Close()
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -0,0 +1,57 @@
+package conformance
+
+import (
+	"io"
+	"strings"
+
+	"testing"
+)
+
+func TestClientArgs(t *testing.T) {
+
+	tests := []struct {
+		Args     []string
+		Expected string
+	}{
+		{
+			Args:     nil,
+			Expected: "127.0.0.1 2323",
+		},
+		{
+			Args:     []string{"-8"},
+			Expected: "-8 127.0.0.1 2323",
+		},
+		{
+			Args:     []string{"-telnet", "-P", "{port}", "{host}"},
+			Expected: "-telnet -P 2323 127.0.0.1",
+		},
+		{
+			Args:     []string{"{host}:{port}"},
+			Expected: "127.0.0.1:2323",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, strings.Join(clientArgs(test.Args, "127.0.0.1", "2323"), " "); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+
+	var closed []string
+	session := &Session{
+		closers: []func() error{
+			func() error { closed = append(closed, "first"); return nil },
+			func() error { closed = append(closed, "second"); return io.ErrClosedPipe },
+		},
+	}
+
+	if expected, actual := io.ErrClosedPipe, session.Close(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+	if expected, actual := "second first", strings.Join(closed, " "); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,289 @@
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// wireQueueLength is how many of the connections that a Listener (or a Relay) recorded are kept
+// for Next; after which the others are still recorded, but not kept.
+const wireQueueLength = 16
+
+// Wire is a (TCP) connection that records what goes over it; in each direction, from our side's
+// point of view. What the peer (such as an external telnet client) sent is what was read from it;
+// and what was sent to the peer is what was written to it. (See Listener, and Relay.)
+//
+// This is for asserting what went over the wire; such as that a NAWS subnegotiation had its bytes
+// in the right order, or that the 255s in some data were escaped. For example:
+//
+//	err := wire.WaitFromPeer(ctx, conformance.Subnegotiation(telnet.OptNAWS, []byte{0, 100, 0, 40}))
+type Wire struct {
+	net.Conn
+
+	mutex    sync.Mutex
+	fromPeer []byte
+	toPeer   []byte
+
+	// changed is closed (and replaced) whenever more is recorded.
+	changed chan struct{}
+}
+
+func newWire(conn net.Conn) *Wire {
+	return &Wire{Conn: conn, changed: make(chan struct{})}
+}
+
+// Read reads (what the peer sent) from the connection; and records it.
+func (wire *Wire) Read(p []byte) (int, error) {
+	n, err := wire.Conn.Read(p)
+	wire.record(&wire.fromPeer, p[:n])
+	return n, err
+}
+
+// Write writes (what is sent to the peer) to the connection; and records it.
+func (wire *Wire) Write(p []byte) (int, error) {
+	n, err := wire.Conn.Write(p)
+	wire.record(&wire.toPeer, p[:n])
+	return n, err
+}
+
+func (wire *Wire) record(recorded *[]byte, p []byte) {
+	if len(p) <= 0 {
+		return
+	}
+
+	wire.mutex.Lock()
+	*recorded = append(*recorded, p...)
+	close(wire.changed)
+	wire.changed = make(chan struct{})
+	wire.mutex.Unlock()
+}
+
+// FromPeer returns (a copy of) what the peer has sent so far; as it went over the wire.
+func (wire *Wire) FromPeer() []byte {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+
+	return append([]byte(nil), wire.fromPeer...)
+}
+
+// ToPeer returns (a copy of) what has been sent to the peer so far; as it went over the wire.
+func (wire *Wire) ToPeer() []byte {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+
+	return append([]byte(nil), wire.toPeer...)
+}
+
+// WaitFromPeer waits for the peer to have sent (any) one of 'expected'; i.e., for what FromPeer
+// returns to have it in it. If 'ctx' is done first, then it returns an error (that Is the error
+// of 'ctx') that says what the peer did send.
+func (wire *Wire) WaitFromPeer(ctx context.Context, expected ...[]byte) error {
+	return wire.wait(ctx, "from", &wire.fromPeer, expected)
+}
+
+// WaitToPeer waits for (any) one of 'expected' to have been sent to the peer; i.e., for what ToPeer
+// returns to have it in it. (It is otherwise like WaitFromPeer.)
+func (wire *Wire) WaitToPeer(ctx context.Context, expected ...[]byte) error {
+	return wire.wait(ctx, "to", &wire.toPeer, expected)
+}
+
+func (wire *Wire) wait(ctx context.Context, direction string, recorded *[]byte, expected [][]byte) error {
+	for {
+		wire.mutex.Lock()
+		for _, p := range expected {
+			if bytes.Contains(*recorded, p) {
+				wire.mutex.Unlock()
+				return nil
+			}
+		}
+		actual := append([]byte(nil), (*recorded)...)
+		changed := wire.changed
+		wire.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return &internalWireError{direction: direction, expected: expected, actual: actual, err: ctx.Err()}
+		}
+	}
+}
+
+type internalWireError struct {
+	direction string
+	expected  [][]byte
+	actual    []byte
+	err       error
+}
+
+func (err *internalWireError) Error() string {
+	return fmt.Sprintf("conformance: expected %v to be sent %s the peer, but only %v was: %v", err.expected, err.direction, err.actual, err.err)
+}
+
+func (err *internalWireError) Unwrap() error {
+	return err.err
+}
+
+// Listener is a net.Listener that records each connection it accepts (as a Wire); for serving
+// (such as with a telnet.Server) to an external telnet client. (See Listen.)
+type Listener struct {
+	net.Listener
+
+	wires chan *Wire
+}
+
+// Listen listens on a (random) port on the loopback address; and records each connection it
+// accepts. (Close it when done with it.)
+func Listen() (*Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		return nil, err
+	}
+
+	return Record(listener), nil
+}
+
+// Record returns a Listener that records each connection that 'listener' accepts.
+func Record(listener net.Listener) *Listener {
+	return &Listener{
+		Listener: listener,
+		wires:    make(chan *Wire, wireQueueLength),
+	}
+}
+
+// Accept accepts a connection; which it returns as a *Wire.
+func (listener *Listener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if nil != err {
+		return nil, err
+	}
+
+	wire := newWire(conn)
+	keep(listener.wires, wire)
+	return wire, nil
+}
+
+// Next returns the next connection that was accepted; waiting for it, if need be (or until 'ctx'
+// is done).
+func (listener *Listener) Next(ctx context.Context) (*Wire, error) {
+	return next(ctx, listener.wires)
+}
+
+// Relay listens on a (random) port on the loopback address; and forwards each connection it
+// accepts to the external telnet server (such as telnetd) at Target, recording it (as a Wire).
+// This is for dialing (such as with a telnet.Dialer) the external server, through it; with the
+// Wire being from the point of view of whatever dialed. (See NewRelay.)
+type Relay struct {
+	Addr   string
+	Target string
+
+	listener net.Listener
+	wires    chan *Wire
+}
+
+// NewRelay returns a Relay (that is running) to 'target'. (Close it when done with it.)
+func NewRelay(target string) (*Relay, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		return nil, err
+	}
+
+	relay := &Relay{
+		Addr:     listener.Addr().String(),
+		Target:   target,
+		listener: listener,
+		wires:    make(chan *Wire, wireQueueLength),
+	}
+	go relay.serve()
+
+	return relay, nil
+}
+
+func (relay *Relay) serve() {
+	for {
+		conn, err := relay.listener.Accept()
+		if nil != err {
+			return
+		}
+
+		go relay.forward(conn)
+	}
+}
+
+// forward forwards 'conn' to the Target; with the Wire being the connection to the Target (so that
+// what the Target sent is what was read from it).
+func (relay *Relay) forward(conn net.Conn) {
+	defer conn.Close()
+
+	target, err := net.Dial("tcp", relay.Target)
+	if nil != err {
+		return
+	}
+	wire := newWire(target)
+	defer wire.Close()
+	keep(relay.wires, wire)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(wire, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, wire)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Next returns the next connection that was forwarded; waiting for it, if need be (or until 'ctx'
+// is done).
+func (relay *Relay) Next(ctx context.Context) (*Wire, error) {
+	return next(ctx, relay.wires)
+}
+
+// Close stops the Relay from accepting (and so forwarding) any more connections.
+func (relay *Relay) Close() error {
+	return relay.listener.Close()
+}
+
+// keep keeps 'wire' for Next; unless there are (already) too many kept.
+func keep(wires chan *Wire, wire *Wire) {
+	select {
+	case wires <- wire:
+	default:
+	}
+}
+
+func next(ctx context.Context, wires chan *Wire) (*Wire, error) {
+	select {
+	case wire := <-wires:
+		return wire, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Negotiation returns the option negotiation (such as IAC DO NAWS) for the verb 'verb' (WILL, WONT,
+// DO, or DONT), and the option 'option'; as it goes over the wire.
+func Negotiation(verb byte, option byte) []byte {
+	return []byte{telnet.IAC, verb, option}
+}
+
+// Subnegotiation returns the subnegotiation (IAC SB <option> <payload> IAC SE) of the option
+// 'option'; as it goes over the wire, i.e., with the IACs in 'payload' escaped.
+func Subnegotiation(option byte, payload []byte) []byte {
+	p := append([]byte{telnet.IAC, telnet.SB}, Escape([]byte{option})...)
+	p = append(p, Escape(payload)...)
+	return append(p, telnet.IAC, telnet.SE)
+}
+
+// Escape returns the data 'data' as it goes over the wire; i.e., with each IAC (byte 255) in it
+// escaped (by doubling it).
+func Escape(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{telnet.IAC}, []byte{telnet.IAC, telnet.IAC})
+}
@@ -0,0 +1,186 @@
+package conformance
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+	"github.com/wouteroostervld/go-telnet/telnettest"
+
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+
+	tests := []struct {
+		Actual   []byte
+		Expected []byte
+	}{
+		{
+			Actual:   Negotiation(telnet.DO, telnet.OptNAWS),
+			Expected: []byte{telnet.IAC, telnet.DO, telnet.OptNAWS},
+		},
+		{
+			Actual:   Subnegotiation(telnet.OptNAWS, []byte{0, 100, 0, 40}),
+			Expected: []byte{telnet.IAC, telnet.SB, telnet.OptNAWS, 0, 100, 0, 40, telnet.IAC, telnet.SE},
+		},
+		{
+			Actual:   Subnegotiation(telnet.OptNAWS, []byte{0, 255, 1, 0}),
+			Expected: []byte{telnet.IAC, telnet.SB, telnet.OptNAWS, 0, 255, 255, 1, 0, telnet.IAC, telnet.SE},
+		},
+		{
+			Actual:   Escape([]byte("apple")),
+			Expected: []byte("apple"),
+		},
+		{
+			Actual:   Escape([]byte{'a', 255, 'b', 255, 255}),
+			Expected: []byte{'a', 255, 255, 'b', 255, 255, 255, 255},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, test.Actual; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+
+	listener, err := Listen()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if nil == err {
+			conn.Write([]byte("banana"))
+			go io.Copy(io.Discard, conn)
+		}
+		accepted <- err
+	}()
+
+	conn, err := telnet.DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+	go io.Copy(io.Discard, conn)
+
+	if err := <-accepted; nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wire, err := listener.Next(ctx)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, err := conn.Write([]byte{'a', 255, 'b'}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if err := wire.WaitFromPeer(ctx, []byte{'a', 255, 255, 'b'}); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := wire.WaitToPeer(ctx, []byte("banana")); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "banana", string(wire.ToPeer()); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestRelay(t *testing.T) {
+
+	server := telnettest.NewServer(telnet.EchoHandler)
+	defer server.Close()
+
+	relay, err := NewRelay(server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer relay.Close()
+
+	conn, err := telnet.DialTo(relay.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+	go io.Copy(io.Discard, conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := conn.Write([]byte("cherry")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	wire, err := relay.Next(ctx)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (What was sent to the (echo) server is what it sent back.)
+	if err := wire.WaitToPeer(ctx, []byte("cherry")); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := wire.WaitFromPeer(ctx, []byte("cherry")); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
+
+func TestWireWaitTimeout(t *testing.T) {
+
+	listener, err := Listen()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		if conn, err := listener.Accept(); nil == err {
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	conn, err := telnet.DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wire, err := listener.Next(ctx)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	conn.Write([]byte("date"))
+	if err := wire.WaitFromPeer(ctx, []byte("date")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+
+	err = wire.WaitFromPeer(shortCtx, []byte("elderberry"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the error to be context.DeadlineExceeded, but actually got: (%T) %v", err, err)
+	}
+	if expected, actual := "but only [100 97 116 101] was", err.Error(); !strings.Contains(actual, expected) {
+		t.Errorf("Expected the error to have %q in it, but actually got %q.", expected, actual)
+	}
+}
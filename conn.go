@@ -1,20 +1,284 @@
 package telnet
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// A Conn is a TELNET (or TELNETS) connection; to a server (see Dial), or from a client (see
+// Server).
+//
+// A Conn is safe for concurrent use. Any number of goroutines can write to it at once (with Write,
+// SendLine, SendCommand, SendSubnegotiation, and so on); while one goroutine reads from it. (A
+// handler can, for example, have a goroutine of its own sending GMCP pings, while it writes to
+// the client.) Each Write is sent whole, and in order; the data of one Write is never mixed in with
+// another's. A command (or subnegotiation) is only ever sent in between the bytes of the data; never
+// inside of an escaped IAC, or of another command. (Commands can go out in the middle of a large
+// Write; see SendCommand.)
+//
+// A Write is only sent whole on its own; ReadFrom (and so io.Copy) is a Write for each chunk it
+// reads. And something written in more than one call (such as WritePrompt, a prompt and then a GA)
+// can have another goroutine's Write go out in between them.
+//
+// A Conn is a net.Conn (and so an io.ReadWriteCloser). What is read from it is the data the peer
+// sent (unescaped; with the TELNET commands in it handled, and taken out), and what is written to
+// it is escaped; so code written against a net.Conn (such as an io.Copy pipeline, a bufio.Scanner,
+// or a gateway relaying an SSH channel) can use a Conn as it is. For example:
+//
+//	go io.Copy(conn, channel)
+//	io.Copy(channel, conn)
+//
+// Its deadlines (see SetDeadline) are as with a net.Conn; a Read (or Write) that is past them
+// returns a timeout error (for which errors.Is(err, os.ErrDeadlineExceeded) is true, as it is for
+// that of a TCP, or TLS, connection), and the Conn can still be used after. Once it is closed,
+// they return ErrClosed (i.e., net.ErrClosed). (See also NewConn; for TELNET over any
+// io.ReadWriteCloser.)
 type Conn struct {
-	conn interface {
-		Read(b []byte) (n int, err error)
-		Write(b []byte) (n int, err error)
-		Close() error
-		LocalAddr() net.Addr
-		RemoteAddr() net.Addr
-	}
+	conn       internalConn
 	dataReader *internalDataReader
 	dataWriter *internalDataWriter
+	negotiator *internalNegotiator
+
+	closeMutex   sync.Mutex
+	closeReason  CloseReason
+	closeMessage string
+	closeErr     error
+	closed       bool
+	done         chan struct{} // Closed once the connection is closed.
+
+	// tornDown is closed once the connection has been closed, and every goroutine it started
+	// (see spawn) has exited. (See Done.)
+	tornDown         chan struct{}
+	goroutineMutex   sync.Mutex
+	goroutines       int
+	goroutinesExited chan struct{} // (If not nil) closed once 'goroutines' is zero.
+
+	// peerErr is why reading from the connection failed (such as io.EOF, or ErrConnectionReset);
+	// before the Conn was closed. (It is guarded by the closeMutex.)
+	peerErr error
+
+	// peerClosed is closed once reading from the connection fails; such as because the peer
+	// closed it. (See CloseGracefully.)
+	peerClosed chan struct{}
+
+	// readMutex is held while reading; so that CloseGracefully can tell whether something else
+	// is reading.
+	readMutex sync.Mutex
+
+	// readDeadline is what SetReadDeadline set; so that what sets the read deadline (of the
+	// underlying connection) for a while (such as ReadContext) can set it back.
+	deadlineMutex sync.Mutex
+	readDeadline  time.Time
+
+	// peeked is the data that Peek has read, but that has not been Read yet.
+	peeked []byte
+
+	// textPending is the (UTF-8 encoded) end of a rune, that Text read, but that did not fit into
+	// what it was reading into.
+	textPending []byte
+
+	textMutex             sync.Mutex
+	invalidUTF8           InvalidUTF8Policy
+	fallbackCharset       Charset
+	fallbackTranscoding   bool
+	charsetNegotiated     bool
+	negotiatedCharset     Charset
+	negotiatedCharsetName string
+
+	// transcoded is the data that was transcoded (to UTF-8; see SetFallbackTranscoding), but that
+	// has not been read yet. And untranscoded is the start of a (UTF-8 encoded) rune that was
+	// written, but not yet transcoded (as the rest of it has not been written yet).
+	transcoded     []byte
+	transcodeMutex sync.Mutex
+	untranscoded   []byte
+
+	// readFilters are what the data read is passed through (in order), after it is transcoded;
+	// and filtered is what they returned, that has not been read yet. (See AddReadFilter.)
+	readFilterMutex sync.Mutex
+	readFilters     []*internalReadFilter
+	filtered        []byte
+
+	// writeFilters are what the data written is passed through (in order), before it is
+	// transcoded, and escaped. (They are guarded by the transcodeMutex; as it is held while
+	// writing. See AddWriteFilter.)
+	writeFilters []*internalWriteFilter
+
+	// newlinePolicy is what SetNewlinePolicy set (as a NewlinePolicy). And writtenCR is whether
+	// the last byte written was a CR (guarded by the transcodeMutex); and readCR whether the last
+	// byte read was (guarded by the readMutex). (See NewlineNVT.)
+	newlinePolicy atomic.Int32
+	writtenCR     bool
+	readCR        bool
+
+	// user is the username the client logged in with. (See User.)
+	userMutex sync.Mutex
+	user      string
+
+	goodbyeMutex sync.Mutex
+	goodbye      string
+
+	// promptData is what ReadUntilPrompt read, before its context was done; for the next call of it.
+	// (And onPrompt is what OnPrompt registered.)
+	promptMutex   sync.Mutex
+	promptData    []byte
+	promptIdleGap time.Duration
+	onPrompt      func()
+
+	// commandHandler is what OnCommand registered; and unhandledHandler what OnUnhandledCommand
+	// registered.
+	commandMutex     sync.RWMutex
+	commandHandler   func(cmd byte)
+	unhandledHandler func(event CommandEvent)
+
+	// relay (if not nil) is the Conn that the commands received are passed on to; as well as the
+	// negotiations (and subnegotiations) of the options 'relayed' returns true for, instead of
+	// them being answered. (See ProxyHandler.)
+	relayMutex sync.RWMutex
+	relay      *Conn
+	relayed    func(option byte) bool
+
+	inputLimiter *internalInputLimiter
+
+	// inputThrottle holds off reading from the peer, once it sends faster than its input rate
+	// limit allows. (See SetInputRateLimit.)
+	inputThrottle *internalInputThrottle
+
+	// received is when something was last received from the peer (as UnixNano); and
+	// keepaliveStop (if not nil) stops what SetKeepalive started. (deadPeer is why the Conn was
+	// closed, if it was closed with CloseDeadPeer.)
+	received       *atomic.Int64
+	keepaliveMutex sync.Mutex
+	keepaliveStop  chan struct{}
+	deadPeer       *DeadPeerError
+
+	// connected is when the Conn was made; and sessionStop (if not nil) stops what
+	// SetSessionTimeouts started.
+	connected    time.Time
+	sessionMutex sync.Mutex
+	sessionStop  chan struct{}
+
+	// tap (if set) is what what is sent, and received, is mirrored to. (See SetTap.) And traffic
+	// is what tells the Metrics (if it is a TrafficMetrics) of it.
+	tap     *atomic.Pointer[Tap]
+	traffic *internalTraffic
+
+	// deflater is what compresses what is sent, once it is started (see OfferCompression); and
+	// inflating is whether what is read is being decompressed (see AcceptCompression).
+	deflater  *internalDeflater
+	inflating atomic.Bool
+
+	// windowSize (if not nil) is the NAWS OptionHandler that SetWindowSize registered; and
+	// peerWindowSize (if not nil) is the one that RequestWindowSize registered. (onWindowSize is
+	// what OnWindowSize registered.)
+	windowSizeMutex sync.Mutex
+	windowSize      *internalWindowSize
+	peerWindowSize  *internalPeerWindowSize
+	onWindowSize    func(width int, height int)
+
+	// peerTerminalType (if not nil) is the TERMINAL-TYPE OptionHandler that RequestTerminalType
+	// registered; and onTerminalTypes is what OnTerminalTypes registered.
+	terminalTypeMutex sync.Mutex
+	peerTerminalType  *internalPeerTerminalType
+	onTerminalTypes   func(names []string)
+
+	// peerEnviron (if not nil) is the NEW-ENVIRON OptionHandler that RequestEnvironment registered;
+	// and onEnvironment is what OnEnvironment registered.
+	environMutex  sync.Mutex
+	peerEnviron   *internalPeerEnviron
+	onEnvironment func(variables map[string]string)
+
+	// linemode (if not nil) is the LINEMODE OptionHandler that RequestLinemode registered.
+	linemodeMutex sync.Mutex
+	linemode      *internalLinemode
+
+	// onGMCP, and onMSDP, are what OnGMCP, and OnMSDP, registered.
+	mudMutex sync.RWMutex
+	onGMCP   func(name string, data []byte)
+	onMSDP   func(name string, value interface{})
+
+	// zmpHandlers are what OnZMP registered; by the (ZMP) command they are for.
+	zmpMutex    sync.RWMutex
+	zmpHandlers map[string]func(args []string)
+
+	// onTornDown (if not nil) is called once the connection has been torn down (just before Done
+	// is closed); with why it was closed. (See Trace.Closed, and Metrics.)
+	onTornDown func(reason CloseReason, msg string)
+
+	// onViolation (if not nil) is told when the client exceeds one of its InputLimits. (See Server.InputViolations.)
+	onViolation func(reason CloseReason)
+
+	logger Logger
+}
+
+type internalConn interface {
+	Read(b []byte) (n int, err error)
+	Write(b []byte) (n int, err error)
+	Close() error
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// newConn wraps 'conn' so that it speaks the TELNET protocol.
+func newConn(conn internalConn, logger Logger) *Conn {
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	tap := &atomic.Pointer[Tap]{}
+	traffic := &internalTraffic{}
+
+	writeTimeout := &internalWriteTimeout{conn: conn}
+	deflater := &internalDeflater{writer: internalTapWriter{writer: internalMeteredWriter{writer: writeTimeout, traffic: traffic}, tap: tap}}
+	dataWriter := newDataWriter(deflater)
+	dataWriter.timeout = writeTimeout
+	dataWriter.traffic = traffic
+	writeTimeout.limiter = dataWriter.limiter
+	inputLimiter := &internalInputLimiter{}
+	inputThrottle := &internalInputThrottle{limiter: newRateLimiter()}
+	peerClosed := make(chan struct{})
+
+	connected := time.Now()
+	received := &atomic.Int64{}
+	received.Store(connected.UnixNano())
+
+	reader := internalMeteredReader{
+		reader:   &internalEOFReader{reader: internalTapReader{reader: conn, tap: tap}, eof: peerClosed},
+		limiter:  inputLimiter,
+		throttle: inputThrottle,
+		received: received,
+		traffic:  traffic,
+	}
+
+	telnetConn := Conn{
+		conn:          conn,
+		dataReader:    newDataReader(reader),
+		dataWriter:    dataWriter,
+		done:          make(chan struct{}),
+		tornDown:      make(chan struct{}),
+		peerClosed:    peerClosed,
+		inputLimiter:  inputLimiter,
+		inputThrottle: inputThrottle,
+		received:      received,
+		connected:     connected,
+		tap:           tap,
+		traffic:       traffic,
+		deflater:      deflater,
+		logger:        logger,
+	}
+	telnetConn.negotiator = newNegotiator(telnetConn.writeCommand, logger)
+	telnetConn.dataReader.handler = &telnetConn
+	telnetConn.dataReader.limiter = inputLimiter
+	telnetConn.dataReader.traffic = traffic
+	traffic.conn = &telnetConn
+	inputLimiter.violated = telnetConn.violated
+	writeTimeout.done = telnetConn.done
+	writeTimeout.timedOut = telnetConn.writeTimedOut
+
+	return &telnetConn
 }
 
 // Dial makes a (un-secure) TELNET client connection to the system's 'loopback address'
@@ -29,29 +293,31 @@ func Dial() (*Conn, error) {
 // 'addr'.
 //
 // If a secure connection is desired, use `DialToTLS` instead.
+//
+// (It is a Dialer, from NewDialer; see NewDialer for more options.)
 func DialTo(addr string) (*Conn, error) {
+	return NewDialer().DialTo(addr)
+}
 
-	const network = "tcp"
-
-	if addr == "" {
-		addr = "127.0.0.1:telnet"
-	}
-
-	conn, err := net.Dial(network, addr)
-	if nil != err {
-		return nil, err
-	}
-
-	dataReader := newDataReader(conn)
-	dataWriter := newDataWriter(conn)
-
-	clientConn := Conn{
-		conn:       conn,
-		dataReader: dataReader,
-		dataWriter: dataWriter,
-	}
-
-	return &clientConn, nil
+// DialContext makes a (un-secure) TELNET client connection to the address specified by 'addr',
+// on the network 'network' (such as "tcp", "tcp4", or "tcp6"); and gives up once 'ctx' is done.
+// This is so that a server that does not answer does not block the caller forever. For example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//
+//	conn, err := telnet.DialContext(ctx, "tcp", "example.net:23")
+//	if nil != err {
+//		//@TODO: Handle error.
+//		return err
+//	}
+//	defer conn.Close()
+//
+// ('ctx' is only for dialing; see ReadContext, WriteContext, and SetDeadline, for after.)
+//
+// (It is a Dialer, from NewDialer; see Dialer.DialToContext for more options.)
+func DialContext(ctx context.Context, network string, addr string) (*Conn, error) {
+	return NewDialer().dialTo(ctx, network, addr)
 }
 
 // DialTLS makes a (secure) TELNETS client connection to the system's 'loopback address'
@@ -62,29 +328,20 @@ func DialTLS(tlsConfig *tls.Config) (*Conn, error) {
 
 // DialToTLS makes a (secure) TELNETS client connection to the the address specified by
 // 'addr'.
+//
+// (It is a Dialer, from NewDialer, with WithTLSConfig; see NewDialer for more options.)
 func DialToTLS(addr string, tlsConfig *tls.Config) (*Conn, error) {
+	return NewDialer(withTLS(tlsConfig)).DialTo(addr)
+}
 
-	const network = "tcp"
-
-	if addr == "" {
-		addr = "127.0.0.1:telnets"
-	}
-
-	conn, err := tls.Dial(network, addr, tlsConfig)
-	if nil != err {
-		return nil, err
-	}
-
-	dataReader := newDataReader(conn)
-	dataWriter := newDataWriter(conn)
-
-	clientConn := Conn{
-		conn:       conn,
-		dataReader: dataReader,
-		dataWriter: dataWriter,
+// withTLS is WithTLSConfig; except that a nil 'tlsConfig' is the default TLS configuration
+// (rather than no TLS).
+func withTLS(tlsConfig *tls.Config) Option {
+	if nil == tlsConfig {
+		tlsConfig = &tls.Config{}
 	}
 
-	return &clientConn, nil
+	return WithTLSConfig(tlsConfig)
 }
 
 // Close closes the client connection.
@@ -98,7 +355,65 @@ func DialToTLS(addr string, tlsConfig *tls.Config) (*Conn, error) {
 //	}
 //	defer telnetsClient.Close()
 func (clientConn *Conn) Close() error {
-	return clientConn.conn.Close()
+	return clientConn.CloseWithReason(CloseUnknown, "")
+}
+
+// CloseWithReason closes the connection (like Close does), and records why it was closed;
+// which can then be gotten with CloseReason.
+//
+// Only the first reason is recorded. I.e., if the connection has already been closed, then
+// the connection's CloseReason does not change. (And what closing it the first time returned
+// is returned again.)
+//
+// If 'reason' is CloseUnknown (such as when it is closed with Close), but reading from the
+// connection had already failed, then the reason is what that failure says; such as
+// ClosePeerClosed (for io.EOF), CloseConnectionReset (for ErrConnectionReset), or
+// CloseProtocolError (for ErrTruncatedCommand).
+//
+// Whatever is still buffered (i.e., written, but not yet sent) is sent before the connection
+// is closed; but without waiting for a Write that is in progress. See CloseGracefully for that.
+func (clientConn *Conn) CloseWithReason(reason CloseReason, msg string) error {
+	clientConn.closeMutex.Lock()
+	defer clientConn.closeMutex.Unlock()
+
+	if clientConn.closed {
+		return clientConn.closeErr
+	}
+
+	if CloseUnknown == reason && "" == msg && nil != clientConn.peerErr {
+		reason, msg = peerCloseReason(clientConn.peerErr)
+	}
+
+	clientConn.closed = true
+	clientConn.closeReason = reason
+	clientConn.closeMessage = msg
+	close(clientConn.done)
+	clientConn.logger.Debugf("Closing connection; because: %v (%s).", reason, msg)
+	clientConn.dataWriter.limiter.close()
+	clientConn.inputThrottle.limiter.close()
+	clientConn.negotiator.abandon(ErrClosed)
+
+	// (Do not wait long, if the peer is not reading.)
+	if deadliner, ok := clientConn.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		deadliner.SetWriteDeadline(time.Now().Add(closeFlushTimeout))
+	}
+	if err := clientConn.dataWriter.flushBuffered(); nil != err {
+		clientConn.logger.Debugf("Problem flushing, while closing: %v", err)
+	}
+
+	clientConn.closeErr = clientConn.conn.Close()
+
+	go clientConn.tearDown()
+
+	return clientConn.closeErr
+}
+
+// CloseReason returns why the connection was closed; as given to CloseWithReason.
+func (clientConn *Conn) CloseReason() (CloseReason, string) {
+	clientConn.closeMutex.Lock()
+	defer clientConn.closeMutex.Unlock()
+
+	return clientConn.closeReason, clientConn.closeMessage
 }
 
 // Read receives `n` bytes sent from the server to the client,
@@ -112,7 +427,17 @@ func (clientConn *Conn) Close() error {
 //
 // Read makes Client fit the io.Reader interface.
 func (clientConn *Conn) Read(p []byte) (n int, err error) {
-	return clientConn.dataReader.Read(p)
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	if clientConn.isClosed() {
+		return 0, clientConn.closedReadErr()
+	}
+	if 0 < len(clientConn.peeked) {
+		return clientConn.readPeeked(p), nil
+	}
+
+	return clientConn.readData(p)
 }
 
 // Write sends `n` bytes from 'p' to the server.
@@ -122,9 +447,21 @@ func (clientConn *Conn) Read(p []byte) (n int, err error) {
 // TELNET (and TELNETS) command codes cannot be sent using this method, as Write deals with
 // TELNET (and TELNETS) "escaping", and will properly "escape" anything written with it.
 //
+// If Write returns an error, then `n` is how many bytes of 'p' were taken (i.e., sent, or still
+// buffered, to be sent by the next Flush); so only p[n:] is to be written again. (See Unflushed.)
+//
+// Write can be called by more than one goroutine at once; the data of each Write is sent whole,
+// and not mixed in with that of the others. (See Conn.)
+//
 // Write makes Conn fit the io.Writer interface.
 func (clientConn *Conn) Write(p []byte) (n int, err error) {
-	return clientConn.dataWriter.Write(p)
+	return clientConn.writeData(p)
+}
+
+// Logger returns the logger of the connection; for a Server's connections, that is the Logger
+// of the Server. (It is never nil.)
+func (clientConn *Conn) Logger() Logger {
+	return clientConn.logger
 }
 
 // LocalAddr returns the local network address.
@@ -136,3 +473,342 @@ func (clientConn *Conn) LocalAddr() net.Addr {
 func (clientConn *Conn) RemoteAddr() net.Addr {
 	return clientConn.conn.RemoteAddr()
 }
+
+// SendCommand sends the TELNET command 'cmd' to the peer, (correctly) prefixed by IAC.
+//
+// For example:
+//
+//	err := conn.SendCommand(telnet.AYT)
+//
+// ... would send the bytes IAC AYT (i.e., []byte{255, 246}) to the peer.
+//
+// Unlike Write, SendCommand does NOT escape what it sends.
+//
+// It is sent after whatever data was written before it; but ahead of whatever data is still
+// waiting to be sent (such as the rest of a large Write, to a slow peer, or one that is waiting on
+// the rate limit, see SetOutputRateLimit). So that, for example, a keepalive, or the answer to an
+// option negotiation, is not stuck behind it. (The same goes for SendSubnegotiation, and for the
+// answers the Conn sends to the peer's option negotiations.)
+//
+// 'cmd' has to be a TELNET command on its own (such as AYT, NOP, or GA); otherwise SendCommand
+// returns ErrInvalidCommand. (See SendOption, and SendSubnegotiation, for the others.)
+func (clientConn *Conn) SendCommand(cmd byte) error {
+	switch cmd {
+	case SB, SE, WILL, WONT, DO, DONT, IAC:
+		return ErrInvalidCommand
+	}
+
+	return clientConn.writeCommand([]byte{IAC, cmd})
+}
+
+// SendBreak sends the TELNET BRK command (i.e., IAC BRK) to the peer.
+func (clientConn *Conn) SendBreak() error {
+	return clientConn.SendCommand(BRK)
+}
+
+// SendSubnegotiation sends the TELNET subnegotiation:
+//
+//	IAC SB <option> <payload> IAC SE
+//
+// ... to the peer, (correctly) escaping any IAC (i.e., byte 255) in 'payload' (by doubling it).
+func (clientConn *Conn) SendSubnegotiation(option byte, payload []byte) error {
+
+	p := make([]byte, 0, 5+len(payload)+len(payload)/8)
+
+	p = append(p, IAC, SB)
+	p = appendEscaped(p, option)
+	for _, b := range payload {
+		p = appendEscaped(p, b)
+	}
+	p = append(p, IAC, SE)
+
+	return clientConn.writeCommand(p)
+}
+
+// SendOption sends the TELNET option negotiation:
+//
+//	IAC <verb> <option>
+//
+// ... to the peer, as-is; where 'verb' is WILL, WONT, DO, or DONT (otherwise it returns
+// ErrInvalidVerb). For example:
+//
+//	err := conn.SendOption(telnet.WILL, telnet.OptEcho)
+//
+// This is for passing on a negotiation as-is (such as for a proxy, or a test harness); it does NOT
+// go through the option negotiation of the Conn, which does not know it was sent (and so might
+// answer the peer's answer to it). To negotiate an option, use RequestEnableRemote (and
+// RequestEnableLocal), or RegisterOption, instead.
+func (clientConn *Conn) SendOption(verb byte, option byte) error {
+	switch verb {
+	case WILL, WONT, DO, DONT:
+	default:
+		return ErrInvalidVerb
+	}
+
+	return clientConn.writeCommand([]byte{IAC, verb, option})
+}
+
+func appendEscaped(p []byte, b byte) []byte {
+	if IAC == b {
+		return append(p, IAC, IAC)
+	}
+
+	return append(p, b)
+}
+
+// RegisterOption registers 'handler' as the implementation of the TELNET option 'option',
+// replacing whatever was previously registered for 'option'.
+//
+// From then on, the Conn answers the peer's negotiation of 'option' according to the OptionSupport
+// returned by handler.Register, tells 'handler' when 'option' is enabled or disabled, and hands
+// 'handler' the subnegotiations the peer sends for 'option'.
+//
+// RegisterOption can be called at any time, including after the connection is already being used.
+// If the OptionSupport asks for it, a WILL and / or DO is sent right away.
+//
+// For example:
+//
+//	err := conn.RegisterOption(0xAA, &myFileTransferOption{})
+func (clientConn *Conn) RegisterOption(option byte, handler OptionHandler) error {
+
+	support := handler.Register(internalOptionSender{conn: clientConn, option: option})
+
+	clientConn.negotiator.register(option, handler, support)
+	clientConn.logger.Debugf("Registered option %s: %+v", OptionName(option), support)
+
+	if support.RequestLocal {
+		if err := clientConn.negotiator.request(option, true, true); nil != err {
+			return err
+		}
+	}
+	if support.RequestRemote {
+		if err := clientConn.negotiator.request(option, false, true); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetDefaultOptionPolicy sets how the Conn answers the peer when the peer asks for (i.e., sends
+// a DO for) or offers (i.e., sends a WILL for) an option that the Conn does not otherwise support.
+//
+// The default is OptionPolicyRefuse, which answers every DO with a WONT, and every WILL with a DONT.
+//
+// SetOptionPolicy can be used to override this for individual options.
+func (clientConn *Conn) SetDefaultOptionPolicy(policy OptionPolicy) {
+	clientConn.negotiator.setDefaultPolicy(policy)
+}
+
+// SetOptionPolicy sets how the Conn answers the peer when the peer asks for (i.e., sends
+// a DO for) or offers (i.e., sends a WILL for) the option 'option'.
+//
+// This overrides (for just 'option') whatever was set with SetDefaultOptionPolicy.
+func (clientConn *Conn) SetOptionPolicy(option byte, policy OptionPolicy) {
+	clientConn.negotiator.setPolicy(option, policy)
+}
+
+// OptionEnabled returns whether the TELNET option 'option' is (currently) enabled on our side
+// ('local') and on the peer's side ('remote').
+//
+// For example, to find out whether the client agreed to NAWS:
+//
+//	_, clientDoesNAWS := conn.OptionEnabled(telnet.OptNAWS)
+//
+// Or whether the server is echoing:
+//
+//	_, serverEchoes := conn.OptionEnabled(telnet.OptEcho)
+//
+// OptionEnabled is safe to call at any time, including while the option is being negotiated.
+func (clientConn *Conn) OptionEnabled(option byte) (local bool, remote bool) {
+	return clientConn.negotiator.enabled(option)
+}
+
+// OptionStates returns a (consistent) snapshot of the negotiated state of every TELNET option
+// that is enabled (or being negotiated) on either side.
+//
+// Options that are not in the returned map are disabled on both sides.
+//
+// The returned map is a copy, and can be kept (and modified) by the caller. Its String method
+// can be useful for logging. For example:
+//
+//	logger.Debugf("Options: %v", conn.OptionStates())
+func (clientConn *Conn) OptionStates() OptionStates {
+	return clientConn.negotiator.states()
+}
+
+// NotifyOptionChanges has an OptionChange sent to 'changes' every time a TELNET option becomes
+// enabled (or disabled) on either side; i.e., every time the negotiation of it settles on something
+// other than what it was. This replaces any channel previously registered. Registering nil stops
+// the sends.
+//
+// For example, to find out once the client agrees to the server not echoing:
+//
+//	changes := make(chan telnet.OptionChange, 16)
+//	conn.NotifyOptionChanges(changes)
+//
+//	for change := range changes {
+//		if telnet.OptEcho == change.Option && change.Local && !change.Enabled {
+//			//@TODO: Go back to echoing what is typed.
+//		}
+//	}
+//
+// (A refusal, or the peer agreeing to what was already so, is not a change; so 'changes' is not
+// sent anything for it. See OnNegotiationEvent for every command.)
+//
+// Like with signal.Notify, the sends do not block: if 'changes' is full, the OptionChange is
+// dropped. So 'changes' should be buffered (enough for the rate the changes are expected at). And
+// (since they are sent by whatever goroutine is reading from the Conn) an unbuffered 'changes' is
+// only sent anything if something is, at that moment, receiving from it.
+//
+// The changes are sent after the option negotiation state machine has been updated (so anything
+// queried from the Conn is consistent with them); and before any OptionHandler registered for the
+// option is told about the change.
+func (clientConn *Conn) NotifyOptionChanges(changes chan<- OptionChange) {
+	clientConn.negotiator.setChanges(changes)
+}
+
+// OnNegotiationEvent registers 'fn' to be called for every TELNET option negotiation command
+// (i.e., WILL, WONT, DO, and DONT) received from, or sent to, the peer. This replaces any
+// function previously registered. Registering nil stops the calls.
+//
+// This can be useful for debugging, or for (reactive) user interfaces.
+//
+// For example:
+//
+//	conn.OnNegotiationEvent(func(event telnet.NegotiationEvent) {
+//		log.Printf("%s %s %s -> %+v", event.Direction, telnet.CommandName(event.Verb), telnet.OptionName(event.Option), event.State)
+//	})
+//
+// Ordering guarantees:
+//
+// 'fn' is called after the option negotiation state machine has been updated, so event.State,
+// and anything 'fn' queries the Conn for, is consistent with the event.
+//
+// For a received command, the Inbound event comes before the Outbound event for our answer (if any).
+// And both come before any OptionHandler registered for the option is told about the change.
+//
+// Received commands are processed (and 'fn' is called) by whatever goroutine is reading from the Conn,
+// as part of Read. 'fn' is called before Read returns any data that came (over the wire) after the
+// command. (Data that came before the command might be returned from the same call to Read, after 'fn'
+// was called.)
+//
+// 'fn' blocks the Conn from reading until it returns; if that is a problem, have 'fn' hand the event off
+// to another goroutine (for example, using a buffered channel).
+func (clientConn *Conn) OnNegotiationEvent(fn func(NegotiationEvent)) {
+	clientConn.negotiator.setEventHandler(fn)
+}
+
+// OnCommand registers 'fn' to be called for every TELNET command, other than option negotiation
+// and subnegotiation, received from the peer. (Ex: IAC IP, IAC AYT, IAC BRK.) This replaces any
+// function previously registered. Registering nil stops the calls.
+//
+// For example:
+//
+//	conn.OnCommand(func(cmd byte) {
+//		if telnet.IP == cmd {
+//			//@TODO: Interrupt whatever is running.
+//		}
+//	})
+//
+// Like with OnNegotiationEvent, 'fn' is called by whatever goroutine is reading from the Conn, as
+// part of Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnCommand(fn func(cmd byte)) {
+	clientConn.commandMutex.Lock()
+	clientConn.commandHandler = fn
+	clientConn.commandMutex.Unlock()
+}
+
+func (clientConn *Conn) handleCommand(cmd byte) {
+	clientConn.logger.Tracef("Received %s.", CommandName(cmd))
+	clientConn.tapEvent(Inbound, cmd, 0, nil)
+	clientConn.traffic.command(cmd)
+
+	clientConn.commandMutex.RLock()
+	fn := clientConn.commandHandler
+	clientConn.commandMutex.RUnlock()
+
+	if nil != fn {
+		fn(cmd)
+	}
+
+	peer, _ := clientConn.relaying()
+	if nil != peer {
+		if err := peer.SendCommand(cmd); nil != err {
+			clientConn.logger.Errorf("Problem relaying %s: %v", CommandName(cmd), err)
+		}
+	}
+
+	prompted := clientConn.prompted(cmd)
+
+	if nil == fn && nil == peer && !prompted {
+		clientConn.unhandled(cmd, 0, nil)
+	}
+}
+
+func (clientConn *Conn) handleNegotiation(verb byte, option byte) {
+	clientConn.tapEvent(Inbound, verb, option, nil)
+	clientConn.traffic.command(verb)
+
+	if peer, relayed := clientConn.relaying(); nil != peer && relayed(option) {
+		clientConn.logger.Tracef("Relaying %s %s.", CommandName(verb), OptionName(option))
+		if err := peer.writeCommand([]byte{IAC, verb, option}); nil != err {
+			clientConn.logger.Errorf("Problem relaying %s %s: %v", CommandName(verb), OptionName(option), err)
+		}
+		return
+	}
+
+	if err := clientConn.negotiator.receive(verb, option); nil != err {
+		clientConn.logger.Errorf("Problem answering %s %s: %v", CommandName(verb), OptionName(option), err)
+	}
+
+	// (A WILL, or a DO, that was refused.)
+	if local, remote := clientConn.OptionEnabled(option); (WILL == verb && !remote) || (DO == verb && !local) {
+		clientConn.unhandled(verb, option, nil)
+	}
+}
+
+func (clientConn *Conn) handleSubnegotiation(option byte, payload []byte) {
+	clientConn.logger.Tracef("Received subnegotiation for %s (%d bytes).", OptionName(option), len(payload))
+	clientConn.tapEvent(Inbound, SB, option, payload)
+	clientConn.traffic.command(SB)
+
+	if peer, relayed := clientConn.relaying(); nil != peer && relayed(option) {
+		if err := peer.SendSubnegotiation(option, payload); nil != err {
+			clientConn.logger.Errorf("Problem relaying subnegotiation for %s: %v", OptionName(option), err)
+		}
+		return
+	}
+
+	if handler := clientConn.negotiator.handler(option); nil != handler {
+		handler.Subnegotiation(payload)
+		return
+	}
+
+	clientConn.unhandled(SB, option, payload)
+}
+
+// relayTo has the commands (other than option negotiations and subnegotiations) received from the
+// peer passed on, as-is, to 'peer'; as well as the negotiations, and subnegotiations, of the
+// options 'relayed' returns true for; which are then not answered (or handled) by this Conn.
+//
+// Read then also returns the data that came before a command before the command is passed on;
+// so that the data, and the commands, get to 'peer' in the order they came in. (relayTo must be
+// called before the Conn is read from.)
+//
+// If 'peer' is nil, then nothing is relayed anymore.
+func (clientConn *Conn) relayTo(peer *Conn, relayed func(option byte) bool) {
+	clientConn.dataReader.keepOrder = nil != peer
+
+	clientConn.relayMutex.Lock()
+	clientConn.relay = peer
+	clientConn.relayed = relayed
+	clientConn.relayMutex.Unlock()
+}
+
+func (clientConn *Conn) relaying() (*Conn, func(option byte) bool) {
+	clientConn.relayMutex.RLock()
+	defer clientConn.relayMutex.RUnlock()
+
+	return clientConn.relay, clientConn.relayed
+}
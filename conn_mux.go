@@ -0,0 +1,255 @@
+package telnet
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultConnMuxTimeout is how long a ConnMux waits for a connection's handshake, if it is not
+// told otherwise.
+const defaultConnMuxTimeout = 3 * time.Second
+
+// A ConnMatcher says whether a connection is one that a route of a ConnMux is for; going by what
+// is known of it once its handshake is done. (See ConnMux.Handle.)
+type ConnMatcher func(conn *Conn) bool
+
+// A ConnMux is a ContextHandler that routes each connection to one of a number of ContextHandlers;
+// going by what is known of it once its handshake (i.e., the negotiation of its options) is done.
+// Such as its terminal type, whether it is TELNETS, the network its address is in, or whether
+// GMCP was agreed to. So that one port can serve both a plain-text menu (for dumb clients) and a
+// rich UI (for capable ones). For example:
+//
+//	var mux telnet.ConnMux
+//	mux.RequestTerminalType = true
+//	mux.Handle(telnet.MatchOption(telnet.OptGMCP), richHandler)
+//	mux.Handle(telnet.MatchTerminalType("XTERM*", "ANSI", "VT1??"), ansiHandler)
+//	mux.HandleDefault(menuHandler)
+//
+//	server := telnet.NewServer(":4000",
+//		telnet.WithContextHandler(&mux),
+//		telnet.WithOptionHandler(telnet.OptGMCP, gmcpHandler),
+//	)
+//
+// The routes are tried in the order they were added; and the connection goes to the first one
+// that matches it (or else to the default, if there is one; or else it is closed).
+//
+// Before it routes the connection, the ConnMux waits on the negotiations that are in progress
+// (such as those of the Server's OptionHandlers); and, if RequestTerminalType is true, for the
+// peer to tell its terminal types. But for no longer than the Timeout. While it waits, it reads
+// from the connection (so that the peer's answers are received); although any data that comes in
+// is kept, for the handler to read. (See Conn.Peek.)
+//
+// The zero ConnMux is ready to use, with no routes. Routes can be added while it is serving.
+type ConnMux struct {
+	// RequestTerminalType is whether to ask each peer for its terminal type (see
+	// Conn.RequestTerminalType); for the routes that go by it, such as MatchTerminalType.
+	RequestTerminalType bool
+
+	// Timeout is how long to wait for the handshake, before routing the connection anyway; 3
+	// seconds, if it is zero.
+	Timeout time.Duration
+
+	mutex    sync.RWMutex
+	routes   []internalConnRoute
+	fallback ContextHandler
+}
+
+type internalConnRoute struct {
+	match   ConnMatcher
+	handler ContextHandler
+}
+
+// Handle adds a route; for the connections that 'match' matches to be served by 'handler'.
+func (mux *ConnMux) Handle(match ConnMatcher, handler ContextHandler) {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	mux.routes = append(mux.routes, internalConnRoute{match: match, handler: handler})
+}
+
+// HandleDefault sets the ContextHandler for the connections that none of the routes match.
+func (mux *ConnMux) HandleDefault(handler ContextHandler) {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	mux.fallback = handler
+}
+
+// Handler returns the ContextHandler that 'conn' is routed to, as things are now (i.e., without
+// waiting for its handshake); or nil, if none.
+func (mux *ConnMux) Handler(conn *Conn) ContextHandler {
+	mux.mutex.RLock()
+	defer mux.mutex.RUnlock()
+
+	for _, route := range mux.routes {
+		if nil != route.match && route.match(conn) {
+			return route.handler
+		}
+	}
+	return mux.fallback
+}
+
+// ServeTELNET waits for the handshake of 'conn'; and then has it served by the ContextHandler it
+// is routed to. (See ConnMux.)
+func (mux *ConnMux) ServeTELNET(ctx context.Context, conn *Conn) {
+	mux.handshake(ctx, conn)
+
+	handler := mux.Handler(conn)
+	if nil == handler {
+		conn.logger.Debugf("No route for the connection from %q.", conn.RemoteAddr())
+		return
+	}
+	handler.ServeTELNET(ctx, conn)
+}
+
+// handshake waits for the negotiations of 'conn' (and, if RequestTerminalType is true, for its
+// terminal types) to be done; or for the Timeout, or for 'ctx' to be done.
+func (mux *ConnMux) handshake(ctx context.Context, conn *Conn) {
+	timeout := mux.Timeout
+	if timeout <= 0 {
+		timeout = defaultConnMuxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// (The peer's answers are only received while the Conn is being read. What is peeked at is
+	// still there for the handler to read; and, if no data comes in, the handler's first Read
+	// just waits on this one.)
+	go conn.Peek(1)
+
+	if mux.RequestTerminalType {
+		if err := conn.RequestTerminalType(); nil != err {
+			conn.logger.Debugf("Problem asking %q for its terminal type: %v", conn.RemoteAddr(), err)
+		}
+	}
+
+	if err := conn.WaitForNegotiations(ctx); nil != err {
+		return
+	}
+
+	if !mux.RequestTerminalType {
+		return
+	}
+	if _, remote := conn.OptionEnabled(OptTerminalType); !remote {
+		return
+	}
+
+	conn.terminalTypeMutex.Lock()
+	handler := conn.peerTerminalType
+	conn.terminalTypeMutex.Unlock()
+	if nil == handler {
+		return
+	}
+
+	select {
+	case <-handler.told:
+	case <-conn.done:
+	case <-ctx.Done():
+	}
+}
+
+// MatchTerminalType returns a ConnMatcher for peers that told (any of) the terminal types
+// 'patterns' (with TERMINAL-TYPE; see ConnMux.RequestTerminalType). Each pattern is as with
+// path.Match (such as "XTERM*", or "VT1??"); and is matched without regard to case.
+func MatchTerminalType(patterns ...string) ConnMatcher {
+	return func(conn *Conn) bool {
+		for _, name := range conn.PeerTerminalTypes() {
+			for _, pattern := range patterns {
+				if matched, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(name)); matched {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// MatchDumbTerminal returns a ConnMatcher for peers that are dumb terminals; i.e., that told a
+// terminal type such as "DUMB" (or "UNKNOWN"), or did not tell one at all. (Unlike Terminal; which
+// takes a peer that did not tell one to be an ANSI terminal.)
+func MatchDumbTerminal() ConnMatcher {
+	return func(conn *Conn) bool {
+		name, ok := conn.PeerTerminalType()
+		return !ok || dumbTerminalType(name)
+	}
+}
+
+// MatchTLS returns a ConnMatcher for TELNETS (i.e., TELNET over TLS) connections.
+func MatchTLS() ConnMatcher {
+	return func(conn *Conn) bool {
+		_, ok := conn.TLSConnectionState()
+		return ok
+	}
+}
+
+// MatchRemoteNetwork returns a ConnMatcher for connections from (an address in) any of the
+// networks 'cidrs'; such as "10.0.0.0/8", or "2001:db8::/32". It panics if any of them is not a
+// CIDR. (Like regexp.MustCompile; as they are, usually, constants.)
+func MatchRemoteNetwork(cidrs ...string) ConnMatcher {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if nil != err {
+			panic("telnet: MatchRemoteNetwork: " + err.Error())
+		}
+		networks = append(networks, network)
+	}
+
+	return func(conn *Conn) bool {
+		ip := remoteIP(conn.RemoteAddr())
+		if nil == ip {
+			return false
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// remoteIP returns the IP (address) of 'addr'; or nil, if it does not have one.
+func remoteIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.IPAddr:
+		return addr.IP
+	case nil:
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if nil != err {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// MatchOption returns a ConnMatcher for connections that 'option' (such as OptGMCP) was agreed
+// to; on either side.
+func MatchOption(option byte) ConnMatcher {
+	return func(conn *Conn) bool {
+		local, remote := conn.OptionEnabled(option)
+		return local || remote
+	}
+}
+
+// MatchAll returns a ConnMatcher for connections that all of 'matchers' match.
+func MatchAll(matchers ...ConnMatcher) ConnMatcher {
+	return func(conn *Conn) bool {
+		for _, match := range matchers {
+			if !match(conn) {
+				return false
+			}
+		}
+		return true
+	}
+}
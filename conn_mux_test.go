@@ -0,0 +1,112 @@
+package telnet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnMux(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	serve := func(name string) ContextHandler {
+		return testContextHandler(func(ctx context.Context, conn *Conn) {
+			conn.SendLine(name)
+			conn.ReadLine()
+		})
+	}
+
+	mux := &ConnMux{
+		RequestTerminalType: true,
+		Timeout:             2 * time.Second,
+	}
+	mux.Handle(MatchRemoteNetwork("10.0.0.0/8"), serve("internal"))
+	mux.Handle(MatchTerminalType("XTERM*", "VT1??"), serve("rich"))
+	mux.Handle(MatchDumbTerminal(), serve("dumb"))
+	mux.HandleDefault(serve("default"))
+
+	server := NewServer("", WithContextHandler(mux))
+	go server.Serve(listener)
+
+	tests := []struct {
+		TerminalTypes []string
+		Expected      string
+	}{
+		{
+			TerminalTypes: []string{"xterm-256color", "xterm"},
+			Expected:      "rich",
+		},
+		{
+			TerminalTypes: []string{"ANSI", "VT100"},
+			Expected:      "rich",
+		},
+		{
+			TerminalTypes: nil, // (Told as "UNKNOWN".)
+			Expected:      "dumb",
+		},
+		{
+			TerminalTypes: []string{"ansi"},
+			Expected:      "default",
+		},
+	}
+
+	for testNumber, test := range tests {
+		client := &Client{TerminalTypes: test.TerminalTypes}
+
+		conn, err := client.DialTo(listener.Addr().String())
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		line, _ := conn.ReadLine()
+		if expected, actual := test.Expected, line; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		conn.Close()
+	}
+}
+
+func TestConnMuxWithoutTerminalType(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	var mux ConnMux
+	mux.Handle(MatchTLS(), testContextHandler(func(ctx context.Context, conn *Conn) {
+		conn.SendLine("secure")
+	}))
+	mux.Handle(MatchAll(MatchRemoteNetwork("127.0.0.0/8", "::1/128"), MatchDumbTerminal()), testContextHandler(func(ctx context.Context, conn *Conn) {
+		// (What the peer sent while the ConnMux was waiting is still there to be read.)
+		line, _ := conn.ReadLine()
+		conn.SendLine("local " + line)
+	}))
+
+	server := NewServer("", WithContextHandler(&mux))
+	go server.Serve(listener)
+
+	// (A peer that does not negotiate at all.)
+	c, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer c.Close()
+	c.Write([]byte("hello\r\n"))
+
+	conn := newConn(c, nil)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if line, err := conn.ReadLine(); "local hello" != line {
+		t.Errorf("Expected %q, but actually got %q (and error %v).", "local hello", line, err)
+	}
+}
@@ -0,0 +1,186 @@
+package telnet
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// defaultLimitsBanner is what is sent to a connection that is turned away by the ConnectionLimits,
+// if their Banner is not set.
+const defaultLimitsBanner = "Too many connections; please try again later.\r\n"
+
+// ServerLimit is which of the limits of a Server was hit; as given to Server.OnLimit.
+type ServerLimit int
+
+const (
+	// LimitMaxConnections is the MaxConnections of the ConnectionLimits; the connection was turned
+	// away.
+	LimitMaxConnections ServerLimit = iota + 1
+
+	// LimitMaxConnectionsPerIP is the MaxConnectionsPerIP of the ConnectionLimits; the connection
+	// was turned away.
+	LimitMaxConnectionsPerIP
+
+	// LimitInputRate is the InputRateLimit; reading from the connection had to wait.
+	LimitInputRate
+)
+
+// String returns the name of the ServerLimit; such as "max connections per IP".
+func (limit ServerLimit) String() string {
+	switch limit {
+	case LimitMaxConnections:
+		return "max connections"
+	case LimitMaxConnectionsPerIP:
+		return "max connections per IP"
+	case LimitInputRate:
+		return "input rate"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionLimits are how many connections a Server serves at once; so that a flood of them
+// (such as from scanners) is shed before any of it gets to the handler. For example:
+//
+//	server := &telnet.Server{
+//		Addr:    ":5555",
+//		Handler: handler,
+//		ConnectionLimits: telnet.ConnectionLimits{
+//			MaxConnections:      1000,
+//			MaxConnectionsPerIP: 4,
+//		},
+//		InputRateLimit: telnet.RateLimit{
+//			BytesPerSecond: 1024,
+//		},
+//		OnLimit: func(remoteAddr net.Addr, limit telnet.ServerLimit) {
+//			log.Printf("%v: hit the %v limit", remoteAddr, limit)
+//		},
+//	}
+//
+// A connection that is over them is turned away as soon as it is accepted; before its TLS
+// handshake (if there is one), and before anything else is done with it. It is sent the Banner,
+// and closed (with ClosePolicyRejected as the reason given to OnDisconnect).
+//
+// The zero value of each limit means no limit.
+type ConnectionLimits struct {
+	// MaxConnections is how many connections can be open at once, in all.
+	MaxConnections int
+
+	// MaxConnectionsPerIP is how many connections can be open at once from each IP address.
+	MaxConnectionsPerIP int
+
+	// Banner is what is sent to a connection that is turned away. If empty, it is "Too many
+	// connections; please try again later.\r\n".
+	Banner string
+}
+
+// internalConnCounter counts the connections a Server is serving; in all, and by IP address.
+type internalConnCounter struct {
+	mutex sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+// acquire counts a connection from 'addr'; unless that would put it over 'limits', in which case
+// it returns which of them (and does not count it).
+func (counter *internalConnCounter) acquire(addr net.Addr, limits ConnectionLimits) (ServerLimit, bool) {
+	key := remoteKey(addr)
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	if 0 < limits.MaxConnections && limits.MaxConnections <= counter.total {
+		return LimitMaxConnections, false
+	}
+	if 0 < limits.MaxConnectionsPerIP && limits.MaxConnectionsPerIP <= counter.perIP[key] {
+		return LimitMaxConnectionsPerIP, false
+	}
+
+	if nil == counter.perIP {
+		counter.perIP = map[string]int{}
+	}
+	counter.total++
+	counter.perIP[key]++
+	return 0, true
+}
+
+// release stops counting a connection from 'addr'; that acquire counted.
+func (counter *internalConnCounter) release(addr net.Addr) {
+	key := remoteKey(addr)
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	if counter.perIP[key] <= 0 {
+		return
+	}
+	counter.total--
+	counter.perIP[key]--
+	if counter.perIP[key] <= 0 {
+		delete(counter.perIP, key)
+	}
+}
+
+// count returns how many connections are counted; in all, and from (the IP address of) 'addr'.
+func (counter *internalConnCounter) count(addr net.Addr) (total int, fromIP int) {
+	key := remoteKey(addr)
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	return counter.total, counter.perIP[key]
+}
+
+// remoteKey returns what connections from 'addr' are counted by; its IP address, or (if it does not
+// have one) the whole of it.
+func remoteKey(addr net.Addr) string {
+	if ip := remoteIP(addr); nil != ip {
+		return ip.String()
+	}
+	if nil == addr {
+		return ""
+	}
+	return addr.String()
+}
+
+// admit counts 'c'; or, if it is over the ConnectionLimits, turns it away (and returns false).
+func (server *Server) admit(c net.Conn) bool {
+	limit, ok := server.connections.acquire(c.RemoteAddr(), server.ConnectionLimits)
+	if ok {
+		return true
+	}
+
+	server.logger().Debugf("Rejected connection from %q; it is over the %v limit.", c.RemoteAddr(), limit)
+	server.limited(c.RemoteAddr(), limit)
+
+	banner := server.ConnectionLimits.Banner
+	if "" == banner {
+		banner = defaultLimitsBanner
+	}
+
+	// (So as to not hold up accepting the next connection, on a peer that is not reading.)
+	go server.reject(c, banner, fmt.Sprintf("over the %v limit", limit))
+	return false
+}
+
+// limited calls OnLimit (if it is not nil).
+func (server *Server) limited(remoteAddr net.Addr, limit ServerLimit) {
+	if fn := server.OnLimit; nil != fn {
+		fn(remoteAddr, limit)
+	}
+}
+
+// Connections returns how many connections the server is serving (or setting up) right now. (See
+// ConnectionLimits.)
+func (server *Server) Connections() int {
+	total, _ := server.connections.count(nil)
+	return total
+}
+
+// ConnectionsFrom returns how many connections the server is serving (or setting up) right now,
+// from the IP address of 'addr'. (See ConnectionLimits.)
+func (server *Server) ConnectionsFrom(addr net.Addr) int {
+	_, fromIP := server.connections.count(addr)
+	return fromIP
+}
@@ -0,0 +1,237 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestConnCounter(t *testing.T) {
+
+	addr := func(s string) net.Addr {
+		a, err := net.ResolveTCPAddr("tcp", s)
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		return a
+	}
+
+	limits := ConnectionLimits{MaxConnections: 3, MaxConnectionsPerIP: 2}
+
+	tests := []struct {
+		Addr     string
+		Release  bool
+		Expected ServerLimit
+		Total    int
+	}{
+		{Addr: "192.0.2.1:1000", Total: 1},
+		{Addr: "192.0.2.1:1001", Total: 2},
+		{Addr: "192.0.2.1:1002", Expected: LimitMaxConnectionsPerIP, Total: 2},
+		{Addr: "192.0.2.2:1000", Total: 3},
+		{Addr: "192.0.2.3:1000", Expected: LimitMaxConnections, Total: 3},
+		{Addr: "192.0.2.1:1000", Release: true, Total: 2},
+		{Addr: "192.0.2.1:1003", Total: 3},
+		{Addr: "203.0.113.9:1000", Release: true, Total: 3}, // (Was never counted.)
+	}
+
+	var counter internalConnCounter
+	for testNumber, test := range tests {
+		if test.Release {
+			counter.release(addr(test.Addr))
+		} else {
+			limit, ok := counter.acquire(addr(test.Addr), limits)
+			if expected, actual := test.Expected, limit; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+			if expected, actual := 0 == test.Expected, ok; expected != actual {
+				t.Errorf("For test #%d, expected %t, but actually got %t.", testNumber, expected, actual)
+			}
+		}
+
+		if total, _ := counter.count(nil); test.Total != total {
+			t.Errorf("For test #%d, expected %d connections, but actually got %d.", testNumber, test.Total, total)
+		}
+	}
+
+	if _, fromIP := counter.count(addr("192.0.2.1:9")); 2 != fromIP {
+		t.Errorf("Expected %d connections from 192.0.2.1, but actually got %d.", 2, fromIP)
+	}
+}
+
+// testLimitEvents records what Server.OnLimit, and Server.OnDisconnect, are called with.
+type testLimitEvents struct {
+	mutex   sync.Mutex
+	limits  []ServerLimit
+	reasons []CloseReason
+}
+
+func (events *testLimitEvents) onLimit(remoteAddr net.Addr, limit ServerLimit) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	events.limits = append(events.limits, limit)
+}
+
+func (events *testLimitEvents) onDisconnect(conn *Conn, reason CloseReason, msg string) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	events.reasons = append(events.reasons, reason)
+}
+
+func (events *testLimitEvents) snapshot() ([]ServerLimit, []CloseReason) {
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+
+	return append([]ServerLimit(nil), events.limits...), append([]CloseReason(nil), events.reasons...)
+}
+
+// testReadRejected reads what is sent to a connection that is turned away.
+func testReadRejected(t *testing.T, listener net.Listener) string {
+	t.Helper()
+
+	rejected := testDial(t, listener)
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	banner, err := io.ReadAll(rejected)
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	return string(banner)
+}
+
+func TestServerConnectionLimits(t *testing.T) {
+
+	tests := []struct {
+		Limits         ConnectionLimits
+		Expected       ServerLimit
+		ExpectedBanner string
+	}{
+		{
+			Limits:         ConnectionLimits{MaxConnectionsPerIP: 2},
+			Expected:       LimitMaxConnectionsPerIP,
+			ExpectedBanner: defaultLimitsBanner,
+		},
+		{
+			Limits:         ConnectionLimits{MaxConnections: 2, Banner: "Go away.\r\n"},
+			Expected:       LimitMaxConnections,
+			ExpectedBanner: "Go away.\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+
+		var events testLimitEvents
+		handler := testPoolHandler{started: make(chan struct{}, 8)}
+		server := &Server{
+			Handler:          handler,
+			ConnectionLimits: test.Limits,
+			OnLimit:          events.onLimit,
+			OnDisconnect:     events.onDisconnect,
+		}
+		go server.Serve(listener)
+
+		var clients []net.Conn
+		for i := 0; i < 2; i++ {
+			clients = append(clients, testDial(t, listener))
+			<-handler.started
+		}
+
+		if expected, actual := test.ExpectedBanner, testReadRejected(t, listener); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		// (OnDisconnect is called once the connection has been closed; which is not necessarily
+		// before the client sees it closed.)
+		limits, reasons := events.snapshot()
+		for begin := time.Now(); 0 == len(reasons) && time.Since(begin) < time.Second; {
+			time.Sleep(5 * time.Millisecond)
+			limits, reasons = events.snapshot()
+		}
+		if expected, actual := []ServerLimit{test.Expected}, limits; 1 != len(actual) || expected[0] != actual[0] {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := []CloseReason{ClosePolicyRejected}, reasons; 1 != len(actual) || expected[0] != actual[0] {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := 2, server.Connections(); expected != actual {
+			t.Errorf("For test #%d, expected %d connections, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := 2, server.ConnectionsFrom(clients[0].LocalAddr()); expected != actual {
+			t.Errorf("For test #%d, expected %d connections from %v, but actually got %d.", testNumber, expected, clients[0].LocalAddr(), actual)
+		}
+
+		// Once a session ends, there is room for another.
+		clients[0].Write([]byte("q"))
+		for begin := time.Now(); 2 <= server.Connections() && time.Since(begin) < time.Second; {
+			time.Sleep(5 * time.Millisecond)
+		}
+		testDial(t, listener)
+		select {
+		case <-handler.started:
+		case <-time.After(time.Second):
+			t.Errorf("For test #%d, timed out waiting for the connection to be served.", testNumber)
+		}
+
+		listener.Close()
+	}
+}
+
+func TestConnSetInputRateLimit(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	defer conn.Close()
+
+	var throttled int
+	conn.inputThrottle.throttled = func() {
+		throttled++
+	}
+	conn.SetInputRateLimit(RateLimit{BytesPerSecond: 200, Burst: 10})
+
+	data := []byte("0123456789012345678901234567890123456789")
+	go remote.Write(data)
+
+	// (The first 10 are the burst; and the other 30 come at 200 per second.)
+	begin := time.Now()
+	p := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if elapsed := time.Since(begin); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected reading to be throttled, but it actually only took %v.", elapsed)
+	}
+	if expected, actual := string(data), string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if throttled < 1 {
+		t.Errorf("Expected reading to have had to wait, but actually it did not.")
+	}
+
+	// A Read waiting on the limit gives up at the read deadline.
+	conn.SetInputRateLimit(RateLimit{BytesPerSecond: 1, Burst: 2})
+	go remote.Write(data[:4])
+	if _, err := io.ReadFull(conn, p[:2]); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := conn.Read(p); !os.IsTimeout(err) {
+		t.Errorf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+
+	// The zero RateLimit removes the limit.
+	conn.SetReadDeadline(time.Time{})
+	conn.SetInputRateLimit(RateLimit{})
+	if _, err := io.ReadFull(conn, p[:2]); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
@@ -0,0 +1,119 @@
+package telnet
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+// A ContextCaller represents the client end of a TELNET (or TELNETS) connection; like a Caller,
+// but it is given the *Conn itself, rather than just a Writer and a Reader. So it can (also) find
+// out what options were agreed to (see Conn.OptionEnabled), such as whether the server is doing
+// the echoing (ECHO), set the window size (see Conn.SetWindowSize), and send TELNET commands (see
+// Conn.SendCommand).
+//
+// 'ctx' is cancelled once the context that the connection was dialed with (see
+// DialToAndCallContext) is; or once the connection drops. (And, once 'ctx' is cancelled, the
+// connection is closed; so that whatever is reading from it, or writing to it, stops.)
+//
+// For example:
+//
+//	func (caller myCaller) CallTELNET(ctx context.Context, conn *telnet.Conn) {
+//		conn.SetWindowSize(80, 24)
+//
+//		//@TODO: Read from, and write to, conn; until ctx is done.
+//	}
+//
+// A Caller can be used where a ContextCaller is needed, with AdaptCaller.
+type ContextCaller interface {
+	CallTELNET(ctx context.Context, conn *Conn)
+}
+
+// AdaptCaller returns a ContextCaller for 'caller'; so that (older) Callers can be used with
+// DialToAndCallContext (and the like). The Caller is given the *Conn as its Writer, and as its
+// Reader.
+//
+// (AdaptCaller(StandardCaller) returns the ContextCaller that the StandardCaller is.)
+func AdaptCaller(caller Caller) ContextCaller {
+	return adaptCaller(caller, nil)
+}
+
+// adaptCaller is AdaptCaller; with 'logger' being what is injected into the Context the Caller
+// is given. (If it is nil, then it is the logger of the Conn.)
+func adaptCaller(caller Caller, logger Logger) ContextCaller {
+	if adapted, ok := caller.(interface{ contextCaller() ContextCaller }); ok {
+		return adapted.contextCaller()
+	}
+
+	return internalCallerAdapter{caller: caller, logger: logger}
+}
+
+type internalCallerAdapter struct {
+	caller Caller
+	logger Logger
+}
+
+func (adapter internalCallerAdapter) CallTELNET(ctx context.Context, conn *Conn) {
+	logger := adapter.logger
+	if nil == logger {
+		logger = conn.logger
+	}
+
+	var telnetCtx Context = NewContext().InjectLogger(logger)
+
+	var w Writer = conn
+	var r Reader = conn
+
+	adapter.caller.CallTELNET(telnetCtx, w, r)
+}
+
+// DialToAndCallContext makes a TELNET client connection to 'srvAddr' (with a Dialer configured by
+// 'options'; see NewDialer), and then calls 'caller' with it. ('ctx' being cancelled, while it is
+// calling, has it (and the connection) end.) For example:
+//
+//	err := telnet.DialToAndCallContext(ctx, "example.net:23", caller,
+//		telnet.WithDialTimeout(10*time.Second),
+//		telnet.WithKeepAlive(time.Minute),
+//	)
+//
+// (Without WithTLSConfig, it is un-secure TELNET.)
+func DialToAndCallContext(ctx context.Context, srvAddr string, caller ContextCaller, options ...Option) error {
+	client := &Client{ContextCaller: caller}
+
+	return client.dialAndCall(ctx, srvAddr, options)
+}
+
+// DialToAndCallTLSContext makes a (secure) TELNETS client connection to 'srvAddr' (see
+// Dialer.DialToTLSContext); and then calls 'caller' with it. (It is DialToAndCallContext, with
+// WithTLSConfig.)
+func DialToAndCallTLSContext(ctx context.Context, srvAddr string, caller ContextCaller, tlsConfig *tls.Config) error {
+	return DialToAndCallContext(ctx, srvAddr, caller, withTLS(tlsConfig))
+}
+
+// CallContext calls the ContextCaller (or, if that is not set, the Caller; and, if that is not
+// set either, the StandardCaller) with 'conn'; and then closes it.
+//
+// The context the ContextCaller is given is cancelled once 'ctx' is, or once the connection
+// drops; and the connection is closed once that context is cancelled. If it returns because 'ctx'
+// was cancelled, then CallContext returns the error of 'ctx'.
+func (client *Client) CallContext(ctx context.Context, conn *Conn) error {
+
+	logger := client.logger()
+
+	caller := client.ContextCaller
+	if nil == caller {
+		oldCaller := client.Caller
+		if nil == oldCaller {
+			logger.Debug("Defaulted caller to StandardCaller.")
+			oldCaller = StandardCaller
+		}
+		caller = adaptCaller(oldCaller, logger)
+	}
+
+	callCtx, cancel := conn.withContext(ctx)
+	defer cancel()
+
+	caller.CallTELNET(callCtx, conn)
+	conn.Close()
+
+	return ctx.Err()
+}
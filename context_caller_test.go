@@ -0,0 +1,198 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+type testContextCaller func(ctx context.Context, conn *Conn)
+
+func (fn testContextCaller) CallTELNET(ctx context.Context, conn *Conn) {
+	fn(ctx, conn)
+}
+
+// testListen returns a listener, whose connections are handled (each) by 'handle'.
+func testListen(t *testing.T, handle func(c net.Conn)) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			go func() {
+				defer c.Close()
+				handle(c)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestDialToAndCallContext(t *testing.T) {
+
+	tests := []struct {
+		Name   string
+		Cancel bool
+		Drop   bool
+	}{
+		{
+			Name:   "cancelled",
+			Cancel: true,
+		},
+		{
+			Name: "dropped",
+			Drop: true,
+		},
+	}
+
+	for testNumber, test := range tests {
+		drop := test.Drop
+		listener := testListen(t, func(c net.Conn) {
+			c.Write([]byte{IAC, WILL, OptEcho})
+			c.Write([]byte("hello"))
+			if drop {
+				return
+			}
+			io.Copy(io.Discard, c)
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var echo bool
+		var data string
+		caller := testContextCaller(func(callCtx context.Context, conn *Conn) {
+			conn.RegisterOption(OptEcho, SimpleOption(OptionSupport{Remote: true}))
+
+			var buffer [5]byte
+			if _, err := io.ReadFull(conn, buffer[:]); nil != err {
+				t.Errorf("For test #%d (%s), did not expect an error, but actually got one: (%T) %v", testNumber, test.Name, err, err)
+			}
+			data = string(buffer[:])
+			_, echo = conn.OptionEnabled(OptEcho)
+
+			// (Something has to be reading, to notice the connection drop.)
+			go io.Copy(io.Discard, conn)
+			if test.Cancel {
+				cancel()
+			}
+
+			select {
+			case <-callCtx.Done():
+			case <-time.After(5 * time.Second):
+				t.Errorf("For test #%d (%s), timed out waiting for the context to be cancelled.", testNumber, test.Name)
+			}
+		})
+
+		err := DialToAndCallContext(ctx, listener.Addr().String(), caller)
+		if test.Cancel && !errors.Is(err, context.Canceled) {
+			t.Errorf("For test #%d (%s), expected %v, but actually got: (%T) %v", testNumber, test.Name, context.Canceled, err, err)
+		}
+		if test.Drop && nil != err {
+			t.Errorf("For test #%d (%s), did not expect an error, but actually got one: (%T) %v", testNumber, test.Name, err, err)
+		}
+
+		if "hello" != data {
+			t.Errorf("For test #%d (%s), expected %q, but actually got %q.", testNumber, test.Name, "hello", data)
+		}
+		if !echo {
+			t.Errorf("For test #%d (%s), expected the server to be doing ECHO, but actually was not.", testNumber, test.Name)
+		}
+
+		cancel()
+	}
+}
+
+type testOldCaller struct {
+	called chan bool
+}
+
+func (caller testOldCaller) CallTELNET(ctx Context, w Writer, r Reader) {
+	_, isConn := w.(*Conn)
+	caller.called <- isConn && Writer(r.(*Conn)) == w && nil != ctx.Logger()
+}
+
+func TestAdaptCaller(t *testing.T) {
+
+	conn, _ := testPipe(t)
+
+	caller := testOldCaller{called: make(chan bool, 1)}
+	AdaptCaller(caller).CallTELNET(context.Background(), conn)
+
+	if actual := <-caller.called; !actual {
+		t.Errorf("Expected the Caller to be given the *Conn (and a logger), but actually was not.")
+	}
+
+	if _, ok := AdaptCaller(StandardCaller).(internalStandardContextCaller); !ok {
+		t.Errorf("Expected the StandardCaller to be adapted to the ContextCaller it is, but actually got: %T", AdaptCaller(StandardCaller))
+	}
+}
+
+func TestStandardCallerCharacterMode(t *testing.T) {
+
+	tests := []struct {
+		ServerSends []byte
+		Mode        string
+		Expected    bool
+	}{
+		{
+			Expected: false,
+		},
+		{
+			ServerSends: []byte{IAC, WILL, OptEcho},
+			Expected:    false,
+		},
+		{
+			ServerSends: []byte{IAC, WILL, OptEcho, IAC, WILL, OptSuppressGoAhead},
+			Expected:    true,
+		},
+		{
+			ServerSends: []byte{IAC, WILL, OptEcho, IAC, WILL, OptSuppressGoAhead},
+			Mode:        "line",
+			Expected:    false,
+		},
+		{
+			Mode:     "character",
+			Expected: true,
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		conn.RegisterOption(OptEcho, SimpleOption(OptionSupport{Remote: true}))
+		conn.RegisterOption(OptSuppressGoAhead, SimpleOption(OptionSupport{Local: true, Remote: true}))
+
+		send(append(test.ServerSends, 'x')...)
+		var buffer [1]byte
+		conn.Read(buffer[:])
+
+		session := internalStandardCallerSession{
+			stderr: io.Discard,
+			w:      conn,
+			conn:   conn,
+		}
+		if "" != test.Mode {
+			session.execute("mode " + test.Mode)
+		}
+
+		if expected, actual := test.Expected, session.inCharacterMode(); expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t; for %q.", testNumber, expected, actual, strings.TrimSpace(string(test.ServerSends)))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package telnet
+
+import (
+	"context"
+)
+
+// A ContextHandler serves a TELNET (or TELNETS) connection; like a Handler, but it is given the
+// *Conn itself, rather than just a Writer and a Reader. So it can (also) get at the client's
+// address (see Conn.RemoteAddr), find out what options were agreed to (see Conn.OptionEnabled),
+// send TELNET commands and subnegotiations (see Conn.SendCommand, and Conn.SendSubnegotiation),
+// and so on.
+//
+// 'ctx' is the context of the connection; which is cancelled once the connection drops (i.e.,
+// once reading from it fails), or is closed. (And, once 'ctx' is cancelled, the connection is
+// closed; so that whatever is reading from it, or writing to it, stops.) So a goroutine that the
+// handler starts, for (say) writing notifications, can stop on ctx.Done(); while the handler
+// itself reads.
+//
+// For example:
+//
+//	type myHandler struct{}
+//
+//	func (myHandler) ServeTELNET(ctx context.Context, conn *telnet.Conn) {
+//		conn.Logger().Debugf("Serving %q.", conn.RemoteAddr())
+//
+//		//@TODO: Read from, and write to, conn; until ctx is done.
+//	}
+//
+//	server := &telnet.Server{
+//		Addr:           ":5555",
+//		ContextHandler: myHandler{},
+//	}
+//
+// Migrating a Handler to a ContextHandler is mostly a matter of changing its ServeTELNET from:
+//
+//	func (myHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader)
+//
+// ... to:
+//
+//	func (myHandler) ServeTELNET(ctx context.Context, conn *telnet.Conn)
+//
+// ... and using 'conn' where it used 'w' and 'r' (and conn.Logger() where it used ctx.Logger());
+// and then setting it as the ContextHandler (rather than the Handler) of the Server.
+//
+// A Handler can be used where a ContextHandler is needed, with AdaptHandler. (Which is what the
+// Server does with its Handler, if it has no ContextHandler.)
+type ContextHandler interface {
+	ServeTELNET(ctx context.Context, conn *Conn)
+}
+
+// AdaptHandler returns a ContextHandler for 'handler'. The Handler is given the *Conn as its
+// Writer, and as its Reader; and a Context with the logger of the Conn.
+//
+// A Handler that also has a method:
+//
+//	ContextHandler() telnet.ContextHandler
+//
+// ... (such as EchoHandler, a *ProxyHandler, and the ShellHandler of telsh, do) is (instead)
+// the ContextHandler that method returns. (This is how a Handler can be both.)
+func AdaptHandler(handler Handler) ContextHandler {
+	if adapted, ok := handler.(interface{ ContextHandler() ContextHandler }); ok {
+		return adapted.ContextHandler()
+	}
+
+	return internalHandlerAdapter{handler: handler}
+}
+
+type internalHandlerAdapter struct {
+	handler Handler
+}
+
+func (adapter internalHandlerAdapter) ServeTELNET(ctx context.Context, conn *Conn) {
+	var telnetCtx Context = NewContext().InjectLogger(conn.logger)
+
+	var w Writer = conn
+	var r Reader = conn
+
+	adapter.handler.ServeTELNET(telnetCtx, w, r)
+}
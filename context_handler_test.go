@@ -0,0 +1,109 @@
+package telnet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"testing"
+)
+
+type testHandler func(ctx Context, w Writer, r Reader)
+
+func (fn testHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	fn(ctx, w, r)
+}
+
+type testContextHandler func(ctx context.Context, conn *Conn)
+
+func (fn testContextHandler) ServeTELNET(ctx context.Context, conn *Conn) {
+	fn(ctx, conn)
+}
+
+func TestServerContextHandler(t *testing.T) {
+
+	type served struct {
+		Data      string
+		Cancelled bool
+	}
+	ch := make(chan served, 1)
+
+	server := &Server{
+		Logger: internalDiscardLogger{},
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			p, _ := io.ReadAll(conn)
+
+			var cancelled bool
+			select {
+			case <-ctx.Done():
+				cancelled = true
+			case <-time.After(time.Second):
+			}
+
+			ch <- served{Data: string(p), Cancelled: cancelled}
+		}),
+	}
+
+	client := testServe(t, server)
+	client.Write([]byte("hello"))
+	client.Close()
+
+	select {
+	case actual := <-ch:
+		if expected := "hello"; expected != actual.Data {
+			t.Errorf("Expected the data to be %q, but actually got %q.", expected, actual.Data)
+		}
+		if !actual.Cancelled {
+			t.Errorf("Expected the context to be cancelled once the client dropped, but actually it was not.")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the handler to be served, but actually it was not.")
+	}
+}
+
+func TestAdaptHandler(t *testing.T) {
+
+	var actualW Writer
+	var actualR Reader
+	var handler Handler = testHandler(func(ctx Context, w Writer, r Reader) {
+		actualW = w
+		actualR = r
+	})
+
+	conn, _ := testPipe(t)
+	AdaptHandler(handler).ServeTELNET(context.Background(), conn)
+
+	if actualW != Writer(conn) {
+		t.Errorf("Expected the Writer to be the Conn, but actually got %T.", actualW)
+	}
+	if actualR != Reader(conn) {
+		t.Errorf("Expected the Reader to be the Conn, but actually got %T.", actualR)
+	}
+
+	tests := []struct {
+		Handler  Handler
+		Expected string
+	}{
+		{
+			Handler:  EchoHandler,
+			Expected: "telnet.internalEchoContextHandler",
+		},
+		{
+			Handler:  &ProxyHandler{Target: "127.0.0.1:23"},
+			Expected: "telnet.internalProxyContextHandler",
+		},
+		{
+			Handler:  handler,
+			Expected: "telnet.internalHandlerAdapter",
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual := fmt.Sprintf("%T", AdaptHandler(test.Handler))
+
+		if expected := test.Expected; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package telnet
+
+// SendEOF sends the TELNET EOF command (i.e., IAC EOF) to the peer; which (per RFC 1184) is the
+// end-of-file character, for the process on the other end. (Such as for a gateway, passing on a
+// Ctrl-D typed at its own terminal.)
+//
+// Like SendCommand, it is sent after whatever data was written before it (even if that data is
+// still buffered; see SetWriteCoalescing); and before whatever data is written after it.
+func (clientConn *Conn) SendEOF() error {
+	return clientConn.SendCommand(EOF)
+}
+
+// SendSuspend sends the TELNET SUSP command (i.e., IAC SUSP) to the peer; which (per RFC 1184)
+// suspends the process on the other end. (It is otherwise like SendEOF.)
+func (clientConn *Conn) SendSuspend() error {
+	return clientConn.SendCommand(SUSP)
+}
+
+// SendAbortProcess sends the TELNET ABORT command (i.e., IAC ABORT) to the peer; which (per RFC
+// 1184) aborts the process on the other end. (It is otherwise like SendEOF.)
+func (clientConn *Conn) SendAbortProcess() error {
+	return clientConn.SendCommand(ABORT)
+}
+
+// SendGoAhead sends the TELNET GA command (i.e., IAC GA) to the peer; which marks (such as for a
+// MUD client) that what was written before it is a prompt. (It is otherwise like SendEOF.)
+//
+// (Once SUPPRESS-GO-AHEAD is enabled, the peer is not expecting it; and so it is not to be sent.)
+func (clientConn *Conn) SendGoAhead() error {
+	return clientConn.SendCommand(GA)
+}
+
+// SendAreYouThere sends the TELNET AYT command (i.e., IAC AYT) to the peer; which asks it to send
+// back something (that a person can see) to show that it is still there. (It is otherwise like
+// SendEOF.)
+func (clientConn *Conn) SendAreYouThere() error {
+	return clientConn.SendCommand(AYT)
+}
+
+// SendInterruptProcess sends the TELNET IP command (i.e., IAC IP) to the peer; which interrupts
+// the process on the other end, like Ctrl-C does at a terminal. (It is otherwise like SendEOF.)
+func (clientConn *Conn) SendInterruptProcess() error {
+	return clientConn.SendCommand(IP)
+}
+
+// SetEOFCommand sets whether an IAC EOF, received from the peer, is read as the end of the data;
+// i.e., has Read (and ReadLine, and the rest) return io.EOF, once, right after the data that
+// came before it. That is like an end-of-file character (such as Ctrl-D) does at a terminal: the
+// connection is not closed; and what comes after it is read after that. For example:
+//
+//	conn.SetEOFCommand(true)
+//
+//	data, err := io.ReadAll(conn) // (Everything up to the IAC EOF.)
+//
+// The default is false; in which case (like any other command) an IAC EOF is only passed on
+// (to what was registered with OnCommand).
+func (clientConn *Conn) SetEOFCommand(enabled bool) {
+	clientConn.dataReader.eofCommand.Store(enabled)
+}
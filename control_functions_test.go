@@ -0,0 +1,189 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestConnSendControlFunctions(t *testing.T) {
+
+	tests := []struct {
+		Send     func(*Conn) error
+		Expected []byte
+	}{
+		{
+			Send:     (*Conn).SendEOF,
+			Expected: []byte{'l', 's', IAC, EOF},
+		},
+		{
+			Send:     (*Conn).SendSuspend,
+			Expected: []byte{'l', 's', IAC, SUSP},
+		},
+		{
+			Send:     (*Conn).SendAbortProcess,
+			Expected: []byte{'l', 's', IAC, ABORT},
+		},
+		{
+			Send:     (*Conn).SendGoAhead,
+			Expected: []byte{'l', 's', IAC, GA},
+		},
+		{
+			Send:     (*Conn).SendAreYouThere,
+			Expected: []byte{'l', 's', IAC, AYT},
+		},
+		{
+			Send:     (*Conn).SendInterruptProcess,
+			Expected: []byte{'l', 's', IAC, IP},
+		},
+		{
+			Send: func(conn *Conn) error {
+				return conn.SendOption(DONT, OptLinemode)
+			},
+			Expected: []byte{'l', 's', IAC, DONT, OptLinemode},
+		},
+		{
+			Send: func(conn *Conn) error {
+				return conn.SendSubnegotiation(OptGMCP, []byte{'x', 255, 'y'})
+			},
+			Expected: []byte{'l', 's', IAC, SB, OptGMCP, 'x', IAC, IAC, 'y', IAC, SE},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		// (The data that is still being held back is sent before the command.)
+		conn.SetWriteCoalescing(time.Hour, 1024)
+
+		errs := make(chan error, 1)
+		go func() {
+			if _, err := conn.Write([]byte("ls")); nil != err {
+				errs <- err
+				return
+			}
+			errs <- test.Send(conn)
+		}()
+
+		if expected, actual := test.Expected, testReadExactly(t, remote, len(test.Expected)); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if err := <-errs; nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+	}
+}
+
+func TestConnSendInvalid(t *testing.T) {
+
+	tests := []struct {
+		Send     func(*Conn) error
+		Expected error
+	}{
+		{
+			Send:     func(conn *Conn) error { return conn.SendCommand(SB) },
+			Expected: ErrInvalidCommand,
+		},
+		{
+			Send:     func(conn *Conn) error { return conn.SendCommand(IAC) },
+			Expected: ErrInvalidCommand,
+		},
+		{
+			Send:     func(conn *Conn) error { return conn.SendCommand(WILL) },
+			Expected: ErrInvalidCommand,
+		},
+		{
+			Send:     func(conn *Conn) error { return conn.SendOption(AYT, OptEcho) },
+			Expected: ErrInvalidVerb,
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, _ := testPipe(t)
+
+		// (Nothing is sent; so nothing has to read it.)
+		if expected, actual := test.Expected, test.Send(conn); expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnReceiveControlFunctions(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+
+	var mutex sync.Mutex
+	var received []byte
+	conn.OnCommand(func(cmd byte) {
+		mutex.Lock()
+		received = append(received, cmd)
+		mutex.Unlock()
+	})
+
+	go remote.Write([]byte{'a', IAC, EOF, 'b', IAC, SUSP, 'c', IAC, ABORT, 'd'})
+
+	// (The EOF is not read as io.EOF; as SetEOFCommand was not called.)
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "abcd", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if expected, actual := []byte{EOF, SUSP, ABORT}, received; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestConnSetEOFCommand(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	conn.SetEOFCommand(true)
+
+	go remote.Write([]byte("cat > notes\r\nhello\r\n\xff\xecls\r\n"))
+
+	var lines []string
+	for {
+		line, err := conn.ReadLine()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		lines = append(lines, line)
+	}
+	if expected, actual := []string{"cat > notes", "hello"}, lines; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// The connection is not closed; what came after the IAC EOF is read next.
+	line, err := conn.ReadLine()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "ls", line; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (And it is not taken to be the peer closing it.)
+	conn.Close()
+	if expected, actual := CloseUnknown, conn.closeReason; expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"errors"
 	"io"
-	"log"
+	"sync/atomic"
 )
 
 var (
-	errCorrupted = errors.New("Corrupted")
+	// errEOFCommand is what Read returns for an IAC EOF (see eofCommand); which the Conn reads
+	// as io.EOF. (It is not io.EOF itself, so that it is not taken to be the peer closing the
+	// connection.)
+	errEOFCommand = errors.New("telnet: IAC EOF")
 )
 
 // An internalDataReader deals with "un-escaping" according to the TELNET protocol.
@@ -56,6 +59,65 @@ var (
 type internalDataReader struct {
 	wrapped  io.Reader
 	buffered *bufio.Reader
+
+	// handler (if not nil) is told about the TELNET commands, option negotiations, and
+	// subnegotiations that are found (and filtered out of the data) while reading.
+	handler internalCommandHandler
+
+	// keepOrder, if true, has Read return the data it has so far before (rather than after) it
+	// would find a TELNET command; so that whatever the command is passed on to does not get it
+	// before the data that came before it.
+	keepOrder bool
+
+	// limiter (if not nil) limits how many subnegotiations can be read per second (and how long each
+	// can be); and traffic is
+	// told how much data is read. (See TrafficMetrics.)
+	limiter *internalInputLimiter
+	traffic *internalTraffic
+
+	// isPrompt (if not nil) returns whether the TELNET command 'cmd' marks a prompt (such as
+	// IAC GA); in which case Read returns right after it. (See Conn.ReadUntilPrompt.)
+	isPrompt func(cmd byte) bool
+	prompted bool
+
+	// eofCommand is whether an IAC EOF is read as the end of the data; i.e., has Read return
+	// io.EOF (once), like an end-of-file character does at a terminal. And eof is whether that is
+	// still to be returned. (See Conn.SetEOFCommand.)
+	eofCommand atomic.Bool
+	eof        bool
+
+	// lenient is whether what is not TELNET is recovered from (ParseLenient), rather than being
+	// an error (ParseStrict). (See Conn.SetParseMode.)
+	lenient atomic.Bool
+
+	// resume is what Read has to pick up where it left off with; when a TELNET command was cut
+	// short by an error (such as a timeout). (And payload is what has been read, so far, of a
+	// subnegotiation.)
+	resume  internalResumeState
+	payload []byte
+}
+
+type internalResumeState int
+
+const (
+	resumeNone           internalResumeState = iota
+	resumeCommand                            // Read an IAC; but not what follows it.
+	resumeSubnegotiation                     // Read part of a subnegotiation (into 'payload').
+)
+
+// internalCommandHandler receives the TELNET commands that an internalDataReader finds
+// in the stream it is reading.
+type internalCommandHandler interface {
+	// handleCommand is called for any command, other than option negotiation and
+	// subnegotiation. (Ex: IAC AYT, IAC NOP, IAC GA.)
+	handleCommand(cmd byte)
+
+	// handleNegotiation is called for IAC WILL, IAC WONT, IAC DO, and IAC DONT.
+	handleNegotiation(verb byte, option byte)
+
+	// handleSubnegotiation is called for IAC SB ... IAC SE, with 'payload' being the
+	// (un-escaped) bytes between the option code and the IAC SE.
+	handleSubnegotiation(option byte, payload []byte)
 }
 
 // newDataReader creates a new DataReader reading from 'r'.
@@ -72,104 +134,473 @@ func newDataReader(r io.Reader) *internalDataReader {
 
 // Read reads the TELNET escaped data from the  wrapped io.Reader, and "un-escapes" it into 'data'.
 func (r *internalDataReader) Read(data []byte) (n int, err error) {
+	defer func() {
+		r.traffic.dataReceived(n)
+	}()
 
-	const IAC = 255
+	p := data
 
-	const SB = 250
-	const SE = 240
+	if len(p) <= 0 {
+		return 0, nil
+	}
 
-	const WILL = 251
-	const WONT = 252
-	const DO = 253
-	const DONT = 254
+	if r.eof {
+		return r.endOfFile(0)
+	}
 
-	p := data
+	// Pick up where a TELNET command that was cut short (by an error, such as a timeout) left off.
+	switch resume := r.resume; resume {
+	case resumeCommand, resumeSubnegotiation:
+		r.resume = resumeNone
 
-	log.Printf("data_reader.go: Read()")
-	log.Printf("length of data = %v", len(data))
-	log.Print("data_reader.go: p = %v", p)
+		var m int
+		if resumeCommand == resume {
+			m, err = r.command(p)
+		} else {
+			err = r.subnegotiation()
+		}
+		n += m
+		p = p[m:]
+		if nil != err || r.prompted {
+			return n, err
+		}
+		if r.eof {
+			return r.endOfFile(n)
+		}
+	}
 
 	for {
 		var b byte
 
+		if 0 < n && r.keepOrder {
+			// (This does not block, as there is something buffered; see the bottom of the loop.)
+			if peeked, _ := r.buffered.Peek(1); 0 < len(peeked) && IAC == peeked[0] {
+				return n, nil
+			}
+		}
+
+		// (A run of data, up to the next IAC, that is already buffered is copied in one go.)
+		if buffered := r.buffered.Buffered(); 0 < buffered {
+			run, _ := r.buffered.Peek(buffered)
+			if i := indexIAC(run); 0 <= i {
+				run = run[:i]
+			}
+
+			if 0 < len(run) {
+				k := copy(p, run)
+				r.buffered.Discard(k)
+				n += k
+				p = p[k:]
+
+				if len(p) <= 0 || r.buffered.Buffered() <= 0 {
+					return n, nil
+				}
+				continue
+			}
+		}
+
 		b, err = r.buffered.ReadByte()
 		if nil != err {
 			return n, err
 		}
 
 		if IAC == b {
-			var peeked []byte
-
-			peeked, err = r.buffered.Peek(1)
+			var m int
+			m, err = r.command(p)
+			n += m
+			p = p[m:]
 			if nil != err {
 				return n, err
 			}
 
-			switch peeked[0] {
+			if r.prompted {
+				return n, nil
+			}
+			if r.eof {
+				return r.endOfFile(n)
+			}
+		} else {
+
+			p[0] = b
+			n++
+			p = p[1:]
+		}
+
+		// Return what we have once 'data' is full, or once we would otherwise
+		// have to block waiting for more bytes to arrive.
+		if len(p) <= 0 || (n > 0 && r.buffered.Buffered() <= 0) {
+			return n, nil
+		}
+	}
+
+}
+
+// command handles what comes after an IAC (which has already been read). If it is an escaped
+// IAC, then the IAC is put into 'p', and 1 is returned.
+//
+// If reading fails part way through, then the next Read picks up where it left off.
+func (r *internalDataReader) command(p []byte) (int, error) {
+
+	peeked, err := r.buffered.Peek(1)
+	if nil != err {
+		r.resume = resumeCommand
+		return 0, truncatedErr(err)
+	}
+
+	switch peeked[0] {
+	case WILL, WONT, DO, DONT:
+		verbAndOption, err := r.buffered.Peek(2)
+		if nil != err {
+			r.resume = resumeCommand
+			return 0, truncatedErr(err)
+		}
+		verb, option := verbAndOption[0], verbAndOption[1]
+
+		if _, err := r.buffered.Discard(2); nil != err {
+			return 0, err
+		}
+
+		if nil != r.handler {
+			r.handler.handleNegotiation(verb, option)
+		}
+	case IAC:
+		p[0] = IAC
+
+		if _, err := r.buffered.Discard(1); nil != err {
+			return 0, err
+		}
+		return 1, nil
+	case SB:
+		if _, err := r.buffered.Discard(1); nil != err {
+			return 0, err
+		}
+
+		r.payload = nil
+		return 0, r.subnegotiation()
+	case SE:
+		if _, err := r.buffered.Discard(1); nil != err {
+			return 0, err
+		}
+		if !r.lenient.Load() {
+			return 0, protocolErr("IAC SE outside of a subnegotiation")
+		}
+	case NOP, DM, BRK, IP, AO, AYT, EC, EL, GA, EOR, EOF, SUSP, ABORT:
+		cmd := peeked[0]
+
+		if _, err := r.buffered.Discard(1); nil != err {
+			return 0, err
+		}
+
+		if nil != r.handler {
+			r.handler.handleCommand(cmd)
+		}
+		if nil != r.isPrompt && r.isPrompt(cmd) {
+			r.prompted = true
+		}
+		if EOF == cmd && r.eofCommand.Load() {
+			r.eof = true
+		}
+	default:
+		// If we get in here, this is not following the TELNET protocol.
+		if r.lenient.Load() {
+			// (The IAC is taken to be data; as is what follows it, which is left to be read.)
+			p[0] = IAC
+			return 1, nil
+		}
+
+		cmd := peeked[0]
+		if _, err := r.buffered.Discard(1); nil != err {
+			return 0, err
+		}
+		return 0, protocolErr("IAC followed by 0x%02x", cmd)
+	}
+
+	return 0, nil
+}
+
+// subnegotiation reads (the rest of) a subnegotiation; and passes it on to the handler.
+//
+// If reading fails part way through, then the next Read picks up where it left off.
+func (r *internalDataReader) subnegotiation() error {
+
+	payload, err := r.readSubnegotiation()
+	if ErrSubnegotiationTooLong == err {
+		return err
+	}
+	if nil != err {
+		r.resume = resumeSubnegotiation
+		return truncatedErr(err)
+	}
+
+	if nil != r.limiter {
+		if err := r.limiter.subnegotiation(); nil != err {
+			return err
+		}
+	}
+
+	if 0 == len(payload) {
+		if r.lenient.Load() {
+			return nil
+		}
+		return protocolErr("empty subnegotiation")
+	}
+
+	if nil != r.handler {
+		r.handler.handleSubnegotiation(payload[0], payload[1:])
+	}
+
+	return nil
+}
+
+// endOfFile returns what Read returns once it has read an IAC EOF (see eofCommand); with 'n'
+// being how much data came before it. That data is returned first; and then (by the next Read,
+// if there was any) errEOFCommand.
+func (r *internalDataReader) endOfFile(n int) (int, error) {
+	if 0 < n {
+		return n, nil
+	}
+
+	r.eof = false
+	return 0, errEOFCommand
+}
+
+// promptSeen returns (and forgets) whether a prompt (see isPrompt) was just read.
+func (r *internalDataReader) promptSeen() bool {
+	prompted := r.prompted
+	r.prompted = false
+
+	return prompted
+}
+
+// readSubnegotiation reads everything after an IAC SB, up to and including the IAC SE,
+// and returns what was between them (un-escaped).
+//
+// So, for example, for:
+//
+//	IAC SB TERMINAL-TYPE IS 'V' 'T' '5' '2' IAC SE
+//
+// ... it would return:
+//
+//	TERMINAL-TYPE IS 'V' 'T' '5' '2'
+//
+// What has been read so far is kept (in 'payload'); so that, if reading fails part way through,
+// it can be called again to pick up where it left off. (Which is also how it goes on after a
+// protocol violation; see ParseMode.)
+//
+// If what has been read gets longer than the MaxSubnegotiationLength of the InputLimits, then it is
+// thrown away; and it returns ErrSubnegotiationTooLong.
+func (r *internalDataReader) readSubnegotiation() ([]byte, error) {
+
+	max := r.limiter.maxSubnegotiationLength()
+
+	for {
+		if 0 < max && max < len(r.payload) {
+			r.payload = nil
+			return nil, r.limiter.subnegotiationTooLong(max)
+		}
+
+		peeked, err := r.buffered.Peek(1)
+		if nil != err {
+			return nil, err
+		}
+
+		if IAC != peeked[0] {
+			r.payload = append(r.payload, peeked[0])
+			if _, err := r.buffered.Discard(1); nil != err {
+				return nil, err
+			}
+			continue
+		}
+
+		peeked, err = r.buffered.Peek(2)
+		if nil != err {
+			return nil, err
+		}
+
+		if _, err := r.buffered.Discard(2); nil != err {
+			return nil, err
+		}
+
+		switch cmd := peeked[1]; cmd {
+		case IAC:
+			r.payload = append(r.payload, IAC)
+		case SE:
+			payload := r.payload
+			r.payload = nil
+			return payload, nil
+		case SB:
+			// (The peer never sent the IAC SE of this one; so it is thrown away, and the one that
+			// cut it short is read instead.)
+			r.payload = nil
+			if !r.lenient.Load() {
+				return nil, protocolErr("subnegotiation cut short by IAC SB")
+			}
+		default:
+			if !r.lenient.Load() {
+				return nil, protocolErr("IAC followed by 0x%02x in a subnegotiation", cmd)
+			}
+		}
+	}
+}
+
+// bufferedData returns how many bytes of data Read could return from what is (already) buffered;
+// i.e., without reading from the wrapped reader. (It does not handle any of the TELNET commands
+// it goes over; Read does that, later.)
+//
+// It stops at the first TELNET command that has not all been buffered yet.
+func (r *internalDataReader) bufferedData() int {
+
+	raw, _ := r.buffered.Peek(r.buffered.Buffered())
+
+	afterIAC := resumeCommand == r.resume
+	inSubnegotiation := resumeSubnegotiation == r.resume
+
+	n := 0
+	for i := 0; i < len(raw); {
+		b := raw[i]
+
+		switch {
+		case inSubnegotiation:
+			if IAC != b {
+				i++
+				continue
+			}
+			if len(raw) <= i+1 {
+				return n
+			}
+			if SE == raw[i+1] {
+				inSubnegotiation = false
+			}
+			i += 2
+		case afterIAC:
+			afterIAC = false
+
+			switch b {
 			case WILL, WONT, DO, DONT:
-				_, err = r.buffered.Discard(2)
-				if nil != err {
-					return n, err
+				if len(raw) <= i+1 {
+					return n
 				}
+				i += 2
 			case IAC:
-				p[0] = IAC
 				n++
-				p = p[1:]
-
-				_, err = r.buffered.Discard(1)
-				if nil != err {
-					return n, err
-				}
+				i++
 			case SB:
-				for {
-					var b2 byte
-					b2, err = r.buffered.ReadByte()
-					if nil != err {
-						return n, err
-					}
-
-					if IAC == b2 {
-						peeked, err = r.buffered.Peek(1)
-						if nil != err {
-							return n, err
-						}
-
-						if IAC == peeked[0] {
-							_, err = r.buffered.Discard(1)
-							if nil != err {
-								return n, err
-							}
-						}
-
-						if SE == peeked[0] {
-							_, err = r.buffered.Discard(1)
-							if nil != err {
-								return n, err
-							}
-							break
-						}
-					}
-				}
-			case SE:
-				_, err = r.buffered.Discard(1)
-				if nil != err {
-					return n, err
+				inSubnegotiation = true
+				i++
+			case SE, NOP, DM, BRK, IP, AO, AYT, EC, EL, GA, EOR, SUSP, ABORT:
+				i++
+			case EOF:
+				if r.eofCommand.Load() {
+					return n
 				}
+				i++
 			default:
-				// If we get in here, this is not following the TELNET protocol.
-				//@TODO: Make a better error.
-				err = errCorrupted
-				return n, err
+				if !r.lenient.Load() {
+					// (Read would fail here.)
+					return n
+				}
+				// (The IAC is data.)
+				n++
 			}
-		} else {
-
-			p[0] = b
+		case IAC == b:
+			afterIAC = true
+			i++
+		default:
 			n++
-			p = p[1:]
-		}
-		if n > 0 {
-			return n, nil
+			i++
 		}
 	}
 
+	return n
+}
+
+// A DataReader reads the TELNET data from an io.Reader (such as a net.Conn); i.e., "un-escapes" it
+// (each IAC IAC becomes a single byte 255), and filters out the TELNET commands (such as IAC NOP),
+// option negotiations (such as IAC DO ECHO), and subnegotiations (IAC SB ... IAC SE). This is for
+// when a TELNET stream is to be treated as a clean byte stream; without the option negotiation
+// that a Conn does. For example:
+//
+//	r := telnet.NewDataReader(c)
+//
+//	io.Copy(os.Stdout, r)
+//
+// The commands are silently consumed; unless they are routed elsewhere, with OnCommand,
+// OnNegotiation, and OnSubnegotiation. For example:
+//
+//	r.OnNegotiation(func(verb byte, option byte) {
+//		//@TODO: Keep track of what the peer asks for.
+//	})
+//
+// (A DataReader is not safe for concurrent use; nor are the On... methods to be called while it is
+// being read from.)
+type DataReader struct {
+	reader  *internalDataReader
+	handler internalDataHandler
+}
+
+// NewDataReader returns a DataReader that reads (the TELNET data) from 'r'.
+func NewDataReader(r io.Reader) *DataReader {
+	reader := DataReader{
+		reader: newDataReader(r),
+	}
+	reader.reader.handler = &reader.handler
+
+	// (So that what the commands are routed to gets them in order with the data.)
+	reader.reader.keepOrder = true
+
+	return &reader
+}
+
+// Read reads the (un-escaped) TELNET data into 'p'.
+//
+// Read makes DataReader fit the io.Reader interface.
+func (reader *DataReader) Read(p []byte) (n int, err error) {
+	return reader.reader.Read(p)
+}
+
+// OnCommand registers 'fn' to be called for each TELNET command that is read (other than an option
+// negotiation, or a subnegotiation); such as IAC AYT, IAC NOP, or IAC GA. (It is called with the
+// command code; such as AYT.) Registering nil has them be silently consumed (again).
+func (reader *DataReader) OnCommand(fn func(cmd byte)) {
+	reader.handler.command = fn
+}
+
+// OnNegotiation registers 'fn' to be called for each option negotiation that is read; i.e., for
+// each IAC WILL, IAC WONT, IAC DO, and IAC DONT. (It is called with the verb, such as DO; and the
+// option code, such as OptEcho.) Registering nil has them be silently consumed (again).
+func (reader *DataReader) OnNegotiation(fn func(verb byte, option byte)) {
+	reader.handler.negotiation = fn
+}
+
+// OnSubnegotiation registers 'fn' to be called for each subnegotiation that is read; i.e., for
+// each IAC SB ... IAC SE. (It is called with the option code; and with the (un-escaped) bytes
+// between it and the IAC SE.) Registering nil has them be silently consumed (again).
+func (reader *DataReader) OnSubnegotiation(fn func(option byte, payload []byte)) {
+	reader.handler.subnegotiation = fn
+}
+
+// internalDataHandler is the internalCommandHandler of a DataReader; which passes the commands on
+// to what was registered (if anything).
+type internalDataHandler struct {
+	command        func(cmd byte)
+	negotiation    func(verb byte, option byte)
+	subnegotiation func(option byte, payload []byte)
+}
+
+func (handler *internalDataHandler) handleCommand(cmd byte) {
+	if fn := handler.command; nil != fn {
+		fn(cmd)
+	}
+}
+
+func (handler *internalDataHandler) handleNegotiation(verb byte, option byte) {
+	if fn := handler.negotiation; nil != fn {
+		fn(verb, option)
+	}
+}
+
+func (handler *internalDataHandler) handleSubnegotiation(option byte, payload []byte) {
+	if fn := handler.subnegotiation; nil != fn {
+		fn(option, payload)
+	}
 }
@@ -351,3 +351,82 @@ func TestDataReader(t *testing.T) {
 		}
 	}
 }
+
+func TestNewDataReader(t *testing.T) {
+
+	tests := []struct {
+		Bytes    []byte
+		Expected []byte
+		Routed   [][]byte
+	}{
+		{
+			Bytes:    []byte("apple banana cherry"),
+			Expected: []byte("apple banana cherry"),
+		},
+		{
+			Bytes:    []byte{'a', IAC, IAC, 'b'},
+			Expected: []byte{'a', 255, 'b'},
+		},
+		{
+			Bytes:    []byte{'a', IAC, NOP, 'b', IAC, AYT},
+			Expected: []byte("ab"),
+			Routed:   [][]byte{{NOP}, {AYT}},
+		},
+		{
+			Bytes:    []byte{IAC, DO, OptEcho, 'a', IAC, WILL, OptNAWS, 'b'},
+			Expected: []byte("ab"),
+			Routed:   [][]byte{{DO, OptEcho}, {WILL, OptNAWS}},
+		},
+		{
+			Bytes:    []byte{'a', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'b', IAC, SB, OptGMCP, 'x', IAC, IAC, IAC, SE},
+			Expected: []byte("ab"),
+			Routed:   [][]byte{{SB, OptNAWS, 0, 80, 0, 24}, {SB, OptGMCP, 'x', 255}},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var routed [][]byte
+
+		reader := NewDataReader(bytes.NewReader(test.Bytes))
+		reader.OnCommand(func(cmd byte) {
+			routed = append(routed, []byte{cmd})
+		})
+		reader.OnNegotiation(func(verb byte, option byte) {
+			routed = append(routed, []byte{verb, option})
+		})
+		reader.OnSubnegotiation(func(option byte, payload []byte) {
+			routed = append(routed, append([]byte{SB, option}, payload...))
+		})
+
+		data, err := io.ReadAll(reader)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := string(test.Expected), string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		if expected, actual := len(test.Routed), len(routed); expected != actual {
+			t.Errorf("For test #%d, expected %d commands, but actually got %d: %v", testNumber, expected, actual, routed)
+			continue
+		}
+		for i := range test.Routed {
+			if expected, actual := test.Routed[i], routed[i]; !bytes.Equal(expected, actual) {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		}
+
+		// (Without anything registered, the commands are silently consumed.)
+		data, err = io.ReadAll(NewDataReader(bytes.NewReader(test.Bytes)))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := string(test.Expected), string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package telnet
 
 import (
-	"bufio"
+	"bytes"
 	"io"
-	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // An internalDataWriter deals with "escaping" according to the TELNET (and TELNETS) protocol.
@@ -35,8 +37,42 @@ import (
 // (Notice that each "255" in the original byte array became 2 "255"s in a row.)
 //
 // internalDataWriter takes care of all this for you, so you do not have to do it.
+//
+// What is written to the wire is either data (with Write) or TELNET commands (with writeCommand);
+// and the commands go ahead of the data that is still waiting to be sent. (See internalCommandQueue.)
 type internalDataWriter struct {
-	wrapped *bufio.Writer
+	// mutex is held while data is being written (with Write, or writeEscaped); so that the data
+	// that is written is sent in the order it was written in, without being mixed together.
+	mutex sync.Mutex
+
+	// wireMutex is held while using 'wrapped' (and the 'coalescer', and timeout.deadline); which data
+	// is written into (and flushed) a buffer's worth at a time, so that the commands that are waiting
+	// to be sent (see internalCommandQueue) can be, in between. (It is never locked before 'mutex'.)
+	wireMutex sync.Mutex
+	wrapped   *internalWireBuffer
+
+	// commands are the TELNET commands that are waiting to be sent.
+	commands *internalCommandQueue
+
+	// limiter limits how fast the (escaped) data is written. (See Conn.SetOutputRateLimit.)
+	limiter *internalRateLimiter
+
+	// pending is how many (escaped) bytes are waiting to be written.
+	pending atomic.Int64
+
+	// traffic is told how much data is written. (See TrafficMetrics.)
+	traffic *internalTraffic
+
+	// timeout (if not nil) is what 'wrapped' flushes to; which has that time out. (See
+	// Conn.SetWriteTimeout.)
+	timeout *internalWriteTimeout
+
+	// coalescer (if it is on) holds off flushing, so that a burst of writes is sent together.
+	// (See Conn.SetWriteCoalescing.)
+	coalescer internalCoalescer
+
+	// highWater holds off writes while too much is pending. (See OutputPolicy.HighWaterMark.)
+	highWater internalHighWater
 }
 
 // newDataWriter creates a new internalDataWriter writing to 'w'.
@@ -58,57 +94,236 @@ type internalDataWriter struct {
 //
 // *internalDataWriter takes care of all this for you, so you do not have to do it.
 func newDataWriter(w io.Writer) *internalDataWriter {
-	b := bufio.NewWriter(w)
-	return &internalDataWriter{wrapped: b}
+	b := newWireBuffer(w, defaultWriteBufferSize)
+	return &internalDataWriter{wrapped: b, commands: newCommandQueue(), limiter: newRateLimiter()}
 }
 
-// Write writes the TELNET (and TELNETS) escaped data for of the data in 'data' to the wrapped io.Writer.
+// Write writes the TELNET (and TELNETS) escaped data for of the data in 'data' to the wrapped io.Writer;
+// as fast as the rate limit allows.
+//
+// It flushes once it is done (unless the coalescer holds that off; see Conn.SetWriteCoalescing, and
+// OutputPolicy). This is what a Conn writes with; and, like a net.Conn, what a Write to a Conn writes
+// is sent by the time it returns. (The DataWriter, which is for writing a TELNET stream in bulk,
+// leaves flushing to its Flush instead; see writeChunk.)
 func (w *internalDataWriter) Write(data []byte) (n int, err error) {
+	return w.writeChunk(data, true)
+}
 
-	// loop through the data, looking for IACs
-	// if we find one, write another one
-	// flush the buffer
-
-	var n_total int = 0
-	log.Printf("Length: %v, Data: %v", len(data), data)
-	for i := 0; i < len(data); i++ {
-		if data[i] == 255 {
-			log.Printf("Found IAC at %d", i)
-			// we found an IAC
-			// write the buffer up to this point
-			// write the IAC
-			n, e := w.wrapped.Write(data[:i])
-			n_total += n
-			if e != nil {
-				log.Printf("Flushing")
-				w.wrapped.Flush()
-				return n_total, e
-			}
-			e = w.wrapped.WriteByte(255)
-			if e != nil {
-				return n_total, e
-			}
-			log.Printf("Flushing")
-			w.wrapped.Flush()
-			n_total += 1
-			e = w.wrapped.WriteByte(255)
-			if e != nil {
-				log.Printf("Flushing")
-				w.wrapped.Flush()
-				return n_total, e
+// writeChunk is Write; but (if 'flush' is false) leaves what is still buffered, once it is done, to
+// be sent by the next flush (or once the buffer fills up). (See copyChunks.)
+func (w *internalDataWriter) writeChunk(data []byte, flush bool) (n int, err error) {
+
+	remaining := escapedLen(data)
+	w.pending.Add(int64(remaining))
+	defer func() {
+		w.pending.Add(-int64(remaining))
+		w.highWater.drain()
+	}()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	defer func() {
+		w.traffic.dataSent(n)
+	}()
+
+	for 0 < len(data) {
+		var k int
+		cost, err := w.limiter.take(remaining, time.Time{}, false, func(available int) int {
+			if available < 0 {
+				// (There is no limit; so all of it fits. And 'remaining' is what it is, escaped.)
+				k = len(data)
+				return remaining
 			}
-			data = data[i+1:]
-			i = 0
+
+			var cost int
+			k, cost = fitEscaped(data, available)
+			return cost
+		})
+		if nil != err {
+			return n, err
+		}
+
+		m, err := w.write(data[:k], flush)
+		n += m
+		remaining -= cost
+		w.pending.Add(-int64(cost))
+		w.highWater.drain()
+		if nil != err {
+			return n, err
+		}
+
+		data = data[k:]
+	}
+
+	return n, nil
+}
+
+// escapedLen returns how many bytes 'data' is, once escaped.
+func escapedLen(data []byte) int {
+	return len(data) + bytes.Count(data, []byte{IAC})
+}
+
+// fitEscaped returns how many bytes (from the start) of 'data' fit in 'available' bytes, once
+// escaped; and how many bytes that is, once escaped. (If 'available' is negative, then all
+// of 'data' fits.)
+func fitEscaped(data []byte, available int) (int, int) {
+	if available < 0 {
+		return len(data), escapedLen(data)
+	}
+
+	var cost int
+	for k, b := range data {
+		size := 1
+		if IAC == b {
+			size = 2
+		}
+		if available < cost+size {
+			return k, cost
 		}
+		cost += size
 	}
-	n, e := w.wrapped.Write(data)
-	n_total += n
-	if e != nil {
-		log.Printf("Flushing")
-		w.wrapped.Flush()
-		return n_total, e
+
+	return len(data), cost
+}
+
+// write writes the TELNET (and TELNETS) escaped data for of the data in 'data' to the wrapped io.Writer.
+// The mutex must be held. (The wireMutex is locked for each buffer's worth of it; so that the commands
+// that are waiting to be sent go out in between.)
+//
+// It returns how many bytes of 'data' were taken; i.e., were sent, or are still buffered (to be
+// sent by the next flush), even if it returns an error. (See Conn.Unflushed.) If 'flush' is false,
+// then what is left in the buffer is not flushed (or handed to the coalescer).
+func (w *internalDataWriter) write(data []byte, flush bool) (n int, err error) {
+
+	for {
+		w.wireMutex.Lock()
+		k := w.wrapped.writeData(data[n:])
+		n += k
+		if len(data) <= n {
+			break
+		}
+
+		// (The buffer is full.)
+		err := w.wrapped.Flush()
+		w.unlockWire()
+		if nil != err {
+			return n, err
+		}
+	}
+
+	if flush {
+		err = w.written()
 	}
-	log.Printf("Flushing")
-	w.wrapped.Flush()
-	return n_total, nil
+	w.unlockWire()
+	if nil != err {
+		return n, err
+	}
+	return n, nil
+}
+
+// flush writes whatever is (still) buffered to the wrapped io.Writer; waiting for whatever is being
+// written right now to be done first.
+func (w *internalDataWriter) flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.wireMutex.Lock()
+	defer w.unlockWire()
+
+	w.coalescer.stop()
+	return w.wrapped.Flush()
+}
+
+// flushBuffered writes whatever is (still) buffered to the wrapped io.Writer; unless something is
+// being written right now (which will flush once it is done), in which case it does not wait for it.
+func (w *internalDataWriter) flushBuffered() error {
+	if !w.mutex.TryLock() {
+		return nil
+	}
+	defer w.mutex.Unlock()
+
+	if !w.wireMutex.TryLock() {
+		return nil
+	}
+	defer w.unlockWire()
+
+	w.coalescer.stop()
+	if w.wrapped.Buffered() <= 0 {
+		return nil
+	}
+	return w.wrapped.Flush()
+}
+
+// writeCommand writes 'p' to the wrapped io.Writer as-is (i.e., without any escaping),
+// and then flushes.
+//
+// This is used for sending TELNET commands, such as IAC AYT, which must NOT be escaped.
+//
+// 'p' is sent after whatever (data) is buffered; but ahead of the data that is still waiting to be
+// buffered (such as the rest of a large Write, to a slow peer). (See internalCommandQueue.)
+func (w *internalDataWriter) writeCommand(p []byte) error {
+	return w.writeCommandThen(p, nil)
+}
+
+// writeCommandThen is writeCommand; but also calls 'then' (with the wireMutex held) once 'p' has
+// been sent, before anything after it is. (If sending 'p' fails, then 'then' is not called.)
+func (w *internalDataWriter) writeCommandThen(p []byte, then func()) error {
+	command := w.commands.push(p, then)
+	w.sendCommands()
+
+	return <-command.sent
+}
+
+// A DataWriter writes TELNET data to an io.Writer (such as a net.Conn); i.e., "escapes" it (each
+// byte 255 becomes IAC IAC). This is the counterpart of DataReader; for when a TELNET stream is
+// to be written to as a clean byte stream, without the option negotiation that a Conn does. For
+// example:
+//
+//	w := telnet.NewDataWriter(c)
+//
+//	io.Copy(w, file)
+//
+// What is written is buffered; and is only sent once Flush is called (or once the buffer fills up,
+// or a command is written with WriteCommand). So, for example:
+//
+//	w.Write(header)
+//	w.Write(body)
+//
+//	if err := w.Flush(); nil != err {
+//		return err
+//	}
+//
+// ... sends the header and the body together. (ReadFrom flushes on its own; see ReadFrom.)
+//
+// A DataWriter is safe for concurrent use; what each Write writes is sent together.
+type DataWriter struct {
+	writer *internalDataWriter
+}
+
+// NewDataWriter returns a DataWriter that writes (the TELNET data) to 'w'.
+func NewDataWriter(w io.Writer) *DataWriter {
+	return &DataWriter{
+		writer: newDataWriter(w),
+	}
+}
+
+// Write writes 'p' (escaped) as TELNET data; into the buffer, which is sent by the next Flush. 'n' is
+// how many bytes of 'p' were taken; which is len(p), unless it returns an error.
+//
+// Write makes DataWriter fit the io.Writer interface.
+func (writer *DataWriter) Write(p []byte) (n int, err error) {
+	return writer.writer.writeChunk(p, false)
+}
+
+// WriteCommand writes 'p' as-is (i.e., without any escaping); such as for sending a TELNET command.
+// For example:
+//
+//	w.WriteCommand([]byte{telnet.IAC, telnet.NOP})
+func (writer *DataWriter) WriteCommand(p []byte) error {
+	return writer.writer.writeCommand(p)
+}
+
+// Flush sends whatever is still buffered; i.e., what Write took, but did not (yet) send.
+func (writer *DataWriter) Flush() error {
+	return writer.writer.flush()
 }
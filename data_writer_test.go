@@ -3,6 +3,7 @@ package telnet
 
 import (
 	"bytes"
+	"io"
 
 	"testing"
 )
@@ -114,3 +115,132 @@ func TestDataWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestNewDataWriter(t *testing.T) {
+
+	tests := []struct {
+		Bytes    []byte
+		Expected []byte
+	}{
+		{
+			Bytes:    []byte("apple banana cherry"),
+			Expected: []byte("apple banana cherry"),
+		},
+		{
+			Bytes:    []byte{'a', 255, 'b', 255},
+			Expected: []byte{'a', IAC, IAC, 'b', IAC, IAC},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		writer := NewDataWriter(&buffer)
+
+		n, err := writer.Write(test.Bytes)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := len(test.Bytes), n; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+		}
+
+		if err := writer.WriteCommand([]byte{IAC, NOP}); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if err := writer.Flush(); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := append(test.Expected, IAC, NOP), buffer.Bytes(); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+
+		// (What a DataReader reads back is what was written.)
+		data, err := io.ReadAll(NewDataReader(&buffer))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := string(test.Bytes), string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+// testPayload returns 'size' bytes of (printable) data; with every 'every'-th byte an IAC (255),
+// or none if 'every' is zero.
+func testPayload(size int, every int) []byte {
+	p := make([]byte, size)
+	for i := range p {
+		p[i] = 'a' + byte(i%26)
+		if 0 < every && 0 == i%every {
+			p[i] = IAC
+		}
+	}
+	return p
+}
+
+func benchmarkDataWriter(b *testing.B, every int) {
+
+	p := testPayload(32*1024, every)
+	writer := NewDataWriter(io.Discard)
+
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := writer.Write(p); nil != err {
+			b.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+}
+
+// BenchmarkDataWriterText writes data that has no IACs in it.
+func BenchmarkDataWriterText(b *testing.B) {
+	benchmarkDataWriter(b, 0)
+}
+
+// BenchmarkDataWriterSomeIACs writes data that has an IAC every 64 bytes.
+func BenchmarkDataWriterSomeIACs(b *testing.B) {
+	benchmarkDataWriter(b, 64)
+}
+
+// BenchmarkDataWriterIACDense writes data that has an IAC every 2 bytes.
+func BenchmarkDataWriterIACDense(b *testing.B) {
+	benchmarkDataWriter(b, 2)
+}
+
+// BenchmarkDataWriterAllIACs writes data that is nothing but IACs.
+func BenchmarkDataWriterAllIACs(b *testing.B) {
+	benchmarkDataWriter(b, 1)
+}
+
+func TestDataWriterFlush(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	writer := NewDataWriter(&buffer)
+
+	for _, p := range [][]byte{[]byte("apple "), {255}, []byte(" banana")} {
+		if _, err := writer.Write(p); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+
+	// (Nothing is sent until it is flushed.)
+	if expected, actual := "", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if err := writer.Flush(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "apple \xff\xff banana", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
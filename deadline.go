@@ -0,0 +1,196 @@
+package telnet
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// SetReadDeadline sets the deadline for reading from the Conn; after which a Read (that has not
+// yet returned, or that is called after it) gives up, and returns a timeout error (one that
+// os.IsTimeout is true for, as with a net.Conn). This is so that a peer that stops sending cannot
+// block a Read forever. For example:
+//
+//	conn.SetReadDeadline(time.Now().Add(30*time.Second))
+//
+// The zero time means no deadline.
+//
+// (It needs the underlying connection to support read deadlines; which TCP, and TLS, connections
+// do. If it does not, then it does nothing. See also ReadContext.)
+func (clientConn *Conn) SetReadDeadline(t time.Time) error {
+	clientConn.deadlineMutex.Lock()
+	clientConn.readDeadline = t
+	clientConn.deadlineMutex.Unlock()
+	clientConn.inputThrottle.limiter.setDeadline(t)
+
+	deadliner, ok := clientConn.conn.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return nil
+	}
+
+	return deadliner.SetReadDeadline(t)
+}
+
+// SetDeadline sets both the read deadline (see SetReadDeadline), and the write deadline (see
+// SetWriteDeadline); as with a net.Conn.
+func (clientConn *Conn) SetDeadline(t time.Time) error {
+	if err := clientConn.SetReadDeadline(t); nil != err {
+		return err
+	}
+
+	return clientConn.SetWriteDeadline(t)
+}
+
+// ReadContext is like Read; except that it gives up once 'ctx' is done, and then returns the error
+// of 'ctx'. For example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+//	defer cancel()
+//
+//	n, err := conn.ReadContext(ctx, p)
+//
+// (The read deadline, if there is one (see SetReadDeadline), still applies. Cancelling 'ctx'
+// needs the underlying connection to support read deadlines.)
+func (clientConn *Conn) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+
+	deadliner, ok := clientConn.conn.(interface{ SetReadDeadline(time.Time) error })
+	if !ok {
+		return clientConn.Read(p)
+	}
+
+	var cancel internalDeadlineCanceller
+	stop := cancel.watch(ctx, deadliner.SetReadDeadline, clientConn.currentReadDeadline())
+	n, err = clientConn.Read(p)
+	stop()
+
+	if nil != err && cancel.cancelled() && isTimeout(err) {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// WriteContext is like Write; except that it gives up once 'ctx' is done, and then returns the
+// error of 'ctx'. (Including while it waits on the rate limit; see SetOutputRateLimit.) As with
+// Write, 'n' is how many bytes of 'p' were taken; some of which may still be buffered, if it gave
+// up (to be sent by the next Flush).
+//
+// (The write deadline, if there is one (see SetWriteDeadline), still applies. Cancelling 'ctx'
+// while the peer is receiving what was written needs the underlying connection to support write
+// deadlines.)
+func (clientConn *Conn) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+
+	var cancel internalDeadlineCanceller
+	stop := cancel.watch(ctx, clientConn.SetWriteDeadline, clientConn.dataWriter.limiter.writeDeadline())
+	n, err = clientConn.Write(p)
+	stop()
+
+	if nil != err && cancel.cancelled() && isTimeout(err) {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// currentReadDeadline returns the read deadline; as set by SetReadDeadline.
+func (clientConn *Conn) currentReadDeadline() time.Time {
+	clientConn.deadlineMutex.Lock()
+	defer clientConn.deadlineMutex.Unlock()
+
+	return clientConn.readDeadline
+}
+
+// isTimeout returns whether 'err' is a timeout error (such as from a deadline passing).
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// internalDeadlineCanceller sets a deadline (the read deadline, or the write deadline) of a
+// connection; to (also) stop a Read (or Write) that is waiting, once a context is done.
+type internalDeadlineCanceller struct {
+	mutex   sync.Mutex
+	set     func(time.Time) error
+	done    bool
+	stopped bool
+
+	// restore is the deadline that was set before; which is what is set again once done. (And
+	// which no deadline that setDeadline sets goes past.)
+	restore time.Time
+}
+
+// watch (in the background) sets the deadline (with 'set') to the past, once 'ctx' is done; until
+// the returned function is called, which sets the deadline back to 'restore'. (The goroutine it
+// starts has exited by the time the returned function returns.)
+func (canceller *internalDeadlineCanceller) watch(ctx context.Context, set func(time.Time) error, restore time.Time) func() {
+	canceller.set = set
+	canceller.restore = restore
+
+	stop := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+		case <-stop:
+			return
+		}
+
+		canceller.mutex.Lock()
+		defer canceller.mutex.Unlock()
+
+		if canceller.stopped {
+			return
+		}
+		canceller.done = true
+		set(time.Unix(1, 0))
+	}()
+
+	return func() {
+		close(stop)
+		<-exited
+
+		canceller.mutex.Lock()
+		defer canceller.mutex.Unlock()
+
+		canceller.stopped = true
+		set(canceller.restore)
+	}
+}
+
+// setDeadline sets the deadline; unless the context is done. (The zero time is the deadline that
+// was set before; and so is any deadline after it.)
+func (canceller *internalDeadlineCanceller) setDeadline(deadline time.Time) {
+	canceller.mutex.Lock()
+	defer canceller.mutex.Unlock()
+
+	if canceller.done {
+		return
+	}
+	if deadline.IsZero() || (!canceller.restore.IsZero() && canceller.restore.Before(deadline)) {
+		deadline = canceller.restore
+	}
+	canceller.set(deadline)
+}
+
+// cancelled returns whether the context is done (and so the deadline was set to the past).
+func (canceller *internalDeadlineCanceller) cancelled() bool {
+	canceller.mutex.Lock()
+	defer canceller.mutex.Unlock()
+
+	return canceller.done
+}
+
+// expired returns whether the deadline that was set before has passed.
+func (canceller *internalDeadlineCanceller) expired() bool {
+	canceller.mutex.Lock()
+	defer canceller.mutex.Unlock()
+
+	return !canceller.restore.IsZero() && !time.Now().Before(canceller.restore)
+}
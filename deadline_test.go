@@ -0,0 +1,142 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"testing"
+)
+
+func TestConnReadContext(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	p := make([]byte, 16)
+	if _, err := conn.ReadContext(ctx, p); context.DeadlineExceeded != err {
+		t.Fatalf("Expected %v, but actually got: (%T) %v", context.DeadlineExceeded, err, err)
+	}
+
+	// (The Conn still works; and the read deadline was set back.)
+	go remote.Write([]byte("hello"))
+	n, err := conn.Read(p)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello", string(p[:n]); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnSetReadDeadline(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+
+	if err := conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	p := make([]byte, 16)
+	if _, err := conn.Read(p); !os.IsTimeout(err) {
+		t.Fatalf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+
+	// (ReadContext keeps to the read deadline; and leaves it as it was.)
+	if _, err := conn.ReadContext(context.Background(), p); !os.IsTimeout(err) {
+		t.Fatalf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+	if _, err := conn.Read(p); !os.IsTimeout(err) {
+		t.Fatalf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	go remote.Write([]byte("hello"))
+	n, err := conn.Read(p)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello", string(p[:n]); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWriteContext(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	conn.SetWriteTimeout(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// (Nothing reads from 'remote'; so what was taken is still buffered.)
+	n, err := conn.WriteContext(ctx, []byte("hello"))
+	if context.DeadlineExceeded != err {
+		t.Fatalf("Expected %v, but actually got: (%T) %v", context.DeadlineExceeded, err, err)
+	}
+	if expected, actual := 5, n; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if conn.isClosed() {
+		t.Fatalf("Expected the Conn to not be closed, but actually it was.")
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, 5)
+		remote.SetReadDeadline(time.Now().Add(3 * time.Second))
+		io.ReadFull(remote, p)
+		received <- p
+	}()
+
+	if err := conn.Flush(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello", string(<-received); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+
+	listener := testListen(t, func(c net.Conn) {
+		c.Write([]byte("hello"))
+		io.Copy(io.Discard, c)
+	})
+
+	conn, err := DialContext(context.Background(), "tcp4", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	p := make([]byte, 5)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DialContext(ctx, "tcp", listener.Addr().String()); nil == err {
+		t.Errorf("Expected an error (as the context was cancelled), but actually did not get one.")
+	}
+}
@@ -0,0 +1,22 @@
+package telnet
+
+// Direction is which way something went over a connection: from the peer to us (Inbound),
+// or from us to the peer (Outbound).
+type Direction int
+
+const (
+	Inbound  Direction = iota // Received from the peer.
+	Outbound                  // Sent to the peer.
+)
+
+// String returns "inbound" or "outbound".
+func (direction Direction) String() string {
+	switch direction {
+	case Inbound:
+		return "inbound"
+	case Outbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,126 @@
+package telnet
+
+import (
+	"bufio"
+	"context"
+)
+
+// Done returns a channel that is closed once the connection has been torn down; i.e., once it
+// has been closed (see Close), every goroutine that it started has exited (such as the one
+// reading, for CloseGracefully, or the one writing to it, for a Broadcaster), and its buffers
+// have been released. This is for synchronizing cleanup. For example:
+//
+//	conn.Close()
+//	<-conn.Done()
+//
+// (Done is closed even if the connection is closed by something other than Close; such as by
+// the peer, once something has noticed, and then called Close.)
+func (clientConn *Conn) Done() <-chan struct{} {
+	return clientConn.tornDown
+}
+
+// withContext returns a context (from 'ctx') that is cancelled once the connection drops (or is
+// closed); and has the connection closed once the context is cancelled. (The CancelFunc has to be
+// called, once done with the context.)
+func (clientConn *Conn) withContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	clientConn.spawn(func() {
+		select {
+		case <-ctx.Done():
+			clientConn.Close()
+		case <-clientConn.peerClosed:
+			cancel()
+		case <-clientConn.done:
+			cancel()
+		}
+	})
+
+	return ctx, cancel
+}
+
+// spawn runs 'fn' in a goroutine; which the connection is not torn down (see Done) until it has
+// returned. ('fn' has to return (promptly) once the Conn is closed; i.e., once 'done' is closed.)
+func (clientConn *Conn) spawn(fn func()) {
+	clientConn.goroutineMutex.Lock()
+	clientConn.goroutines++
+	clientConn.goroutineMutex.Unlock()
+
+	go func() {
+		defer clientConn.exited()
+
+		fn()
+	}()
+}
+
+// exited is called once a goroutine (that spawn started) has returned.
+func (clientConn *Conn) exited() {
+	clientConn.goroutineMutex.Lock()
+	defer clientConn.goroutineMutex.Unlock()
+
+	clientConn.goroutines--
+	if 0 == clientConn.goroutines && nil != clientConn.goroutinesExited {
+		close(clientConn.goroutinesExited)
+		clientConn.goroutinesExited = nil
+	}
+}
+
+// tearDown is run (in a goroutine of its own) once the Conn has been closed. It waits for the
+// goroutines (that spawn started) to exit; releases the buffers; and then closes 'tornDown'.
+func (clientConn *Conn) tearDown() {
+	defer close(clientConn.tornDown)
+
+	clientConn.goroutineMutex.Lock()
+	if 0 < clientConn.goroutines {
+		exited := make(chan struct{})
+		clientConn.goroutinesExited = exited
+		clientConn.goroutineMutex.Unlock()
+
+		<-exited
+	} else {
+		clientConn.goroutineMutex.Unlock()
+	}
+
+	// (Whatever is reading, or writing, returns (promptly) now that the connection is closed.
+	// And anything that reads, or writes, once the Conn is closed, does not use the buffers.)
+	clientConn.readMutex.Lock()
+	clientConn.peeked = nil
+	clientConn.textPending = nil
+	clientConn.transcoded = nil
+	clientConn.filtered = nil
+	clientConn.dataReader.payload = nil
+	clientConn.dataReader.buffered = bufio.NewReaderSize(internalClosedConn{}, 16)
+	clientConn.readMutex.Unlock()
+
+	clientConn.promptMutex.Lock()
+	clientConn.promptData = nil
+	clientConn.promptMutex.Unlock()
+
+	clientConn.transcodeMutex.Lock()
+	clientConn.untranscoded = nil
+	clientConn.transcodeMutex.Unlock()
+
+	w := clientConn.dataWriter
+	w.mutex.Lock()
+	w.wireMutex.Lock()
+	w.coalescer.stop()
+	w.wrapped.reset(internalClosedConn{}, 1)
+	w.unlockWire()
+	w.mutex.Unlock()
+
+	if fn := clientConn.onTornDown; nil != fn {
+		fn(clientConn.CloseReason())
+	}
+}
+
+// internalClosedConn is what the buffers read from (and write to) once the connection has been
+// torn down.
+type internalClosedConn struct{}
+
+func (internalClosedConn) Read(p []byte) (int, error) {
+	return 0, ErrClosed
+}
+
+func (internalClosedConn) Write(p []byte) (int, error) {
+	return 0, ErrClosed
+}
@@ -0,0 +1,228 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestConnDone(t *testing.T) {
+
+	tests := []struct {
+		Name  string
+		Setup func(conn *Conn)
+	}{
+		{
+			Name:  "nothing",
+			Setup: func(conn *Conn) {},
+		},
+		{
+			Name: "broadcaster",
+			Setup: func(conn *Conn) {
+				var broadcaster Broadcaster
+				broadcaster.Add(conn)
+				broadcaster.Write([]byte("hello"))
+			},
+		},
+		{
+			Name: "rate limited write",
+			Setup: func(conn *Conn) {
+				conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 1})
+				go conn.Write([]byte(strings.Repeat("x", 100)))
+			},
+		},
+		{
+			Name: "close gracefully",
+			Setup: func(conn *Conn) {
+				// (The peer never closes its side; so this reads, in the background, until it gives up.)
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				defer cancel()
+				conn.CloseGracefully(ctx)
+			},
+		},
+		{
+			Name: "read until prompt",
+			Setup: func(conn *Conn) {
+				go conn.ReadUntilPrompt(context.Background())
+			},
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+		go io.Copy(io.Discard, remote)
+
+		conn := newConn(local, nil)
+		test.Setup(conn)
+		time.Sleep(10 * time.Millisecond)
+
+		select {
+		case <-conn.Done():
+			if !conn.isClosed() {
+				t.Errorf("For test #%d (%s), did not expect Done to be closed before the Conn was.", testNumber, test.Name)
+			}
+		default:
+		}
+
+		conn.Close()
+
+		select {
+		case <-conn.Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("For test #%d (%s), timed out waiting for Done to be closed.", testNumber, test.Name)
+		}
+
+		remote.Close()
+	}
+}
+
+func TestConnNoGoroutineLeaks(t *testing.T) {
+
+	baseline := runtime.NumGoroutine()
+
+	var broadcaster Broadcaster
+
+	for i := 0; i < 1000; i++ {
+		local, remote := net.Pipe()
+		go io.Copy(io.Discard, remote)
+
+		conn := newConn(local, nil)
+		conn.SetWriteCoalescing(time.Second, 0)
+		broadcaster.Add(conn)
+
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("hello"))
+		broadcaster.Write([]byte("hello, everyone"))
+
+		if 0 == i%2 {
+			conn.Close()
+		} else {
+			// (The peer closing its side has the reading stop; and then, once it is closed, the rest too.)
+			remote.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			conn.CloseGracefully(ctx)
+			cancel()
+		}
+
+		select {
+		case <-conn.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("For #%d, timed out waiting for Done to be closed.", i)
+		}
+		remote.Close()
+	}
+
+	var actual int
+	for begin := time.Now(); time.Since(begin) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if actual = runtime.NumGoroutine(); actual <= baseline {
+			break
+		}
+	}
+	if actual > baseline {
+		buffer := make([]byte, 1<<16)
+		t.Errorf("Expected (at most) %d goroutine(s), but actually got %d:\n%s", baseline, actual, buffer[:runtime.Stack(buffer, true)])
+	}
+	if expected, actual := 0, broadcaster.Len(); expected != actual {
+		t.Errorf("Expected %d broadcast member(s), but actually got %d.", expected, actual)
+	}
+}
+
+// testPipeListener is a net.Listener; whose connections are (the server's ends of) net.Pipes. (So
+// that the goroutines of a Server can be counted without those of a TCP stack.)
+type testPipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+}
+
+func newTestPipeListener() *testPipeListener {
+	return &testPipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// dial returns the client's end of a new connection; once the server has accepted it.
+func (listener *testPipeListener) dial() net.Conn {
+	local, remote := net.Pipe()
+	listener.conns <- remote
+	return local
+}
+
+func (listener *testPipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (listener *testPipeListener) Close() error {
+	select {
+	case <-listener.closed:
+	default:
+		close(listener.closed)
+	}
+	return nil
+}
+
+func (listener *testPipeListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestServerNoGoroutineLeaksOnPanic(t *testing.T) {
+
+	baseline := runtime.NumGoroutine()
+
+	listener := newTestPipeListener()
+	served := make(chan *Conn, 1)
+	server := &Server{
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			// (So that there are goroutines (see Conn.spawn) to be stopped.)
+			conn.SetKeepalive(Keepalive{Interval: time.Minute})
+			served <- conn
+			panic("boom")
+		}),
+		SessionTimeouts:       SessionTimeouts{IdleTimeout: time.Minute, MaxSessionDuration: time.Hour},
+		OnNegotiationComplete: func(conn *Conn) {},
+		OptionHandlers: OptionHandlers{
+			OptNAWS: func() OptionHandler {
+				return &testOptionHandler{support: OptionSupport{Remote: true, RequestRemote: true}}
+			},
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		server.Serve(listener)
+		close(done)
+	}()
+
+	for i := 0; i < 200; i++ {
+		client := listener.dial()
+		go io.Copy(io.Discard, client)
+
+		conn := <-served
+		select {
+		case <-conn.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("For #%d, timed out waiting for Done to be closed.", i)
+		}
+		client.Close()
+	}
+
+	listener.Close()
+	<-done
+
+	var actual int
+	for begin := time.Now(); time.Since(begin) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if actual = runtime.NumGoroutine(); actual <= baseline {
+			break
+		}
+	}
+	if actual > baseline {
+		buffer := make([]byte, 1<<16)
+		t.Errorf("Expected (at most) %d goroutine(s), but actually got %d:\n%s", baseline, actual, buffer[:runtime.Stack(buffer, true)])
+	}
+}
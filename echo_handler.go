@@ -3,11 +3,16 @@ package telnet
 
 import (
 	"github.com/reiver/go-oi"
+
+	"context"
+	"io"
 )
 
 
 // EchoHandler is a simple TELNET server which "echos" back to the client any (non-command)
 // data back to the TELNET client, it received from the TELNET client.
+//
+// It is (also) a ContextHandler; see AdaptHandler.
 var EchoHandler Handler = internalEchoHandler{}
 
 
@@ -15,6 +20,27 @@ type internalEchoHandler struct{}
 
 
 func (handler internalEchoHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	echo(w, r)
+}
+
+
+// ContextHandler returns the EchoHandler, as a ContextHandler.
+func (handler internalEchoHandler) ContextHandler() ContextHandler {
+	return internalEchoContextHandler{}
+}
+
+
+// internalEchoContextHandler is the EchoHandler, as a ContextHandler.
+type internalEchoContextHandler struct{}
+
+
+func (handler internalEchoContextHandler) ServeTELNET(ctx context.Context, conn *Conn) {
+	echo(conn, conn)
+}
+
+
+// echo writes (to 'w') whatever it reads (from 'r'); until reading fails.
+func echo(w io.Writer, r io.Reader) {
 
 	var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
 	p := buffer[:]
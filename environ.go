@@ -0,0 +1,382 @@
+package telnet
+
+import (
+	"sort"
+	"sync"
+)
+
+// The NEW-ENVIRON (RFC 1572) subnegotiation commands; and the bytes that the variables in them
+// are made out of.
+const (
+	environIS   = 0
+	environSEND = 1
+	environINFO = 2
+
+	environVAR     = 0
+	environVALUE   = 1
+	environESC     = 2
+	environUSERVAR = 3
+)
+
+// environWellKnown are the (well-known) variables that RFC 1572 defines; which are sent as a VAR.
+// (Any other variable is sent as a USERVAR.)
+var environWellKnown = map[string]bool{
+	"USER":       true,
+	"JOB":        true,
+	"ACCT":       true,
+	"PRINTER":    true,
+	"SYSTEMTYPE": true,
+	"DISPLAY":    true,
+}
+
+// SetEnvironment sets the (environment) variables (such as "USER", and "DISPLAY") that are told to
+// the peer with the NEW-ENVIRON option (RFC 1572). This is for clients. For example:
+//
+//	conn.SetEnvironment(map[string]string{
+//		"USER":    "joe",
+//		"DISPLAY": "workstation:0.0",
+//	})
+//
+// Once the peer asks for (i.e., sends a DO for) NEW-ENVIRON, it is agreed to; and each time the
+// peer asks for variables, it is sent those it asked for (or all of them, if it did not say). The
+// well-known variables (USER, JOB, ACCT, PRINTER, SYSTEMTYPE, and DISPLAY) are sent as a VAR, and
+// the rest as a USERVAR. A variable it asked for that is not in 'variables' is sent without a
+// value; i.e., as undefined.
+//
+// (It registers an OptionHandler for NEW-ENVIRON; replacing any that was registered before. Such
+// as by RequestEnvironment; which is for the other side.)
+func (clientConn *Conn) SetEnvironment(variables map[string]string) error {
+	copied := make(map[string]string, len(variables))
+	for name, value := range variables {
+		copied[name] = value
+	}
+
+	clientConn.environMutex.Lock()
+	clientConn.peerEnviron = nil
+	clientConn.environMutex.Unlock()
+
+	return clientConn.RegisterOption(OptNewEnviron, &internalEnviron{variables: copied})
+}
+
+// internalEnviron is the (NEW-ENVIRON) OptionHandler that SetEnvironment registers.
+type internalEnviron struct {
+	mutex     sync.Mutex
+	sender    OptionSender
+	variables map[string]string
+}
+
+func (environ *internalEnviron) Register(sender OptionSender) OptionSupport {
+	environ.mutex.Lock()
+	environ.sender = sender
+	environ.mutex.Unlock()
+
+	return OptionSupport{
+		Local: true,
+	}
+}
+
+func (*internalEnviron) LocalChanged(bool)  {}
+func (*internalEnviron) RemoteChanged(bool) {}
+
+// Subnegotiation handles:
+//
+//	IAC SB NEW-ENVIRON SEND [(VAR|USERVAR) [<name>]]... IAC SE
+//
+// by sending:
+//
+//	IAC SB NEW-ENVIRON IS [(VAR|USERVAR) <name> [VALUE <value>]]... IAC SE
+//
+// (Where a VAR, or USERVAR, without a name asks for all of the variables of that kind.)
+func (environ *internalEnviron) Subnegotiation(payload []byte) {
+	if len(payload) < 1 || environSEND != payload[0] {
+		return
+	}
+	requested := parseEnviron(payload[1:])
+
+	environ.mutex.Lock()
+	sender := environ.sender
+	variables := environ.variables
+	environ.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reply := []byte{environIS}
+	if len(requested) <= 0 {
+		for _, name := range names {
+			reply = appendEnvironVariable(reply, name, variables[name], true)
+		}
+	}
+	for _, variable := range requested {
+		if "" != variable.name {
+			value, ok := variables[variable.name]
+			reply = appendEnvironVariable(reply, variable.name, value, ok)
+			continue
+		}
+		for _, name := range names {
+			if environWellKnown[name] == variable.user {
+				continue
+			}
+			reply = appendEnvironVariable(reply, name, variables[name], true)
+		}
+	}
+
+	sender.SendSubnegotiation(reply)
+}
+
+// RequestEnvironment asks the peer for its (environment) variables, with the NEW-ENVIRON option
+// (RFC 1572); i.e., sends a DO for NEW-ENVIRON. This is for servers. (Such as for one that fills
+// in the login name with the "USER" the client told.) For example:
+//
+//	conn.OnEnvironment(func(variables map[string]string) {
+//		//@TODO: Use variables["USER"].
+//	})
+//
+//	if err := conn.RequestEnvironment("USER", "DISPLAY"); nil != err {
+//		return err
+//	}
+//
+// Once the peer agrees to it, it asks for the variables 'names' (or for all of the peer's
+// variables, if there are not any); and then keeps track of what the peer tells, including when
+// it changes any of them later (with an INFO). What the peer has told is what PeerEnvironment
+// returns.
+//
+// (It registers an OptionHandler for NEW-ENVIRON; replacing any that was registered before. Such
+// as by SetEnvironment; which is for the other side.)
+func (clientConn *Conn) RequestEnvironment(names ...string) error {
+	handler := &internalPeerEnviron{names: append([]string(nil), names...)}
+
+	clientConn.environMutex.Lock()
+	clientConn.peerEnviron = handler
+	clientConn.environMutex.Unlock()
+
+	return clientConn.RegisterOption(OptNewEnviron, handler)
+}
+
+// PeerEnvironment returns (a copy of) the variables the peer has told so far, with NEW-ENVIRON
+// (see RequestEnvironment). Or nil, if it has not told any.
+func (clientConn *Conn) PeerEnvironment() map[string]string {
+	clientConn.environMutex.Lock()
+	handler := clientConn.peerEnviron
+	clientConn.environMutex.Unlock()
+
+	if nil == handler {
+		return nil
+	}
+	return handler.environment()
+}
+
+// OnEnvironment registers 'fn' to be called each time the peer tells (any of) its variables, with
+// NEW-ENVIRON (see RequestEnvironment); with (a copy of) all of the variables it has told so far.
+// This replaces whatever was registered before. Registering nil stops the calls.
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnEnvironment(fn func(variables map[string]string)) {
+	clientConn.environMutex.Lock()
+	defer clientConn.environMutex.Unlock()
+
+	clientConn.onEnvironment = fn
+}
+
+// internalPeerEnviron is the (NEW-ENVIRON) OptionHandler that RequestEnvironment registers.
+type internalPeerEnviron struct {
+	mutex     sync.Mutex
+	sender    OptionSender
+	names     []string
+	variables map[string]string
+}
+
+func (environ *internalPeerEnviron) Register(sender OptionSender) OptionSupport {
+	environ.mutex.Lock()
+	environ.sender = sender
+	environ.mutex.Unlock()
+
+	return OptionSupport{
+		Remote:        true,
+		RequestRemote: true,
+	}
+}
+
+func (*internalPeerEnviron) LocalChanged(bool) {}
+
+// RemoteChanged asks for the variables, once the peer has agreed to NEW-ENVIRON:
+//
+//	IAC SB NEW-ENVIRON SEND [(VAR|USERVAR) <name>]... IAC SE
+func (environ *internalPeerEnviron) RemoteChanged(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	environ.mutex.Lock()
+	sender := environ.sender
+	names := environ.names
+	environ.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+
+	request := []byte{environSEND}
+	for _, name := range names {
+		kind := byte(environUSERVAR)
+		if environWellKnown[name] {
+			kind = environVAR
+		}
+		request = appendEnviron(append(request, kind), name)
+	}
+	sender.SendSubnegotiation(request)
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB NEW-ENVIRON IS [(VAR|USERVAR) <name> [VALUE <value>]]... IAC SE
+//
+// ... and the same with INFO (rather than IS); which is what the peer sends when any of its
+// variables change. A variable without a VALUE is undefined (on the peer); so is removed.
+func (environ *internalPeerEnviron) Subnegotiation(payload []byte) {
+	if len(payload) < 1 || (environIS != payload[0] && environINFO != payload[0]) {
+		return
+	}
+
+	environ.mutex.Lock()
+	if nil == environ.variables {
+		environ.variables = map[string]string{}
+	}
+	for _, variable := range parseEnviron(payload[1:]) {
+		if "" == variable.name {
+			continue
+		}
+		if variable.defined {
+			environ.variables[variable.name] = variable.value
+		} else {
+			delete(environ.variables, variable.name)
+		}
+	}
+	sender := environ.sender
+	environ.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+
+	conn := sender.Conn()
+	conn.environMutex.Lock()
+	fn := conn.onEnvironment
+	conn.environMutex.Unlock()
+
+	if nil != fn {
+		fn(environ.environment())
+	}
+}
+
+func (environ *internalPeerEnviron) environment() map[string]string {
+	environ.mutex.Lock()
+	defer environ.mutex.Unlock()
+
+	if nil == environ.variables {
+		return nil
+	}
+	variables := make(map[string]string, len(environ.variables))
+	for name, value := range environ.variables {
+		variables[name] = value
+	}
+	return variables
+}
+
+type internalEnvironVariable struct {
+	user    bool
+	name    string
+	value   string
+	defined bool
+}
+
+// parseEnviron parses the (VAR|USERVAR) <name> [VALUE <value>] list of an IS (or INFO, or SEND);
+// where any VAR, VALUE, ESC, or USERVAR in a name or value is escaped with an ESC.
+func parseEnviron(p []byte) []internalEnvironVariable {
+
+	var variables []internalEnvironVariable
+
+	var current *internalEnvironVariable
+	var buffer []byte
+	inValue := false
+
+	flush := func() {
+		if nil != current {
+			if inValue {
+				current.value = string(buffer)
+			} else {
+				current.name = string(buffer)
+			}
+		}
+		buffer = buffer[:0]
+	}
+
+	for i := 0; i < len(p); i++ {
+		b := p[i]
+
+		switch b {
+		case environVAR, environUSERVAR:
+			flush()
+			if nil != current {
+				variables = append(variables, *current)
+			}
+			current = &internalEnvironVariable{user: environUSERVAR == b}
+			inValue = false
+		case environVALUE:
+			flush()
+			if nil != current {
+				current.defined = true
+			}
+			inValue = true
+		case environESC:
+			if i+1 < len(p) {
+				i++
+				buffer = append(buffer, p[i])
+			}
+		default:
+			buffer = append(buffer, b)
+		}
+	}
+	flush()
+	if nil != current {
+		variables = append(variables, *current)
+	}
+
+	return variables
+}
+
+// appendEnvironVariable appends the variable to 'p'; as a VAR (if it is one of the well-known
+// variables) or a USERVAR, with its VALUE (if it is 'defined').
+func appendEnvironVariable(p []byte, name string, value string, defined bool) []byte {
+	kind := byte(environUSERVAR)
+	if environWellKnown[name] {
+		kind = environVAR
+	}
+
+	p = appendEnviron(append(p, kind), name)
+	if defined {
+		p = appendEnviron(append(p, environVALUE), value)
+	}
+	return p
+}
+
+// appendEnviron appends 's' to 'p'; with any VAR, VALUE, ESC, or USERVAR in it escaped with an ESC.
+func appendEnviron(p []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; b {
+		case environVAR, environVALUE, environESC, environUSERVAR:
+			p = append(p, environESC, b)
+		default:
+			p = append(p, b)
+		}
+	}
+	return p
+}
@@ -0,0 +1,212 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"time"
+
+	"testing"
+)
+
+func TestConnRequestEnvironment(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	told := make(chan map[string]string, 2)
+	conn.OnEnvironment(func(variables map[string]string) {
+		told <- variables
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RequestEnvironment("USER", "COLUMNS")
+	}()
+
+	if expected, actual := string([]byte{IAC, DO, OptNewEnviron}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	remote.Write([]byte{IAC, WILL, OptNewEnviron})
+
+	expected := string([]byte{IAC, SB, OptNewEnviron, environSEND, environVAR}) + "USER" + string([]byte{environUSERVAR}) + "COLUMNS" + string([]byte{IAC, SE})
+	if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (The name has an escaped VALUE in it; and the value has an IAC.)
+	remote.Write([]byte(string([]byte{IAC, SB, OptNewEnviron, environIS, environVAR}) + "USER" + string([]byte{environVALUE}) + "joe\xff\xff" +
+		string([]byte{environUSERVAR}) + "A" + string([]byte{environESC, environVALUE}) + "B" + string([]byte{environVALUE}) + "1" + string([]byte{IAC, SE})))
+
+	select {
+	case actual := <-told:
+		if expected := map[string]string{"USER": "joe\xff", "A\x01B": "1"}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnEnvironment to be called, but actually it was not.")
+	}
+
+	// (A variable told without a VALUE is undefined.)
+	remote.Write([]byte(string([]byte{IAC, SB, OptNewEnviron, environINFO, environUSERVAR}) + "A\x02\x01B" + string([]byte{IAC, SE})))
+
+	select {
+	case actual := <-told:
+		if expected := map[string]string{"USER": "joe\xff"}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnEnvironment to be called, but actually it was not.")
+	}
+
+	if expected, actual := map[string]string{"USER": "joe\xff"}, conn.PeerEnvironment(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnSetEnvironment(t *testing.T) {
+
+	variables := map[string]string{
+		"USER":    "joe",
+		"DISPLAY": "ws:0",
+		"LANG":    "en\x01US",
+	}
+
+	tests := []struct {
+		Send     []byte
+		Expected string
+	}{
+		{
+			// (All of them.)
+			Send:     []byte{environSEND},
+			Expected: "\x00DISPLAY\x01ws:0\x03LANG\x01en\x02\x01US\x00USER\x01joe",
+		},
+		{
+			Send:     append([]byte{environSEND, environVAR}, "USER"...),
+			Expected: "\x00USER\x01joe",
+		},
+		{
+			// (One that is not set is sent as undefined.)
+			Send:     append([]byte{environSEND, environUSERVAR}, "TERM"...),
+			Expected: "\x03TERM",
+		},
+		{
+			// (All of the well-known ones; and then all of the others.)
+			Send:     []byte{environSEND, environVAR, environUSERVAR},
+			Expected: "\x00DISPLAY\x01ws:0\x00USER\x01joe\x03LANG\x01en\x02\x01US",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		if err := conn.SetEnvironment(variables); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		remote.Write([]byte{IAC, DO, OptNewEnviron})
+		if expected, actual := string([]byte{IAC, WILL, OptNewEnviron}), string(testReadExactly(t, remote, 3)); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		remote.Write(append(append([]byte{IAC, SB, OptNewEnviron}, test.Send...), IAC, SE))
+
+		expected := string([]byte{IAC, SB, OptNewEnviron, environIS}) + test.Expected + string([]byte{IAC, SE})
+		if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnEnvironmentBetweenConns(t *testing.T) {
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	server := newConn(serverSide, nil)
+	client := newConn(clientSide, nil)
+	go io.Copy(io.Discard, server)
+	go io.Copy(io.Discard, client)
+
+	if err := client.SetEnvironment(map[string]string{"USER": "joe", "EDITOR": "vi"}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	told := make(chan map[string]string, 1)
+	server.OnEnvironment(func(variables map[string]string) {
+		told <- variables
+	})
+	if err := server.RequestEnvironment(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	select {
+	case actual := <-told:
+		if expected := map[string]string{"USER": "joe", "EDITOR": "vi"}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnEnvironment to be called, but actually it was not.")
+	}
+}
+
+func TestParseEnviron(t *testing.T) {
+
+	tests := []struct {
+		Payload  []byte
+		Expected []internalEnvironVariable
+	}{
+		{
+			Payload:  []byte{},
+			Expected: nil,
+		},
+		{
+			Payload: []byte("\x00USER\x01joe"),
+			Expected: []internalEnvironVariable{
+				{name: "USER", value: "joe", defined: true},
+			},
+		},
+		{
+			Payload: []byte("\x00USER\x01joe\x03TERM_COLORS\x01256\x00DISPLAY"),
+			Expected: []internalEnvironVariable{
+				{name: "USER", value: "joe", defined: true},
+				{user: true, name: "TERM_COLORS", value: "256", defined: true},
+				{name: "DISPLAY"},
+			},
+		},
+		{
+			Payload: []byte("\x03A\x02\x01B\x01x\x02\x00y\x00\x01z"),
+			Expected: []internalEnvironVariable{
+				{user: true, name: "A\x01B", value: "x\x00y", defined: true},
+				{name: "", value: "z", defined: true}, // (A variable without a name is kept; it means all of them, in a SEND.)
+			},
+		},
+		{
+			Payload: []byte("\x03EMPTY\x01"),
+			Expected: []internalEnvironVariable{
+				{user: true, name: "EMPTY", value: "", defined: true},
+			},
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual := parseEnviron(test.Payload)
+
+		if expected := test.Expected; len(expected) != len(actual) {
+			t.Errorf("For test #%d, expected %+v, but actually got %+v; for payload: %q", testNumber, expected, actual, test.Payload)
+			continue
+		}
+		for i := range actual {
+			if expected := test.Expected[i]; expected != actual[i] {
+				t.Errorf("For test #%d, expected %+v, but actually got %+v; for payload: %q", testNumber, test.Expected, actual, test.Payload)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// ErrExpectBufferFull is what Expect returns when it has read 1 MiB (of data), without any of the
+// patterns matching.
+var ErrExpectBufferFull = errors.New("telnet: expect buffer full")
+
+// expectMaxBuffered is how much (data) Expect reads, at most, while waiting for a match.
+const expectMaxBuffered = 1 << 20
+
+// An ExpectMatch is what Expect matched.
+type ExpectMatch struct {
+	// Index is the index (in the patterns Expect was given) of the pattern that matched; and
+	// Pattern is that pattern.
+	Index   int
+	Pattern *regexp.Regexp
+
+	// Before is what was read before the match; and Match is what matched.
+	Before string
+	Match  string
+
+	// Groups are the sub-matches; i.e., what the parenthesized sub-expressions of the pattern
+	// matched. (Groups[0] is what the first one matched. A sub-expression that did not match
+	// anything is "".)
+	Groups []string
+}
+
+// Expect reads (TELNET data) until one of 'patterns' matches what was read; and returns what
+// matched, and what was read before it. This is for scripting network devices (such as routers,
+// and switches); where what is sent next depends on what prompt the device shows. For example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+//	defer cancel()
+//
+//	if _, err := conn.Expect(ctx, regexp.MustCompile(`Username: ?$`)); nil != err {
+//		return err
+//	}
+//	conn.SendLine(username)
+//
+//	if _, err := conn.Expect(ctx, regexp.MustCompile(`Password: ?$`)); nil != err {
+//		return err
+//	}
+//	conn.SendLine(password)
+//
+//	match, err := conn.Expect(ctx, regexp.MustCompile(`(\S+)[>#] ?$`), regexp.MustCompile(`% (.*)`))
+//	if nil != err {
+//		return err
+//	}
+//	if 1 == match.Index {
+//		return fmt.Errorf("login failed: %s", match.Groups[0])
+//	}
+//	hostname := match.Groups[0]
+//
+// (For matching a string as-is, use regexp.QuoteMeta.)
+//
+// The patterns are matched against everything read since the last match; so a pattern can match
+// across what came in over several reads. If more than one of them matches, then the one whose
+// match starts first wins; or, if some start at the same place, the first of those (in the order
+// they were given). A $ in a pattern matches at the end of what has been read so far; so a
+// pattern such as `# ?$` works for a prompt (which the device sends no more after, until it is
+// sent something).
+//
+// What was read after the match is kept; for the next Expect (or Read, ReadLine, and the rest).
+//
+// If 'ctx' is done before any of the patterns match, then Expect returns the error of 'ctx'. (So
+// context.WithTimeout is how to give up on a device that does not show what was expected.) And if
+// reading fails (such as with io.EOF, once the device hangs up), then Expect returns why. Either
+// way, what was read is kept (for the next Expect, or Read); nothing is lost. (Cancelling 'ctx'
+// needs the underlying connection to support read deadlines; which TCP, and TLS, connections do.)
+//
+// If 1 MiB has been read without a match, then Expect returns ErrExpectBufferFull.
+func (clientConn *Conn) Expect(ctx context.Context, patterns ...*regexp.Regexp) (*ExpectMatch, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	// (What Peek, or an Expect before, read, but has not been Read yet, comes first.)
+	data := clientConn.peeked
+	clientConn.peeked = nil
+	defer func() {
+		clientConn.peeked = data
+	}()
+
+	deadliner, _ := clientConn.conn.(interface{ SetReadDeadline(time.Time) error })
+
+	// (The underlying connection's read deadline is what stops a Read that is waiting, once
+	// 'ctx' is done.)
+	var cancel internalDeadlineCanceller
+	if nil != deadliner {
+		stop := cancel.watch(ctx, deadliner.SetReadDeadline, clientConn.currentReadDeadline())
+		defer stop()
+	}
+
+	var buffer [512]byte
+	for {
+		if match, end := expectMatch(data, patterns); nil != match {
+			data = data[end:]
+			if len(data) <= 0 {
+				data = nil
+			}
+			return match, nil
+		}
+
+		if expectMaxBuffered <= len(data) {
+			return nil, ErrExpectBufferFull
+		}
+
+		n, err := clientConn.readData(buffer[:])
+		data = append(data, buffer[:n]...)
+		if nil == err {
+			continue
+		}
+
+		if cancel.cancelled() && isTimeout(err) {
+			return nil, ctx.Err()
+		}
+
+		// (What came in along with the error might match.)
+		if match, end := expectMatch(data, patterns); nil != match {
+			data = data[end:]
+			if len(data) <= 0 {
+				data = nil
+			}
+			return match, nil
+		}
+		return nil, err
+	}
+}
+
+// expectMatch returns which of 'patterns' matches 'data' (the one whose match starts first; see
+// Expect), and where (in 'data') its match ends. Or nil, if none of them match.
+func expectMatch(data []byte, patterns []*regexp.Regexp) (*ExpectMatch, int) {
+	var match *ExpectMatch
+	var matchStart, matchEnd int
+
+	for index, pattern := range patterns {
+		loc := pattern.FindSubmatchIndex(data)
+		if nil == loc {
+			continue
+		}
+		if nil != match && matchStart <= loc[0] {
+			continue
+		}
+
+		groups := make([]string, 0, len(loc)/2-1)
+		for k := 2; k < len(loc); k += 2 {
+			if loc[k] < 0 {
+				groups = append(groups, "")
+				continue
+			}
+			groups = append(groups, string(data[loc[k]:loc[k+1]]))
+		}
+
+		match = &ExpectMatch{
+			Index:   index,
+			Pattern: pattern,
+			Before:  string(data[:loc[0]]),
+			Match:   string(data[loc[0]:loc[1]]),
+			Groups:  groups,
+		}
+		matchStart, matchEnd = loc[0], loc[1]
+	}
+
+	return match, matchEnd
+}
+
+// SendLine writes 's' to the peer; followed by the end of a line (i.e., CR LF). For example:
+//
+//	if err := conn.SendLine("show version"); nil != err {
+//		return err
+//	}
+//
+// (See Expect.)
+func (clientConn *Conn) SendLine(s string) error {
+	_, err := clientConn.Write([]byte(s + "\r\n"))
+	return err
+}
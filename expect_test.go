@@ -0,0 +1,180 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"testing"
+)
+
+func TestExpectMatch(t *testing.T) {
+
+	tests := []struct {
+		Data     string
+		Patterns []string
+		Expected *ExpectMatch
+		End      int
+	}{
+		{
+			Data:     "Username: ",
+			Patterns: []string{`Password: ?$`},
+			Expected: nil,
+		},
+		{
+			Data:     "\r\nUsername: ",
+			Patterns: []string{`Password: ?$`, `Username: ?$`},
+			Expected: &ExpectMatch{Index: 1, Before: "\r\n", Match: "Username: ", Groups: []string{}},
+			End:      12,
+		},
+		{
+			// (The one whose match starts first wins.)
+			Data:     "% Bad secrets\r\nrouter> ",
+			Patterns: []string{`(\S+)> $`, `% (.*)\r\n`},
+			Expected: &ExpectMatch{Index: 1, Before: "", Match: "% Bad secrets\r\n", Groups: []string{"Bad secrets"}},
+			End:      15,
+		},
+		{
+			// (If they start at the same place, the first of them.)
+			Data:     "router# more",
+			Patterns: []string{`router#`, `router# more`},
+			Expected: &ExpectMatch{Index: 0, Before: "", Match: "router#", Groups: []string{}},
+			End:      7,
+		},
+		{
+			Data:     "switch# ",
+			Patterns: []string{`(\S+)(>)?# $`},
+			Expected: &ExpectMatch{Index: 0, Before: "", Match: "switch# ", Groups: []string{"switch", ""}},
+			End:      8,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var patterns []*regexp.Regexp
+		for _, pattern := range test.Patterns {
+			patterns = append(patterns, regexp.MustCompile(pattern))
+		}
+
+		match, end := expectMatch([]byte(test.Data), patterns)
+		if nil == test.Expected {
+			if nil != match {
+				t.Errorf("For test #%d, did not expect a match, but actually got: %+v", testNumber, match)
+			}
+			continue
+		}
+		if nil == match {
+			t.Errorf("For test #%d, expected a match, but actually did not get one.", testNumber)
+			continue
+		}
+
+		test.Expected.Pattern = patterns[test.Expected.Index]
+		if expected, actual := test.Expected, match; expected.Index != actual.Index || expected.Pattern != actual.Pattern || expected.Before != actual.Before || expected.Match != actual.Match || !testEqualStrings(expected.Groups, actual.Groups) {
+			t.Errorf("For test #%d, expected %+v, but actually got %+v.", testNumber, expected, actual)
+			continue
+		}
+		if expected, actual := test.End, end; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestConnExpect(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	username, password := regexp.MustCompile(`Username: ?$`), regexp.MustCompile(`Password: ?$`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// (It matches across what came in over several reads; and the TELNET commands are not part of it.)
+	send([]byte("\r\nUser")...)
+	send(IAC, NOP)
+	send([]byte("name: ")...)
+
+	match, err := conn.Expect(ctx, password, username)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := 1, match.Index; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := "\r\n", match.Before; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (If it gives up, what it read is kept.)
+	send([]byte("Pass")...)
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	if _, err := conn.Expect(shortCtx, password); context.DeadlineExceeded != err {
+		t.Fatalf("Expected %v, but actually got: (%T) %v", context.DeadlineExceeded, err, err)
+	}
+
+	send([]byte("word: welcome")...)
+	match, err = conn.Expect(ctx, regexp.MustCompile(`Password: `))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "Password: ", match.Match; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (What came after the match is what is read next.)
+	p := make([]byte, len("welcome"))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "welcome", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnExpectEOF(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer local.Close()
+
+	conn := newConn(local, nil)
+	go func() {
+		remote.Write([]byte("router# bye"))
+		remote.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	match, err := conn.Expect(ctx, regexp.MustCompile(`(\S+)# `))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []string{"router"}, match.Groups; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if _, err := conn.Expect(ctx, regexp.MustCompile(`# `)); io.EOF != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", io.EOF, err, err)
+	}
+
+	// (What was read is kept; even once reading fails.)
+	p, err := io.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "bye", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnSendLine(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	go conn.SendLine("show version\xff")
+	if expected, actual := "show version\xff\xff\r\n", string(testReadExactly(t, remote, 16)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
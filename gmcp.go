@@ -0,0 +1,131 @@
+package telnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrOptionNotEnabled is returned when sending (the subnegotiation of) an option that is not
+// enabled on either side; such as by SendGMCP, before the client has agreed to GMCP.
+var ErrOptionNotEnabled = errors.New("telnet: option not enabled")
+
+// GMCPOption returns an OptionHandler for the GMCP option (the Generic Mud Communication
+// Protocol); which sends (and receives) messages, each of which is a package name (such as
+// "Char.Vitals") and (optionally) JSON data, as subnegotiations. For example:
+//
+//	conn.RegisterOption(telnet.OptGMCP, telnet.GMCPOption(func(name string, data []byte) {
+//		log.Printf("GMCP %s %s", name, data)
+//	}))
+//
+// It offers (i.e., sends a WILL for) GMCP right away; as a MUD server does. 'receive' is called
+// (if it is not nil) with each message the peer sends; with 'data' being the JSON (if there is
+// any) as-is. As is whatever OnGMCP registered. (See SendGMCP to send one.)
+func GMCPOption(receive func(name string, data []byte)) OptionHandler {
+	return &internalGMCP{receive: receive}
+}
+
+type internalGMCP struct {
+	receive func(name string, data []byte)
+
+	mutex  sync.Mutex
+	sender OptionSender
+}
+
+func (gmcp *internalGMCP) Register(sender OptionSender) OptionSupport {
+	gmcp.mutex.Lock()
+	gmcp.sender = sender
+	gmcp.mutex.Unlock()
+
+	return OptionSupport{Local: true, Remote: true, RequestLocal: true}
+}
+
+func (*internalGMCP) LocalChanged(enabled bool)  {}
+func (*internalGMCP) RemoteChanged(enabled bool) {}
+
+func (gmcp *internalGMCP) Subnegotiation(payload []byte) {
+	name, data := payload, []byte(nil)
+	if k := bytes.IndexAny(payload, " \t\r\n"); 0 <= k {
+		name, data = payload[:k], bytes.TrimSpace(payload[k+1:])
+	}
+
+	if nil != gmcp.receive {
+		gmcp.receive(string(name), data)
+	}
+
+	gmcp.mutex.Lock()
+	sender := gmcp.sender
+	gmcp.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	if fn := sender.Conn().gmcpHandler(); nil != fn {
+		fn(string(name), data)
+	}
+}
+
+// OnGMCP registers 'fn' to be called with each GMCP message that the peer sends; i.e., its package
+// name (such as "Char.Vitals", or "Core.Hello"), and its JSON data (if there is any) as-is. This
+// replaces whatever was registered before. Registering nil stops the calls. For example:
+//
+//	conn.OnGMCP(func(name string, data []byte) {
+//		switch name {
+//		case "Core.Hello":
+//			var hello struct {
+//				Client  string `json:"client"`
+//				Version string `json:"version"`
+//			}
+//			if err := json.Unmarshal(data, &hello); nil != err {
+//				return
+//			}
+//
+//			//@TODO: Do something with 'hello'.
+//		}
+//	})
+//
+// (GMCPOption, or MUDBridge, has to be registered for GMCP messages to be received.)
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnGMCP(fn func(name string, data []byte)) {
+	clientConn.mudMutex.Lock()
+	defer clientConn.mudMutex.Unlock()
+
+	clientConn.onGMCP = fn
+}
+
+func (clientConn *Conn) gmcpHandler() func(name string, data []byte) {
+	clientConn.mudMutex.RLock()
+	defer clientConn.mudMutex.RUnlock()
+
+	return clientConn.onGMCP
+}
+
+// SendGMCP sends a GMCP message to the peer; i.e., the package name 'name' (such as
+// "Char.Vitals"), followed by 'data' as JSON (unless it is nil). For example:
+//
+//	err := conn.SendGMCP("Char.Vitals", map[string]int{"hp": 95, "maxhp": 120})
+//
+// ... sends:
+//
+//	IAC SB GMCP 'Char.Vitals {"hp":95,"maxhp":120}' IAC SE
+//
+// It returns ErrOptionNotEnabled if GMCP is not enabled (on either side). (See GMCPOption.)
+func (clientConn *Conn) SendGMCP(name string, data interface{}) error {
+	if local, remote := clientConn.OptionEnabled(OptGMCP); !local && !remote {
+		return ErrOptionNotEnabled
+	}
+
+	payload := []byte(name)
+	if nil != data {
+		encoded, err := json.Marshal(data)
+		if nil != err {
+			return err
+		}
+		payload = append(append(payload, ' '), encoded...)
+	}
+
+	return clientConn.SendSubnegotiation(OptGMCP, payload)
+}
@@ -3,3 +3,7 @@ module github.com/wouteroostervld/go-telnet
 go 1.19
 
 require github.com/reiver/go-oi v1.0.0
+
+require golang.org/x/crypto v0.14.0
+
+require golang.org/x/text v0.13.0
@@ -0,0 +1,139 @@
+package telnet
+
+import (
+	"github.com/reiver/go-oi"
+
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// closeFlushTimeout is how long (plain) Close waits for what is still buffered to be sent.
+const closeFlushTimeout = time.Second
+
+// internalEOFReader closes 'eof' once reading from 'reader' fails; such as because the peer
+// closed the connection.
+type internalEOFReader struct {
+	reader io.Reader
+	once   sync.Once
+	eof    chan struct{}
+}
+
+func (reader *internalEOFReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	err = connectionErr(err)
+	if nil != err {
+		reader.once.Do(func() {
+			close(reader.eof)
+		})
+	}
+
+	return n, err
+}
+
+// SetGoodbye sets a (final) message, which CloseGracefully writes (as data) just before closing
+// the connection. For example:
+//
+//	conn.SetGoodbye("Goodbye!\r\n")
+//
+// The default is no message.
+func (clientConn *Conn) SetGoodbye(message string) {
+	clientConn.goodbyeMutex.Lock()
+	defer clientConn.goodbyeMutex.Unlock()
+
+	clientConn.goodbye = message
+}
+
+// CloseGracefully closes the connection (like Close does); but without dropping any of what was
+// written to it. For example:
+//
+//	oi.LongWriteString(conn, "Goodbye.\r\n")
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//
+//	conn.CloseGracefully(ctx)
+//
+// CloseGracefully:
+//
+//  1. waits for what is being written (and what is buffered) to be sent,
+//  2. writes the goodbye message (see SetGoodbye), if there is one,
+//  3. closes the sending side of the connection (i.e., CloseWrite), if it can be,
+//  4. waits for the peer to close its side of the connection (reading, and throwing away,
+//     whatever the peer sends, if nothing else is reading), and then
+//  5. closes the connection.
+//
+// It gives up waiting once 'ctx' is done; and then closes the connection right away.
+//
+// Like Close, CloseGracefully can be called more than once; returning what closing the
+// connection returned the first time.
+func (clientConn *Conn) CloseGracefully(ctx context.Context) error {
+
+	select {
+	case <-clientConn.done:
+		return clientConn.Close()
+	default:
+	}
+
+	clientConn.goodbyeMutex.Lock()
+	goodbye := clientConn.goodbye
+	clientConn.goodbyeMutex.Unlock()
+
+	written := make(chan error, 1)
+	clientConn.spawn(func() {
+		if "" == goodbye {
+			written <- clientConn.Flush()
+			return
+		}
+
+		if _, err := oi.LongWriteString(clientConn, goodbye); nil != err {
+			written <- err
+			return
+		}
+		written <- clientConn.Flush()
+	})
+
+	select {
+	case err := <-written:
+		if nil != err {
+			clientConn.logger.Debugf("Problem writing, while closing gracefully: %v", err)
+			return clientConn.Close()
+		}
+	case <-ctx.Done():
+		return clientConn.Close()
+	}
+
+	writeCloser, ok := clientConn.conn.(interface{ CloseWrite() error })
+	if !ok {
+		return clientConn.Close()
+	}
+	if err := writeCloser.CloseWrite(); nil != err {
+		clientConn.logger.Debugf("Problem closing the sending side, while closing gracefully: %v", err)
+		return clientConn.Close()
+	}
+
+	// If nothing else is reading, then nothing would notice the peer closing its side; so read
+	// (and throw away) whatever it still sends, until it does.
+	if clientConn.readMutex.TryLock() {
+		clientConn.spawn(func() {
+			defer clientConn.readMutex.Unlock()
+
+			var buffer [256]byte
+			for {
+				// (An IAC EOF is not the peer closing its side.)
+				if _, err := clientConn.dataReader.Read(buffer[:]); nil != err && errEOFCommand != err {
+					return
+				}
+			}
+		})
+	}
+
+	select {
+	case <-clientConn.peerClosed:
+	case <-clientConn.done:
+	case <-ctx.Done():
+	}
+
+	return clientConn.Close()
+}
@@ -0,0 +1,167 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnCloseGracefully(t *testing.T) {
+
+	tests := []struct {
+		Goodbye  string
+		Expected string
+	}{
+		{
+			Goodbye:  "",
+			Expected: "hello\r\n",
+		},
+		{
+			Goodbye:  "Goodbye!\r\n",
+			Expected: "hello\r\nGoodbye!\r\n",
+		},
+		{
+			Goodbye:  "bye \xff\r\n",
+			Expected: "hello\r\nbye \xff\xff\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+
+		conn := newConn(local, nil)
+		conn.SetGoodbye(test.Goodbye)
+
+		received := make(chan string, 1)
+		go func() {
+			p, _ := io.ReadAll(remote)
+			received <- string(p)
+		}()
+
+		conn.Write([]byte("hello\r\n"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := conn.CloseGracefully(ctx); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		cancel()
+
+		// What was written (and the goodbye) all arrives before the end-of-file.
+		select {
+		case actual := <-received:
+			if expected := test.Expected; expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("For test #%d, timed out waiting for the end-of-file.", testNumber)
+		}
+		remote.Close()
+	}
+}
+
+func TestConnCloseGracefullyTCP(t *testing.T) {
+
+	tests := []struct {
+		PeerCloses      bool
+		ExpectedAtLeast time.Duration
+		ExpectedAtMost  time.Duration
+	}{
+		{
+			PeerCloses:     true,
+			ExpectedAtMost: 500 * time.Millisecond,
+		},
+		{
+			// (It gives up waiting for the peer, once the context is done.)
+			PeerCloses:      false,
+			ExpectedAtLeast: 150 * time.Millisecond,
+			ExpectedAtMost:  time.Second,
+		},
+	}
+
+	for testNumber, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			c, err := listener.Accept()
+			if nil == err {
+				accepted <- c
+			}
+		}()
+
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		conn := newConn(<-accepted, nil)
+		conn.SetGoodbye("Goodbye!\r\n")
+
+		received := make(chan string, 1)
+		go func() {
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			p, _ := io.ReadAll(client) // (Gets to the end-of-file, once the server does CloseWrite.)
+			received <- string(p)
+			if test.PeerCloses {
+				client.Close()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		begin := time.Now()
+		err = conn.CloseGracefully(ctx)
+		elapsed := time.Since(begin)
+		cancel()
+
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := "Goodbye!\r\n", <-received; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if elapsed < test.ExpectedAtLeast || test.ExpectedAtMost < elapsed {
+			t.Errorf("For test #%d, expected closing to take between %v and %v, but actually took %v.", testNumber, test.ExpectedAtLeast, test.ExpectedAtMost, elapsed)
+		}
+
+		client.Close()
+		listener.Close()
+	}
+}
+
+// testCloseErrConn is an (in memory) connection, whose Close fails; differently each time.
+type testCloseErrConn struct {
+	testBufferConn
+	closes int
+}
+
+func (conn *testCloseErrConn) Close() error {
+	conn.closes++
+	return errors.New("close #" + string(rune('0'+conn.closes)))
+}
+
+func TestConnCloseTwice(t *testing.T) {
+
+	var c testCloseErrConn
+	conn := newConn(&c, nil)
+
+	first := conn.Close()
+	second := conn.CloseGracefully(context.Background())
+	third := conn.Close()
+
+	if nil == first || "close #1" != first.Error() {
+		t.Errorf("Expected the first error, but actually got: (%T) %v", first, first)
+	}
+	if first != second || first != third {
+		t.Errorf("Expected the first error every time, but actually got: %v, %v", second, third)
+	}
+	if expected, actual := 1, c.closes; expected != actual {
+		t.Errorf("Expected the connection to be closed %d time(s), but actually was %d.", expected, actual)
+	}
+}
@@ -0,0 +1,356 @@
+package telnet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrLineTooLong is returned when a line (being read) is longer than the MaxLineLength of
+	// the InputLimits.
+	ErrLineTooLong = errors.New("telnet: line too long")
+
+	// ErrSubnegotiationFlood is returned by a Conn's Read, once the client has sent more
+	// subnegotiations than the MaxSubnegotiationsPerSecond of the InputLimits allows.
+	ErrSubnegotiationFlood = errors.New("telnet: too many subnegotiations")
+
+	// ErrSubnegotiationTooLong is returned by a Conn's Read, once the client has sent a
+	// subnegotiation longer than the MaxSubnegotiationLength of the InputLimits allows.
+	ErrSubnegotiationTooLong = errors.New("telnet: subnegotiation too long")
+
+	// ErrInputFlood is returned by a Conn's Read, once the client has sent more bytes than the
+	// MaxBytesPerSecond of the InputLimits allows.
+	ErrInputFlood = errors.New("telnet: too much input")
+)
+
+// InputLimits are limits on what a client can send; so that a misbehaving (or malicious) client
+// cannot flood the server.
+//
+// The zero value of each limit means no limit; and so the zero InputLimits (which is what a
+// Server uses, unless told otherwise) means no limits at all. DefaultInputLimits are limits
+// that are generous enough to not get in the way of anyone typing, or pasting, into a session.
+//
+// For example:
+//
+//	server := &telnet.Server{
+//		Addr:        ":5555",
+//		Handler:     handler,
+//		InputLimits: telnet.DefaultInputLimits,
+//	}
+type InputLimits struct {
+	// MaxLineLength is the longest a line can be, in bytes (not including the end-of-line).
+	// It is up to whatever puts together the lines (such as telsh.LineEditor) to enforce it;
+	// see Conn.LineTooLong.
+	MaxLineLength int
+
+	// DisconnectOnLongLine, if true, has the connection closed (with CloseLineTooLong) when a
+	// line is longer than MaxLineLength. Otherwise, only that line is thrown away.
+	DisconnectOnLongLine bool
+
+	// MaxSubnegotiationsPerSecond is how many subnegotiations (IAC SB ... IAC SE) the client
+	// can send per second (with bursts of up to a second's worth), before the connection is
+	// closed (with CloseSubnegotiationFlood).
+	MaxSubnegotiationsPerSecond int
+
+	// MaxSubnegotiationLength is the longest a subnegotiation (what is between the IAC SB and the
+	// IAC SE, un-escaped) can be, in bytes; before the connection is closed (with
+	// CloseSubnegotiationTooLong). It is checked as the subnegotiation is being read; so that a
+	// client that never sends the IAC SE cannot have it buffered without end.
+	MaxSubnegotiationLength int
+
+	// MaxBytesPerSecond is how many bytes (as they come over the wire, including any TELNET
+	// commands) the client can send per second (with bursts of up to a second's worth), before
+	// the connection is closed (with CloseInputFlood).
+	MaxBytesPerSecond int
+}
+
+// DefaultInputLimits are generous InputLimits; which will not get in the way of legitimate
+// interactive use.
+var DefaultInputLimits = InputLimits{
+	MaxLineLength:               4096,
+	MaxSubnegotiationsPerSecond: 100,
+	MaxSubnegotiationLength:     64 * 1024,
+	MaxBytesPerSecond:           1024 * 1024,
+}
+
+// InputViolations counts how many times each of the InputLimits was exceeded.
+type InputViolations struct {
+	LineTooLong           uint64
+	SubnegotiationFlood   uint64
+	SubnegotiationTooLong uint64
+	InputFlood            uint64
+}
+
+// internalInputViolations is what a Server counts the InputViolations (of all its connections) with.
+type internalInputViolations struct {
+	lineTooLong           atomic.Uint64
+	subnegotiationFlood   atomic.Uint64
+	subnegotiationTooLong atomic.Uint64
+	inputFlood            atomic.Uint64
+}
+
+func (violations *internalInputViolations) count(reason CloseReason) {
+	switch reason {
+	case CloseLineTooLong:
+		violations.lineTooLong.Add(1)
+	case CloseSubnegotiationFlood:
+		violations.subnegotiationFlood.Add(1)
+	case CloseSubnegotiationTooLong:
+		violations.subnegotiationTooLong.Add(1)
+	case CloseInputFlood:
+		violations.inputFlood.Add(1)
+	}
+}
+
+func (violations *internalInputViolations) snapshot() InputViolations {
+	return InputViolations{
+		LineTooLong:           violations.lineTooLong.Load(),
+		SubnegotiationFlood:   violations.subnegotiationFlood.Load(),
+		SubnegotiationTooLong: violations.subnegotiationTooLong.Load(),
+		InputFlood:            violations.inputFlood.Load(),
+	}
+}
+
+// internalRateMeter tells when something happens more often than 'rate' times per second; allowing
+// bursts of up to a second's worth. (As a token bucket.)
+type internalRateMeter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow spends 'n' tokens, and returns whether there were enough of them.
+func (meter *internalRateMeter) allow(rate int, n int, now time.Time) bool {
+	if meter.last.IsZero() {
+		meter.tokens = float64(rate)
+	} else {
+		meter.tokens += now.Sub(meter.last).Seconds() * float64(rate)
+		if float64(rate) < meter.tokens {
+			meter.tokens = float64(rate)
+		}
+	}
+	meter.last = now
+
+	meter.tokens -= float64(n)
+	return 0 <= meter.tokens
+}
+
+// internalInputLimiter enforces the InputLimits of a Conn.
+type internalInputLimiter struct {
+	mutex           sync.Mutex
+	limits          InputLimits
+	bytes           internalRateMeter
+	subnegotiations internalRateMeter
+
+	// violated is called when one of the limits is exceeded; and closes the connection.
+	violated func(reason CloseReason, msg string)
+}
+
+func (limiter *internalInputLimiter) set(limits InputLimits) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.limits = limits
+	limiter.bytes = internalRateMeter{}
+	limiter.subnegotiations = internalRateMeter{}
+}
+
+func (limiter *internalInputLimiter) get() InputLimits {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return limiter.limits
+}
+
+// received is told about the 'n' bytes that were just read from the client.
+func (limiter *internalInputLimiter) received(n int) error {
+	limiter.mutex.Lock()
+	rate := limiter.limits.MaxBytesPerSecond
+	ok := rate <= 0 || limiter.bytes.allow(rate, n, time.Now())
+	limiter.mutex.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	limiter.violate(CloseInputFlood, fmt.Sprintf("sent more than %d bytes per second", rate))
+	return ErrInputFlood
+}
+
+// subnegotiation is told about a subnegotiation that was just read from the client.
+func (limiter *internalInputLimiter) subnegotiation() error {
+	limiter.mutex.Lock()
+	rate := limiter.limits.MaxSubnegotiationsPerSecond
+	ok := rate <= 0 || limiter.subnegotiations.allow(rate, 1, time.Now())
+	limiter.mutex.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	limiter.violate(CloseSubnegotiationFlood, fmt.Sprintf("sent more than %d subnegotiations per second", rate))
+	return ErrSubnegotiationFlood
+}
+
+// maxSubnegotiationLength returns the MaxSubnegotiationLength of the InputLimits; or zero, if there
+// is no limit (or no limiter).
+func (limiter *internalInputLimiter) maxSubnegotiationLength() int {
+	if nil == limiter {
+		return 0
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return limiter.limits.MaxSubnegotiationLength
+}
+
+// subnegotiationTooLong is told that the subnegotiation being read from the client is longer than
+// 'max' bytes.
+func (limiter *internalInputLimiter) subnegotiationTooLong(max int) error {
+	limiter.violate(CloseSubnegotiationTooLong, fmt.Sprintf("sent a subnegotiation longer than %d bytes", max))
+	return ErrSubnegotiationTooLong
+}
+
+func (limiter *internalInputLimiter) violate(reason CloseReason, msg string) {
+	if fn := limiter.violated; nil != fn {
+		fn(reason, msg)
+	}
+}
+
+// internalInputThrottle holds off reading from the peer, so that it is read from no faster than its
+// RateLimit allows. (See Conn.SetInputRateLimit.)
+type internalInputThrottle struct {
+	limiter *internalRateLimiter
+
+	// throttled (if not nil) is called each time reading had to wait. (It is set before the Conn
+	// is read from.)
+	throttled func()
+}
+
+// allow waits for (up to) 'n' bytes to be allowed to be read; and returns how many are. (Which is
+// 'n', if there is no limit.) It gives up at the read deadline, or once the Conn is closed.
+func (throttle *internalInputThrottle) allow(n int) (int, error) {
+	if n <= 0 {
+		return n, nil
+	}
+
+	tries := 0
+	allowed, err := throttle.limiter.take(n, time.Time{}, true, func(available int) int {
+		tries++
+		if available < 0 || n <= available {
+			return n
+		}
+		return available
+	})
+	if 1 < tries && nil != throttle.throttled {
+		throttle.throttled()
+	}
+
+	return allowed, err
+}
+
+// internalMeteredReader tells an internalInputLimiter about everything read from the client. (And
+// reads from it no faster than the internalInputThrottle allows.)
+type internalMeteredReader struct {
+	reader   io.Reader
+	limiter  *internalInputLimiter
+	throttle *internalInputThrottle
+
+	// received (if not nil) is set to when something was last received. (See Conn.SetKeepalive.)
+	// And traffic is told how much was. (See TrafficMetrics.)
+	received *atomic.Int64
+	traffic  *internalTraffic
+}
+
+func (reader internalMeteredReader) Read(p []byte) (int, error) {
+	allowed, err := reader.throttle.allow(len(p))
+	if nil != err {
+		return 0, err
+	}
+
+	n, err := reader.reader.Read(p[:allowed])
+	reader.throttle.limiter.refund(allowed - n)
+	if 0 < n {
+		if nil != reader.received {
+			reader.received.Store(time.Now().UnixNano())
+		}
+		reader.traffic.received(n)
+		if limitErr := reader.limiter.received(n); nil != limitErr {
+			return 0, limitErr
+		}
+	}
+
+	return n, err
+}
+
+// SetInputLimits sets the limits on what the client can send. (A Server sets them to its
+// InputLimits.) The limits can be changed at any time.
+func (clientConn *Conn) SetInputLimits(limits InputLimits) {
+	clientConn.inputLimiter.set(limits)
+}
+
+// SetInputRateLimit limits how fast the Conn reads from the peer; in bytes (as they come over the
+// wire, including any TELNET commands) per second, with bursts of up to Burst bytes. (A Server
+// sets it to its InputRateLimit.) For example:
+//
+//	conn.SetInputRateLimit(telnet.RateLimit{
+//		BytesPerSecond: 1024,
+//		Burst:          4096,
+//	})
+//
+// Unlike the MaxBytesPerSecond of the InputLimits (which closes the connection of a client that
+// sends too much), this throttles the client: once it sends faster than the limit, the rest of
+// what it sends is only read as fast as the limit allows; and it is left waiting to be read (and
+// so, with TCP, the client is slowed down). A Read waiting on the limit gives up at the read
+// deadline (see SetReadDeadline), with a timeout error; or once the Conn is closed.
+//
+// (The NonBlocking of the RateLimit is ignored; reading always waits.)
+//
+// The zero RateLimit removes the limit. The limit can be changed at any time.
+func (clientConn *Conn) SetInputRateLimit(limit RateLimit) {
+	clientConn.inputThrottle.limiter.set(limit)
+}
+
+// InputLimits returns the limits on what the client can send; as set with SetInputLimits.
+func (clientConn *Conn) InputLimits() InputLimits {
+	return clientConn.inputLimiter.get()
+}
+
+// LineTooLong is for whatever puts together lines from what the client sends (such as
+// telsh.LineEditor) to call when a line is longer than the MaxLineLength of the InputLimits.
+// (Whatever called it should then throw the line away.)
+//
+// It counts the violation, closes the connection (with CloseLineTooLong) if DisconnectOnLongLine
+// is true, and returns ErrLineTooLong.
+func (clientConn *Conn) LineTooLong() error {
+	limits := clientConn.InputLimits()
+
+	if limits.DisconnectOnLongLine {
+		clientConn.inputLimiter.violate(CloseLineTooLong, fmt.Sprintf("sent a line longer than %d bytes", limits.MaxLineLength))
+	} else if fn := clientConn.onViolation; nil != fn {
+		fn(CloseLineTooLong)
+	}
+
+	return ErrLineTooLong
+}
+
+// violated closes the connection, because the client exceeded one of its InputLimits.
+func (clientConn *Conn) violated(reason CloseReason, msg string) {
+	clientConn.logger.Warnf("Closing connection, because it %s.", msg)
+
+	if fn := clientConn.onViolation; nil != fn {
+		fn(reason)
+	}
+
+	clientConn.CloseWithReason(reason, msg)
+}
+
+// InputViolations returns how many times (so far) the server's connections exceeded each of
+// the InputLimits.
+func (server *Server) InputViolations() InputViolations {
+	return server.violations.snapshot()
+}
@@ -0,0 +1,211 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestInternalRateMeter(t *testing.T) {
+
+	begin := time.Now()
+
+	tests := []struct {
+		After    time.Duration
+		N        int
+		Expected bool
+	}{
+		{After: 0, N: 6, Expected: true},
+		{After: 0, N: 4, Expected: true},
+		{After: 0, N: 1, Expected: false},
+		{After: 500 * time.Millisecond, N: 4, Expected: true},
+		{After: 10 * time.Second, N: 10, Expected: true}, // (Never more than a second's worth.)
+		{After: 10 * time.Second, N: 1, Expected: false},
+	}
+
+	var meter internalRateMeter
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, meter.allow(10, test.N, begin.Add(test.After)); expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnInputLimits(t *testing.T) {
+
+	tests := []struct {
+		Limits         InputLimits
+		ClientSends    []byte
+		ExpectedErr    error
+		ExpectedReason CloseReason
+	}{
+		{
+			Limits:      InputLimits{},
+			ClientSends: append([]byte(strings.Repeat("x", 100)), IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'y'),
+		},
+		{
+			Limits:      DefaultInputLimits,
+			ClientSends: append([]byte(strings.Repeat("x", 100)), IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'y'),
+		},
+		{
+			Limits:         InputLimits{MaxBytesPerSecond: 50},
+			ClientSends:    []byte(strings.Repeat("x", 100)),
+			ExpectedErr:    ErrInputFlood,
+			ExpectedReason: CloseInputFlood,
+		},
+		{
+			Limits: InputLimits{MaxSubnegotiationsPerSecond: 2},
+			ClientSends: []byte{
+				IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE,
+				IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE,
+				IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE,
+				'y',
+			},
+			ExpectedErr:    ErrSubnegotiationFlood,
+			ExpectedReason: CloseSubnegotiationFlood,
+		},
+		{
+			Limits:      InputLimits{MaxSubnegotiationLength: 5},
+			ClientSends: []byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'y'},
+		},
+		{
+			// (The IAC SE never comes.)
+			Limits:         InputLimits{MaxSubnegotiationLength: 5},
+			ClientSends:    append([]byte{IAC, SB, OptNAWS}, strings.Repeat("x", 1000)...),
+			ExpectedErr:    ErrSubnegotiationTooLong,
+			ExpectedReason: CloseSubnegotiationTooLong,
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+
+		conn := newConn(local, nil)
+		conn.SetInputLimits(test.Limits)
+
+		go func() {
+			remote.Write(test.ClientSends)
+			remote.Close()
+		}()
+
+		_, err := io.ReadAll(conn)
+		if nil == test.ExpectedErr {
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			}
+			local.Close()
+			continue
+		}
+
+		if expected, actual := test.ExpectedErr, err; expected != actual {
+			t.Errorf("For test #%d, expected error %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+		}
+		if expected, actual := test.ExpectedReason, testCloseReason(conn); expected != actual {
+			t.Errorf("For test #%d, expected close reason %v, but actually got %v.", testNumber, expected, actual)
+		}
+		local.Close()
+	}
+}
+
+func testCloseReason(conn *Conn) CloseReason {
+	reason, _ := conn.CloseReason()
+	return reason
+}
+
+type testLongLineHandler struct{}
+
+func (testLongLineHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	conn := w.(*Conn)
+
+	// Pretends to have read a line that is too long; and then reads until the client is gone.
+	conn.LineTooLong()
+	io.Copy(io.Discard, r)
+}
+
+func TestServerInputLimits(t *testing.T) {
+
+	tests := []struct {
+		Limits             InputLimits
+		ClientSends        []byte
+		ExpectedReason     CloseReason
+		ExpectedViolations InputViolations
+	}{
+		{
+			Limits:             InputLimits{MaxLineLength: 10},
+			ExpectedReason:     ClosePeerClosed,
+			ExpectedViolations: InputViolations{LineTooLong: 1},
+		},
+		{
+			Limits:             InputLimits{MaxLineLength: 10, DisconnectOnLongLine: true},
+			ExpectedReason:     CloseLineTooLong,
+			ExpectedViolations: InputViolations{LineTooLong: 1},
+		},
+		{
+			Limits:             InputLimits{MaxBytesPerSecond: 10},
+			ClientSends:        []byte(strings.Repeat("x", 100)),
+			ExpectedReason:     CloseInputFlood,
+			ExpectedViolations: InputViolations{LineTooLong: 1, InputFlood: 1},
+		},
+		{
+			Limits:             InputLimits{MaxSubnegotiationsPerSecond: 1},
+			ClientSends:        []byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE},
+			ExpectedReason:     CloseSubnegotiationFlood,
+			ExpectedViolations: InputViolations{LineTooLong: 1, SubnegotiationFlood: 1},
+		},
+		{
+			Limits:             InputLimits{MaxSubnegotiationLength: 16},
+			ClientSends:        append([]byte{IAC, SB, OptNAWS}, strings.Repeat("x", 100)...),
+			ExpectedReason:     CloseSubnegotiationTooLong,
+			ExpectedViolations: InputViolations{LineTooLong: 1, SubnegotiationTooLong: 1},
+		},
+	}
+
+	for testNumber, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		reasons := make(chan CloseReason, 1)
+
+		server := &Server{
+			Handler:     testLongLineHandler{},
+			InputLimits: test.Limits,
+			OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+				reasons <- reason
+			},
+		}
+		go server.Serve(listener)
+
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		client.Write(test.ClientSends)
+		if ClosePeerClosed == test.ExpectedReason {
+			// (Nothing closes the connection but the client.)
+			time.Sleep(50 * time.Millisecond)
+			client.Close()
+		}
+
+		select {
+		case reason := <-reasons:
+			if expected, actual := test.ExpectedReason, reason; expected != actual {
+				t.Errorf("For test #%d, expected close reason %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("For test #%d, timed out waiting for OnDisconnect to be called.", testNumber)
+		}
+
+		// (Each test has a server of its own; and so only counts its own violations.)
+		if expected, actual := test.ExpectedViolations, server.InputViolations(); expected != actual {
+			t.Errorf("For test #%d, expected %+v, but actually got %+v.", testNumber, expected, actual)
+		}
+
+		client.Close()
+		listener.Close()
+	}
+}
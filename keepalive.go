@@ -0,0 +1,152 @@
+package telnet
+
+import (
+	"time"
+)
+
+// Keepalive configures the keepalives of a Conn. (See Conn.SetKeepalive.)
+type Keepalive struct {
+	// Interval (if not zero) is how long nothing has to have been received from the peer for,
+	// before a keepalive is sent; and then how long to wait before sending the next one (if still
+	// nothing has been received).
+	Interval time.Duration
+
+	// Timeout (if not zero) is how long nothing can be received from the peer for; after which the
+	// peer is taken to be dead, and the Conn is closed (with CloseDeadPeer).
+	Timeout time.Duration
+
+	// Command is the TELNET command that is sent as the keepalive; which is NOP (if it is zero), or
+	// AYT. (Most peers answer an AYT; such as with "[Yes]". Which is data, and so is read, like any
+	// other data. But it is what tells a peer that is otherwise quiet from one that is dead.)
+	Command byte
+}
+
+// DeadPeerError is what reading from a Conn returns once it has been closed because nothing was
+// received from the peer for longer than the Timeout of its Keepalive. (See SetKeepalive.) It is
+// ErrClosed; i.e., errors.Is(err, telnet.ErrClosed) is true for it. For example:
+//
+//	telnet: peer is not responding (nothing received for 1m30s)
+type DeadPeerError struct {
+	Silence time.Duration // How long nothing was received for.
+}
+
+func (err *DeadPeerError) Error() string {
+	return "telnet: peer is not responding (nothing received for " + err.Silence.String() + ")"
+}
+
+func (err *DeadPeerError) Unwrap() error {
+	return ErrClosed
+}
+
+// SetKeepalive sets (or, with the zero Keepalive, stops) the keepalives of the Conn. This is for
+// long lived connections; such as to a device behind a NAT, which could otherwise go away without
+// the Conn ever finding out. For example:
+//
+//	conn.SetKeepalive(telnet.Keepalive{
+//		Interval: 30 * time.Second,
+//		Timeout:  90 * time.Second,
+//		Command:  telnet.AYT,
+//	})
+//
+// Once nothing has been received from the peer for the Interval, the Command (IAC NOP, unless it
+// is said otherwise) is sent; and then again, every Interval, for as long as nothing is received.
+// (Sending it keeps the NAT from forgetting about the connection; and sending it to a peer that is
+// gone has the connection fail, once TCP gives up on it.)
+//
+// If nothing has been received from the peer for the Timeout, then the Conn is closed (with
+// CloseDeadPeer); and reading from it returns a *DeadPeerError. (An IAC NOP is not answered; so,
+// for a peer that can be quiet for longer than the Timeout, the Command is to be AYT.)
+//
+// What is received is only known of once it is read; so the Conn has to be read from (as it has
+// to be anyway, for the peer's negotiations to be answered), for it to not be taken to be dead.
+func (clientConn *Conn) SetKeepalive(keepalive Keepalive) {
+	if 0 == keepalive.Command {
+		keepalive.Command = NOP
+	}
+
+	clientConn.keepaliveMutex.Lock()
+	defer clientConn.keepaliveMutex.Unlock()
+
+	if nil != clientConn.keepaliveStop {
+		close(clientConn.keepaliveStop)
+		clientConn.keepaliveStop = nil
+	}
+	if keepalive.Interval <= 0 && keepalive.Timeout <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	clientConn.keepaliveStop = stop
+	clientConn.spawn(func() {
+		clientConn.keepAlive(keepalive, stop)
+	})
+}
+
+// keepAlive sends the keepalives (and closes the Conn, once the peer is taken to be dead); until
+// 'stop' is closed, or the Conn is.
+func (clientConn *Conn) keepAlive(keepalive Keepalive, stop <-chan struct{}) {
+	started := time.Now()
+	var sent time.Time
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		received := time.Unix(0, clientConn.received.Load())
+		if received.Before(started) {
+			received = started
+		}
+		silence := now.Sub(received)
+
+		if 0 < keepalive.Timeout && keepalive.Timeout <= silence {
+			clientConn.deadPeerClose(silence)
+			return
+		}
+
+		// (The next one is an Interval after what was received, or sent, last.)
+		var next time.Time
+		if 0 < keepalive.Interval {
+			last := received
+			if last.Before(sent) {
+				last = sent
+			}
+			next = last.Add(keepalive.Interval)
+
+			if !now.Before(next) {
+				if err := clientConn.SendCommand(keepalive.Command); nil != err {
+					clientConn.logger.Debugf("Problem sending the keepalive: %v", err)
+				}
+				sent = now
+				next = now.Add(keepalive.Interval)
+			}
+		}
+		if 0 < keepalive.Timeout {
+			if dead := received.Add(keepalive.Timeout); next.IsZero() || dead.Before(next) {
+				next = dead
+			}
+		}
+
+		timer.Reset(next.Sub(now))
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		case <-clientConn.done:
+			return
+		}
+	}
+}
+
+// deadPeerClose closes the Conn; because nothing was received from the peer for 'silence'.
+func (clientConn *Conn) deadPeerClose(silence time.Duration) {
+	err := &DeadPeerError{Silence: silence.Round(time.Millisecond)}
+
+	clientConn.keepaliveMutex.Lock()
+	if nil == clientConn.deadPeer {
+		clientConn.deadPeer = err
+	}
+	clientConn.keepaliveMutex.Unlock()
+
+	clientConn.CloseWithReason(CloseDeadPeer, err.Error())
+}
@@ -0,0 +1,134 @@
+package telnet
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnKeepaliveProbes(t *testing.T) {
+
+	tests := []struct {
+		Command  byte
+		Expected []byte
+	}{
+		{Command: 0, Expected: []byte{IAC, NOP, IAC, NOP}},
+		{Command: AYT, Expected: []byte{IAC, AYT, IAC, AYT}},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+		conn.SetKeepalive(Keepalive{Interval: 20 * time.Millisecond, Command: test.Command})
+
+		if expected, actual := string(test.Expected), string(testReadExactly(t, remote, 4)); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		// (With the zero Keepalive, no more are sent.)
+		conn.SetKeepalive(Keepalive{})
+		remote.SetReadDeadline(time.Now().Add(80 * time.Millisecond))
+		if n, err := remote.Read(make([]byte, 2)); !isTimeout(err) {
+			t.Errorf("For test #%d, did not expect another keepalive, but actually got %d bytes (%v).", testNumber, n, err)
+			continue
+		}
+	}
+}
+
+func TestConnKeepaliveDeadPeer(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	conn.SetKeepalive(Keepalive{Timeout: 50 * time.Millisecond})
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 16))
+		errs <- err
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the Conn to be closed, but it actually was not.")
+	}
+
+	var deadPeerErr *DeadPeerError
+	if !errors.As(err, &deadPeerErr) {
+		t.Fatalf("Expected a *DeadPeerError, but actually got: (%T) %v", err, err)
+	}
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected %v, but actually got: (%T) %v", ErrClosed, err, err)
+	}
+	if deadPeerErr.Silence < 50*time.Millisecond {
+		t.Errorf("Expected a silence of at least %v, but actually got %v.", 50*time.Millisecond, deadPeerErr.Silence)
+	}
+	if reason, _ := conn.CloseReason(); CloseDeadPeer != reason {
+		t.Errorf("Expected %v, but actually got %v.", CloseDeadPeer, reason)
+	}
+}
+
+func TestConnKeepaliveTraffic(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		p := make([]byte, 16)
+		for {
+			if _, err := conn.Read(p); nil != err {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	conn.SetKeepalive(Keepalive{Interval: 40 * time.Millisecond, Timeout: 80 * time.Millisecond})
+
+	// (For as long as the peer sends something, it is not taken to be dead; and no keepalives are
+	// sent to it.)
+	probes := make(chan []byte, 16)
+	go func() {
+		for {
+			p := make([]byte, 2)
+			n, err := remote.Read(p)
+			if nil != err {
+				return
+			}
+			probes <- p[:n]
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		remote.Write([]byte("."))
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	select {
+	case err := <-errs:
+		t.Fatalf("Did not expect the Conn to be closed, but it actually was: (%T) %v", err, err)
+	case p := <-probes:
+		t.Fatalf("Did not expect a keepalive, but actually got %q.", p)
+	default:
+	}
+
+	// (Once it stops, it is.)
+	select {
+	case err := <-errs:
+		var deadPeerErr *DeadPeerError
+		if !errors.As(err, &deadPeerErr) {
+			t.Errorf("Expected a *DeadPeerError, but actually got: (%T) %v", err, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the Conn to be closed, but it actually was not.")
+	}
+}
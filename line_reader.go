@@ -0,0 +1,88 @@
+package telnet
+
+import (
+	"strings"
+)
+
+// A LineReader reads (complete) lines from a Conn; with the end of a line being any of what the
+// TELNET clients out there send for it. I.e., CR LF (which is what RFC 854 says it is), CR NUL
+// (which is what some clients send for the Enter key), a bare LF, or a bare CR. For example:
+//
+//	lines := telnet.NewLineReader(conn)
+//	for {
+//		line, err := lines.ReadLine()
+//		if nil != err {
+//			return err
+//		}
+//
+//		//@TODO: Do something with 'line'.
+//	}
+//
+// (Unlike with Conn.ReadLine; which only takes LF, or CR LF, as the end of a line. A CR is taken to
+// be the end of the line as soon as it is read; and the LF, or NUL, after it is skipped once the
+// next line is read. So ReadLine does not wait for what comes after a CR.)
+//
+// A line longer than the MaxLineLength of the InputLimits of the Conn (see SetInputLimits) is
+// thrown away; and ReadLine then returns ErrLineTooLong (see Conn.LineTooLong).
+//
+// (Use the same LineReader for all of the lines read from a Conn; as it keeps track of whether
+// the last line ended with a CR.)
+type LineReader struct {
+	conn *Conn
+
+	// afterCR is whether the last line ended with a CR; so that a LF, or NUL, right after it
+	// (i.e., at the start of the next line) is skipped.
+	afterCR bool
+}
+
+// NewLineReader returns a LineReader that reads lines from 'conn'.
+func NewLineReader(conn *Conn) *LineReader {
+	return &LineReader{conn: conn}
+}
+
+// ReadLine reads a line; and returns it (without the end of the line). The bytes that are not
+// (valid) UTF-8 are dealt with according to the policy set with Conn.SetInvalidUTF8Policy.
+//
+// If reading fails before the end of the line, then ReadLine returns what it read, and why.
+func (reader *LineReader) ReadLine() (string, error) {
+	var line strings.Builder
+	var tooLong error
+
+	for {
+		r, size, err := reader.conn.ReadRune()
+		if nil != err {
+			if nil != tooLong {
+				return "", tooLong
+			}
+			return line.String(), err
+		}
+
+		afterCR := reader.afterCR
+		reader.afterCR = false
+
+		switch {
+		case afterCR && ('\n' == r || 0 == r):
+			// (That is the rest of the end of the last line.)
+			continue
+		case '\n' == r || '\r' == r:
+			reader.afterCR = '\r' == r
+			if nil != tooLong {
+				return "", tooLong
+			}
+			return line.String(), nil
+		case 0 == r:
+			// (A NUL, other than after a CR, is nothing; and is thrown away.)
+			continue
+		}
+
+		if nil != tooLong {
+			continue
+		}
+		if limit := reader.conn.InputLimits().MaxLineLength; 0 < limit && limit < line.Len()+size {
+			tooLong = reader.conn.LineTooLong()
+			line.Reset()
+			continue
+		}
+		line.WriteRune(r)
+	}
+}
@@ -0,0 +1,71 @@
+package telnet
+
+import (
+	"testing"
+)
+
+func TestLineReaderReadLine(t *testing.T) {
+
+	tests := []struct {
+		MaxLineLength int
+		Sends         [][]byte
+		Expected      []string
+		Errors        []error // (One for each line.)
+	}{
+		{
+			Sends:    [][]byte{[]byte("one\r\ntwo\r\x00three\nfour\rfive\r\n")},
+			Expected: []string{"one", "two", "three", "four", "five"},
+		},
+		{
+			// (The LF, or NUL, after a CR can come in a read of its own.)
+			Sends:    [][]byte{[]byte("one\r"), []byte("\ntwo\r"), []byte("\x00three\r"), []byte("four\r\n")},
+			Expected: []string{"one", "two", "three", "four"},
+		},
+		{
+			// (An empty line, after a CR.)
+			Sends:    [][]byte{[]byte("one\r\r\n\ntwo\n")},
+			Expected: []string{"one", "", "", "two"},
+		},
+		{
+			// (A NUL, other than after a CR, is thrown away.)
+			Sends:    [][]byte{[]byte("o\x00ne\n")},
+			Expected: []string{"one"},
+		},
+		{
+			Sends:    [][]byte{[]byte("h\xc3\xa9llo\r\n")},
+			Expected: []string{"héllo"},
+		},
+		{
+			MaxLineLength: 4,
+			Sends:         [][]byte{[]byte("four\r\nfive!\r\nsix\r\n")},
+			Expected:      []string{"four", "", "six"},
+			Errors:        []error{nil, ErrLineTooLong, nil},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		conn.SetInputLimits(InputLimits{MaxLineLength: test.MaxLineLength})
+
+		for _, p := range test.Sends {
+			send(p...)
+		}
+
+		reader := NewLineReader(conn)
+		for i, expected := range test.Expected {
+			var expectedErr error
+			if i < len(test.Errors) {
+				expectedErr = test.Errors[i]
+			}
+
+			actual, err := reader.ReadLine()
+			if expectedErr != err {
+				t.Errorf("For test #%d and line #%d, expected the error %v, but actually got: (%T) %v", testNumber, i, expectedErr, err, err)
+				break
+			}
+			if expected != actual {
+				t.Errorf("For test #%d and line #%d, expected %q, but actually got %q.", testNumber, i, expected, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package telnet
+
+import (
+	"sync"
+)
+
+// The LINEMODE (RFC 1184) modes; which are OR'ed together, for RequestLinemode (and
+// SetLinemodeMode).
+const (
+	LinemodeEdit    byte = 1  // The client edits the line (such as for erasing a character) before sending it.
+	LinemodeTrapSig byte = 2  // The client sends signals (such as IAC IP for Ctrl-C), rather than the characters for them.
+	LinemodeSoftTab byte = 8  // The client expands tabs to spaces.
+	LinemodeLitEcho byte = 16 // The client echoes non-printable characters as they are; rather than as ^X.
+
+	linemodeModeAck byte = 4 // (Set by the client, in its answer to a MODE.)
+)
+
+// The LINEMODE (RFC 1184) SLC ("set local characters") functions; i.e., what LinemodeSLC is
+// asked about. (Such as SLCInterruptProcess, which is usually Ctrl-C.)
+const (
+	SLCSynch            byte = 1
+	SLCBreak            byte = 2
+	SLCInterruptProcess byte = 3
+	SLCAbortOutput      byte = 4
+	SLCAreYouThere      byte = 5
+	SLCEndOfRecord      byte = 6
+	SLCAbort            byte = 7
+	SLCEOF              byte = 8
+	SLCSuspend          byte = 9
+	SLCEraseChar        byte = 10
+	SLCEraseLine        byte = 11
+	SLCEraseWord        byte = 12
+	SLCReprint          byte = 13
+	SLCLiteralNext      byte = 14
+	SLCXon              byte = 15
+	SLCXoff             byte = 16
+	SLCForward1         byte = 17
+	SLCForward2         byte = 18
+
+	slcFunctions = 19
+)
+
+// The LINEMODE subnegotiation commands; and the levels (and flags) of an SLC triplet.
+const (
+	linemodeMODE        = 1
+	linemodeFORWARDMASK = 2
+	linemodeSLC         = 3
+
+	slcNoSupport  byte = 0
+	slcCantChange byte = 1
+	slcValue      byte = 2
+	slcDefault    byte = 3
+	slcLevelBits  byte = 3
+	slcFlushOut   byte = 32
+	slcFlushIn    byte = 64
+	slcAck        byte = 128
+)
+
+// internalSLC is (the flags, and the value of) one of the SLC functions.
+type internalSLC struct {
+	flags byte
+	value byte
+}
+
+func (slc internalSLC) level() byte {
+	return slc.flags & slcLevelBits
+}
+
+// linemodeDefaultSLC are the characters a server has the SLC functions be, unless the client says
+// otherwise; which are what is usual on UNIX. (Indexed by the SLC function.)
+var linemodeDefaultSLC = [slcFunctions]internalSLC{
+	SLCInterruptProcess: {flags: slcValue | slcFlushIn | slcFlushOut, value: 0x03}, // Ctrl-C
+	SLCAbortOutput:      {flags: slcValue | slcFlushOut, value: 0x0f},              // Ctrl-O
+	SLCAreYouThere:      {flags: slcValue, value: 0x14},                            // Ctrl-T
+	SLCAbort:            {flags: slcValue | slcFlushIn | slcFlushOut, value: 0x1c}, // Ctrl-\
+	SLCEOF:              {flags: slcValue, value: 0x04},                            // Ctrl-D
+	SLCSuspend:          {flags: slcValue | slcFlushIn, value: 0x1a},               // Ctrl-Z
+	SLCEraseChar:        {flags: slcValue, value: 0x7f},                            // DEL
+	SLCEraseLine:        {flags: slcValue, value: 0x15},                            // Ctrl-U
+	SLCEraseWord:        {flags: slcValue, value: 0x17},                            // Ctrl-W
+	SLCReprint:          {flags: slcValue, value: 0x12},                            // Ctrl-R
+	SLCLiteralNext:      {flags: slcValue, value: 0x16},                            // Ctrl-V
+	SLCXon:              {flags: slcValue, value: 0x11},                            // Ctrl-Q
+	SLCXoff:             {flags: slcValue, value: 0x13},                            // Ctrl-S
+}
+
+// RequestLinemode asks the peer to do LINEMODE (RFC 1184), with 'mode' (such as LinemodeEdit |
+// LinemodeTrapSig); i.e., sends a DO for LINEMODE. This is for servers; such as a MUD, or a BBS,
+// that would rather the client edit (and send) a line at a time. For example:
+//
+//	if err := conn.RequestLinemode(telnet.LinemodeEdit | telnet.LinemodeTrapSig); nil != err {
+//		return err
+//	}
+//
+//	lines := telnet.NewLineReader(conn)
+//	for {
+//		line, err := lines.ReadLine()
+//		if nil != err {
+//			return err
+//		}
+//
+//		//@TODO: Do something with 'line'.
+//	}
+//
+// Once the peer agrees to it, it sends the mode (with MODE); and which characters the SLC
+// functions are (such as Ctrl-C for SLCInterruptProcess). The mode the peer then acknowledges is
+// what LinemodeMode returns. The characters the peer would rather the SLC functions be are agreed
+// to (unless it asks for the defaults; which it is then sent); and are what LinemodeSLC returns.
+//
+// (It registers an OptionHandler for LINEMODE; replacing any that was registered before.)
+func (clientConn *Conn) RequestLinemode(mode byte) error {
+	handler := &internalLinemode{
+		mode: mode &^ linemodeModeAck,
+		slc:  linemodeDefaultSLC,
+	}
+
+	clientConn.linemodeMutex.Lock()
+	clientConn.linemode = handler
+	clientConn.linemodeMutex.Unlock()
+
+	return clientConn.RegisterOption(OptLinemode, handler)
+}
+
+// SetLinemodeMode changes the LINEMODE mode (see RequestLinemode); such as to turn LinemodeEdit off
+// while running a full-screen program. If the peer is doing LINEMODE, then the new mode is sent
+// right away; otherwise once it agrees to it. (It does nothing, if RequestLinemode was not called.)
+func (clientConn *Conn) SetLinemodeMode(mode byte) error {
+	clientConn.linemodeMutex.Lock()
+	handler := clientConn.linemode
+	clientConn.linemodeMutex.Unlock()
+
+	if nil == handler {
+		return nil
+	}
+	return handler.setMode(mode &^ linemodeModeAck)
+}
+
+// LinemodeMode returns the LINEMODE mode that the peer acknowledged (see RequestLinemode); i.e.,
+// the one it is in. 'ok' is false if it has not acknowledged one (or has since disabled LINEMODE).
+func (clientConn *Conn) LinemodeMode() (mode byte, ok bool) {
+	clientConn.linemodeMutex.Lock()
+	handler := clientConn.linemode
+	clientConn.linemodeMutex.Unlock()
+
+	if nil == handler {
+		return 0, false
+	}
+	return handler.acknowledged()
+}
+
+// LinemodeSLC returns which character the SLC function 'function' (such as SLCEraseChar) is, with
+// LINEMODE (see RequestLinemode). 'ok' is false if the function is not supported; or if
+// RequestLinemode was not called.
+func (clientConn *Conn) LinemodeSLC(function byte) (value byte, ok bool) {
+	clientConn.linemodeMutex.Lock()
+	handler := clientConn.linemode
+	clientConn.linemodeMutex.Unlock()
+
+	if nil == handler || slcFunctions <= int(function) {
+		return 0, false
+	}
+	return handler.function(function)
+}
+
+// internalLinemode is the (LINEMODE) OptionHandler that RequestLinemode registers.
+type internalLinemode struct {
+	mutex   sync.Mutex
+	sender  OptionSender
+	enabled bool
+	mode    byte
+
+	// acked is the mode that the peer acknowledged (if 'ackedOK' is true).
+	acked   byte
+	ackedOK bool
+
+	slc [slcFunctions]internalSLC
+}
+
+func (linemode *internalLinemode) Register(sender OptionSender) OptionSupport {
+	linemode.mutex.Lock()
+	linemode.sender = sender
+	linemode.mutex.Unlock()
+
+	return OptionSupport{
+		Remote:        true,
+		RequestRemote: true,
+	}
+}
+
+func (*internalLinemode) LocalChanged(bool) {}
+
+func (linemode *internalLinemode) RemoteChanged(enabled bool) {
+	linemode.mutex.Lock()
+	linemode.enabled = enabled
+	linemode.acked, linemode.ackedOK = 0, false
+	linemode.mutex.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	linemode.sendMode()
+	linemode.sendSLC(linemode.table())
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB LINEMODE MODE <mask> IAC SE
+//	IAC SB LINEMODE SLC <function> <flags> <value> ... IAC SE
+//
+// (And ignores the FORWARDMASK ones; as it never asks for FORWARDMASK.)
+func (linemode *internalLinemode) Subnegotiation(payload []byte) {
+	if len(payload) < 1 {
+		return
+	}
+
+	switch payload[0] {
+	case linemodeMODE:
+		if len(payload) < 2 {
+			return
+		}
+		linemode.receiveMode(payload[1])
+	case linemodeSLC:
+		linemode.receiveSLC(payload[1:])
+	}
+}
+
+// receiveMode handles the mode the peer sent. If it has MODE_ACK set, then that is the mode the
+// peer is now in. (One without it, the peer is not to send; so it is ignored. As is one that is
+// the same as the one it is in; as answering it could go back and forth forever. Per RFC 1184.)
+func (linemode *internalLinemode) receiveMode(mask byte) {
+	if 0 == mask&linemodeModeAck {
+		return
+	}
+
+	linemode.mutex.Lock()
+	defer linemode.mutex.Unlock()
+
+	linemode.acked, linemode.ackedOK = mask&^linemodeModeAck, true
+}
+
+// receiveSLC handles the SLC triplets the peer sent; and answers those it has to (all in one
+// subnegotiation).
+func (linemode *internalLinemode) receiveSLC(triplets []byte) {
+	var answer []byte
+
+	linemode.mutex.Lock()
+	for ; 3 <= len(triplets); triplets = triplets[3:] {
+		function, flags, value := triplets[0], triplets[1], triplets[2]
+		theirs := internalSLC{flags: flags &^ slcAck, value: value}
+
+		if 0 == function {
+			// (0 SLC_DEFAULT 0 asks for the defaults; and 0 SLC_VARIABLE 0 for what they are now.)
+			if slcDefault == theirs.level() {
+				linemode.slc = linemodeDefaultSLC
+			}
+			answer = appendSLC(answer, linemode.slc[:])
+			continue
+		}
+		if slcFunctions <= int(function) || 0 != flags&slcAck {
+			continue
+		}
+
+		ours := &linemode.slc[function]
+		switch {
+		case slcDefault == theirs.level():
+			*ours = linemodeDefaultSLC[function]
+			answer = append(answer, function, ours.flags, ours.value)
+		case ours.level() == theirs.level() && ours.value == theirs.value:
+			// (It is already agreed.)
+		case slcCantChange == ours.level():
+			answer = append(answer, function, ours.flags, ours.value)
+		default:
+			*ours = theirs
+			answer = append(answer, function, theirs.flags|slcAck, theirs.value)
+		}
+	}
+	sender := linemode.sender
+	linemode.mutex.Unlock()
+
+	if 0 == len(answer) || nil == sender {
+		return
+	}
+	sender.SendSubnegotiation(append([]byte{linemodeSLC}, answer...))
+}
+
+// appendSLC appends an SLC triplet for each of the (supported) functions in 'table'.
+func appendSLC(p []byte, table []internalSLC) []byte {
+	for function, slc := range table {
+		if 0 == function || slcNoSupport == slc.level() {
+			continue
+		}
+		p = append(p, byte(function), slc.flags, slc.value)
+	}
+	return p
+}
+
+func (linemode *internalLinemode) setMode(mode byte) error {
+	linemode.mutex.Lock()
+	linemode.mode = mode
+	enabled := linemode.enabled
+	linemode.mutex.Unlock()
+
+	if !enabled {
+		return nil
+	}
+	return linemode.sendMode()
+}
+
+// sendMode sends:
+//
+//	IAC SB LINEMODE MODE <mask> IAC SE
+func (linemode *internalLinemode) sendMode() error {
+	linemode.mutex.Lock()
+	sender := linemode.sender
+	mode := linemode.mode
+	linemode.mutex.Unlock()
+
+	if nil == sender {
+		return nil
+	}
+	return sender.SendSubnegotiation([]byte{linemodeMODE, mode})
+}
+
+// sendSLC sends:
+//
+//	IAC SB LINEMODE SLC <function> <flags> <value> ... IAC SE
+//
+// ... with a triplet for each of the (supported) functions in 'table'.
+func (linemode *internalLinemode) sendSLC(table [slcFunctions]internalSLC) error {
+	linemode.mutex.Lock()
+	sender := linemode.sender
+	linemode.mutex.Unlock()
+
+	if nil == sender {
+		return nil
+	}
+	return sender.SendSubnegotiation(appendSLC([]byte{linemodeSLC}, table[:]))
+}
+
+func (linemode *internalLinemode) table() [slcFunctions]internalSLC {
+	linemode.mutex.Lock()
+	defer linemode.mutex.Unlock()
+
+	return linemode.slc
+}
+
+func (linemode *internalLinemode) acknowledged() (byte, bool) {
+	linemode.mutex.Lock()
+	defer linemode.mutex.Unlock()
+
+	return linemode.acked, linemode.ackedOK
+}
+
+func (linemode *internalLinemode) function(function byte) (byte, bool) {
+	linemode.mutex.Lock()
+	defer linemode.mutex.Unlock()
+
+	slc := linemode.slc[function]
+	if slcNoSupport == slc.level() {
+		return 0, false
+	}
+	return slc.value, true
+}
@@ -0,0 +1,152 @@
+package telnet
+
+import (
+	"bytes"
+	"time"
+
+	"testing"
+)
+
+func TestConnRequestLinemode(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RequestLinemode(LinemodeEdit | LinemodeTrapSig)
+	}()
+
+	if expected, actual := string([]byte{IAC, DO, OptLinemode}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, ok := conn.LinemodeMode(); ok {
+		t.Errorf("Expected the mode to not have been acknowledged yet, but actually it was.")
+	}
+
+	remote.Write([]byte{IAC, WILL, OptLinemode})
+
+	mode := string([]byte{IAC, SB, OptLinemode, linemodeMODE, LinemodeEdit | LinemodeTrapSig, IAC, SE})
+	if expected, actual := mode, string(testReadExactly(t, remote, len(mode))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	slc := string(append(appendSLC([]byte{IAC, SB, OptLinemode, linemodeSLC}, linemodeDefaultSLC[:]), IAC, SE))
+	if expected, actual := slc, string(testReadExactly(t, remote, len(slc))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (The peer acknowledges the mode; and would rather erase characters with BS.)
+	remote.Write([]byte{IAC, SB, OptLinemode, linemodeMODE, LinemodeEdit | LinemodeTrapSig | linemodeModeAck, IAC, SE})
+	remote.Write([]byte{IAC, SB, OptLinemode, linemodeSLC, SLCEraseChar, slcValue, 0x08, IAC, SE})
+
+	ack := string([]byte{IAC, SB, OptLinemode, linemodeSLC, SLCEraseChar, slcValue | slcAck, 0x08, IAC, SE})
+	if expected, actual := ack, string(testReadExactly(t, remote, len(ack))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if mode, ok := conn.LinemodeMode(); !ok || LinemodeEdit|LinemodeTrapSig != mode {
+		t.Errorf("Expected %d (and ok), but actually got %d (and %t).", LinemodeEdit|LinemodeTrapSig, mode, ok)
+	}
+	if value, ok := conn.LinemodeSLC(SLCEraseChar); !ok || 0x08 != value {
+		t.Errorf("Expected %d (and ok), but actually got %d (and %t).", 0x08, value, ok)
+	}
+	if value, ok := conn.LinemodeSLC(SLCInterruptProcess); !ok || 0x03 != value {
+		t.Errorf("Expected %d (and ok), but actually got %d (and %t).", 0x03, value, ok)
+	}
+	if _, ok := conn.LinemodeSLC(SLCForward1); ok {
+		t.Errorf("Expected FORW1 to not be supported, but actually it was.")
+	}
+
+	// (Changing the mode sends it right away.)
+	sent := testReadLater(remote, len(mode))
+	if err := conn.SetLinemodeMode(LinemodeEdit); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	select {
+	case actual := <-sent:
+		if expected := string([]byte{IAC, SB, OptLinemode, linemodeMODE, LinemodeEdit, IAC, SE}); expected != string(actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the mode to be sent, but actually it was not.")
+	}
+}
+
+// testSubnegotiationSender is an OptionSender that records the subnegotiations sent with it. (The
+// rest of OptionSender it does not do.)
+type testSubnegotiationSender struct {
+	OptionSender
+	sent [][]byte
+}
+
+func (sender *testSubnegotiationSender) SendSubnegotiation(payload []byte) error {
+	sender.sent = append(sender.sent, append([]byte(nil), payload...))
+	return nil
+}
+
+func TestInternalLinemodeReceiveSLC(t *testing.T) {
+
+	tests := []struct {
+		Ours     internalSLC
+		Triplet  []byte
+		Expected []byte // (The answer; nil for none.)
+		Value    byte
+	}{
+		{
+			// (Agreed to.)
+			Ours:     internalSLC{flags: slcValue, value: 0x7f},
+			Triplet:  []byte{SLCEraseChar, slcValue, 0x08},
+			Expected: []byte{SLCEraseChar, slcValue | slcAck, 0x08},
+			Value:    0x08,
+		},
+		{
+			// (Already agreed.)
+			Ours:     internalSLC{flags: slcValue, value: 0x08},
+			Triplet:  []byte{SLCEraseChar, slcValue, 0x08},
+			Expected: nil,
+			Value:    0x08,
+		},
+		{
+			// (An acknowledgement is not answered.)
+			Ours:     internalSLC{flags: slcValue, value: 0x7f},
+			Triplet:  []byte{SLCEraseChar, slcValue | slcAck, 0x08},
+			Expected: nil,
+			Value:    0x7f,
+		},
+		{
+			Ours:     internalSLC{flags: slcCantChange, value: 0x7f},
+			Triplet:  []byte{SLCEraseChar, slcValue, 0x08},
+			Expected: []byte{SLCEraseChar, slcCantChange, 0x7f},
+			Value:    0x7f,
+		},
+		{
+			Ours:     internalSLC{flags: slcValue, value: 0x08},
+			Triplet:  []byte{SLCEraseChar, slcDefault, 0},
+			Expected: []byte{SLCEraseChar, slcValue, 0x7f},
+			Value:    0x7f,
+		},
+	}
+
+	for testNumber, test := range tests {
+		sender := &testSubnegotiationSender{}
+		linemode := &internalLinemode{slc: linemodeDefaultSLC, sender: sender}
+		linemode.slc[SLCEraseChar] = test.Ours
+
+		linemode.Subnegotiation(append([]byte{linemodeSLC}, test.Triplet...))
+
+		if nil == test.Expected {
+			if expected, actual := 0, len(sender.sent); expected != actual {
+				t.Errorf("For test #%d, expected %d subnegotiations, but actually got %d.", testNumber, expected, actual)
+			}
+		} else if expected, actual := append([]byte{linemodeSLC}, test.Expected...), sender.sent; 1 != len(actual) || !bytes.Equal(expected, actual[0]) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if value, _ := linemode.function(SLCEraseChar); test.Value != value {
+			t.Errorf("For test #%d, expected %d, but actually got %d.", testNumber, test.Value, value)
+		}
+	}
+}
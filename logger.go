@@ -1,6 +1,12 @@
 package telnet
 
 
+// Logger is what the library logs to; such as the Logger of a Server (see Server.Logger), or of
+// a Dialer (see Dialer.Logger). If none is given, then nothing is logged.
+//
+// What goes on with each connection (such as why it was closed) is logged with Debug; and each
+// TELNET command, and option negotiation, with Trace. (See NewSlogLogger, for logging to a
+// *slog.Logger.)
 type Logger interface{
 	Debug(...interface{})
 	Debugf(string, ...interface{})
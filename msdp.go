@@ -0,0 +1,282 @@
+package telnet
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The bytes that (the subnegotiations of) MSDP are made out of.
+const (
+	msdpVAR        = 1
+	msdpVAL        = 2
+	msdpTableOpen  = 3
+	msdpTableClose = 4
+	msdpArrayOpen  = 5
+	msdpArrayClose = 6
+)
+
+// MSDPOption returns an OptionHandler for the MSDP option (the Mud Server Data Protocol); which
+// sends variables (such as "HEALTH"), each with a value that is a string, an array, or a table,
+// as subnegotiations. For example:
+//
+//	conn.RegisterOption(telnet.OptMSDP, telnet.MSDPOption())
+//
+// It offers (i.e., sends a WILL for) MSDP right away; as a MUD server does. (See SendMSDP to send
+// a variable; and OnMSDP to receive them.)
+func MSDPOption() OptionHandler {
+	return &internalMSDP{}
+}
+
+type internalMSDP struct {
+	mutex  sync.Mutex
+	sender OptionSender
+}
+
+func (msdp *internalMSDP) Register(sender OptionSender) OptionSupport {
+	msdp.mutex.Lock()
+	msdp.sender = sender
+	msdp.mutex.Unlock()
+
+	return OptionSupport{Local: true, Remote: true, RequestLocal: true}
+}
+
+func (*internalMSDP) LocalChanged(enabled bool)  {}
+func (*internalMSDP) RemoteChanged(enabled bool) {}
+
+// Subnegotiation handles:
+//
+//	IAC SB MSDP VAR <name> VAL <value> ... IAC SE
+//
+// ... by passing each of the variables on to what OnMSDP registered.
+func (msdp *internalMSDP) Subnegotiation(payload []byte) {
+	msdp.mutex.Lock()
+	sender := msdp.sender
+	msdp.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	fn := sender.Conn().msdpHandler()
+	if nil == fn {
+		return
+	}
+
+	for _, variable := range decodeMSDP(payload) {
+		fn(variable.name, variable.value)
+	}
+}
+
+// OnMSDP registers 'fn' to be called with each MSDP variable that the peer sends; i.e., its name
+// (such as "HEALTH"; or, from a client, a command such as "REPORT"), and its value. This replaces
+// whatever was registered before. Registering nil stops the calls. For example:
+//
+//	conn.OnMSDP(func(name string, value interface{}) {
+//		if "REPORT" == name {
+//			//@TODO: Send the variables in 'value' whenever they change.
+//		}
+//	})
+//
+// The value is a string; an array ([]interface{}), for an array, or for a variable with more than
+// one value; or a table (map[string]interface{}). (Like what encoding/json decodes into; with the
+// values in the arrays, and tables, being the same.)
+//
+// (MSDPOption, or MUDBridge, has to be registered for MSDP variables to be received.)
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnMSDP(fn func(name string, value interface{})) {
+	clientConn.mudMutex.Lock()
+	defer clientConn.mudMutex.Unlock()
+
+	clientConn.onMSDP = fn
+}
+
+func (clientConn *Conn) msdpHandler() func(name string, value interface{}) {
+	clientConn.mudMutex.RLock()
+	defer clientConn.mudMutex.RUnlock()
+
+	return clientConn.onMSDP
+}
+
+// SendMSDP sends a MSDP variable to the peer; i.e., the variable 'name' (such as "HEALTH"), with
+// 'value' as its value. For example:
+//
+//	err := conn.SendMSDP("HEALTH", 95)
+//
+// ... sends:
+//
+//	IAC SB MSDP VAR 'HEALTH' VAL '95' IAC SE
+//
+// 'value' is turned into MSDP like it would be into JSON (with encoding/json). So a map, or a
+// struct, becomes a table (with its keys in order); a slice, or an array, becomes an array; nil
+// becomes an empty string; and anything else (such as a number, or a bool) becomes a string.
+//
+// It returns ErrOptionNotEnabled if MSDP is not enabled (on either side). (See MSDPOption.)
+func (clientConn *Conn) SendMSDP(name string, value interface{}) error {
+	if local, remote := clientConn.OptionEnabled(OptMSDP); !local && !remote {
+		return ErrOptionNotEnabled
+	}
+
+	payload, err := encodeMSDP(name, value)
+	if nil != err {
+		return err
+	}
+
+	return clientConn.SendSubnegotiation(OptMSDP, payload)
+}
+
+// encodeMSDP returns the (MSDP) subnegotiation payload for the variable 'name', with 'value'.
+func encodeMSDP(name string, value interface{}) ([]byte, error) {
+	// (So that structs, and the rest, are turned into what (plain) JSON is made out of.)
+	encoded, err := json.Marshal(value)
+	if nil != err {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); nil != err {
+		return nil, err
+	}
+
+	payload := append([]byte{msdpVAR}, name...)
+	return appendMSDPValue(payload, decoded), nil
+}
+
+// appendMSDPValue appends VAL, and 'value' (which is what encoding/json decodes into), to 'payload'.
+func appendMSDPValue(payload []byte, value interface{}) []byte {
+	payload = append(payload, msdpVAL)
+
+	switch value := value.(type) {
+	case string:
+		payload = append(payload, value...)
+	case bool:
+		payload = strconv.AppendBool(payload, value)
+	case float64:
+		payload = strconv.AppendFloat(payload, value, 'f', -1, 64)
+	case []interface{}:
+		payload = append(payload, msdpArrayOpen)
+		for _, element := range value {
+			payload = appendMSDPValue(payload, element)
+		}
+		payload = append(payload, msdpArrayClose)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		payload = append(payload, msdpTableOpen)
+		for _, key := range keys {
+			payload = append(append(payload, msdpVAR), key...)
+			payload = appendMSDPValue(payload, value[key])
+		}
+		payload = append(payload, msdpTableClose)
+	}
+
+	return payload
+}
+
+// internalMSDPVariable is a (MSDP) variable; as decodeMSDP returns it.
+type internalMSDPVariable struct {
+	name  string
+	value interface{}
+}
+
+// decodeMSDP returns the variables in the (MSDP) subnegotiation payload 'payload'. Whatever it can
+// not make sense of (such as a TABLE_CLOSE without a TABLE_OPEN) is skipped.
+func decodeMSDP(payload []byte) []internalMSDPVariable {
+	var variables []internalMSDPVariable
+
+	for 0 < len(payload) {
+		if msdpVAR != payload[0] {
+			payload = payload[1:]
+			continue
+		}
+
+		var name string
+		var value interface{}
+		name, value, payload = decodeMSDPVariable(payload[1:])
+		variables = append(variables, internalMSDPVariable{name: name, value: value})
+	}
+
+	return variables
+}
+
+// decodeMSDPVariable decodes (what comes after the VAR of) a variable; and returns its name, its
+// value, and what comes after it. (A variable with more than one VAL has an array as its value.)
+func decodeMSDPVariable(p []byte) (string, interface{}, []byte) {
+	name, p := decodeMSDPString(p)
+
+	var values []interface{}
+	for 0 < len(p) && msdpVAL == p[0] {
+		var value interface{}
+		value, p = decodeMSDPValue(p[1:])
+		values = append(values, value)
+	}
+
+	switch len(values) {
+	case 0:
+		return name, "", p
+	case 1:
+		return name, values[0], p
+	default:
+		return name, values, p
+	}
+}
+
+// decodeMSDPValue decodes (what comes after the VAL of) a value; and returns it, and what comes
+// after it.
+func decodeMSDPValue(p []byte) (interface{}, []byte) {
+	if len(p) <= 0 {
+		return "", p
+	}
+
+	switch p[0] {
+	case msdpArrayOpen:
+		array := []interface{}{}
+		for p = p[1:]; 0 < len(p); {
+			switch p[0] {
+			case msdpArrayClose:
+				return array, p[1:]
+			case msdpVAL:
+				var value interface{}
+				value, p = decodeMSDPValue(p[1:])
+				array = append(array, value)
+			default:
+				p = p[1:]
+			}
+		}
+		return array, p
+	case msdpTableOpen:
+		table := map[string]interface{}{}
+		for p = p[1:]; 0 < len(p); {
+			switch p[0] {
+			case msdpTableClose:
+				return table, p[1:]
+			case msdpVAR:
+				var name string
+				var value interface{}
+				name, value, p = decodeMSDPVariable(p[1:])
+				table[name] = value
+			default:
+				p = p[1:]
+			}
+		}
+		return table, p
+	default:
+		return decodeMSDPString(p)
+	}
+}
+
+// decodeMSDPString returns the string at the start of 'p' (i.e., up to the next MSDP byte); and
+// what comes after it.
+func decodeMSDPString(p []byte) (string, []byte) {
+	k := 0
+	for k < len(p) && msdpArrayClose < p[k] {
+		k++
+	}
+
+	return string(p[:k]), p[k:]
+}
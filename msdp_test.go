@@ -0,0 +1,196 @@
+package telnet
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	"testing"
+)
+
+func TestEncodeMSDP(t *testing.T) {
+
+	tests := []struct {
+		Name     string
+		Value    interface{}
+		Expected []byte
+	}{
+		{
+			Name:     "HEALTH",
+			Value:    95,
+			Expected: []byte("\x01HEALTH\x0295"),
+		},
+		{
+			Name:     "NAME",
+			Value:    "Bubba",
+			Expected: []byte("\x01NAME\x02Bubba"),
+		},
+		{
+			Name:     "AFK",
+			Value:    false,
+			Expected: []byte("\x01AFK\x02false"),
+		},
+		{
+			Name:     "TARGET",
+			Value:    nil,
+			Expected: []byte("\x01TARGET\x02"),
+		},
+		{
+			Name:     "EXITS",
+			Value:    []string{"north", "up"},
+			Expected: []byte("\x01EXITS\x02\x05\x02north\x02up\x06"),
+		},
+		// A table (with its keys in order), with an array in it.
+		{
+			Name:     "ROOM",
+			Value:    map[string]interface{}{"VNUM": 6008, "EXITS": []string{"n"}},
+			Expected: []byte("\x01ROOM\x02\x03\x01EXITS\x02\x05\x02n\x06\x01VNUM\x026008\x04"),
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual, err := encodeMSDP(test.Name, test.Value)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected := test.Expected; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnSendMSDPNotEnabled(t *testing.T) {
+
+	conn, _ := testPipe(t)
+
+	if expected, actual := ErrOptionNotEnabled, conn.SendMSDP("HEALTH", 95); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+	if expected, actual := ErrOptionNotEnabled, conn.SendGMCP("Char.Vitals", nil); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestGMCPOptionReceive(t *testing.T) {
+
+	tests := []struct {
+		Payload      string
+		ExpectedName string
+		ExpectedData string
+	}{
+		{
+			Payload:      `Core.Hello {"client":"Mudlet","version":"4.17"}`,
+			ExpectedName: "Core.Hello",
+			ExpectedData: `{"client":"Mudlet","version":"4.17"}`,
+		},
+		{
+			Payload:      "Core.Ping",
+			ExpectedName: "Core.Ping",
+		},
+	}
+
+	for testNumber, test := range tests {
+		var name string
+		var data []byte
+		GMCPOption(func(receivedName string, receivedData []byte) {
+			name, data = receivedName, receivedData
+		}).Subnegotiation([]byte(test.Payload))
+
+		if expected, actual := test.ExpectedName, name; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedData, string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestDecodeMSDP(t *testing.T) {
+
+	tests := []struct {
+		Payload  []byte
+		Expected []internalMSDPVariable
+	}{
+		{
+			Payload:  []byte("\x01HEALTH\x0295"),
+			Expected: []internalMSDPVariable{{name: "HEALTH", value: "95"}},
+		},
+		{
+			// (A client asking for variables; each VAL of which is one of them.)
+			Payload:  []byte("\x01REPORT\x02HEALTH\x02MANA"),
+			Expected: []internalMSDPVariable{{name: "REPORT", value: []interface{}{"HEALTH", "MANA"}}},
+		},
+		{
+			Payload: []byte("\x01TARGET\x02\x01LIST\x02COMMANDS"),
+			Expected: []internalMSDPVariable{
+				{name: "TARGET", value: ""},
+				{name: "LIST", value: "COMMANDS"},
+			},
+		},
+		{
+			Payload: []byte("\x01ROOM\x02\x03\x01EXITS\x02\x05\x02n\x02up\x06\x01VNUM\x026008\x04"),
+			Expected: []internalMSDPVariable{{name: "ROOM", value: map[string]interface{}{
+				"EXITS": []interface{}{"n", "up"},
+				"VNUM":  "6008",
+			}}},
+		},
+		{
+			// (An array that is not closed; and a stray TABLE_CLOSE.)
+			Payload:  []byte("\x04\x01EXITS\x02\x05\x02n"),
+			Expected: []internalMSDPVariable{{name: "EXITS", value: []interface{}{"n"}}},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, decodeMSDP(test.Payload); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected %#v, but actually got %#v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnOnMSDPAndOnGMCP(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	type message struct {
+		name  string
+		value interface{}
+	}
+	received := make(chan message, 4)
+	conn.OnMSDP(func(name string, value interface{}) {
+		received <- message{name: name, value: value}
+	})
+	conn.OnGMCP(func(name string, data []byte) {
+		received <- message{name: name, value: string(data)}
+	})
+
+	sent := testReadLater(remote, 6)
+	if err := conn.RegisterOption(OptMSDP, MSDPOption()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := conn.RegisterOption(OptGMCP, GMCPOption(nil)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	<-sent
+
+	remote.Write([]byte{IAC, SB, OptMSDP})
+	remote.Write([]byte("\x01REPORT\x02HEALTH\x02MANA"))
+	remote.Write([]byte{IAC, SE, IAC, SB, OptGMCP})
+	remote.Write([]byte(`Core.Hello {"client":"Mudlet"}`))
+	remote.Write([]byte{IAC, SE})
+
+	for testNumber, expected := range []message{
+		{name: "REPORT", value: []interface{}{"HEALTH", "MANA"}},
+		{name: "Core.Hello", value: `{"client":"Mudlet"}`},
+	} {
+		select {
+		case actual := <-received:
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("For test #%d, expected %#v, but actually got %#v.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("For test #%d, expected it to be received, but actually it was not.", testNumber)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package telnet
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The bytes that (the subnegotiations of) MSSP are made out of.
+const (
+	msspVAR = 1
+	msspVAL = 2
+)
+
+// MSSP is the status that a MUD server announces to MUD crawlers (i.e., the MUD listing sites),
+// with the MSSP option (the Mud Server Status Protocol); as variables (such as "NAME", "PLAYERS",
+// and "UPTIME"), each with one (or more) values. For example:
+//
+//	var status telnet.MSSP
+//	status.Set("NAME", "My MUD")
+//	status.Set("CODEBASE", "mymud 1.0")
+//	status.SetUptime(time.Now())
+//	status.SetFunc("PLAYERS", func() []string {
+//		return []string{strconv.Itoa(world.Players())}
+//	})
+//
+//	server := telnet.NewServer(":4000",
+//		telnet.WithHandler(handler),
+//		telnet.WithOptionHandler(telnet.OptMSSP, func() telnet.OptionHandler {
+//			return telnet.MSSPOption(&status)
+//		}),
+//	)
+//
+// The same MSSP is (usually) shared by all of a Server's connections; and can be changed at any
+// time (such as when the number of players changes). What is sent to a crawler is what it is then.
+//
+// The zero MSSP is ready to use. (And an MSSP must not be copied once it has been used.)
+type MSSP struct {
+	mutex     sync.RWMutex
+	variables map[string]func() []string
+}
+
+// Set sets the variable 'name' (such as "NAME") to 'values'; replacing what it was. (A variable
+// can have more than one value; such as "PORT", for a MUD that listens on more than one port.)
+func (status *MSSP) Set(name string, values ...string) {
+	values = append([]string(nil), values...)
+	status.SetFunc(name, func() []string {
+		return values
+	})
+}
+
+// SetFunc has the variable 'name' be whatever 'fn' returns, each time the status is sent; such as
+// for "PLAYERS". ('fn' has to be safe to call concurrently; as it is called for each connection
+// the status is sent to.) It replaces what the variable was.
+func (status *MSSP) SetFunc(name string, fn func() []string) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	if nil == status.variables {
+		status.variables = map[string]func() []string{}
+	}
+	status.variables[name] = fn
+}
+
+// SetUptime sets the "UPTIME" variable to when the MUD was started; as MSSP has it (i.e., as a
+// Unix time).
+func (status *MSSP) SetUptime(started time.Time) {
+	status.Set("UPTIME", strconv.FormatInt(started.Unix(), 10))
+}
+
+// Delete removes the variable 'name'; so that it is no longer sent.
+func (status *MSSP) Delete(name string) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	delete(status.variables, name)
+}
+
+// Variables returns (a copy of) the variables; each with its values, as they are now.
+func (status *MSSP) Variables() map[string][]string {
+	status.mutex.RLock()
+	fns := make(map[string]func() []string, len(status.variables))
+	for name, fn := range status.variables {
+		fns[name] = fn
+	}
+	status.mutex.RUnlock()
+
+	variables := make(map[string][]string, len(fns))
+	for name, fn := range fns {
+		variables[name] = append([]string(nil), fn()...)
+	}
+	return variables
+}
+
+// payload returns the (MSSP) subnegotiation payload for the variables, as they are now:
+//
+//	MSSP_VAR "NAME" MSSP_VAL "My MUD" MSSP_VAR "PORT" MSSP_VAL "4000" MSSP_VAL "4001" ...
+//
+// ... with the variables in order (of name). A variable with no values is sent with an empty one.
+func (status *MSSP) payload() []byte {
+	variables := status.Variables()
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var payload []byte
+	for _, name := range names {
+		payload = append(append(payload, msspVAR), name...)
+
+		values := variables[name]
+		if 0 == len(values) {
+			values = []string{""}
+		}
+		for _, value := range values {
+			payload = append(append(payload, msspVAL), value...)
+		}
+	}
+	return payload
+}
+
+// MSSPOption returns an OptionHandler for the MSSP option (the Mud Server Status Protocol); which
+// sends 'status' to a MUD crawler, as a subnegotiation, once it agrees to MSSP. (See MSSP.)
+//
+// It offers (i.e., sends a WILL for) MSSP right away; as a MUD server does. (A crawler that asks
+// for it first, with a DO, is agreed to; and is sent the status just the same.)
+func MSSPOption(status *MSSP) OptionHandler {
+	return &internalMSSP{status: status}
+}
+
+type internalMSSP struct {
+	status *MSSP
+
+	mutex  sync.Mutex
+	sender OptionSender
+}
+
+func (mssp *internalMSSP) Register(sender OptionSender) OptionSupport {
+	mssp.mutex.Lock()
+	mssp.sender = sender
+	mssp.mutex.Unlock()
+
+	return OptionSupport{Local: true, RequestLocal: true}
+}
+
+// LocalChanged sends the status; once MSSP has been enabled.
+func (mssp *internalMSSP) LocalChanged(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	mssp.mutex.Lock()
+	sender := mssp.sender
+	mssp.mutex.Unlock()
+
+	if nil == sender || nil == mssp.status {
+		return
+	}
+	if err := sender.SendSubnegotiation(mssp.status.payload()); nil != err {
+		sender.Conn().logger.Debugf("Problem sending the MSSP status: %v", err)
+	}
+}
+
+func (*internalMSSP) RemoteChanged(enabled bool)    {}
+func (*internalMSSP) Subnegotiation(payload []byte) {}
@@ -0,0 +1,87 @@
+package telnet
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	"testing"
+)
+
+func TestMSSPPayload(t *testing.T) {
+
+	var status MSSP
+	status.Set("NAME", "My MUD")
+	status.Set("PORT", "4000", "4001")
+	status.Set("CODEBASE", "mymud 1.0")
+	status.SetUptime(time.Unix(1700000000, 0))
+	status.Delete("CODEBASE")
+
+	players := 3
+	status.SetFunc("PLAYERS", func() []string {
+		return []string{strconv.Itoa(players)}
+	})
+
+	expected := "\x01NAME\x02My MUD" +
+		"\x01PLAYERS\x023" +
+		"\x01PORT\x024000\x024001" +
+		"\x01UPTIME\x021700000000"
+	if actual := string(status.payload()); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (What SetFunc set is called each time.)
+	players = 5
+	if expected, actual := map[string][]string{
+		"NAME":    {"My MUD"},
+		"PLAYERS": {"5"},
+		"PORT":    {"4000", "4001"},
+		"UPTIME":  {"1700000000"},
+	}, status.Variables(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestMSSPOption(t *testing.T) {
+
+	tests := []struct {
+		Variables map[string]string
+		Expected  string
+	}{
+		{
+			Variables: map[string]string{"NAME": "My MUD", "PLAYERS": "12"},
+			Expected:  "\x01NAME\x02My MUD\x01PLAYERS\x0212",
+		},
+		{
+			// (An IAC in a value is escaped.)
+			Variables: map[string]string{"NAME": "\xffMUD"},
+			Expected:  "\x01NAME\x02\xff\xffMUD",
+		},
+	}
+
+	for testNumber, test := range tests {
+		var status MSSP
+		for name, value := range test.Variables {
+			status.Set(name, value)
+		}
+
+		conn, remote := testPipe(t)
+
+		offered := testReadLater(remote, 3)
+		if err := conn.RegisterOption(OptMSSP, MSSPOption(&status)); nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := string([]byte{IAC, WILL, OptMSSP}), string(<-offered); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		// (Once the crawler agrees, it is sent the status.)
+		remote.Write([]byte{IAC, DO, OptMSSP})
+
+		expected := string([]byte{IAC, SB, OptMSSP}) + test.Expected + string([]byte{IAC, SE})
+		if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package telnet
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MUDProtocol is a protocol that a MUD server sends (out-of-band) data to a client with; such as
+// the character's vitals, or the room it is in. (See MUDBridge.)
+type MUDProtocol int
+
+const (
+	// MUDProtocolNone is for a client that has agreed to neither GMCP, nor MSDP.
+	MUDProtocolNone MUDProtocol = iota
+
+	// MUDProtocolGMCP is GMCP. (See GMCPOption.)
+	MUDProtocolGMCP
+
+	// MUDProtocolMSDP is MSDP. (See MSDPOption.)
+	MUDProtocolMSDP
+)
+
+// String returns the name of the protocol.
+func (protocol MUDProtocol) String() string {
+	switch protocol {
+	case MUDProtocolNone:
+		return "none"
+	case MUDProtocolGMCP:
+		return "GMCP"
+	case MUDProtocolMSDP:
+		return "MSDP"
+	default:
+		return "MUDProtocol(" + strconv.Itoa(int(protocol)) + ")"
+	}
+}
+
+// MUDBridge sends (out-of-band) data to each client with whichever of GMCP, and MSDP, it agreed
+// to; so that a MUD server only has to have the one code path for it. The data is given (once)
+// as Go values (such as maps, and structs), with a GMCP package name (such as "Char.Vitals"); and
+// is sent as a GMCP message, or as a MSDP variable (with the name it maps to). For example:
+//
+//	bridge := &telnet.MUDBridge{
+//		MSDPNames: map[string]string{
+//			"Char.Vitals": "VITALS",
+//		},
+//	}
+//
+//	bridge.Register(conn)
+//
+//	//@TODO: Once the client has had a chance to agree to GMCP, or MSDP.
+//
+//	err := bridge.Send(conn, "Char.Vitals", map[string]int{"hp": 95, "maxhp": 120})
+//
+// ... sends a GMCP client:
+//
+//	IAC SB GMCP 'Char.Vitals {"hp":95,"maxhp":120}' IAC SE
+//
+// ... and a MSDP client:
+//
+//	IAC SB MSDP VAR 'VITALS' VAL TABLE_OPEN VAR 'hp' VAL '95' VAR 'maxhp' VAL '120' TABLE_CLOSE IAC SE
+//
+// A client that agreed to neither is not sent anything. And a client that agreed to both is only
+// sent it with the one preferred (see Prefer).
+//
+// (A MUDBridge can be used for many Conns, by many goroutines, at once; as long as it is not
+// changed.)
+type MUDBridge struct {
+	// MSDPNames maps GMCP package names (such as "Char.Vitals") to the MSDP variable names that
+	// they are sent as (such as "VITALS"). A name that is not in it is sent as itself; but in
+	// upper case, with each "." turned into "_" (so "Char.Vitals" is sent as "CHAR_VITALS").
+	MSDPNames map[string]string
+
+	// Prefer is the protocol used for a client that agreed to both. The default is GMCP.
+	Prefer MUDProtocol
+}
+
+// Register registers GMCPOption, and MSDPOption, with 'conn'; which offers the client both. (What
+// the client sends with them is passed on to what OnGMCP, and OnMSDP, registered.)
+func (bridge *MUDBridge) Register(conn *Conn) error {
+	if err := conn.RegisterOption(OptGMCP, GMCPOption(nil)); nil != err {
+		return err
+	}
+	return conn.RegisterOption(OptMSDP, MSDPOption())
+}
+
+// Protocol returns the protocol that 'conn' is sent data with; i.e., whichever of GMCP, and MSDP,
+// is enabled (or the preferred one, if both are); or MUDProtocolNone, if neither is.
+func (bridge *MUDBridge) Protocol(conn *Conn) MUDProtocol {
+	gmcpLocal, gmcpRemote := conn.OptionEnabled(OptGMCP)
+	msdpLocal, msdpRemote := conn.OptionEnabled(OptMSDP)
+	gmcp, msdp := gmcpLocal || gmcpRemote, msdpLocal || msdpRemote
+
+	switch {
+	case gmcp && msdp:
+		if MUDProtocolMSDP == bridge.Prefer {
+			return MUDProtocolMSDP
+		}
+		return MUDProtocolGMCP
+	case gmcp:
+		return MUDProtocolGMCP
+	case msdp:
+		return MUDProtocolMSDP
+	default:
+		return MUDProtocolNone
+	}
+}
+
+// MSDPName returns the MSDP variable name that the GMCP package name 'name' is sent as. (See
+// MSDPNames.)
+func (bridge *MUDBridge) MSDPName(name string) string {
+	if msdpName, ok := bridge.MSDPNames[name]; ok {
+		return msdpName
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// Send sends 'data' (as 'name') to 'conn'; with whichever protocol it agreed to. (See Protocol.)
+// If it agreed to neither, then nothing is sent (and nil is returned).
+func (bridge *MUDBridge) Send(conn *Conn, name string, data interface{}) error {
+	switch bridge.Protocol(conn) {
+	case MUDProtocolGMCP:
+		return conn.SendGMCP(name, data)
+	case MUDProtocolMSDP:
+		return conn.SendMSDP(bridge.MSDPName(name), data)
+	default:
+		return nil
+	}
+}
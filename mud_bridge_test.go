@@ -0,0 +1,160 @@
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testMUDConn returns a Conn that the bridge has registered GMCP, and MSDP, with; once the peer
+// has answered (the WILLs for) them with 'answer'.
+func testMUDConn(t *testing.T, bridge *MUDBridge, expected MUDProtocol, answer ...byte) (*Conn, net.Conn) {
+	t.Helper()
+
+	conn, remote := testPipe(t)
+
+	offered := testReadLater(remote, 6)
+	if err := bridge.Register(conn); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []byte{IAC, WILL, OptGMCP, IAC, WILL, OptMSDP}, <-offered; !bytes.Equal(expected, actual) {
+		t.Fatalf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	if _, err := remote.Write(answer); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	for i := 0; i < 100 && expected != bridge.Protocol(conn); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if actual := bridge.Protocol(conn); expected != actual {
+		t.Fatalf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	return conn, remote
+}
+
+func TestMUDBridgeSend(t *testing.T) {
+
+	type vitals struct {
+		HP    int `json:"hp"`
+		MaxHP int `json:"maxhp"`
+	}
+
+	gmcpFrame := append(append([]byte{IAC, SB, OptGMCP}, `Char.Vitals {"hp":95,"maxhp":120}`...), IAC, SE)
+
+	msdpFrame := func(name string) []byte {
+		frame := append([]byte{IAC, SB, OptMSDP, msdpVAR}, name...)
+		frame = append(frame, msdpVAL, msdpTableOpen)
+		frame = append(append(frame, msdpVAR), "hp"...)
+		frame = append(append(frame, msdpVAL), "95"...)
+		frame = append(append(frame, msdpVAR), "maxhp"...)
+		frame = append(append(frame, msdpVAL), "120"...)
+		return append(frame, msdpTableClose, IAC, SE)
+	}
+
+	tests := []struct {
+		Bridge   MUDBridge
+		Answer   []byte
+		Protocol MUDProtocol
+		Expected []byte
+	}{
+		{
+			Answer:   []byte{IAC, DO, OptGMCP, IAC, DONT, OptMSDP},
+			Protocol: MUDProtocolGMCP,
+			Expected: gmcpFrame,
+		},
+		{
+			Answer:   []byte{IAC, DONT, OptGMCP, IAC, DO, OptMSDP},
+			Protocol: MUDProtocolMSDP,
+			Expected: msdpFrame("CHAR_VITALS"),
+		},
+		{
+			Bridge:   MUDBridge{MSDPNames: map[string]string{"Char.Vitals": "VITALS"}},
+			Answer:   []byte{IAC, DONT, OptGMCP, IAC, DO, OptMSDP},
+			Protocol: MUDProtocolMSDP,
+			Expected: msdpFrame("VITALS"),
+		},
+		// Both; GMCP is preferred (by default).
+		{
+			Answer:   []byte{IAC, DO, OptGMCP, IAC, DO, OptMSDP},
+			Protocol: MUDProtocolGMCP,
+			Expected: gmcpFrame,
+		},
+		{
+			Bridge:   MUDBridge{Prefer: MUDProtocolMSDP},
+			Answer:   []byte{IAC, DO, OptGMCP, IAC, DO, OptMSDP},
+			Protocol: MUDProtocolMSDP,
+			Expected: msdpFrame("CHAR_VITALS"),
+		},
+		// Neither; nothing is sent.
+		{
+			Answer:   []byte{IAC, DONT, OptGMCP, IAC, DONT, OptMSDP},
+			Protocol: MUDProtocolNone,
+			Expected: []byte("x"),
+		},
+	}
+
+	for testNumber, test := range tests {
+		bridge := test.Bridge
+
+		conn, remote := testMUDConn(t, &bridge, test.Protocol, test.Answer...)
+
+		errs := make(chan error, 1)
+		go func() {
+			err := bridge.Send(conn, "Char.Vitals", vitals{HP: 95, MaxHP: 120})
+			if nil == err {
+				// (So that what is read next is what was sent after it; if anything.)
+				_, err = conn.Write([]byte("x"))
+			}
+			errs <- err
+		}()
+
+		if MUDProtocolNone != test.Protocol {
+			test.Expected = append(test.Expected, 'x')
+		}
+
+		if expected, actual := test.Expected, testReadExactly(t, remote, len(test.Expected)); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if err := <-errs; nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+	}
+}
+
+func TestMUDBridgeMSDPName(t *testing.T) {
+
+	bridge := MUDBridge{
+		MSDPNames: map[string]string{
+			"Room.Info": "ROOM",
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		Expected string
+	}{
+		{
+			Name:     "Char.Vitals",
+			Expected: "CHAR_VITALS",
+		},
+		{
+			Name:     "Char.Status.Level",
+			Expected: "CHAR_STATUS_LEVEL",
+		},
+		{
+			Name:     "Room.Info",
+			Expected: "ROOM",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, bridge.MSDPName(test.Name); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package telnet
+
+import (
+	"time"
+)
+
+// A NegotiationEvent reports a single TELNET option negotiation command (i.e., WILL, WONT,
+// DO, or DONT) that was received from, or sent to, the peer.
+//
+// See Conn.OnNegotiationEvent.
+type NegotiationEvent struct {
+	Time time.Time
+
+	// Direction is Inbound if the peer sent this, and Outbound if we sent this.
+	Direction Direction
+
+	Verb   byte // WILL, WONT, DO, or DONT.
+	Option byte
+
+	// State is the state of the option, after this was sent (or received) and the
+	// option negotiation state machine was updated.
+	State OptionState
+}
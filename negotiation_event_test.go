@@ -0,0 +1,95 @@
+package telnet
+
+import (
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestConnOnNegotiationEvent(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOptionPolicy(77, OptionPolicyAccept)
+
+	var mutex sync.Mutex
+	var events []NegotiationEvent
+	conn.OnNegotiationEvent(func(event NegotiationEvent) {
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	})
+
+	if _, err := remote.Write([]byte{IAC, DO, 77, IAC, WILL, 78}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	testReadExactly(t, remote, 6)
+
+	expected := []NegotiationEvent{
+		{Direction: Inbound, Verb: DO, Option: 77, State: OptionState{Local: true}},
+		{Direction: Outbound, Verb: WILL, Option: 77, State: OptionState{Local: true}},
+		{Direction: Inbound, Verb: WILL, Option: 78, State: OptionState{}},
+		{Direction: Outbound, Verb: DONT, Option: 78, State: OptionState{}},
+	}
+
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		n := len(events)
+		mutex.Unlock()
+		if len(expected) <= n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if expected, actual := len(expected), len(events); expected != actual {
+		t.Fatalf("Expected %d events, but actually got %d: %+v", expected, actual, events)
+	}
+
+	for i, event := range events {
+		if event.Time.IsZero() {
+			t.Errorf("For event #%d, expected a time, but did not get one.", i)
+		}
+		event.Time = time.Time{}
+
+		if expected, actual := expected[i], event; expected != actual {
+			t.Errorf("For event #%d, expected %+v, but actually got %+v.", i, expected, actual)
+		}
+	}
+}
+
+func TestConnOnCommand(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	commands := make(chan byte, 10)
+	conn.OnCommand(func(cmd byte) {
+		commands <- cmd
+	})
+
+	if _, err := remote.Write([]byte{'a', IAC, IP, 'b', IAC, WONT, 77, IAC, AYT, IAC, IAC}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	expected := []byte{IP, AYT}
+
+	for i, expected := range expected {
+		select {
+		case actual := <-commands:
+			if expected != actual {
+				t.Errorf("For command #%d, expected %s, but actually got %s.", i, CommandName(expected), CommandName(actual))
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("For command #%d, expected %s, but did not get any.", i, CommandName(expected))
+		}
+	}
+
+	select {
+	case actual := <-commands:
+		t.Errorf("Did not expect any more commands, but actually got %s.", CommandName(actual))
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package telnet
+
+import (
+	"context"
+	"time"
+)
+
+// SetNegotiationTimeout sets how long the Conn waits for the peer to answer an option negotiation
+// that it started (i.e., a WILL, WONT, DO, or DONT that it sent; such as for RegisterOption, or
+// for an OptionSender's EnableRemote); after which it gives up waiting, and takes the option to
+// be disabled (on that side). This is for peers (such as some old terminal servers) that never
+// answer some negotiations; so that the option is not left pending forever. For example:
+//
+//	conn.SetNegotiationTimeout(5*time.Second)
+//
+// (If the peer does answer, after that, then the answer is taken as a new negotiation; per RFC 1143.)
+//
+// A 'timeout' of zero (the default) means to wait for as long as it takes. (See also
+// Server.NegotiationTimeout, and Dialer.NegotiationTimeout.)
+func (clientConn *Conn) SetNegotiationTimeout(timeout time.Duration) {
+	clientConn.negotiator.setTimeout(timeout)
+}
+
+func (negotiator *internalNegotiator) setTimeout(timeout time.Duration) {
+	negotiator.mutex.Lock()
+	defer negotiator.mutex.Unlock()
+
+	negotiator.timeout = timeout
+}
+
+// expireLater has the negotiation (that was just started) of 'option', on our side (if 'local' is
+// true) or the peer's side, given up on once the timeout has passed; if it has not been answered
+// by then. (The mutex must be held.)
+func (negotiator *internalNegotiator) expireLater(option byte, local bool) {
+	if negotiator.timeout <= 0 {
+		return
+	}
+
+	side := 0
+	if local {
+		side = 1
+	}
+	negotiator.requests[option][side]++
+	request := negotiator.requests[option][side]
+
+	time.AfterFunc(negotiator.timeout, func() {
+		negotiator.expire(option, local, request)
+	})
+}
+
+// expire gives up on the 'request'th negotiation of 'option'; unless it has been answered (or
+// another one has been started since).
+func (negotiator *internalNegotiator) expire(option byte, local bool, request uint32) {
+	negotiator.mutex.Lock()
+	failed := negotiator.expireLocked(option, local, request)
+	negotiator.mutex.Unlock()
+
+	if nil != failed {
+		failed(option)
+	}
+}
+
+// expireLocked is expire; and returns what is to be told that the negotiation failed (if it was
+// given up on, and there is anything to tell). (The mutex must be held.)
+func (negotiator *internalNegotiator) expireLocked(option byte, local bool, request uint32) func(option byte) {
+
+	side := 0
+	state, opposite := &negotiator.options[option].him, &negotiator.options[option].himOpposite
+	if local {
+		side = 1
+		state, opposite = &negotiator.options[option].us, &negotiator.options[option].usOpposite
+	}
+
+	if request != negotiator.requests[option][side] {
+		return nil
+	}
+	if qWantYes != *state && qWantNo != *state {
+		return nil
+	}
+
+	negotiator.logger.Debugf("Gave up waiting for the peer to answer the negotiation of %s (after %v).", OptionName(option), negotiator.timeout)
+
+	// (Neither WANTYES nor WANTNO is enabled; so, going to NO, nothing is told of the change.)
+	*state = qNo
+	*opposite = false
+
+	negotiator.resolveLocked(option, local, context.DeadlineExceeded)
+
+	return negotiator.failed
+}
@@ -0,0 +1,92 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testReadLater reads 'n' bytes from 'conn' (in another goroutine); as writing to a net.Pipe waits for
+// them to be read. (What is sent is what was read; or nil, if reading failed.)
+func testReadLater(conn net.Conn, n int) <-chan []byte {
+	sent := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, n)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(conn, p); nil != err {
+			p = nil
+		}
+		sent <- p
+	}()
+
+	return sent
+}
+
+func TestConnNegotiationTimeout(t *testing.T) {
+
+	tests := []struct {
+		Timeout  time.Duration
+		Expected OptionState
+	}{
+		{
+			// (With no timeout, the DO is waited on forever.)
+			Expected: OptionState{RemotePending: true},
+		},
+		{
+			Timeout:  20 * time.Millisecond,
+			Expected: OptionState{},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+		conn.SetNegotiationTimeout(test.Timeout)
+
+		// (The DO is read, but never answered.)
+		sent := testReadLater(remote, 3)
+
+		if err := conn.RegisterOption(0xAA, SimpleOption(OptionSupport{Remote: true, RequestRemote: true})); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := []byte{IAC, DO, 0xAA}, <-sent; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if expected, actual := test.Expected, conn.OptionStates()[0xAA]; expected != actual {
+			t.Errorf("For test #%d, expected the option state to be %v, but actually got %v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnNegotiationTimeoutLateAnswer(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetNegotiationTimeout(20 * time.Millisecond)
+
+	sent := testReadLater(remote, 3)
+
+	if err := conn.RegisterOption(0xAA, SimpleOption(OptionSupport{Remote: true, RequestRemote: true})); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []byte{IAC, DO, 0xAA}, <-sent; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// (An answer, after giving up, is taken as a new negotiation; which is agreed to.)
+	remote.Write([]byte{IAC, WILL, 0xAA})
+	if expected, actual := []byte{IAC, DO, 0xAA}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	if expected, actual := (OptionState{Remote: true}), conn.OptionStates()[0xAA]; expected != actual {
+		t.Errorf("Expected the option state to be %v, but actually got %v.", expected, actual)
+	}
+}
@@ -0,0 +1,562 @@
+package telnet
+
+import (
+	"sync"
+	"time"
+)
+
+// internalQState is the state of one side of one option, per the "Q Method" of
+// RFC 1143 ("The Q Method of Implementing TELNET Option Negotiation").
+type internalQState uint8
+
+const (
+	qNo internalQState = iota
+	qYes
+	qWantNo
+	qWantYes
+)
+
+func (state internalQState) String() string {
+	switch state {
+	case qNo:
+		return "NO"
+	case qYes:
+		return "YES"
+	case qWantNo:
+		return "WANTNO"
+	case qWantYes:
+		return "WANTYES"
+	default:
+		return "?"
+	}
+}
+
+// internalOptionQ is the negotiation state for a single option.
+//
+// 'us' is whether we are performing the option (i.e., what we have said WILL or WONT to).
+//
+// 'him' is whether the peer is performing the option (i.e., what we have said DO or DONT to).
+//
+// The 'opposite' fields are the RFC 1143 "queue" bits.
+type internalOptionQ struct {
+	us          internalQState
+	usOpposite  bool
+	him         internalQState
+	himOpposite bool
+}
+
+// state returns the (exported) OptionState for 'q'.
+func (q internalOptionQ) state() OptionState {
+	return OptionState{
+		Local:         qYes == q.us,
+		Remote:        qYes == q.him,
+		LocalPending:  qWantYes == q.us || qWantNo == q.us,
+		RemotePending: qWantYes == q.him || qWantNo == q.him,
+	}
+}
+
+// internalRegisteredOption is an OptionHandler, along with the OptionSupport it
+// returned when it was registered.
+type internalRegisteredOption struct {
+	handler OptionHandler
+	support OptionSupport
+}
+
+// internalOptionChange records whether negotiation changed whether an option is enabled.
+type internalOptionChange struct {
+	localChanged  bool
+	localEnabled  bool
+	remoteChanged bool
+	remoteEnabled bool
+}
+
+// internalNegotiator implements TELNET option negotiation, using the "Q Method" from RFC 1143.
+//
+// The Q Method guarantees that negotiation never loops. In particular, a refusal is
+// never answered with another refusal.
+//
+// When the peer asks for (or offers) an option that we are not already performing (or
+// allowing), whether we agree is determined by the option's registered OptionHandler, or
+// (if there isn't one) by the option's OptionPolicy.
+type internalNegotiator struct {
+	mutex sync.Mutex
+
+	options [256]internalOptionQ
+
+	defaultPolicy OptionPolicy
+	policies      map[byte]OptionPolicy
+
+	handlers map[byte]internalRegisteredOption
+
+	eventHandler func(NegotiationEvent)
+
+	// changes (if not nil) is sent an OptionChange for every change in whether an option is
+	// enabled; without blocking. (See Conn.NotifyOptionChanges.)
+	changes chan<- OptionChange
+
+	// trace (if not nil) is called with every NegotiationEvent; besides the event handler. (See
+	// Trace.Negotiation.)
+	trace func(NegotiationEvent)
+
+	// failed (if not nil) is called for every negotiation we started that the peer refused, or
+	// did not answer in time. (See TrafficMetrics.NegotiationFailed.)
+	failed func(option byte)
+
+	// timeout (if not zero) is how long to wait for the peer to answer a negotiation we started;
+	// and requests counts the negotiations started, for each option (by side), so that only the
+	// latest one is given up on. (See Conn.SetNegotiationTimeout.)
+	timeout  time.Duration
+	requests [256][2]uint32
+
+	// waiters are (for each option, by side) what is waiting for the negotiation of it to be
+	// settled; each of which is sent the outcome. (See Conn.RequestEnableRemote.)
+	waiters [256][2][]chan error
+
+	send   func([]byte) error
+	logger Logger
+}
+
+func newNegotiator(send func([]byte) error, logger Logger) *internalNegotiator {
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	negotiator := internalNegotiator{
+		send:   send,
+		logger: logger,
+	}
+
+	return &negotiator
+}
+
+func (negotiator *internalNegotiator) setDefaultPolicy(policy OptionPolicy) {
+	negotiator.mutex.Lock()
+	negotiator.defaultPolicy = policy
+	negotiator.mutex.Unlock()
+}
+
+func (negotiator *internalNegotiator) setPolicy(option byte, policy OptionPolicy) {
+	negotiator.mutex.Lock()
+	if nil == negotiator.policies {
+		negotiator.policies = map[byte]OptionPolicy{}
+	}
+	negotiator.policies[option] = policy
+	negotiator.mutex.Unlock()
+}
+
+// policy returns the OptionPolicy for 'option'.
+//
+// The caller must hold the mutex.
+func (negotiator *internalNegotiator) policy(option byte) OptionPolicy {
+	if policy, ok := negotiator.policies[option]; ok {
+		return policy
+	}
+
+	return negotiator.defaultPolicy
+}
+
+func (negotiator *internalNegotiator) setEventHandler(fn func(NegotiationEvent)) {
+	negotiator.mutex.Lock()
+	negotiator.eventHandler = fn
+	negotiator.mutex.Unlock()
+}
+
+func (negotiator *internalNegotiator) setChanges(changes chan<- OptionChange) {
+	negotiator.mutex.Lock()
+	negotiator.changes = changes
+	negotiator.mutex.Unlock()
+}
+
+// emitChange sends an OptionChange to the changes channel (if there is one); unless it is full.
+//
+// The caller must NOT hold the mutex.
+func (negotiator *internalNegotiator) emitChange(option byte, local bool, enabled bool, q internalOptionQ) {
+	negotiator.mutex.Lock()
+	changes := negotiator.changes
+	negotiator.mutex.Unlock()
+
+	if nil == changes {
+		return
+	}
+
+	select {
+	case changes <- OptionChange{Option: option, Local: local, Enabled: enabled, State: q.state()}:
+	default:
+		negotiator.logger.Debugf("Dropped the change to %s; the channel was full.", OptionName(option))
+	}
+}
+
+// emit calls the event handler (if there is one) with a NegotiationEvent for 'verb' 'option'
+// having been sent or received, which resulted in 'q'.
+//
+// The caller must NOT hold the mutex.
+func (negotiator *internalNegotiator) emit(direction Direction, verb byte, option byte, q internalOptionQ) {
+	negotiator.mutex.Lock()
+	fn, trace := negotiator.eventHandler, negotiator.trace
+	negotiator.mutex.Unlock()
+
+	if nil == fn && nil == trace {
+		return
+	}
+
+	event := NegotiationEvent{
+		Time:      time.Now(),
+		Direction: direction,
+		Verb:      verb,
+		Option:    option,
+		State:     q.state(),
+	}
+
+	if nil != trace {
+		trace(event)
+	}
+	if nil != fn {
+		fn(event)
+	}
+}
+
+// enabled returns whether 'option' is enabled on our side ('local') and on the peer's side ('remote').
+func (negotiator *internalNegotiator) enabled(option byte) (local bool, remote bool) {
+	negotiator.mutex.Lock()
+	q := negotiator.options[option]
+	negotiator.mutex.Unlock()
+
+	return qYes == q.us, qYes == q.him
+}
+
+// states returns a snapshot of the state of every option that is not (simply) disabled on both sides.
+func (negotiator *internalNegotiator) states() OptionStates {
+	states := OptionStates{}
+
+	negotiator.mutex.Lock()
+	defer negotiator.mutex.Unlock()
+
+	for option, q := range negotiator.options {
+		state := q.state()
+		if (OptionState{}) == state {
+			continue
+		}
+		states[byte(option)] = state
+	}
+
+	return states
+}
+
+// register registers 'handler' (which returned 'support') for 'option', replacing
+// any OptionHandler previously registered for it.
+func (negotiator *internalNegotiator) register(option byte, handler OptionHandler, support OptionSupport) {
+	negotiator.mutex.Lock()
+	if nil == negotiator.handlers {
+		negotiator.handlers = map[byte]internalRegisteredOption{}
+	}
+	negotiator.handlers[option] = internalRegisteredOption{handler: handler, support: support}
+	negotiator.mutex.Unlock()
+}
+
+// handler returns the OptionHandler registered for 'option', or nil if there isn't one.
+func (negotiator *internalNegotiator) handler(option byte) OptionHandler {
+	negotiator.mutex.Lock()
+	registered, ok := negotiator.handlers[option]
+	negotiator.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return registered.handler
+}
+
+// accepts returns whether we agree to the peer's request to enable 'option' on our side
+// (if 'local' is true), or the peer's offer to enable 'option' on its side (if 'local' is false).
+//
+// If 'silent' is true, then the peer is not to be answered at all.
+//
+// The caller must hold the mutex.
+func (negotiator *internalNegotiator) accepts(option byte, local bool) (accept bool, silent bool) {
+	if registered, ok := negotiator.handlers[option]; ok {
+		if local {
+			return registered.support.Local, false
+		}
+		return registered.support.Remote, false
+	}
+
+	switch negotiator.policy(option) {
+	case OptionPolicyAccept:
+		return true, false
+	case OptionPolicyIgnore:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// receive handles an IAC WILL, IAC WONT, IAC DO, or IAC DONT received from the peer,
+// and sends whatever answer (if any) is needed.
+func (negotiator *internalNegotiator) receive(verb byte, option byte) error {
+
+	negotiator.mutex.Lock()
+	before := negotiator.options[option]
+	answer := negotiator.receiveLocked(verb, option)
+	after := negotiator.options[option]
+	negotiator.settleLocked(option)
+	failed := negotiator.failed
+	negotiator.mutex.Unlock()
+
+	negotiator.emit(Inbound, verb, option, after)
+
+	// (A negotiation we started, to enable the option, that the peer refused.)
+	if nil != failed && ((qWantYes == before.us && qNo == after.us) || (qWantYes == before.him && qNo == after.him)) {
+		failed(option)
+	}
+
+	var err error
+	if 0 == answer {
+		negotiator.logger.Tracef("Received %s %s; not answering; %s is %v.", CommandName(verb), OptionName(option), OptionName(option), after.state())
+	} else {
+		negotiator.logger.Tracef("Received %s %s; answering %s %s; %s is %v.", CommandName(verb), OptionName(option), CommandName(answer), OptionName(option), OptionName(option), after.state())
+		err = negotiator.send([]byte{IAC, answer, option})
+		negotiator.emit(Outbound, answer, option, after)
+	}
+
+	negotiator.notify(option, before, after)
+
+	return err
+}
+
+// request starts enabling (if 'enable' is true) or disabling (if 'enable' is false) 'option',
+// on our side (if 'local' is true) or on the peer's side (if 'local' is false), per RFC 1143.
+//
+// Nothing is sent if the option is already in (or on its way to) the requested state.
+func (negotiator *internalNegotiator) request(option byte, local bool, enable bool) error {
+	return negotiator.requestWaiting(option, local, enable, nil)
+}
+
+// requestWaiting is like request; but (if 'result' is not nil) also has the outcome of the
+// negotiation sent to 'result', once it is settled. (See awaitLocked.)
+func (negotiator *internalNegotiator) requestWaiting(option byte, local bool, enable bool, result chan error) error {
+
+	negotiator.mutex.Lock()
+	before := negotiator.options[option]
+	verb := negotiator.requestLocked(option, local, enable)
+	after := negotiator.options[option]
+	if 0 != verb {
+		negotiator.expireLater(option, local)
+	}
+	if nil != result {
+		negotiator.awaitLocked(option, local, result)
+	}
+	negotiator.mutex.Unlock()
+
+	var err error
+	if 0 != verb {
+		negotiator.logger.Tracef("Requesting %s %s; %s is %v.", CommandName(verb), OptionName(option), OptionName(option), after.state())
+		err = negotiator.send([]byte{IAC, verb, option})
+		negotiator.emit(Outbound, verb, option, after)
+	}
+	if nil != err {
+		// (The peer never got asked; so it is never going to answer.)
+		negotiator.mutex.Lock()
+		negotiator.resolveLocked(option, local, err)
+		negotiator.mutex.Unlock()
+	}
+
+	negotiator.notify(option, before, after)
+
+	return err
+}
+
+// requestLocked updates the state for 'option', per RFC 1143, and returns the verb
+// to send, or zero if nothing is to be sent.
+//
+// The caller must hold the mutex.
+func (negotiator *internalNegotiator) requestLocked(option byte, local bool, enable bool) byte {
+
+	q := &negotiator.options[option]
+
+	state, opposite := &q.him, &q.himOpposite
+	enableVerb, disableVerb := DO, DONT
+	if local {
+		state, opposite = &q.us, &q.usOpposite
+		enableVerb, disableVerb = WILL, WONT
+	}
+
+	switch *state {
+	case qNo:
+		if enable {
+			*state = qWantYes
+			return enableVerb
+		}
+	case qYes:
+		if !enable {
+			*state = qWantNo
+			return disableVerb
+		}
+	case qWantNo:
+		// Whatever we are asking for now is the opposite of what we are waiting on
+		// if we want it enabled; so either queue that up, or cancel what was queued.
+		*opposite = enable
+	case qWantYes:
+		*opposite = !enable
+	}
+
+	return 0
+}
+
+// notify tells the OptionHandler registered for 'option' (if there is one), and the changes
+// channel (if there is one), about any change in whether the option is enabled, between 'before'
+// and 'after'.
+func (negotiator *internalNegotiator) notify(option byte, before internalOptionQ, after internalOptionQ) {
+
+	change := internalOptionChange{
+		localChanged:  (qYes == before.us) != (qYes == after.us),
+		localEnabled:  qYes == after.us,
+		remoteChanged: (qYes == before.him) != (qYes == after.him),
+		remoteEnabled: qYes == after.him,
+	}
+
+	if !change.localChanged && !change.remoteChanged {
+		return
+	}
+
+	if change.localChanged {
+		negotiator.emitChange(option, true, change.localEnabled, after)
+	}
+	if change.remoteChanged {
+		negotiator.emitChange(option, false, change.remoteEnabled, after)
+	}
+
+	handler := negotiator.handler(option)
+	if nil == handler {
+		return
+	}
+
+	if change.localChanged {
+		handler.LocalChanged(change.localEnabled)
+	}
+	if change.remoteChanged {
+		handler.RemoteChanged(change.remoteEnabled)
+	}
+}
+
+// receiveLocked updates the state for 'option', per RFC 1143, and returns the verb
+// to answer with, or zero if no answer is to be sent.
+//
+// The caller must hold the mutex.
+func (negotiator *internalNegotiator) receiveLocked(verb byte, option byte) byte {
+
+	q := &negotiator.options[option]
+
+	switch verb {
+	case WILL:
+		switch q.him {
+		case qNo:
+			accept, silent := negotiator.accepts(option, false)
+			switch {
+			case accept:
+				q.him = qYes
+				return DO
+			case silent:
+				return 0
+			default:
+				return DONT
+			}
+		case qYes:
+			return 0
+		case qWantNo:
+			// Either the peer is answering our DONT with a WILL (which is an error),
+			// or we had queued up a request to re-enable the option.
+			if q.himOpposite {
+				q.him = qYes
+				q.himOpposite = false
+				return 0
+			}
+			q.him = qNo
+			return 0
+		case qWantYes:
+			if q.himOpposite {
+				q.him = qWantNo
+				q.himOpposite = false
+				return DONT
+			}
+			q.him = qYes
+			return 0
+		}
+
+	case WONT:
+		switch q.him {
+		case qNo:
+			return 0
+		case qYes:
+			q.him = qNo
+			return DONT
+		case qWantNo:
+			if q.himOpposite {
+				q.him = qWantYes
+				q.himOpposite = false
+				return DO
+			}
+			q.him = qNo
+			return 0
+		case qWantYes:
+			q.him = qNo
+			q.himOpposite = false
+			return 0
+		}
+
+	case DO:
+		switch q.us {
+		case qNo:
+			accept, silent := negotiator.accepts(option, true)
+			switch {
+			case accept:
+				q.us = qYes
+				return WILL
+			case silent:
+				return 0
+			default:
+				return WONT
+			}
+		case qYes:
+			return 0
+		case qWantNo:
+			if q.usOpposite {
+				q.us = qYes
+				q.usOpposite = false
+				return 0
+			}
+			q.us = qNo
+			return 0
+		case qWantYes:
+			if q.usOpposite {
+				q.us = qWantNo
+				q.usOpposite = false
+				return WONT
+			}
+			q.us = qYes
+			return 0
+		}
+
+	case DONT:
+		switch q.us {
+		case qNo:
+			return 0
+		case qYes:
+			q.us = qNo
+			return WONT
+		case qWantNo:
+			if q.usOpposite {
+				q.us = qWantYes
+				q.usOpposite = false
+				return WILL
+			}
+			q.us = qNo
+			return 0
+		case qWantYes:
+			q.us = qNo
+			q.usOpposite = false
+			return 0
+		}
+	}
+
+	return 0
+}
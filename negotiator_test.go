@@ -0,0 +1,164 @@
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testServe starts a Server, serving 'handler' on a (random) loopback port, and returns
+// a client connection (speaking raw TCP) to it.
+func testServe(t *testing.T, server *Server) net.Conn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// testReadFor reads everything 'conn' receives, until nothing has been received for 'quiet'.
+func testReadFor(conn net.Conn, quiet time.Duration) []byte {
+	var buffer bytes.Buffer
+
+	p := make([]byte, 4096)
+	for {
+		conn.SetReadDeadline(time.Now().Add(quiet))
+		n, err := conn.Read(p)
+		buffer.Write(p[:n])
+		if nil != err {
+			return buffer.Bytes()
+		}
+	}
+}
+
+func TestServerRefusesEveryUnknownOption(t *testing.T) {
+
+	client := testServe(t, &Server{Handler: EchoHandler})
+
+	var request bytes.Buffer
+	var expected bytes.Buffer
+	for option := 0; option <= 255; option++ {
+		request.Write([]byte{IAC, DO, byte(option)})
+		expected.Write([]byte{IAC, WONT, byte(option)})
+
+		request.Write([]byte{IAC, WILL, byte(option)})
+		expected.Write([]byte{IAC, DONT, byte(option)})
+	}
+
+	if _, err := client.Write(request.Bytes()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	actual := testReadFor(client, 250*time.Millisecond)
+
+	if !bytes.Equal(expected.Bytes(), actual) {
+		t.Errorf("Expected %d bytes (one refusal per request), but actually got %d bytes: %v", expected.Len(), len(actual), actual)
+	}
+}
+
+func TestServerDoesNotRefuseARefusal(t *testing.T) {
+
+	client := testServe(t, &Server{Handler: EchoHandler})
+
+	var request bytes.Buffer
+	for option := 0; option <= 255; option++ {
+		request.Write([]byte{IAC, DONT, byte(option)})
+		request.Write([]byte{IAC, WONT, byte(option)})
+	}
+	request.WriteString("apple")
+
+	if _, err := client.Write(request.Bytes()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "apple", string(testReadFor(client, 250*time.Millisecond)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServerOptionPolicy(t *testing.T) {
+
+	tests := []struct {
+		OptionPolicy   OptionPolicy
+		OptionPolicies map[byte]OptionPolicy
+		Bytes          []byte
+		Expected       []byte
+	}{
+		{
+			OptionPolicy: OptionPolicyAccept,
+			Bytes:        []byte{IAC, DO, 77, IAC, WILL, 77},
+			Expected:     []byte{IAC, WILL, 77, IAC, DO, 77},
+		},
+		{
+			// Already enabled, so there is nothing to answer the second time.
+			OptionPolicy: OptionPolicyAccept,
+			Bytes:        []byte{IAC, DO, 77, IAC, DO, 77},
+			Expected:     []byte{IAC, WILL, 77},
+		},
+		{
+			OptionPolicy: OptionPolicyAccept,
+			Bytes:        []byte{IAC, DO, 77, IAC, DONT, 77},
+			Expected:     []byte{IAC, WILL, 77, IAC, WONT, 77},
+		},
+		{
+			OptionPolicy: OptionPolicyIgnore,
+			Bytes:        []byte{IAC, DO, 77, IAC, WILL, 77},
+			Expected:     []byte{},
+		},
+		{
+			OptionPolicy:   OptionPolicyIgnore,
+			OptionPolicies: map[byte]OptionPolicy{77: OptionPolicyAccept, 78: OptionPolicyRefuse},
+			Bytes:          []byte{IAC, DO, 76, IAC, DO, 77, IAC, DO, 78},
+			Expected:       []byte{IAC, WILL, 77, IAC, WONT, 78},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		client := testServe(t, &Server{Handler: EchoHandler, OptionPolicy: test.OptionPolicy, OptionPolicies: test.OptionPolicies})
+
+		if _, err := client.Write(test.Bytes); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, testReadFor(client, 100*time.Millisecond); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestNegotiatorQMethod(t *testing.T) {
+
+	var sent bytes.Buffer
+	negotiator := newNegotiator(func(p []byte) error {
+		sent.Write(p)
+		return nil
+	}, nil)
+
+	// Every request is answered with a refusal, but a refusal is never answered.
+	for _, verb := range []byte{WILL, WILL, WONT, WONT, DO, DO, DONT, DONT} {
+		if err := negotiator.receive(verb, 77); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+
+	if expected, actual := []byte{IAC, DONT, 77, IAC, DONT, 77, IAC, WONT, 77, IAC, WONT, 77}, sent.Bytes(); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
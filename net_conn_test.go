@@ -0,0 +1,115 @@
+package telnet
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+// testNetConn returns a Conn, as a net.Conn; and the other end of it.
+func testNetConn(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	var conn net.Conn = newConn(local, nil)
+	return conn, remote
+}
+
+func TestConnNetConnScanner(t *testing.T) {
+
+	conn, remote := testNetConn(t)
+
+	go func() {
+		remote.Write([]byte("one\r\ntw\xff\xffo\xff\xf1\r\nthr\xff\xfb\x01ee\r\n"))
+		remote.Close()
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := []string{"one", "tw\xffo", "three"}, lines; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnNetConnCopy(t *testing.T) {
+
+	conn, remote := testNetConn(t)
+
+	// What is copied to it is escaped.
+	sent := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, 4)
+		io.ReadFull(remote, p)
+		sent <- p
+	}()
+	if _, err := io.Copy(conn, strings.NewReader("a\xffb")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "a\xff\xffb", string(<-sent); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// And what is copied from it is unescaped; until the peer closes it.
+	go func() {
+		remote.Write([]byte("x\xff\xffy\xff\xf1z"))
+		remote.Close()
+	}()
+	var buffer bytes.Buffer
+	if _, err := io.Copy(&buffer, conn); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "x\xffyz", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnNetConnDeadlines(t *testing.T) {
+
+	conn, remote := testNetConn(t)
+
+	conn.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+	if _, err := conn.Write([]byte("a")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected a timeout error, but actually got: (%T) %v", err, err)
+	}
+
+	// (It can still be used after.)
+	conn.SetDeadline(time.Time{})
+	go remote.Write([]byte("b"))
+	if p := testReadExactly(t, conn, 1); "b" != string(p) {
+		t.Errorf("Expected %q, but actually got %q.", "b", p)
+	}
+
+	// (What did not get sent, past the deadline, is sent as it closes.)
+	go io.Copy(io.Discard, remote)
+	if err := conn.Close(); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed, but actually got: (%T) %v", err, err)
+	}
+	if _, err := conn.Write([]byte("c")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected net.ErrClosed, but actually got: (%T) %v", err, err)
+	}
+}
@@ -0,0 +1,72 @@
+package telnet
+
+import (
+	"io"
+	"net"
+)
+
+// NewConn has 'rwc' speak the TELNET protocol (as the client end of it); where 'rwc' is any
+// connection, other than one that was dialed over TCP. Such as a WebSocket (for TELNET over a
+// WebSocket; such as to a device behind a gateway, that a terminal in a browser reaches it
+// through), an SSH channel, a serial port, or one end of a net.Pipe. For example:
+//
+//	ws, _, err := websocket.Dial(ctx, "wss://gateway.example.net/telnet/10.0.0.7", nil)
+//	if nil != err {
+//		return err
+//	}
+//
+//	conn := telnet.NewConn(websocket.NetConn(ctx, ws, websocket.MessageBinary),
+//		telnet.WithLogger(logger),
+//	)
+//	defer conn.Close()
+//
+// The Conn is set up by 'options', like a Conn dialed by NewDialer(options...) is; and closing
+// the Conn closes 'rwc'. (See also Dialer.NewConn.)
+//
+// If 'rwc' is a net.Conn, then the Conn's LocalAddr and RemoteAddr are its; and its deadlines
+// are what the Conn's deadlines (and ReadContext, WriteContext, Expect, and the rest, which use
+// them) set. Otherwise the addresses are "pipe" ones; and the deadlines do nothing (so that, for
+// instance, ReadContext does not give up until something is read).
+//
+// (To serve TELNET over such connections instead, hand them to Server.Serve, with a net.Listener
+// that returns them.)
+func NewConn(rwc io.ReadWriteCloser, options ...Option) *Conn {
+	return NewDialer(options...).NewConn(rwc)
+}
+
+// NewConn has 'rwc' speak the TELNET protocol; set up as the Dialer says to (but without dialing
+// anything; so the Timeout, SocketOptions, TLSConfig, and Proxy are not used). (See the NewConn
+// function.)
+func (dialer *Dialer) NewConn(rwc io.ReadWriteCloser) *Conn {
+	conn, ok := rwc.(internalConn)
+	if !ok {
+		conn = internalReadWriteCloserConn{ReadWriteCloser: rwc}
+	}
+
+	return dialer.newConn(conn)
+}
+
+// internalReadWriteCloserConn gives an io.ReadWriteCloser (that does not have them) the addresses
+// a Conn needs.
+type internalReadWriteCloserConn struct {
+	io.ReadWriteCloser
+}
+
+func (internalReadWriteCloserConn) LocalAddr() net.Addr {
+	return internalPipeAddr{}
+}
+
+func (internalReadWriteCloserConn) RemoteAddr() net.Addr {
+	return internalPipeAddr{}
+}
+
+// internalPipeAddr is the address of a connection that has none. (Like net.Pipe's.)
+type internalPipeAddr struct{}
+
+func (internalPipeAddr) Network() string {
+	return "pipe"
+}
+
+func (internalPipeAddr) String() string {
+	return "pipe"
+}
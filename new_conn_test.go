@@ -0,0 +1,87 @@
+package telnet
+
+import (
+	"io"
+	"time"
+
+	"testing"
+)
+
+// testReadWriteCloser is an io.ReadWriteCloser (that is not a net.Conn); made of two io.Pipes.
+type testReadWriteCloser struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (rwc testReadWriteCloser) Close() error {
+	rwc.PipeReader.Close()
+	return rwc.PipeWriter.Close()
+}
+
+func testReadWriteClosers() (testReadWriteCloser, testReadWriteCloser) {
+	localReader, remoteWriter := io.Pipe()
+	remoteReader, localWriter := io.Pipe()
+
+	return testReadWriteCloser{localReader, localWriter}, testReadWriteCloser{remoteReader, remoteWriter}
+}
+
+func TestNewConn(t *testing.T) {
+
+	local, remote := testReadWriteClosers()
+	defer remote.Close()
+
+	var connected *Conn
+	conn := NewConn(local, WithTrace(&Trace{
+		Connected: func(conn *Conn) {
+			connected = conn
+		},
+	}))
+	defer conn.Close()
+
+	if expected, actual := conn, connected; expected != actual {
+		t.Errorf("Expected Trace.Connected to be called with %p, but actually got %p.", expected, actual)
+	}
+	if expected, actual := "pipe", conn.RemoteAddr().String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (It speaks TELNET over it; such as answering a negotiation.)
+	answers := make(chan []byte, 1)
+	go func() {
+		answer := make([]byte, 3)
+		io.ReadFull(remote, answer)
+		answers <- answer
+	}()
+	go remote.Write([]byte{'h', 'i', IAC, DO, OptEcho, IAC, IAC})
+
+	p := make([]byte, 3)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hi\xff", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := string([]byte{IAC, WONT, OptEcho}), string(<-answers); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (Closing the Conn closes what it was made with.)
+	if err := conn.Close(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := remote.Read(make([]byte, 1))
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if expected, actual := io.EOF, err; expected != actual {
+			t.Errorf("Expected %v, but actually got: (%T) %v", expected, actual, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected it to be closed, but it actually was not.")
+	}
+}
@@ -0,0 +1,123 @@
+package telnet
+
+import (
+	"strconv"
+)
+
+// TELNET option codes.
+//
+// These are used with the WILL, WONT, DO, and DONT TELNET commands (to negotiate whether
+// an option is enabled) and with the SB TELNET command (to subnegotiate the option's parameters).
+const (
+	OptBinary          byte = 0   // RFC 856: Binary Transmission.
+	OptEcho            byte = 1   // RFC 857: Echo.
+	OptSuppressGoAhead byte = 3   // RFC 858: Suppress Go Ahead.
+	OptStatus          byte = 5   // RFC 859: Status.
+	OptTimingMark      byte = 6   // RFC 860: Timing Mark.
+	OptLogout          byte = 18  // RFC 727: Logout.
+	OptTerminalType    byte = 24  // RFC 1091: Terminal Type.
+	OptEndOfRecord     byte = 25  // RFC 885: End of Record.
+	OptNAWS            byte = 31  // RFC 1073: Negotiate About Window Size.
+	OptTerminalSpeed   byte = 32  // RFC 1079: Terminal Speed.
+	OptLinemode        byte = 34  // RFC 1184: Linemode.
+	OptNewEnviron      byte = 39  // RFC 1572: New Environment.
+	OptCharset         byte = 42  // RFC 2066: Charset.
+	OptMSDP            byte = 69  // MSDP: Mud Server Data Protocol.
+	OptMSSP            byte = 70  // MSSP: Mud Server Status Protocol.
+	OptCompress        byte = 85  // MCCP (version 1): Mud Client Compression Protocol.
+	OptCompress2       byte = 86  // MCCP (version 2): Mud Client Compression Protocol.
+	OptCompress3       byte = 87  // MCCP (version 3): Mud Client Compression Protocol; for what the client sends.
+	OptZMP             byte = 93  // ZMP: Zenith MUD Protocol.
+	OptGMCP            byte = 201 // GMCP: Generic Mud Communication Protocol.
+)
+
+// OptionName returns the conventional name of the TELNET option code 'option',
+// such as "ECHO" or "NAWS".
+//
+// If 'option' is not a known TELNET option code, then its decimal value is returned instead.
+func OptionName(option byte) string {
+	switch option {
+	case OptBinary:
+		return "BINARY"
+	case OptEcho:
+		return "ECHO"
+	case OptSuppressGoAhead:
+		return "SUPPRESS-GO-AHEAD"
+	case OptStatus:
+		return "STATUS"
+	case OptTimingMark:
+		return "TIMING-MARK"
+	case OptLogout:
+		return "LOGOUT"
+	case OptTerminalType:
+		return "TERMINAL-TYPE"
+	case OptEndOfRecord:
+		return "END-OF-RECORD"
+	case OptNAWS:
+		return "NAWS"
+	case OptTerminalSpeed:
+		return "TERMINAL-SPEED"
+	case OptLinemode:
+		return "LINEMODE"
+	case OptNewEnviron:
+		return "NEW-ENVIRON"
+	case OptCharset:
+		return "CHARSET"
+	case OptMSDP:
+		return "MSDP"
+	case OptMSSP:
+		return "MSSP"
+	case OptCompress:
+		return "COMPRESS"
+	case OptCompress2:
+		return "COMPRESS2"
+	case OptCompress3:
+		return "COMPRESS3"
+	case OptZMP:
+		return "ZMP"
+	case OptGMCP:
+		return "GMCP"
+	default:
+		return strconv.Itoa(int(option))
+	}
+}
+
+// An OptionPolicy determines how a Conn answers the peer when the peer asks for
+// (i.e., sends a DO for) or offers (i.e., sends a WILL for) an option that the
+// Conn does not otherwise support.
+//
+// The zero value is OptionPolicyRefuse.
+type OptionPolicy int
+
+const (
+	// OptionPolicyRefuse answers every DO with a WONT, and every WILL with a DONT.
+	//
+	// This is what a standards-compliant TELNET endpoint does, and is the default.
+	OptionPolicyRefuse OptionPolicy = iota
+
+	// OptionPolicyAccept answers every DO with a WILL, and every WILL with a DO.
+	//
+	// This can be useful for, for example, honeypots that want to look permissive.
+	// Note that although the option becomes (nominally) enabled, nothing is done to
+	// actually implement the option.
+	OptionPolicyAccept
+
+	// OptionPolicyIgnore does not answer at all.
+	//
+	// Note that some peers will stall, waiting for an answer, if this is used.
+	OptionPolicyIgnore
+)
+
+// String returns the name of the policy.
+func (policy OptionPolicy) String() string {
+	switch policy {
+	case OptionPolicyRefuse:
+		return "refuse"
+	case OptionPolicyAccept:
+		return "accept"
+	case OptionPolicyIgnore:
+		return "ignore"
+	default:
+		return "OptionPolicy(" + strconv.Itoa(int(policy)) + ")"
+	}
+}
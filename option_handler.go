@@ -0,0 +1,145 @@
+package telnet
+
+// An OptionHandler implements a TELNET option.
+//
+// An OptionHandler is registered with a Conn for a particular option code, using Conn.RegisterOption.
+// For example:
+//
+//	conn.RegisterOption(0xAA, &myVendorOption{})
+//
+// Once registered, the Conn negotiates the option (WILL, WONT, DO, DONT) on the OptionHandler's
+// behalf, according to the OptionSupport it returns from Register, tells the OptionHandler
+// whenever the option is enabled or disabled (on either side), and hands it any subnegotiations
+// (IAC SB ... IAC SE) the peer sends for the option.
+//
+// The OptionSender passed to Register can be used by the OptionHandler to send its own
+// subnegotiations, and to (try to) enable or disable the option.
+//
+// All the methods of an OptionHandler are called from whatever goroutine is reading
+// from the Conn, with one exception: Register is called from whatever goroutine calls
+// Conn.RegisterOption. The methods are never called while the Conn holds any of its locks,
+// so an OptionHandler may use its OptionSender (or the Conn) from within them.
+type OptionHandler interface {
+	// Register is called (once) when the OptionHandler is registered with a Conn.
+	Register(sender OptionSender) OptionSupport
+
+	// LocalChanged is called after the option has become enabled (or disabled) on our side.
+	// I.e., after we are (or are no longer) performing the option.
+	LocalChanged(enabled bool)
+
+	// RemoteChanged is called after the option has become enabled (or disabled) on the peer's
+	// side. I.e., after the peer is (or is no longer) performing the option.
+	RemoteChanged(enabled bool)
+
+	// Subnegotiation is called when the peer sends a subnegotiation for the option.
+	//
+	// 'payload' is the (un-escaped) data between the option code and the IAC SE.
+	// So, for example, for:
+	//
+	//	IAC SB TERMINAL-TYPE IS 'V' 'T' '5' '2' IAC SE
+	//
+	// ... 'payload' would be:
+	//
+	//	IS 'V' 'T' '5' '2'
+	Subnegotiation(payload []byte)
+}
+
+// OptionSupport is returned by an OptionHandler's Register method, and says which side(s)
+// of the option it supports.
+type OptionSupport struct {
+	// Local is whether we are willing to perform the option. I.e., whether we answer a DO with a WILL.
+	Local bool
+
+	// Remote is whether we are willing to have the peer perform the option. I.e., whether we
+	// answer a WILL with a DO.
+	Remote bool
+
+	// RequestLocal is whether to offer (i.e., send a WILL for) the option, as soon as the
+	// OptionHandler is registered.
+	RequestLocal bool
+
+	// RequestRemote is whether to ask for (i.e., send a DO for) the option, as soon as the
+	// OptionHandler is registered.
+	RequestRemote bool
+}
+
+// An OptionSender is given to an OptionHandler (when it is registered) so that it can
+// send things, for its option, to the peer.
+type OptionSender interface {
+	// Conn returns the Conn the OptionHandler is registered with.
+	Conn() *Conn
+
+	// SendSubnegotiation sends IAC SB <option> <payload> IAC SE to the peer, (correctly)
+	// escaping any IAC in 'payload'.
+	SendSubnegotiation(payload []byte) error
+
+	// EnableLocal offers (i.e., sends a WILL for) the option, unless it is already enabled
+	// (or being enabled) on our side.
+	EnableLocal() error
+
+	// DisableLocal sends a WONT for the option, unless it is already disabled (or being
+	// disabled) on our side.
+	DisableLocal() error
+
+	// EnableRemote asks for (i.e., sends a DO for) the option, unless it is already enabled
+	// (or being enabled) on the peer's side.
+	EnableRemote() error
+
+	// DisableRemote sends a DONT for the option, unless it is already disabled (or being
+	// disabled) on the peer's side.
+	DisableRemote() error
+}
+
+type internalOptionSender struct {
+	conn   *Conn
+	option byte
+}
+
+func (sender internalOptionSender) Conn() *Conn {
+	return sender.conn
+}
+
+func (sender internalOptionSender) SendSubnegotiation(payload []byte) error {
+	return sender.conn.SendSubnegotiation(sender.option, payload)
+}
+
+func (sender internalOptionSender) EnableLocal() error {
+	return sender.conn.negotiator.request(sender.option, true, true)
+}
+
+func (sender internalOptionSender) DisableLocal() error {
+	return sender.conn.negotiator.request(sender.option, true, false)
+}
+
+func (sender internalOptionSender) EnableRemote() error {
+	return sender.conn.negotiator.request(sender.option, false, true)
+}
+
+func (sender internalOptionSender) DisableRemote() error {
+	return sender.conn.negotiator.request(sender.option, false, false)
+}
+
+// SimpleOption returns an OptionHandler for options that do not have any subnegotiation
+// (such as SUPPRESS-GO-AHEAD), where all that matters is whether the option is enabled.
+//
+// For example:
+//
+//	conn.RegisterOption(telnet.OptSuppressGoAhead, telnet.SimpleOption(telnet.OptionSupport{
+//		Local:  true,
+//		Remote: true,
+//	}))
+func SimpleOption(support OptionSupport) OptionHandler {
+	return internalSimpleOption{support: support}
+}
+
+type internalSimpleOption struct {
+	support OptionSupport
+}
+
+func (handler internalSimpleOption) Register(OptionSender) OptionSupport {
+	return handler.support
+}
+
+func (internalSimpleOption) LocalChanged(bool)     {}
+func (internalSimpleOption) RemoteChanged(bool)    {}
+func (internalSimpleOption) Subnegotiation([]byte) {}
@@ -0,0 +1,184 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+type testOptionHandler struct {
+	mutex sync.Mutex
+
+	support OptionSupport
+	sender  OptionSender
+
+	events          []string
+	subnegotiations [][]byte
+}
+
+func (handler *testOptionHandler) Register(sender OptionSender) OptionSupport {
+	handler.sender = sender
+	return handler.support
+}
+
+func (handler *testOptionHandler) LocalChanged(enabled bool) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	if enabled {
+		handler.events = append(handler.events, "local enabled")
+	} else {
+		handler.events = append(handler.events, "local disabled")
+	}
+}
+
+func (handler *testOptionHandler) RemoteChanged(enabled bool) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	if enabled {
+		handler.events = append(handler.events, "remote enabled")
+	} else {
+		handler.events = append(handler.events, "remote disabled")
+	}
+}
+
+func (handler *testOptionHandler) Subnegotiation(payload []byte) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	handler.subnegotiations = append(handler.subnegotiations, payload)
+
+	// Echo it back; to exercise the OptionSender.
+	handler.sender.SendSubnegotiation(payload)
+}
+
+func (handler *testOptionHandler) Events() []string {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
+	return append([]string(nil), handler.events...)
+}
+
+// testPipe returns a *Conn, and the (raw) other end of its connection.
+//
+// Everything the *Conn receives is read (and discarded) in the background, so that
+// it does all its command processing.
+func testPipe(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	conn := newConn(local, nil)
+
+	go io.Copy(io.Discard, conn)
+
+	return conn, remote
+}
+
+func testReadExactly(t *testing.T, conn net.Conn, n int) []byte {
+	t.Helper()
+
+	p := make([]byte, n)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return p
+}
+
+func TestConnRegisterOption(t *testing.T) {
+
+	const option = 0xAA
+
+	conn, remote := testPipe(t)
+
+	handler := testOptionHandler{support: OptionSupport{Local: true}}
+	if err := conn.RegisterOption(option, &handler); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, err := remote.Write([]byte{IAC, DO, option, IAC, WILL, option}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := []byte{IAC, WILL, option, IAC, DONT, option}, testReadExactly(t, remote, 6); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	// Includes an (escaped) IAC in the payload.
+	if _, err := remote.Write([]byte{IAC, SB, option, 1, 2, IAC, IAC, 3, IAC, SE}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := []byte{IAC, SB, option, 1, 2, IAC, IAC, 3, IAC, SE}, testReadExactly(t, remote, 10); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	if _, err := remote.Write([]byte{IAC, DONT, option}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := []byte{IAC, WONT, option}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	for i := 0; i < 100 && len(handler.Events()) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if expected, actual := []string{"local enabled", "local disabled"}, handler.Events(); len(expected) != len(actual) || expected[0] != actual[0] || expected[1] != actual[1] {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnRegisterOptionWhenLive(t *testing.T) {
+
+	const option = 0xAA
+
+	conn, remote := testPipe(t)
+
+	// The connection is already being used.
+	if _, err := remote.Write([]byte{IAC, WILL, option}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []byte{IAC, DONT, option}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	handler := testOptionHandler{support: OptionSupport{Remote: true, RequestRemote: true}}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RegisterOption(option, &handler)
+	}()
+
+	if expected, actual := []byte{IAC, DO, option}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// Answering the DO is not answered again.
+	if _, err := remote.Write([]byte{IAC, WILL, option, IAC, NOP}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	for i := 0; i < 100 && len(handler.Events()) <= 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if expected, actual := []string{"remote enabled"}, handler.Events(); len(expected) != len(actual) || expected[0] != actual[0] {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
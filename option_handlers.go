@@ -0,0 +1,46 @@
+package telnet
+
+import (
+	"sort"
+)
+
+// OptionHandlers are the options that a Server (or a Dialer) registers on each of its connections;
+// with, for each option code, what makes the OptionHandler for it. (Each connection gets its own
+// OptionHandler; as an OptionHandler is told of, and keeps, the state of its option on the one
+// connection it is registered with.) For example:
+//
+//	server := &telnet.Server{
+//		Handler: handler,
+//		OptionHandlers: telnet.OptionHandlers{
+//			telnet.OptGMCP: func() telnet.OptionHandler {
+//				return &myGMCPOption{}
+//			},
+//		},
+//	}
+//
+// (This is so that the library dispatches the DO, DONT, WILL, WONT, and SB ... SE, of those
+// options, to them; from the start of each connection. See Conn.RegisterOption, which is what it
+// calls.)
+type OptionHandlers map[byte]func() OptionHandler
+
+// register registers an OptionHandler (made for it) for each of the options on 'conn'; in order of
+// option code (so that what is sent for them is always sent in the same order).
+func (handlers OptionHandlers) register(conn *Conn) error {
+	options := make([]int, 0, len(handlers))
+	for option := range handlers {
+		options = append(options, int(option))
+	}
+	sort.Ints(options)
+
+	for _, option := range options {
+		fn := handlers[byte(option)]
+		if nil == fn {
+			continue
+		}
+		if err := conn.RegisterOption(byte(option), fn()); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package telnet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestOptionHandlersServer(t *testing.T) {
+
+	made := make(chan *testOptionHandler, 2)
+	server := NewServer("",
+		WithOptionHandler(OptEcho, func() OptionHandler {
+			handler := &testOptionHandler{support: OptionSupport{Local: true, RequestLocal: true}}
+			made <- handler
+			return handler
+		}),
+		WithOptionHandler(OptSuppressGoAhead, func() OptionHandler {
+			return &testOptionHandler{support: OptionSupport{Local: true, RequestLocal: true}}
+		}),
+		WithContextHandler(testContextHandler(func(ctx context.Context, conn *Conn) {
+			io.Copy(io.Discard, conn)
+		})),
+	)
+
+	client := testServe(t, server)
+
+	// (In order of option code; SUPPRESS-GO-AHEAD is 3, and ECHO is 1.)
+	p := testReadExactly(t, client, 6)
+	if expected, actual := []byte{IAC, WILL, OptEcho, IAC, WILL, OptSuppressGoAhead}, p; !bytes.Equal(expected, actual) {
+		t.Fatalf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	var handler *testOptionHandler
+	select {
+	case handler = <-made:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the OptionHandler to be made, but actually it was not.")
+	}
+
+	client.Write([]byte{IAC, DO, OptEcho})
+	for deadline := time.Now().Add(3 * time.Second); len(handler.Events()) < 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if expected, actual := []string{"local enabled"}, handler.Events(); !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (Each connection gets its own OptionHandler.)
+	testServe(t, server)
+	select {
+	case other := <-made:
+		if handler == other {
+			t.Errorf("Expected each connection to get its own OptionHandler, but actually they did not.")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the OptionHandler to be made, but actually it was not.")
+	}
+}
+
+func TestOptionHandlersDialer(t *testing.T) {
+
+	received := make(chan []byte, 1)
+	listener := testListen(t, func(c net.Conn) {
+		p := make([]byte, 3)
+		c.SetReadDeadline(time.Now().Add(3 * time.Second))
+		io.ReadFull(c, p)
+		received <- p
+
+		c.Write([]byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE})
+		io.Copy(io.Discard, c)
+	})
+
+	handler := &testOptionHandler{support: OptionSupport{Remote: true, RequestRemote: true}}
+	dialer := &Dialer{
+		OptionHandlers: OptionHandlers{
+			OptNAWS: func() OptionHandler { return handler },
+		},
+	}
+
+	conn, err := dialer.DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+	go io.Copy(io.Discard, conn)
+
+	select {
+	case p := <-received:
+		if expected, actual := []byte{IAC, DO, OptNAWS}, p; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the DO to be sent, but actually it was not.")
+	}
+
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		handler.mutex.Lock()
+		n := len(handler.subnegotiations)
+		handler.mutex.Unlock()
+		if 0 < n {
+			break
+		}
+	}
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	if expected, actual := 1, len(handler.subnegotiations); expected != actual {
+		t.Fatalf("Expected %d subnegotiations, but actually got %d.", expected, actual)
+	}
+	if expected, actual := []byte{0, 80, 0, 24}, handler.subnegotiations[0]; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestWithOptionHandler(t *testing.T) {
+
+	fn := func() OptionHandler { return &testOptionHandler{} }
+
+	server := NewServer("", WithOptionHandler(OptEcho, fn), WithOptionHandler(OptNAWS, fn))
+	dialer := NewDialer(WithOptionHandler(OptNAWS, fn))
+
+	tests := []struct {
+		Handlers OptionHandlers
+		Expected []byte
+	}{
+		{
+			Handlers: server.OptionHandlers,
+			Expected: []byte{OptEcho, OptNAWS},
+		},
+		{
+			Handlers: dialer.OptionHandlers,
+			Expected: []byte{OptNAWS},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := len(test.Expected), len(test.Handlers); expected != actual {
+			t.Errorf("For test #%d, expected %d options, but actually got %d.", testNumber, expected, actual)
+			continue
+		}
+		for _, option := range test.Expected {
+			if nil == test.Handlers[option] {
+				t.Errorf("For test #%d, expected %s to be registered, but actually it was not.", testNumber, OptionName(option))
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOptionRefused is returned (by RequestEnableRemote, and RequestEnableLocal) when the peer
+// refuses to enable an option; i.e., answers a DO with a WONT, or a WILL with a DONT.
+var ErrOptionRefused = errors.New("telnet: option refused by peer")
+
+// RequestEnableRemote asks the peer to perform 'option' (i.e., sends a DO for it); and waits for
+// the peer to answer. For example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//
+//	if err := conn.RequestEnableRemote(ctx, telnet.OptNAWS); nil != err {
+//		//@TODO: Do without the window size.
+//	}
+//
+// It returns nil once the option is enabled on the peer's side; ErrOptionRefused, if the peer
+// refuses it; and the context's error (such as context.DeadlineExceeded) if the context is done
+// first. (The negotiation keeps going, after that; it is only the waiting that stops.) If the
+// Conn's negotiation timeout (see SetNegotiationTimeout) passes first, then it returns
+// context.DeadlineExceeded. And, if the Conn is closed first, it returns ErrClosed.
+//
+// If the option is already enabled on the peer's side, then it returns nil right away (without
+// sending anything). And, if a negotiation of it is already in progress (such as from another
+// call of RequestEnableRemote, or from RegisterOption), then nothing more is sent; it waits for
+// the outcome of that one (per RFC 1143).
+//
+// (Whether the peer's answer is accepted is not up to the OptionHandler, or the OptionPolicy, for
+// the option; as it is the Conn that asked for it.)
+func (clientConn *Conn) RequestEnableRemote(ctx context.Context, option byte) error {
+	return awaitNegotiation(ctx, clientConn.RequestEnableRemoteAsync(option))
+}
+
+// RequestEnableLocal offers to perform 'option' (i.e., sends a WILL for it); and waits for the
+// peer to answer. It returns nil once the option is enabled on our side; and ErrOptionRefused, if
+// the peer refuses it. (It is otherwise like RequestEnableRemote.)
+func (clientConn *Conn) RequestEnableLocal(ctx context.Context, option byte) error {
+	return awaitNegotiation(ctx, clientConn.RequestEnableLocalAsync(option))
+}
+
+// WaitForNegotiations waits for each of the option negotiations that are in progress (such as
+// those started by RegisterOption, or by RequestEnableRemoteAsync) to be settled; whether the
+// option was agreed to, or refused. For example:
+//
+//	conn.RegisterOption(telnet.OptNAWS, nawsHandler)
+//	conn.RegisterOption(telnet.OptTerminalType, terminalTypeHandler)
+//
+//	if err := conn.WaitForNegotiations(ctx); nil != err {
+//		return err
+//	}
+//
+// It returns the context's error if the context is done first; and ErrClosed if the Conn is
+// closed first. (The peer's answers are only received while the Conn is being read; so it is for
+// calling from some other goroutine than the one reading.)
+func (clientConn *Conn) WaitForNegotiations(ctx context.Context) error {
+	for _, result := range clientConn.negotiator.awaitPending() {
+		select {
+		case err := <-result:
+			if nil != err && ErrOptionRefused != err {
+				return err
+			}
+		case <-clientConn.done:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// RequestEnableRemoteAsync is like RequestEnableRemote; but, rather than waiting, it returns a
+// channel, that the outcome is sent to (once). For example:
+//
+//	naws := conn.RequestEnableRemoteAsync(telnet.OptNAWS)
+//	ttype := conn.RequestEnableRemoteAsync(telnet.OptTerminalType)
+//
+//	nawsErr, ttypeErr := <-naws, <-ttype
+//
+// (The channel is buffered; so it does not matter if nothing ever receives from it.)
+func (clientConn *Conn) RequestEnableRemoteAsync(option byte) <-chan error {
+	return clientConn.requestEnable(option, false)
+}
+
+// RequestEnableLocalAsync is like RequestEnableLocal; but, rather than waiting, it returns a
+// channel, that the outcome is sent to (once). (See RequestEnableRemoteAsync.)
+func (clientConn *Conn) RequestEnableLocalAsync(option byte) <-chan error {
+	return clientConn.requestEnable(option, true)
+}
+
+func (clientConn *Conn) requestEnable(option byte, local bool) <-chan error {
+	result := make(chan error, 1)
+
+	// (If sending fails, then that is what is sent to 'result'.)
+	clientConn.negotiator.requestWaiting(option, local, true, result)
+
+	return result
+}
+
+// awaitNegotiation waits for the outcome (sent to 'result'), or for 'ctx' to be done.
+func awaitNegotiation(ctx context.Context, result <-chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// awaitLocked has the outcome of the negotiation of 'option', on our side (if 'local' is true) or
+// the peer's side, sent to 'result' once it is settled; or right away, if it already is. (The
+// mutex must be held.)
+func (negotiator *internalNegotiator) awaitLocked(option byte, local bool, result chan error) {
+	side := 0
+	if local {
+		side = 1
+	}
+	negotiator.waiters[option][side] = append(negotiator.waiters[option][side], result)
+
+	negotiator.settleLocked(option)
+}
+
+// settleLocked sends the outcome to what is waiting on the negotiation of 'option' (on either
+// side), if it is settled; i.e., nil if the option is enabled, and ErrOptionRefused if it is not.
+// (The mutex must be held.)
+func (negotiator *internalNegotiator) settleLocked(option byte) {
+	q := negotiator.options[option]
+
+	for _, local := range []bool{false, true} {
+		state := q.him
+		if local {
+			state = q.us
+		}
+
+		switch state {
+		case qYes:
+			negotiator.resolveLocked(option, local, nil)
+		case qNo:
+			negotiator.resolveLocked(option, local, ErrOptionRefused)
+		}
+	}
+}
+
+// resolveLocked sends 'err' to what is waiting on the negotiation of 'option', on our side (if
+// 'local' is true) or the peer's side. (The mutex must be held.)
+func (negotiator *internalNegotiator) resolveLocked(option byte, local bool, err error) {
+	side := 0
+	if local {
+		side = 1
+	}
+
+	// (Each channel is buffered, and is only ever sent the 1 outcome; so this never blocks.)
+	for _, result := range negotiator.waiters[option][side] {
+		result <- err
+	}
+	negotiator.waiters[option][side] = nil
+}
+
+// awaitPending returns a channel for each of the negotiations (of an option, on one side) that is
+// in progress; which the outcome of it is sent to, once it is settled.
+func (negotiator *internalNegotiator) awaitPending() []chan error {
+	negotiator.mutex.Lock()
+	defer negotiator.mutex.Unlock()
+
+	var results []chan error
+	for option := range negotiator.options {
+		state := negotiator.options[option].state()
+
+		for _, local := range []bool{false, true} {
+			if (local && !state.LocalPending) || (!local && !state.RemotePending) {
+				continue
+			}
+
+			result := make(chan error, 1)
+			negotiator.awaitLocked(byte(option), local, result)
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// abandon sends 'err' to everything that is waiting on a negotiation; such as once the Conn is
+// closed.
+func (negotiator *internalNegotiator) abandon(err error) {
+	negotiator.mutex.Lock()
+	defer negotiator.mutex.Unlock()
+
+	for option := range negotiator.waiters {
+		negotiator.resolveLocked(byte(option), false, err)
+		negotiator.resolveLocked(byte(option), true, err)
+	}
+}
@@ -0,0 +1,170 @@
+package telnet
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"testing"
+)
+
+func TestConnRequestEnable(t *testing.T) {
+
+	const option = 0xAA
+
+	tests := []struct {
+		Local    bool
+		Answer   byte
+		Expected error
+	}{
+		{
+			Answer:   WILL,
+			Expected: nil,
+		},
+		{
+			Answer:   WONT,
+			Expected: ErrOptionRefused,
+		},
+		{
+			Local:    true,
+			Answer:   DO,
+			Expected: nil,
+		},
+		{
+			Local:    true,
+			Answer:   DONT,
+			Expected: ErrOptionRefused,
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		request, verb := conn.RequestEnableRemote, DO
+		if test.Local {
+			request, verb = conn.RequestEnableLocal, WILL
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- request(context.Background(), option)
+		}()
+
+		if expected, actual := []byte{IAC, verb, option}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if _, err := remote.Write([]byte{IAC, test.Answer, option}); nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+
+		select {
+		case err := <-errs:
+			if expected, actual := test.Expected, err; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("For test #%d, expected the request to return, but it did not.", testNumber)
+		}
+	}
+}
+
+func TestConnRequestEnableRemoteCoalesces(t *testing.T) {
+
+	const option = 0xAA
+
+	conn, remote := testPipe(t)
+
+	asked := testReadLater(remote, 3)
+	results := []<-chan error{
+		conn.RequestEnableRemoteAsync(option),
+		conn.RequestEnableRemoteAsync(option),
+		conn.RequestEnableRemoteAsync(option),
+	}
+	if expected, actual := []byte{IAC, DO, option}, <-asked; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	if _, err := remote.Write([]byte{IAC, WILL, option}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	for resultNumber, result := range results {
+		select {
+		case err := <-result:
+			if nil != err {
+				t.Errorf("For result #%d, did not expect an error, but actually got one: (%T) %v", resultNumber, err, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("For result #%d, expected an outcome, but did not get one.", resultNumber)
+		}
+	}
+
+	// Once enabled, asking again returns right away; and sends nothing.
+	if err := conn.RequestEnableRemote(context.Background(), option); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	go conn.Write([]byte("x"))
+	if expected, actual := []byte("x"), testReadExactly(t, remote, 1); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %q (i.e., nothing sent before it), but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnRequestEnableRemoteNotAnswered(t *testing.T) {
+
+	const option = 0xAA
+
+	t.Run("context", func(t *testing.T) {
+		conn, remote := testPipe(t)
+
+		asked := testReadLater(remote, 3)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if expected, actual := context.DeadlineExceeded, conn.RequestEnableRemote(ctx, option); expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+		<-asked
+
+		// (The negotiation is still in progress.)
+		if expected, actual := (OptionState{RemotePending: true}), conn.OptionStates()[option]; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	})
+
+	t.Run("negotiation timeout", func(t *testing.T) {
+		conn, remote := testPipe(t)
+		conn.SetNegotiationTimeout(20 * time.Millisecond)
+
+		asked := testReadLater(remote, 3)
+
+		if expected, actual := context.DeadlineExceeded, conn.RequestEnableRemote(context.Background(), option); expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+		<-asked
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		conn, remote := testPipe(t)
+
+		asked := testReadLater(remote, 3)
+		result := conn.RequestEnableRemoteAsync(option)
+		<-asked
+
+		conn.Close()
+
+		select {
+		case err := <-result:
+			if expected, actual := ErrClosed, err; expected != actual {
+				t.Errorf("Expected %v, but actually got %v.", expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Expected an outcome, but did not get one.")
+		}
+
+		if expected, actual := ErrClosed, conn.RequestEnableLocal(context.Background(), option); expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package telnet
+
+import (
+	"strings"
+)
+
+// OptionState is the negotiated state of a single TELNET option.
+type OptionState struct {
+	Local  bool // Whether we are performing the option. (I.e., we said WILL, and the peer said DO.)
+	Remote bool // Whether the peer is performing the option. (I.e., the peer said WILL, and we said DO.)
+
+	LocalPending  bool // Whether we are waiting on the peer to answer our WILL or WONT.
+	RemotePending bool // Whether we are waiting on the peer to answer our DO or DONT.
+}
+
+// String returns a (short) human readable description of the OptionState.
+//
+// For example:
+//
+//	local=yes remote=no
+//
+// ... or (while waiting on the peer to answer):
+//
+//	local=no remote=pending
+func (state OptionState) String() string {
+	return "local=" + optionStateSide(state.Local, state.LocalPending) + " remote=" + optionStateSide(state.Remote, state.RemotePending)
+}
+
+func optionStateSide(enabled bool, pending bool) string {
+	switch {
+	case pending:
+		return "pending"
+	case enabled:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+// OptionStates is a snapshot of the negotiated state of (some) TELNET options.
+//
+// See Conn.OptionStates.
+type OptionStates map[byte]OptionState
+
+// String returns a (short) human readable description of the OptionStates, with the options
+// in numerical order.
+//
+// For example:
+//
+//	ECHO(local=yes remote=no) SUPPRESS-GO-AHEAD(local=yes remote=yes) NAWS(local=no remote=yes)
+func (states OptionStates) String() string {
+	var buffer strings.Builder
+
+	for option := 0; option <= 255; option++ {
+		state, ok := states[byte(option)]
+		if !ok {
+			continue
+		}
+
+		if 0 < buffer.Len() {
+			buffer.WriteByte(' ')
+		}
+		buffer.WriteString(OptionName(byte(option)))
+		buffer.WriteByte('(')
+		buffer.WriteString(state.String())
+		buffer.WriteByte(')')
+	}
+
+	return buffer.String()
+}
+
+// An OptionChange reports a TELNET option having become enabled (or disabled) on one side.
+//
+// See Conn.NotifyOptionChanges.
+type OptionChange struct {
+	Option byte
+
+	// Local is true if it is our side the option changed on; and false if it is the peer's side.
+	Local   bool
+	Enabled bool
+
+	// State is the state of the option (on both sides), after the change.
+	State OptionState
+}
+
+// String returns a (short) human readable description of the OptionChange.
+//
+// For example:
+//
+//	ECHO local=yes
+func (change OptionChange) String() string {
+	side := "remote="
+	if change.Local {
+		side = "local="
+	}
+	return OptionName(change.Option) + " " + side + optionStateSide(change.Enabled, false)
+}
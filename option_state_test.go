@@ -0,0 +1,117 @@
+package telnet
+
+import (
+	"testing"
+)
+
+func TestOptionStatesString(t *testing.T) {
+
+	tests := []struct {
+		States   OptionStates
+		Expected string
+	}{
+		{
+			States:   OptionStates{},
+			Expected: "",
+		},
+		{
+			States:   OptionStates{OptEcho: {Local: true}},
+			Expected: "ECHO(local=yes remote=no)",
+		},
+		{
+			States: OptionStates{
+				OptNAWS:            {RemotePending: true},
+				OptEcho:            {Local: true},
+				OptSuppressGoAhead: {Local: true, Remote: true},
+				200:                {Remote: true},
+			},
+			Expected: "ECHO(local=yes remote=no) SUPPRESS-GO-AHEAD(local=yes remote=yes) NAWS(local=no remote=pending) 200(local=no remote=yes)",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, test.States.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestConnOptionEnabled(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOptionPolicy(OptSuppressGoAhead, OptionPolicyAccept)
+
+	// What OptionEnabled says, from inside the callback, must match the event.
+	inconsistent := make(chan NegotiationEvent, 8)
+	conn.OnNegotiationEvent(func(event NegotiationEvent) {
+		local, remote := conn.OptionEnabled(event.Option)
+		if local != event.State.Local || remote != event.State.Remote {
+			inconsistent <- event
+		}
+	})
+
+	if _, err := remote.Write([]byte{IAC, DO, OptSuppressGoAhead, IAC, WILL, OptSuppressGoAhead}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	testReadExactly(t, remote, 6)
+
+	go conn.negotiator.request(OptNAWS, false, true)
+	testReadExactly(t, remote, 3)
+
+	if local, remote := conn.OptionEnabled(OptSuppressGoAhead); !local || !remote {
+		t.Errorf("Expected SUPPRESS-GO-AHEAD to be enabled on both sides, but actually got: local=%t remote=%t", local, remote)
+	}
+
+	if expected, actual := "SUPPRESS-GO-AHEAD(local=yes remote=yes) NAWS(local=no remote=pending)", conn.OptionStates().String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	select {
+	case event := <-inconsistent:
+		t.Errorf("Inconsistent state inside callback for event: %+v", event)
+	default:
+	}
+}
+
+func TestConnNotifyOptionChanges(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOptionPolicy(OptSuppressGoAhead, OptionPolicyAccept)
+
+	changes := make(chan OptionChange, 8)
+	conn.NotifyOptionChanges(changes)
+
+	// (A refusal is not a change; nor is agreeing to what was already so.)
+	if _, err := remote.Write([]byte{IAC, DO, OptSuppressGoAhead, IAC, WILL, OptNAWS, IAC, DO, OptSuppressGoAhead, IAC, WILL, OptSuppressGoAhead, IAC, DONT, OptSuppressGoAhead}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	testReadExactly(t, remote, 12)
+
+	expected := []OptionChange{
+		{Option: OptSuppressGoAhead, Local: true, Enabled: true, State: OptionState{Local: true}},
+		{Option: OptSuppressGoAhead, Local: false, Enabled: true, State: OptionState{Local: true, Remote: true}},
+		{Option: OptSuppressGoAhead, Local: true, Enabled: false, State: OptionState{Remote: true}},
+	}
+	for changeNumber, expected := range expected {
+		if actual := <-changes; expected != actual {
+			t.Errorf("For change #%d, expected %v (%+v), but actually got %v (%+v).", changeNumber, expected, expected, actual, actual)
+		}
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("Did not expect another change, but actually got: %+v", change)
+	default:
+	}
+
+	conn.NotifyOptionChanges(nil)
+	remote.Write([]byte{IAC, DO, OptSuppressGoAhead})
+	testReadExactly(t, remote, 3)
+
+	select {
+	case change := <-changes:
+		t.Errorf("Did not expect a change (once the channel was unregistered), but actually got: %+v", change)
+	default:
+	}
+}
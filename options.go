@@ -0,0 +1,403 @@
+package telnet
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// An Option configures a Server (see NewServer), or a Dialer (see NewDialer). For example:
+//
+//	options := []telnet.Option{
+//		telnet.WithLogger(logger),
+//		telnet.WithKeepAlive(time.Minute),
+//		telnet.WithWriteTimeout(30*time.Second),
+//		telnet.WithTrace(trace),
+//	}
+//
+//	server := telnet.NewServer(":5555", append(options, telnet.WithHandler(handler))...)
+//
+//	dialer := telnet.NewDialer(options...)
+//
+// The same Options can be used for both; an Option that does not apply to one of them (such as
+// WithBanner, for a Dialer) is ignored by it. An Option just sets (one of) the fields of the
+// Server (or of the Dialer); so whatever can be configured with an Option can also be configured
+// by setting the field. (Later Options override earlier ones.)
+type Option func(config internalConfig)
+
+// internalConfig is what an Option configures; either the Server, or the Dialer, is nil.
+type internalConfig struct {
+	server *Server
+	dialer *Dialer
+}
+
+// NewServer returns a Server that listens on 'addr' (see Server.Addr); configured by 'options'.
+// For example:
+//
+//	server := telnet.NewServer(":5555",
+//		telnet.WithHandler(telnet.EchoHandler),
+//		telnet.WithBanner("Welcome!\r\n"),
+//	)
+//
+//	err := server.ListenAndServe()
+func NewServer(addr string, options ...Option) *Server {
+	server := &Server{Addr: addr}
+	for _, option := range options {
+		option(internalConfig{server: server})
+	}
+
+	return server
+}
+
+// NewDialer returns a Dialer configured by 'options'. For example:
+//
+//	dialer := telnet.NewDialer(
+//		telnet.WithDialTimeout(10*time.Second),
+//		telnet.WithTLSConfig(tlsConfig),
+//	)
+//
+//	conn, err := dialer.DialToContext(ctx, "example.net:992")
+func NewDialer(options ...Option) *Dialer {
+	dialer := &Dialer{}
+	for _, option := range options {
+		option(internalConfig{dialer: dialer})
+	}
+
+	return dialer
+}
+
+// WithLogger sets the logger of the Server (see Server.Logger), or of each Conn dialed (see
+// Dialer.Logger).
+func WithLogger(logger Logger) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.Logger = logger
+		}
+		if nil != config.dialer {
+			config.dialer.Logger = logger
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration; of the Server (see Server.TLSConfig, which is used by
+// ListenAndServeTLS, and ServeTLS), or of the Dialer (which then makes TELNETS connections; see
+// Dialer.TLSConfig).
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.TLSConfig = tlsConfig
+		}
+		if nil != config.dialer {
+			config.dialer.TLSConfig = tlsConfig
+		}
+	}
+}
+
+// WithNegotiationTimeout sets how long each connection waits for the peer to answer an option
+// negotiation it started. (See Conn.SetNegotiationTimeout.)
+func WithNegotiationTimeout(timeout time.Duration) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.NegotiationTimeout = timeout
+		}
+		if nil != config.dialer {
+			config.dialer.NegotiationTimeout = timeout
+		}
+	}
+}
+
+// WithWriteTimeout sets how long sending what is written to each connection can take. (See
+// Conn.SetWriteTimeout.)
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.WriteTimeout = timeout
+		}
+		if nil != config.dialer {
+			config.dialer.WriteTimeout = timeout
+		}
+	}
+}
+
+// WithSocketOptions sets the (operating system level) options of each (TCP) connection. (See
+// SocketOptions.)
+func WithSocketOptions(options SocketOptions) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.SocketOptions = options
+		}
+		if nil != config.dialer {
+			config.dialer.SocketOptions = options
+		}
+	}
+}
+
+// WithKeepAlive sets how long each (TCP) connection is idle before TCP keepalive probes are sent;
+// leaving the rest of the SocketOptions as they are. (See SocketOptions.KeepAlive.)
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.SocketOptions.KeepAlive = keepAlive
+		}
+		if nil != config.dialer {
+			config.dialer.SocketOptions.KeepAlive = keepAlive
+		}
+	}
+}
+
+// WithTrace sets the hooks that are called at each stage of each connection. (See Trace.)
+func WithTrace(trace *Trace) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.Trace = trace
+		}
+		if nil != config.dialer {
+			config.dialer.Trace = trace
+		}
+	}
+}
+
+// WithMetrics sets what is told of each connection opened, and closed. (See Metrics.)
+func WithMetrics(metrics Metrics) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.Metrics = metrics
+		}
+		if nil != config.dialer {
+			config.dialer.Metrics = metrics
+		}
+	}
+}
+
+// WithDialTimeout sets how long connecting can take. (See Dialer.Timeout.) (Only for a Dialer.)
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(config internalConfig) {
+		if nil != config.dialer {
+			config.dialer.Timeout = timeout
+		}
+	}
+}
+
+// WithProxy has each Conn dialed connect through 'proxy'. (See Dialer.Proxy.) (Only for a Dialer.)
+func WithProxy(proxy ContextDialer) Option {
+	return func(config internalConfig) {
+		if nil != config.dialer {
+			config.dialer.Proxy = proxy
+		}
+	}
+}
+
+// WithHandler sets the (old-style) Handler of the Server. (See Server.Handler.) (Only for a Server.)
+func WithHandler(handler Handler) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.Handler = handler
+		}
+	}
+}
+
+// WithContextHandler sets the ContextHandler of the Server. (See Server.ContextHandler.) (Only
+// for a Server.)
+func WithContextHandler(handler ContextHandler) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.ContextHandler = handler
+		}
+	}
+}
+
+// WithBanner sets what is sent to each client, before its connection is handed to the handler.
+// (See Server.Banner.) (Only for a Server.)
+func WithBanner(banner string) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.Banner = banner
+		}
+	}
+}
+
+// WithOptionPolicy sets how each connection answers a client that asks for, or offers, an option
+// that is not otherwise supported. (See Server.OptionPolicy.) (Only for a Server.)
+func WithOptionPolicy(policy OptionPolicy) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OptionPolicy = policy
+		}
+	}
+}
+
+// WithOptionHandler registers (an OptionHandler made by 'fn' for) the option 'option' on each
+// connection of the Server (see Server.OptionHandlers), or on each Conn dialed (see
+// Dialer.OptionHandlers). (Each use of it adds to the OptionHandlers; rather than replace them.)
+func WithOptionHandler(option byte, fn func() OptionHandler) Option {
+	return func(config internalConfig) {
+		var handlers *OptionHandlers
+		if nil != config.server {
+			handlers = &config.server.OptionHandlers
+		} else {
+			handlers = &config.dialer.OptionHandlers
+		}
+
+		if nil == *handlers {
+			*handlers = OptionHandlers{}
+		}
+		(*handlers)[option] = fn
+	}
+}
+
+// WithInputLimits sets the limits on what each client can send. (See Server.InputLimits.) (Only
+// for a Server.)
+func WithInputLimits(limits InputLimits) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.InputLimits = limits
+		}
+	}
+}
+
+// WithConnectionLimits sets how many connections are served at once. (See
+// Server.ConnectionLimits.) (Only for a Server.)
+func WithConnectionLimits(limits ConnectionLimits) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.ConnectionLimits = limits
+		}
+	}
+}
+
+// WithInputRateLimit sets how fast each connection is read from. (See Server.InputRateLimit.)
+// (Only for a Server.)
+func WithInputRateLimit(limit RateLimit) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.InputRateLimit = limit
+		}
+	}
+}
+
+// WithOnLimit sets what is called each time a connection hits one of the limits. (See
+// Server.OnLimit.) (Only for a Server.)
+func WithOnLimit(fn func(remoteAddr net.Addr, limit ServerLimit)) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OnLimit = fn
+		}
+	}
+}
+
+// WithOnConnect sets what is called for each connection, once it has been set up. (See
+// Server.OnConnect.) (Only for a Server.)
+func WithOnConnect(fn func(conn *Conn)) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OnConnect = fn
+		}
+	}
+}
+
+// WithOnNegotiationComplete sets what is called for each connection, once the option negotiations
+// started when it was set up have been settled. (See Server.OnNegotiationComplete.) (Only for a
+// Server.)
+func WithOnNegotiationComplete(fn func(conn *Conn)) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OnNegotiationComplete = fn
+		}
+	}
+}
+
+// WithOnDisconnect sets what is called after each connection has been closed. (See
+// Server.OnDisconnect.) (Only for a Server.)
+func WithOnDisconnect(fn func(conn *Conn, reason CloseReason, msg string)) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OnDisconnect = fn
+		}
+	}
+}
+
+// WithFallbackCharset sets the charset that each connection's data is transcoded from (and to),
+// while no charset has been negotiated. (See Server.FallbackCharset.) (Only for a Server.)
+func WithFallbackCharset(charset Charset) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.FallbackCharset = charset
+		}
+	}
+}
+
+// WithWriteCoalescing sets how long what is written to each connection can be held back for. (See
+// Server.WriteCoalescing.) (Only for a Server.)
+func WithWriteCoalescing(delay time.Duration) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.WriteCoalescing = delay
+		}
+	}
+}
+
+// WithOutputPolicy sets how what is written to each connection is buffered, and flushed; and what
+// is done once the client can not keep up. (See Server.OutputPolicy.) (Only for a Server.)
+func WithOutputPolicy(policy OutputPolicy) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.OutputPolicy = policy
+		}
+	}
+}
+
+// WithWorkerPool has the connections served by a (bounded) pool of goroutines. (See
+// Server.WorkerPool.) (Only for a Server.)
+func WithWorkerPool(pool WorkerPool) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.WorkerPool = &pool
+		}
+	}
+}
+
+// WithAuthHandler has each client log in, before the handler is run. (See Server.AuthHandler.)
+// (Only for a Server.)
+func WithAuthHandler(auth *AuthHandler) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.AuthHandler = auth
+		}
+	}
+}
+
+// WithNewlinePolicy sets what each connection does with the newlines in its data, while it is not
+// BINARY. (See Conn.SetNewlinePolicy.)
+func WithNewlinePolicy(policy NewlinePolicy) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.NewlinePolicy = policy
+		}
+		if nil != config.dialer {
+			config.dialer.NewlinePolicy = policy
+		}
+	}
+}
+
+// WithParseMode sets what reading from each connection does with what the peer sends that is not
+// TELNET. (See Conn.SetParseMode.)
+func WithParseMode(mode ParseMode) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.ParseMode = mode
+		}
+		if nil != config.dialer {
+			config.dialer.ParseMode = mode
+		}
+	}
+}
+
+// WithSessionTimeouts sets how long each connection can be idle for, and can last. (See
+// Server.SessionTimeouts.) (Only for a Server.)
+func WithSessionTimeouts(timeouts SessionTimeouts) Option {
+	return func(config internalConfig) {
+		if nil != config.server {
+			config.server.SessionTimeouts = timeouts
+		}
+	}
+}
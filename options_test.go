@@ -0,0 +1,398 @@
+package telnet
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testLogger is a Logger, that is only compared (by identity).
+type testLogger struct {
+	internalDiscardLogger
+	name string
+}
+
+// testMetrics keeps what it is told.
+type testMetrics struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (metrics *testMetrics) Opened(conn *Conn) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.events = append(metrics.events, "opened")
+}
+
+func (metrics *testMetrics) Closed(conn *Conn, reason CloseReason) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.events = append(metrics.events, "closed: "+reason.String())
+}
+
+func (metrics *testMetrics) Events() []string {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	return append([]string(nil), metrics.events...)
+}
+
+// testTrace returns a Trace, that records (the names of) the hooks called.
+func testTrace() (*Trace, func() []string) {
+	var mutex sync.Mutex
+	var events []string
+	record := func(event string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		events = append(events, event)
+	}
+
+	trace := &Trace{
+		DialStart: func(addr string) { record("dial start") },
+		DialDone:  func(addr string, err error) { record("dial done") },
+		Accepted:  func(remoteAddr net.Addr) { record("accepted") },
+		Connected: func(conn *Conn) { record("connected") },
+		Negotiation: func(conn *Conn, event NegotiationEvent) {
+			record(event.Direction.String() + " " + CommandName(event.Verb))
+		},
+		Closed: func(conn *Conn, reason CloseReason, msg string) { record("closed: " + reason.String()) },
+	}
+
+	return trace, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return append([]string(nil), events...)
+	}
+}
+
+func TestOptions(t *testing.T) {
+
+	logger := &testLogger{name: "options"}
+	tlsConfig := &tls.Config{ServerName: "example.net"}
+	trace := &Trace{}
+	metrics := &testMetrics{}
+	socketOptions := SocketOptions{KeepAlive: time.Minute, Nagle: true}
+	auth := &AuthHandler{Tries: 5}
+
+	tests := []struct {
+		Option Option
+		Server *Server
+		Dialer *Dialer
+	}{
+		{
+			Option: WithLogger(logger),
+			Server: &Server{Logger: logger},
+			Dialer: &Dialer{Logger: logger},
+		},
+		{
+			Option: WithTLSConfig(tlsConfig),
+			Server: &Server{TLSConfig: tlsConfig},
+			Dialer: &Dialer{TLSConfig: tlsConfig},
+		},
+		{
+			Option: WithNegotiationTimeout(3 * time.Second),
+			Server: &Server{NegotiationTimeout: 3 * time.Second},
+			Dialer: &Dialer{NegotiationTimeout: 3 * time.Second},
+		},
+		{
+			Option: WithWriteTimeout(4 * time.Second),
+			Server: &Server{WriteTimeout: 4 * time.Second},
+			Dialer: &Dialer{WriteTimeout: 4 * time.Second},
+		},
+		{
+			Option: WithSocketOptions(socketOptions),
+			Server: &Server{SocketOptions: socketOptions},
+			Dialer: &Dialer{SocketOptions: socketOptions},
+		},
+		{
+			Option: WithKeepAlive(2 * time.Minute),
+			Server: &Server{SocketOptions: SocketOptions{KeepAlive: 2 * time.Minute}},
+			Dialer: &Dialer{SocketOptions: SocketOptions{KeepAlive: 2 * time.Minute}},
+		},
+		{
+			Option: WithTrace(trace),
+			Server: &Server{Trace: trace},
+			Dialer: &Dialer{Trace: trace},
+		},
+		{
+			Option: WithMetrics(metrics),
+			Server: &Server{Metrics: metrics},
+			Dialer: &Dialer{Metrics: metrics},
+		},
+		{
+			Option: WithDialTimeout(5 * time.Second),
+			Dialer: &Dialer{Timeout: 5 * time.Second},
+		},
+		{
+			Option: WithHandler(EchoHandler),
+			Server: &Server{Handler: EchoHandler},
+		},
+		{
+			Option: WithContextHandler(internalEchoContextHandler{}),
+			Server: &Server{ContextHandler: internalEchoContextHandler{}},
+		},
+		{
+			Option: WithBanner("Welcome!\r\n"),
+			Server: &Server{Banner: "Welcome!\r\n"},
+		},
+		{
+			Option: WithOptionPolicy(OptionPolicyAccept),
+			Server: &Server{OptionPolicy: OptionPolicyAccept},
+		},
+		{
+			Option: WithInputLimits(DefaultInputLimits),
+			Server: &Server{InputLimits: DefaultInputLimits},
+		},
+		{
+			Option: WithConnectionLimits(ConnectionLimits{MaxConnections: 100, MaxConnectionsPerIP: 4}),
+			Server: &Server{ConnectionLimits: ConnectionLimits{MaxConnections: 100, MaxConnectionsPerIP: 4}},
+		},
+		{
+			Option: WithInputRateLimit(RateLimit{BytesPerSecond: 1024}),
+			Server: &Server{InputRateLimit: RateLimit{BytesPerSecond: 1024}},
+		},
+		{
+			Option: WithFallbackCharset(Latin1),
+			Server: &Server{FallbackCharset: Latin1},
+		},
+		{
+			Option: WithWriteCoalescing(2 * time.Millisecond),
+			Server: &Server{WriteCoalescing: 2 * time.Millisecond},
+		},
+		{
+			Option: WithOutputPolicy(OutputPolicy{BufferSize: 16 * 1024, HighWaterMark: 64 * 1024, NonBlocking: true}),
+			Server: &Server{OutputPolicy: OutputPolicy{BufferSize: 16 * 1024, HighWaterMark: 64 * 1024, NonBlocking: true}},
+		},
+		{
+			Option: WithWorkerPool(WorkerPool{Size: 3}),
+			Server: &Server{WorkerPool: &WorkerPool{Size: 3}},
+		},
+		{
+			Option: WithAuthHandler(auth),
+			Server: &Server{AuthHandler: auth},
+		},
+		{
+			Option: WithNewlinePolicy(NewlineNVT),
+			Server: &Server{NewlinePolicy: NewlineNVT},
+			Dialer: &Dialer{NewlinePolicy: NewlineNVT},
+		},
+		{
+			Option: WithParseMode(ParseLenient),
+			Server: &Server{ParseMode: ParseLenient},
+			Dialer: &Dialer{ParseMode: ParseLenient},
+		},
+		{
+			Option: WithSessionTimeouts(SessionTimeouts{IdleTimeout: time.Minute}),
+			Server: &Server{SessionTimeouts: SessionTimeouts{IdleTimeout: time.Minute}},
+		},
+	}
+
+	for testNumber, test := range tests {
+		// (A nil Server, or Dialer, is one that the Option does not apply to.)
+		expectedServer, expectedDialer := test.Server, test.Dialer
+		if nil == expectedServer {
+			expectedServer = &Server{}
+		}
+		if nil == expectedDialer {
+			expectedDialer = &Dialer{}
+		}
+		expectedServer.Addr = ":5555"
+
+		server := NewServer(":5555", test.Option)
+		if expected, actual := expectedServer, server; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected the Server to be %+v, but actually got %+v.", testNumber, expected, actual)
+		}
+
+		dialer := NewDialer(test.Option)
+		if expected, actual := expectedDialer, dialer; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("For test #%d, expected the Dialer to be %+v, but actually got %+v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestOptionsReachTheServedConn(t *testing.T) {
+
+	logger := &testLogger{name: "server"}
+	trace, traced := testTrace()
+	metrics := &testMetrics{}
+
+	type served struct {
+		Logger             Logger
+		NegotiationTimeout time.Duration
+		WriteTimeout       time.Duration
+	}
+	ch := make(chan served, 1)
+	disconnected := make(chan struct{})
+
+	server := NewServer("",
+		WithLogger(logger),
+		WithBanner("Welcome!\r\n"),
+		WithNegotiationTimeout(3*time.Second),
+		WithWriteTimeout(4*time.Second),
+		WithTrace(trace),
+		WithMetrics(metrics),
+		WithContextHandler(testContextHandler(func(ctx context.Context, conn *Conn) {
+			conn.negotiator.mutex.Lock()
+			negotiationTimeout := conn.negotiator.timeout
+			conn.negotiator.mutex.Unlock()
+
+			ch <- served{
+				Logger:             conn.Logger(),
+				NegotiationTimeout: negotiationTimeout,
+				WriteTimeout:       time.Duration(conn.dataWriter.timeout.timeout.Load()),
+			}
+
+			io.Copy(io.Discard, conn)
+		})),
+		WithOnDisconnect(func(conn *Conn, reason CloseReason, msg string) {
+			<-conn.Done()
+			close(disconnected)
+		}),
+	)
+
+	client := testServe(t, server)
+
+	p := make([]byte, len("Welcome!\r\n"))
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(client, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "Welcome!\r\n", string(p); expected != actual {
+		t.Errorf("Expected the banner %q, but actually got %q.", expected, actual)
+	}
+
+	// (Which the server refuses.)
+	client.Write([]byte{IAC, WILL, OptEcho})
+	testReadExactly(t, client, 3)
+
+	select {
+	case actual := <-ch:
+		if expected := (served{Logger: logger, NegotiationTimeout: 3 * time.Second, WriteTimeout: 4 * time.Second}); expected != actual {
+			t.Errorf("Expected the Conn to be served with %+v, but actually got %+v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the handler to be served, but actually it was not.")
+	}
+
+	client.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the connection to be closed, but actually it was not.")
+	}
+
+	if expected, actual := []string{"accepted", "connected", "inbound WILL", "outbound DONT", "closed: peer closed"}, traced(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the trace %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := []string{"opened", "closed: peer closed"}, metrics.Events(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the metrics %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestOptionsReachTheDialedConn(t *testing.T) {
+
+	listener := testListen(t, func(c net.Conn) {
+		c.Write([]byte{IAC, DO, OptEcho})
+		io.Copy(io.Discard, c)
+	})
+
+	logger := &testLogger{name: "dialer"}
+	trace, traced := testTrace()
+	metrics := &testMetrics{}
+
+	dialer := NewDialer(
+		WithLogger(logger),
+		WithNegotiationTimeout(3*time.Second),
+		WithWriteTimeout(4*time.Second),
+		WithTrace(trace),
+		WithMetrics(metrics),
+	)
+
+	conn, err := dialer.DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := Logger(logger), conn.Logger(); expected != actual {
+		t.Errorf("Expected the logger to be %v, but actually got %v.", expected, actual)
+	}
+	if expected, actual := 4*time.Second, time.Duration(conn.dataWriter.timeout.timeout.Load()); expected != actual {
+		t.Errorf("Expected the write timeout to be %v, but actually got %v.", expected, actual)
+	}
+	conn.negotiator.mutex.Lock()
+	if expected, actual := 3*time.Second, conn.negotiator.timeout; expected != actual {
+		t.Errorf("Expected the negotiation timeout to be %v, but actually got %v.", expected, actual)
+	}
+	conn.negotiator.mutex.Unlock()
+
+	// (So that the DO is received; and refused.)
+	go io.Copy(io.Discard, conn)
+	for deadline := time.Now().Add(3 * time.Second); len(traced()) < 5 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	conn.Close()
+	select {
+	case <-conn.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected the Conn to be torn down, but actually it was not.")
+	}
+
+	if expected, actual := []string{"dial start", "dial done", "connected", "inbound DO", "outbound WONT", "closed: unknown"}, traced(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the trace %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := []string{"opened", "closed: unknown"}, metrics.Events(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the metrics %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestDialToAndCallContextOptions(t *testing.T) {
+
+	listener := testListen(t, func(c net.Conn) {
+		c.Write([]byte("hello"))
+	})
+
+	logger := &testLogger{name: "caller"}
+
+	var actual Logger
+	caller := testContextCaller(func(ctx context.Context, conn *Conn) {
+		actual = conn.Logger()
+		io.Copy(io.Discard, conn)
+	})
+
+	if err := DialToAndCallContext(context.Background(), listener.Addr().String(), caller, WithLogger(logger)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected := Logger(logger); expected != actual {
+		t.Errorf("Expected the logger to be %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestNewServerServes(t *testing.T) {
+
+	server := NewServer("", WithHandler(EchoHandler))
+
+	client := testServe(t, server)
+	client.Write([]byte("hello\r\n"))
+
+	p := make([]byte, len("hello\r\n"))
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(client, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hello\r\n", string(p); !strings.EqualFold(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
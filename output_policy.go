@@ -0,0 +1,223 @@
+package telnet
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultWriteBufferSize is the size of the write buffer of a Conn, if it is not told otherwise.
+const defaultWriteBufferSize = 4096
+
+// ErrHighWaterMark is the error returned by a Conn's Write when (with a NonBlocking OutputPolicy)
+// there is already as much waiting to be sent as the HighWaterMark allows; as the peer is not
+// keeping up. (None of what was being written was.)
+var ErrHighWaterMark = errors.New("telnet: too much output waiting to be sent; the peer is not keeping up")
+
+// OutputPolicy is how what is written to a Conn is buffered, and flushed; and what a Write does
+// when the peer can not keep up with it. (See Conn.SetOutputPolicy.)
+//
+// The zero OutputPolicy is the default: a 4096 byte buffer, that is flushed once each Write is
+// done, with no limit on how much can be waiting to be sent.
+type OutputPolicy struct {
+	// BufferSize is the size of the write buffer (in bytes, escaped, as they go over the wire);
+	// 4096, if it is zero. Data is sent a buffer's worth at a time; with the TELNET commands that
+	// are waiting to be sent going out in between.
+	BufferSize int
+
+	// FlushInterval, and FlushThreshold, are the delay, and the threshold, of the write coalescing
+	// (see Conn.SetWriteCoalescing); i.e., what is written is sent once FlushInterval has passed
+	// since the first write that has not been sent yet, or once FlushThreshold bytes are buffered.
+	// A FlushInterval of zero flushes once each Write is done; and a negative one waits for Flush.
+	FlushInterval  time.Duration
+	FlushThreshold int
+
+	// HighWaterMark, if not zero, is how many bytes (escaped) can be waiting to be sent (by the
+	// Writes that have not yet returned) before a Write waits for (some of) them to be; so that a
+	// slow, or stuck, client does not have more and more piled up for it. (A Write that is bigger
+	// than the HighWaterMark goes ahead once nothing else is waiting.)
+	HighWaterMark int
+
+	// NonBlocking, if true, has Write return ErrHighWaterMark (without writing anything), instead
+	// of waiting, when the HighWaterMark has been reached. (Such as for a server broadcasting to
+	// many clients; which would rather skip a client that can not keep up than wait on it.)
+	NonBlocking bool
+}
+
+// SetOutputPolicy sets how what is written to the Conn is buffered and flushed; and what a Write
+// does when the peer can not keep up (see OutputPolicy). For example:
+//
+//	conn.SetOutputPolicy(telnet.OutputPolicy{
+//		BufferSize:    16 * 1024,
+//		FlushInterval: 5 * time.Millisecond,
+//		HighWaterMark: 64 * 1024,
+//		NonBlocking:   true,
+//	})
+//
+// A Write that waits on the HighWaterMark gives up at the write deadline (see SetWriteDeadline),
+// with a timeout error; or once the Conn is closed, with ErrClosed.
+//
+// It replaces what SetWriteCoalescing set; and sends what is buffered. The zero OutputPolicy puts
+// back the default. (See also Server.OutputPolicy.)
+func (clientConn *Conn) SetOutputPolicy(policy OutputPolicy) {
+	size := policy.BufferSize
+	if size <= 0 {
+		size = defaultWriteBufferSize
+	}
+
+	clientConn.dataWriter.setBufferSize(size)
+	clientConn.dataWriter.setCoalescing(policy.FlushInterval, policy.FlushThreshold)
+	clientConn.dataWriter.highWater.set(policy.HighWaterMark, policy.NonBlocking)
+}
+
+// setBufferSize sends what is buffered; and then has the buffer be 'size' (from now on).
+func (w *internalDataWriter) setBufferSize(size int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.wireMutex.Lock()
+	defer w.unlockWire()
+
+	w.coalescer.stop()
+	w.wrapped.Flush()
+	w.wrapped.size = size
+	if size < w.coalescer.threshold {
+		w.coalescer.threshold = size
+	}
+}
+
+// internalHighWater holds off a Write while there is as much waiting to be sent (see
+// internalDataWriter.pending) as the high-water mark allows.
+type internalHighWater struct {
+	mark        atomic.Int64
+	nonBlocking atomic.Bool
+
+	// queued is how many (escaped) bytes the Writes that were let in, but that are waiting for
+	// the Writes ahead of them (and so are not pending yet), are.
+	queued atomic.Int64
+
+	// drained is closed (and replaced) whenever what is waiting to be sent goes down, while there
+	// are 'waiters'; so that they can look again.
+	mutex   sync.Mutex
+	drained chan struct{}
+	waiters int
+}
+
+func (highWater *internalHighWater) set(mark int, nonBlocking bool) {
+	highWater.mark.Store(int64(mark))
+	highWater.nonBlocking.Store(nonBlocking)
+	highWater.wake()
+}
+
+// drain wakes up whatever is waiting; as what is waiting to be sent has gone down. (If there is no
+// high-water mark, then there is nothing waiting.)
+func (highWater *internalHighWater) drain() {
+	if highWater.mark.Load() <= 0 {
+		return
+	}
+	highWater.wake()
+}
+
+func (highWater *internalHighWater) wake() {
+	highWater.mutex.Lock()
+	defer highWater.mutex.Unlock()
+
+	if 0 < highWater.waiters && nil != highWater.drained {
+		close(highWater.drained)
+		highWater.drained = nil
+	}
+}
+
+// watch returns a channel that is closed once drain is called; with it counted as a waiter, until
+// 'done' is called.
+func (highWater *internalHighWater) watch() (drained <-chan struct{}, done func()) {
+	highWater.mutex.Lock()
+	defer highWater.mutex.Unlock()
+
+	if nil == highWater.drained {
+		highWater.drained = make(chan struct{})
+	}
+	highWater.waiters++
+
+	return highWater.drained, func() {
+		highWater.mutex.Lock()
+		highWater.waiters--
+		highWater.mutex.Unlock()
+	}
+}
+
+// unqueue is called once a Write (that admit let in) is no longer waiting for the Writes ahead of
+// it; with what admit returned.
+func (highWater *internalHighWater) unqueue(queued int64) {
+	if 0 < queued {
+		highWater.queued.Add(-queued)
+	}
+}
+
+// admit waits for there to be room for 'need' (escaped) bytes, under the high-water mark, in what
+// is waiting to be sent; unless it is non-blocking, in which case it returns ErrHighWaterMark. It
+// gives up at the write deadline (with os.ErrDeadlineExceeded), or once the Conn is closed (with
+// ErrClosed).
+//
+// Once it lets the Write in, the Write is counted as queued; until unqueue is called, with what
+// admit returned. (Which is zero, if there is no high-water mark.)
+func (w *internalDataWriter) admit(need int) (int64, error) {
+	highWater := &w.highWater
+
+	for {
+		mark := highWater.mark.Load()
+		if mark <= 0 {
+			return 0, nil
+		}
+
+		drained, done := highWater.watch()
+		waiting := w.pending.Load() + highWater.queued.Load()
+		if 0 == waiting || waiting+int64(need) <= mark {
+			done()
+			highWater.queued.Add(int64(need))
+			return int64(need), nil
+		}
+		if highWater.nonBlocking.Load() {
+			done()
+			return 0, ErrHighWaterMark
+		}
+
+		deadline, changed, closed := w.limiter.watch()
+		if closed {
+			done()
+			return 0, ErrClosed
+		}
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			pause := time.Until(deadline)
+			if pause <= 0 {
+				done()
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(pause)
+			expired = timer.C
+		}
+
+		select {
+		case <-drained:
+		case <-changed:
+		case <-expired:
+		}
+		if nil != timer {
+			timer.Stop()
+		}
+		done()
+	}
+}
+
+// watch returns the write deadline; and a channel that is closed once it (or the limit) changes,
+// or the Conn is closed. And whether the Conn has been closed.
+func (limiter *internalRateLimiter) watch() (deadline time.Time, changed <-chan struct{}, closed bool) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return limiter.deadline, limiter.changed, limiter.closed
+}
@@ -0,0 +1,126 @@
+package telnet
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"testing"
+)
+
+// testWaitForPendingOutput waits for (at least) 'n' bytes to be waiting to be sent, on 'conn'.
+func testWaitForPendingOutput(t *testing.T, conn *Conn, n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(3 * time.Second); conn.PendingOutput() < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d bytes to be pending, but actually there were %d.", n, conn.PendingOutput())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnOutputPolicyHighWaterMark(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOutputPolicy(OutputPolicy{HighWaterMark: 16, NonBlocking: true})
+
+	// (The peer is not reading; so this one is stuck, with 10 bytes pending.)
+	first := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("0123456789"))
+		first <- err
+	}()
+	testWaitForPendingOutput(t, conn, 10)
+
+	n, err := conn.Write([]byte("abcdefgh"))
+	if expected, actual := ErrHighWaterMark, err; expected != actual {
+		t.Errorf("Expected error %v, but actually got: (%T) %v", expected, actual, actual)
+	}
+	if expected, actual := 0, n; expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+
+	// (What fits under the high-water mark is not held off.)
+	second := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("xyz"))
+		second <- err
+	}()
+
+	if expected, actual := "0123456789xyz", string(testReadExactly(t, remote, 13)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-first; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := <-second; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
+
+func TestConnOutputPolicyHighWaterMarkDeadline(t *testing.T) {
+
+	conn, _ := testPipe(t)
+	conn.SetOutputPolicy(OutputPolicy{HighWaterMark: 16})
+
+	go conn.Write([]byte("0123456789"))
+	testWaitForPendingOutput(t, conn, 10)
+
+	// (It waits; until the write deadline.)
+	conn.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Write([]byte("abcdefgh")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected error %v, but actually got: (%T) %v", os.ErrDeadlineExceeded, err, err)
+	}
+}
+
+func TestConnOutputPolicyHighWaterMarkWaits(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOutputPolicy(OutputPolicy{HighWaterMark: 16})
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("0123456789"))
+		first <- err
+	}()
+	testWaitForPendingOutput(t, conn, 10)
+
+	// (This does not fit, until the first one has been sent.)
+	second := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("abcdefgh"))
+		second <- err
+	}()
+
+	if expected, actual := "0123456789abcdefgh", string(testReadExactly(t, remote, 18)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-first; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := <-second; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+}
+
+func TestConnOutputPolicyBufferSize(t *testing.T) {
+
+	conn, remote := testPipe(t)
+	conn.SetOutputPolicy(OutputPolicy{BufferSize: 8})
+
+	go conn.Write([]byte("0123456789abcdefghij"))
+
+	// (It is sent a buffer's worth at a time.)
+	for testNumber, expected := range []string{"01234567", "89abcdef", "ghij"} {
+		p := make([]byte, 64)
+		remote.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := remote.Read(p)
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if actual := string(p[:n]); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
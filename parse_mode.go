@@ -0,0 +1,101 @@
+package telnet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrProtocolViolation is what reading returns (wrapped around what the violation was), in the
+// ParseStrict mode, when the peer sent something that is not TELNET; such as an IAC followed by a
+// byte that is not a TELNET command. (See ParseMode.) For example:
+//
+//	telnet: protocol violation: IAC followed by 0x78
+var ErrProtocolViolation = errors.New("telnet: protocol violation")
+
+// ParseMode is what reading does with what the peer sends that is not TELNET (i.e., that violates
+// RFC 854, or RFC 855). (See Conn.SetParseMode.)
+//
+// The violations are: an IAC followed by a byte that is not a TELNET command; an IAC SE that is not
+// the end of a subnegotiation; an empty subnegotiation (IAC SB IAC SE); an IAC followed by a byte
+// other than IAC (or SE) in a subnegotiation; and a subnegotiation that is cut short by another
+// (i.e., an IAC SB in a subnegotiation, which is how a peer that never sent the IAC SE of the
+// first one looks).
+type ParseMode int
+
+const (
+	// ParseStrict has reading return ErrProtocolViolation (wrapped around what the violation was)
+	// for each violation; once it has read the data that came before it. What was in violation is
+	// thrown away; and the next Read goes on from after it (so whether to carry on is up to the
+	// caller). A subnegotiation that is cut short is thrown away; and (for an IAC other than IAC SB)
+	// the subnegotiation is still read to its IAC SE. (This is the default.)
+	ParseStrict ParseMode = iota
+
+	// ParseLenient recovers from each violation as best it can, without an error: an IAC followed by
+	// a byte that is not a TELNET command is taken to be data (i.e., a byte 255 that was not
+	// escaped; which is what a client that does not know it has to do sends), as is the byte after
+	// it; an IAC SE that ends no subnegotiation, and an empty subnegotiation, are ignored; an IAC
+	// followed by some other byte in a subnegotiation is thrown away (as is that byte); and a
+	// subnegotiation that is cut short is thrown away, with the one that cut it short read instead.
+	//
+	// This is for Internet-facing services; which get all kinds of garbage sent to them.
+	ParseLenient
+)
+
+// String returns the name of the ParseMode; such as "strict".
+func (mode ParseMode) String() string {
+	switch mode {
+	case ParseStrict:
+		return "strict"
+	case ParseLenient:
+		return "lenient"
+	default:
+		return "unknown"
+	}
+}
+
+// SetParseMode sets what reading from the Conn does with what the peer sends that is not TELNET.
+// (See ParseMode.) For example:
+//
+//	conn.SetParseMode(telnet.ParseLenient)
+//
+// (A Server (or Dialer) sets it to its ParseMode.)
+func (clientConn *Conn) SetParseMode(mode ParseMode) {
+	clientConn.dataReader.lenient.Store(ParseLenient == mode)
+}
+
+// ParseMode returns what reading from the Conn does with what the peer sends that is not TELNET.
+// (See SetParseMode.)
+func (clientConn *Conn) ParseMode() ParseMode {
+	return clientConn.dataReader.parseMode()
+}
+
+// SetParseMode sets what reading does with what is not TELNET. (See ParseMode.)
+func (reader *DataReader) SetParseMode(mode ParseMode) {
+	reader.reader.lenient.Store(ParseLenient == mode)
+}
+
+func (r *internalDataReader) parseMode() ParseMode {
+	if r.lenient.Load() {
+		return ParseLenient
+	}
+	return ParseStrict
+}
+
+// internalProtocolError is ErrProtocolViolation; wrapped around what the violation was.
+type internalProtocolError struct {
+	violation string
+}
+
+// protocolErr returns ErrProtocolViolation; wrapped around the violation that 'format' (and 'a')
+// says.
+func protocolErr(format string, a ...interface{}) error {
+	return internalProtocolError{violation: fmt.Sprintf(format, a...)}
+}
+
+func (err internalProtocolError) Error() string {
+	return ErrProtocolViolation.Error() + ": " + err.violation
+}
+
+func (err internalProtocolError) Is(target error) bool {
+	return ErrProtocolViolation == target
+}
@@ -0,0 +1,240 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"testing"
+)
+
+// testParse reads all of 'input' with a DataReader, in 'mode'; and returns the data, the
+// subnegotiations, and the errors (other than io.EOF). It fails the test if reading does not get
+// to the end.
+func testParse(t *testing.T, mode ParseMode, input []byte, size int) (string, []string, []error) {
+	t.Helper()
+
+	reader := NewDataReader(bytes.NewReader(input))
+	reader.SetParseMode(mode)
+
+	var subnegotiations []string
+	reader.OnSubnegotiation(func(option byte, payload []byte) {
+		subnegotiations = append(subnegotiations, fmt.Sprintf("%d:%q", option, payload))
+	})
+
+	var data []byte
+	var errs []error
+	p := make([]byte, size)
+	for reads := 0; ; reads++ {
+		if 2*len(input)+8 < reads {
+			t.Fatalf("Expected reading %q to get to the end, but actually it did not.", input)
+		}
+
+		n, err := reader.Read(p)
+		data = append(data, p[:n]...)
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			errs = append(errs, err)
+			if ErrTruncatedCommand == err {
+				break
+			}
+		}
+	}
+
+	return string(data), subnegotiations, errs
+}
+
+func TestParseMode(t *testing.T) {
+
+	tests := []struct {
+		Input    string
+		Mode     ParseMode
+		Expected string
+
+		ExpectedSubnegotiations []string
+		ExpectedErrs            []error
+	}{
+		{
+			// (An IAC followed by what is not a TELNET command.)
+			Input:        "a\xffxb",
+			Mode:         ParseStrict,
+			Expected:     "ab",
+			ExpectedErrs: []error{ErrProtocolViolation},
+		},
+		{
+			Input:    "a\xffxb",
+			Mode:     ParseLenient,
+			Expected: "a\xffxb",
+		},
+		{
+			// (An IAC SE outside of a subnegotiation.)
+			Input:        "a\xff\xf0b",
+			Mode:         ParseStrict,
+			Expected:     "ab",
+			ExpectedErrs: []error{ErrProtocolViolation},
+		},
+		{
+			Input:    "a\xff\xf0b",
+			Mode:     ParseLenient,
+			Expected: "ab",
+		},
+		{
+			// (An empty subnegotiation.)
+			Input:        "\xff\xfa\xff\xf0x",
+			Mode:         ParseStrict,
+			Expected:     "x",
+			ExpectedErrs: []error{ErrProtocolViolation},
+		},
+		{
+			Input:    "\xff\xfa\xff\xf0x",
+			Mode:     ParseLenient,
+			Expected: "x",
+		},
+		{
+			// (An IAC followed by something other than IAC, or SE, in a subnegotiation.)
+			Input:                   "\xff\xfa\x18\x01\xff\x01\x02\xff\xf0z",
+			Mode:                    ParseStrict,
+			Expected:                "z",
+			ExpectedSubnegotiations: []string{`24:"\x01\x02"`},
+			ExpectedErrs:            []error{ErrProtocolViolation},
+		},
+		{
+			Input:                   "\xff\xfa\x18\x01\xff\x01\x02\xff\xf0z",
+			Mode:                    ParseLenient,
+			Expected:                "z",
+			ExpectedSubnegotiations: []string{`24:"\x01\x02"`},
+		},
+		{
+			// (A subnegotiation cut short by another.)
+			Input:                   "\xff\xfa\x18ab\xff\xfa\x1f\x00\x50\xff\xf0c",
+			Mode:                    ParseStrict,
+			Expected:                "c",
+			ExpectedSubnegotiations: []string{`31:"\x00P"`},
+			ExpectedErrs:            []error{ErrProtocolViolation},
+		},
+		{
+			Input:                   "\xff\xfa\x18ab\xff\xfa\x1f\x00\x50\xff\xf0c",
+			Mode:                    ParseLenient,
+			Expected:                "c",
+			ExpectedSubnegotiations: []string{`31:"\x00P"`},
+		},
+		{
+			// (A subnegotiation that the connection closed part way through.)
+			Input:        "a\xff\xfa\x18ab",
+			Mode:         ParseStrict,
+			Expected:     "a",
+			ExpectedErrs: []error{ErrTruncatedCommand},
+		},
+		{
+			Input:        "a\xff\xfa\x18ab",
+			Mode:         ParseLenient,
+			Expected:     "a",
+			ExpectedErrs: []error{ErrTruncatedCommand},
+		},
+		{
+			// (What is TELNET is read the same, either way.)
+			Input:                   "a\xff\xff\xff\xf1b\xff\xfb\x01\xff\xfa\x18\x00\xff\xff\xff\xf0c",
+			Mode:                    ParseStrict,
+			Expected:                "a\xffbc",
+			ExpectedSubnegotiations: []string{`24:"\x00\xff"`},
+		},
+	}
+
+	for testNumber, test := range tests {
+		for _, size := range []int{1, 3, 64} {
+			data, subnegotiations, errs := testParse(t, test.Mode, []byte(test.Input), size)
+
+			if expected, actual := test.Expected, data; expected != actual {
+				t.Errorf("For test #%d (%v, reading %d at a time), expected %q, but actually got %q.", testNumber, test.Mode, size, expected, actual)
+			}
+			if expected, actual := test.ExpectedSubnegotiations, subnegotiations; !testEqualStrings(expected, actual) {
+				t.Errorf("For test #%d (%v, reading %d at a time), expected subnegotiations %q, but actually got %q.", testNumber, test.Mode, size, expected, actual)
+			}
+			if expected, actual := len(test.ExpectedErrs), len(errs); expected != actual {
+				t.Errorf("For test #%d (%v, reading %d at a time), expected %d errors, but actually got %d: %v", testNumber, test.Mode, size, expected, actual, errs)
+				continue
+			}
+			for i, err := range errs {
+				if !errors.Is(err, test.ExpectedErrs[i]) {
+					t.Errorf("For test #%d (%v, reading %d at a time), expected error %v, but actually got: (%T) %v", testNumber, test.Mode, size, test.ExpectedErrs[i], err, err)
+				}
+			}
+		}
+	}
+}
+
+func TestConnSetParseMode(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	if expected, actual := ParseStrict, conn.ParseMode(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	conn.SetParseMode(ParseLenient)
+	if expected, actual := ParseLenient, conn.ParseMode(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	// (An unescaped byte 255; such as from a client sending Latin-1.)
+	send('\xff', 'a')
+	if p := testReadExactly(t, conn, 2); "\xffa" != string(p) {
+		t.Errorf("Expected %q, but actually got %q.", "\xffa", p)
+	}
+}
+
+// FuzzDataReader reads garbage; which (in either mode) must not panic, must get to the end, and must
+// only fail with the errors that ParseMode says.
+func FuzzDataReader(f *testing.F) {
+	f.Add([]byte("a\xffxb"))
+	f.Add([]byte("a\xff\xf0b\xff"))
+	f.Add([]byte("\xff\xfa\xff\xf0x\xff\xfa"))
+	f.Add([]byte("\xff\xfa\x18\x01\xff\x01\x02\xff\xf0z"))
+	f.Add([]byte("\xff\xfa\x18ab\xff\xfa\x1f\x00\x50\xff\xf0c"))
+	f.Add([]byte("\xff\xfb\xff\xfd\xff\xff\xff\xf1\xff\xec"))
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		for _, mode := range []ParseMode{ParseStrict, ParseLenient} {
+			for _, size := range []int{1, 7} {
+				data, _, errs := testParse(t, mode, input, size)
+
+				if len(input) < len(data) {
+					t.Errorf("Expected (%v) no more than %d bytes of data, but actually got %d.", mode, len(input), len(data))
+				}
+				for _, err := range errs {
+					if ErrTruncatedCommand == err || (ParseStrict == mode && errors.Is(err, ErrProtocolViolation)) {
+						continue
+					}
+					t.Errorf("Did not expect (%v) the error: (%T) %v", mode, err, err)
+				}
+			}
+		}
+	})
+}
+
+// FuzzDataReaderRoundTrip has what a DataWriter escaped be read back (by a DataReader) as it was.
+func FuzzDataReaderRoundTrip(f *testing.F) {
+	f.Add([]byte("hello"))
+	f.Add([]byte("\xff\xff\xfa\xf0\xff"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var buffer bytes.Buffer
+		writer := NewDataWriter(&buffer)
+		if _, err := writer.Write(data); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		if err := writer.Flush(); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		actual, _, errs := testParse(t, ParseStrict, buffer.Bytes(), 5)
+		if 0 < len(errs) {
+			t.Errorf("Did not expect any errors, but actually got: %v", errs)
+		}
+		if expected := string(data); expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package telnet
+
+import (
+	"bufio"
+)
+
+// maxPeek is the most (data) bytes Peek can return.
+const maxPeek = 4096
+
+// Peek returns (up to) the next 'n' bytes of (TELNET) data, without reading them; i.e., what the
+// next Read would return. This is for looking at what the peer sends first (such as to tell
+// whether it is a person, or a program), before deciding what to do with it. For example:
+//
+//	p, err := conn.Peek(4)
+//	if nil == err && "\x00BIN" == string(p) {
+//		//@TODO: Hand the connection off to the binary protocol.
+//	}
+//
+// Like Read, Peek un-escapes the data, and handles the TELNET commands (and option negotiations)
+// that come in while it is peeking; which are never returned.
+//
+// Peek blocks (like the Peek of a bufio.Reader does) until there are 'n' bytes of data, or
+// reading fails; and if it returns fewer than 'n' bytes, then it also returns why. If 'n' is
+// more than 4096, then Peek returns (up to) 4096 bytes, and bufio.ErrBufferFull.
+//
+// The bytes returned are only valid until the next Read (or Peek).
+//
+// (A prompt marker that Peek goes over is not seen by ReadUntilPrompt; which returns what was
+// peeked at, along with what comes after it, up to the next prompt.)
+func (clientConn *Conn) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	limit := n
+	if maxPeek < limit {
+		limit = maxPeek
+	}
+
+	if err := clientConn.fill(limit); nil != err {
+		return clientConn.peeked, err
+	}
+
+	if limit < n {
+		return clientConn.peeked[:limit], bufio.ErrBufferFull
+	}
+	return clientConn.peeked[:n], nil
+}
+
+// Buffered returns how many bytes of (TELNET) data can be read (or peeked at) right now; i.e.,
+// without waiting for the peer to send anything more.
+//
+// (That does not count data that is still behind a TELNET command that has not all come in yet.)
+func (clientConn *Conn) Buffered() int {
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	return clientConn.buffered()
+}
+
+// buffered returns how many bytes of data can be read right now. (The readMutex must be held.)
+//
+// (With transcoding, the data that is still buffered might be more bytes once it is transcoded;
+// so this is how many bytes, at least.)
+func (clientConn *Conn) buffered() int {
+	return len(clientConn.peeked) + len(clientConn.transcoded) + clientConn.dataReader.bufferedData()
+}
+
+// fill reads (into what has been peeked at) until there are (at least) 'n' bytes of data peeked
+// at; or reading fails. (The readMutex must be held.)
+func (clientConn *Conn) fill(n int) error {
+
+	// (So that Read returns the data before a TELNET command, without first waiting for all of
+	// the command to come in; as it might not need to be waited for.)
+	reader := clientConn.dataReader
+	keepOrder := reader.keepOrder
+	reader.keepOrder = true
+	defer func() {
+		reader.keepOrder = keepOrder
+	}()
+
+	var buffer [512]byte
+	for len(clientConn.peeked) < n {
+		m, err := clientConn.readData(buffer[:])
+		clientConn.peeked = append(clientConn.peeked, buffer[:m]...)
+		if nil != err {
+			if n <= len(clientConn.peeked) {
+				break
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPeeked reads (into 'p') what Peek has already read, if anything.
+func (clientConn *Conn) readPeeked(p []byte) int {
+	n := copy(p, clientConn.peeked)
+	clientConn.peeked = clientConn.peeked[n:]
+	if len(clientConn.peeked) <= 0 {
+		clientConn.peeked = nil
+	}
+
+	return n
+}
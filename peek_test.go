@@ -0,0 +1,184 @@
+package telnet
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestConnPeek(t *testing.T) {
+
+	tests := []struct {
+		Send             []byte
+		Peek             int
+		Expected         string
+		ExpectedCommands []byte
+	}{
+		{
+			Send:     []byte("hello world"),
+			Peek:     5,
+			Expected: "hello",
+		},
+		{
+			Send:     []byte{'a', IAC, IAC, 'b', IAC, IAC},
+			Peek:     4,
+			Expected: "a\xffb\xff",
+		},
+		{
+			// (The commands are handled, while peeking; but never returned.)
+			Send:             []byte{IAC, NOP, 'a', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, IAC, IAC, IAC, AYT, 'b'},
+			Peek:             3,
+			Expected:         "a\xffb",
+			ExpectedCommands: []byte{NOP, AYT},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+
+		var mutex sync.Mutex
+		var commands []byte
+		conn.OnCommand(func(cmd byte) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			commands = append(commands, cmd)
+		})
+
+		// (Sent a byte at a time; so that Peek has to wait for all of it.)
+		for _, b := range test.Send {
+			send(b)
+		}
+
+		peeked, err := conn.Peek(test.Peek)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := test.Expected, string(peeked); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		// Peeking again returns the same thing.
+		if again, err := conn.Peek(test.Peek); nil != err || string(peeked) != string(again) {
+			t.Errorf("For test #%d, expected %q again, but actually got: %q, (%T) %v", testNumber, peeked, again, err, err)
+		}
+
+		if expected, actual := len(test.Expected), conn.Buffered(); actual < expected {
+			t.Errorf("For test #%d, expected at least %d byte(s) to be buffered, but actually got %d.", testNumber, expected, actual)
+		}
+
+		// What Read returns is what was peeked at.
+		read := make([]byte, test.Peek)
+		if _, err := io.ReadFull(conn, read); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := test.Expected, string(read); expected != actual {
+			t.Errorf("For test #%d, expected Read to return %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		mutex.Lock()
+		if expected, actual := string(test.ExpectedCommands), string(commands); expected != actual {
+			t.Errorf("For test #%d, expected commands %v, but actually got %v.", testNumber, []byte(expected), []byte(actual))
+		}
+		mutex.Unlock()
+	}
+}
+
+func TestConnPeekErrors(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+
+	if _, err := conn.Peek(-1); bufio.ErrNegativeCount != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", bufio.ErrNegativeCount, err, err)
+	}
+
+	big := make([]byte, maxPeek+10)
+	for i := range big {
+		big[i] = 'x'
+	}
+	send(big...)
+
+	peeked, err := conn.Peek(maxPeek + 1)
+	if bufio.ErrBufferFull != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", bufio.ErrBufferFull, err, err)
+	}
+	if expected, actual := maxPeek, len(peeked); expected != actual {
+		t.Errorf("Expected %d byte(s), but actually got %d.", expected, actual)
+	}
+
+	// Fewer bytes than asked for come with why.
+	local, remote := net.Pipe()
+	conn = newConn(local, nil)
+	go func() {
+		remote.Write([]byte("ab"))
+		remote.Close()
+	}()
+
+	peeked, err = conn.Peek(3)
+	if io.EOF != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", io.EOF, err, err)
+	}
+	if expected, actual := "ab", string(peeked); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	local.Close()
+}
+
+func TestConnBuffered(t *testing.T) {
+
+	tests := []struct {
+		Send     []byte
+		Expected int
+	}{
+		{
+			Send:     []byte("hello"),
+			Expected: 5,
+		},
+		{
+			Send:     []byte{'a', IAC, IAC, 'b', IAC, NOP, 'c'},
+			Expected: 4,
+		},
+		{
+			Send:     []byte{'a', IAC, SB, OptNAWS, 0, 80, IAC, IAC, 24, IAC, SE, 'b'},
+			Expected: 2,
+		},
+		{
+			// (It stops at a command that has not all come in yet.)
+			Send:     []byte{'a', 'b', IAC, SB, OptNAWS, 0, 80, 'n', 'o', 't'},
+			Expected: 2,
+		},
+		{
+			Send:     []byte{'a', IAC, WILL},
+			Expected: 1,
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+
+		send(test.Send...)
+
+		// Peek(1) has the first of it read in (and buffered).
+		if _, err := conn.Peek(1); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		// (Give the rest of it time to be buffered too.)
+		var actual int
+		for begin := time.Now(); time.Since(begin) < time.Second; time.Sleep(10 * time.Millisecond) {
+			if actual = conn.Buffered(); test.Expected <= actual {
+				break
+			}
+		}
+
+		if expected := test.Expected; expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be buffered, but actually got %d.", testNumber, expected, actual)
+		}
+	}
+}
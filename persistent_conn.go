@@ -0,0 +1,411 @@
+package telnet
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrReconnecting is returned by the Read (and Write) of a PersistentConn, with the
+	// ReconnectFail policy, while it is reconnecting.
+	ErrReconnecting = errors.New("telnet: reconnecting")
+
+	// ErrGaveUp is returned by the Read (and Write) of a PersistentConn, once it has given up
+	// reconnecting. (See PersistentConn.MaxAttempts.)
+	ErrGaveUp = errors.New("telnet: gave up reconnecting")
+
+	errPersistentNotConnected = errors.New("telnet: persistent connection not connected yet")
+)
+
+// ReconnectPolicy is what the Read (and Write) of a PersistentConn do while it is reconnecting.
+type ReconnectPolicy int
+
+const (
+	// ReconnectBlock has Read (and Write) wait until the connection is back. This is the default.
+	ReconnectBlock ReconnectPolicy = iota
+
+	// ReconnectFail has Read (and Write) return ErrReconnecting right away.
+	ReconnectFail
+)
+
+// PersistentState is the state of a PersistentConn.
+type PersistentState int
+
+const (
+	PersistentDisconnected PersistentState = iota // Not connected yet; or closed.
+	PersistentConnected
+	PersistentReconnecting
+	PersistentFailed // Gave up reconnecting.
+)
+
+// String returns the name of the state. (Ex: "reconnecting".)
+func (state PersistentState) String() string {
+	switch state {
+	case PersistentDisconnected:
+		return "disconnected"
+	case PersistentConnected:
+		return "connected"
+	case PersistentReconnecting:
+		return "reconnecting"
+	case PersistentFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// PersistentConn is a (client) TELNET connection that re-dials (with backoff) whenever the
+// connection dies; such as because the device at the other end rebooted. This is for things such
+// as monitoring agents, that keep a session open to a device for days. For example:
+//
+//	conn := &telnet.PersistentConn{
+//		Addr: "10.0.0.7:23",
+//		OnReconnect: func(conn *telnet.Conn) error {
+//			//@TODO: Log in again.
+//			return nil
+//		},
+//		OnStateChange: func(state telnet.PersistentState, err error) {
+//			log.Printf("%v: %v", state, err)
+//		},
+//		MaxAttempts: 20,
+//	}
+//
+//	if err := conn.Connect(ctx); nil != err {
+//		return err
+//	}
+//	defer conn.Close()
+//
+// A connection is taken to be dead once reading from it (or writing to it) fails; other than by
+// a timeout (such as from SetReadDeadline). (So, to notice a dead peer even when nothing is
+// being written, use TCP keepalives; see Dialer.SocketOptions.)
+type PersistentConn struct {
+	// Addr is the address to dial. (It is not used if Dial is set.)
+	Addr string
+
+	// Dialer is what Addr is dialed with.
+	Dialer Dialer
+
+	// TLSConfig, if not nil, has Addr dialed with TELNETS (i.e., TELNET over TLS).
+	TLSConfig *tls.Config
+
+	// Dial (if not nil) is used to make the connection; instead of dialing Addr.
+	Dial func(ctx context.Context) (*Conn, error)
+
+	// OnReconnect (if not nil) is called after each time the connection is made (including the
+	// first time); before it is used for anything else. It is for logging in (again), and for
+	// restoring any state. If it returns an error, then the connection is closed; and (unless
+	// that was for Connect) dialed again.
+	OnReconnect func(conn *Conn) error
+
+	// OnStateChange (if not nil) is called each time the state changes; with the error that
+	// caused the change, if there was one.
+	OnStateChange func(state PersistentState, err error)
+
+	// Policy is what Read (and Write) do while reconnecting.
+	Policy ReconnectPolicy
+
+	// MinBackoff and MaxBackoff are how long to wait before the first re-dial (100ms if zero),
+	// and the most to wait between re-dials (30s if zero). The wait doubles after each failure.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// MaxAttempts (if not zero) is how many times in a row re-dialing can fail, before giving up.
+	MaxAttempts int
+
+	mutex      sync.Mutex
+	changed    *sync.Cond // Broadcast each time the state changes.
+	conn       *Conn
+	generation int // Which connection 'conn' is; so that the same death is only reacted to once.
+	state      PersistentState
+	err        error
+	closed     bool
+	cancel     context.CancelFunc
+	ctx        context.Context
+}
+
+// Connect makes the connection (and calls OnReconnect); and returns an error if it could not be
+// made. (It is not retried; that is only done once the connection is made, and then dies.)
+func (persistent *PersistentConn) Connect(ctx context.Context) error {
+
+	persistent.mutex.Lock()
+	persistent.init()
+	if persistent.closed {
+		persistent.mutex.Unlock()
+		return net.ErrClosed
+	}
+	persistent.mutex.Unlock()
+
+	conn, err := persistent.dial(ctx)
+	if nil != err {
+		return err
+	}
+
+	persistent.connected(conn)
+	return nil
+}
+
+// init sets up what the zero PersistentConn does not have. (The mutex must be held.)
+func (persistent *PersistentConn) init() {
+	if nil == persistent.changed {
+		persistent.changed = sync.NewCond(&persistent.mutex)
+		persistent.ctx, persistent.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// dial makes the connection; and calls OnReconnect.
+func (persistent *PersistentConn) dial(ctx context.Context) (*Conn, error) {
+	var conn *Conn
+	var err error
+
+	switch {
+	case nil != persistent.Dial:
+		conn, err = persistent.Dial(ctx)
+	case nil != persistent.TLSConfig:
+		conn, err = persistent.Dialer.DialToTLSContext(ctx, persistent.Addr, persistent.TLSConfig)
+	default:
+		conn, err = persistent.Dialer.DialToContext(ctx, persistent.Addr)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	if fn := persistent.OnReconnect; nil != fn {
+		if err := fn(conn); nil != err {
+			conn.Close()
+			return nil, fmt.Errorf("telnet: re-establishing the session: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// connected makes 'conn' the connection.
+func (persistent *PersistentConn) connected(conn *Conn) {
+	persistent.mutex.Lock()
+	if persistent.closed {
+		persistent.mutex.Unlock()
+		conn.Close()
+		return
+	}
+
+	persistent.conn = conn
+	persistent.generation++
+	persistent.state = PersistentConnected
+	persistent.err = nil
+	persistent.changed.Broadcast()
+	persistent.mutex.Unlock()
+
+	persistent.notify(PersistentConnected, nil)
+}
+
+// notify calls OnStateChange.
+func (persistent *PersistentConn) notify(state PersistentState, err error) {
+	if fn := persistent.OnStateChange; nil != fn {
+		fn(state, err)
+	}
+}
+
+// current returns the connection (and which one it is); waiting while reconnecting, if the
+// policy says to.
+func (persistent *PersistentConn) current() (*Conn, int, error) {
+	persistent.mutex.Lock()
+	defer persistent.mutex.Unlock()
+
+	for {
+		switch {
+		case persistent.closed:
+			return nil, 0, net.ErrClosed
+		case nil == persistent.changed:
+			return nil, 0, errPersistentNotConnected
+		}
+
+		switch persistent.state {
+		case PersistentConnected:
+			return persistent.conn, persistent.generation, nil
+		case PersistentFailed:
+			return nil, 0, fmt.Errorf("%w: %v", ErrGaveUp, persistent.err)
+		case PersistentReconnecting:
+			if ReconnectFail == persistent.Policy {
+				return nil, 0, ErrReconnecting
+			}
+			persistent.changed.Wait()
+		default:
+			return nil, 0, errPersistentNotConnected
+		}
+	}
+}
+
+// died is called once using the connection (that is the 'generation'th one) failed with 'err';
+// and (unless that was a timeout) starts reconnecting. It returns whether it did (or something
+// else already did).
+func (persistent *PersistentConn) died(generation int, err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return false
+	}
+
+	persistent.mutex.Lock()
+	if persistent.closed {
+		persistent.mutex.Unlock()
+		return false
+	}
+	if generation != persistent.generation || PersistentConnected != persistent.state {
+		persistent.mutex.Unlock()
+		return true
+	}
+
+	persistent.state = PersistentReconnecting
+	persistent.err = err
+	persistent.conn.Close()
+	persistent.changed.Broadcast()
+	persistent.mutex.Unlock()
+
+	persistent.notify(PersistentReconnecting, err)
+	go persistent.reconnect()
+
+	return true
+}
+
+// reconnect re-dials (with backoff) until it succeeds; or gives up, or the PersistentConn is closed.
+func (persistent *PersistentConn) reconnect() {
+
+	backoff := persistent.MinBackoff
+	if backoff <= 0 {
+		backoff = defaultMinBackoff
+	}
+	maxBackoff := persistent.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	ctx := persistent.ctx
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		conn, err := persistent.dial(ctx)
+		if nil == err {
+			persistent.connected(conn)
+			return
+		}
+
+		if 0 < persistent.MaxAttempts && persistent.MaxAttempts <= attempt {
+			persistent.mutex.Lock()
+			if persistent.closed {
+				persistent.mutex.Unlock()
+				return
+			}
+			persistent.state = PersistentFailed
+			persistent.err = err
+			persistent.changed.Broadcast()
+			persistent.mutex.Unlock()
+
+			persistent.notify(PersistentFailed, err)
+			return
+		}
+
+		backoff *= 2
+		if maxBackoff < backoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Read reads (TELNET) data from the connection; re-dialing (and then reading from the new
+// connection) if it dies. (What was read from the dead connection is returned first.)
+func (persistent *PersistentConn) Read(p []byte) (int, error) {
+	for {
+		conn, generation, err := persistent.current()
+		if nil != err {
+			return 0, err
+		}
+
+		n, err := conn.Read(p)
+		if nil == err || 0 < n {
+			if nil != err {
+				persistent.died(generation, err)
+			}
+			return n, nil
+		}
+
+		if !persistent.died(generation, err) {
+			return n, err
+		}
+	}
+}
+
+// Write writes (TELNET) data to the connection; re-dialing (and then writing to the new
+// connection) if it dies. (If the connection dies part way through, then only the rest of 'p'
+// is written to the new connection.)
+func (persistent *PersistentConn) Write(p []byte) (int, error) {
+	var n int
+	for {
+		conn, generation, err := persistent.current()
+		if nil != err {
+			return n, err
+		}
+
+		m, err := conn.Write(p[n:])
+		n += m
+		if nil == err {
+			return n, nil
+		}
+
+		if !persistent.died(generation, err) {
+			return n, err
+		}
+	}
+}
+
+// Conn returns the connection (right now); or nil if there is not one.
+func (persistent *PersistentConn) Conn() *Conn {
+	persistent.mutex.Lock()
+	defer persistent.mutex.Unlock()
+
+	if PersistentConnected != persistent.state {
+		return nil
+	}
+	return persistent.conn
+}
+
+// State returns the state (right now).
+func (persistent *PersistentConn) State() PersistentState {
+	persistent.mutex.Lock()
+	defer persistent.mutex.Unlock()
+
+	return persistent.state
+}
+
+// Close closes the connection; and stops any reconnecting. (Any Read, or Write, that is waiting
+// for the connection to come back returns net.ErrClosed.)
+func (persistent *PersistentConn) Close() error {
+	persistent.mutex.Lock()
+	defer persistent.mutex.Unlock()
+
+	if persistent.closed {
+		return nil
+	}
+	persistent.init()
+
+	persistent.closed = true
+	persistent.state = PersistentDisconnected
+	persistent.cancel()
+	persistent.changed.Broadcast()
+
+	if nil != persistent.conn {
+		return persistent.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testPersistentServer accepts connections (on 'accepted'); for a PersistentConn to dial.
+type testPersistentServer struct {
+	listener net.Listener
+	accepted chan net.Conn
+}
+
+func testNewPersistentServer(t *testing.T) *testPersistentServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	server := testPersistentServer{listener: listener, accepted: make(chan net.Conn, 8)}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			t.Cleanup(func() {
+				c.Close()
+			})
+			server.accepted <- c
+		}
+	}()
+
+	return &server
+}
+
+func (server *testPersistentServer) accept(t *testing.T) net.Conn {
+	t.Helper()
+
+	select {
+	case c := <-server.accepted:
+		return c
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for a connection.")
+		return nil
+	}
+}
+
+// testStates records the state changes of a PersistentConn.
+type testStates struct {
+	mutex  sync.Mutex
+	states []PersistentState
+}
+
+func (states *testStates) record(state PersistentState, err error) {
+	states.mutex.Lock()
+	defer states.mutex.Unlock()
+
+	states.states = append(states.states, state)
+}
+
+func (states *testStates) get() []PersistentState {
+	states.mutex.Lock()
+	defer states.mutex.Unlock()
+
+	return append([]PersistentState(nil), states.states...)
+}
+
+func TestPersistentConnReconnect(t *testing.T) {
+
+	server := testNewPersistentServer(t)
+
+	var states testStates
+	logins := make(chan struct{}, 8)
+	persistent := &PersistentConn{
+		Addr: server.listener.Addr().String(),
+		OnReconnect: func(conn *Conn) error {
+			logins <- struct{}{}
+			_, err := conn.Write([]byte("login\r\n"))
+			return err
+		},
+		OnStateChange: states.record,
+		MinBackoff:    10 * time.Millisecond,
+	}
+	if err := persistent.Connect(context.Background()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer persistent.Close()
+
+	first := server.accept(t)
+	if expected, actual := "login\r\n", string(testReadExactly(t, first, 7)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	first.Write([]byte("one"))
+
+	p := make([]byte, 3)
+	if _, err := io.ReadFull(persistent, p); nil != err || "one" != string(p) {
+		t.Errorf("Expected %q, but actually got: %q, (%T) %v", "one", p, err, err)
+	}
+
+	// (The device "reboots".)
+	first.Close()
+
+	read := make(chan string, 1)
+	go func() {
+		p := make([]byte, 3)
+		io.ReadFull(persistent, p) // (Waits, while reconnecting.)
+		read <- string(p)
+	}()
+
+	second := server.accept(t)
+	if expected, actual := "login\r\n", string(testReadExactly(t, second, 7)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	second.Write([]byte("two"))
+
+	select {
+	case actual := <-read:
+		if expected := "two"; expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the read.")
+	}
+
+	if expected, actual := 2, len(logins); expected != actual {
+		t.Errorf("Expected OnReconnect to be called %d time(s), but actually was %d.", expected, actual)
+	}
+
+	expected := []PersistentState{PersistentConnected, PersistentReconnecting, PersistentConnected}
+	if actual := states.get(); len(expected) != len(actual) || expected[0] != actual[0] || expected[1] != actual[1] || expected[2] != actual[2] {
+		t.Errorf("Expected the states %v, but actually got %v.", expected, actual)
+	}
+
+	// (A Write goes to the new connection.)
+	persistent.Write([]byte("hi"))
+	if expected, actual := "hi", string(testReadExactly(t, second, 2)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestPersistentConnReconnectFail(t *testing.T) {
+
+	server := testNewPersistentServer(t)
+
+	persistent := &PersistentConn{
+		Addr:       server.listener.Addr().String(),
+		Policy:     ReconnectFail,
+		MinBackoff: 100 * time.Millisecond,
+	}
+	if err := persistent.Connect(context.Background()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer persistent.Close()
+
+	server.accept(t).Close()
+
+	var buffer [1]byte
+	if _, err := persistent.Read(buffer[:]); ErrReconnecting != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", ErrReconnecting, err, err)
+	}
+	if _, err := persistent.Write([]byte("x")); ErrReconnecting != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", ErrReconnecting, err, err)
+	}
+	if expected, actual := PersistentReconnecting, persistent.State(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	server.accept(t)
+	for begin := time.Now(); PersistentConnected != persistent.State() && time.Since(begin) < time.Second; {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if expected, actual := PersistentConnected, persistent.State(); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestPersistentConnGiveUp(t *testing.T) {
+
+	server := testNewPersistentServer(t)
+
+	var states testStates
+	persistent := &PersistentConn{
+		Addr:          server.listener.Addr().String(),
+		OnStateChange: states.record,
+		MinBackoff:    5 * time.Millisecond,
+		MaxAttempts:   3,
+	}
+	if err := persistent.Connect(context.Background()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer persistent.Close()
+
+	// (The device goes away for good.)
+	server.listener.Close()
+	server.accept(t).Close()
+
+	var buffer [1]byte
+	_, err := persistent.Read(buffer[:])
+	if !errors.Is(err, ErrGaveUp) {
+		t.Errorf("Expected %v, but actually got: (%T) %v", ErrGaveUp, err, err)
+	}
+
+	expected := []PersistentState{PersistentConnected, PersistentReconnecting, PersistentFailed}
+	if actual := states.get(); len(expected) != len(actual) || expected[0] != actual[0] || expected[1] != actual[1] || expected[2] != actual[2] {
+		t.Errorf("Expected the states %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestPersistentConnClose(t *testing.T) {
+
+	server := testNewPersistentServer(t)
+
+	persistent := &PersistentConn{
+		Addr:       server.listener.Addr().String(),
+		MinBackoff: time.Hour,
+	}
+	if err := persistent.Connect(context.Background()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	server.accept(t).Close()
+
+	// (A Read that is waiting for the connection to come back returns, once it is closed.)
+	read := make(chan error, 1)
+	go func() {
+		var buffer [1]byte
+		_, err := persistent.Read(buffer[:])
+		read <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	persistent.Close()
+
+	select {
+	case err := <-read:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Expected %v, but actually got: (%T) %v", net.ErrClosed, err, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the read.")
+	}
+}
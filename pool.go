@@ -0,0 +1,323 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPoolMaxIdle = 2
+
+	// poolCheckTimeout is how long checking an idle connection waits, to see whether the peer has
+	// closed it.
+	poolCheckTimeout = time.Millisecond
+)
+
+// ErrPoolClosed is returned by Pool.Get once the Pool has been closed.
+var ErrPoolClosed = errors.New("telnet: pool closed")
+
+// Pool keeps (up to MaxIdle) idle client connections to each address; so that automation that
+// runs many commands against many devices does not have to dial (and log in) for each one. For
+// example:
+//
+//	pool := &telnet.Pool{
+//		Prepare: func(ctx context.Context, conn *telnet.Conn) error {
+//			//@TODO: Log in.
+//			return nil
+//		},
+//		IdleTimeout: time.Minute,
+//	}
+//	defer pool.Close()
+//
+//	conn, err := pool.Get(ctx, "switch-0001.example.net:23")
+//	if nil != err {
+//		return err
+//	}
+//
+//	err = runCommand(conn, "show version")
+//	pool.Put(conn, err)
+//
+// Get hands out an idle connection to the address (the one that was put back last), if there
+// is one that passes the health check; otherwise it dials a new one, and prepares it (with
+// Prepare). Put takes it back; unless it errored.
+//
+// The health check (which a connection has to pass before it is handed out again) sends it an
+// IAC NOP; and then waits (very briefly) to see whether the peer has closed it. (And then calls
+// Check, if it is not nil.) A connection that fails it is closed (i.e., evicted).
+//
+// The zero Pool is ready to use. A Pool can be used from many goroutines at the same time; but
+// its fields should not be changed once it is used.
+type Pool struct {
+	// Dialer (if not nil) is what dials the connections. Otherwise, they are dialed like
+	// DialToContext does.
+	Dialer *Dialer
+
+	// Prepare (if not nil) is called with each connection that is dialed, before it is handed
+	// out; such as to log in. If it returns an error, then the connection is closed, and Get
+	// returns the error.
+	Prepare func(ctx context.Context, conn *Conn) error
+
+	// Check (if not nil) is called with an idle connection, once it has passed the health check,
+	// before it is handed out again; such as to make sure that the device is still at its prompt.
+	// If it returns an error, then the connection is evicted.
+	Check func(conn *Conn) error
+
+	// MaxIdle is how many idle connections to keep, for each address. (A connection that is put
+	// back once there are that many is evicted.) The default is 2.
+	MaxIdle int
+
+	// IdleTimeout (if not zero) is how long a connection can be idle for; after which it is
+	// evicted. Zero means for as long as the Pool is open.
+	IdleTimeout time.Duration
+
+	mutex sync.Mutex
+	idle  map[string][]*internalPooledConn
+	out   map[*Conn]string
+
+	closed bool
+	stats  ConnPoolStats
+}
+
+// ConnPoolStats is how well a Pool (of connections) is doing. (See Pool.Stats.)
+type ConnPoolStats struct {
+	Hits      uint64 // How many times Get handed out an idle connection.
+	Misses    uint64 // How many times Get had to dial a new connection.
+	Evictions uint64 // How many connections were closed by the Pool; i.e., because they failed the health check, errored, expired, or there were too many idle.
+
+	Idle int // How many idle connections there are (to all the addresses).
+}
+
+type internalPooledConn struct {
+	conn  *Conn
+	timer *time.Timer
+}
+
+// Get returns a connection to 'addr'; either an idle one (that passes the health check), or a
+// new one (which was prepared, see Prepare). Once done with it, give it back with Put.
+//
+// If 'ctx' is done before a new connection is dialed (and prepared), then Get returns the error
+// of 'ctx'.
+func (pool *Pool) Get(ctx context.Context, addr string) (*Conn, error) {
+	for {
+		if err := ctx.Err(); nil != err {
+			return nil, err
+		}
+
+		pooled, err := pool.take(addr)
+		if nil != err {
+			return nil, err
+		}
+		if nil == pooled {
+			break
+		}
+
+		if err := pool.check(pooled.conn); nil != err {
+			pooled.conn.logger.Debugf("Evicting pooled connection to %s, as it failed the health check: %v", addr, err)
+			pooled.conn.Close()
+			pool.count(&pool.stats.Evictions)
+			continue
+		}
+
+		if err := pool.handOut(pooled.conn, addr); nil != err {
+			return nil, err
+		}
+		pool.count(&pool.stats.Hits)
+		return pooled.conn, nil
+	}
+
+	pool.count(&pool.stats.Misses)
+
+	dialer := pool.Dialer
+	if nil == dialer {
+		dialer = &Dialer{}
+	}
+	conn, err := dialer.DialToContext(ctx, addr)
+	if nil != err {
+		return nil, err
+	}
+
+	if fn := pool.Prepare; nil != fn {
+		if err := fn(ctx, conn); nil != err {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if err := pool.handOut(conn, addr); nil != err {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put gives 'conn' (which Get returned) back to the Pool; with the error (if any) that using it
+// ended with. If 'err' is not nil (or the Conn has been closed), then it is not reused; but is
+// closed (i.e., evicted).
+//
+// (A Conn that did not come from the Pool, or was already put back, is closed.)
+func (pool *Pool) Put(conn *Conn, err error) {
+	pool.mutex.Lock()
+
+	addr, ok := pool.out[conn]
+	delete(pool.out, conn)
+
+	maxIdle := pool.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = defaultPoolMaxIdle
+	}
+
+	switch {
+	case !ok || pool.closed:
+		pool.mutex.Unlock()
+		conn.Close()
+		return
+	case nil != err || conn.isClosed() || maxIdle <= len(pool.idle[addr]):
+		pool.stats.Evictions++
+		pool.mutex.Unlock()
+		conn.Close()
+		return
+	}
+
+	pooled := &internalPooledConn{conn: conn}
+	if 0 < pool.IdleTimeout {
+		pooled.timer = time.AfterFunc(pool.IdleTimeout, func() {
+			pool.expire(addr, pooled)
+		})
+	}
+
+	if nil == pool.idle {
+		pool.idle = map[string][]*internalPooledConn{}
+	}
+	pool.idle[addr] = append(pool.idle[addr], pooled)
+	pool.mutex.Unlock()
+}
+
+// Stats returns how well the Pool is doing (so far).
+func (pool *Pool) Stats() ConnPoolStats {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	stats := pool.stats
+	for _, idle := range pool.idle {
+		stats.Idle += len(idle)
+	}
+	return stats
+}
+
+// Close closes the idle connections; and has Get return ErrPoolClosed (and Put close what it is
+// given) from now on.
+func (pool *Pool) Close() error {
+	pool.mutex.Lock()
+	idle := pool.idle
+	pool.idle = nil
+	pool.closed = true
+	pool.mutex.Unlock()
+
+	for _, pooled := range idle {
+		for _, p := range pooled {
+			if nil != p.timer {
+				p.timer.Stop()
+			}
+			p.conn.Close()
+		}
+	}
+
+	return nil
+}
+
+// take takes (out of the Pool) the idle connection to 'addr' that was put back last; or returns
+// nil, if there is not one.
+func (pool *Pool) take(addr string) (*internalPooledConn, error) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if pool.closed {
+		return nil, ErrPoolClosed
+	}
+
+	idle := pool.idle[addr]
+	if len(idle) <= 0 {
+		return nil, nil
+	}
+
+	pooled := idle[len(idle)-1]
+	pool.idle[addr] = idle[:len(idle)-1]
+	if nil != pooled.timer {
+		pooled.timer.Stop()
+	}
+
+	return pooled, nil
+}
+
+// handOut records that 'conn' (to 'addr') has been handed out; so that Put knows where it goes.
+func (pool *Pool) handOut(conn *Conn, addr string) error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if pool.closed {
+		conn.Close()
+		return ErrPoolClosed
+	}
+
+	if nil == pool.out {
+		pool.out = map[*Conn]string{}
+	}
+	pool.out[conn] = addr
+	return nil
+}
+
+// expire evicts 'pooled' (which is idle, for 'addr'); unless it has been taken since.
+func (pool *Pool) expire(addr string, pooled *internalPooledConn) {
+	pool.mutex.Lock()
+
+	idle := pool.idle[addr]
+	for k, p := range idle {
+		if pooled == p {
+			pool.idle[addr] = append(idle[:k], idle[k+1:]...)
+			pool.stats.Evictions++
+			pool.mutex.Unlock()
+
+			pooled.conn.logger.Debugf("Evicting pooled connection to %s, as it was idle for %v.", addr, pool.IdleTimeout)
+			pooled.conn.Close()
+			return
+		}
+	}
+
+	pool.mutex.Unlock()
+}
+
+func (pool *Pool) count(counter *uint64) {
+	pool.mutex.Lock()
+	*counter++
+	pool.mutex.Unlock()
+}
+
+// check is the health check; which an idle connection has to pass before it is handed out again.
+func (pool *Pool) check(conn *Conn) error {
+	if conn.isClosed() {
+		return ErrClosed
+	}
+
+	if err := conn.SendCommand(NOP); nil != err {
+		return err
+	}
+
+	// (If the peer has closed the connection, then a read returns that right away. Otherwise it
+	// times out; unless the peer sent something, which is left to be read.)
+	if deadliner, ok := conn.conn.(interface{ SetReadDeadline(time.Time) error }); ok {
+		deadliner.SetReadDeadline(time.Now().Add(poolCheckTimeout))
+		_, err := conn.Peek(1)
+		deadliner.SetReadDeadline(time.Time{})
+
+		if netErr, ok := err.(net.Error); nil != err && !(ok && netErr.Timeout()) {
+			return err
+		}
+	}
+
+	if fn := pool.Check; nil != fn {
+		return fn(conn)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package telnet_test
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+	"github.com/wouteroostervld/go-telnet/telnettest"
+
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testDeviceHandler is like a (very simple) network device: it shows a prompt; and answers each
+// command with "ok". ("quit" closes the connection.)
+type testDeviceHandler struct{}
+
+func (testDeviceHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader) {
+	conn := w.(*telnet.Conn)
+
+	conn.Write([]byte("# "))
+	for {
+		line, err := conn.ReadLine()
+		if nil != err || "quit" == line {
+			return
+		}
+		conn.Write([]byte("ok\r\n# "))
+	}
+}
+
+// testDevicePrepare waits for the prompt; as logging in would.
+func testDevicePrepare(ctx context.Context, conn *telnet.Conn) error {
+	p := make([]byte, 2)
+	_, err := io.ReadFull(conn, p)
+	return err
+}
+
+// testDeviceCommand runs a command on 'conn'.
+func testDeviceCommand(conn *telnet.Conn, command string) error {
+	if _, err := conn.Write([]byte(command + "\r\n")); nil != err {
+		return err
+	}
+
+	p := make([]byte, 6)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		return err
+	}
+	if expected, actual := "ok\r\n# ", string(p); expected != actual {
+		return errors.New("expected " + expected + ", but actually got " + actual)
+	}
+	return nil
+}
+
+func TestPool(t *testing.T) {
+
+	server := telnettest.NewServer(testDeviceHandler{})
+	defer server.Close()
+
+	pool := &telnet.Pool{Prepare: testDevicePrepare}
+	defer pool.Close()
+
+	ctx := context.Background()
+
+	first, err := pool.Get(ctx, server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	err = testDeviceCommand(first, "show version")
+	pool.Put(first, err)
+
+	// (Reused.)
+	second, err := pool.Get(ctx, server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if first != second {
+		t.Errorf("Expected the idle connection to be reused, but it was not.")
+	}
+	err = testDeviceCommand(second, "show interfaces")
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (Errored; so not reused.)
+	pool.Put(second, errors.New("timed out"))
+
+	third, err := pool.Get(ctx, server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if second == third {
+		t.Errorf("Expected a new connection, but actually got the one that errored.")
+	}
+
+	// (The device closed it; so it fails the health check.)
+	testDeviceCommand(third, "quit")
+	pool.Put(third, nil)
+	time.Sleep(50 * time.Millisecond)
+
+	fourth, err := pool.Get(ctx, server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if third == fourth {
+		t.Errorf("Expected a new connection, but actually got the one that was closed.")
+	}
+	if err := testDeviceCommand(fourth, "show clock"); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	pool.Put(fourth, nil)
+
+	if expected, actual := (telnet.ConnPoolStats{Hits: 1, Misses: 3, Evictions: 2, Idle: 1}), pool.Stats(); expected != actual {
+		t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+	}
+
+	pool.Close()
+	if _, err := pool.Get(ctx, server.Addr); telnet.ErrPoolClosed != err {
+		t.Errorf("Expected %v, but actually got %v.", telnet.ErrPoolClosed, err)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+
+	server := telnettest.NewServer(testDeviceHandler{})
+	defer server.Close()
+
+	pool := &telnet.Pool{
+		Prepare:     testDevicePrepare,
+		IdleTimeout: 20 * time.Millisecond,
+	}
+	defer pool.Close()
+
+	conn, err := pool.Get(context.Background(), server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	pool.Put(conn, nil)
+
+	if expected, actual := (telnet.ConnPoolStats{Misses: 1, Idle: 1}), pool.Stats(); expected != actual {
+		t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if expected, actual := (telnet.ConnPoolStats{Misses: 1, Evictions: 1}), pool.Stats(); expected != actual {
+		t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+	}
+	select {
+	case <-conn.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected the expired connection to be closed, but it was not.")
+	}
+}
+
+func TestPoolConcurrent(t *testing.T) {
+
+	const (
+		workers  = 8
+		commands = 25
+		maxIdle  = 4
+	)
+
+	server := telnettest.NewServer(testDeviceHandler{})
+	defer server.Close()
+
+	pool := &telnet.Pool{
+		Prepare: testDevicePrepare,
+		MaxIdle: maxIdle,
+	}
+	defer pool.Close()
+
+	errs := make(chan error, workers*commands)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for command := 0; command < commands; command++ {
+				conn, err := pool.Get(context.Background(), server.Addr)
+				if nil != err {
+					errs <- err
+					continue
+				}
+				err = testDeviceCommand(conn, "show version")
+				if nil != err {
+					errs <- err
+				}
+				pool.Put(conn, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	stats := pool.Stats()
+	if expected, actual := uint64(workers*commands), stats.Hits+stats.Misses; expected != actual {
+		t.Errorf("Expected %d hits and misses, but actually got %d; %+v.", expected, actual, stats)
+	}
+	if stats.Misses < 1 || workers*commands/2 < stats.Misses {
+		t.Errorf("Expected most of the connections to be reused, but they were not; %+v.", stats)
+	}
+	if maxIdle < stats.Idle {
+		t.Errorf("Expected no more than %d idle, but actually got %+v.", maxIdle, stats)
+	}
+	// (Each connection that was dialed is either idle, or was evicted; as there were too many idle.)
+	if expected, actual := stats.Misses, uint64(stats.Idle)+stats.Evictions; expected != actual {
+		t.Errorf("Expected %d to be idle, or evicted, but actually got %d; %+v.", expected, actual, stats)
+	}
+}
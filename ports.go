@@ -0,0 +1,34 @@
+package telnet
+
+import (
+	"net"
+)
+
+// The (well known) ports of TELNET, and of TELNETS.
+const (
+	telnetPort  = "23"
+	telnetsPort = "992"
+)
+
+// resolvePort returns 'addr' with the port, if it is given by name as "telnet" or "telnets"
+// (such as ":telnets"), replaced by its number. (The Go resolver falls back on a table of its own,
+// where there is no /etc/services; such as in a container built from scratch. And that table does
+// not have "telnets"; so, without this, listening on (or dialing) the default address of TELNETS
+// would fail there.)
+func resolvePort(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if nil != err {
+		return addr
+	}
+
+	switch port {
+	case "telnet":
+		port = telnetPort
+	case "telnets":
+		port = telnetsPort
+	default:
+		return addr
+	}
+
+	return net.JoinHostPort(host, port)
+}
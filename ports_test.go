@@ -0,0 +1,48 @@
+package telnet
+
+import (
+	"testing"
+)
+
+func TestResolvePort(t *testing.T) {
+
+	tests := []struct {
+		Addr     string
+		Expected string
+	}{
+		{
+			Addr:     ":telnet",
+			Expected: ":23",
+		},
+		{
+			Addr:     ":telnets",
+			Expected: ":992",
+		},
+		{
+			Addr:     "127.0.0.1:telnets",
+			Expected: "127.0.0.1:992",
+		},
+		{
+			Addr:     "[::1]:telnets",
+			Expected: "[::1]:992",
+		},
+		{
+			Addr:     "example.net:5555",
+			Expected: "example.net:5555",
+		},
+		{
+			Addr:     "example.net:ssh",
+			Expected: "example.net:ssh",
+		},
+		{
+			Addr:     "example.net",
+			Expected: "example.net",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, resolvePort(test.Addr); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
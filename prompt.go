@@ -0,0 +1,210 @@
+package telnet
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// SetPromptIdleGap sets how long the peer can go without sending anything (after it has sent
+// something), before ReadUntilPrompt takes that to mean it is showing a prompt; for peers that do
+// not mark their prompts with IAC GA (or IAC EOR). For example:
+//
+//	conn.SetPromptIdleGap(250 * time.Millisecond)
+//
+// This is a heuristic; and so is off (i.e., zero) unless it is asked for.
+//
+// (It needs the underlying connection to support read deadlines; which TCP, and TLS, connections do.)
+func (clientConn *Conn) SetPromptIdleGap(gap time.Duration) {
+	clientConn.promptMutex.Lock()
+	defer clientConn.promptMutex.Unlock()
+
+	clientConn.promptIdleGap = gap
+}
+
+// ReadUntilPrompt reads (TELNET data) until the peer shows a prompt; and returns what it read
+// (not including the prompt marker). This is for things such as MUD clients, that want to know
+// when the server has finished sending something, and is waiting for the user; such as to run
+// triggers. For example:
+//
+//	for {
+//		data, err := conn.ReadUntilPrompt(ctx)
+//		if nil != err {
+//			return err
+//		}
+//
+//		//@TODO: Run the triggers on 'data'.
+//	}
+//
+// A prompt is marked by the peer sending an IAC GA; or an IAC EOR, if the peer is doing the
+// END-OF-RECORD option. (Which the Conn agrees to, if the peer offers it, once told to with
+// SetOptionPolicy(telnet.OptEndOfRecord, telnet.OptionPolicyAccept).)
+// If the prompt idle gap is set (see SetPromptIdleGap), then the peer going quiet (for that long)
+// also counts as a prompt.
+//
+// If 'ctx' is done before a prompt is read, then ReadUntilPrompt returns the error of 'ctx';
+// and what it had read is kept, for the next call of ReadUntilPrompt. (Cancelling 'ctx' needs
+// the underlying connection to support read deadlines.)
+func (clientConn *Conn) ReadUntilPrompt(ctx context.Context) ([]byte, error) {
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	reader := clientConn.dataReader
+	reader.isPrompt = clientConn.isPrompt
+	defer func() {
+		reader.isPrompt = nil
+	}()
+
+	clientConn.promptMutex.Lock()
+	data := clientConn.promptData
+	clientConn.promptData = nil
+	gap := clientConn.promptIdleGap
+	clientConn.promptMutex.Unlock()
+
+	// (What Peek read, but has not been Read yet, comes first.)
+	if 0 < len(clientConn.peeked) {
+		data = append(data, clientConn.peeked...)
+		clientConn.peeked = nil
+	}
+
+	deadliner, _ := clientConn.conn.(interface{ SetReadDeadline(time.Time) error })
+
+	// (The underlying connection's read deadline is what stops a Read that is waiting, once
+	// 'ctx' is done.)
+	var cancel internalDeadlineCanceller
+	if nil != deadliner {
+		stop := cancel.watch(ctx, deadliner.SetReadDeadline, clientConn.currentReadDeadline())
+		defer stop()
+	}
+
+	var buffer [512]byte
+	for {
+		if nil != deadliner && 0 < gap {
+			if 0 < len(data) {
+				cancel.setDeadline(time.Now().Add(gap))
+			} else {
+				cancel.setDeadline(time.Time{})
+			}
+		}
+
+		n, err := clientConn.readData(buffer[:])
+		data = append(data, buffer[:n]...)
+
+		if reader.promptSeen() {
+			return data, nil
+		}
+		if nil == err {
+			continue
+		}
+
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			if cancel.cancelled() {
+				clientConn.promptMutex.Lock()
+				clientConn.promptData = data
+				clientConn.promptMutex.Unlock()
+				return nil, ctx.Err()
+			}
+			if 0 < gap && 0 < len(data) && !cancel.expired() {
+				return data, nil
+			}
+		}
+		return data, err
+	}
+}
+
+// isPrompt returns whether the TELNET command 'cmd' marks a prompt.
+func (clientConn *Conn) isPrompt(cmd byte) bool {
+	switch cmd {
+	case GA:
+		return true
+	case EOR:
+		_, remote := clientConn.OptionEnabled(OptEndOfRecord)
+		return remote
+	default:
+		return false
+	}
+}
+
+// RequestEndOfRecord asks the peer to do the END-OF-RECORD option (i.e., sends a DO for it); so
+// that it marks its prompts with IAC EOR. This is for clients; such as MUD clients. (Whether the
+// peer agreed to it is the second value that OptionEnabled(telnet.OptEndOfRecord) returns.)
+//
+// It does not wait for the peer to answer; see RequestEnableRemote, for that.
+func (clientConn *Conn) RequestEndOfRecord() error {
+	return clientConn.negotiator.request(OptEndOfRecord, false, true)
+}
+
+// OfferEndOfRecord offers to do the END-OF-RECORD option (i.e., sends a WILL for it); so that
+// WritePrompt marks the prompts with IAC EOR (rather than IAC GA), once the peer agrees to it.
+// This is for servers.
+//
+// It does not wait for the peer to answer; see RequestEnableLocal, for that.
+func (clientConn *Conn) OfferEndOfRecord() error {
+	return clientConn.negotiator.request(OptEndOfRecord, true, true)
+}
+
+// WritePrompt writes 'prompt' to the peer (like Write does); followed by what marks it as a
+// prompt. For example:
+//
+//	if err := conn.WritePrompt("> "); nil != err {
+//		return err
+//	}
+//
+// What marks it is an IAC EOR, if the END-OF-RECORD option is enabled on our side (see
+// OfferEndOfRecord); or else an IAC GA, unless the SUPPRESS-GO-AHEAD option is enabled on our side
+// (in which case there is nothing to mark it with).
+func (clientConn *Conn) WritePrompt(prompt string) error {
+	if _, err := clientConn.Write([]byte(prompt)); nil != err {
+		return err
+	}
+
+	if eor, _ := clientConn.OptionEnabled(OptEndOfRecord); eor {
+		return clientConn.writeCommand([]byte{IAC, EOR})
+	}
+	if suppressGoAhead, _ := clientConn.OptionEnabled(OptSuppressGoAhead); suppressGoAhead {
+		return nil
+	}
+	return clientConn.writeCommand([]byte{IAC, GA})
+}
+
+// OnPrompt registers 'fn' to be called each time the peer marks a prompt (see ReadUntilPrompt);
+// i.e., sends an IAC GA, or an IAC EOR (if the END-OF-RECORD option is enabled on the peer's
+// side). This is for knowing where the prompts are, however the Conn is being read. For example:
+//
+//	prompts := make(chan struct{}, 1)
+//	conn.OnPrompt(func() {
+//		select {
+//		case prompts <- struct{}{}:
+//		default:
+//		}
+//	})
+//
+// This replaces any function previously registered. Registering nil stops the calls. (The prompt
+// idle gap, see SetPromptIdleGap, does not count; as it is not sent by the peer.)
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns. 'fn' is called before Read returns any
+// data that came after the prompt. (Data that came before it might be returned from the same call
+// to Read, after 'fn' was called.)
+func (clientConn *Conn) OnPrompt(fn func()) {
+	clientConn.promptMutex.Lock()
+	clientConn.onPrompt = fn
+	clientConn.promptMutex.Unlock()
+}
+
+// prompted calls what OnPrompt registered (if anything), if the TELNET command 'cmd' marks a
+// prompt; and returns whether anything was told of the prompt (i.e., whether it was handled).
+func (clientConn *Conn) prompted(cmd byte) bool {
+	if !clientConn.isPrompt(cmd) {
+		return false
+	}
+
+	clientConn.promptMutex.Lock()
+	fn := clientConn.onPrompt
+	clientConn.promptMutex.Unlock()
+
+	if nil != fn {
+		fn()
+	}
+	return nil != fn || nil != clientConn.dataReader.isPrompt
+}
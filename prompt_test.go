@@ -0,0 +1,276 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testPromptPipe returns a Conn (for ReadUntilPrompt to be used on), and a function to send it
+// bytes (as the peer), without waiting for them to be read.
+func testPromptPipe(t *testing.T) (*Conn, func(p ...byte)) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	// (Whatever the Conn sends, such as to answer negotiations, is thrown away.)
+	go io.Copy(io.Discard, remote)
+
+	sends := make(chan []byte, 16)
+	go func() {
+		for p := range sends {
+			remote.Write(p)
+		}
+	}()
+	t.Cleanup(func() {
+		close(sends)
+	})
+
+	return newConn(local, nil), func(p ...byte) {
+		sends <- p
+	}
+}
+
+func TestConnReadUntilPrompt(t *testing.T) {
+
+	tests := []struct {
+		AcceptEOR bool
+		Sends     [][]byte
+		Expected  []string
+	}{
+		{
+			Sends: [][]byte{
+				[]byte("hello\r\n> "),
+				{IAC, GA},
+				[]byte("more"),
+				{IAC, GA},
+			},
+			Expected: []string{"hello\r\n> ", "more"},
+		},
+		{
+			// Everything that comes in before the prompt (however it is split up) is put together.
+			Sends: [][]byte{
+				[]byte("one "),
+				[]byte("t\xff"),
+				{IAC, 'o', IAC, GA, '>', IAC, GA}, // (The IAC IAC is split up.)
+			},
+			Expected: []string{"one t\xffo", ">"},
+		},
+		{
+			// IAC EOR only marks a prompt once the peer is doing END-OF-RECORD.
+			Sends: [][]byte{
+				[]byte("a"),
+				{IAC, EOR},
+				[]byte("b"),
+				{IAC, GA},
+			},
+			Expected: []string{"ab"},
+		},
+		{
+			AcceptEOR: true,
+			Sends: [][]byte{
+				{IAC, WILL, OptEndOfRecord},
+				[]byte("a"),
+				{IAC, EOR},
+				[]byte("b"),
+				{IAC, GA},
+			},
+			Expected: []string{"a", "b"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		if test.AcceptEOR {
+			conn.SetOptionPolicy(OptEndOfRecord, OptionPolicyAccept)
+		}
+
+		for _, p := range test.Sends {
+			send(p...)
+		}
+
+		for i, expected := range test.Expected {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			data, err := conn.ReadUntilPrompt(ctx)
+			cancel()
+
+			if nil != err {
+				t.Errorf("For test #%d and prompt #%d, did not expect an error, but actually got one: (%T) %v", testNumber, i, err, err)
+				continue
+			}
+			if actual := string(data); expected != actual {
+				t.Errorf("For test #%d and prompt #%d, expected %q, but actually got %q.", testNumber, i, expected, actual)
+			}
+		}
+	}
+}
+
+func TestConnReadUntilPromptIdleGap(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	conn.SetPromptIdleGap(50 * time.Millisecond)
+
+	send([]byte("What is your name? ")...)
+
+	begin := time.Now()
+	data, err := conn.ReadUntilPrompt(context.Background())
+	elapsed := time.Since(begin)
+
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "What is your name? ", string(data); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if elapsed < 40*time.Millisecond || time.Second < elapsed {
+		t.Errorf("Expected the prompt after about 50ms, but actually took %v.", elapsed)
+	}
+
+	// GA still marks a prompt, right away.
+	send(append([]byte("ok"), IAC, GA)...)
+	if data, err := conn.ReadUntilPrompt(context.Background()); "ok" != string(data) || nil != err {
+		t.Errorf("Expected %q, but actually got: %q, (%T) %v", "ok", data, err, err)
+	}
+}
+
+func TestConnReadUntilPromptCancel(t *testing.T) {
+
+	tests := []struct {
+		First  []byte
+		Second []byte
+	}{
+		{
+			First:  []byte("abc"),
+			Second: []byte{'d', 'e', 'f', IAC, GA},
+		},
+		{
+			// (The command is cut short; and picked up where it left off.)
+			First:  []byte{'a', 'b', 'c', IAC},
+			Second: []byte{GA, 'd', 'e', 'f', IAC, GA},
+		},
+		{
+			// (The subnegotiation is cut short; and picked up where it left off.)
+			First:  []byte{'a', 'b', 'c', IAC, SB, OptNAWS, 0},
+			Second: []byte{80, 0, 24, IAC, SE, 'd', 'e', 'f', IAC, GA},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+
+		send(test.First...)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		data, err := conn.ReadUntilPrompt(ctx)
+		cancel()
+
+		if expected, actual := context.DeadlineExceeded, err; expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+		}
+		if 0 != len(data) {
+			t.Errorf("For test #%d, expected no data, but actually got %q.", testNumber, data)
+		}
+
+		send(test.Second...)
+
+		// What was read is kept, for the next call.
+		expected := "abcdef"
+		if 1 == testNumber {
+			expected = "abc"
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		data, err = conn.ReadUntilPrompt(ctx)
+		cancel()
+
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if actual := string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnOnPrompt(t *testing.T) {
+
+	conn, send := testPromptPipe(t)
+	conn.SetOptionPolicy(OptEndOfRecord, OptionPolicyAccept)
+
+	var prompts []string
+	var data []byte
+	conn.OnPrompt(func() {
+		prompts = append(prompts, string(data))
+	})
+
+	read := func(n int) {
+		t.Helper()
+
+		p := make([]byte, n)
+		for k := 0; k < n; k++ {
+			if _, err := io.ReadFull(conn, p[k:k+1]); nil != err {
+				t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+			}
+			data = append(data, p[k])
+		}
+	}
+
+	// (An IAC EOR only marks a prompt once the END-OF-RECORD option is enabled.)
+	send([]byte("a> ")...)
+	send(IAC, GA)
+	send('b')
+	send(IAC, EOR)
+	send(IAC, WILL, OptEndOfRecord)
+	send('c')
+	send(IAC, EOR)
+	send('d')
+	read(6)
+
+	if expected, actual := []string{"a> ", "a> bc"}, prompts; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWritePrompt(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	go conn.WritePrompt("> ")
+	if expected, actual := string([]byte{'>', ' ', IAC, GA}), string(testReadExactly(t, remote, 4)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	go conn.OfferEndOfRecord()
+	if expected, actual := string([]byte{IAC, WILL, OptEndOfRecord}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	remote.Write([]byte{IAC, DO, OptEndOfRecord})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := conn.WaitForNegotiations(ctx); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	go conn.WritePrompt("> ")
+	if expected, actual := string([]byte{'>', ' ', IAC, EOR}), string(testReadExactly(t, remote, 4)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnRequestEndOfRecord(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	go conn.RequestEndOfRecord()
+	if expected, actual := string([]byte{IAC, DO, OptEndOfRecord}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,265 @@
+package telnet
+
+import (
+	"github.com/reiver/go-oi"
+
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// DialFunc is how a ProxyHandler connects to its target. (net.Dial is a DialFunc; and so is the
+// Dial method of a net.Dialer, or of a tls.Dialer.)
+type DialFunc func(network string, address string) (net.Conn, error)
+
+// ProxyOptionMode is how a ProxyHandler deals with the negotiation of an option.
+type ProxyOptionMode int
+
+const (
+	// ProxyPassThrough has the negotiations (and subnegotiations) of the option passed on, as-is,
+	// from either side to the other; so that the client and the target negotiate it with each
+	// other, as if there were no proxy in between.
+	ProxyPassThrough ProxyOptionMode = iota
+
+	// ProxyLocal has each side's negotiations of the option answered by the proxy itself, and
+	// not passed on. (The client is answered according to the Server's OptionPolicy, and the
+	// target is refused.)
+	ProxyLocal
+)
+
+// defaultProxyOptions are the options a ProxyHandler answers locally, unless it is told otherwise.
+//
+// MCCP compresses everything sent after it is enabled; which the proxy could not see into
+// (to Rewrite it), and which the other side might not even support.
+var defaultProxyOptions = map[byte]ProxyOptionMode{
+	OptCompress:  ProxyLocal,
+	OptCompress2: ProxyLocal,
+	OptCompress3: ProxyLocal,
+}
+
+// ProxySide is a side (or leg) of a proxied connection.
+type ProxySide int
+
+const (
+	ProxyClient ProxySide = iota // The connection from the client, to the proxy.
+	ProxyTarget                  // The connection from the proxy, to the target.
+)
+
+// String returns "client" or "target".
+func (side ProxySide) String() string {
+	switch side {
+	case ProxyClient:
+		return "client"
+	case ProxyTarget:
+		return "target"
+	default:
+		return "unknown"
+	}
+}
+
+// ProxyError is an error on one of the sides of a proxied connection. For example:
+//
+//	proxy: dial target: dial tcp 10.0.0.7:23: connect: connection refused
+type ProxyError struct {
+	Side ProxySide // Which connection the error was on.
+	Op   string    // What was being done; i.e., "dial", "read" or "write".
+	Err  error
+}
+
+func (err *ProxyError) Error() string {
+	return "proxy: " + err.Op + " " + err.Side.String() + ": " + err.Err.Error()
+}
+
+func (err *ProxyError) Unwrap() error {
+	return err.Err
+}
+
+// ProxyHandler is a TELNET server which, for each connection, connects to another TELNET
+// server (the target), and relays everything between the client and the target.
+//
+// For a simple example:
+//
+//	handler := &telnet.ProxyHandler{
+//		Target: "10.0.0.7:23",
+//	}
+//
+//	err := telnet.ListenAndServeTLS(":telnets", "cert.pem", "key.pem", handler)
+//
+// The data is relayed as data; i.e., it is un-escaped when read from one side, and escaped (again)
+// when written to the other. So it can be changed along the way, with Rewrite.
+//
+// The commands (such as IAC IP, and IAC AYT) are passed on as-is. And so are the negotiations, and
+// subnegotiations, of the options; except for those that Options says are to be answered locally.
+// (By default, MCCP is answered locally; and everything else, such as NAWS, is passed on.)
+//
+// When either side closes the connection (or fails), the other side is closed too.
+type ProxyHandler struct {
+	// Target is the (TCP) address of the TELNET server to connect to.
+	Target string
+
+	// Dial, if not nil, is used to connect to Target; instead of net.Dial. (Such as to connect
+	// with TLS, or with a timeout.)
+	Dial DialFunc
+
+	// Rewrite, if not nil, is called with the data read from either side, before it is written
+	// to the other side; and what it returns is written instead. (Inbound is the data from the
+	// client, going to the target; and Outbound is the data from the target, going to the client.)
+	//
+	// Rewrite is called for whatever each read returned; so something it is looking for might
+	// be split across calls.
+	Rewrite func(dir Direction, p []byte) []byte
+
+	// Options optionally overrides how the negotiations of individual options are dealt with.
+	// For example:
+	//
+	//	Options: map[byte]telnet.ProxyOptionMode{
+	//		telnet.OptEcho: telnet.ProxyLocal,
+	//	},
+	Options map[byte]ProxyOptionMode
+
+	// OnError, if not nil, is called with each error (which is a *ProxyError); from either side.
+	// (The side closing the connection, or being closed because the other side closed, is not
+	// an error.)
+	OnError func(err error)
+}
+
+// ServeTELNET connects to the target, and then relays everything between the client and the
+// target; until either of them closes the connection.
+func (handler *ProxyHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	handler.serve(context.Background(), logger, w, r)
+}
+
+// ContextHandler returns the ProxyHandler, as a ContextHandler. (As one, the context of the
+// connection being cancelled, such as because the client went away, stops it dialing the target;
+// unless Dial is set.)
+func (handler *ProxyHandler) ContextHandler() ContextHandler {
+	return internalProxyContextHandler{handler: handler}
+}
+
+// internalProxyContextHandler is a ProxyHandler, as a ContextHandler.
+type internalProxyContextHandler struct {
+	handler *ProxyHandler
+}
+
+func (proxy internalProxyContextHandler) ServeTELNET(ctx context.Context, conn *Conn) {
+	proxy.handler.serve(ctx, conn.logger, conn, conn)
+}
+
+// serve connects to the target, and then relays everything between the client and the target.
+func (handler *ProxyHandler) serve(ctx context.Context, logger Logger, w Writer, r Reader) {
+
+	dial := handler.Dial
+	if nil == dial {
+		dial = func(network string, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+
+	c, err := dial("tcp", handler.Target)
+	if nil != err {
+		handler.report(logger, &ProxyError{Side: ProxyTarget, Op: "dial", Err: err})
+		return
+	}
+	logger.Debugf("Connected to %q.", handler.Target)
+
+	target := newConn(c, logger)
+	defer target.Close()
+
+	// Negotiations can only be passed on (or, for that matter, told apart from data) if the
+	// client is on a *Conn. Otherwise just the data is relayed.
+	client, _ := r.(*Conn)
+	if nil != client {
+		client.relayTo(target, handler.relayed)
+	}
+	target.relayTo(client, handler.relayed)
+
+	done := make(chan *ProxyError, 2)
+	go func() {
+		done <- handler.copy(Inbound, target, r)
+	}()
+	go func() {
+		done <- handler.copy(Outbound, w, target)
+	}()
+
+	first := <-done
+	handler.report(logger, first)
+
+	// Whichever side is still open is closed; so that the other copy stops (promptly) too.
+	target.Close()
+	closer, closeable := r.(io.Closer)
+	if !closeable {
+		logger.Debugf("Not waiting for the client, as it cannot be closed.")
+		return
+	}
+	closer.Close()
+
+	// Errors from reading (or writing) the side that was just closed are expected.
+	if second := <-done; nil != second && !errors.Is(second.Err, net.ErrClosed) {
+		handler.report(logger, second)
+	}
+}
+
+// relayed returns whether the negotiations of 'option' are passed on from one side to the other.
+func (handler *ProxyHandler) relayed(option byte) bool {
+	mode, ok := handler.Options[option]
+	if !ok {
+		mode = defaultProxyOptions[option]
+	}
+
+	return ProxyPassThrough == mode
+}
+
+// copy copies (and rewrites) the data from 'reader' to 'writer', until either fails, or 'reader'
+// has no more.
+func (handler *ProxyHandler) copy(dir Direction, writer io.Writer, reader io.Reader) *ProxyError {
+
+	from, to := ProxyClient, ProxyTarget
+	if Outbound == dir {
+		from, to = ProxyTarget, ProxyClient
+	}
+
+	var buffer [1024]byte
+	p := buffer[:]
+
+	for {
+		n, err := reader.Read(p)
+
+		if 0 < n {
+			data := p[:n]
+			if nil != handler.Rewrite {
+				data = handler.Rewrite(dir, data)
+			}
+
+			if _, werr := oi.LongWrite(writer, data); nil != werr {
+				return &ProxyError{Side: to, Op: "write", Err: werr}
+			}
+		}
+
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return &ProxyError{Side: from, Op: "read", Err: err}
+		}
+	}
+}
+
+func (handler *ProxyHandler) report(logger Logger, err *ProxyError) {
+	if nil == err {
+		return
+	}
+
+	logger.Errorf("%v", err)
+
+	if fn := handler.OnError; nil != fn {
+		fn(err)
+	}
+}
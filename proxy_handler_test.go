@@ -0,0 +1,244 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testProxy starts a ProxyHandler, proxying a (net.Pipe) client to a target that the test
+// gets the other end of. It returns the client's end, the target's end, and a channel that
+// is closed once ServeTELNET returns.
+func testProxy(t *testing.T, handler *ProxyHandler) (net.Conn, net.Conn, <-chan struct{}) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler.Target = listener.Addr().String()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	conn := newConn(local, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeTELNET(NewContext(), conn, conn)
+	}()
+
+	target, err := listener.Accept()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		target.Close()
+	})
+
+	return remote, target, done
+}
+
+func testWaitFor(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the proxy to be done, but it was not.")
+	}
+}
+
+func TestProxyHandler(t *testing.T) {
+
+	client, target, _ := testProxy(t, &ProxyHandler{})
+
+	// The data is re-escaped; and the NAWS subnegotiation, and the IP, are passed on (in order).
+	go client.Write([]byte{'a', IAC, IAC, 'b', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'c', IAC, IP})
+
+	expected := []byte{'a', IAC, IAC, 'b', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'c', IAC, IP}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+
+	// MCCP is refused by the proxy (and not passed on); ECHO is passed on.
+	go target.Write([]byte{IAC, WILL, OptCompress2, IAC, WILL, OptEcho, 'x'})
+
+	expected = []byte{IAC, WILL, OptEcho, 'x'}
+	if actual := testReadExactly(t, client, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the client to get %v, but actually got %v.", expected, actual)
+	}
+
+	expected = []byte{IAC, DONT, OptCompress2}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestProxyHandlerOptions(t *testing.T) {
+
+	client, target, _ := testProxy(t, &ProxyHandler{
+		Options: map[byte]ProxyOptionMode{
+			OptNAWS:      ProxyLocal,
+			OptCompress2: ProxyPassThrough,
+		},
+	})
+
+	go client.Write([]byte{IAC, WILL, OptNAWS, IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE, 'a'})
+
+	// The proxy refuses NAWS, and does not pass on its subnegotiation.
+	expected := []byte{IAC, DONT, OptNAWS}
+	if actual := testReadExactly(t, client, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the client to get %v, but actually got %v.", expected, actual)
+	}
+
+	expected = []byte{'a'}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+
+	go target.Write([]byte{IAC, WILL, OptCompress2})
+
+	expected = []byte{IAC, WILL, OptCompress2}
+	if actual := testReadExactly(t, client, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the client to get %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestProxyHandlerRewrite(t *testing.T) {
+
+	client, target, _ := testProxy(t, &ProxyHandler{
+		Rewrite: func(dir Direction, p []byte) []byte {
+			if Outbound == dir {
+				return bytes.ReplaceAll(p, []byte("secret"), []byte("******"))
+			}
+			return bytes.ToUpper(p)
+		},
+	})
+
+	go client.Write([]byte("hello"))
+
+	if expected, actual := "HELLO", string(testReadExactly(t, target, 5)); expected != actual {
+		t.Errorf("Expected the target to get %q, but actually got %q.", expected, actual)
+	}
+
+	go target.Write([]byte("the secret\r\n"))
+
+	if expected, actual := "the ******\r\n", string(testReadExactly(t, client, 12)); expected != actual {
+		t.Errorf("Expected the client to get %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestProxyHandlerTeardown(t *testing.T) {
+
+	t.Run("target", func(t *testing.T) {
+		client, target, done := testProxy(t, &ProxyHandler{})
+
+		target.Close()
+		testWaitFor(t, done)
+
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := client.Read(make([]byte, 1)); io.EOF != err {
+			t.Errorf("Expected the client to be closed, but actually got: (%T) %v", err, err)
+		}
+	})
+
+	t.Run("client", func(t *testing.T) {
+		client, target, done := testProxy(t, &ProxyHandler{})
+
+		client.Close()
+		testWaitFor(t, done)
+
+		target.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := target.Read(make([]byte, 1)); io.EOF != err {
+			t.Errorf("Expected the target to be closed, but actually got: (%T) %v", err, err)
+		}
+	})
+}
+
+func TestProxyHandlerErrors(t *testing.T) {
+
+	var mutex sync.Mutex
+	var errs []error
+	onError := func(err error) {
+		mutex.Lock()
+		errs = append(errs, err)
+		mutex.Unlock()
+	}
+
+	// Failing to connect to the target.
+	refused := errors.New("connection refused")
+	handler := &ProxyHandler{
+		Dial: func(network string, address string) (net.Conn, error) {
+			return nil, refused
+		},
+		OnError: onError,
+	}
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := newConn(local, nil)
+	handler.ServeTELNET(NewContext(), conn, conn)
+
+	// The client sending something that is not TELNET.
+	client, _, done := testProxy(t, &ProxyHandler{OnError: onError})
+	go client.Write([]byte{IAC, 0})
+	testWaitFor(t, done)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if expected, actual := 2, len(errs); expected != actual {
+		t.Fatalf("Expected %d errors, but actually got %d: %v", expected, actual, errs)
+	}
+
+	tests := []struct {
+		ExpectedSide  ProxySide
+		ExpectedOp    string
+		ExpectedErr   error
+		ExpectedError string
+	}{
+		{
+			ExpectedSide:  ProxyTarget,
+			ExpectedOp:    "dial",
+			ExpectedErr:   refused,
+			ExpectedError: "proxy: dial target: connection refused",
+		},
+		{
+			ExpectedSide: ProxyClient,
+			ExpectedOp:   "read",
+			ExpectedErr:  ErrProtocolViolation,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var proxyErr *ProxyError
+		if !errors.As(errs[testNumber], &proxyErr) {
+			t.Errorf("For test #%d, expected a *ProxyError, but actually got: (%T) %v", testNumber, errs[testNumber], errs[testNumber])
+			continue
+		}
+		if expected, actual := test.ExpectedSide, proxyErr.Side; expected != actual {
+			t.Errorf("For test #%d, expected side %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedOp, proxyErr.Op; expected != actual {
+			t.Errorf("For test #%d, expected op %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if !errors.Is(proxyErr, test.ExpectedErr) {
+			t.Errorf("For test #%d, expected the error to wrap %v, but actually got: %v", testNumber, test.ExpectedErr, proxyErr)
+		}
+		if expected, actual := test.ExpectedError, proxyErr.Error(); "" != expected && expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
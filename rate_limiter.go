@@ -0,0 +1,246 @@
+package telnet
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrWouldBlock is the error returned by a Conn's Write when (with a non-blocking RateLimit) what
+// is being written does not all fit in what the rate limit allows right now. (What did fit was
+// written; which is what the 'n' returned by Write says.)
+var ErrWouldBlock = errors.New("telnet: write would block")
+
+// RateLimit is a limit on how fast a Conn sends data; in bytes per second, with bursts of
+// up to Burst bytes. (As a token bucket.)
+//
+// The bytes are the (escaped) bytes sent over the wire; so an IAC in the data counts as 2 bytes.
+//
+// The zero RateLimit means no limit.
+type RateLimit struct {
+	// BytesPerSecond is how many bytes can be sent per second, on average. Zero means
+	// there is no limit.
+	BytesPerSecond int
+
+	// Burst is how many bytes can be sent all at once, if nothing was sent for a while.
+	// The default is BytesPerSecond (but no less than 2, so that an escaped IAC fits).
+	Burst int
+
+	// NonBlocking, if true, has Write return ErrWouldBlock, instead of waiting, when what is
+	// being written does not fit in what can be sent right now.
+	NonBlocking bool
+}
+
+// internalRateLimiter is a token bucket.
+type internalRateLimiter struct {
+	mutex  sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+
+	// deadline is the write deadline; after which waiting (for tokens) gives up.
+	deadline time.Time
+
+	// changed is closed (and replaced) whenever the limit, or the deadline, changes; so that
+	// whatever is waiting can start over.
+	changed chan struct{}
+
+	// closed is set once the Conn has been closed; after which waiting gives up (with ErrClosed).
+	closed bool
+}
+
+func newRateLimiter() *internalRateLimiter {
+	return &internalRateLimiter{
+		changed: make(chan struct{}),
+	}
+}
+
+func (limiter *internalRateLimiter) set(limit RateLimit) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if 0 < limit.BytesPerSecond && limit.Burst <= 0 {
+		limit.Burst = limit.BytesPerSecond
+	}
+	if 0 < limit.BytesPerSecond && limit.Burst < 2 {
+		limit.Burst = 2
+	}
+
+	limiter.limit = limit
+	limiter.tokens = float64(limit.Burst)
+	limiter.last = time.Now()
+	limiter.wake()
+}
+
+func (limiter *internalRateLimiter) setDeadline(deadline time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.deadline = deadline
+	limiter.wake()
+}
+
+// close has whatever is waiting (and whatever would) give up; as the Conn has been closed.
+func (limiter *internalRateLimiter) close() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.closed = true
+	limiter.wake()
+}
+
+func (limiter *internalRateLimiter) writeDeadline() time.Time {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	return limiter.deadline
+}
+
+// wake wakes up whatever is waiting. The mutex must be held.
+func (limiter *internalRateLimiter) wake() {
+	close(limiter.changed)
+	limiter.changed = make(chan struct{})
+}
+
+// refill adds the tokens (bytes) earned since the last refill. The mutex must be held.
+func (limiter *internalRateLimiter) refill(now time.Time) {
+	limit := limiter.limit
+
+	limiter.tokens += now.Sub(limiter.last).Seconds() * float64(limit.BytesPerSecond)
+	if burst := float64(limit.Burst); burst < limiter.tokens {
+		limiter.tokens = burst
+	}
+	limiter.last = now
+}
+
+// refund gives back 'n' tokens, that were spent but not used; such as by a Read that read less
+// than it was allowed to.
+func (limiter *internalRateLimiter) refund(n int) {
+	if n <= 0 {
+		return
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if limiter.limit.BytesPerSecond <= 0 {
+		return
+	}
+	limiter.tokens += float64(n)
+	if burst := float64(limiter.limit.Burst); burst < limiter.tokens {
+		limiter.tokens = burst
+	}
+}
+
+// take spends (some of) the tokens available; waiting for there to be some, if there are not
+// (unless the limit is non-blocking, and 'wait' is false). It gives up waiting at the write
+// deadline, or at 'deadline' (if that is not zero), whichever comes first.
+//
+// 'fit' is given how many tokens are available (or -1 if there is no limit), and returns how
+// many of them to spend; 'need' is how many tokens to wait for, if 'fit' did not spend any.
+//
+// take returns how many tokens were spent. If it could not spend any, then it returns ErrWouldBlock
+// (if the limit is non-blocking), or a timeout error (if the deadline passed while waiting), or
+// ErrClosed (if the Conn was closed).
+func (limiter *internalRateLimiter) take(need int, deadline time.Time, wait bool, fit func(available int) int) (int, error) {
+	for {
+		limiter.mutex.Lock()
+		if limiter.closed {
+			limiter.mutex.Unlock()
+			return 0, ErrClosed
+		}
+		limit := limiter.limit
+		if limit.BytesPerSecond <= 0 {
+			limiter.mutex.Unlock()
+			return fit(-1), nil
+		}
+
+		now := time.Now()
+		limiter.refill(now)
+
+		if spent := fit(int(limiter.tokens)); 0 < spent {
+			limiter.tokens -= float64(spent)
+			limiter.mutex.Unlock()
+			return spent, nil
+		}
+
+		until, changed := limiter.deadline, limiter.changed
+		if until.IsZero() || (!deadline.IsZero() && deadline.Before(until)) {
+			until = deadline
+		}
+		if !until.IsZero() && !now.Before(until) {
+			limiter.mutex.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+		if limit.NonBlocking && !wait {
+			limiter.mutex.Unlock()
+			return 0, ErrWouldBlock
+		}
+
+		// Wait for enough tokens for 'need' (but never more than a burst's worth).
+		if limit.Burst < need {
+			need = limit.Burst
+		}
+		pause := time.Duration((float64(need) - limiter.tokens) / float64(limit.BytesPerSecond) * float64(time.Second))
+		if !until.IsZero() && until.Sub(now) < pause {
+			pause = until.Sub(now)
+		}
+		limiter.mutex.Unlock()
+
+		timer := time.NewTimer(pause)
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		}
+	}
+}
+
+// SetOutputRateLimit limits how fast the Conn sends data; with Write, and by a Broadcaster.
+// (The TELNET commands the Conn sends, such as to answer option negotiations, are not limited.)
+// For example:
+//
+//	conn.SetOutputRateLimit(telnet.RateLimit{
+//		BytesPerSecond: 2400,
+//		Burst:          8 * 1024,
+//	})
+//
+// With the (default) blocking RateLimit, Write waits for as long as it takes for what it is
+// writing to fit in the limit; or until the write deadline (see SetWriteDeadline), after which
+// it returns a timeout error (like os.ErrDeadlineExceeded). With a NonBlocking RateLimit,
+// Write writes as much as fits right now, and returns ErrWouldBlock if that was not all of it.
+//
+// The zero RateLimit removes the limit. The limit can be changed at any time.
+func (clientConn *Conn) SetOutputRateLimit(limit RateLimit) {
+	clientConn.dataWriter.limiter.set(limit)
+}
+
+// PendingOutput returns how many bytes (escaped, as they will go over the wire) are waiting to
+// be sent; i.e., what is being written by Writes that have not yet returned, which is held up
+// by the rate limit (see SetOutputRateLimit), or by the client not keeping up.
+//
+// This can be used to adapt to slow clients. For example:
+//
+//	if conn.PendingOutput() < 1024 {
+//		fmt.Fprintf(conn, "\r[%d%% done]", percent)
+//	}
+func (clientConn *Conn) PendingOutput() int {
+	return int(clientConn.dataWriter.pending.Load())
+}
+
+// SetWriteDeadline sets the deadline for writing to the Conn; after which a Write (that has
+// not yet returned) gives up, and returns a timeout error. This includes a Write that is waiting
+// on the rate limit (see SetOutputRateLimit).
+//
+// The zero time means no deadline.
+func (clientConn *Conn) SetWriteDeadline(t time.Time) error {
+	clientConn.dataWriter.limiter.setDeadline(t)
+
+	deadliner, ok := clientConn.conn.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return nil
+	}
+
+	return deadliner.SetWriteDeadline(t)
+}
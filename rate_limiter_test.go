@@ -0,0 +1,234 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testBufferConn is an (in memory) connection, that keeps what is written to it.
+type testBufferConn struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (*testBufferConn) Read(p []byte) (int, error) { return 0, net.ErrClosed }
+func (conn *testBufferConn) Write(p []byte) (int, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	return conn.buffer.Write(p)
+}
+func (*testBufferConn) Close() error         { return nil }
+func (*testBufferConn) LocalAddr() net.Addr  { return nil }
+func (*testBufferConn) RemoteAddr() net.Addr { return nil }
+
+func (conn *testBufferConn) String() string {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	return conn.buffer.String()
+}
+
+func TestConnOutputRateLimit(t *testing.T) {
+
+	var buffer testBufferConn
+	conn := newConn(&buffer, nil)
+
+	conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 1000, Burst: 100})
+
+	// The first 100 bytes go at once; the other 200 take (about) 200ms.
+	data := strings.Repeat("x", 300)
+
+	begin := time.Now()
+	n, err := conn.Write([]byte(data))
+	elapsed := time.Since(begin)
+
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := len(data), n; expected != actual {
+		t.Errorf("Expected %d bytes written, but actually got %d.", expected, actual)
+	}
+	if expected, actual := data, buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if elapsed < 150*time.Millisecond || time.Second < elapsed {
+		t.Errorf("Expected writing to take about 200ms, but actually took %v.", elapsed)
+	}
+}
+
+func TestConnOutputRateLimitNonBlocking(t *testing.T) {
+
+	tests := []struct {
+		Data          []byte
+		Burst         int
+		ExpectedN     int
+		ExpectedErr   error
+		ExpectedBytes []byte
+		ExpectedSpent bool
+	}{
+		{
+			Data:          []byte("apple"),
+			Burst:         10,
+			ExpectedN:     5,
+			ExpectedBytes: []byte("apple"),
+		},
+		{
+			Data:          []byte("apple banana cherry"),
+			Burst:         10,
+			ExpectedN:     10,
+			ExpectedErr:   ErrWouldBlock,
+			ExpectedBytes: []byte("apple bana"),
+			ExpectedSpent: true,
+		},
+		{
+			// Each IAC is 2 bytes, once escaped.
+			Data:          []byte{IAC, 'a', IAC, 'b'},
+			Burst:         4,
+			ExpectedN:     2,
+			ExpectedErr:   ErrWouldBlock,
+			ExpectedBytes: []byte{IAC, IAC, 'a'},
+		},
+		{
+			Data:          []byte{'a', IAC, IAC},
+			Burst:         4,
+			ExpectedN:     2,
+			ExpectedErr:   ErrWouldBlock,
+			ExpectedBytes: []byte{'a', IAC, IAC},
+		},
+		{
+			Data:          []byte{'a', 'b', IAC},
+			Burst:         4,
+			ExpectedN:     3,
+			ExpectedBytes: []byte{'a', 'b', IAC, IAC},
+			ExpectedSpent: true,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var buffer testBufferConn
+		conn := newConn(&buffer, nil)
+
+		conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 1, Burst: test.Burst, NonBlocking: true})
+
+		n, err := conn.Write(test.Data)
+		if expected, actual := test.ExpectedErr, err; expected != actual {
+			t.Errorf("For test #%d, expected error %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+		}
+		if expected, actual := test.ExpectedN, n; expected != actual {
+			t.Errorf("For test #%d, expected %d bytes written, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := string(test.ExpectedBytes), buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		// Once the budget is spent, nothing more can be written (for now).
+		if !test.ExpectedSpent {
+			continue
+		}
+		if n, err := conn.Write([]byte("x")); 0 != n || ErrWouldBlock != err {
+			t.Errorf("For test #%d, expected nothing to be written, but actually got: %d, (%T) %v", testNumber, n, err, err)
+		}
+	}
+}
+
+func TestConnOutputRateLimitDeadline(t *testing.T) {
+
+	var buffer testBufferConn
+	conn := newConn(&buffer, nil)
+
+	conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 10, Burst: 10})
+	conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+
+	begin := time.Now()
+	n, err := conn.Write([]byte(strings.Repeat("x", 100)))
+	elapsed := time.Since(begin)
+
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected a timeout, but actually got: (%T) %v", err, err)
+	}
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("Expected a timeout net.Error, but actually got: (%T) %v", err, err)
+	}
+	if n < 10 || 11 < n {
+		t.Errorf("Expected the burst (about 10 bytes) to be written, but actually got %d.", n)
+	}
+	if elapsed < 50*time.Millisecond || time.Second < elapsed {
+		t.Errorf("Expected writing to give up after about 100ms, but actually took %v.", elapsed)
+	}
+
+	// With the deadline gone, writing works again.
+	conn.SetWriteDeadline(time.Time{})
+	conn.SetOutputRateLimit(RateLimit{})
+	if n, err := conn.Write([]byte("done")); 4 != n || nil != err {
+		t.Errorf("Expected the write to work, but actually got: %d, (%T) %v", n, err, err)
+	}
+}
+
+func TestConnPendingOutput(t *testing.T) {
+
+	var buffer testBufferConn
+	conn := newConn(&buffer, nil)
+
+	conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 1, Burst: 2})
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		conn.Write([]byte{'a', 'b', IAC, 'c', 'd'})
+	}()
+
+	// (The first 2 bytes go at once.)
+	deadline := time.Now().Add(time.Second)
+	for 4 != conn.PendingOutput() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if expected, actual := 4, conn.PendingOutput(); expected != actual {
+		t.Errorf("Expected %d bytes pending, but actually got %d.", expected, actual)
+	}
+
+	// Lifting the limit lets what is waiting go.
+	conn.SetOutputRateLimit(RateLimit{})
+
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the write to be done, once the limit was lifted, but it was not.")
+	}
+
+	if expected, actual := 0, conn.PendingOutput(); expected != actual {
+		t.Errorf("Expected %d bytes pending, but actually got %d.", expected, actual)
+	}
+	if expected, actual := "ab\xff\xffcd", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestBroadcasterOutputRateLimit(t *testing.T) {
+
+	var buffer testBufferConn
+	conn := newConn(&buffer, nil)
+
+	// Even with a non-blocking limit, a broadcast waits for it.
+	conn.SetOutputRateLimit(RateLimit{BytesPerSecond: 100, Burst: 5, NonBlocking: true})
+
+	var broadcaster Broadcaster
+	broadcaster.Add(conn)
+	broadcaster.Printf("hello \xff\r\n")
+
+	expected := "hello \xff\xff\r\n"
+	deadline := time.Now().Add(time.Second)
+	for expected != buffer.String() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if actual := buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
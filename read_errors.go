@@ -0,0 +1,158 @@
+package telnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+var (
+	// ErrConnectionReset is what reading returns (wrapped around the error from the connection)
+	// when the connection was lost abnormally; i.e., it was reset by the peer (with a TCP RST),
+	// or aborted, or timed out (such as by TCP keepalives). (The error from the connection is
+	// still there to be gotten; i.e., errors.Is(err, syscall.ECONNRESET) is true for a reset.)
+	//
+	// (When the peer closes the connection cleanly, once it has sent everything, then reading
+	// returns io.EOF.)
+	ErrConnectionReset = errors.New("telnet: connection reset by peer")
+
+	// ErrIdleTimeout is what reading returns once the Conn has been closed for being idle; i.e.,
+	// with the CloseIdleTimeout reason (see CloseWithReason). It is ErrClosed; i.e.,
+	// errors.Is(err, ErrClosed) (and errors.Is(err, net.ErrClosed)) is true for it.
+	ErrIdleTimeout error = internalIdleTimeoutError{}
+
+	// ErrReadFilter is what reading returns (wrapped around the error from the ReadFilter) when
+	// a ReadFilter failed. (See AddReadFilter.)
+	ErrReadFilter = errors.New("telnet: read filter failed")
+
+	// ErrTruncatedCommand is what reading returns when the peer closed the connection part way
+	// through a TELNET command; such as after an IAC, or inside a subnegotiation.
+	ErrTruncatedCommand = errors.New("telnet: connection closed part way through a TELNET command")
+)
+
+type internalIdleTimeoutError struct{}
+
+func (internalIdleTimeoutError) Error() string {
+	return "telnet: connection closed for being idle"
+}
+
+func (internalIdleTimeoutError) Unwrap() error {
+	return ErrClosed
+}
+
+// internalResetError is ErrConnectionReset; wrapped around the error from the connection.
+type internalResetError struct {
+	err error
+}
+
+func (err internalResetError) Error() string {
+	return ErrConnectionReset.Error() + ": " + err.err.Error()
+}
+
+func (err internalResetError) Unwrap() error {
+	return err.err
+}
+
+func (err internalResetError) Is(target error) bool {
+	return ErrConnectionReset == target
+}
+
+// internalReadFilterError is ErrReadFilter; wrapped around the error from the ReadFilter.
+type internalReadFilterError struct {
+	err error
+}
+
+func (err internalReadFilterError) Error() string {
+	return ErrReadFilter.Error() + ": " + err.err.Error()
+}
+
+func (err internalReadFilterError) Unwrap() error {
+	return err.err
+}
+
+func (err internalReadFilterError) Is(target error) bool {
+	return ErrReadFilter == target
+}
+
+// connectionErr returns what reading from the connection returns, instead of 'err'; which is
+// ErrConnectionReset (wrapped around 'err') if the connection was lost abnormally.
+func connectionErr(err error) error {
+	switch {
+	case nil == err, io.EOF == err:
+		return err
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.ETIMEDOUT):
+		return internalResetError{err: err}
+	default:
+		return err
+	}
+}
+
+// truncatedErr returns ErrTruncatedCommand instead of io.EOF; for when the connection closed
+// part way through a TELNET command.
+func truncatedErr(err error) error {
+	if io.EOF == err {
+		return ErrTruncatedCommand
+	}
+	return err
+}
+
+// readErr returns what reading (TELNET data) returns, instead of 'err'. Once the Conn has been
+// closed that is ErrClosed (or ErrIdleTimeout, or a *DeadPeerError); otherwise it is 'err', which (unless it is a
+// timeout) is remembered as why the peer went away. (See CloseWithReason.)
+func (clientConn *Conn) readErr(err error) error {
+	switch err {
+	case nil, ErrInputFlood, ErrSubnegotiationFlood, ErrSubnegotiationTooLong:
+		return err
+	case errEOFCommand:
+		return io.EOF
+	}
+
+	if clientConn.isClosed() {
+		return clientConn.closedReadErr()
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return err
+	}
+
+	clientConn.closeMutex.Lock()
+	if nil == clientConn.peerErr {
+		clientConn.peerErr = err
+	}
+	clientConn.closeMutex.Unlock()
+
+	return err
+}
+
+// closedReadErr returns what reading returns once the Conn has been closed.
+func (clientConn *Conn) closedReadErr() error {
+	// (The close reason does not change once 'done' is closed; so the closeMutex is not needed.)
+	switch clientConn.closeReason {
+	case CloseIdleTimeout:
+		return ErrIdleTimeout
+	case CloseDeadPeer:
+		clientConn.keepaliveMutex.Lock()
+		defer clientConn.keepaliveMutex.Unlock()
+
+		if nil != clientConn.deadPeer {
+			return clientConn.deadPeer
+		}
+	}
+	return ErrClosed
+}
+
+// peerCloseReason returns why the connection was closed, when it was because reading from it
+// failed with 'err'.
+func peerCloseReason(err error) (CloseReason, string) {
+	switch {
+	case io.EOF == err:
+		return ClosePeerClosed, "peer closed the connection"
+	case errors.Is(err, ErrConnectionReset):
+		return CloseConnectionReset, err.Error()
+	case ErrTruncatedCommand == err, errors.Is(err, ErrProtocolViolation):
+		return CloseProtocolError, err.Error()
+	default:
+		return CloseUnknown, err.Error()
+	}
+}
@@ -0,0 +1,180 @@
+package telnet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"testing"
+)
+
+func TestConnReadErrors(t *testing.T) {
+
+	tests := []struct {
+		PeerSends      []byte
+		ExpectedData   string
+		ExpectedErr    error
+		ExpectedReason CloseReason
+	}{
+		{
+			PeerSends:      []byte("logout\r\n"),
+			ExpectedData:   "logout\r\n",
+			ExpectedErr:    io.EOF,
+			ExpectedReason: ClosePeerClosed,
+		},
+		{
+			PeerSends:      []byte{'a', 'b', IAC, NOP, 'c'},
+			ExpectedData:   "abc",
+			ExpectedErr:    io.EOF,
+			ExpectedReason: ClosePeerClosed,
+		},
+		{
+			PeerSends:      []byte{'a', 'b', IAC},
+			ExpectedData:   "ab",
+			ExpectedErr:    ErrTruncatedCommand,
+			ExpectedReason: CloseProtocolError,
+		},
+		{
+			PeerSends:      []byte{'a', 'b', IAC, WILL},
+			ExpectedData:   "ab",
+			ExpectedErr:    ErrTruncatedCommand,
+			ExpectedReason: CloseProtocolError,
+		},
+		{
+			PeerSends:      []byte{'a', 'b', IAC, SB, OptNAWS, 0, 80},
+			ExpectedData:   "ab",
+			ExpectedErr:    ErrTruncatedCommand,
+			ExpectedReason: CloseProtocolError,
+		},
+		{
+			PeerSends:      []byte{'a', 'b', IAC, SB, OptNAWS, 0, 80, 0, 24, IAC},
+			ExpectedData:   "ab",
+			ExpectedErr:    ErrTruncatedCommand,
+			ExpectedReason: CloseProtocolError,
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+		go io.Copy(io.Discard, remote)
+		go func() {
+			remote.Write(test.PeerSends)
+			remote.Close()
+		}()
+
+		conn := newConn(local, nil)
+
+		var data []byte
+		var err error
+		for nil == err {
+			var buffer [16]byte
+			var n int
+			n, err = conn.Read(buffer[:])
+			data = append(data, buffer[:n]...)
+		}
+
+		if expected, actual := test.ExpectedData, string(data); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedErr, err; expected != actual {
+			t.Errorf("For test #%d, expected %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+		}
+
+		conn.Close()
+		if expected, actual := test.ExpectedReason, testCloseReason(conn); expected != actual {
+			t.Errorf("For test #%d, expected close reason %v, but actually got %v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnReadConnectionReset(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		c, err := listener.Accept()
+		if nil != err {
+			return
+		}
+
+		// (Wait for the client, so that the connection is all the way up.)
+		var buffer [1]byte
+		c.Read(buffer[:])
+
+		// (Closing with SO_LINGER 0 has the connection reset, rather than closed.)
+		c.Write([]byte("hello"))
+		c.(*net.TCPConn).SetLinger(0)
+		c.Close()
+	}()
+
+	conn, err := DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	conn.conn.(*net.TCPConn).SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("x"))
+
+	time.Sleep(50 * time.Millisecond) // (So that the reset has arrived, before anything is read.)
+
+	var buffer [16]byte
+	for nil == err {
+		_, err = conn.Read(buffer[:])
+	}
+
+	if !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("Expected the error to be ErrConnectionReset, but actually was not: (%T) %v", err, err)
+	}
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected the error to (still) be ECONNRESET, but actually was not: (%T) %v", err, err)
+	}
+
+	conn.Close()
+	if expected, actual := CloseConnectionReset, testCloseReason(conn); expected != actual {
+		t.Errorf("Expected close reason %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestConnReadIdleTimeout(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go io.Copy(io.Discard, remote)
+
+	conn := newConn(local, nil)
+
+	errs := make(chan error, 1)
+	go func() {
+		var buffer [16]byte
+		_, err := conn.Read(buffer[:])
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	conn.CloseWithReason(CloseIdleTimeout, "idle for 5m0s")
+
+	// (Both what was reading, and what reads after.)
+	var buffer [16]byte
+	_, err := conn.Read(buffer[:])
+	for i, err := range []error{<-errs, err} {
+		if ErrIdleTimeout != err {
+			t.Errorf("For #%d, expected %v, but actually got: (%T) %v", i, ErrIdleTimeout, err, err)
+		}
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("For #%d, expected the error to be net.ErrClosed, but actually was not: (%T) %v", i, err, err)
+		}
+	}
+
+	if _, err := conn.Write([]byte("hello")); ErrClosed != err {
+		t.Errorf("Expected %v, but actually got: (%T) %v", ErrClosed, err, err)
+	}
+
+	if reason, msg := conn.CloseReason(); CloseIdleTimeout != reason || "idle for 5m0s" != msg {
+		t.Errorf("Expected the close reason to be kept, but actually got: %v %q", reason, msg)
+	}
+}
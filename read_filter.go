@@ -0,0 +1,178 @@
+package telnet
+
+// A ReadFilter transforms the data read from a Conn, before it is read (with Read, ReadLine,
+// Peek, ReadUntilPrompt, and the rest); such as to strip control characters (see StripControl),
+// to drop the padding some terminals send, or to map line-drawing characters. (See
+// Conn.AddReadFilter.)
+//
+// A ReadFilter is given the data as a stream; so it can keep state from one call of Read to the
+// next (such as for a sequence, that is split across them), and can hold back (the start of)
+// whatever it can not decide on yet, to return it from a later Read. (What is held back once
+// nothing more is received is never read.) For a filter that does not need to, see
+// ReadFilterFunc.
+//
+// A ReadFilter never sees (nor can it affect) TELNET commands, option negotiations, or
+// subnegotiations; it is only given the data that is left once they have been dealt with (and
+// that has been unescaped). It is given the data as it is to be read; i.e., as UTF-8, if the Conn
+// is transcoding (see SetFallbackTranscoding), after it is transcoded.
+//
+// (Its method is only called by 1 goroutine at a time.)
+type ReadFilter interface {
+	// Read returns what 'p' turns into. It must not keep 'p' (after it returns); but what it
+	// returns can be 'p' itself (changed, or not).
+	//
+	// If it returns an error, then reading returns ErrReadFilter (wrapped around it); and what
+	// 'p' was is dropped. (The Conn can still be read from, after that.)
+	Read(p []byte) ([]byte, error)
+}
+
+// ReadFilterFunc adapts a (stateless) function into a ReadFilter. For example:
+//
+//	conn.AddReadFilter(telnet.ReadFilterFunc(func(p []byte) ([]byte, error) {
+//		return bytes.ReplaceAll(p, []byte{0x7f}, nil), nil
+//	}))
+type ReadFilterFunc func(p []byte) ([]byte, error)
+
+// Read calls the function.
+func (fn ReadFilterFunc) Read(p []byte) ([]byte, error) {
+	return fn(p)
+}
+
+// internalReadFilter is a ReadFilter that was added (so it can be removed; even if the same
+// ReadFilter was added more than once).
+type internalReadFilter struct {
+	filter ReadFilter
+}
+
+// AddReadFilter has the data read from the Conn (see Read) be passed through 'filter' first;
+// after the ReadFilters added before it. It returns a function that removes it again. For
+// example:
+//
+//	remove := conn.AddReadFilter(telnet.StripControl())
+//	defer remove()
+//
+// It applies to what is received after it is added (but not to what has been read, and not
+// returned yet, such as by Peek). (Calling the function more than once does nothing more.)
+func (clientConn *Conn) AddReadFilter(filter ReadFilter) (remove func()) {
+	added := &internalReadFilter{filter: filter}
+
+	clientConn.readFilterMutex.Lock()
+	clientConn.readFilters = append(clientConn.readFilters, added)
+	clientConn.readFilterMutex.Unlock()
+
+	return func() {
+		clientConn.removeReadFilter(added)
+	}
+}
+
+func (clientConn *Conn) removeReadFilter(removed *internalReadFilter) {
+	clientConn.readFilterMutex.Lock()
+	defer clientConn.readFilterMutex.Unlock()
+
+	for k, added := range clientConn.readFilters {
+		if removed == added {
+			clientConn.readFilters = append(clientConn.readFilters[:k], clientConn.readFilters[k+1:]...)
+			return
+		}
+	}
+}
+
+// hasReadFilters returns whether there are ReadFilters; or what they returned, that has not been
+// read yet. (The readMutex must be held.)
+func (clientConn *Conn) hasReadFilters() bool {
+	if 0 < len(clientConn.filtered) {
+		return true
+	}
+
+	clientConn.readFilterMutex.Lock()
+	defer clientConn.readFilterMutex.Unlock()
+
+	return 0 < len(clientConn.readFilters)
+}
+
+// readFiltered reads (TELNET) data; transcoded to UTF-8 (if it is being), and passed through the
+// ReadFilters. (The readMutex must be held.)
+func (clientConn *Conn) readFiltered(p []byte) (n int, err error) {
+	if 0 < len(clientConn.filtered) {
+		n = copy(p, clientConn.filtered)
+		clientConn.filtered = clientConn.filtered[n:]
+		if len(clientConn.filtered) <= 0 {
+			clientConn.filtered = nil
+		}
+		return n, nil
+	}
+
+	for {
+		n, err = clientConn.readTranscodedData(p)
+
+		data, filterErr := clientConn.filterRead(p[:n])
+		if nil != filterErr {
+			return 0, internalReadFilterError{err: filterErr}
+		}
+
+		// (What does not fit is read next time.)
+		if len(p) < len(data) {
+			clientConn.filtered = append(clientConn.filtered, data[len(p):]...)
+		}
+		n = copy(p, data)
+
+		// (If the ReadFilters dropped all of it, then there is more to read.)
+		if 0 < n || nil != err || 0 == len(p) {
+			return n, err
+		}
+	}
+}
+
+// filterRead passes 'p' through the ReadFilters; in order.
+func (clientConn *Conn) filterRead(p []byte) ([]byte, error) {
+	clientConn.readFilterMutex.Lock()
+	defer clientConn.readFilterMutex.Unlock()
+
+	for _, added := range clientConn.readFilters {
+		if 0 == len(p) {
+			break
+		}
+
+		var err error
+		if p, err = added.filter.Read(p); nil != err {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// StripControl returns a ReadFilter that removes (ASCII) control characters from the data; i.e.,
+// the bytes 0 to 31 (such as NUL), and 127 (DEL); except for CR, LF, and TAB, and those in 'keep'.
+// For example:
+//
+//	conn.AddReadFilter(telnet.StripControl())
+//
+// ... or, to keep backspace (and DEL; which some terminals send for backspace):
+//
+//	conn.AddReadFilter(telnet.StripControl('\b', 0x7f))
+//
+// (The bytes of UTF-8 encoded characters are never removed; as they are all above 127.)
+func StripControl(keep ...byte) ReadFilter {
+	var kept [128]bool
+	kept['\r'], kept['\n'], kept['\t'] = true, true, true
+	for _, b := range keep {
+		if int(b) < len(kept) {
+			kept[b] = true
+		}
+	}
+
+	return ReadFilterFunc(func(p []byte) ([]byte, error) {
+		// (What is kept is never more than what has been looked at; so 'p' is filtered in place.)
+		n := 0
+		for _, b := range p {
+			if (b < 0x20 || 0x7f == b) && !kept[b] {
+				continue
+			}
+			p[n] = b
+			n++
+		}
+
+		return p[:n], nil
+	})
+}
@@ -0,0 +1,215 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+
+	"testing"
+)
+
+// testReadFilterConn returns a Conn (that is not read from), with 'sent' sent to it by the peer.
+func testReadFilterConn(t *testing.T, sent []byte) *Conn {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	go func() {
+		remote.Write(sent)
+		remote.Close()
+	}()
+
+	return newConn(local, nil)
+}
+
+// testSeenFilter keeps what it is given; and swaps each "ab" for "ba", even when it is split
+// across calls of Read (by holding back an "a" at the end).
+type testSeenFilter struct {
+	seen []byte
+	held bool
+}
+
+func (filter *testSeenFilter) Read(p []byte) ([]byte, error) {
+	filter.seen = append(filter.seen, p...)
+
+	var data []byte
+	if filter.held {
+		data = append(data, 'a')
+		filter.held = false
+	}
+	data = append(data, p...)
+
+	data = bytes.ReplaceAll(data, []byte("ab"), []byte("ba"))
+	if 0 < len(data) && 'a' == data[len(data)-1] {
+		data = data[:len(data)-1]
+		filter.held = true
+	}
+
+	return data, nil
+}
+
+func TestStripControl(t *testing.T) {
+
+	tests := []struct {
+		Keep     []byte
+		Data     string
+		Expected string
+	}{
+		{
+			Data:     "plain text\r\n",
+			Expected: "plain text\r\n",
+		},
+		{
+			Data:     "a\x00b\x7fc\x01\x1bd\te\b\r\n",
+			Expected: "abcd\te\r\n",
+		},
+		{
+			Keep:     []byte{'\b', 0x7f},
+			Data:     "ab\bc\x7f\x00\r\n",
+			Expected: "ab\bc\x7f\r\n",
+		},
+		{
+			Data:     "caf\xc3\xa9 \xe2\x9b\x84\x00",
+			Expected: "caf\xc3\xa9 \xe2\x9b\x84",
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual, err := StripControl(test.Keep...).Read([]byte(test.Data))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected := test.Expected; expected != string(actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnAddReadFilter(t *testing.T) {
+
+	conn := testReadFilterConn(t, []byte{'x', 'a', IAC, NOP, 'b', 0, IAC, IAC, '\r', '\n', 'a'})
+
+	filter := &testSeenFilter{}
+	conn.AddReadFilter(StripControl())
+	conn.AddReadFilter(filter)
+
+	// (With a buffer of 1 byte; so the "a", and the "b", come in different calls of Read.)
+	var actual []byte
+	p := make([]byte, 1)
+	for {
+		n, err := conn.Read(p)
+		actual = append(actual, p[:n]...)
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+
+	if expected := []byte{'x', 'b', 'a', IAC, '\r', '\n'}; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (The filters never see the TELNET commands; nor what the filters before them removed.)
+	if expected, actual := []byte{'x', 'a', 'b', IAC, '\r', '\n', 'a'}, filter.seen; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the filter to see %q, but actually it saw %q.", expected, actual)
+	}
+}
+
+func TestConnAddReadFilterExpands(t *testing.T) {
+
+	conn := testReadFilterConn(t, []byte("ab\x00c\r\nnext\r\n"))
+
+	conn.AddReadFilter(StripControl())
+	conn.AddReadFilter(ReadFilterFunc(func(p []byte) ([]byte, error) {
+		return bytes.ReplaceAll(p, []byte("b"), []byte("[bee]")), nil
+	}))
+
+	for _, expected := range []string{"a[bee]c", "next"} {
+		actual, err := conn.ReadLine()
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		if expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	}
+}
+
+func TestConnReadFilterError(t *testing.T) {
+
+	conn := testReadFilterConn(t, []byte("good\x07bad!"))
+
+	errBell := errors.New("bell")
+	conn.AddReadFilter(ReadFilterFunc(func(p []byte) ([]byte, error) {
+		if bytes.IndexByte(p, 0x07) >= 0 {
+			return nil, errBell
+		}
+		return p, nil
+	}))
+
+	var actual []byte
+	var errs []error
+	p := make([]byte, 4)
+	for {
+		n, err := conn.Read(p)
+		actual = append(actual, p[:n]...)
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			errs = append(errs, err)
+			continue
+		}
+	}
+
+	// (What the filter failed on is dropped; but what came after it is still read.)
+	if expected := "good!"; expected != string(actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := 1, len(errs); expected != actual {
+		t.Fatalf("Expected %d error, but actually got %d: %v", expected, actual, errs)
+	}
+	if !errors.Is(errs[0], ErrReadFilter) {
+		t.Errorf("Expected the error to be ErrReadFilter, but actually got: (%T) %v", errs[0], errs[0])
+	}
+	if !errors.Is(errs[0], errBell) {
+		t.Errorf("Expected the error to wrap the filter's, but actually got: (%T) %v", errs[0], errs[0])
+	}
+}
+
+func TestConnRemoveReadFilter(t *testing.T) {
+
+	conn := testReadFilterConn(t, []byte("a\x00bc\x00d"))
+
+	remove := conn.AddReadFilter(StripControl())
+
+	p := make([]byte, 3)
+	n, err := conn.Read(p)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "ab", string(p[:n]); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	remove()
+	remove()
+
+	rest, err := io.ReadAll(conn)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "c\x00d", string(rest); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
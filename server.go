@@ -1,8 +1,13 @@
 package telnet
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
+	"sync"
+	"time"
 )
 
 // ListenAndServe listens on the TCP network address `addr` and then spawns a call to the ServeTELNET
@@ -27,19 +32,19 @@ import (
 //			panic(err)
 //		}
 //	}
+//
+// (It is NewServer, with WithHandler; see NewServer for more options.)
 func ListenAndServe(addr string, handler Handler) error {
-	server := &Server{Addr: addr, Handler: handler}
-	return server.ListenAndServe()
+	return NewServer(addr, WithHandler(handler)).ListenAndServe()
 }
 
 // Serve accepts an incoming TELNET or TELNETS client connection on the net.Listener `listener`.
 func Serve(listener net.Listener, handler Handler) error {
-
-	server := &Server{Handler: handler}
-	return server.Serve(listener)
+	return NewServer("", WithHandler(handler)).Serve(listener)
 }
 
-// A Server defines parameters of a running TELNET server.
+// A Server defines parameters of a running TELNET server. (A Server can also be made with
+// NewServer; and Options.)
 //
 // For a simple example:
 //
@@ -68,9 +73,142 @@ type Server struct {
 	Addr    string  // TCP address to listen on; ":telnet" or ":telnets" if empty (when used with ListenAndServe or ListenAndServeTLS respectively).
 	Handler Handler // handler to invoke; telnet.EchoServer if nil
 
+	// ContextHandler, if not nil, is invoked instead of Handler. (See ContextHandler.)
+	ContextHandler ContextHandler
+
 	TLSConfig *tls.Config // optional TLS configuration; used by ListenAndServeTLS.
 
+	// OptionPolicy determines how connections answer a client that asks for (i.e., sends a DO for)
+	// or offers (i.e., sends a WILL for) an option that is not otherwise supported.
+	//
+	// The default (OptionPolicyRefuse) answers every DO with a WONT, and every WILL with a DONT.
+	OptionPolicy OptionPolicy
+
+	// OptionPolicies optionally overrides OptionPolicy for individual options.
+	OptionPolicies map[byte]OptionPolicy
+
+	// OptionHandlers (if not nil) are the options registered on each connection; before it is
+	// handed to the handler. (See OptionHandlers.)
+	OptionHandlers OptionHandlers
+
+	// OnConnect, if not nil, is called for each connection; once it has been set up (with
+	// everything else here), but before the Banner is sent, and before it is handed to the handler.
+	OnConnect func(conn *Conn)
+
+	// OnNegotiationComplete, if not nil, is called for each connection once the option
+	// negotiations that were started when it was set up (such as by the OptionHandlers) have all
+	// been settled. (See Conn.WaitForNegotiations.) It is called from a goroutine of its own; while
+	// the handler is running, as the client's answers are only received while the connection is
+	// being read. (It is not called if the connection is closed first.)
+	OnNegotiationComplete func(conn *Conn)
+
+	// OnDisconnect, if not nil, is called after each connection has been closed, with
+	// why it was closed (see Conn.CloseWithReason). It is called for every connection accepted;
+	// including one that is turned away, one whose TLS handshake fails (with
+	// CloseTLSHandshakeFailed), and one whose handler panics (with CloseHandlerPanic).
+	OnDisconnect func(conn *Conn, reason CloseReason, msg string)
+
+	// InputLimits are the limits on what each client can send. A client that exceeds them is
+	// disconnected (with CloseLineTooLong, CloseSubnegotiationFlood, CloseSubnegotiationTooLong, or
+	// CloseInputFlood as the reason given to OnDisconnect); and each time a limit is exceeded is counted (see InputViolations).
+	//
+	// The default (the zero InputLimits) is no limits. See DefaultInputLimits.
+	InputLimits InputLimits
+
+	// ConnectionLimits are how many connections are served at once; in all, and from each IP
+	// address. A connection that is over them is turned away as soon as it is accepted (with
+	// ClosePolicyRejected as the reason given to OnDisconnect). (See ConnectionLimits.)
+	//
+	// The default (the zero ConnectionLimits) is no limits.
+	ConnectionLimits ConnectionLimits
+
+	// InputRateLimit, if not zero, is how fast each connection is read from; a client that sends
+	// faster than it is throttled (rather than disconnected; unlike with the MaxBytesPerSecond of
+	// the InputLimits). (See Conn.SetInputRateLimit.)
+	InputRateLimit RateLimit
+
+	// OnLimit, if not nil, is called each time a connection hits one of the ConnectionLimits (once
+	// it has been turned away), or the InputRateLimit (each time reading from it had to wait);
+	// with the address it is from, and which limit it was. It is called from the goroutine that
+	// accepts the connections (for the ConnectionLimits), or from whatever goroutine is reading
+	// from the connection (for the InputRateLimit); so it should not take long.
+	OnLimit func(remoteAddr net.Addr, limit ServerLimit)
+
+	// SocketOptions are the (operating system level) options, such as TCP keepalives, set on
+	// each (TCP) connection accepted.
+	SocketOptions SocketOptions
+
+	// FallbackCharset, if not nil, is the charset that each connection's data is transcoded from
+	// (and to), while no charset has been negotiated; for (legacy) clients that never negotiate
+	// CHARSET, but send (say) Latin-1. (See Conn.SetFallbackTranscoding.) For example:
+	//
+	//	server.FallbackCharset = telnet.Latin1
+	FallbackCharset Charset
+
+	// NewlinePolicy is what each connection does with the newlines (and carriage returns) in its
+	// data, while it is not BINARY. (See Conn.SetNewlinePolicy.) The default (NewlineRaw) leaves
+	// the data as it is.
+	NewlinePolicy NewlinePolicy
+
+	// ParseMode is what reading from each connection does with what the client sends that is not
+	// TELNET. (See Conn.SetParseMode.) The default (ParseStrict) has it be an error.
+	ParseMode ParseMode
+
+	// WriteCoalescing, if not zero, is how long what is written to each connection can be held
+	// back for; so that a burst of small writes is sent together. (See Conn.SetWriteCoalescing.)
+	WriteCoalescing time.Duration
+
+	// OutputPolicy, if not the zero OutputPolicy, is how what is written to each connection is
+	// buffered, and flushed; and what is done once the client can not keep up. (See
+	// Conn.SetOutputPolicy.) It replaces the WriteCoalescing.
+	OutputPolicy OutputPolicy
+
+	// WriteTimeout, if not zero, is how long sending what is written to each connection (i.e.,
+	// each flush of it) can take; after which the connection is closed (with CloseSlowClient as
+	// the reason given to OnDisconnect). So that a client that stops reading cannot block a
+	// handler forever. (See Conn.SetWriteTimeout.)
+	WriteTimeout time.Duration
+
+	// NegotiationTimeout, if not zero, is how long each connection waits for the client to answer
+	// an option negotiation it started. (See Conn.SetNegotiationTimeout.)
+	NegotiationTimeout time.Duration
+
+	// SessionTimeouts are how long each connection can be idle for, and can last; after which it
+	// is closed (with CloseIdleTimeout, or CloseSessionTimeout, as the reason given to
+	// OnDisconnect). A handler can override them for its own connection. (See
+	// Conn.SetSessionTimeouts.)
+	SessionTimeouts SessionTimeouts
+
+	// Banner, if not empty, is sent to each client; once its connection has been set up, but
+	// before it is handed to the handler.
+	Banner string
+
+	// AuthHandler, if not nil, has each client log in (after the Banner is sent); before it is
+	// handed to the handler. A client that does not log in is disconnected (with CloseAuthFailed
+	// as the reason given to OnDisconnect). (See AuthHandler.)
+	AuthHandler *AuthHandler
+
+	// Trace (if not nil) has its hooks called for each connection; and Metrics (if not nil) is
+	// told of each. (See Trace, and Metrics.)
+	Trace   *Trace
+	Metrics Metrics
+
+	// WorkerPool, if not nil, has the connections served by a (bounded) pool of goroutines; rather
+	// than with a goroutine for each connection (which is the default). (See PoolStats.)
+	WorkerPool *WorkerPool
+
 	Logger Logger
+
+	violations internalInputViolations
+
+	// connections counts the connections (for the ConnectionLimits).
+	connections internalConnCounter
+
+	poolMutex sync.Mutex
+	pool      *internalWorkerPool
+
+	// (See Shutdown.)
+	lifecycle internalLifecycle
 }
 
 // ListenAndServe listens on the TCP network address 'server.Addr' and then spawns a call to the ServeTELNET
@@ -106,7 +244,7 @@ func (server *Server) ListenAndServe() error {
 		addr = ":telnet"
 	}
 
-	listener, err := net.Listen("tcp", addr)
+	listener, err := net.Listen("tcp", resolvePort(addr))
 	if nil != err {
 		return err
 	}
@@ -119,14 +257,33 @@ func (server *Server) Serve(listener net.Listener) error {
 
 	defer listener.Close()
 
+	if !server.lifecycle.addListener(listener) {
+		return ErrServerClosed
+	}
+	defer server.lifecycle.removeListener(listener)
+
 	logger := server.logger()
 
-	handler := server.Handler
+	handler := server.ContextHandler
 	if nil == handler {
-		//@TODO: Should this be a "ShellHandler" instead, that gives a shell-like experience by default
-		//       If this is changd, then need to change the comment in the "type Server struct" definition.
-		logger.Debug("Defaulted handler to EchoHandler.")
-		handler = EchoHandler
+		oldHandler := server.Handler
+		if nil == oldHandler {
+			//@TODO: Should this be a "ShellHandler" instead, that gives a shell-like experience by default
+			//       If this is changd, then need to change the comment in the "type Server struct" definition.
+			logger.Debug("Defaulted handler to EchoHandler.")
+			oldHandler = EchoHandler
+		}
+		handler = AdaptHandler(oldHandler)
+	}
+
+	var pool *internalWorkerPool
+	if nil != server.WorkerPool {
+		pool = newWorkerPool(*server.WorkerPool, server, handler)
+		defer pool.close()
+
+		server.poolMutex.Lock()
+		server.pool = pool
+		server.poolMutex.Unlock()
 	}
 
 	for {
@@ -134,10 +291,28 @@ func (server *Server) Serve(listener net.Listener) error {
 		logger.Debugf("Listening at %q.", listener.Addr())
 		conn, err := listener.Accept()
 		if err != nil {
+			if server.lifecycle.shuttingDown() {
+				return ErrServerClosed
+			}
 			//@TODO: Could try to recover from certain kinds of errors. Maybe waiting a while before trying again.
 			return err
 		}
 		logger.Debugf("Received new connection from %q.", conn.RemoteAddr())
+		server.Trace.accepted(conn.RemoteAddr())
+
+		if !server.admit(conn) {
+			continue
+		}
+
+		if err := server.SocketOptions.apply(conn, true); nil != err {
+			logger.Warnf("Problem setting socket options of connection from %q: %v", conn.RemoteAddr(), err)
+		}
+
+		if nil != pool {
+			pool.dispatch(conn)
+			logger.Debugf("Dispatched connection from %q to the worker pool.", conn.RemoteAddr())
+			continue
+		}
 
 		// Handle the new TELNET client connection by spawning
 		// a new goroutine.
@@ -146,7 +321,8 @@ func (server *Server) Serve(listener net.Listener) error {
 	}
 }
 
-func (server *Server) handle(c net.Conn, handler Handler) {
+func (server *Server) handle(c net.Conn, handler ContextHandler) {
+	defer server.connections.release(c.RemoteAddr())
 	defer c.Close()
 
 	logger := server.logger()
@@ -159,13 +335,155 @@ func (server *Server) handle(c net.Conn, handler Handler) {
 		}
 	}()
 
-	var ctx Context = NewContext().InjectLogger(logger)
+	if err := handshake(c); nil != err {
+		logger.Debugf("Problem with the TLS handshake of connection from %q: %v", c.RemoteAddr(), err)
+		server.handshakeFailed(c, err)
+		return
+	}
+
+	conn := newConn(c, logger)
+	admitted := server.lifecycle.addConn(conn)
+
+	// (However the session ends; including if the handler (or a hook) panics. So that the Conn is
+	// always closed, and torn down; and OnDisconnect, the Trace, and the Metrics, are told of it.)
+	cancel := func() {}
+	defer func() {
+		if admitted {
+			defer server.lifecycle.removeConn(conn)
+		}
+
+		if r := recover(); nil != r {
+			logger.Errorf("Recovered from: (%T) %v", r, r)
+			conn.CloseWithReason(CloseHandlerPanic, fmt.Sprintf("panic: %v", r))
+		}
+		cancel()
+		conn.Close()
+
+		server.disconnected(conn)
+	}()
+
+	if !admitted {
+		// (So that the Trace, the Metrics, and OnDisconnect are told of it; like of any other.)
+		logger.Debugf("Rejected connection from %q; the server is shutting down.", c.RemoteAddr())
+		conn.instrument(server.Trace, server.Metrics)
+		conn.CloseWithReason(CloseServerShutdown, "server shutting down")
+		return
+	}
+
+	conn.SetDefaultOptionPolicy(server.OptionPolicy)
+	for option, policy := range server.OptionPolicies {
+		conn.SetOptionPolicy(option, policy)
+	}
+	conn.SetInputLimits(server.InputLimits)
+	conn.onViolation = server.violations.count
+	if nil != server.FallbackCharset {
+		conn.SetFallbackCharset(server.FallbackCharset)
+		conn.SetFallbackTranscoding(true)
+	}
+	if NewlineRaw != server.NewlinePolicy {
+		conn.SetNewlinePolicy(server.NewlinePolicy)
+	}
+	if ParseStrict != server.ParseMode {
+		conn.SetParseMode(server.ParseMode)
+	}
+	if 0 < server.WriteCoalescing {
+		conn.SetWriteCoalescing(server.WriteCoalescing, 0)
+	}
+	if 0 < server.InputRateLimit.BytesPerSecond {
+		conn.SetInputRateLimit(server.InputRateLimit)
+		if nil != server.OnLimit {
+			conn.inputThrottle.throttled = func() {
+				server.limited(conn.RemoteAddr(), LimitInputRate)
+			}
+		}
+	}
+	if (OutputPolicy{}) != server.OutputPolicy {
+		conn.SetOutputPolicy(server.OutputPolicy)
+	}
+	if 0 < server.WriteTimeout {
+		conn.SetWriteTimeout(server.WriteTimeout)
+	}
+	if 0 < server.NegotiationTimeout {
+		conn.SetNegotiationTimeout(server.NegotiationTimeout)
+	}
+	if timeouts := server.SessionTimeouts; 0 < timeouts.IdleTimeout || 0 < timeouts.MaxSessionDuration {
+		conn.SetSessionTimeouts(timeouts)
+	}
+	conn.instrument(server.Trace, server.Metrics)
+
+	if err := server.OptionHandlers.register(conn); nil != err {
+		logger.Debugf("Problem registering the options of connection from %q: %v", conn.RemoteAddr(), err)
+	}
+
+	if fn := server.OnNegotiationComplete; nil != fn {
+		go func() {
+			if err := conn.WaitForNegotiations(context.Background()); nil == err {
+				fn(conn)
+			}
+		}()
+	}
+	if fn := server.OnConnect; nil != fn {
+		fn(conn)
+	}
+
+	if "" != server.Banner {
+		if _, err := conn.Write([]byte(server.Banner)); nil != err {
+			logger.Debugf("Problem sending the banner to %q: %v", conn.RemoteAddr(), err)
+		}
+	}
 
-	var w Writer = newDataWriter(c)
-	var r Reader = newDataReader(c)
+	var ctx context.Context
+	ctx, cancel = conn.withContext(context.Background())
 
-	handler.ServeTELNET(ctx, w, r)
-	c.Close()
+	if auth := server.AuthHandler; nil != auth {
+		user, err := auth.login(ctx, conn)
+		if nil != err {
+			logger.Debugf("Connection from %q did not log in: %v", conn.RemoteAddr(), err)
+			switch {
+			case errors.Is(err, ErrAuthFailed):
+				conn.CloseWithReason(CloseAuthFailed, "too many tries")
+			case isTimeout(err):
+				conn.CloseWithReason(CloseAuthFailed, "timed out")
+			default:
+				conn.Close()
+			}
+			return
+		}
+		conn.setUser(user)
+	}
+
+	handler.ServeTELNET(ctx, conn)
+}
+
+// reject sends 'c' the 'banner', and closes it (with ClosePolicyRejected, and 'msg').
+func (server *Server) reject(c net.Conn, banner string, msg string) {
+	// (So that the Trace, the Metrics, and OnDisconnect are told of it; like of any other.)
+	conn := newConn(c, server.logger())
+	conn.instrument(server.Trace, server.Metrics)
+
+	c.SetWriteDeadline(time.Now().Add(closeFlushTimeout))
+	conn.Write([]byte(banner))
+	conn.CloseWithReason(ClosePolicyRejected, msg)
+
+	server.disconnected(conn)
+}
+
+// handshakeFailed closes 'c'; whose TLS handshake failed (with 'err').
+func (server *Server) handshakeFailed(c net.Conn, err error) {
+	// (So that the Trace, the Metrics, and OnDisconnect are told of it; like of any other.)
+	conn := newConn(c, server.logger())
+	conn.instrument(server.Trace, server.Metrics)
+	conn.CloseWithReason(CloseTLSHandshakeFailed, err.Error())
+
+	server.disconnected(conn)
+}
+
+// disconnected calls OnDisconnect (if it is not nil) for 'conn'; which has been closed.
+func (server *Server) disconnected(conn *Conn) {
+	if fn := server.OnDisconnect; nil != fn {
+		reason, msg := conn.CloseReason()
+		fn(conn, reason, msg)
+	}
 }
 
 func (server *Server) logger() Logger {
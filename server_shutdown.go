@@ -0,0 +1,157 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrServerClosed is returned by Serve (and ListenAndServe, and ListenAndServeTLS) once the Server
+// has been shut down. (See Shutdown.)
+var ErrServerClosed = errors.New("telnet: server closed")
+
+// Shutdown shuts the Server down gracefully. For example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//
+//	if err := server.Shutdown(ctx); nil != err {
+//		//@TODO: Some of the sessions had to be cut off.
+//	}
+//
+// Shutdown:
+//
+//  1. stops the Server from accepting any more connections (by closing its listeners; so that
+//     Serve returns ErrServerClosed),
+//  2. waits for the sessions that are in progress to finish (i.e., for their handlers to return),
+//  3. and, if 'ctx' is done first, closes the connections of those that have not (with
+//     CloseServerShutdown as the reason given to OnDisconnect); waits for their handlers to
+//     return; and returns the context's error.
+//
+// (So, to close the connections right away, give it a context that is already done.) Once the
+// Server has been shut down, it cannot be used again.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.logger().Debug("Shutting down.")
+
+	err := server.lifecycle.shutdown()
+
+	finished := make(chan struct{})
+	go func() {
+		server.lifecycle.sessions.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return err
+	case <-ctx.Done():
+	}
+
+	for _, conn := range server.lifecycle.remaining() {
+		conn.CloseWithReason(CloseServerShutdown, "server shutting down")
+	}
+	<-finished
+
+	return ctx.Err()
+}
+
+// internalLifecycle keeps track of the listeners, and the connections, of a Server; so that it can
+// be shut down. (See Server.Shutdown.)
+type internalLifecycle struct {
+	mutex     sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+
+	// sessions counts the connections that are being handled. (It is only added to while the
+	// mutex is held, and before closed is set; so not once it is being waited on.)
+	sessions sync.WaitGroup
+}
+
+// addListener keeps track of 'listener'; unless the Server has been shut down, in which case it
+// returns false.
+func (lifecycle *internalLifecycle) addListener(listener net.Listener) bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	if lifecycle.closed {
+		return false
+	}
+	if nil == lifecycle.listeners {
+		lifecycle.listeners = map[net.Listener]struct{}{}
+	}
+	lifecycle.listeners[listener] = struct{}{}
+	return true
+}
+
+func (lifecycle *internalLifecycle) removeListener(listener net.Listener) {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	delete(lifecycle.listeners, listener)
+}
+
+// addConn keeps track of 'conn' (as a session in progress); unless the Server has been shut down,
+// in which case it returns false.
+func (lifecycle *internalLifecycle) addConn(conn *Conn) bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	if lifecycle.closed {
+		return false
+	}
+	if nil == lifecycle.conns {
+		lifecycle.conns = map[*Conn]struct{}{}
+	}
+	lifecycle.conns[conn] = struct{}{}
+	lifecycle.sessions.Add(1)
+	return true
+}
+
+// removeConn stops keeping track of 'conn'; once its session has finished.
+func (lifecycle *internalLifecycle) removeConn(conn *Conn) {
+	lifecycle.mutex.Lock()
+	delete(lifecycle.conns, conn)
+	lifecycle.mutex.Unlock()
+
+	lifecycle.sessions.Done()
+}
+
+func (lifecycle *internalLifecycle) shuttingDown() bool {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	return lifecycle.closed
+}
+
+// shutdown marks the Server as shut down; and closes its listeners. It returns the first error
+// closing them returned.
+func (lifecycle *internalLifecycle) shutdown() error {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	lifecycle.closed = true
+
+	var err error
+	for listener := range lifecycle.listeners {
+		if closeErr := listener.Close(); nil == err {
+			err = closeErr
+		}
+	}
+	lifecycle.listeners = nil
+
+	return err
+}
+
+// remaining returns the connections whose sessions are (still) in progress.
+func (lifecycle *internalLifecycle) remaining() []*Conn {
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	conns := make([]*Conn, 0, len(lifecycle.conns))
+	for conn := range lifecycle.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
@@ -0,0 +1,245 @@
+package telnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testShutdownServer serves 'server' (in the background) on a listener of its own; and returns
+// the address to connect to, and a channel that what Serve returned is sent to.
+func testShutdownServer(t *testing.T, server *Server) (string, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	return listener.Addr().String(), served
+}
+
+func TestServerShutdownWaitsForSessions(t *testing.T) {
+
+	started := make(chan struct{}, 1)
+	server := &Server{
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			started <- struct{}{}
+
+			// (The session finishes once the client says so.)
+			p := make([]byte, 1)
+			conn.Read(p)
+		}),
+	}
+	addr, served := testShutdownServer(t, server)
+
+	client, err := net.Dial("tcp", addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-served:
+		if expected, actual := ErrServerClosed, err; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected Serve to return, but actually it did not.")
+	}
+
+	if _, err := net.DialTimeout("tcp", addr, time.Second); nil == err {
+		t.Errorf("Expected connecting to fail (once the server was shut down), but actually it did not.")
+	}
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Expected Shutdown to wait for the session, but actually it returned: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client.Write([]byte("x"))
+	select {
+	case err := <-shutdown:
+		if nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected Shutdown to return, but actually it did not.")
+	}
+
+	if expected, actual := ErrServerClosed, server.Serve(testListen(t, func(net.Conn) {})); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestServerShutdownCutsOffSessions(t *testing.T) {
+
+	started := make(chan struct{}, 1)
+	disconnected := make(chan CloseReason, 1)
+	server := &Server{
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			started <- struct{}{}
+			io.Copy(io.Discard, conn)
+		}),
+		OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+			disconnected <- reason
+		},
+	}
+	addr, served := testShutdownServer(t, server)
+
+	client, err := net.Dial("tcp", addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if expected, actual := context.DeadlineExceeded, server.Shutdown(ctx); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+	if expected, actual := ErrServerClosed, <-served; expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	select {
+	case reason := <-disconnected:
+		if expected, actual := CloseServerShutdown, reason; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnDisconnect to be called, but actually it was not.")
+	}
+
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.Copy(io.Discard, client); nil != err {
+		t.Errorf("Expected the connection to be closed, but actually got: (%T) %v", err, err)
+	}
+}
+
+func TestServerLifecycleHooks(t *testing.T) {
+
+	var mutex sync.Mutex
+	var events []string
+	record := func(event string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		events = append(events, event)
+	}
+
+	negotiated := make(chan struct{})
+	server := &Server{
+		Banner: "Welcome!\r\n",
+		OptionHandlers: OptionHandlers{
+			OptNAWS: func() OptionHandler {
+				return &testOptionHandler{support: OptionSupport{Remote: true, RequestRemote: true}}
+			},
+		},
+		OnConnect: func(conn *Conn) {
+			if _, remote := conn.OptionEnabled(OptNAWS); remote {
+				t.Errorf("Expected NAWS to not be enabled yet, but actually it was.")
+			}
+			record("connect")
+		},
+		OnNegotiationComplete: func(conn *Conn) {
+			if _, remote := conn.OptionEnabled(OptNAWS); !remote {
+				t.Errorf("Expected NAWS to be enabled, but actually it was not.")
+			}
+			record("negotiation complete")
+			close(negotiated)
+		},
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			record("handler")
+			io.Copy(io.Discard, conn)
+		}),
+	}
+
+	client := testServe(t, server)
+
+	if expected, actual := string([]byte{IAC, DO, OptNAWS})+"Welcome!\r\n", string(testReadExactly(t, client, 3+len("Welcome!\r\n"))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	client.Write([]byte{IAC, WILL, OptNAWS})
+	select {
+	case <-negotiated:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnNegotiationComplete to be called, but actually it was not.")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if expected, actual := []string{"connect", "handler", "negotiation complete"}, events; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWaitForNegotiations(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	// (Nothing is in progress.)
+	if err := conn.WaitForNegotiations(context.Background()); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (The pipe is synchronous; so what is sent has to be read as it is sent.)
+	sent := testReadLater(remote, 6)
+	naws := conn.RequestEnableRemoteAsync(OptNAWS)
+	echo := conn.RequestEnableLocalAsync(OptEcho)
+	<-sent
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- conn.WaitForNegotiations(context.Background())
+	}()
+
+	remote.Write([]byte{IAC, WILL, OptNAWS})
+	<-naws
+
+	select {
+	case err := <-waited:
+		t.Fatalf("Expected WaitForNegotiations to wait for ECHO too, but actually it returned: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// (A refusal settles it too.)
+	remote.Write([]byte{IAC, DONT, OptEcho})
+	<-echo
+
+	select {
+	case err := <-waited:
+		if nil != err {
+			t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected WaitForNegotiations to return, but actually it did not.")
+	}
+
+	sent = testReadLater(remote, 3)
+	conn.RequestEnableRemoteAsync(OptTerminalType)
+	<-sent
+	conn.Close()
+	if expected, actual := ErrClosed, conn.WaitForNegotiations(context.Background()); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}
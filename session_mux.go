@@ -0,0 +1,286 @@
+package telnet
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultSessionQueueSize = 64
+	sessionReadSize         = 4096
+)
+
+var (
+	// ErrNotController is returned when a SessionParticipant that is (only) observing the
+	// session is written to; i.e., one that has not been promoted. (See SessionMux.Promote.)
+	ErrNotController = errors.New("telnet: not the controller of the session")
+
+	// ErrDetached is returned when a SessionParticipant that has been detached from its
+	// SessionMux is written to, or promoted. (See SessionMux.Detach.)
+	ErrDetached = errors.New("telnet: detached from the session")
+)
+
+// SessionMux shares a Conn between many participants; such as for support engineers to
+// "shadow" a customer's console session. What is read from the Conn is written to every
+// participant; but only one of them, the controller, can write to the Conn. For example:
+//
+//	mux := telnet.NewSessionMux(conn)
+//
+//	user := mux.Attach(userTerminal)
+//	mux.Promote(user)
+//	go io.Copy(user, userKeyboard)
+//
+//	//...
+//
+//	shadow := mux.Attach(engineerTerminal)
+//	defer mux.Detach(shadow)
+//
+// (And, to have the engineer take over, mux.Promote(shadow).)
+//
+// The SessionMux does the reading from the Conn (from when it is created; until reading fails,
+// such as once the Conn is closed). So nothing else should read from it; and what is read while
+// there are no participants is not written anywhere. What is read is the data; i.e., without
+// the TELNET commands, and unescaped.
+//
+// Each participant has a queue of its own, that what is read is written to it from; so that a
+// slow participant (or one that is stuck) does not hold up the session, nor the other
+// participants. If its queue is full, then what was read is dropped, for that participant (see
+// SessionParticipant.Dropped). If writing to it fails, then it is detached.
+//
+// A SessionMux can be used from many goroutines at the same time; and participants can be
+// attached, detached, and promoted at any time.
+type SessionMux struct {
+	// QueueSize is how many reads there can be waiting to be written to a participant, before
+	// they are dropped. The default is 64. (It applies to the participants attached after it
+	// is set.)
+	QueueSize int
+
+	conn *Conn
+
+	mutex        sync.Mutex
+	participants map[*SessionParticipant]struct{}
+	controller   *SessionParticipant
+	stopped      bool
+	err          error
+
+	// writeMutex serializes what (the controller) writes to the Conn; even across a promotion.
+	writeMutex sync.Mutex
+
+	done chan struct{}
+}
+
+// SessionParticipant is a participant in (i.e., is attached to) a SessionMux. (See
+// SessionMux.Attach.)
+type SessionParticipant struct {
+	mux    *SessionMux
+	writer io.Writer
+
+	queue    chan []byte
+	detached chan struct{}
+
+	dropped atomic.Int64
+}
+
+// NewSessionMux returns a SessionMux for 'conn'; which starts reading from it right away.
+func NewSessionMux(conn *Conn) *SessionMux {
+	mux := &SessionMux{
+		conn:         conn,
+		participants: map[*SessionParticipant]struct{}{},
+		done:         make(chan struct{}),
+	}
+
+	conn.spawn(mux.read)
+
+	return mux
+}
+
+// Attach adds a participant, that what is read from the Conn is written to 'w'; as an observer
+// (i.e., it can not write to the Conn, until it is promoted).
+//
+// It only gets what is read after it was attached.
+func (mux *SessionMux) Attach(w io.Writer) *SessionParticipant {
+	size := mux.QueueSize
+	if size <= 0 {
+		size = defaultSessionQueueSize
+	}
+
+	participant := &SessionParticipant{
+		mux:      mux,
+		writer:   w,
+		queue:    make(chan []byte, size),
+		detached: make(chan struct{}),
+	}
+
+	mux.mutex.Lock()
+	if mux.stopped {
+		close(participant.queue)
+	}
+	mux.participants[participant] = struct{}{}
+	mux.mutex.Unlock()
+
+	go participant.deliver()
+
+	return participant
+}
+
+// Detach removes 'participant'; if it was not removed already. Whatever is still waiting to be
+// written to it is not written. (If it was the controller, then there is no controller after
+// that.)
+func (mux *SessionMux) Detach(participant *SessionParticipant) {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	if _, ok := mux.participants[participant]; !ok {
+		return
+	}
+
+	delete(mux.participants, participant)
+	close(participant.detached)
+	if participant == mux.controller {
+		mux.controller = nil
+	}
+}
+
+// Promote makes 'participant' the controller; i.e., the one participant that can write to the
+// Conn. Whichever participant was the controller becomes an observer.
+//
+// It returns ErrDetached, if 'participant' has been detached.
+func (mux *SessionMux) Promote(participant *SessionParticipant) error {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	if _, ok := mux.participants[participant]; !ok {
+		return ErrDetached
+	}
+
+	mux.controller = participant
+	return nil
+}
+
+// Controller returns the controller; or nil, if there is not one.
+func (mux *SessionMux) Controller() *SessionParticipant {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	return mux.controller
+}
+
+// Len returns how many participants there are.
+func (mux *SessionMux) Len() int {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	return len(mux.participants)
+}
+
+// Done returns a channel that is closed once the SessionMux has stopped reading from the Conn.
+// (See Err.)
+func (mux *SessionMux) Done() <-chan struct{} {
+	return mux.done
+}
+
+// Err returns why the SessionMux stopped reading from the Conn (such as io.EOF); or nil, if it
+// has not.
+func (mux *SessionMux) Err() error {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	return mux.err
+}
+
+// read reads from the Conn, and queues what it read for each of the participants; until reading
+// fails.
+func (mux *SessionMux) read() {
+	defer close(mux.done)
+
+	for {
+		// (Each read gets a buffer of its own; as it is shared by the participants' queues.)
+		p := make([]byte, sessionReadSize)
+		n, err := mux.conn.Read(p)
+		if 0 < n {
+			mux.queue(p[:n])
+		}
+		if nil != err {
+			mux.stop(err)
+			return
+		}
+	}
+}
+
+// queue queues 'p' for each of the participants; dropping it for those whose queue is full.
+func (mux *SessionMux) queue(p []byte) {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	for participant := range mux.participants {
+		select {
+		case participant.queue <- p:
+		default:
+			participant.dropped.Add(1)
+		}
+	}
+}
+
+// stop is called once reading has failed with 'err'. (The participants get what is still queued
+// for them; and nothing more.)
+func (mux *SessionMux) stop(err error) {
+	mux.mutex.Lock()
+	defer mux.mutex.Unlock()
+
+	mux.stopped = true
+	mux.err = err
+	for participant := range mux.participants {
+		close(participant.queue)
+	}
+}
+
+// Write writes 'p' to the Conn; if the participant is the controller. Otherwise, it returns
+// ErrNotController (or ErrDetached, if it has been detached).
+func (participant *SessionParticipant) Write(p []byte) (int, error) {
+	mux := participant.mux
+
+	mux.writeMutex.Lock()
+	defer mux.writeMutex.Unlock()
+
+	mux.mutex.Lock()
+	_, attached := mux.participants[participant]
+	controller := participant == mux.controller
+	mux.mutex.Unlock()
+
+	switch {
+	case !attached:
+		return 0, ErrDetached
+	case !controller:
+		return 0, ErrNotController
+	}
+
+	return mux.conn.Write(p)
+}
+
+// Dropped returns how many reads were dropped for the participant, because its queue was full.
+// A participant that has had any dropped is lagging; i.e., it is not keeping up.
+func (participant *SessionParticipant) Dropped() int {
+	return int(participant.dropped.Load())
+}
+
+// deliver writes what is queued for the participant to its io.Writer, one after another; until it
+// is detached (or there is nothing more to write).
+func (participant *SessionParticipant) deliver() {
+	for {
+		select {
+		case <-participant.detached:
+			return
+		case p, ok := <-participant.queue:
+			if !ok {
+				return
+			}
+			if _, err := participant.writer.Write(p); nil != err {
+				participant.mux.conn.logger.Debugf("Detaching session participant, as writing to it failed: %v", err)
+				participant.mux.Detach(participant)
+				return
+			}
+		}
+	}
+}
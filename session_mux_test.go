@@ -0,0 +1,180 @@
+package telnet
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testSessionWriter is a participant's io.Writer; that (if it is 'slow') does not return from Write
+// until it is released.
+type testSessionWriter struct {
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	release chan struct{}
+}
+
+func (w *testSessionWriter) Write(p []byte) (int, error) {
+	if nil != w.release {
+		<-w.release
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.buffer.Write(p)
+}
+
+func (w *testSessionWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.buffer.String()
+}
+
+// wait waits for what was written to be 'expected'.
+func (w *testSessionWriter) wait(t *testing.T, expected string) {
+	t.Helper()
+
+	for i := 0; i < 1000 && expected != w.String(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if actual := w.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func testSessionMux(t *testing.T) (*SessionMux, net.Conn) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	return NewSessionMux(newConn(local, nil)), remote
+}
+
+func TestSessionMux(t *testing.T) {
+
+	mux, remote := testSessionMux(t)
+
+	var user, shadow testSessionWriter
+
+	userParticipant := mux.Attach(&user)
+	shadowParticipant := mux.Attach(&shadow)
+	if err := mux.Promote(userParticipant); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (TELNET commands are not written to the participants.)
+	if _, err := remote.Write([]byte("login: \xff\xf1")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	user.wait(t, "login: ")
+	shadow.wait(t, "login: ")
+
+	go userParticipant.Write([]byte("root\r\n"))
+	if expected, actual := "root\r\n", string(testReadExactly(t, remote, 6)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if n, err := shadowParticipant.Write([]byte("halt\r\n")); 0 != n || ErrNotController != err {
+		t.Errorf("Expected 0 and %v, but actually got %d and %v.", ErrNotController, n, err)
+	}
+
+	// The engineer takes over.
+	if err := mux.Promote(shadowParticipant); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := shadowParticipant, mux.Controller(); expected != actual {
+		t.Errorf("Expected the shadow to be the controller, but it was not.")
+	}
+	if n, err := userParticipant.Write([]byte("rm -rf\r\n")); 0 != n || ErrNotController != err {
+		t.Errorf("Expected 0 and %v, but actually got %d and %v.", ErrNotController, n, err)
+	}
+	go shadowParticipant.Write([]byte("uptime\r\n"))
+	if expected, actual := "uptime\r\n", string(testReadExactly(t, remote, 8)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	mux.Detach(shadowParticipant)
+	if expected, actual := 1, mux.Len(); expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if nil != mux.Controller() {
+		t.Errorf("Expected there to be no controller, but there was one.")
+	}
+	if _, err := shadowParticipant.Write([]byte("uptime\r\n")); ErrDetached != err {
+		t.Errorf("Expected %v, but actually got %v.", ErrDetached, err)
+	}
+	if err := mux.Promote(shadowParticipant); ErrDetached != err {
+		t.Errorf("Expected %v, but actually got %v.", ErrDetached, err)
+	}
+
+	if _, err := remote.Write([]byte(" 10:00 up 1 day\r\n")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	user.wait(t, "login:  10:00 up 1 day\r\n")
+	shadow.wait(t, "login: ")
+
+	remote.Close()
+	select {
+	case <-mux.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the SessionMux to stop reading, but it did not.")
+	}
+	if nil == mux.Err() {
+		t.Errorf("Expected an error, but did not get one.")
+	}
+}
+
+func TestSessionMuxSlowObserver(t *testing.T) {
+
+	mux, remote := testSessionMux(t)
+	mux.QueueSize = 2
+
+	var user testSessionWriter
+	slow := testSessionWriter{release: make(chan struct{})}
+	defer close(slow.release)
+
+	userParticipant := mux.Attach(&user)
+	slowParticipant := mux.Attach(&slow)
+	mux.Promote(userParticipant)
+
+	// The slow observer is stuck; but the session (and the user) keeps going.
+	var expected strings.Builder
+	for i := 0; i < 10; i++ {
+		line := strings.Repeat("x", 10) + "\r\n"
+		expected.WriteString(line)
+
+		if _, err := remote.Write([]byte(line)); nil != err {
+			t.Fatalf("For line #%d, did not expect an error, but actually got one: (%T) %v", i, err, err)
+		}
+		user.wait(t, expected.String())
+
+		go userParticipant.Write([]byte("ok\r\n"))
+		if expected, actual := "ok\r\n", string(testReadExactly(t, remote, 4)); expected != actual {
+			t.Errorf("For line #%d, expected %q, but actually got %q.", i, expected, actual)
+		}
+	}
+
+	if 0 != userParticipant.Dropped() {
+		t.Errorf("Expected nothing to be dropped for the user, but actually got %d.", userParticipant.Dropped())
+	}
+	// (1 being written, and 2 queued; or, if it had not started writing the first yet, 2 queued.)
+	if actual := slowParticipant.Dropped(); actual < 7 || 8 < actual {
+		t.Errorf("Expected 7 (or 8) to be dropped for the slow observer, but actually got %d.", actual)
+	}
+
+	// Detaching the slow observer does not need it to get unstuck.
+	mux.Detach(slowParticipant)
+	if expected, actual := 1, mux.Len(); expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+}
@@ -0,0 +1,127 @@
+package telnet
+
+import (
+	"time"
+)
+
+// SessionTimeouts configures how long a connection (to, or from, a client) can last. (See
+// Conn.SetSessionTimeouts, and Server.SessionTimeouts.)
+type SessionTimeouts struct {
+	// IdleTimeout (if not zero) is how long nothing can be received from the client for; after
+	// which the Conn is closed (with CloseIdleTimeout).
+	IdleTimeout time.Duration
+
+	// MaxSessionDuration (if not zero) is how long the connection can last, however busy it is;
+	// after which the Conn is closed (with CloseSessionTimeout).
+	MaxSessionDuration time.Duration
+
+	// Goodbye (if not empty) is written (as data) to the client just before the Conn is closed, for
+	// either of them; such as "\r\nTimed out; goodbye.\r\n".
+	Goodbye string
+}
+
+// SetSessionTimeouts sets (or, with the zero SessionTimeouts, stops) the session timeouts of the
+// Conn; so that a client that has gone quiet (or has been connected for too long) does not hold on
+// to the connection forever. For example:
+//
+//	conn.SetSessionTimeouts(telnet.SessionTimeouts{
+//		IdleTimeout:        15 * time.Minute,
+//		MaxSessionDuration: 8 * time.Hour,
+//		Goodbye:            "\r\nTimed out; goodbye.\r\n",
+//	})
+//
+// This replaces any SessionTimeouts set before; such as (for a Server's connections) the
+// Server's SessionTimeouts, which a handler can override (or stop) for its own connection this way.
+//
+// If nothing has been received from the client for the IdleTimeout, then the Conn is closed (with
+// CloseIdleTimeout); and reading from it returns ErrIdleTimeout. Anything received counts; such as
+// an IAC NOP. (What is sent to the client does not.) What is received is only known of once it is
+// read; so the Conn has to be read from, for it to not be taken to be idle.
+//
+// Once the Conn has been open for the MaxSessionDuration (counting from when it was connected, not
+// from when SetSessionTimeouts was called), it is closed (with CloseSessionTimeout); and reading
+// from it returns ErrClosed.
+//
+// The Goodbye is written first, in either case; waiting (at most a second) for it to be written.
+func (clientConn *Conn) SetSessionTimeouts(timeouts SessionTimeouts) {
+	clientConn.sessionMutex.Lock()
+	defer clientConn.sessionMutex.Unlock()
+
+	if nil != clientConn.sessionStop {
+		close(clientConn.sessionStop)
+		clientConn.sessionStop = nil
+	}
+	if timeouts.IdleTimeout <= 0 && timeouts.MaxSessionDuration <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	clientConn.sessionStop = stop
+	clientConn.spawn(func() {
+		clientConn.timeSession(timeouts, stop)
+	})
+}
+
+// timeSession closes the Conn, once it has been idle for too long, or open for too long; unless
+// 'stop' is closed (or the Conn is) first.
+func (clientConn *Conn) timeSession(timeouts SessionTimeouts, stop <-chan struct{}) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+
+		var next time.Time
+		if 0 < timeouts.MaxSessionDuration {
+			next = clientConn.connected.Add(timeouts.MaxSessionDuration)
+			if !now.Before(next) {
+				clientConn.timedOutClose(CloseSessionTimeout, "connected for "+timeouts.MaxSessionDuration.String(), timeouts.Goodbye)
+				return
+			}
+		}
+		if 0 < timeouts.IdleTimeout {
+			received := time.Unix(0, clientConn.received.Load())
+			idle := received.Add(timeouts.IdleTimeout)
+			if !now.Before(idle) {
+				clientConn.timedOutClose(CloseIdleTimeout, "idle for "+now.Sub(received).Round(time.Millisecond).String(), timeouts.Goodbye)
+				return
+			}
+			if next.IsZero() || idle.Before(next) {
+				next = idle
+			}
+		}
+
+		timer.Reset(next.Sub(now))
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		case <-clientConn.done:
+			return
+		}
+	}
+}
+
+// timedOutClose writes the 'goodbye' (if there is one); and then closes the Conn with 'reason'.
+func (clientConn *Conn) timedOutClose(reason CloseReason, msg string, goodbye string) {
+	if "" != goodbye {
+		written := make(chan struct{})
+		clientConn.spawn(func() {
+			defer close(written)
+
+			if _, err := clientConn.Write([]byte(goodbye)); nil != err {
+				clientConn.logger.Debugf("Problem writing the goodbye, before closing (%v): %v", reason, err)
+			}
+		})
+
+		timer := time.NewTimer(closeFlushTimeout)
+		select {
+		case <-written:
+		case <-timer.C:
+		case <-clientConn.done:
+		}
+		timer.Stop()
+	}
+
+	clientConn.CloseWithReason(reason, msg)
+}
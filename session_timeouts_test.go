@@ -0,0 +1,178 @@
+package telnet
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnSessionTimeouts(t *testing.T) {
+
+	tests := []struct {
+		Timeouts       SessionTimeouts
+		ExpectedReason CloseReason
+		ExpectedErr    error
+	}{
+		{
+			Timeouts:       SessionTimeouts{IdleTimeout: 50 * time.Millisecond},
+			ExpectedReason: CloseIdleTimeout,
+			ExpectedErr:    ErrIdleTimeout,
+		},
+		{
+			Timeouts:       SessionTimeouts{IdleTimeout: 50 * time.Millisecond, Goodbye: "Idle too long.\r\n"},
+			ExpectedReason: CloseIdleTimeout,
+			ExpectedErr:    ErrIdleTimeout,
+		},
+		{
+			Timeouts:       SessionTimeouts{MaxSessionDuration: 50 * time.Millisecond, Goodbye: "Time is up.\r\n"},
+			ExpectedReason: CloseSessionTimeout,
+			ExpectedErr:    ErrClosed,
+		},
+		{
+			Timeouts:       SessionTimeouts{IdleTimeout: time.Hour, MaxSessionDuration: 50 * time.Millisecond},
+			ExpectedReason: CloseSessionTimeout,
+			ExpectedErr:    ErrClosed,
+		},
+	}
+
+	for testNumber, test := range tests {
+		local, remote := net.Pipe()
+		defer remote.Close()
+
+		conn := newConn(local, nil)
+		conn.SetSessionTimeouts(test.Timeouts)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := conn.Read(make([]byte, 16))
+			errs <- err
+		}()
+
+		if "" != test.Timeouts.Goodbye {
+			if expected, actual := test.Timeouts.Goodbye, string(testReadExactly(t, remote, len(test.Timeouts.Goodbye))); expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		}
+
+		select {
+		case err := <-errs:
+			if expected, actual := test.ExpectedErr, err; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got: (%T) %v", testNumber, expected, actual, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("For test #%d, expected the Conn to be closed, but it actually was not.", testNumber)
+		}
+
+		if reason, _ := conn.CloseReason(); test.ExpectedReason != reason {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, test.ExpectedReason, reason)
+		}
+	}
+}
+
+func TestConnSessionTimeoutsTraffic(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		p := make([]byte, 16)
+		for {
+			if _, err := conn.Read(p); nil != err {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	conn.SetSessionTimeouts(SessionTimeouts{IdleTimeout: 60 * time.Millisecond})
+
+	// (For as long as the client sends something, it is not idle.)
+	for i := 0; i < 10; i++ {
+		remote.Write([]byte("."))
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	select {
+	case err := <-errs:
+		t.Fatalf("Did not expect the Conn to be closed, but it actually was: (%T) %v", err, err)
+	default:
+	}
+
+	// (With the zero SessionTimeouts, it is never idle for too long.)
+	conn.SetSessionTimeouts(SessionTimeouts{})
+	select {
+	case err := <-errs:
+		t.Fatalf("Did not expect the Conn to be closed, but it actually was: (%T) %v", err, err)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestServerSessionTimeouts(t *testing.T) {
+
+	tests := []struct {
+		Override       *SessionTimeouts
+		ExpectedReason CloseReason
+	}{
+		{
+			ExpectedReason: CloseIdleTimeout,
+		},
+		{
+			// (The handler overrides them, for its own connection.)
+			Override:       &SessionTimeouts{MaxSessionDuration: 100 * time.Millisecond},
+			ExpectedReason: CloseSessionTimeout,
+		},
+	}
+
+	for testNumber, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		defer listener.Close()
+
+		reasons := make(chan CloseReason, 1)
+		server := NewServer("",
+			WithSessionTimeouts(SessionTimeouts{IdleTimeout: 50 * time.Millisecond, Goodbye: "Goodbye!\r\n"}),
+			WithContextHandler(testContextHandler(func(ctx context.Context, conn *Conn) {
+				if nil != test.Override {
+					conn.SetSessionTimeouts(*test.Override)
+				}
+				conn.ReadLine()
+			})),
+			WithOnDisconnect(func(conn *Conn, reason CloseReason, msg string) {
+				reasons <- reason
+			}),
+		)
+		go server.Serve(listener)
+
+		conn, err := DialTo(listener.Addr().String())
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		defer conn.Close()
+
+		// (The client is sent the Goodbye, unless the handler overrode it; and is then disconnected.)
+		expected := "Goodbye!"
+		if nil != test.Override {
+			expected = ""
+		}
+		if actual, _ := conn.ReadLine(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		select {
+		case reason := <-reasons:
+			if expected, actual := test.ExpectedReason, reason; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Errorf("For test #%d, expected OnDisconnect to be called, but it actually was not.", testNumber)
+		}
+	}
+}
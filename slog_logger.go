@@ -0,0 +1,92 @@
+//go:build go1.21
+
+package telnet
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LevelTrace is the slog.Level that a Logger from NewSlogLogger logs at for Trace (and Tracef);
+// which is below slog.LevelDebug, as there is no trace level in slog. (Such as for each TELNET
+// command, and option negotiation, that is received.)
+const LevelTrace = slog.LevelDebug - 4
+
+// NewSlogLogger returns a Logger that logs to 'logger' (a *slog.Logger, which is in the standard
+// library since Go 1.21). For example:
+//
+//	server := &telnet.Server{
+//		Handler: handler,
+//		Logger:  telnet.NewSlogLogger(slog.Default()),
+//	}
+//
+// Debug (and Debugf) log at slog.LevelDebug; Trace (and Tracef) at LevelTrace; and so on. So, to
+// see the tracing of the negotiation, have the handler of 'logger' log down to LevelTrace. For
+// example:
+//
+//	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: telnet.LevelTrace})
+//	logger := telnet.NewSlogLogger(slog.New(handler))
+//
+// (What is logged is only formatted if the handler of 'logger' is enabled for its level.)
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if nil == logger {
+		logger = slog.Default()
+	}
+
+	return internalSlogLogger{logger: logger}
+}
+
+type internalSlogLogger struct {
+	logger *slog.Logger
+}
+
+func (logger internalSlogLogger) Debug(v ...interface{}) {
+	logger.log(slog.LevelDebug, v)
+}
+
+func (logger internalSlogLogger) Debugf(format string, v ...interface{}) {
+	logger.logf(slog.LevelDebug, format, v)
+}
+
+func (logger internalSlogLogger) Error(v ...interface{}) {
+	logger.log(slog.LevelError, v)
+}
+
+func (logger internalSlogLogger) Errorf(format string, v ...interface{}) {
+	logger.logf(slog.LevelError, format, v)
+}
+
+func (logger internalSlogLogger) Trace(v ...interface{}) {
+	logger.log(LevelTrace, v)
+}
+
+func (logger internalSlogLogger) Tracef(format string, v ...interface{}) {
+	logger.logf(LevelTrace, format, v)
+}
+
+func (logger internalSlogLogger) Warn(v ...interface{}) {
+	logger.log(slog.LevelWarn, v)
+}
+
+func (logger internalSlogLogger) Warnf(format string, v ...interface{}) {
+	logger.logf(slog.LevelWarn, format, v)
+}
+
+func (logger internalSlogLogger) log(level slog.Level, v []interface{}) {
+	ctx := context.Background()
+	if !logger.logger.Enabled(ctx, level) {
+		return
+	}
+
+	logger.logger.Log(ctx, level, fmt.Sprint(v...))
+}
+
+func (logger internalSlogLogger) logf(level slog.Level, format string, v []interface{}) {
+	ctx := context.Background()
+	if !logger.logger.Enabled(ctx, level) {
+		return
+	}
+
+	logger.logger.Log(ctx, level, fmt.Sprintf(format, v...))
+}
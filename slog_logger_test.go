@@ -0,0 +1,56 @@
+//go:build go1.21
+
+package telnet
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+
+	"testing"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+
+	tests := []struct {
+		Level    slog.Level
+		Expected []string
+	}{
+		{
+			Level:    slog.LevelInfo,
+			Expected: []string{"level=WARN msg=\"warn 3\"", "level=ERROR msg=\"error 4\""},
+		},
+		{
+			Level:    slog.LevelDebug,
+			Expected: []string{"level=DEBUG msg=\"debug 2\"", "level=WARN msg=\"warn 3\"", "level=ERROR msg=\"error 4\""},
+		},
+		{
+			Level:    LevelTrace,
+			Expected: []string{"level=DEBUG-4 msg=\"trace 1\"", "level=DEBUG msg=\"debug 2\"", "level=WARN msg=\"warn 3\"", "level=ERROR msg=\"error 4\""},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{
+			Level: test.Level,
+			ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+				if slog.TimeKey == attr.Key {
+					return slog.Attr{}
+				}
+				return attr
+			},
+		})))
+
+		logger.Tracef("trace %d", 1)
+		logger.Debugf("debug %d", 2)
+		logger.Warn("warn ", 3)
+		logger.Errorf("error %d", 4)
+
+		if expected, actual := test.Expected, strings.Split(strings.TrimSpace(buffer.String()), "\n"); !testEqualStrings(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package telnet
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"syscall"
+	"time"
+)
+
+// SocketOptions are the (operating system level) options of a TCP connection; such as TCP
+// keepalives, which long-lived sessions over flaky links can need.
+//
+// They are applied to each connection a Server accepts (see Server.SocketOptions), or a Dialer
+// dials. Connections that are not TCP connections are left as they are.
+//
+// The zero SocketOptions leaves each option at the default (i.e., what Go, and the operating
+// system, would otherwise do).
+//
+// For example:
+//
+//	server := &telnet.Server{
+//		Addr:    ":5555",
+//		Handler: handler,
+//		SocketOptions: telnet.SocketOptions{
+//			KeepAlive:         2 * time.Minute,
+//			KeepAliveInterval: 30 * time.Second,
+//			KeepAliveCount:    4,
+//		},
+//	}
+type SocketOptions struct {
+	// KeepAlive is how long a connection is idle before TCP keepalive probes are sent.
+	// Zero means the default (15 seconds); a negative KeepAlive turns TCP keepalives off.
+	KeepAlive time.Duration
+
+	// KeepAliveInterval is how long to wait between TCP keepalive probes; and KeepAliveCount
+	// is how many of them go unanswered before the connection is dropped. Zero means the default.
+	//
+	// (These need Go 1.23, or later; before that, the probes are sent every KeepAlive.)
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+
+	// Nagle, if true, turns TCP_NODELAY off; so that small writes are put together (by the
+	// operating system) before being sent. By default (as Go does) TCP_NODELAY is on.
+	Nagle bool
+
+	// ReadBuffer and WriteBuffer, if not zero, are the sizes of the operating system's receive
+	// (SO_RCVBUF) and send (SO_SNDBUF) buffers for the connection.
+	ReadBuffer  int
+	WriteBuffer int
+
+	// Control, if not nil, is called with the (raw) socket of each connection; for anything
+	// else. When dialing, it is called before connecting (like the Control of a net.Dialer);
+	// otherwise, as each connection is accepted.
+	Control func(network, address string, c syscall.RawConn) error
+}
+
+// apply applies the SocketOptions to 'conn'; if it is a TCP connection (or a TLS connection over one).
+//
+// Control is only called if 'control' is true. (When dialing, it was already called, before connecting.)
+func (options SocketOptions) apply(conn net.Conn, control bool) error {
+	if netConner, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = netConner.NetConn()
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if err := setKeepAlive(tcpConn, options); nil != err {
+		return err
+	}
+	if options.Nagle {
+		if err := tcpConn.SetNoDelay(false); nil != err {
+			return err
+		}
+	}
+	if 0 < options.ReadBuffer {
+		if err := tcpConn.SetReadBuffer(options.ReadBuffer); nil != err {
+			return err
+		}
+	}
+	if 0 < options.WriteBuffer {
+		if err := tcpConn.SetWriteBuffer(options.WriteBuffer); nil != err {
+			return err
+		}
+	}
+
+	if fn := options.Control; nil != fn && control {
+		rawConn, err := tcpConn.SyscallConn()
+		if nil != err {
+			return err
+		}
+		if err := fn(tcpConn.LocalAddr().Network(), tcpConn.RemoteAddr().String(), rawConn); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dialer makes TELNET (and TELNETS) client connections; with SocketOptions.
+//
+// For example:
+//
+//	dialer := telnet.Dialer{
+//		Timeout: 10 * time.Second,
+//		SocketOptions: telnet.SocketOptions{
+//			KeepAlive: time.Minute,
+//		},
+//	}
+//
+//	conn, err := dialer.DialTo("example.net:23")
+//
+// The zero Dialer dials like DialTo (and DialToTLS) do. (A Dialer can also be made with NewDialer;
+// and Options.)
+type Dialer struct {
+	// Timeout, if not zero, is how long connecting (including the TLS handshake, for
+	// DialToTLS) can take.
+	Timeout time.Duration
+
+	SocketOptions SocketOptions
+
+	// TLSConfig, if not nil, has DialTo (and DialToContext) make TELNETS connections (i.e.,
+	// TELNET over TLS); like DialToTLS does, with it.
+	TLSConfig *tls.Config
+
+	// Logger (if not nil) is the logger of each Conn dialed.
+	Logger Logger
+
+	// NegotiationTimeout and WriteTimeout (if not zero) are set on each Conn dialed. (See
+	// Conn.SetNegotiationTimeout, and Conn.SetWriteTimeout.)
+	NegotiationTimeout time.Duration
+	WriteTimeout       time.Duration
+
+	// NewlinePolicy, and ParseMode, are set on each Conn dialed. (See Conn.SetNewlinePolicy, and
+	// Conn.SetParseMode.)
+	NewlinePolicy NewlinePolicy
+	ParseMode     ParseMode
+
+	// Trace (if not nil) has its hooks called for each Conn dialed; and Metrics (if not nil)
+	// is told of each. (See Trace, and Metrics.)
+	Trace   *Trace
+	Metrics Metrics
+
+	// OptionHandlers (if not nil) are the options registered on each Conn dialed. (See
+	// OptionHandlers.)
+	OptionHandlers OptionHandlers
+
+	// Proxy (if not nil) is what connects to the address; such as through a SOCKS5, or HTTP, proxy
+	// (see ProxyFromURL). The Timeout still applies; but the SocketOptions Control does not.
+	Proxy ContextDialer
+}
+
+// Dial connects to 'address' on the network 'network' (like net.Dial does), and applies the
+// SocketOptions to the connection. It does NOT speak TELNET over it; see DialTo for that.
+//
+// (Dial is a DialFunc; and so can be the Dial of a ProxyHandler.)
+func (dialer *Dialer) Dial(network string, address string) (net.Conn, error) {
+	return dialer.DialContext(context.Background(), network, address)
+}
+
+// DialContext is like Dial; except that it gives up once 'ctx' is done.
+func (dialer *Dialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if nil != dialer.Proxy {
+		return dialer.dialProxy(ctx, network, address)
+	}
+
+	netDialer := net.Dialer{
+		Timeout: dialer.Timeout,
+		Control: dialer.SocketOptions.Control,
+	}
+
+	conn, err := netDialer.DialContext(ctx, network, resolvePort(address))
+	if nil != err {
+		return nil, err
+	}
+
+	if err := dialer.SocketOptions.apply(conn, false); nil != err {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dialProxy connects to 'address' with the Proxy; and applies the SocketOptions to the connection
+// (to the proxy).
+func (dialer *Dialer) dialProxy(ctx context.Context, network string, address string) (net.Conn, error) {
+	if 0 < dialer.Timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+
+	conn, err := dialer.Proxy.DialContext(ctx, network, resolvePort(address))
+	if nil != err {
+		return nil, err
+	}
+
+	if err := dialer.SocketOptions.apply(conn, false); nil != err {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// DialTo makes a (un-secure) TELNET client connection to the the address specified by
+// 'addr'; like the DialTo function does.
+func (dialer *Dialer) DialTo(addr string) (*Conn, error) {
+	return dialer.DialToContext(context.Background(), addr)
+}
+
+// DialToContext is like DialTo; except that it gives up once 'ctx' is done.
+func (dialer *Dialer) DialToContext(ctx context.Context, addr string) (*Conn, error) {
+	return dialer.dialTo(ctx, "tcp", addr)
+}
+
+// dialTo makes a TELNET (or, if the Dialer has a TLSConfig, TELNETS) client connection to 'addr',
+// on the network 'network' (such as "tcp", "tcp4", or "tcp6").
+func (dialer *Dialer) dialTo(ctx context.Context, network string, addr string) (*Conn, error) {
+	if nil != dialer.TLSConfig {
+		return dialer.dialToTLS(ctx, network, addr, dialer.TLSConfig)
+	}
+
+	if addr == "" {
+		addr = "127.0.0.1:telnet"
+	}
+
+	dialer.Trace.dialStart(addr)
+	conn, err := dialer.DialContext(ctx, network, addr)
+	dialer.Trace.dialDone(addr, err)
+	if nil != err {
+		return nil, err
+	}
+
+	return dialer.newConn(conn), nil
+}
+
+// DialToTLS makes a (secure) TELNETS client connection to the the address specified by
+// 'addr'; like the DialToTLS function does.
+func (dialer *Dialer) DialToTLS(addr string, tlsConfig *tls.Config) (*Conn, error) {
+	return dialer.DialToTLSContext(context.Background(), addr, tlsConfig)
+}
+
+// DialToTLSContext is like DialToTLS; except that it gives up once 'ctx' is done.
+func (dialer *Dialer) DialToTLSContext(ctx context.Context, addr string, tlsConfig *tls.Config) (*Conn, error) {
+	return dialer.dialToTLS(ctx, "tcp", addr, tlsConfig)
+}
+
+// dialToTLS makes a TELNETS client connection to 'addr', on the network 'network'.
+func (dialer *Dialer) dialToTLS(ctx context.Context, network string, addr string, tlsConfig *tls.Config) (*Conn, error) {
+	if addr == "" {
+		addr = "127.0.0.1:telnets"
+	}
+
+	if 0 < dialer.Timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+
+	dialer.Trace.dialStart(addr)
+	tlsConn, err := dialer.dialTLS(ctx, network, addr, tlsConfig)
+	dialer.Trace.dialDone(addr, err)
+	if nil != err {
+		return nil, err
+	}
+
+	return dialer.newConn(tlsConn), nil
+}
+
+// dialTLS connects to 'addr', and does the TLS handshake.
+func (dialer *Dialer) dialTLS(ctx context.Context, network string, addr string, tlsConfig *tls.Config) (*tls.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if nil != err {
+		return nil, err
+	}
+
+	if nil == tlsConfig {
+		tlsConfig = &tls.Config{}
+	}
+	if "" == tlsConfig.ServerName {
+		host, _, err := net.SplitHostPort(addr)
+		if nil != err {
+			host = addr
+		}
+
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); nil != err {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// newConn wraps 'conn' (which was just dialed) so that it speaks the TELNET protocol; set up as
+// the Dialer says to.
+func (dialer *Dialer) newConn(conn internalConn) *Conn {
+	telnetConn := newConn(conn, dialer.Logger)
+	if 0 < dialer.NegotiationTimeout {
+		telnetConn.SetNegotiationTimeout(dialer.NegotiationTimeout)
+	}
+	if 0 < dialer.WriteTimeout {
+		telnetConn.SetWriteTimeout(dialer.WriteTimeout)
+	}
+	if NewlineRaw != dialer.NewlinePolicy {
+		telnetConn.SetNewlinePolicy(dialer.NewlinePolicy)
+	}
+	if ParseStrict != dialer.ParseMode {
+		telnetConn.SetParseMode(dialer.ParseMode)
+	}
+	telnetConn.instrument(dialer.Trace, dialer.Metrics)
+
+	if err := dialer.OptionHandlers.register(telnetConn); nil != err {
+		telnetConn.logger.Debugf("Problem registering the options: %v", err)
+	}
+
+	return telnetConn
+}
@@ -0,0 +1,23 @@
+//go:build !go1.23
+
+package telnet
+
+import (
+	"net"
+)
+
+// setKeepAlive sets the TCP keepalive options of 'conn'. (Before Go 1.23, only the period can be
+// set; and so KeepAliveInterval, and KeepAliveCount, are ignored.)
+func setKeepAlive(conn *net.TCPConn, options SocketOptions) error {
+	if options.KeepAlive < 0 {
+		return conn.SetKeepAlive(false)
+	}
+	if 0 == options.KeepAlive {
+		return nil
+	}
+
+	if err := conn.SetKeepAlive(true); nil != err {
+		return err
+	}
+	return conn.SetKeepAlivePeriod(options.KeepAlive)
+}
@@ -0,0 +1,25 @@
+//go:build go1.23
+
+package telnet
+
+import (
+	"net"
+)
+
+// setKeepAlive sets the TCP keepalive options of 'conn'; with net.KeepAliveConfig, which (since
+// Go 1.23) has the interval, and count, of the keepalive probes.
+func setKeepAlive(conn *net.TCPConn, options SocketOptions) error {
+	if options.KeepAlive < 0 {
+		return conn.SetKeepAlive(false)
+	}
+	if 0 == options.KeepAlive && 0 == options.KeepAliveInterval && 0 == options.KeepAliveCount {
+		return nil
+	}
+
+	return conn.SetKeepAliveConfig(net.KeepAliveConfig{
+		Enable:   true,
+		Idle:     options.KeepAlive,
+		Interval: options.KeepAliveInterval,
+		Count:    options.KeepAliveCount,
+	})
+}
@@ -0,0 +1,186 @@
+//go:build linux
+
+package telnet
+
+import (
+	"net"
+	"syscall"
+	"time"
+
+	"testing"
+)
+
+// testGetsockopt reads back the socket options of 'conn' (with the syscall interface).
+func testGetsockopt(t *testing.T, conn net.Conn) map[string]int {
+	t.Helper()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	options := map[string]int{}
+	err = rawConn.Control(func(fd uintptr) {
+		for name, option := range map[string][2]int{
+			"SO_KEEPALIVE":  {syscall.SOL_SOCKET, syscall.SO_KEEPALIVE},
+			"SO_RCVBUF":     {syscall.SOL_SOCKET, syscall.SO_RCVBUF},
+			"SO_SNDBUF":     {syscall.SOL_SOCKET, syscall.SO_SNDBUF},
+			"TCP_NODELAY":   {syscall.IPPROTO_TCP, syscall.TCP_NODELAY},
+			"TCP_KEEPIDLE":  {syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE},
+			"TCP_KEEPINTVL": {syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL},
+			"TCP_KEEPCNT":   {syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT},
+		} {
+			value, err := syscall.GetsockoptInt(int(fd), option[0], option[1])
+			if nil != err {
+				t.Errorf("Did not expect an error getting %s, but actually got one: (%T) %v", name, err, err)
+			}
+			options[name] = value
+		}
+	})
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return options
+}
+
+// testCheckSocketOptions checks that the SocketOptions (of TestSocketOptions) were applied to 'conn'.
+func testCheckSocketOptions(t *testing.T, side string, conn net.Conn) {
+	t.Helper()
+
+	actual := testGetsockopt(t, conn)
+
+	if expected := 1; expected != actual["SO_KEEPALIVE"] {
+		t.Errorf("For the %s, expected SO_KEEPALIVE %d, but actually got %d.", side, expected, actual["SO_KEEPALIVE"])
+	}
+	if expected := 0; expected != actual["TCP_NODELAY"] {
+		t.Errorf("For the %s, expected TCP_NODELAY %d, but actually got %d.", side, expected, actual["TCP_NODELAY"])
+	}
+	if expected := 120; expected != actual["TCP_KEEPIDLE"] {
+		t.Errorf("For the %s, expected TCP_KEEPIDLE %d, but actually got %d.", side, expected, actual["TCP_KEEPIDLE"])
+	}
+	// (Linux doubles the buffer sizes it is given.)
+	if expected := 2 * 32 * 1024; expected != actual["SO_RCVBUF"] {
+		t.Errorf("For the %s, expected SO_RCVBUF %d, but actually got %d.", side, expected, actual["SO_RCVBUF"])
+	}
+	if expected := 2 * 64 * 1024; expected != actual["SO_SNDBUF"] {
+		t.Errorf("For the %s, expected SO_SNDBUF %d, but actually got %d.", side, expected, actual["SO_SNDBUF"])
+	}
+}
+
+type testConnHandler chan *Conn
+
+func (handler testConnHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	conn := w.(*Conn)
+	handler <- conn
+	<-conn.done
+}
+
+func TestSocketOptions(t *testing.T) {
+
+	controlled := make(chan string, 2)
+
+	options := SocketOptions{
+		KeepAlive:   2 * time.Minute,
+		Nagle:       true,
+		ReadBuffer:  32 * 1024,
+		WriteBuffer: 64 * 1024,
+		Control: func(network, address string, c syscall.RawConn) error {
+			controlled <- network
+			return nil
+		},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	conns := make(testConnHandler, 1)
+
+	server := &Server{
+		Handler:       conns,
+		SocketOptions: options,
+	}
+	go server.Serve(listener)
+
+	dialer := Dialer{
+		Timeout:       5 * time.Second,
+		SocketOptions: options,
+	}
+	client, err := dialer.DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	testCheckSocketOptions(t, "client", client.conn.(net.Conn))
+
+	select {
+	case conn := <-conns:
+		testCheckSocketOptions(t, "server", conn.conn.(net.Conn))
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the connection to be accepted.")
+	}
+
+	for _, side := range []string{"client", "server"} {
+		select {
+		case network := <-controlled:
+			// (When dialing, net.Dialer calls Control with "tcp4", or "tcp6".)
+			if "tcp" != network && "tcp4" != network {
+				t.Errorf("For the %s, expected Control to be called with a TCP network, but actually got %q.", side, network)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("For the %s, expected Control to be called, but it was not.", side)
+		}
+	}
+}
+
+func TestSocketOptionsKeepAliveOff(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		if conn, err := listener.Accept(); nil == err {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+
+	dialer := Dialer{SocketOptions: SocketOptions{KeepAlive: -1}}
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	if expected, actual := 0, testGetsockopt(t, conn)["SO_KEEPALIVE"]; expected != actual {
+		t.Errorf("Expected SO_KEEPALIVE %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := 1, testGetsockopt(t, conn)["TCP_NODELAY"]; expected != actual {
+		t.Errorf("Expected TCP_NODELAY %d, but actually got %d.", expected, actual)
+	}
+}
+
+func TestWithKeepAlive(t *testing.T) {
+
+	listener := testListen(t, func(c net.Conn) {
+		c.Read(make([]byte, 1))
+	})
+
+	conn, err := NewDialer(WithKeepAlive(77 * time.Second)).DialTo(listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	if expected, actual := 77, testGetsockopt(t, conn.conn.(net.Conn))["TCP_KEEPIDLE"]; expected != actual {
+		t.Errorf("Expected TCP_KEEPIDLE %d, but actually got %d.", expected, actual)
+	}
+}
@@ -5,27 +5,108 @@ import (
 
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"time"
 )
 
+// DefaultEscapeByte is the byte that, when typed by the user of the StandardCaller, suspends
+// forwarding data to the server and brings up a (local) "telnet> " command prompt.
+//
+// It is Ctrl-] (i.e., 0x1D), the same as the classic TELNET client.
+const DefaultEscapeByte byte = 0x1D
+
 // StandardCaller is a simple TELNET client which sends to the server any data it gets from os.Stdin
 // as TELNET (and TELNETS) data, and writes any TELNET (or TELNETS) data it receives from
 // the server to os.Stdout, and writes any error it has to os.Stderr.
-var StandardCaller Caller = internalStandardCaller{}
+//
+// Typing Ctrl-] (i.e., DefaultEscapeByte) brings up a local command prompt (on os.Stderr), where
+// the following commands are available:
+//
+//	close           close the connection
+//	quit            close the connection, and exit
+//	send brk        send a TELNET BRK ("break")
+//	send ayt        send a TELNET AYT ("are you there")
+//	send ip         send a TELNET IP ("interrupt process")
+//	send ao         send a TELNET AO ("abort output")
+//	send nop        send a TELNET NOP ("no operation")
+//	send escape     send the escape byte itself
+//	mode character  send each byte as soon as it is typed
+//	mode line       send a line at a time
+//	?               print help
+//
+// Entering an empty command resumes the session.
+//
+// Typing Ctrl-] twice in a row sends a (single) Ctrl-] to the server.
+//
+// It lets the server do the echoing (ECHO), and suppress go-ahead (SUPPRESS-GO-AHEAD). Once the
+// server is doing both, what is typed is sent as soon as it is typed (like "mode character");
+// otherwise it is sent a line at a time. (Unless the mode was set, with the "mode" command.)
+// (Turning off the echoing of the terminal itself, while the server is doing it, is up to the
+// program; such as with golang.org/x/term.)
+//
+// The StandardCaller is (also) a ContextCaller; see AdaptCaller. As one, it ends once its
+// context is cancelled, such as because the connection dropped.
+//
+// Once it returns, nothing more is written to os.Stdout. (Whatever is still being received then is
+// cut off; by closing the connection.)
+//
+// To use a different escape byte, use NewStandardCaller.
+var StandardCaller Caller = internalStandardCaller{escapeByte: DefaultEscapeByte}
+
+// NewStandardCaller returns a StandardCaller that uses 'escapeByte', rather than DefaultEscapeByte,
+// to bring up its local command prompt.
+//
+// If 'escapeByte' is zero, then the local command prompt is disabled, and everything typed is
+// sent to the server.
+func NewStandardCaller(escapeByte byte) Caller {
+	return internalStandardCaller{escapeByte: escapeByte}
+}
 
-type internalStandardCaller struct{}
+type internalStandardCaller struct {
+	escapeByte byte
+}
 
+// CallTELNET is the StandardCaller, as a Caller. (If 'r' is a *Conn, then it is the same as the
+// StandardCaller as a ContextCaller; but is never cancelled.)
 func (caller internalStandardCaller) CallTELNET(ctx Context, w Writer, r Reader) {
-	standardCallerCallTELNET(os.Stdin, os.Stdout, os.Stderr, ctx, w, r)
+	if conn, ok := r.(*Conn); ok && Writer(conn) == w {
+		internalStandardContextCaller(caller).CallTELNET(context.Background(), conn)
+		return
+	}
+
+	caller.callTELNET(context.Background(), os.Stdin, os.Stdout, os.Stderr, w, r)
+}
+
+// contextCaller returns the StandardCaller, as a ContextCaller. (See AdaptCaller.)
+func (caller internalStandardCaller) contextCaller() ContextCaller {
+	return internalStandardContextCaller(caller)
+}
+
+// internalStandardContextCaller is the StandardCaller, as a ContextCaller.
+type internalStandardContextCaller internalStandardCaller
+
+func (caller internalStandardContextCaller) CallTELNET(ctx context.Context, conn *Conn) {
+	conn.RegisterOption(OptEcho, SimpleOption(OptionSupport{Remote: true}))
+	conn.RegisterOption(OptSuppressGoAhead, SimpleOption(OptionSupport{Local: true, Remote: true}))
+
+	internalStandardCaller(caller).callTELNET(ctx, os.Stdin, os.Stdout, os.Stderr, conn, conn)
 }
 
 func standardCallerCallTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, ctx Context, w Writer, r Reader) {
+	internalStandardCaller{escapeByte: DefaultEscapeByte}.callTELNET(context.Background(), stdin, stdout, stderr, w, r)
+}
 
+func (caller internalStandardCaller) callTELNET(ctx context.Context, stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, w Writer, r Reader) {
+
+	// (Closed once nothing more is going to be written to 'stdout'.)
+	pumped := make(chan struct{})
 	go func(writer io.Writer, reader io.Reader) {
+		defer close(pumped)
 
 		var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
 		p := buffer[:]
@@ -43,42 +124,321 @@ func standardCallerCallTELNET(stdin io.ReadCloser, stdout io.WriteCloser, stderr
 		}
 	}(stdout, r)
 
-	var buffer bytes.Buffer
-	var p []byte
+	session := internalStandardCallerSession{
+		escapeByte: caller.escapeByte,
+		stdin:      bufio.NewReader(stdin),
+		stderr:     stderr,
+		w:          w,
+	}
+	session.conn, _ = w.(*Conn)
+
+	// (If 'ctx' is done first, then whatever is typed after that is not sent.)
+	ran := make(chan struct{})
+	go func() {
+		defer close(ran)
+		session.run()
+	}()
+
+	select {
+	case <-ran:
+		// Wait a bit to receive data from the server (that we would send to io.Stdout).
+		select {
+		case <-pumped:
+		case <-time.After(3 * time.Millisecond):
+		}
+	case <-ctx.Done():
+		// (Unless it was the session that closed the connection; such as with "quit".)
+		select {
+		case <-ran:
+		case <-time.After(10 * time.Millisecond):
+			fmt.Fprint(stderr, "\r\nConnection closed.\r\n")
+		}
+	}
+
+	// Nothing is written to 'stdout' once this returns; so whatever is still being received is cut
+	// off, by closing 'r' (if it can be; the connection is closed once this returns, anyway).
+	select {
+	case <-pumped:
+	default:
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+		<-pumped
+	}
+}
 
-	var crlfBuffer [2]byte = [2]byte{'\r', '\n'}
-	crlf := crlfBuffer[:]
+// internalStandardCallerSession is the "input" half of the StandardCaller.
+//
+// It takes what the user types, and (depending on the mode) sends it to the server
+// a line at a time, or a byte at a time.
+//
+// It also watches for the escape byte, which brings up the local command prompt.
+type internalStandardCallerSession struct {
+	escapeByte byte
 
-	scanner := bufio.NewScanner(stdin)
-	scanner.Split(scannerSplitFunc)
+	stdin  *bufio.Reader
+	stderr io.Writer
+	w      Writer
 
-	for scanner.Scan() {
-		buffer.Write(scanner.Bytes())
-		buffer.Write(crlf)
+	// conn (if not nil) is what 'w' is; whose options say what the mode is, unless it was set.
+	conn *Conn
 
-		p = buffer.Bytes()
+	characterMode bool
+	modeSet       bool
+	line          bytes.Buffer
+}
+
+func (session *internalStandardCallerSession) run() {
 
-		n, err := oi.LongWrite(w, p)
+	for {
+		b, err := session.stdin.ReadByte()
 		if nil != err {
-			break
+			// Note that any partially typed line (i.e., not ended with a "\n") is
+			// NOT sent to the server.
+			return
 		}
-		if expected, actual := int64(len(p)), n; expected != actual {
-			err := fmt.Errorf("transmission problem: tried sending %d bytes, but actually only sent %d bytes", expected, actual)
-			fmt.Fprint(stderr, err.Error())
+
+		if 0 != session.escapeByte && session.escapeByte == b {
+			if session.stdin.Buffered() > 0 {
+				if peeked, err := session.stdin.Peek(1); nil == err && session.escapeByte == peeked[0] {
+					session.stdin.Discard(1)
+
+					if err := session.forward(b); nil != err {
+						return
+					}
+					continue
+				}
+			}
+
+			if done := session.escape(); done {
+				return
+			}
+			continue
+		}
+
+		if err := session.forward(b); nil != err {
 			return
 		}
+	}
+}
+
+// forward sends 'b' to the server, if in character mode; or adds 'b' to the current
+// line (and sends the line, if 'b' ends it) if in line mode.
+func (session *internalStandardCallerSession) forward(b byte) error {
 
-		buffer.Reset()
+	if session.inCharacterMode() {
+		// Anything typed before the mode changed still needs to go out.
+		if 0 < session.line.Len() {
+			if err := session.send(session.line.Bytes()); nil != err {
+				return err
+			}
+			session.line.Reset()
+		}
+
+		if '\n' == b {
+			return session.send([]byte{'\r', '\n'})
+		}
+		return session.send([]byte{b})
+	}
+
+	if '\n' != b {
+		session.line.WriteByte(b)
+		return nil
 	}
 
-	// Wait a bit to receive data from the server (that we would send to io.Stdout).
-	time.Sleep(3 * time.Millisecond)
+	// Similar to bufio.ScanLines, a trailing "\r" is dropped, since we are
+	// about to send our own "\r\n".
+	p := session.line.Bytes()
+	if 0 < len(p) && '\r' == p[len(p)-1] {
+		p = p[:len(p)-1]
+	}
+
+	var buffer bytes.Buffer
+	buffer.Write(p)
+	buffer.WriteString("\r\n")
+
+	session.line.Reset()
+
+	return session.send(buffer.Bytes())
 }
 
-func scannerSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF {
-		return 0, nil, nil
+// inCharacterMode returns whether what is typed is sent as soon as it is typed (rather than a
+// line at a time). Unless the mode was set (with the "mode" command), that is whether the server
+// is doing the echoing, and suppressing go-ahead; as then it expects each key as it is pressed.
+func (session *internalStandardCallerSession) inCharacterMode() bool {
+	if session.modeSet || nil == session.conn {
+		return session.characterMode
 	}
 
-	return bufio.ScanLines(data, atEOF)
+	_, echo := session.conn.OptionEnabled(OptEcho)
+	_, suppressGoAhead := session.conn.OptionEnabled(OptSuppressGoAhead)
+
+	return echo && suppressGoAhead
+}
+
+func (session *internalStandardCallerSession) send(p []byte) error {
+
+	n, err := oi.LongWrite(session.w, p)
+	if nil != err {
+		return err
+	}
+	if expected, actual := int64(len(p)), n; expected != actual {
+		err := fmt.Errorf("transmission problem: tried sending %d bytes, but actually only sent %d bytes", expected, actual)
+		fmt.Fprint(session.stderr, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// escape runs the local command prompt.
+//
+// It returns true if the session should end.
+func (session *internalStandardCallerSession) escape() bool {
+
+	// If the escape byte was not the last thing on the line that was typed, then
+	// the rest of the line is the command. (Ex: "^]quit".)
+	var command string
+	if session.stdin.Buffered() > 0 {
+		rest, err := session.stdin.ReadString('\n')
+		if nil != err && io.EOF != err {
+			return true
+		}
+		command = strings.TrimSpace(rest)
+	}
+
+	if "" == command {
+		fmt.Fprint(session.stderr, "\r\ntelnet> ")
+
+		// Typing the escape byte (again) at the prompt sends it; the same as typing it twice in a
+		// row does, when the second one comes in a read of its own (as it does when typed).
+		b, err := session.stdin.ReadByte()
+		if nil != err {
+			return true
+		}
+		if session.escapeByte == b {
+			fmt.Fprint(session.stderr, "\r\n")
+			return nil != session.forward(b)
+		}
+		session.stdin.UnreadByte()
+
+		typed, err := session.stdin.ReadString('\n')
+		if nil != err && "" == typed {
+			return true
+		}
+		command = strings.TrimSpace(typed)
+	}
+
+	return session.execute(command)
+}
+
+// execute runs a single command typed at the local command prompt.
+//
+// It returns true if the session should end.
+func (session *internalStandardCallerSession) execute(command string) bool {
+
+	fields := strings.Fields(command)
+	if len(fields) <= 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "quit", "close":
+		fmt.Fprint(session.stderr, "Connection closed.\r\n")
+		if closer, ok := session.w.(io.Closer); ok {
+			closer.Close()
+		}
+		return true
+
+	case "send":
+		if len(fields) < 2 {
+			fmt.Fprint(session.stderr, "?Need an argument to 'send' command\r\n")
+			return false
+		}
+		if err := session.sendCommand(fields[1]); nil != err {
+			fmt.Fprintf(session.stderr, "?%v\r\n", err)
+		}
+		return false
+
+	case "mode":
+		if len(fields) < 2 {
+			fmt.Fprint(session.stderr, "?Need an argument to 'mode' command\r\n")
+			return false
+		}
+		switch fields[1] {
+		case "character", "char":
+			session.characterMode = true
+			session.modeSet = true
+			// Anything typed before switching modes still needs to go out.
+			if 0 < session.line.Len() {
+				if err := session.send(session.line.Bytes()); nil != err {
+					return true
+				}
+				session.line.Reset()
+			}
+		case "line":
+			session.characterMode = false
+			session.modeSet = true
+		default:
+			fmt.Fprintf(session.stderr, "?Invalid mode: %q\r\n", fields[1])
+		}
+		return false
+
+	case "?", "help":
+		fmt.Fprint(session.stderr,
+			"close           close the connection\r\n"+
+				"quit            close the connection, and exit\r\n"+
+				"send brk        send a TELNET BRK (\"break\")\r\n"+
+				"send ayt        send a TELNET AYT (\"are you there\")\r\n"+
+				"send ip         send a TELNET IP (\"interrupt process\")\r\n"+
+				"send ao         send a TELNET AO (\"abort output\")\r\n"+
+				"send nop        send a TELNET NOP (\"no operation\")\r\n"+
+				"send escape     send the escape byte itself\r\n"+
+				"mode character  send each byte as soon as it is typed\r\n"+
+				"mode line       send a line at a time\r\n",
+		)
+		return false
+
+	default:
+		fmt.Fprintf(session.stderr, "?Invalid command: %q\r\n", fields[0])
+		return false
+	}
+}
+
+func (session *internalStandardCallerSession) sendCommand(name string) error {
+
+	if "escape" == name {
+		return session.send([]byte{session.escapeByte})
+	}
+
+	type breakSender interface {
+		SendBreak() error
+	}
+
+	type commandSender interface {
+		SendCommand(byte) error
+	}
+
+	sender, ok := session.w.(commandSender)
+	if !ok {
+		return fmt.Errorf("cannot send TELNET commands over a %T", session.w)
+	}
+
+	switch name {
+	case "brk", "break":
+		if breaker, ok := session.w.(breakSender); ok {
+			return breaker.SendBreak()
+		}
+		return sender.SendCommand(BRK)
+	case "ayt":
+		return sender.SendCommand(AYT)
+	case "ip":
+		return sender.SendCommand(IP)
+	case "ao":
+		return sender.SendCommand(AO)
+	case "nop":
+		return sender.SendCommand(NOP)
+	default:
+		return fmt.Errorf("unknown 'send' argument: %q", name)
+	}
 }
@@ -3,6 +3,7 @@ package telnet
 import (
 	"bytes"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/reiver/go-oi"
@@ -809,3 +810,165 @@ func TestStandardCallerFromServerToClient(t *testing.T) {
 
 	}
 }
+
+type testStandardCallerConn struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (conn *testStandardCallerConn) SendCommand(cmd byte) error {
+	conn.Buffer.Write([]byte{255, cmd})
+	return nil
+}
+
+func (conn *testStandardCallerConn) SendBreak() error {
+	return conn.SendCommand(243)
+}
+
+func (conn *testStandardCallerConn) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func TestStandardCallerEscape(t *testing.T) {
+
+	tests := []struct {
+		Bytes          []byte
+		Expected       []byte
+		ExpectedClosed bool
+	}{
+		{
+			Bytes:    []byte("\x1d\nsend ayt\n"),
+			Expected: []byte{255, 246},
+		},
+		{
+			Bytes:    []byte("\x1dsend brk\n"),
+			Expected: []byte{255, 243},
+		},
+		{
+			Bytes:    []byte("apple\n\x1d\nsend ayt\nbanana\n"),
+			Expected: []byte("apple\r\n\xff\xf6banana\r\n"),
+		},
+		{
+			Bytes:    []byte("app\x1d\nsend ip\nle\n"),
+			Expected: []byte("\xff\xf4apple\r\n"),
+		},
+		{
+			Bytes:    []byte("\x1d\x1d\n"),
+			Expected: []byte("\x1d\r\n"),
+		},
+		{
+			Bytes:    []byte("a\x1d\x1db\n"),
+			Expected: []byte("a\x1db\r\n"),
+		},
+		{
+			Bytes:    []byte("\x1d\n\napple\n"),
+			Expected: []byte("apple\r\n"),
+		},
+		{
+			Bytes:    []byte("\x1d\nmode character\nab\n"),
+			Expected: []byte("ab\r\n"),
+		},
+		{
+			Bytes:          []byte("apple\n\x1d\nquit\nbanana\n"),
+			Expected:       []byte("apple\r\n"),
+			ExpectedClosed: true,
+		},
+		{
+			Bytes:          []byte("\x1dclose\nbanana\n"),
+			Expected:       []byte{},
+			ExpectedClosed: true,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var stdoutBuffer bytes.Buffer
+		var stderrBuffer bytes.Buffer
+
+		stdin := io.NopCloser(bytes.NewReader(test.Bytes))
+		stdout := oi.WriteNopCloser(&stdoutBuffer)
+		stderr := oi.WriteNopCloser(&stderrBuffer)
+
+		var ctx Context = nil
+
+		var conn testStandardCallerConn
+
+		dataReader := newDataReader(bytes.NewReader([]byte{}))
+
+		standardCallerCallTELNET(stdin, stdout, stderr, ctx, &conn, dataReader)
+
+		if expected, actual := string(test.Expected), conn.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for %q.", testNumber, expected, actual, test.Bytes)
+			continue
+		}
+
+		if expected, actual := test.ExpectedClosed, conn.closed; expected != actual {
+			t.Errorf("For test #%d, expected closed to be %t, but actually was %t; for %q.", testNumber, expected, actual, test.Bytes)
+			continue
+		}
+	}
+}
+
+// testChunkedReader returns each of its chunks from a Read of its own; the way a terminal gives
+// what is typed, a key at a time.
+type testChunkedReader struct {
+	chunks [][]byte
+}
+
+func (reader *testChunkedReader) Read(p []byte) (int, error) {
+	if len(reader.chunks) <= 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, reader.chunks[0])
+	reader.chunks[0] = reader.chunks[0][n:]
+	if len(reader.chunks[0]) <= 0 {
+		reader.chunks = reader.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestStandardCallerEscapeTwiceTyped(t *testing.T) {
+
+	tests := []struct {
+		Chunks   [][]byte
+		Expected []byte
+	}{
+		{
+			Chunks:   [][]byte{[]byte("\x1d"), []byte("\x1d"), []byte("\n")},
+			Expected: []byte("\x1d\r\n"),
+		},
+		{
+			Chunks:   [][]byte{[]byte("a"), []byte("\x1d"), []byte("\x1d"), []byte("b"), []byte("\n")},
+			Expected: []byte("a\x1db\r\n"),
+		},
+		{
+			Chunks:   [][]byte{[]byte("\x1d"), []byte("send ayt\n"), []byte("a\n")},
+			Expected: []byte("\xff\xf6a\r\n"),
+		},
+	}
+
+	for testNumber, test := range tests {
+		var stdoutBuffer bytes.Buffer
+		var stderrBuffer bytes.Buffer
+
+		stdin := io.NopCloser(&testChunkedReader{chunks: append([][]byte(nil), test.Chunks...)})
+		stdout := oi.WriteNopCloser(&stdoutBuffer)
+		stderr := oi.WriteNopCloser(&stderrBuffer)
+
+		var conn testStandardCallerConn
+
+		dataReader := newDataReader(bytes.NewReader([]byte{}))
+
+		standardCallerCallTELNET(stdin, stdout, stderr, nil, &conn, dataReader)
+
+		if expected, actual := string(test.Expected), conn.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for %q.", testNumber, expected, actual, test.Chunks)
+			continue
+		}
+
+		if strings.Contains(stderrBuffer.String(), "?Invalid command") {
+			t.Errorf("For test #%d, did not expect an invalid command, but actually got: %q", testNumber, stderrBuffer.String())
+		}
+	}
+}
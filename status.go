@@ -0,0 +1,362 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The (first) bytes of the subnegotiations of the STATUS option. (See RFC 859.)
+const (
+	statusIS   = 0
+	statusSEND = 1
+)
+
+// statusPoll is how often QueryPeerStatus checks whether the peer has answered its DO STATUS.
+// (A refusal does not change whether the option is enabled; so it is not told of it.)
+const statusPoll = 10 * time.Millisecond
+
+// ErrStatusRefused is returned by QueryPeerStatus when the peer refuses (i.e., answers the DO
+// with a WONT) to perform the STATUS option.
+var ErrStatusRefused = errors.New("telnet: peer refused the STATUS option")
+
+// StatusOption returns an OptionHandler for the STATUS option (RFC 859). With it registered, the
+// Conn answers a DO STATUS with a WILL; and (once it does) answers each:
+//
+//	IAC SB STATUS SEND IAC SE
+//
+// ... with what it thinks the negotiated state is. For example:
+//
+//	conn.RegisterOption(telnet.OptStatus, telnet.StatusOption())
+//
+// (It also accepts the peer's WILL STATUS; which is what QueryPeerStatus needs.)
+func StatusOption() OptionHandler {
+	return &internalStatus{}
+}
+
+// OptionStatus is the state of an option, as the peer told it with the STATUS option. (See
+// QueryPeerStatus.)
+type OptionStatus struct {
+	Will bool // Whether the peer says it is performing the option. (Our OptionState.Remote.)
+	Do   bool // Whether the peer says we are performing the option. (Our OptionState.Local.)
+}
+
+// String returns a (short) human readable description of the OptionStatus. (Ex: "will=yes do=no".)
+func (status OptionStatus) String() string {
+	return "will=" + optionStateSide(status.Will, false) + " do=" + optionStateSide(status.Do, false)
+}
+
+// StatusMismatch is an option whose state, as the peer told it, is not what the Conn thinks it is.
+type StatusMismatch struct {
+	Option byte
+	Peer   OptionStatus // What the peer thinks.
+	Local  OptionStatus // What the Conn thinks.
+}
+
+// String returns a (short) human readable description of the StatusMismatch.
+//
+// For example:
+//
+//	ECHO: peer says will=yes do=no; but it is will=no do=no
+func (mismatch StatusMismatch) String() string {
+	return fmt.Sprintf("%s: peer says %v; but it is %v", OptionName(mismatch.Option), mismatch.Peer, mismatch.Local)
+}
+
+// PeerStatus is what QueryPeerStatus returns.
+type PeerStatus struct {
+	// Options are the options that the peer says are enabled (on either side).
+	Options map[byte]OptionStatus
+
+	// Mismatches are the options (in numerical order) that the peer, and the Conn, disagree on.
+	Mismatches []StatusMismatch
+}
+
+// QueryPeerStatus asks the peer (with the STATUS option; RFC 859) what it thinks the negotiated
+// state is; and compares that to what the Conn thinks it is (see OptionStates). This is for
+// debugging interoperability problems; such as with old terminal servers. For example:
+//
+//	status, err := conn.QueryPeerStatus(ctx)
+//	if nil != err {
+//		return err
+//	}
+//	for _, mismatch := range status.Mismatches {
+//		log.Print(mismatch)
+//	}
+//
+// If the peer is not performing STATUS yet, then it is asked to (with a DO); and, if it refuses,
+// then ErrStatusRefused is returned. (It registers a StatusOption for STATUS, if one is not
+// already; replacing whatever was registered before.)
+//
+// The reply is received like any other subnegotiation; so something has to be reading from the
+// Conn (such as with Read) while QueryPeerStatus waits for it. It waits until 'ctx' is done, at
+// most.
+func (clientConn *Conn) QueryPeerStatus(ctx context.Context) (PeerStatus, error) {
+	status, ok := clientConn.negotiator.handler(OptStatus).(*internalStatus)
+	if !ok {
+		status = &internalStatus{}
+		if err := clientConn.RegisterOption(OptStatus, status); nil != err {
+			return PeerStatus{}, err
+		}
+	}
+
+	if err := clientConn.statusEnabled(ctx, status); nil != err {
+		return PeerStatus{}, err
+	}
+
+	reply := status.await()
+	defer status.forget(reply)
+
+	if err := clientConn.SendSubnegotiation(OptStatus, []byte{statusSEND}); nil != err {
+		return PeerStatus{}, err
+	}
+
+	select {
+	case options := <-reply:
+		return PeerStatus{Options: options, Mismatches: statusMismatches(options, clientConn.OptionStates())}, nil
+	case <-clientConn.done:
+		return PeerStatus{}, ErrClosed
+	case <-ctx.Done():
+		return PeerStatus{}, ctx.Err()
+	}
+}
+
+// statusEnabled has the peer perform the STATUS option (if it is not already); and waits for it to.
+func (clientConn *Conn) statusEnabled(ctx context.Context, status *internalStatus) error {
+	if _, remote := clientConn.OptionEnabled(OptStatus); remote {
+		return nil
+	}
+
+	changed := status.changes()
+	if err := clientConn.negotiator.request(OptStatus, false, true); nil != err {
+		return err
+	}
+
+	ticker := time.NewTicker(statusPoll)
+	defer ticker.Stop()
+
+	for {
+		state := clientConn.OptionStates()[OptStatus]
+		switch {
+		case state.Remote:
+			return nil
+		case !state.RemotePending:
+			return ErrStatusRefused
+		}
+
+		select {
+		case <-changed:
+			changed = status.changes()
+		case <-ticker.C:
+		case <-clientConn.done:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// statusMismatches returns the options that 'options' (what the peer says) and 'states' (what the
+// Conn thinks) disagree on; in numerical order.
+func statusMismatches(options map[byte]OptionStatus, states OptionStates) []StatusMismatch {
+	var mismatches []StatusMismatch
+
+	for option := 0; option <= 255; option++ {
+		state := states[byte(option)]
+		local := OptionStatus{Will: state.Remote, Do: state.Local}
+
+		if peer := options[byte(option)]; peer != local {
+			mismatches = append(mismatches, StatusMismatch{Option: byte(option), Peer: peer, Local: local})
+		}
+	}
+
+	return mismatches
+}
+
+// internalStatus is the OptionHandler that StatusOption returns.
+type internalStatus struct {
+	mutex   sync.Mutex
+	sender  OptionSender
+	changed chan struct{} // (If not nil) closed once the option is enabled, or disabled, on the peer's side.
+	replies map[chan map[byte]OptionStatus]struct{}
+}
+
+func (status *internalStatus) Register(sender OptionSender) OptionSupport {
+	status.mutex.Lock()
+	status.sender = sender
+	status.mutex.Unlock()
+
+	return OptionSupport{
+		Local:  true,
+		Remote: true,
+	}
+}
+
+func (*internalStatus) LocalChanged(bool) {}
+
+func (status *internalStatus) RemoteChanged(bool) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	if nil != status.changed {
+		close(status.changed)
+		status.changed = nil
+	}
+}
+
+func (status *internalStatus) Subnegotiation(payload []byte) {
+	if len(payload) < 1 {
+		return
+	}
+
+	switch payload[0] {
+	case statusSEND:
+		status.send()
+	case statusIS:
+		status.received(decodeStatus(payload[1:]))
+	}
+}
+
+// changes returns a channel that is closed once the option is enabled, or disabled, on the peer's side.
+func (status *internalStatus) changes() <-chan struct{} {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	if nil == status.changed {
+		status.changed = make(chan struct{})
+	}
+	return status.changed
+}
+
+// await returns a channel that the next IS (that the peer sends) is sent to.
+func (status *internalStatus) await() chan map[byte]OptionStatus {
+	reply := make(chan map[byte]OptionStatus, 1)
+
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	if nil == status.replies {
+		status.replies = map[chan map[byte]OptionStatus]struct{}{}
+	}
+	status.replies[reply] = struct{}{}
+
+	return reply
+}
+
+func (status *internalStatus) forget(reply chan map[byte]OptionStatus) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	delete(status.replies, reply)
+}
+
+// received is called with (what was decoded from) an IS, that the peer sent.
+func (status *internalStatus) received(options map[byte]OptionStatus) {
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	for reply := range status.replies {
+		// (Each waits for a single IS; and has room for it.)
+		select {
+		case reply <- options:
+		default:
+		}
+	}
+}
+
+// send answers a SEND; with what the Conn thinks the negotiated state is. (It is only answered
+// if we are performing STATUS; per RFC 859.)
+func (status *internalStatus) send() {
+	status.mutex.Lock()
+	sender := status.sender
+	status.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+
+	conn := sender.Conn()
+	if local, _ := conn.OptionEnabled(OptStatus); !local {
+		return
+	}
+
+	if err := sender.SendSubnegotiation(encodeStatus(conn.OptionStates())); nil != err {
+		conn.logger.Debugf("Problem sending STATUS IS: %v", err)
+	}
+}
+
+// encodeStatus returns the payload of the IS for 'states':
+//
+//	IS WILL <option> ... DO <option> ...
+//
+// I.e., a WILL for each option we are performing, and a DO for each option the peer is. A SE
+// (as an option code) is doubled; as RFC 859 says to. (The IAC are doubled by SendSubnegotiation.)
+func encodeStatus(states OptionStates) []byte {
+	payload := []byte{statusIS}
+
+	for option := 0; option <= 255; option++ {
+		state := states[byte(option)]
+
+		if state.Local {
+			payload = appendStatusOption(payload, WILL, byte(option))
+		}
+		if state.Remote {
+			payload = appendStatusOption(payload, DO, byte(option))
+		}
+	}
+
+	return payload
+}
+
+func appendStatusOption(payload []byte, verb byte, option byte) []byte {
+	payload = append(payload, verb, option)
+	if SE == option {
+		payload = append(payload, SE)
+	}
+
+	return payload
+}
+
+// decodeStatus returns (by option) what the (un-escaped) 'data' of an IS says; 'data' being what
+// comes after the IS. (The subnegotiation state, "SB <option> ... SE", that it might have is skipped.)
+func decodeStatus(data []byte) map[byte]OptionStatus {
+	options := map[byte]OptionStatus{}
+
+	for i := 0; i+1 < len(data); {
+		verb, option := data[i], data[i+1]
+		i += 2
+
+		// (A SE, as the option code, is doubled.)
+		if SE == option && i < len(data) && SE == data[i] {
+			i++
+		}
+
+		switch verb {
+		case WILL:
+			status := options[option]
+			status.Will = true
+			options[option] = status
+		case DO:
+			status := options[option]
+			status.Do = true
+			options[option] = status
+		case SB:
+			// Skip to the SE (that is not doubled).
+			for i < len(data) {
+				if SE != data[i] {
+					i++
+					continue
+				}
+				if i+1 < len(data) && SE == data[i+1] {
+					i += 2
+					continue
+				}
+				i++
+				break
+			}
+		}
+	}
+
+	return options
+}
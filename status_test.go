@@ -0,0 +1,182 @@
+package telnet
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"time"
+
+	"testing"
+)
+
+func TestConnStatusAnswersSend(t *testing.T) {
+
+	tests := []struct {
+		Options  []byte
+		Expected []byte
+	}{
+		{
+			Expected: []byte{IAC, SB, OptStatus, statusIS, WILL, OptStatus, IAC, SE},
+		},
+		{
+			Options:  []byte{OptEcho},
+			Expected: []byte{IAC, SB, OptStatus, statusIS, WILL, OptEcho, WILL, OptStatus, IAC, SE},
+		},
+		{
+			// (An IAC, as the option code, is doubled; as always.)
+			Options:  []byte{255},
+			Expected: []byte{IAC, SB, OptStatus, statusIS, WILL, OptStatus, WILL, IAC, IAC, IAC, SE},
+		},
+		{
+			// (A SE, as the option code, is doubled too; per RFC 859.)
+			Options:  []byte{SE},
+			Expected: []byte{IAC, SB, OptStatus, statusIS, WILL, OptStatus, WILL, SE, SE, IAC, SE},
+		},
+		{
+			Options:  []byte{SE, 255},
+			Expected: []byte{IAC, SB, OptStatus, statusIS, WILL, OptStatus, WILL, SE, SE, WILL, IAC, IAC, IAC, SE},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		if err := conn.RegisterOption(OptStatus, StatusOption()); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		for _, option := range append([]byte{OptStatus}, test.Options...) {
+			if OptStatus != option {
+				conn.RegisterOption(option, SimpleOption(OptionSupport{Local: true}))
+			}
+			remote.Write([]byte{IAC, DO, option})
+			if expected, actual := []byte{IAC, WILL, option}, testReadExactly(t, remote, 3); !bytes.Equal(expected, actual) {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		}
+
+		remote.Write([]byte{IAC, SB, OptStatus, statusSEND, IAC, SE})
+
+		if expected, actual := test.Expected, testReadExactly(t, remote, len(test.Expected)); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnQueryPeerStatusMismatches(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	go func() {
+		p := make([]byte, 6)
+		if _, err := io.ReadFull(remote, p[:3]); nil != err { // IAC DO STATUS
+			return
+		}
+		remote.Write([]byte{IAC, WILL, OptStatus})
+		if _, err := io.ReadFull(remote, p); nil != err { // IAC SB STATUS SEND IAC SE
+			return
+		}
+		remote.Write([]byte{
+			IAC, SB, OptStatus, statusIS,
+			WILL, OptEcho,
+			DO, IAC, IAC,
+			WILL, SE, SE,
+			SB, OptTerminalType, 0, 'x', SE, SE, 'y', SE,
+			DO, OptSuppressGoAhead,
+			IAC, SE,
+		})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := conn.QueryPeerStatus(ctx)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	expectedOptions := map[byte]OptionStatus{
+		OptEcho:            {Will: true},
+		255:                {Do: true},
+		SE:                 {Will: true},
+		OptSuppressGoAhead: {Do: true},
+	}
+	if expected, actual := expectedOptions, status.Options; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the options to be %v, but actually got %v.", expected, actual)
+	}
+
+	expectedMismatches := []StatusMismatch{
+		{Option: OptEcho, Peer: OptionStatus{Will: true}},
+		{Option: OptSuppressGoAhead, Peer: OptionStatus{Do: true}},
+		{Option: OptStatus, Local: OptionStatus{Will: true}},
+		{Option: SE, Peer: OptionStatus{Will: true}},
+		{Option: 255, Peer: OptionStatus{Do: true}},
+	}
+	if expected, actual := expectedMismatches, status.Mismatches; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the mismatches to be %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestConnQueryPeerStatusRoundTrip(t *testing.T) {
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	clientConn := newConn(client, nil)
+	serverConn := newConn(server, nil)
+	go io.Copy(io.Discard, clientConn)
+	go io.Copy(io.Discard, serverConn)
+
+	if err := serverConn.RegisterOption(OptStatus, StatusOption()); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (Options whose codes collide with the escaping; enabled on both sides.)
+	for _, option := range []byte{255, SE, OptEcho} {
+		serverConn.RegisterOption(option, SimpleOption(OptionSupport{Local: true, Remote: true}))
+		clientConn.RegisterOption(option, SimpleOption(OptionSupport{Local: true, Remote: true, RequestLocal: true, RequestRemote: true}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := clientConn.QueryPeerStatus(ctx)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	expected := map[byte]OptionStatus{
+		OptEcho:   {Will: true, Do: true},
+		OptStatus: {Will: true},
+		SE:        {Will: true, Do: true},
+		255:       {Will: true, Do: true},
+	}
+	if actual := status.Options; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected the options to be %v, but actually got %v.", expected, actual)
+	}
+	if actual := status.Mismatches; 0 != len(actual) {
+		t.Errorf("Expected no mismatches, but actually got %v.", actual)
+	}
+}
+
+func TestConnQueryPeerStatusRefused(t *testing.T) {
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	clientConn := newConn(client, nil)
+	serverConn := newConn(server, nil)
+	go io.Copy(io.Discard, clientConn)
+	go io.Copy(io.Discard, serverConn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if _, err := clientConn.QueryPeerStatus(ctx); ErrStatusRefused != err {
+		t.Errorf("Expected the error to be ErrStatusRefused, but actually got: (%T) %v", err, err)
+	}
+}
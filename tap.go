@@ -0,0 +1,176 @@
+package telnet
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// A Tap is what a Conn mirrors what it sends, and receives, to; for recording a session (such as
+// for an audit, or to replay it later). (See Conn.SetTap.) Any (or all) of its fields can be nil.
+type Tap struct {
+	// Inbound is written what is received from the peer; and Outbound what is sent to the peer.
+	// As-is; i.e., with the TELNET commands (and the escaped IACs) in it, just as on the wire.
+	// (So a recording of what Inbound is written can be replayed by having a Conn read it.)
+	Inbound  io.Writer
+	Outbound io.Writer
+
+	// Event is called with each TELNET command that is received from, or sent to, the peer;
+	// decoded. (Including the option negotiations, and subnegotiations.)
+	Event func(event TapEvent)
+}
+
+// A TapEvent is a TELNET command that was received from, or sent to, the peer. (See Tap.Event.)
+type TapEvent struct {
+	Time time.Time
+
+	// Direction is Inbound if the peer sent this, and Outbound if we sent this.
+	Direction Direction
+
+	// Command is the TELNET command (such as NOP, GA, or WILL); which is SB for a subnegotiation.
+	// Option is the option of an option negotiation (i.e., WILL, WONT, DO, or DONT), or of a
+	// subnegotiation; and Payload is the (un-escaped) payload of a subnegotiation.
+	Command byte
+	Option  byte
+	Payload []byte
+}
+
+// String returns the event as the peer would see it (and then which way it went); such as
+// "IAC DO ECHO (inbound)", or "IAC SB NAWS <4 bytes> IAC SE (outbound)".
+func (event TapEvent) String() string {
+	return commandString(event.Command, event.Option, event.Payload) + " (" + event.Direction.String() + ")"
+}
+
+// SetTap sets what the Conn mirrors what it sends, and receives, to; or, if 'tap' is nil, stops
+// it. For example, to record a session:
+//
+//	conn.SetTap(&telnet.Tap{
+//		Inbound:  inboundFile,
+//		Outbound: outboundFile,
+//		Event: func(event telnet.TapEvent) {
+//			log.Printf("%v: %v", conn.RemoteAddr(), event)
+//		},
+//	})
+//
+// (To tap every connection of a Server, or of a Dialer, call SetTap from Trace.Connected.)
+//
+// What is received is mirrored as it is read off the connection (before it is un-escaped, and
+// before the TELNET commands are taken out of it); and what is sent as it is written to the
+// connection (after it is escaped). If the Conn is compressing (see OfferCompression, and
+// AcceptCompression), then that is the compressed stream; as that is what is on the wire. (The
+// Event is told of the commands in it either way.)
+//
+// Inbound (and the Event, for what is received) is written by whatever goroutine is reading from
+// the Conn; and Outbound (and the Event, for what is sent) by whatever goroutine is writing. So,
+// if Inbound and Outbound are the same io.Writer, it has to be safe for concurrent use; as does
+// the Event. What they return (such as an error) is ignored; and they block the Conn until they
+// return, so they should be quick.
+func (clientConn *Conn) SetTap(tap *Tap) {
+	clientConn.tap.Store(tap)
+}
+
+// tapEvent tells the Event of the Tap (if there is one) of the TELNET command; which went in
+// 'direction'.
+func (clientConn *Conn) tapEvent(direction Direction, cmd byte, option byte, payload []byte) {
+	tap := clientConn.tap.Load()
+	if nil == tap || nil == tap.Event {
+		return
+	}
+
+	tap.Event(TapEvent{
+		Time:      time.Now(),
+		Direction: direction,
+		Command:   cmd,
+		Option:    option,
+		Payload:   payload,
+	})
+}
+
+// tapCommands tells the Event of the Tap (if there is one) of each of the TELNET commands in 'p';
+// which are being sent. (See writeCommand.)
+func (clientConn *Conn) tapCommands(p []byte) {
+	if tap := clientConn.tap.Load(); nil == tap || nil == tap.Event {
+		return
+	}
+
+	for i := 0; i+1 < len(p); {
+		if IAC != p[i] {
+			i++
+			continue
+		}
+
+		cmd := p[i+1]
+		switch cmd {
+		case WILL, WONT, DO, DONT:
+			if len(p) <= i+2 {
+				return
+			}
+			clientConn.tapEvent(Outbound, cmd, p[i+2], nil)
+			i += 3
+		case SB:
+			// (The option, and the payload, are escaped; up to the IAC SE.)
+			var option byte
+			var payload []byte
+			k, first := i+2, true
+			for k < len(p) {
+				b := p[k]
+				if IAC == b {
+					if len(p) <= k+1 || SE == p[k+1] {
+						k += 2
+						break
+					}
+					k++
+				}
+				if first {
+					option, first = b, false
+				} else {
+					payload = append(payload, b)
+				}
+				k++
+			}
+			clientConn.tapEvent(Outbound, SB, option, payload)
+			i = k
+		case IAC:
+			i += 2
+		default:
+			clientConn.tapEvent(Outbound, cmd, 0, nil)
+			i += 2
+		}
+	}
+}
+
+// internalTapReader is what the Conn reads from the connection through; which mirrors what it
+// read to the Inbound of the Tap (if there is one).
+type internalTapReader struct {
+	reader io.Reader
+	tap    *atomic.Pointer[Tap]
+}
+
+func (reader internalTapReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if 0 < n {
+		if tap := reader.tap.Load(); nil != tap && nil != tap.Inbound {
+			tap.Inbound.Write(p[:n])
+		}
+	}
+
+	return n, err
+}
+
+// internalTapWriter is what the Conn writes to the connection through; which mirrors what it
+// wrote to the Outbound of the Tap (if there is one).
+type internalTapWriter struct {
+	writer io.Writer
+	tap    *atomic.Pointer[Tap]
+}
+
+func (writer internalTapWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	if 0 < n {
+		if tap := writer.tap.Load(); nil != tap && nil != tap.Outbound {
+			tap.Outbound.Write(p[:n])
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,153 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testTapRecorder records what a Tap is written, and told of.
+type testTapRecorder struct {
+	mutex    sync.Mutex
+	inbound  bytes.Buffer
+	outbound bytes.Buffer
+	events   []string
+}
+
+func (recorder *testTapRecorder) tap() *Tap {
+	return &Tap{
+		Inbound:  testTapWriter{recorder, &recorder.inbound},
+		Outbound: testTapWriter{recorder, &recorder.outbound},
+		Event: func(event TapEvent) {
+			recorder.mutex.Lock()
+			recorder.events = append(recorder.events, event.String())
+			recorder.mutex.Unlock()
+		},
+	}
+}
+
+func (recorder *testTapRecorder) get() (string, string, []string) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.inbound.String(), recorder.outbound.String(), append([]string(nil), recorder.events...)
+}
+
+type testTapWriter struct {
+	recorder *testTapRecorder
+	buffer   *bytes.Buffer
+}
+
+func (w testTapWriter) Write(p []byte) (int, error) {
+	w.recorder.mutex.Lock()
+	defer w.recorder.mutex.Unlock()
+
+	return w.buffer.Write(p)
+}
+
+func TestConnTapCommands(t *testing.T) {
+
+	tests := []struct {
+		Command  []byte
+		Expected []string
+	}{
+		{
+			Command:  []byte{IAC, NOP},
+			Expected: []string{"IAC NOP (outbound)"},
+		},
+		{
+			Command:  []byte{IAC, WILL, OptEcho},
+			Expected: []string{"IAC WILL ECHO (outbound)"},
+		},
+		{
+			Command:  []byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE},
+			Expected: []string{"IAC SB NAWS <4 bytes> IAC SE (outbound)"},
+		},
+		{
+			// (The payload is un-escaped.)
+			Command:  []byte{IAC, SB, OptNAWS, 0, IAC, IAC, 0, 24, IAC, SE, IAC, GA},
+			Expected: []string{"IAC SB NAWS <4 bytes> IAC SE (outbound)", "IAC GA (outbound)"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, _ := testPipe(t)
+
+		var recorder testTapRecorder
+		conn.SetTap(recorder.tap())
+		conn.tapCommands(test.Command)
+
+		if _, _, actual := recorder.get(); !testEqualStrings(test.Expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, test.Expected, actual)
+			continue
+		}
+	}
+}
+
+func TestConnTap(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	conn := newConn(local, nil)
+	defer conn.Close()
+
+	var recorder testTapRecorder
+	conn.SetTap(recorder.tap())
+
+	// (What the Conn answers with is what it sends.)
+	answers := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, 3)
+		io.ReadFull(remote, p)
+		answers <- p
+		io.Copy(io.Discard, remote)
+	}()
+
+	received := []byte{'h', 'i', IAC, IAC, IAC, NOP, IAC, DO, OptEcho, '!'}
+	go remote.Write(received)
+
+	p := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "hi\xff!", string(p); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	select {
+	case answer := <-answers:
+		if expected, actual := string([]byte{IAC, WONT, OptEcho}), string(answer); expected != actual {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected an answer, but actually did not get one.")
+	}
+
+	if _, err := conn.Write([]byte("ok\xff")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	inbound, outbound, events := recorder.get()
+	if expected, actual := string(received), inbound; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := string([]byte{IAC, WONT, OptEcho})+"ok\xff\xff", outbound; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := []string{"IAC NOP (inbound)", "IAC DO ECHO (inbound)", "IAC WONT ECHO (outbound)"}, events; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (Once it is stopped, nothing more is mirrored.)
+	conn.SetTap(nil)
+	conn.Write([]byte("bye"))
+	if _, actual, _ := recorder.get(); outbound != actual {
+		t.Errorf("Expected %q, but actually got %q.", outbound, actual)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Package telnettest provides utilities for testing TELNET (and TELNETS) handlers, and clients; much
+like net/http/httptest does for HTTP.
+
+# Server
+
+Here is an example usage:
+
+	func TestHandler(t *testing.T) {
+
+		server := telnettest.NewServer(handler)
+		defer server.Close()
+
+		conn, err := telnet.DialTo(server.Addr)
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		defer conn.Close()
+
+		//@TODO: Talk to the handler, over 'conn'.
+	}
+
+# TLS Server
+
+A TELNETS server gets a (self-signed) certificate, for "127.0.0.1", and "localhost", made for it;
+unless it is given its own TLS config. For example:
+
+	server := telnettest.NewTLSServer(handler)
+	defer server.Close()
+
+	conn, err := server.DialTLS(nil)
+
+(NewCertificate makes certificates; such as for testing a server with a certificate for each SNI
+name.)
+*/
+package telnettest
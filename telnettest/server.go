@@ -0,0 +1,127 @@
+package telnettest
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"crypto/tls"
+	"fmt"
+	"net"
+)
+
+// Server is a TELNET (or TELNETS) server, listening on a (random) port on the loopback address;
+// for (end-to-end) tests.
+type Server struct {
+	// Addr is the address the server is listening on; in the form "127.0.0.1:port".
+	Addr string
+
+	Listener net.Listener
+
+	// Config is the telnet.Server; which can be changed (before Start, or StartTLS, is called) such
+	// as to set its InputLimits.
+	Config *telnet.Server
+
+	// TLS is the TLS config the server uses (once StartTLS is called). It can be set before
+	// StartTLS is called; otherwise a (self-signed) certificate is made for it.
+	TLS *tls.Config
+
+	// certificates are what the server was given, or made; for DialTLS to trust.
+	certificates []tls.Certificate
+
+	started bool
+	served  chan error
+}
+
+// NewServer starts (and returns) a TELNET server, that serves each connection with 'handler'.
+// (Close it when done with it.)
+func NewServer(handler telnet.Handler) *Server {
+	server := NewUnstartedServer(handler)
+	server.Start()
+	return server
+}
+
+// NewTLSServer starts (and returns) a TELNETS server, that serves each connection with 'handler'.
+// (Close it when done with it.)
+func NewTLSServer(handler telnet.Handler) *Server {
+	server := NewUnstartedServer(handler)
+	server.StartTLS()
+	return server
+}
+
+// NewUnstartedServer returns a server (that serves each connection with 'handler'); but does not
+// start it. This is so that its Config, or TLS, can be changed first. (Start it with Start, or
+// StartTLS; and close it when done with it.)
+func NewUnstartedServer(handler telnet.Handler) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		panic(fmt.Sprintf("telnettest: failed to listen on a port: %v", err))
+	}
+
+	return &Server{
+		Addr:     listener.Addr().String(),
+		Listener: listener,
+		Config:   &telnet.Server{Handler: handler},
+		served:   make(chan error, 1),
+	}
+}
+
+// Start starts the (TELNET) server.
+func (server *Server) Start() {
+	if server.started {
+		panic("telnettest: server already started")
+	}
+	server.started = true
+
+	go func() {
+		server.served <- server.Config.Serve(server.Listener)
+	}()
+}
+
+// StartTLS starts the server as a TELNETS (i.e., TELNET over TLS) server.
+func (server *Server) StartTLS() {
+	if server.started {
+		panic("telnettest: server already started")
+	}
+	server.started = true
+
+	if nil == server.TLS {
+		server.TLS = &tls.Config{}
+	}
+	if 0 == len(server.TLS.Certificates) && nil == server.TLS.GetCertificate && nil == server.TLS.GetConfigForClient {
+		certificate, err := NewCertificate("127.0.0.1", "localhost")
+		if nil != err {
+			panic(fmt.Sprintf("telnettest: failed to make a certificate: %v", err))
+		}
+		server.TLS.Certificates = []tls.Certificate{certificate}
+	}
+	server.certificates = append(server.certificates, server.TLS.Certificates...)
+
+	server.Config.TLSConfig = server.TLS
+
+	go func() {
+		server.served <- server.Config.ServeTLS(server.Listener, "", "")
+	}()
+}
+
+// DialTLS makes a TELNETS client connection to the server. If 'tlsConfig' is nil, then it trusts
+// the certificates of the server's TLS config (see NewCertificate, and CertPool) for the name
+// "127.0.0.1".
+func (server *Server) DialTLS(tlsConfig *tls.Config) (*telnet.Conn, error) {
+	if nil == tlsConfig {
+		tlsConfig = &tls.Config{
+			RootCAs:    CertPool(server.certificates...),
+			ServerName: "127.0.0.1",
+		}
+	}
+
+	return telnet.DialToTLS(server.Addr, tlsConfig)
+}
+
+// Close stops the server (from accepting any more connections); and waits for it to stop.
+// (The connections that are still being served are not closed.)
+func (server *Server) Close() {
+	server.Listener.Close()
+	if server.started {
+		<-server.served
+		server.started = false
+	}
+}
@@ -0,0 +1,76 @@
+package telnettest
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"io"
+	"time"
+
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+
+	tests := []struct {
+		TLS bool
+	}{
+		{TLS: false},
+		{TLS: true},
+	}
+
+	for testNumber, test := range tests {
+		var server *Server
+		var conn *telnet.Conn
+		var err error
+		if test.TLS {
+			server = NewTLSServer(telnet.EchoHandler)
+			conn, err = server.DialTLS(nil)
+		} else {
+			server = NewServer(telnet.EchoHandler)
+			conn, err = telnet.DialTo(server.Addr)
+		}
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			server.Close()
+			continue
+		}
+
+		conn.Write([]byte("hello"))
+
+		done := make(chan string, 1)
+		go func() {
+			p := make([]byte, 5)
+			io.ReadFull(conn, p)
+			done <- string(p)
+		}()
+
+		select {
+		case actual := <-done:
+			if expected := "hello"; expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("For test #%d, timed out waiting for the echo.", testNumber)
+		}
+
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestNewCertificate(t *testing.T) {
+
+	certificate, err := NewCertificate("example.com", "127.0.0.1")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	for _, name := range []string{"example.com", "127.0.0.1"} {
+		if err := certificate.Leaf.VerifyHostname(name); nil != err {
+			t.Errorf("For %q, did not expect an error, but actually got one: (%T) %v", name, err, err)
+		}
+	}
+	if err := certificate.Leaf.VerifyHostname("example.net"); nil == err {
+		t.Errorf("Expected an error for %q, but did not actually get one.", "example.net")
+	}
+}
@@ -0,0 +1,160 @@
+package telsh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxAliasDepth is how many times aliases are expanded (for a single command line) before
+// giving up; so that aliases that (directly or indirectly) refer to themselves do not loop forever.
+const maxAliasDepth = 16
+
+var (
+	errAliasTooDeep     = errors.New("aliases nested too deeply")
+	errAmbiguousCommand = errors.New("ambiguous command")
+)
+
+// Alias makes 'name' an alias for 'expansion'.
+//
+// When a command line starts with 'name', then 'name' is replaced with 'expansion' before
+// the command line is split into arguments (see Tokenize). For example, with:
+//
+//	shellHandler.Alias("ll", "list --long")
+//
+// ... the command line:
+//
+//	ll /tmp
+//
+// ... runs:
+//
+//	list --long /tmp
+//
+// An alias can expand to another alias. But (like in other shells) an alias is not expanded
+// again within its own expansion; so, for example, this works:
+//
+//	shellHandler.Alias("ls", "ls -F")
+func (telnetHandler *ShellHandler) Alias(name string, expansion string) error {
+
+	telnetHandler.muxtex.Lock()
+	if nil == telnetHandler.aliases {
+		telnetHandler.aliases = map[string]string{}
+	}
+	telnetHandler.aliases[name] = expansion
+	telnetHandler.muxtex.Unlock()
+
+	return nil
+}
+
+func (telnetHandler *ShellHandler) MustAlias(name string, expansion string) *ShellHandler {
+	if err := telnetHandler.Alias(name, expansion); nil != err {
+		panic(err)
+	}
+
+	return telnetHandler
+}
+
+// internalAmbiguousError is returned when an abbreviated command name could be more than one command.
+type internalAmbiguousError struct {
+	candidates []string
+}
+
+func (err internalAmbiguousError) Error() string {
+	return errAmbiguousCommand.Error() + "; could be: " + strings.Join(err.candidates, " ")
+}
+
+func (err internalAmbiguousError) Unwrap() error {
+	return errAmbiguousCommand
+}
+
+// expand expands any alias (and, if AbbreviationMatching is on, any abbreviation) that
+// the command line 'fields' starts with.
+func (telnetHandler *ShellHandler) expand(fields []string) ([]string, error) {
+
+	expanded := map[string]struct{}{}
+
+	for depth := 0; 0 < len(fields); depth++ {
+		if maxAliasDepth <= depth {
+			return fields, errAliasTooDeep
+		}
+
+		name, err := telnetHandler.resolve(fields[0])
+		if nil != err {
+			return fields, err
+		}
+
+		telnetHandler.muxtex.RLock()
+		expansion, ok := telnetHandler.aliases[name]
+		telnetHandler.muxtex.RUnlock()
+
+		if _, already := expanded[name]; !ok || already {
+			return append([]string{name}, fields[1:]...), nil
+		}
+		expanded[name] = struct{}{}
+
+		tokens, err := Tokenize(expansion)
+		if nil != err {
+			return fields, err
+		}
+		fields = append(tokens, fields[1:]...)
+	}
+
+	return fields, nil
+}
+
+// expandPipeline expands (see expand) each of the commands of a pipeline.
+//
+// If there is nothing to run (such as for a blank line), then nil is returned.
+func (telnetHandler *ShellHandler) expandPipeline(stages [][]string) ([][]string, error) {
+
+	var expanded [][]string
+
+	for _, fields := range stages {
+		if len(fields) <= 0 {
+			continue
+		}
+
+		command, err := telnetHandler.expand(fields)
+		if nil != err {
+			return nil, fmt.Errorf("%s: %w", fields[0], err)
+		}
+		if len(command) <= 0 {
+			continue
+		}
+
+		expanded = append(expanded, command)
+	}
+
+	if 0 < len(expanded) && len(expanded) < len(stages) {
+		return nil, fmt.Errorf("syntax error: %w", errMissingPipelineCommand)
+	}
+
+	return expanded, nil
+}
+
+// resolve returns the full command name for 'name'; which (if AbbreviationMatching is on)
+// might be an abbreviation.
+func (telnetHandler *ShellHandler) resolve(name string) (string, error) {
+	if !telnetHandler.AbbreviationMatching {
+		return name, nil
+	}
+
+	var candidates []string
+	for _, command := range telnetHandler.Commands() {
+		if command == name {
+			return name, nil
+		}
+		if strings.HasPrefix(command, name) {
+			candidates = append(candidates, command)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return name, nil
+	case 1:
+		return candidates[0], nil
+	default:
+		return name, internalAmbiguousError{candidates: candidates}
+	}
+}
@@ -0,0 +1,109 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"testing"
+)
+
+func TestServeTELNETAlias(t *testing.T) {
+
+	tests := []struct {
+		AbbreviationMatching bool
+		ClientSends          string
+		Expected             string
+	}{
+		{
+			ClientSends: "ll /tmp\r\n",
+			Expected:    "list -a --long /tmp\r\n",
+		},
+		{
+			ClientSends: "l\r\n", // An alias of an alias (of an alias).
+			Expected:    "list -a --long\r\n",
+		},
+		{
+			ClientSends: "list -a\r\n", // An alias of itself.
+			Expected:    "list -a -a\r\n",
+		},
+		{
+			ClientSends: "loop1\r\n",
+			Expected:    "loop1: command not found\r\n",
+		},
+		{
+			ClientSends: "deep0\r\n",
+			Expected:    "deep0: aliases nested too deeply\r\n",
+		},
+		{
+			ClientSends: "help ll\r\n",
+			Expected:    "Usage: ll\r\n\r\nAlias for \"list --long\".\r\n",
+		},
+		{
+			ClientSends: "li\r\n",
+			Expected:    "li: command not found\r\n",
+		},
+
+		{
+			AbbreviationMatching: true,
+			ClientSends:          "sho version\r\n",
+			Expected:             "show version\r\n",
+		},
+		{
+			AbbreviationMatching: true,
+			ClientSends:          "sh version\r\n",
+			Expected:             "sh: ambiguous command; could be: show shutdown\r\n",
+		},
+		{
+			AbbreviationMatching: true,
+			ClientSends:          "lis\r\n", // "list" is both a command and an alias; which is not ambiguous.
+			Expected:             "list -a\r\n",
+		},
+		{
+			AbbreviationMatching: true,
+			ClientSends:          "lo\r\n",
+			Expected:             "lo: ambiguous command; could be: logout loop1 loop2\r\n",
+		},
+		{
+			AbbreviationMatching: true,
+			ClientSends:          "x\r\n",
+			Expected:             "x: command not found\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+		shellHandler.AbbreviationMatching = test.AbbreviationMatching
+
+		for _, name := range []string{"list", "show", "shutdown"} {
+			name := name
+			shellHandler.MustRegisterHandlerFunc(name, func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+				oi.LongWriteString(stdout, name+" "+strings.Join(args, " ")+"\r\n")
+				return nil
+			})
+		}
+
+		shellHandler.MustAlias("ll", "list --long")
+		shellHandler.MustAlias("l", "ll")
+		shellHandler.MustAlias("list", "list -a")
+		shellHandler.MustAlias("loop1", "loop2")
+		shellHandler.MustAlias("loop2", "loop1")
+		for i := 0; i < 20; i++ {
+			shellHandler.MustAlias("deep"+strconv.Itoa(i), "deep"+strconv.Itoa(i+1))
+		}
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := shellHandler.WelcomeMessage+shellHandler.Prompt+test.Expected+shellHandler.Prompt+shellHandler.ExitMessage, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"unicode/utf8"
+)
+
+// Style styles text, with ANSI SGR escape sequences; but only if the client supports them.
+// Otherwise the text is returned as is. So, for example:
+//
+//	func(ctx telnet.Context, name string, args ...string) telsh.Handler {
+//		style := ctx.(telsh.Context).Style()
+//
+//		return telsh.PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+//			oi.LongWriteString(stdout, "eth0 "+style.Green("UP")+"\r\n")
+//			oi.LongWriteString(stdout, "eth1 "+style.Red("DOWN")+"\r\n")
+//
+//			return nil
+//		})
+//	}
+//
+// ... would write "eth0 UP" with the "UP" in green (and "eth1 DOWN" with the "DOWN" in red)
+// to clients that support ANSI escape sequences, and just "eth0 UP" (and "eth1 DOWN") to
+// those that do not.
+//
+// Whether the client supports them comes from its terminal type (see TERMINAL-TYPE, and
+// MTTS); or from what the user set (for the session) with the built-in command:
+//
+//	terminal color on|off|auto
+type Style struct {
+	enabled bool
+}
+
+// Enabled returns whether the Style is styling text. I.e., whether the client supports
+// ANSI escape sequences.
+func (style Style) Enabled() bool {
+	return style.enabled
+}
+
+func (style Style) sgr(code string, text string) string {
+	if !style.enabled {
+		return text
+	}
+
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+func (style Style) Bold(text string) string      { return style.sgr("1", text) }
+func (style Style) Dim(text string) string       { return style.sgr("2", text) }
+func (style Style) Underline(text string) string { return style.sgr("4", text) }
+func (style Style) Reverse(text string) string   { return style.sgr("7", text) }
+
+func (style Style) Red(text string) string     { return style.sgr("31", text) }
+func (style Style) Green(text string) string   { return style.sgr("32", text) }
+func (style Style) Yellow(text string) string  { return style.sgr("33", text) }
+func (style Style) Blue(text string) string    { return style.sgr("34", text) }
+func (style Style) Magenta(text string) string { return style.sgr("35", text) }
+func (style Style) Cyan(text string) string    { return style.sgr("36", text) }
+
+// StripANSI returns 'p' without any ANSI escape sequences (such as those for colors, or
+// for moving the cursor) in it. (It strips what telnet.StripANSI does; as it uses it.)
+//
+// This can be used to filter the output of a subprocess, before writing it to a client
+// that does not support ANSI escape sequences.
+func StripANSI(p []byte) []byte {
+	return telnet.StripANSI().Write(p)
+}
+
+// visibleWidth returns how many characters of 's' are shown; i.e., not counting any ANSI
+// escape sequences.
+func visibleWidth(s string) int {
+	return utf8.RuneCount(StripANSI([]byte(s)))
+}
@@ -0,0 +1,165 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"strings"
+
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+
+	tests := []struct {
+		Data     string
+		Expected string
+	}{
+		{
+			Data:     "",
+			Expected: "",
+		},
+		{
+			Data:     "apple",
+			Expected: "apple",
+		},
+		{
+			Data:     "\x1b[31mDOWN\x1b[0m",
+			Expected: "DOWN",
+		},
+		{
+			Data:     "a\x1b[1;32mb\x1b[0mc",
+			Expected: "abc",
+		},
+		{
+			Data:     "\x1b[2J\x1b[H\x1b[?25lhome",
+			Expected: "home",
+		},
+		{
+			Data:     "\x1b]0;title\x07text",
+			Expected: "text",
+		},
+		{
+			Data:     "\x1b]0;title\x1b\\text",
+			Expected: "text",
+		},
+		{
+			Data:     "\x1b=keypad\x1b>",
+			Expected: "keypad",
+		},
+		{
+			Data:     "\x1bP1$r0m\x1b\\after",
+			Expected: "after",
+		},
+		{
+			Data:     "\x1b_private\x1b\\message",
+			Expected: "message",
+		},
+		{
+			Data:     "\x1b(Bplain",
+			Expected: "plain",
+		},
+		{
+			Data:     "caf\xc3\xa9\r\n",
+			Expected: "caf\xc3\xa9\r\n",
+		},
+		{
+			Data:     "cut\x1b[3",
+			Expected: "cut",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, string(StripANSI([]byte(test.Data))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for data: %q", testNumber, expected, actual, test.Data)
+			continue
+		}
+	}
+}
+
+func TestStyle(t *testing.T) {
+
+	tests := []struct {
+		Enabled  bool
+		Styled   func(Style) string
+		Expected string
+	}{
+		{
+			Enabled:  true,
+			Styled:   func(style Style) string { return style.Red("DOWN") },
+			Expected: "\x1b[31mDOWN\x1b[0m",
+		},
+		{
+			Enabled:  true,
+			Styled:   func(style Style) string { return style.Bold(style.Green("UP")) },
+			Expected: "\x1b[1m\x1b[32mUP\x1b[0m\x1b[0m",
+		},
+		{
+			Enabled:  false,
+			Styled:   func(style Style) string { return style.Red("DOWN") },
+			Expected: "DOWN",
+		},
+		{
+			Enabled:  false,
+			Styled:   func(style Style) string { return style.Bold(style.Green("UP")) },
+			Expected: "UP",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, test.Styled(Style{enabled: test.Enabled}); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETStyle(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "status\r\n",
+			Expected:    "§ eth0 DOWN\r\n§ ",
+		},
+		{
+			ClientSends: "terminal color on\r\nstatus\r\nterminal color\r\n",
+			Expected:    "§ § eth0 \x1b[31mDOWN\x1b[0m\r\n§ on\r\n§ ",
+		},
+		{
+			ClientSends: "terminal color on\r\nterminal color auto\r\nstatus\r\nterminal color\r\n",
+			Expected:    "§ § § eth0 DOWN\r\n§ auto (off)\r\n§ ",
+		},
+		{
+			ClientSends: "terminal color blue\r\n",
+			Expected:    "§ terminal: invalid color: blue\r\n§ ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+		shellHandler.WelcomeMessage = ""
+		shellHandler.ExitMessage = ""
+		shellHandler.MustRegister("status", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			style := ctx.(Context).Style()
+
+			return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+				io.WriteString(stdout, "eth0 "+style.Red("DOWN")+"\r\n")
+				return nil
+			})
+		}))
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// AsyncWriter returns an io.Writer for writing (asynchronous) messages to the client, such as
+// notifications and alarms; which can be used from any goroutine, at any time.
+//
+// Each Write is a message, which is written on a line (or lines) of its own. If a line is being
+// read when the message is written, then the line being edited is erased, the message is
+// written, and then the prompt and the line being edited are drawn again (under the message);
+// so that the message does not end up in the middle of what the user is typing.
+// For example:
+//
+//	go func() {
+//		for range ticker.C {
+//			fmt.Fprintf(editor.AsyncWriter(), "The time is now %s.", time.Now().Format(time.Kitchen))
+//		}
+//	}()
+//
+// An end-of-line ("\r\n") is written after the message, if it does not end with one.
+//
+// Write never waits for the LineEditor. If something else is being written to the client at
+// the time (such as by a command, or by the LineEditor echoing what was typed), then the message
+// is written once that is done. (And if a command has written part of a line, then the message
+// is written once the command has written the rest of that line, or is done.) So it is safe to
+// Write from anywhere; including from a command, or from a Complete func. (But it also means
+// that the error from writing the message to the client, if any, is not returned.)
+func (editor *LineEditor) AsyncWriter() io.Writer {
+	return internalAsyncWriter{editor: editor}
+}
+
+type internalAsyncWriter struct {
+	editor *LineEditor
+}
+
+func (writer internalAsyncWriter) Write(p []byte) (int, error) {
+	if len(p) <= 0 {
+		return 0, nil
+	}
+
+	editor := writer.editor
+
+	message := make([]byte, len(p))
+	copy(message, p)
+
+	editor.asyncMutex.Lock()
+	editor.async = append(editor.async, message)
+	editor.asyncMutex.Unlock()
+
+	// If something else has the render lock, then it writes the message when it unlocks.
+	if editor.mutex.TryLock() {
+		editor.unlock()
+	}
+
+	return len(p), nil
+}
+
+// lock acquires the render lock.
+func (editor *LineEditor) lock() {
+	editor.mutex.Lock()
+}
+
+// unlock releases the render lock; after writing any (asynchronous) messages that came while
+// it was held.
+func (editor *LineEditor) unlock() {
+	for {
+		ready := editor.flushable()
+		editor.flush()
+		editor.mutex.Unlock()
+
+		// A message might have come after the flush, but before the Unlock; in which case
+		// its Write might not have gotten the lock. (Unless the messages have to wait anyway;
+		// then whatever makes them not have to wait writes them.)
+		if !ready {
+			return
+		}
+
+		editor.asyncMutex.Lock()
+		pending := 0 < len(editor.async)
+		editor.asyncMutex.Unlock()
+
+		if !pending || !editor.mutex.TryLock() {
+			return
+		}
+	}
+}
+
+// flush writes the (asynchronous) messages that are waiting to be written. The render lock
+// must be held.
+//
+// While a line is not being read (i.e., while a command is running), the messages wait for
+// whatever was written (by the command) to get to the end of its line; so as not to split
+// a line of its output.
+func (editor *LineEditor) flush() {
+	if !editor.flushable() {
+		return
+	}
+
+	editor.asyncMutex.Lock()
+	messages := editor.async
+	editor.async = nil
+	editor.asyncMutex.Unlock()
+
+	for _, message := range messages {
+		editor.interject(message)
+	}
+}
+
+// flushable returns whether the (asynchronous) messages can be written now. The render lock
+// must be held.
+func (editor *LineEditor) flushable() bool {
+	return editor.reading || editor.atLineStart
+}
+
+// interject writes 'message' on a line of its own; and then, if a line is being read, draws
+// the prompt and the line being edited again.
+func (editor *LineEditor) interject(message []byte) error {
+
+	var prefix string
+	switch {
+	case !editor.reading:
+	case editor.echo():
+		// Go back to the start of the row the prompt is on, and erase from there to the
+		// end of the screen. (Like refresh does.)
+		prefix = "\r"
+		if 0 < editor.row {
+			prefix += "\x1b[" + strconv.Itoa(editor.row) + "A"
+		}
+		prefix += "\x1b[J"
+	default:
+		// The client is doing its own echoing (or what is being typed is hidden), so what
+		// was typed so far cannot be erased (or drawn again).
+		prefix = "\r\n"
+	}
+
+	if _, err := oi.LongWriteString(editor.writer, prefix); nil != err {
+		return err
+	}
+	if _, err := oi.LongWrite(editor.writer, message); nil != err {
+		return err
+	}
+	if !bytes.HasSuffix(message, []byte("\n")) {
+		if _, err := oi.LongWriteString(editor.writer, "\r\n"); nil != err {
+			return err
+		}
+	}
+	editor.atLineStart = true
+
+	switch {
+	case !editor.reading:
+		return nil
+	case editor.echo():
+		return editor.draw()
+	default:
+		_, err := oi.LongWriteString(editor.writer, editor.prompt)
+		return err
+	}
+}
+
+// output returns an io.Writer that writes to the client with the render lock held; which is
+// what the output of commands is written with.
+func (editor *LineEditor) output() io.Writer {
+	return internalEditorWriter{editor: editor}
+}
+
+type internalEditorWriter struct {
+	editor *LineEditor
+}
+
+func (writer internalEditorWriter) Write(p []byte) (int, error) {
+	editor := writer.editor
+
+	editor.lock()
+	defer editor.unlock()
+
+	n, err := editor.writer.Write(p)
+	if 0 < n {
+		editor.atLineStart = '\n' == p[n-1]
+	}
+
+	return n, err
+}
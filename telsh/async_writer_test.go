@@ -0,0 +1,231 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+func TestLineEditorAsyncWriter(t *testing.T) {
+
+	tests := []struct {
+		Echo        bool
+		Width       int
+		ClientSends string
+		Message     string
+		Expected    string
+	}{
+		{
+			Echo:        true,
+			ClientSends: "ab",
+			Message:     "Hello!",
+			Expected:    "> ab\r\x1b[JHello!\r\n> ab",
+		},
+		{
+			Echo:        true,
+			ClientSends: "abc\x1b[D",
+			Message:     "Hello!\r\n",
+			Expected:    "> abc\r\x1b[4C\r\x1b[JHello!\r\n> abc\r\x1b[4C",
+		},
+		{
+			Echo:        true,
+			Width:       4,
+			ClientSends: "abcdef",
+			Message:     "Hello!",
+			Expected:    "> ab\r\ncdef\r\n\r\x1b[2A\x1b[JHello!\r\n> abcdef\r\n",
+		},
+		{
+			Echo:        true,
+			ClientSends: "ls\r\n",
+			Message:     "Hello!",
+			Expected:    "> ls\r\nHello!\r\n",
+		},
+		{
+			ClientSends: "ab",
+			Message:     "Hello!",
+			Expected:    "> \r\nHello!\r\n> ",
+		},
+		{
+			ClientSends: "ls\r\n",
+			Message:     "Hello!",
+			Expected:    "> Hello!\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		editor := NewLineEditor(&buffer)
+		if test.Echo {
+			editor.Echo = func() bool { return true }
+		}
+		if 0 < test.Width {
+			width := test.Width
+			editor.Width = func() int { return width }
+		}
+
+		editor.ShowPrompt("> ")
+		for _, b := range []byte(test.ClientSends) {
+			editor.Feed(b)
+		}
+
+		io.WriteString(editor.AsyncWriter(), test.Message)
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestLineEditorAsyncWriterReentrant(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	editor := NewLineEditor(&buffer)
+	editor.Echo = func() bool { return true }
+
+	// The Complete func is called while the render lock is held.
+	editor.Complete = func(line string, cursor int) []string {
+		io.WriteString(editor.AsyncWriter(), "Completing!")
+		return []string{"show"}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		editor.ShowPrompt("> ")
+		for _, b := range []byte("sh\t") {
+			editor.Feed(b)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Deadlocked writing to the AsyncWriter while the render lock was held.")
+	}
+
+	if expected, actual := "> show \r\x1b[JCompleting!\r\n> show ", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServeTELNETAsyncWriter(t *testing.T) {
+
+	const ticks = 20
+
+	var waitGroup sync.WaitGroup
+
+	shellHandler := NewShellHandler()
+	shellHandler.CharacterMode = true
+	shellHandler.MustRegisterHandlerFunc("echo", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		io.WriteString(stdout, strings.Join(args, " ")+"\r\n")
+		return nil
+	})
+
+	// ticker writes (in the background, after it is done) a tick every millisecond.
+	shellHandler.MustRegister("ticker", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		shellCtx := ctx.(Context)
+
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+
+			for i := 0; i < ticks; i++ {
+				<-ticker.C
+				shellCtx.Printf("tick %02d", i)
+			}
+		}()
+
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			return nil
+		})
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, shellHandler)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	var mutex sync.Mutex
+	var received bytes.Buffer
+	go func() {
+		p := make([]byte, 64)
+		for {
+			n, err := client.Read(p)
+			mutex.Lock()
+			received.Write(p[:n])
+			mutex.Unlock()
+			if nil != err {
+				return
+			}
+		}
+	}()
+
+	client.Write([]byte{
+		telnet.IAC, telnet.DO, telnet.OptSuppressGoAhead,
+		telnet.IAC, telnet.DO, telnet.OptEcho,
+	})
+	client.Write([]byte("ticker\r\x00"))
+
+	// Type (slowly) while the ticks are being written.
+	for _, b := range []byte("echo hello\r\x00") {
+		client.Write([]byte{b})
+		time.Sleep(time.Millisecond)
+	}
+
+	waitGroup.Wait()
+	client.Write([]byte("echo done\r\x00"))
+
+	var output string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mutex.Lock()
+		output = received.String()
+		mutex.Unlock()
+		if strings.HasSuffix(output, "done\r\n"+shellHandler.Prompt) {
+			break
+		}
+	}
+
+	if expected, actual := ticks, strings.Count(output, "tick "); expected != actual {
+		t.Errorf("Expected %d ticks, but actually got %d: %q", expected, actual, output)
+	}
+
+	// Each tick is on a line of its own; i.e., it comes after an erased line (if a line was
+	// being typed), or at the start of a row (if not).
+	// (Which is also not in the middle of a line written by a command.)
+	for _, match := range regexp.MustCompile(`tick \d\d`).FindAllStringIndex(output, -1) {
+		before, after := output[:match[0]], output[match[1]:]
+		if !strings.HasSuffix(before, "\r\x1b[J") && !strings.HasSuffix(before, "\r\n") || !strings.HasPrefix(after, "\r\n") {
+			t.Errorf("Did not expect a tick in the middle of a line, but actually got one (at %d): %q", match[0], output)
+			break
+		}
+	}
+
+	if !strings.Contains(output, "\r\nhello\r\n") {
+		t.Errorf("Expected the command to have been typed (and run) intact, but actually got %q.", output)
+	}
+}
@@ -0,0 +1,177 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"errors"
+	"sync"
+)
+
+// ErrPermissionDenied is the error for a user not being allowed to run a command.
+//
+// An Authorizer should return it (or an error that wraps it) when it does not allow a command.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Permission is the permission a user needs to be allowed to run a command; which a command
+// is registered with, in its CommandInfo. For example:
+//
+//	shellHandler.MustRegisterCommand("show", showProducer, telsh.CommandInfo{
+//		Description: "Show the running configuration.",
+//		Permission:  telsh.Permission{Level: 1},
+//	})
+//
+//	shellHandler.MustRegisterCommand("reload", reloadProducer, telsh.CommandInfo{
+//		Description: "Restart the system.",
+//		Permission:  telsh.Permission{Level: 15, Name: "reload"},
+//	})
+//
+// The zero Permission means that anyone can run the command.
+type Permission struct {
+	// Level is the (lowest) permission level a user needs. Zero means any level will do.
+	Level int
+
+	// Name, if not empty, is the named permission a user needs (in addition to Level).
+	Name string
+}
+
+// Permissions are what a user is allowed to do; i.e., their permission level, and their
+// named permissions.
+//
+// The Permissions of a session are set (with Context.SetPermissions) by whatever logs the
+// user in; for example, by the ShellHandler's Authenticate:
+//
+//	shellHandler.Authenticate = func(ctx telsh.Context, username string, password string, tries int) (string, error) {
+//		//...
+//
+//		ctx.SetPermissions(telsh.Permissions{Level: 1})
+//		return username, nil
+//	}
+//
+// Until then, a session has the zero Permissions.
+type Permissions struct {
+	Level int
+	Names []string
+}
+
+// Has returns whether 'permissions' includes the named permission 'name'.
+func (permissions Permissions) Has(name string) bool {
+	for _, datum := range permissions.Names {
+		if name == datum {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authorizer decides whether the user (of the session 'ctx') is allowed to run the command
+// 'name'; which needs the Permission 'required' (see CommandInfo).
+//
+// If the user is allowed to, then Authorize returns nil. Otherwise it returns an error; which
+// should be (or wrap) ErrPermissionDenied.
+//
+// An Authorizer can be used to hand the decision off to some other system; such as a policy
+// engine, or a directory server.
+type Authorizer interface {
+	Authorize(ctx Context, name string, required Permission) error
+}
+
+// AuthorizerFunc is an adaptor, that can be used to turn a func with the signature:
+//
+//	func(ctx telsh.Context, name string, required telsh.Permission) error
+//
+// Into an Authorizer.
+type AuthorizerFunc func(ctx Context, name string, required Permission) error
+
+func (fn AuthorizerFunc) Authorize(ctx Context, name string, required Permission) error {
+	return fn(ctx, name, required)
+}
+
+// LevelAuthorizer is an Authorizer, which allows a user to run a command if their permission
+// level is at least the command's, and (if the command needs a named permission) they have
+// that named permission.
+//
+// This is the Authorizer a ShellHandler uses if it was not given another.
+type LevelAuthorizer struct{}
+
+func (LevelAuthorizer) Authorize(ctx Context, name string, required Permission) error {
+	permissions := ctx.Permissions()
+
+	if permissions.Level < required.Level {
+		return ErrPermissionDenied
+	}
+	if "" != required.Name && !permissions.Has(required.Name) {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// internalPermissions are the (per session) Permissions; which are shared with the Context of
+// each command run in the session.
+type internalPermissions struct {
+	mutex       sync.RWMutex
+	permissions Permissions
+}
+
+func (permissions *internalPermissions) get() Permissions {
+	permissions.mutex.RLock()
+	defer permissions.mutex.RUnlock()
+
+	return permissions.permissions
+}
+
+func (permissions *internalPermissions) set(value Permissions) {
+	permissions.mutex.Lock()
+	permissions.permissions = value
+	permissions.mutex.Unlock()
+}
+
+// authorize returns nil if the user (of the session 'ctx') is allowed to run the command 'name';
+// and an error if not.
+//
+// If 'ctx' is not a Context, then the user is only allowed to run commands that anyone can run.
+func (telnetHandler *ShellHandler) authorize(ctx telnet.Context, name string) error {
+
+	telnetHandler.muxtex.RLock()
+	info := telnetHandler.infos[name]
+	authorizer := telnetHandler.Authorizer
+	telnetHandler.muxtex.RUnlock()
+
+	shellCtx, ok := ctx.(Context)
+	if !ok {
+		if (Permission{}) != info.Permission {
+			return ErrPermissionDenied
+		}
+		return nil
+	}
+
+	if nil == authorizer {
+		authorizer = LevelAuthorizer{}
+	}
+
+	return authorizer.Authorize(shellCtx, name, info.Permission)
+}
+
+// permitted returns whether the user of the session 'ctx' is allowed to run the command 'name'.
+//
+// (The user is allowed to run an alias if they are allowed to run the command it is an alias for.)
+func (telnetHandler *ShellHandler) permitted(ctx telnet.Context, name string) bool {
+
+	telnetHandler.muxtex.RLock()
+	expansion, isAlias := telnetHandler.aliases[name]
+	telnetHandler.muxtex.RUnlock()
+	if isAlias {
+		if fields, err := Tokenize(expansion); nil == err && 0 < len(fields) {
+			name = fields[0]
+		}
+	}
+
+	return nil == telnetHandler.authorize(ctx, name)
+}
+
+// visible returns whether the command 'name' is to be shown (in the help, and when completing)
+// to the user of the session 'ctx'.
+func (telnetHandler *ShellHandler) visible(ctx telnet.Context, name string) bool {
+	return !telnetHandler.HideUnauthorized || telnetHandler.permitted(ctx, name)
+}
@@ -0,0 +1,256 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"testing"
+)
+
+func testAuthorizationShellHandler(produced *[]string) *ShellHandler {
+	shellHandler := NewShellHandler()
+	shellHandler.WelcomeMessage = ""
+	shellHandler.ExitMessage = ""
+	shellHandler.UsernamePrompt = ""
+	shellHandler.PasswordPrompt = ""
+
+	shellHandler.Authenticate = func(ctx Context, username string, password string, tries int) (string, error) {
+		switch username {
+		case "operator":
+			ctx.SetPermissions(Permissions{Level: 1})
+		case "admin":
+			ctx.SetPermissions(Permissions{Level: 15, Names: []string{"reload"}})
+		case "guest":
+		default:
+			return "", errors.New("no such user")
+		}
+		return username, nil
+	}
+
+	producer := func(output string) Producer {
+		return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			*produced = append(*produced, name)
+			return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+				io.WriteString(stdout, output+"\r\n")
+				return nil
+			})
+		})
+	}
+
+	shellHandler.MustRegisterCommand("show", producer("running"), CommandInfo{
+		Description: "Show the configuration.",
+		Permission:  Permission{Level: 1},
+	})
+	shellHandler.MustRegisterCommand("reload", producer("reloading"), CommandInfo{
+		Description: "Restart the system.",
+		Permission:  Permission{Level: 15, Name: "reload"},
+	})
+	shellHandler.MustRegisterCommand("uptime", producer("42 days"), CommandInfo{
+		Description: "Show how long the system has been up.",
+	})
+	shellHandler.MustAlias("restart", "reload")
+
+	return shellHandler
+}
+
+func TestServeTELNETAuthorization(t *testing.T) {
+
+	tests := []struct {
+		ClientSends      string
+		Expected         string
+		ExpectedProduced []string
+	}{
+		{
+			ClientSends:      "guest\r\n\r\nuptime\r\nshow\r\nreload\r\n",
+			Expected:         "§ 42 days\r\n§ show: permission denied\r\n§ reload: permission denied\r\n§ ",
+			ExpectedProduced: []string{"uptime"},
+		},
+		{
+			ClientSends:      "operator\r\n\r\nshow\r\nreload\r\nrestart\r\nuptime | show\r\nshow | reload\r\n",
+			Expected:         "§ running\r\n§ reload: permission denied\r\n§ reload: permission denied\r\n§ running\r\n§ reload: permission denied\r\n§ ",
+			ExpectedProduced: []string{"show", "uptime", "show"},
+		},
+		{
+			ClientSends:      "admin\r\n\r\nshow\r\nrestart\r\n",
+			Expected:         "§ running\r\n§ reloading\r\n§ ",
+			ExpectedProduced: []string{"show", "reload"},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var produced []string
+		shellHandler := testAuthorizationShellHandler(&produced)
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+		if expected, actual := strings.Join(test.ExpectedProduced, ","), strings.Join(produced, ","); expected != actual {
+			t.Errorf("For test #%d, expected the Producers of %q to have been called, but actually %q were; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETAuthorizer(t *testing.T) {
+
+	var produced []string
+	shellHandler := testAuthorizationShellHandler(&produced)
+
+	var names []string
+	shellHandler.Authorizer = AuthorizerFunc(func(ctx Context, name string, required Permission) error {
+		names = append(names, name)
+		if "uptime" == name && "admin" != ctx.User() {
+			return fmt.Errorf("%w: ask an admin", ErrPermissionDenied)
+		}
+		return LevelAuthorizer{}.Authorize(ctx, name, required)
+	})
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("operator\r\n\r\nuptime\r\nshow\r\n"))
+
+	if expected, actual := "§ uptime: permission denied: ask an admin\r\n§ running\r\n§ ", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := "uptime,show", strings.Join(names, ","); expected != actual {
+		t.Errorf("Expected the Authorizer to have been asked about %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServeTELNETAuthorizationHelp(t *testing.T) {
+
+	tests := []struct {
+		HideUnauthorized bool
+		ClientSends      string
+		Expected         string
+	}{
+		{
+			ClientSends: "guest\r\n\r\nhelp\r\n",
+			Expected: "§ " +
+				"count     Count the lines of the input.\r\n" +
+				"env       List the session's variables.\r\n" +
+				"exit      End the session.\r\n" +
+				"grep      Write the lines of the input that match a pattern.\r\n" +
+				"head      Write the first lines of the input.\r\n" +
+				"help      List the commands; or show the help for a command.\r\n" +
+				"logout    End the session.\r\n" +
+				"quit      End the session.\r\n" +
+				"reload    (not permitted) Restart the system.\r\n" +
+				"restart   (not permitted) Alias for \"reload\".\r\n" +
+				"set       Set a session variable; or, without any arguments, list them.\r\n" +
+				"show      (not permitted) Show the configuration.\r\n" +
+				"terminal  Show or set the terminal settings for the session.\r\n" +
+				"unset     Remove session variables.\r\n" +
+				"uptime    Show how long the system has been up.\r\n" +
+				"§ ",
+		},
+		{
+			HideUnauthorized: true,
+			ClientSends:      "operator\r\n\r\nhelp\r\nhelp reload\r\n",
+			Expected: "§ " +
+				"count     Count the lines of the input.\r\n" +
+				"env       List the session's variables.\r\n" +
+				"exit      End the session.\r\n" +
+				"grep      Write the lines of the input that match a pattern.\r\n" +
+				"head      Write the first lines of the input.\r\n" +
+				"help      List the commands; or show the help for a command.\r\n" +
+				"logout    End the session.\r\n" +
+				"quit      End the session.\r\n" +
+				"set       Set a session variable; or, without any arguments, list them.\r\n" +
+				"show      Show the configuration.\r\n" +
+				"terminal  Show or set the terminal settings for the session.\r\n" +
+				"unset     Remove session variables.\r\n" +
+				"uptime    Show how long the system has been up.\r\n" +
+				"§ help: no such command: reload\r\n" +
+				"§ ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var produced []string
+		shellHandler := testAuthorizationShellHandler(&produced)
+		shellHandler.HideUnauthorized = test.HideUnauthorized
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestShellHandlerCompleteAuthorization(t *testing.T) {
+
+	tests := []struct {
+		HideUnauthorized bool
+		Permissions      Permissions
+		Line             string
+		Expected         []string
+	}{
+		{
+			Line:     "re",
+			Expected: []string{"reload", "restart"},
+		},
+		{
+			HideUnauthorized: true,
+			Line:             "re",
+			Expected:         []string{},
+		},
+		{
+			HideUnauthorized: true,
+			Permissions:      Permissions{Level: 15},
+			Line:             "re",
+			Expected:         []string{},
+		},
+		{
+			HideUnauthorized: true,
+			Permissions:      Permissions{Level: 15, Names: []string{"reload"}},
+			Line:             "re",
+			Expected:         []string{"reload", "restart"},
+		},
+		{
+			HideUnauthorized: true,
+			Line:             "s",
+			Expected:         []string{"set"},
+		},
+		{
+			HideUnauthorized: true,
+			Permissions:      Permissions{Level: 1},
+			Line:             "s",
+			Expected:         []string{"set", "show"},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var produced []string
+		shellHandler := testAuthorizationShellHandler(&produced)
+		shellHandler.HideUnauthorized = test.HideUnauthorized
+
+		ctx := newContext(context.Background(), nil, nil, shellHandler)
+		ctx.SetPermissions(test.Permissions)
+
+		actual := shellHandler.complete(ctx, test.Line, len(test.Line))
+
+		if expected := test.Expected; strings.Join(expected, ",") != strings.Join(actual, ",") {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, expected, actual, test.Line)
+			continue
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package telsh
+
+import (
+	"sort"
+	"time"
+)
+
+// CommandInfo describes a shell "command"; for the built-in help command (and for how long it
+// can run for, and who can run it).
+//
+// For example:
+//
+//	shellHandler.MustRegisterCommand("ping", pingProducer, telsh.CommandInfo{
+//		Description: "Check whether a host is reachable.",
+//		Usage:       "ping <host>",
+//		Help:        "Sends ICMP echo requests to <host>, and reports the replies.",
+//	})
+type CommandInfo struct {
+	// Description is a short (one line) description of the command.
+	Description string
+
+	// Usage is the usage line of the command; such as "ping <host>".
+	Usage string
+
+	// Help is the (optional) long help for the command. It can be many lines long.
+	Help string
+
+	// Timeout, if not zero, is how long the command can run for; overriding the ShellHandler's
+	// CommandTimeout. A negative Timeout means the command can run for as long as it likes.
+	Timeout time.Duration
+
+	// Permission is the permission a user needs to be allowed to run the command (see
+	// ShellHandler.Authorizer). The zero Permission means that anyone can run it.
+	Permission Permission
+}
+
+// RegisterCommand registers 'producer' as the command 'name' (like Register does), along
+// with 'info' describing it.
+func (telnetHandler *ShellHandler) RegisterCommand(name string, producer Producer, info CommandInfo) error {
+	if err := telnetHandler.Register(name, producer); nil != err {
+		return err
+	}
+
+	return telnetHandler.Describe(name, info)
+}
+
+func (telnetHandler *ShellHandler) MustRegisterCommand(name string, producer Producer, info CommandInfo) *ShellHandler {
+	if err := telnetHandler.RegisterCommand(name, producer, info); nil != err {
+		panic(err)
+	}
+
+	return telnetHandler
+}
+
+// Describe sets the CommandInfo for the command 'name'.
+func (telnetHandler *ShellHandler) Describe(name string, info CommandInfo) error {
+
+	telnetHandler.muxtex.Lock()
+	if nil == telnetHandler.infos {
+		telnetHandler.infos = map[string]CommandInfo{}
+	}
+	telnetHandler.infos[name] = info
+	telnetHandler.muxtex.Unlock()
+
+	return nil
+}
+
+// Commands returns the names of all the commands (including the built-in ones), sorted.
+func (telnetHandler *ShellHandler) Commands() []string {
+
+	telnetHandler.muxtex.RLock()
+	defer telnetHandler.muxtex.RUnlock()
+
+	var names []string
+	for name := range telnetHandler.producers {
+		names = append(names, name)
+	}
+	for name := range telnetHandler.aliases {
+		if _, isProducer := telnetHandler.producers[name]; !isProducer {
+			names = append(names, name)
+		}
+	}
+	for name := range telnetHandler.builtins() {
+		_, isProducer := telnetHandler.producers[name]
+		_, isAlias := telnetHandler.aliases[name]
+		if !isProducer && !isAlias {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Info returns the CommandInfo for the command 'name'; and whether there is such a command.
+func (telnetHandler *ShellHandler) Info(name string) (CommandInfo, bool) {
+
+	telnetHandler.muxtex.RLock()
+	defer telnetHandler.muxtex.RUnlock()
+
+	_, isProducer := telnetHandler.producers[name]
+	expansion, isAlias := telnetHandler.aliases[name]
+	_, isBuiltin := telnetHandler.builtins()[name]
+
+	if !isProducer && !isAlias && !isBuiltin {
+		return CommandInfo{}, false
+	}
+
+	if info, ok := telnetHandler.infos[name]; ok {
+		return info, true
+	}
+
+	// An alias takes precedence over a command with the same name.
+	switch {
+	case isAlias:
+		info := CommandInfo{
+			Description: "Alias for \"" + expansion + "\".",
+		}
+		return info, true
+	case isProducer:
+		return CommandInfo{}, true
+	default:
+		return telnetHandler.builtinInfos()[name], true
+	}
+}
+
+// builtins returns the built-in commands.
+//
+// Registering a command with the same name as a built-in one overrides the built-in one.
+//
+// (The exit commands are here with a nil Producer, as the shell handles them itself.)
+func (telnetHandler *ShellHandler) builtins() map[string]Producer {
+	builtins := map[string]Producer{
+		"count":    filter(countFilter),
+		"env":      internalVariablesProducer{},
+		"grep":     filter(grepFilter),
+		"head":     filter(headFilter),
+		"help":     Help(telnetHandler),
+		"set":      internalVariablesProducer{},
+		"terminal": internalTerminalProducer{},
+		"unset":    internalVariablesProducer{},
+	}
+	for _, name := range telnetHandler.exitCommandNames() {
+		builtins[name] = nil
+	}
+
+	return builtins
+}
+
+func (telnetHandler *ShellHandler) builtinInfos() map[string]CommandInfo {
+	infos := map[string]CommandInfo{
+		"count": CommandInfo{
+			Description: "Count the lines of the input.",
+			Usage:       "count",
+			Help:        "Writes how many lines its input has; such as with:\n\n    show sessions | count",
+		},
+		"env": CommandInfo{
+			Description: "List the session's variables.",
+			Usage:       "env",
+		},
+		"grep": CommandInfo{
+			Description: "Write the lines of the input that match a pattern.",
+			Usage:       "grep [-i] [-v] <pattern>",
+			Help: "Writes the lines of its input that match the regular expression <pattern>.\n" +
+				"With -i the match ignores case, and with -v the lines that do not match are\n" +
+				"written instead.",
+		},
+		"head": CommandInfo{
+			Description: "Write the first lines of the input.",
+			Usage:       "head [-n <lines>]",
+			Help:        "Writes the first <lines> (10, if not given) lines of its input.",
+		},
+		"help": CommandInfo{
+			Description: "List the commands; or show the help for a command.",
+			Usage:       "help [<command>...]",
+		},
+		"set": CommandInfo{
+			Description: "Set a session variable; or, without any arguments, list them.",
+			Usage:       "set [<name> <value>...]",
+			Help: "Sets the variable <name> to <value> (or to the rest of the arguments, joined\n" +
+				"with spaces). \"set <name>=<value>\" also works. The variables last until the\n" +
+				"session ends.",
+		},
+		"unset": CommandInfo{
+			Description: "Remove session variables.",
+			Usage:       "unset <name>...",
+		},
+		"terminal": CommandInfo{
+			Description: "Show or set the terminal settings for the session.",
+			Usage:       terminalUsage,
+			Help: "terminal length shows (or, with <lines>, sets) how many lines a page of output\n" +
+				"is, before pausing with --More--. A length of 0 turns the pager off.\n" +
+				"\n" +
+				"terminal color shows (or sets) whether to use colors; where auto means to use\n" +
+				"them if the terminal type says the terminal supports them.",
+		},
+	}
+	for _, name := range telnetHandler.exitCommandNames() {
+		infos[name] = CommandInfo{
+			Description: "End the session.",
+			Usage:       name,
+		}
+	}
+
+	return infos
+}
+
+// producer returns the Producer for the command 'name'; falling back to the built-in
+// commands, and then to the "else" Producer (see RegisterElse).
+// timeout returns how long the command 'name' can run for; or zero if it can run for as long
+// as it likes.
+func (telnetHandler *ShellHandler) timeout(name string) time.Duration {
+
+	telnetHandler.muxtex.RLock()
+	defer telnetHandler.muxtex.RUnlock()
+
+	timeout := telnetHandler.CommandTimeout
+	if info, ok := telnetHandler.infos[name]; ok && 0 != info.Timeout {
+		timeout = info.Timeout
+	}
+	if timeout < 0 {
+		return 0
+	}
+
+	return timeout
+}
+
+func (telnetHandler *ShellHandler) producer(name string) Producer {
+
+	telnetHandler.muxtex.RLock()
+	defer telnetHandler.muxtex.RUnlock()
+
+	if producer, ok := telnetHandler.producers[name]; ok {
+		return producer
+	}
+	if producer := telnetHandler.builtins()[name]; nil != producer {
+		return producer
+	}
+
+	return telnetHandler.elseProducer
+}
@@ -0,0 +1,168 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"strings"
+	"unicode/utf8"
+)
+
+// A Completer provides a Complete method, which is used (when the user presses TAB) to
+// complete the arguments of a shell "command".
+//
+// 'args' are the arguments before the one being completed, and 'prefix' is what has been
+// typed (so far) of the argument being completed. So, for example, for:
+//
+//	show interfaces eth
+//
+// ... the Completer registered for "show" would have Complete called with:
+//
+//	args   = []string{"interfaces"}
+//	prefix = "eth"
+//
+// Complete returns the (whole) arguments that 'prefix' could be completed to.
+//
+// A Producer that also implements Completer is used as the Completer for the command
+// it is registered as, unless another Completer was registered (with RegisterCompleter)
+// for that command.
+type Completer interface {
+	Complete(args []string, prefix string) []string
+}
+
+// CompleterFunc is an adaptor, that can be used to turn a func with the
+// signature:
+//
+//	func(args []string, prefix string) []string
+//
+// Into a Completer
+type CompleterFunc func(args []string, prefix string) []string
+
+// Complete makes CompleterFunc fit the Completer interface.
+func (fn CompleterFunc) Complete(args []string, prefix string) []string {
+	return fn(args, prefix)
+}
+
+func (telnetHandler *ShellHandler) RegisterCompleter(name string, completer Completer) error {
+
+	telnetHandler.muxtex.Lock()
+	if nil == telnetHandler.completers {
+		telnetHandler.completers = map[string]Completer{}
+	}
+	telnetHandler.completers[name] = completer
+	telnetHandler.muxtex.Unlock()
+
+	return nil
+}
+
+func (telnetHandler *ShellHandler) MustRegisterCompleter(name string, completer Completer) *ShellHandler {
+	if err := telnetHandler.RegisterCompleter(name, completer); nil != err {
+		panic(err)
+	}
+
+	return telnetHandler
+}
+
+// complete returns what the word (in 'line') that ends at 'cursor' could be completed to.
+//
+// The first word is completed to the registered command names (that are visible to the
+// user of the session 'ctx'). Any other word is completed by the command's Completer (if it
+// has one).
+func (telnetHandler *ShellHandler) complete(ctx telnet.Context, line string, cursor int) []string {
+
+	line = line[:cursor]
+
+	args := strings.Fields(line)
+	var prefix string
+	if 0 < len(args) && !strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\t") {
+		prefix = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+
+	if len(args) <= 0 {
+		var names []string
+		for _, name := range telnetHandler.Commands() {
+			if strings.HasPrefix(name, prefix) && telnetHandler.visible(ctx, name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	telnetHandler.muxtex.RLock()
+	completer, ok := telnetHandler.completers[args[0]]
+	telnetHandler.muxtex.RUnlock()
+	if !ok {
+		completer, ok = telnetHandler.producer(args[0]).(Completer)
+	}
+	if !ok || nil == completer {
+		return nil
+	}
+
+	return completer.Complete(args[1:], prefix)
+}
+
+// commonPrefix returns the longest prefix all of 'words' share.
+func commonPrefix(words []string) string {
+	if len(words) <= 0 {
+		return ""
+	}
+
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	// Do not cut a (UTF-8 encoded) character in half.
+	for 0 < len(prefix) {
+		if r, size := utf8.DecodeLastRuneInString(prefix); utf8.RuneError != r || 1 != size {
+			break
+		}
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	return prefix
+}
+
+// columns lays out 'words' in columns (going down, and then across, like ls does) that
+// fit in 'width'; with each row ending in "\r\n".
+func columns(words []string, width int) string {
+	if len(words) <= 0 {
+		return ""
+	}
+
+	const gap = 2
+
+	var longest int
+	for _, word := range words {
+		if length := visibleWidth(word); longest < length {
+			longest = length
+		}
+	}
+
+	numColumns := (width + gap) / (longest + gap)
+	if numColumns < 1 {
+		numColumns = 1
+	}
+	numRows := (len(words) + numColumns - 1) / numColumns
+
+	var buffer strings.Builder
+	for row := 0; row < numRows; row++ {
+		for column := 0; column < numColumns; column++ {
+			i := column*numRows + row
+			if len(words) <= i {
+				break
+			}
+			word := words[i]
+
+			buffer.WriteString(word)
+			if next := i + numRows; next < len(words) {
+				buffer.WriteString(strings.Repeat(" ", longest+gap-visibleWidth(word)))
+			}
+		}
+		buffer.WriteString("\r\n")
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,194 @@
+package telsh
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"testing"
+)
+
+func TestShellHandlerComplete(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+	shellHandler.MustRegister("help", Help(shellHandler))
+	shellHandler.MustRegisterHandlerFunc("show", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		return nil
+	})
+	shellHandler.MustRegisterHandlerFunc("shutdown", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		return nil
+	})
+	shellHandler.MustRegisterCompleter("show", CompleterFunc(func(args []string, prefix string) []string {
+		var completions []string
+		for _, completion := range []string{"interfaces", "ip", "version"} {
+			if 0 == len(args) && strings.HasPrefix(completion, prefix) {
+				completions = append(completions, completion)
+			}
+		}
+		return completions
+	}))
+
+	tests := []struct {
+		Line     string
+		Expected []string
+	}{
+		{
+			Line:     "",
+			Expected: []string{"count", "env", "exit", "grep", "head", "help", "logout", "quit", "set", "show", "shutdown", "terminal", "unset"},
+		},
+		{
+			Line:     "s",
+			Expected: []string{"set", "show", "shutdown"},
+		},
+		{
+			Line:     "  sh",
+			Expected: []string{"show", "shutdown"},
+		},
+		{
+			Line:     "sho",
+			Expected: []string{"show"},
+		},
+		{
+			Line:     "x",
+			Expected: []string{},
+		},
+
+		{
+			Line:     "show ",
+			Expected: []string{"interfaces", "ip", "version"},
+		},
+		{
+			Line:     "show i",
+			Expected: []string{"interfaces", "ip"},
+		},
+		{
+			Line:     "show ip ",
+			Expected: []string{},
+		},
+		{
+			Line:     "shutdown ",
+			Expected: []string{},
+		},
+		{
+			Line:     "help ",
+			Expected: []string{"count", "env", "exit", "grep", "head", "help", "logout", "quit", "set", "show", "shutdown", "terminal", "unset"},
+		},
+		{
+			Line:     "help sh",
+			Expected: []string{"show", "shutdown"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual := shellHandler.complete(nil, test.Line, len(test.Line))
+
+		if expected := test.Expected; strings.Join(expected, ",") != strings.Join(actual, ",") {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, expected, actual, test.Line)
+			continue
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+
+	tests := []struct {
+		Words    []string
+		Width    int
+		Expected string
+	}{
+		{
+			Words:    []string{"a", "bb", "ccc", "d", "e"},
+			Width:    80,
+			Expected: "a    bb   ccc  d    e\r\n",
+		},
+		{
+			Words:    []string{"a", "bb", "ccc", "d", "e"},
+			Width:    12,
+			Expected: "a    d\r\nbb   e\r\nccc\r\n",
+		},
+		{
+			Words:    []string{"a", "bb", "ccc"},
+			Width:    2,
+			Expected: "a\r\nbb\r\nccc\r\n",
+		},
+		{
+			Words:    []string{"\x1b[1ma\x1b[0m", "bb", "\x1b[31mccc\x1b[0m", "d"},
+			Width:    12,
+			Expected: "\x1b[1ma\x1b[0m    \x1b[31mccc\x1b[0m\r\nbb   d\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, columns(test.Words, test.Width); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestLineEditorTab(t *testing.T) {
+
+	complete := func(line string, cursor int) []string {
+		var completions []string
+		for _, completion := range []string{"show", "shutdown", "help"} {
+			if strings.HasPrefix(completion, line[:cursor]) {
+				completions = append(completions, completion)
+			}
+		}
+		return completions
+	}
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+		ExpectedOut string
+	}{
+		{
+			ClientSends: "he\t\r",
+			Expected:    "help ",
+			ExpectedOut: "> help \r\n",
+		},
+		{
+			ClientSends: "s\t\r",
+			Expected:    "sh",
+			ExpectedOut: "> sh\r\nshow      shutdown\r\n> sh\r\n",
+		},
+		{
+			ClientSends: "x\t\r",
+			Expected:    "x",
+			ExpectedOut: "> x\a\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		editor := NewLineEditor(&buffer)
+		editor.Echo = func() bool { return true }
+		editor.Complete = complete
+
+		editor.ShowPrompt("> ")
+
+		var lines []string
+		for _, b := range []byte(test.ClientSends) {
+			line, ok, err := editor.Feed(b)
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+				continue
+			}
+			if ok {
+				lines = append(lines, line)
+			}
+		}
+
+		if expected, actual := []string{test.Expected}, lines; len(expected) != len(actual) || expected[0] != actual[0] {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+		if expected, actual := test.ExpectedOut, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
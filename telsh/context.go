@@ -0,0 +1,215 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Context is the context of a shell session.
+//
+// The telnet.Context the ShellHandler passes to a Producer's Produce method is a Context.
+// So a Producer can get at it with:
+//
+//	func(ctx telnet.Context, name string, args ...string) telsh.Handler {
+//		shellCtx, ok := ctx.(telsh.Context)
+//
+//		//...
+//	}
+//
+// A Context is also a context.Context, which is cancelled when the command is done, or is
+// stopped; such as when it times out (see ShellHandler.CommandTimeout), when the user
+// interrupts it (with Ctrl-C, or IAC IP), or when the session ends (before the connection is
+// closed). So a Producer that starts something in the background can stop it with:
+//
+//	go func() {
+//		<-shellCtx.Done()
+//
+//		//...
+//	}()
+type Context interface {
+	telnet.Context
+	context.Context
+
+	// Conn returns the *telnet.Conn the session is on; or nil if the session is not on
+	// a *telnet.Conn.
+	Conn() *telnet.Conn
+
+	// ShellHandler returns the ShellHandler that is serving the session.
+	ShellHandler() *ShellHandler
+
+	// WindowSize returns the width and height of the client's terminal; or zeros if the
+	// client has not said (with NAWS) what they are.
+	WindowSize() (width int, height int)
+
+	// Style returns a Style for styling text (with ANSI escape sequences) for the client;
+	// which only does so if the client supports them.
+	Style() Style
+
+	// Variables returns the session's variables; which last as long as the session does.
+	Variables() *Variables
+
+	// User returns the user that logged in (see ShellHandler.Authenticate); or the
+	// empty string if no one did.
+	User() string
+
+	// Permissions returns what the user is allowed to do (see ShellHandler.Authorizer).
+	Permissions() Permissions
+
+	// SetPermissions sets what the user is allowed to do, for the rest of the session.
+	// It is for whatever logs the user in (such as ShellHandler.Authenticate) to call.
+	SetPermissions(permissions Permissions)
+
+	// AsyncWriter returns an io.Writer for writing (asynchronous) messages to the client,
+	// such as notifications; which can be used from any goroutine, at any time, for as long
+	// as the session lasts. Each Write is written on a line of its own; and if the user is
+	// in the middle of typing a command, then it is drawn again under the message. (See
+	// LineEditor.AsyncWriter.)
+	AsyncWriter() io.Writer
+
+	// Printf writes a message (formatted like fmt.Printf does) to the AsyncWriter.
+	Printf(format string, a ...interface{}) (int, error)
+}
+
+type internalContext struct {
+	telnet.Context
+
+	session      context.Context
+	cancel       context.CancelFunc
+	conn         *telnet.Conn
+	shellHandler *ShellHandler
+	windowSize   bool // (Whether the client was asked for the size of its terminal; see telnet.Conn.RequestWindowSize.)
+	terminal     *internalTerminalSettings
+	terminalType bool // (Whether the client was asked for its terminal type; see telnet.Conn.RequestTerminalType.)
+	variables    *Variables
+	editor       *LineEditor
+	permissions  *internalPermissions
+	user         string
+}
+
+func newContext(parent context.Context, ctx telnet.Context, conn *telnet.Conn, shellHandler *ShellHandler) *internalContext {
+	if nil == parent {
+		parent = context.Background()
+	}
+	if nil == ctx {
+		ctx = telnet.NewContext()
+	}
+
+	sessionCtx, cancel := context.WithCancel(parent)
+
+	shellCtx := internalContext{
+		Context:      ctx,
+		session:      sessionCtx,
+		cancel:       cancel,
+		conn:         conn,
+		shellHandler: shellHandler,
+		variables:    &Variables{},
+		permissions:  &internalPermissions{},
+		terminal: &internalTerminalSettings{
+			pager:       shellHandler.Pager,
+			pagerLength: shellHandler.PagerLength,
+		},
+	}
+
+	return &shellCtx
+}
+
+// command returns the Context for a command (run in the session); which is cancelled
+// when the command is done, or is stopped (for example, from the pager).
+//
+// If 'timeout' is not zero, then it is also cancelled once 'timeout' has passed.
+func (ctx *internalContext) command(timeout time.Duration) *internalContext {
+	commandCtx := *ctx
+	if 0 < timeout {
+		commandCtx.session, commandCtx.cancel = context.WithTimeout(ctx.session, timeout)
+	} else {
+		commandCtx.session, commandCtx.cancel = context.WithCancel(ctx.session)
+	}
+
+	return &commandCtx
+}
+
+func (ctx *internalContext) InjectLogger(logger telnet.Logger) telnet.Context {
+	ctx.Context.InjectLogger(logger)
+
+	return ctx
+}
+
+func (ctx *internalContext) Conn() *telnet.Conn {
+	return ctx.conn
+}
+
+func (ctx *internalContext) ShellHandler() *ShellHandler {
+	return ctx.shellHandler
+}
+
+func (ctx *internalContext) Variables() *Variables {
+	return ctx.variables
+}
+
+func (ctx *internalContext) User() string {
+	return ctx.user
+}
+
+func (ctx *internalContext) Permissions() Permissions {
+	return ctx.permissions.get()
+}
+
+func (ctx *internalContext) SetPermissions(permissions Permissions) {
+	ctx.permissions.set(permissions)
+}
+
+func (ctx *internalContext) AsyncWriter() io.Writer {
+	if nil == ctx.editor {
+		return io.Discard
+	}
+
+	return ctx.editor.AsyncWriter()
+}
+
+func (ctx *internalContext) Printf(format string, a ...interface{}) (int, error) {
+	return fmt.Fprintf(ctx.AsyncWriter(), format, a...)
+}
+
+func (ctx *internalContext) Style() Style {
+	var enabled bool
+
+	switch ctx.terminal.color {
+	case colorOn:
+		enabled = true
+	case colorOff:
+		enabled = false
+	default:
+		enabled = ctx.terminalType && ansiTerminal(ctx.conn.PeerTerminalTypes())
+	}
+
+	return Style{enabled: enabled}
+}
+
+func (ctx *internalContext) WindowSize() (int, int) {
+	if !ctx.windowSize {
+		return 0, 0
+	}
+
+	width, height, _ := ctx.conn.PeerWindowSize()
+	return width, height
+}
+
+func (ctx *internalContext) Deadline() (time.Time, bool) {
+	return ctx.session.Deadline()
+}
+
+func (ctx *internalContext) Done() <-chan struct{} {
+	return ctx.session.Done()
+}
+
+func (ctx *internalContext) Err() error {
+	return ctx.session.Err()
+}
+
+func (ctx *internalContext) Value(key interface{}) interface{} {
+	return ctx.session.Value(key)
+}
@@ -0,0 +1,20 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"fmt"
+
+	"testing"
+)
+
+func TestShellHandlerContextHandler(t *testing.T) {
+
+	var handler telnet.Handler = NewShellHandler()
+
+	actual := fmt.Sprintf("%T", telnet.AdaptHandler(handler))
+
+	if expected := "telsh.internalShellContextHandler"; expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
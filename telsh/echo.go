@@ -0,0 +1,33 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+)
+
+// internalEcho is an (ECHO) telnet.OptionHandler, which keeps hold of its telnet.OptionSender;
+// so that the shell can turn (server-side) echoing on and off. (Such as for reading a password.)
+type internalEcho struct {
+	sender telnet.OptionSender
+
+	// request is whether to offer (i.e., send a WILL for) ECHO as soon as it is registered.
+	request bool
+}
+
+func (echo *internalEcho) Register(sender telnet.OptionSender) telnet.OptionSupport {
+	echo.sender = sender
+
+	return telnet.OptionSupport{
+		Local:        true,
+		RequestLocal: echo.request,
+	}
+}
+
+func (*internalEcho) LocalChanged(bool)     {}
+func (*internalEcho) RemoteChanged(bool)    {}
+func (*internalEcho) Subnegotiation([]byte) {}
+
+// Enabled returns whether the shell is echoing.
+func (echo *internalEcho) Enabled() bool {
+	local, _ := echo.sender.Conn().OptionEnabled(telnet.OptEcho)
+	return local
+}
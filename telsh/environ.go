@@ -0,0 +1,30 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+)
+
+// seedVariables asks the client for its environment variables (such as USER, or DISPLAY), with
+// NEW-ENVIRON (see telnet.Conn.RequestEnvironment); and keeps 'variables' seeded with them. Each
+// time the client tells them (including when it changes any of them later), they are set; and
+// any that the client has since undefined are unset.
+//
+// It registers (on 'conn') an OnEnvironment; which the caller clears once the session is over.
+func seedVariables(conn *telnet.Conn, variables *Variables) error {
+
+	// (OnEnvironment is only called by whatever goroutine is reading from the Conn; one at a time.)
+	var told map[string]string
+	conn.OnEnvironment(func(environment map[string]string) {
+		for name := range told {
+			if _, ok := environment[name]; !ok {
+				variables.Unset(name)
+			}
+		}
+		for name, value := range environment {
+			variables.Set(name, value)
+		}
+		told = environment
+	})
+
+	return conn.RequestEnvironment()
+}
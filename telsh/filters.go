@@ -0,0 +1,162 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const defaultHeadLines = 10
+
+var (
+	errGrepUsage = errors.New("usage: grep [-i] [-v] <pattern>")
+	errHeadUsage = errors.New("usage: head [-n <lines>]")
+)
+
+// filter returns a Producer for the (built-in) filter command 'fn'.
+func filter(fn HandlerFunc) Producer {
+	return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		return PromoteHandlerFunc(fn, args...)
+	})
+}
+
+// eachLine calls 'fn' with each line read from 'reader' (including its end-of-line, if
+// it has one), until there are no more lines, or 'fn' returns false.
+func eachLine(reader io.Reader, fn func(line string) bool) error {
+
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if "" != line && !fn(line) {
+			return nil
+		}
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+	}
+}
+
+// grepFilter is the built-in grep command, which writes (only) the lines of its input that
+// match a regular expression (see the regexp package).
+//
+//	grep [-i] [-v] <pattern>
+//
+// With -i the match ignores case, and with -v the lines that do not match are written
+// instead. Any ANSI escape sequences in a line (such as for colors) are ignored when matching.
+func grepFilter(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+
+	var ignoreCase, invert bool
+	for 0 < len(args) && strings.HasPrefix(args[0], "-") && 1 < len(args[0]) {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'i':
+				ignoreCase = true
+			case 'v':
+				invert = true
+			default:
+				return errGrepUsage
+			}
+		}
+		args = args[1:]
+	}
+	if 1 != len(args) {
+		return errGrepUsage
+	}
+
+	pattern := args[0]
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if nil != err {
+		return err
+	}
+
+	var writeErr error
+	err = eachLine(stdin, func(line string) bool {
+		text := strings.TrimRight(string(StripANSI([]byte(line))), "\r\n")
+		if re.MatchString(text) == invert {
+			return true
+		}
+
+		_, writeErr = oi.LongWriteString(stdout, line)
+		return nil == writeErr
+	})
+	if nil != err {
+		return err
+	}
+
+	return writeErr
+}
+
+// headFilter is the built-in head command, which writes (only) the first lines of its input.
+//
+//	head [-n <lines>]
+//
+// ("head -<lines>" also works.) The default is 10 lines.
+func headFilter(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+
+	lines := defaultHeadLines
+
+	switch {
+	case 0 == len(args):
+	case 2 == len(args) && "-n" == args[0]:
+		n, err := strconv.Atoi(args[1])
+		if nil != err || n < 0 {
+			return errHeadUsage
+		}
+		lines = n
+	case 1 == len(args) && strings.HasPrefix(args[0], "-"):
+		n, err := strconv.Atoi(args[0][1:])
+		if nil != err || n < 0 {
+			return errHeadUsage
+		}
+		lines = n
+	default:
+		return errHeadUsage
+	}
+
+	if lines <= 0 {
+		return nil
+	}
+
+	var writeErr error
+	var written int
+	err := eachLine(stdin, func(line string) bool {
+		_, writeErr = oi.LongWriteString(stdout, line)
+		written++
+
+		return nil == writeErr && written < lines
+	})
+	if nil != err {
+		return err
+	}
+
+	return writeErr
+}
+
+// countFilter is the built-in count command, which writes how many lines its input has.
+func countFilter(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+
+	var count int
+	err := eachLine(stdin, func(line string) bool {
+		count++
+		return true
+	})
+	if nil != err {
+		return err
+	}
+
+	_, err = oi.LongWriteString(stdout, strconv.Itoa(count)+"\r\n")
+	return err
+}
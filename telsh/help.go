@@ -5,13 +5,31 @@ import (
 	"github.com/wouteroostervld/go-telnet"
 
 	"io"
-	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	noDescription = "(no description)"
+	notPermitted  = "(not permitted) "
 )
 
 type internalHelpProducer struct {
 	shellHandler *ShellHandler
 }
 
+// Help returns a Producer for a help command.
+//
+// When run without any arguments, it lists all the commands of 'shellHandler' (with their
+// descriptions, see CommandInfo), fitted to the width of the client's terminal. When run
+// with arguments, i.e.:
+//
+//	help <command>...
+//
+// ... it shows the usage line, and (long) help, of each of those commands.
+//
+// A ShellHandler has a built-in help command, which is Help of itself; so this is only
+// needed to have a help command under some other name.
 func Help(shellHandler *ShellHandler) Producer {
 	producer := internalHelpProducer{
 		shellHandler: shellHandler,
@@ -20,13 +38,28 @@ func Help(shellHandler *ShellHandler) Producer {
 	return &producer
 }
 
-func (producer *internalHelpProducer) Produce(telnet.Context, string, ...string) Handler {
-	return newHelpHandler(producer)
+func (producer *internalHelpProducer) Produce(ctx telnet.Context, name string, args ...string) Handler {
+	return newHelpHandler(producer, ctx, args)
+}
+
+// Complete completes the arguments of the help command; which are command names.
+func (producer *internalHelpProducer) Complete(args []string, prefix string) []string {
+	var names []string
+	for _, name := range producer.shellHandler.Commands() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	return names
 }
 
 type internalHelpHandler struct {
 	helpProducer *internalHelpProducer
 
+	ctx  telnet.Context
+	args []string
+
 	err error
 
 	stdin  io.ReadCloser
@@ -38,7 +71,7 @@ type internalHelpHandler struct {
 	stderrPipe io.ReadCloser
 }
 
-func newHelpHandler(helpProducer *internalHelpProducer) *internalHelpHandler {
+func newHelpHandler(helpProducer *internalHelpProducer, ctx telnet.Context, args []string) *internalHelpHandler {
 	stdin, stdinPipe := io.Pipe()
 	stdoutPipe, stdout := io.Pipe()
 	stderrPipe, stderr := io.Pipe()
@@ -46,6 +79,9 @@ func newHelpHandler(helpProducer *internalHelpProducer) *internalHelpHandler {
 	handler := internalHelpHandler{
 		helpProducer: helpProducer,
 
+		ctx:  ctx,
+		args: args,
+
 		err: nil,
 
 		stdin:  stdin,
@@ -65,18 +101,28 @@ func (handler *internalHelpHandler) Run() error {
 		return handler.err
 	}
 
-	//@TODO: Should this be reaching inside of ShellHandler? Maybe there should be ShellHandler public methods instead.
-	keys := make([]string, 1+len(handler.helpProducer.shellHandler.producers))
-	i := 0
-	for key, _ := range handler.helpProducer.shellHandler.producers {
-		keys[i] = key
-		i++
+	shellHandler := handler.helpProducer.shellHandler
+
+	if len(handler.args) <= 0 {
+		var width int
+		if shellCtx, ok := handler.ctx.(Context); ok {
+			width, _ = shellCtx.WindowSize()
+		}
+		if width <= 0 {
+			width = defaultWidth
+		}
+
+		oi.LongWriteString(handler.stdout, helpList(shellHandler, handler.ctx, width))
 	}
-	keys[i] = handler.helpProducer.shellHandler.ExitCommandName
-	sort.Strings(keys)
-	for _, key := range keys {
-		oi.LongWriteString(handler.stdout, key)
-		oi.LongWriteString(handler.stdout, "\r\n")
+
+	for _, name := range handler.args {
+		info, ok := shellHandler.Info(name)
+		if !ok || !shellHandler.visible(handler.ctx, name) {
+			oi.LongWriteString(handler.stderr, "help: no such command: "+name+"\r\n")
+			continue
+		}
+
+		oi.LongWriteString(handler.stdout, helpCommand(name, info))
 	}
 
 	handler.stdin.Close()
@@ -109,3 +155,74 @@ func (handler *internalHelpHandler) StderrPipe() (io.ReadCloser, error) {
 
 	return handler.stderrPipe, nil
 }
+
+// helpList returns the list of all the commands (that are visible to the user of the session
+// 'ctx'), with their descriptions, fitted to 'width'.
+//
+// The commands the user is not allowed to run are marked as such.
+func helpList(shellHandler *ShellHandler, ctx telnet.Context, width int) string {
+
+	const gap = 2
+
+	var names []string
+	for _, name := range shellHandler.Commands() {
+		if shellHandler.visible(ctx, name) {
+			names = append(names, name)
+		}
+	}
+
+	var longest int
+	for _, name := range names {
+		if length := utf8.RuneCountInString(name); longest < length {
+			longest = length
+		}
+	}
+
+	var buffer strings.Builder
+	for _, name := range names {
+		info, _ := shellHandler.Info(name)
+
+		description := info.Description
+		if "" == description {
+			description = noDescription
+		}
+		if !shellHandler.permitted(ctx, name) {
+			description = notPermitted + description
+		}
+
+		// Cut the description short, if it does not fit.
+		if available := width - longest - gap - 1; 3 < available && available < utf8.RuneCountInString(description) {
+			description = string([]rune(description)[:available-3]) + "..."
+		}
+
+		buffer.WriteString(name)
+		buffer.WriteString(strings.Repeat(" ", longest+gap-utf8.RuneCountInString(name)))
+		buffer.WriteString(description)
+		buffer.WriteString("\r\n")
+	}
+
+	return buffer.String()
+}
+
+// helpCommand returns the usage line, and (long) help, for the command 'name'.
+func helpCommand(name string, info CommandInfo) string {
+
+	usage := info.Usage
+	if "" == usage {
+		usage = name
+	}
+
+	help := info.Help
+	if "" == help {
+		help = info.Description
+	}
+	if "" == help {
+		help = noDescription
+	}
+
+	// The help might use "\n" rather than "\r\n" for its end-of-lines.
+	help = strings.ReplaceAll(strings.ReplaceAll(help, "\r\n", "\n"), "\n", "\r\n")
+	help = strings.TrimSuffix(help, "\r\n")
+
+	return "Usage: " + usage + "\r\n" + "\r\n" + help + "\r\n"
+}
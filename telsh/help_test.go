@@ -0,0 +1,102 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"strings"
+
+	"testing"
+)
+
+func TestServeTELNETHelp(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "help\r\n",
+			Expected: "" +
+				"count     Count the lines of the input.\r\n" +
+				"env       List the session's variables.\r\n" +
+				"exit      End the session.\r\n" +
+				"grep      Write the lines of the input that match a pattern.\r\n" +
+				"head      Write the first lines of the input.\r\n" +
+				"help      List the commands; or show the help for a command.\r\n" +
+				"logout    End the session.\r\n" +
+				"ping      Check whether a host is reachable.\r\n" +
+				"quit      End the session.\r\n" +
+				"reboot    (no description)\r\n" +
+				"set       Set a session variable; or, without any arguments, list them.\r\n" +
+				"shutdown  Turn everything off; and I do mean everything, really, all of it. ...\r\n" +
+				"terminal  Show or set the terminal settings for the session.\r\n" +
+				"unset     Remove session variables.\r\n",
+		},
+		{
+			ClientSends: "help ping\r\n",
+			Expected:    "Usage: ping <host>\r\n\r\nSends ICMP echo requests to <host>,\r\nand reports the replies.\r\n",
+		},
+		{
+			ClientSends: "help reboot\r\n",
+			Expected:    "Usage: reboot\r\n\r\n(no description)\r\n",
+		},
+		{
+			ClientSends: "help shutdown\r\n",
+			Expected:    "Usage: shutdown\r\n\r\nTurn everything off; and I do mean everything, really, all of it. It cannot be undone.\r\n",
+		},
+		{
+			ClientSends: "help apple\r\n",
+			Expected:    "help: no such command: apple\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+
+		fn := func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			return nil
+		}
+		produce := func(ctx telnet.Context, name string, args ...string) Handler {
+			return PromoteHandlerFunc(fn, args...)
+		}
+
+		shellHandler.MustRegisterCommand("ping", ProducerFunc(produce), CommandInfo{
+			Description: "Check whether a host is reachable.",
+			Usage:       "ping <host>",
+			Help:        "Sends ICMP echo requests to <host>,\nand reports the replies.\n",
+		})
+		shellHandler.MustRegisterCommand("shutdown", ProducerFunc(produce), CommandInfo{
+			Description: "Turn everything off; and I do mean everything, really, all of it. It cannot be undone.",
+		})
+		shellHandler.MustRegister("reboot", ProducerFunc(produce))
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := shellHandler.WelcomeMessage+shellHandler.Prompt+test.Expected+shellHandler.Prompt+shellHandler.ExitMessage, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETHelpOverridden(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+	shellHandler.MustRegisterHandlerFunc("help", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		io.WriteString(stdout, "rtfm!\r\n")
+		return nil
+	})
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("help\r\n"))
+
+	if expected, actual := shellHandler.WelcomeMessage+shellHandler.Prompt+"rtfm!\r\n"+shellHandler.Prompt+shellHandler.ExitMessage, buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
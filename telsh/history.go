@@ -0,0 +1,96 @@
+package telsh
+
+// internalHistory is a (per session) command history, which can be moved through
+// (with the up and down arrow keys) by the line editor.
+//
+// It is a ring buffer: once it holds 'size' lines, adding another line drops the oldest one.
+type internalHistory struct {
+	size    int
+	entries []string
+
+	// position is the index (into 'entries') of the line currently being shown by the
+	// line editor; len(entries) means the (new) line being typed.
+	position int
+
+	// pending is what was being typed, before moving into the history; so that it can
+	// be restored when moving back out of the history.
+	pending string
+}
+
+func newHistory(size int, entries []string) *internalHistory {
+	history := internalHistory{
+		size: size,
+	}
+
+	for _, entry := range entries {
+		history.add(entry)
+	}
+
+	return &history
+}
+
+// add adds 'line' to the end of the history (unless it is empty, or the same as the
+// most recent line), and resets the position to the end.
+func (history *internalHistory) add(line string) {
+	defer history.reset()
+
+	if history.size <= 0 || "" == line {
+		return
+	}
+
+	if length := len(history.entries); 0 < length && line == history.entries[length-1] {
+		return
+	}
+
+	history.entries = append(history.entries, line)
+	if excess := len(history.entries) - history.size; 0 < excess {
+		history.entries = append([]string(nil), history.entries[excess:]...)
+	}
+}
+
+// reset moves the position back to the (new) line being typed.
+func (history *internalHistory) reset() {
+	history.position = len(history.entries)
+	history.pending = ""
+}
+
+// previous moves back one line in the history, and returns that line.
+//
+// 'current' is what is currently being shown.
+//
+// If already at the oldest line, then false is returned.
+func (history *internalHistory) previous(current string) (string, bool) {
+	if history.position <= 0 {
+		return "", false
+	}
+
+	if len(history.entries) == history.position {
+		history.pending = current
+	}
+
+	history.position--
+
+	return history.entries[history.position], true
+}
+
+// next moves forward one line in the history, and returns that line.
+//
+// If already at the (new) line being typed, then false is returned.
+func (history *internalHistory) next() (string, bool) {
+	if len(history.entries) <= history.position {
+		return "", false
+	}
+
+	history.position++
+
+	if len(history.entries) == history.position {
+		return history.pending, true
+	}
+
+	return history.entries[history.position], true
+}
+
+// lines returns a copy of the lines in the history, oldest first.
+func (history *internalHistory) lines() []string {
+	return append([]string(nil), history.entries...)
+}
@@ -0,0 +1,69 @@
+package telsh
+
+import (
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+
+	history := newHistory(3, []string{"one", "two"})
+
+	history.add("three")
+	history.add("three") // Duplicates of the most recent line are not added.
+	history.add("")      // Neither are empty lines.
+	history.add("four")  // Drops "one".
+
+	if expected, actual := []string{"two", "three", "four"}, history.lines(); len(expected) != len(actual) || expected[0] != actual[0] || expected[1] != actual[1] || expected[2] != actual[2] {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	tests := []struct {
+		Up         bool
+		ExpectedOK bool
+		Expected   string
+	}{
+		{Up: false, ExpectedOK: false, Expected: ""},
+
+		{Up: true, ExpectedOK: true, Expected: "four"},
+		{Up: true, ExpectedOK: true, Expected: "three"},
+		{Up: true, ExpectedOK: true, Expected: "two"},
+		{Up: true, ExpectedOK: false, Expected: ""},
+
+		{Up: false, ExpectedOK: true, Expected: "three"},
+		{Up: false, ExpectedOK: true, Expected: "four"},
+		{Up: false, ExpectedOK: true, Expected: "half typed"},
+		{Up: false, ExpectedOK: false, Expected: ""},
+	}
+
+	for testNumber, test := range tests {
+		var actual string
+		var ok bool
+		if test.Up {
+			actual, ok = history.previous("half typed")
+		} else {
+			actual, ok = history.next()
+		}
+
+		if expected := test.ExpectedOK; expected != ok {
+			t.Errorf("For test #%d, expected %t, but actually got %t.", testNumber, expected, ok)
+			continue
+		}
+		if expected := test.Expected; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+
+	history := newHistory(0, []string{"one"})
+	history.add("two")
+
+	if actual := history.lines(); 0 != len(actual) {
+		t.Errorf("Expected no lines, but actually got %q.", actual)
+	}
+	if _, ok := history.previous(""); ok {
+		t.Errorf("Expected nothing to recall, but actually got something.")
+	}
+}
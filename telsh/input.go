@@ -0,0 +1,151 @@
+package telsh
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+const asciiETX = 0x03 // Ctrl-C
+
+// internalInput reads (in the background) everything the client sends, for the rest of the
+// session; and queues it up, to be read (with Read) by the shell, or a command.
+//
+// This is so that the shell can notice the user interrupting a command (with Ctrl-C, or
+// IAC IP), even while it is waiting for that command (and not reading from the client).
+// Anything else typed while a command is running is kept; and is read once the command is done.
+type internalInput struct {
+	mutex     sync.Mutex
+	queue     []byte
+	err       error
+	ready     chan struct{}
+	interrupt func()
+	ctrlC     bool
+}
+
+// newInput creates an internalInput, and starts it reading from 'reader'.
+func newInput(reader io.Reader) *internalInput {
+	input := internalInput{
+		ready: make(chan struct{}, 1),
+	}
+
+	go input.pump(reader)
+
+	return &input
+}
+
+func (input *internalInput) pump(reader io.Reader) {
+
+	var buffer [1]byte
+	p := buffer[:]
+
+	for {
+		n, err := reader.Read(p)
+		if n <= 0 && nil == err {
+			continue
+		}
+
+		input.mutex.Lock()
+		var interrupt func()
+		if 0 < n {
+			if asciiETX == p[0] && input.ctrlC && nil != input.interrupt {
+				interrupt = input.interrupt
+			} else {
+				input.queue = append(input.queue, p[0])
+			}
+		}
+		if nil != err {
+			input.err = err
+		}
+		input.mutex.Unlock()
+
+		if nil != interrupt {
+			interrupt()
+		}
+
+		select {
+		case input.ready <- struct{}{}:
+		default:
+		}
+
+		if nil != err {
+			return
+		}
+	}
+}
+
+// Read reads what the client sent; waiting for it to send something, if it has not yet.
+func (input *internalInput) Read(p []byte) (int, error) {
+	return input.read(nil, p)
+}
+
+// read is like Read, except that it stops waiting (and returns the error of 'ctx') once 'ctx'
+// is done. (If 'ctx' is nil, then it waits for as long as it takes.)
+func (input *internalInput) read(ctx context.Context, p []byte) (int, error) {
+	if len(p) <= 0 {
+		return 0, nil
+	}
+
+	var done <-chan struct{}
+	if nil != ctx {
+		done = ctx.Done()
+	}
+
+	for {
+		input.mutex.Lock()
+		if 0 < len(input.queue) {
+			n := copy(p, input.queue)
+			input.queue = input.queue[n:]
+			input.mutex.Unlock()
+			return n, nil
+		}
+		err := input.err
+		input.mutex.Unlock()
+
+		if nil != err {
+			return 0, err
+		}
+
+		select {
+		case <-input.ready:
+		case <-done:
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// until returns an io.Reader, which reads from 'input' until 'ctx' is done.
+func (input *internalInput) until(ctx context.Context) io.Reader {
+	return internalInputReader{input: input, ctx: ctx}
+}
+
+type internalInputReader struct {
+	input *internalInput
+	ctx   context.Context
+}
+
+func (reader internalInputReader) Read(p []byte) (int, error) {
+	return reader.input.read(reader.ctx, p)
+}
+
+// interruptWith has 'fn' called whenever the user interrupts; which is with IAC IP, or (if 'ctrlC'
+// is true, i.e. in character mode) with Ctrl-C. The Ctrl-C is then not queued up to be read.
+//
+// If 'fn' is nil, then interrupting does nothing (and a Ctrl-C is read like anything else).
+func (input *internalInput) interruptWith(fn func(), ctrlC bool) {
+	input.mutex.Lock()
+	input.interrupt = fn
+	input.ctrlC = ctrlC
+	input.mutex.Unlock()
+}
+
+// interrupted is called when the client sent an IAC IP.
+func (input *internalInput) interrupted() {
+	input.mutex.Lock()
+	fn := input.interrupt
+	input.mutex.Unlock()
+
+	if nil != fn {
+		fn()
+	}
+}
@@ -0,0 +1,797 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	asciiNUL   = 0x00
+	asciiCtrlA = 0x01
+	asciiCtrlB = 0x02
+	asciiCtrlD = 0x04
+	asciiCtrlE = 0x05
+	asciiCtrlF = 0x06
+	asciiBEL   = 0x07
+	asciiBS    = 0x08
+	asciiTAB   = 0x09
+	asciiLF    = '\n'
+	asciiCtrlK = 0x0B
+	asciiCR    = '\r'
+	asciiCtrlU = 0x15
+	asciiCtrlW = 0x17
+	asciiESC   = 0x1B
+	asciiDEL   = 0x7F
+)
+
+// defaultWidth is the width assumed for the client's terminal when it has not said
+// (with NAWS) what its width is.
+const defaultWidth = 80
+
+type internalEscapeState int
+
+const (
+	escapeNone internalEscapeState = iota
+	escapeESC                      // Received ESC.
+	escapeCSI                      // Received ESC [ (or ESC O), and now collecting the rest of the sequence.
+)
+
+// LineEditor turns the bytes typed by the client into lines.
+//
+// It is what the ShellHandler uses to read commands, but can be used by any telnet.ContextHandler
+// (or telnet.Handler). For example:
+//
+//	func (handler myHandler) ServeTELNET(ctx context.Context, conn *telnet.Conn) {
+//		editor := telsh.NewLineEditor(conn)
+//		editor.Echo = func() bool {
+//			local, _ := conn.OptionEnabled(telnet.OptEcho)
+//			return local
+//		}
+//
+//		for {
+//			editor.ShowPrompt("> ")
+//
+//			line, err := editor.ReadLine(conn)
+//			if nil != err {
+//				return
+//			}
+//
+//			//...
+//		}
+//	}
+//
+// If the server is echoing (i.e., Echo returns true), then the LineEditor echos what is
+// typed back, and supports editing the line with:
+//
+//	BS, DEL       delete the character before the cursor
+//	Ctrl-A, Home  move to the start of the line
+//	Ctrl-E, End   move to the end of the line
+//	Ctrl-B, Left  move left one character
+//	Ctrl-F, Right move right one character
+//	Delete        delete the character under the cursor
+//	Ctrl-D        delete the character under the cursor (or, on an empty line, end-of-file)
+//	Ctrl-K        delete from the cursor to the end of the line
+//	Ctrl-U        delete from the start of the line to the cursor
+//	Ctrl-W        delete the word before the cursor
+//	Up, Down      recall the previous (or next) line from the history
+//	TAB           complete the word before the cursor (see Complete)
+//
+// (Where the arrow keys, and Home, End, and Delete, are the ANSI escape sequences the
+// client sends for them.)
+//
+// If the client is echoing locally (i.e., Echo returns false), then the client does its
+// own line editing; so nothing is echoed, and only BS and DEL are handled.
+//
+// Either way, Ctrl-D on an empty line is end-of-file; so ReadLine (and Feed) return io.EOF.
+//
+// If a line gets longer than MaxLength, then the rest of it is thrown away; and, at the end
+// of the line, ReadLine (and Feed) return telnet.ErrLineTooLong (rather than the line).
+type LineEditor struct {
+	// Echo returns whether the server (and therefore the LineEditor) is echoing.
+	//
+	// If Echo is nil, then the LineEditor does not echo.
+	Echo func() bool
+
+	// Width returns the width of the client's terminal, or zero if it is unknown.
+	//
+	// The width is needed to correctly redraw lines that are longer than the width
+	// of the client's terminal (and so have wrapped).
+	Width func() int
+
+	// Complete, if not nil, returns what the word (in 'line') that ends at 'cursor'
+	// could be completed to. It is called when the user presses TAB.
+	Complete func(line string, cursor int) []string
+
+	// MaxLength, if not zero, is the longest a line can be, in bytes (not including the
+	// end-of-line). Once a line gets longer than that, the rest of it is thrown away (with a
+	// BEL sent to the client, if the LineEditor is echoing).
+	MaxLength int
+
+	// LineTooLong, if not nil, is called at the end of a line that was longer than MaxLength;
+	// and what it returns is returned (by ReadLine, and Feed) instead of telnet.ErrLineTooLong.
+	// For example:
+	//
+	//	editor.MaxLength = conn.InputLimits().MaxLineLength
+	//	editor.LineTooLong = conn.LineTooLong
+	LineTooLong func() error
+
+	writer io.Writer
+
+	history *internalHistory
+
+	prompt string
+	buffer []byte
+
+	// cursor is the index (into 'buffer') of the cursor.
+	cursor int
+
+	// row is which row of the client's terminal (counting from the row the prompt starts
+	// on) the client's cursor is on.
+	row int
+
+	escape       internalEscapeState
+	escapeParams []byte
+
+	// hidden is whether what is typed is hidden; i.e., not echoed (and not added to the
+	// history). It is used for reading passwords.
+	hidden bool
+
+	// lastWasCR is whether the previous byte was a CR; so that the LF (or NUL) after
+	// it can be dropped.
+	lastWasCR bool
+
+	// overflowed is whether the line being read got longer than MaxLength; and so the rest of
+	// it is being thrown away.
+	overflowed bool
+
+	// mutex is the render lock; which is held while anything is written to the client
+	// (see AsyncWriter).
+	mutex sync.Mutex
+
+	// reading is whether the prompt (and the line being edited) is shown; i.e., whether
+	// a line is being read.
+	reading bool
+
+	// atLineStart is whether the client's cursor is at the start of a row; which is only
+	// kept track of while a line is not being read.
+	atLineStart bool
+
+	asyncMutex sync.Mutex
+	async      [][]byte
+}
+
+// NewLineEditor returns a LineEditor that echos (and redraws) to 'writer'.
+//
+// If 'writer' is a *telnet.Conn, then it takes care of escaping any IAC bytes.
+func NewLineEditor(writer io.Writer) *LineEditor {
+	editor := LineEditor{
+		writer:  writer,
+		history: newHistory(0, nil),
+	}
+
+	return &editor
+}
+
+// SetHistory sets the (maximum) number of lines kept in the LineEditor's history, and
+// the lines in it, oldest first.
+func (editor *LineEditor) SetHistory(size int, lines []string) {
+	editor.history = newHistory(size, lines)
+}
+
+// History returns the lines in the LineEditor's history, oldest first.
+func (editor *LineEditor) History() []string {
+	return editor.history.lines()
+}
+
+// ShowPrompt writes 'prompt', and starts a new (empty) line.
+func (editor *LineEditor) ShowPrompt(prompt string) error {
+	editor.lock()
+	defer editor.unlock()
+
+	editor.reading = true
+	editor.prompt = prompt
+	editor.buffer = editor.buffer[:0]
+	editor.cursor = 0
+	editor.row = 0
+	editor.escape = escapeNone
+	editor.overflowed = false
+
+	if _, err := oi.LongWriteString(editor.writer, prompt); nil != err {
+		return err
+	}
+
+	if !editor.echo() {
+		return nil
+	}
+	return editor.wrapped()
+}
+
+// Redraw draws the prompt, and the line being edited, again.
+//
+// It is for after something else has been written to the client, in the middle of a
+// line being edited, which left the client's cursor at the start of a new row.
+// For example:
+//
+//	oi.LongWriteString(w, "\r\nThe system is going down for reboot in 5 minutes!\r\n")
+//	editor.Redraw()
+func (editor *LineEditor) Redraw() error {
+	editor.lock()
+	defer editor.unlock()
+
+	if !editor.echo() {
+		_, err := oi.LongWriteString(editor.writer, editor.prompt)
+		return err
+	}
+
+	return editor.draw()
+}
+
+// ReadLine reads from 'reader' (1 byte at a time, so as to not read past the end of the
+// line) until a line has been typed, and returns it (without the end-of-line).
+func (editor *LineEditor) ReadLine(reader io.Reader) (string, error) {
+
+	var buffer [1]byte
+	p := buffer[:]
+
+	for {
+		n, err := reader.Read(p)
+		if 0 < n {
+			line, ok, err := editor.Feed(p[0])
+			if nil != err {
+				return "", err
+			}
+			if ok {
+				return line, nil
+			}
+		}
+		if nil != err {
+			return "", err
+		}
+	}
+}
+
+// Feed handles a single byte typed by the client.
+//
+// If 'b' ended a line, then the line (without the end-of-line) is returned along with true.
+//
+// If 'b' is Ctrl-D on an empty line, then io.EOF is returned.
+//
+// If 'b' ended a line that was longer than MaxLength, then telnet.ErrLineTooLong (or what
+// LineTooLong returned) is returned.
+func (editor *LineEditor) Feed(b byte) (string, bool, error) {
+	editor.lock()
+	defer editor.unlock()
+
+	return editor.feed(b)
+}
+
+func (editor *LineEditor) feed(b byte) (string, bool, error) {
+
+	lastWasCR := editor.lastWasCR
+	editor.lastWasCR = false
+
+	switch editor.escape {
+	case escapeESC:
+		switch b {
+		case '[', 'O':
+			editor.escape = escapeCSI
+			editor.escapeParams = editor.escapeParams[:0]
+		default:
+			editor.escape = escapeNone
+		}
+		return "", false, nil
+	case escapeCSI:
+		// Parameter and intermediate bytes come before the final byte.
+		if 0x40 <= b && b <= 0x7E {
+			editor.escape = escapeNone
+			return "", false, editor.escapeSequence(string(editor.escapeParams), b)
+		}
+		editor.escapeParams = append(editor.escapeParams, b)
+		return "", false, nil
+	}
+
+	if editor.overflowed {
+		switch b {
+		case asciiCR, asciiLF:
+			editor.lastWasCR = asciiCR == b
+			return "", false, editor.endOverflowed()
+		}
+		return "", false, nil
+	}
+
+	switch b {
+	case asciiCR:
+		editor.lastWasCR = true
+		return editor.enter()
+	case asciiLF, asciiNUL:
+		if lastWasCR {
+			return "", false, nil
+		}
+		if asciiLF == b {
+			return editor.enter()
+		}
+		return "", false, nil
+	case asciiESC:
+		editor.escape = escapeESC
+		return "", false, nil
+	case asciiBS, asciiDEL:
+		return "", false, editor.backspace()
+	case asciiCtrlD:
+		// Ctrl-D on an empty line is end-of-file.
+		if len(editor.buffer) <= 0 {
+			return "", false, io.EOF
+		}
+	}
+
+	if !editor.echo() {
+		// Drop any other control characters.
+		if b < 0x20 {
+			return "", false, nil
+		}
+
+		if editor.full() {
+			return "", false, editor.overflow()
+		}
+
+		editor.buffer = append(editor.buffer, b)
+		editor.cursor = len(editor.buffer)
+		return "", false, nil
+	}
+
+	switch b {
+	case asciiTAB:
+		return "", false, editor.tab()
+	case asciiCtrlA:
+		return "", false, editor.moveTo(0)
+	case asciiCtrlE:
+		return "", false, editor.moveTo(len(editor.buffer))
+	case asciiCtrlB:
+		return "", false, editor.moveTo(editor.previousRune(editor.cursor))
+	case asciiCtrlF:
+		return "", false, editor.moveTo(editor.nextRune(editor.cursor))
+	case asciiCtrlD:
+		return "", false, editor.delete(editor.cursor, editor.nextRune(editor.cursor))
+	case asciiCtrlK:
+		return "", false, editor.delete(editor.cursor, len(editor.buffer))
+	case asciiCtrlU:
+		return "", false, editor.delete(0, editor.cursor)
+	case asciiCtrlW:
+		return "", false, editor.delete(editor.previousWord(editor.cursor), editor.cursor)
+	}
+
+	// Drop any other control characters.
+	if b < 0x20 {
+		return "", false, nil
+	}
+
+	if editor.full() {
+		return "", false, editor.overflow()
+	}
+
+	return "", false, editor.insert(string([]byte{b}))
+}
+
+// full returns whether the line being read is already MaxLength long.
+func (editor *LineEditor) full() bool {
+	return 0 < editor.MaxLength && editor.MaxLength <= len(editor.buffer)
+}
+
+// overflow throws away the line being read (and the rest of it, as it is typed); because it got
+// longer than MaxLength.
+func (editor *LineEditor) overflow() error {
+	editor.overflowed = true
+
+	if !editor.echo() {
+		return nil
+	}
+
+	_, err := oi.LongWrite(editor.writer, []byte{asciiBEL})
+	return err
+}
+
+// endOverflowed ends the line that got longer than MaxLength.
+func (editor *LineEditor) endOverflowed() error {
+	echo := editor.echo()
+	if echo {
+		if err := editor.moveTo(len(editor.buffer)); nil != err {
+			return err
+		}
+	}
+
+	editor.overflowed = false
+	editor.reading = false
+	editor.atLineStart = true
+	editor.buffer = editor.buffer[:0]
+	editor.cursor = 0
+
+	if echo {
+		if _, err := oi.LongWriteString(editor.writer, "\r\n"); nil != err {
+			return err
+		}
+	}
+
+	if fn := editor.LineTooLong; nil != fn {
+		return fn()
+	}
+	return telnet.ErrLineTooLong
+}
+
+// ReadPassword is like ReadLine, except what is typed is not echoed (no matter what Echo
+// returns), and is not added to the history.
+func (editor *LineEditor) ReadPassword(reader io.Reader) (string, error) {
+	editor.lock()
+	editor.hidden = true
+	editor.unlock()
+	defer func() {
+		editor.lock()
+		editor.hidden = false
+		editor.unlock()
+	}()
+
+	return editor.ReadLine(reader)
+}
+
+func (editor *LineEditor) echo() bool {
+	if nil == editor.Echo || editor.hidden {
+		return false
+	}
+
+	return editor.Echo()
+}
+
+func (editor *LineEditor) width() int {
+	if nil == editor.Width {
+		return 0
+	}
+
+	return editor.Width()
+}
+
+func (editor *LineEditor) enter() (string, bool, error) {
+	line := string(editor.buffer)
+
+	editor.reading = false
+	editor.atLineStart = true
+
+	if editor.hidden {
+		editor.buffer = editor.buffer[:0]
+		editor.cursor = 0
+
+		// Even though what was typed was not echoed, the end-of-line still is.
+		if nil != editor.Echo && editor.Echo() {
+			if _, err := oi.LongWriteString(editor.writer, "\r\n"); nil != err {
+				return line, true, err
+			}
+		}
+		return line, true, nil
+	}
+
+	editor.history.add(line)
+
+	if editor.echo() {
+		if err := editor.moveTo(len(editor.buffer)); nil != err {
+			return line, true, err
+		}
+
+		// If the line ended exactly at the edge of the client's terminal, then the
+		// client's cursor has already been moved to the next row.
+		if _, column := editor.position(len(editor.buffer)); 0 != column || 0 == editor.row {
+			if _, err := oi.LongWriteString(editor.writer, "\r\n"); nil != err {
+				return line, true, err
+			}
+		}
+	}
+
+	editor.buffer = editor.buffer[:0]
+	editor.cursor = 0
+
+	return line, true, nil
+}
+
+// insert inserts 's' at the cursor.
+func (editor *LineEditor) insert(s string) error {
+	if "" == s {
+		return nil
+	}
+
+	if len(editor.buffer) != editor.cursor {
+		editor.buffer = append(editor.buffer[:editor.cursor], append([]byte(s), editor.buffer[editor.cursor:]...)...)
+		editor.cursor += len(s)
+		return editor.refresh()
+	}
+
+	editor.buffer = append(editor.buffer, s...)
+	editor.cursor = len(editor.buffer)
+
+	if _, err := oi.LongWriteString(editor.writer, s); nil != err {
+		return err
+	}
+
+	return editor.wrapped()
+}
+
+// wrapped makes sure the client's cursor has actually moved to the next row, after
+// writing up to (exactly) the edge of the client's terminal; and keeps track of which
+// row the client's cursor is on.
+//
+// (Terminals do not wrap until the next character is written.)
+func (editor *LineEditor) wrapped() error {
+	row, column := editor.position(editor.cursor)
+	if 0 == column && editor.row < row {
+		if _, err := oi.LongWriteString(editor.writer, "\r\n"); nil != err {
+			return err
+		}
+	}
+
+	editor.row = row
+	return nil
+}
+
+func (editor *LineEditor) backspace() error {
+	if editor.cursor <= 0 {
+		return nil
+	}
+
+	i := editor.previousRune(editor.cursor)
+
+	if !editor.echo() {
+		editor.buffer = editor.buffer[:i]
+		editor.cursor = i
+		return nil
+	}
+
+	// The simple case, of deleting the last character (on the same row).
+	if _, column := editor.position(editor.cursor); len(editor.buffer) == editor.cursor && 0 != column {
+		editor.buffer = editor.buffer[:i]
+		editor.cursor = i
+
+		_, err := oi.LongWriteString(editor.writer, "\b \b")
+		return err
+	}
+
+	return editor.delete(i, editor.cursor)
+}
+
+// delete deletes buffer[from:to], and redraws the line.
+func (editor *LineEditor) delete(from int, to int) error {
+	if to <= from {
+		return nil
+	}
+
+	editor.buffer = append(editor.buffer[:from], editor.buffer[to:]...)
+	editor.cursor = from
+
+	return editor.refresh()
+}
+
+// previousRune returns the index of the (UTF-8 encoded) character before buffer[i].
+func (editor *LineEditor) previousRune(i int) int {
+	if i <= 0 {
+		return 0
+	}
+
+	i--
+	for 0 < i && !utf8.RuneStart(editor.buffer[i]) {
+		i--
+	}
+
+	return i
+}
+
+// nextRune returns the index of the (UTF-8 encoded) character after the one at buffer[i].
+func (editor *LineEditor) nextRune(i int) int {
+	if len(editor.buffer) <= i {
+		return len(editor.buffer)
+	}
+
+	_, size := utf8.DecodeRune(editor.buffer[i:])
+	return i + size
+}
+
+// previousWord returns the index of the start of the word before buffer[i].
+func (editor *LineEditor) previousWord(i int) int {
+	for 0 < i && ' ' == editor.buffer[i-1] {
+		i--
+	}
+	for 0 < i && ' ' != editor.buffer[i-1] {
+		i--
+	}
+
+	return i
+}
+
+// position returns the row (counting from the row the prompt starts on) and column that
+// buffer[i] is (or would be) drawn at on the client's terminal.
+func (editor *LineEditor) position(i int) (int, int) {
+	cells := visibleWidth(editor.prompt) + utf8.RuneCount(editor.buffer[:i])
+
+	width := editor.width()
+	if width <= 0 {
+		return 0, cells
+	}
+
+	return cells / width, cells % width
+}
+
+// moveTo moves the cursor to buffer[i].
+func (editor *LineEditor) moveTo(i int) error {
+	if editor.cursor == i {
+		return nil
+	}
+
+	editor.cursor = i
+
+	row, column := editor.position(i)
+
+	var sequence string
+	if row < editor.row {
+		sequence += "\x1b[" + strconv.Itoa(editor.row-row) + "A"
+	} else if editor.row < row {
+		sequence += "\x1b[" + strconv.Itoa(row-editor.row) + "B"
+	}
+	sequence += "\r"
+	if 0 < column {
+		sequence += "\x1b[" + strconv.Itoa(column) + "C"
+	}
+
+	editor.row = row
+
+	_, err := oi.LongWriteString(editor.writer, sequence)
+	return err
+}
+
+// refresh redraws the prompt and the line being edited.
+func (editor *LineEditor) refresh() error {
+
+	// Go back to the start of the row the prompt is on. If the line being edited has
+	// wrapped (past the width of the client's terminal), then that means going up.
+	if _, err := oi.LongWriteString(editor.writer, "\r"); nil != err {
+		return err
+	}
+	if 0 < editor.row {
+		if _, err := oi.LongWriteString(editor.writer, "\x1b["+strconv.Itoa(editor.row)+"A"); nil != err {
+			return err
+		}
+	}
+	// Erase from there, to the end of the screen.
+	if _, err := oi.LongWriteString(editor.writer, "\x1b[J"); nil != err {
+		return err
+	}
+
+	return editor.draw()
+}
+
+// draw draws the prompt and the line being edited (starting wherever the client's cursor
+// is), and then moves the client's cursor to where the cursor is.
+func (editor *LineEditor) draw() error {
+	editor.row = 0
+
+	if _, err := oi.LongWriteString(editor.writer, editor.prompt); nil != err {
+		return err
+	}
+	if _, err := oi.LongWrite(editor.writer, editor.buffer); nil != err {
+		return err
+	}
+
+	cursor := editor.cursor
+	editor.cursor = len(editor.buffer)
+	if err := editor.wrapped(); nil != err {
+		return err
+	}
+
+	return editor.moveTo(cursor)
+}
+
+// tab completes the word before the cursor.
+//
+// If there is only one completion, then the word is completed (followed by a space).
+// If there are many, then the word is completed as far as they all agree, and they are
+// listed (in columns) under the line, which is then redrawn. And if there are no
+// completions, then the bell is rung.
+func (editor *LineEditor) tab() error {
+
+	var completions []string
+	if nil != editor.Complete {
+		completions = editor.Complete(string(editor.buffer), editor.cursor)
+	}
+
+	if len(completions) <= 0 {
+		_, err := oi.LongWrite(editor.writer, []byte{asciiBEL})
+		return err
+	}
+
+	start := strings.LastIndexAny(string(editor.buffer[:editor.cursor]), " \t") + 1
+	word := string(editor.buffer[start:editor.cursor])
+
+	completion := commonPrefix(completions)
+	if 1 == len(completions) {
+		completion += " "
+	}
+
+	if len(word) < len(completion) {
+		if strings.HasPrefix(completion, word) {
+			if err := editor.insert(completion[len(word):]); nil != err {
+				return err
+			}
+		} else {
+			editor.buffer = append(editor.buffer[:start], append([]byte(completion), editor.buffer[editor.cursor:]...)...)
+			editor.cursor = start + len(completion)
+			if err := editor.refresh(); nil != err {
+				return err
+			}
+		}
+	}
+
+	if 1 == len(completions) {
+		return nil
+	}
+
+	width := editor.width()
+	if width <= 0 {
+		width = defaultWidth
+	}
+
+	// List the completions under the line, and then draw the line again under them.
+	cursor := editor.cursor
+	if err := editor.moveTo(len(editor.buffer)); nil != err {
+		return err
+	}
+	editor.cursor = cursor
+
+	if _, err := oi.LongWriteString(editor.writer, "\r\n"+columns(completions, width)); nil != err {
+		return err
+	}
+
+	return editor.draw()
+}
+
+// escapeSequence handles the (ANSI) escape sequence: ESC [ <params> <final>.
+func (editor *LineEditor) escapeSequence(params string, final byte) error {
+	if !editor.echo() {
+		return nil
+	}
+
+	switch final {
+	case 'A': // Up arrow.
+		if line, ok := editor.history.previous(string(editor.buffer)); ok {
+			return editor.replace(line)
+		}
+	case 'B': // Down arrow.
+		if line, ok := editor.history.next(); ok {
+			return editor.replace(line)
+		}
+	case 'C': // Right arrow.
+		return editor.moveTo(editor.nextRune(editor.cursor))
+	case 'D': // Left arrow.
+		return editor.moveTo(editor.previousRune(editor.cursor))
+	case 'H': // Home.
+		return editor.moveTo(0)
+	case 'F': // End.
+		return editor.moveTo(len(editor.buffer))
+	case '~':
+		switch params {
+		case "1", "7": // Home.
+			return editor.moveTo(0)
+		case "4", "8": // End.
+			return editor.moveTo(len(editor.buffer))
+		case "3": // Delete.
+			return editor.delete(editor.cursor, editor.nextRune(editor.cursor))
+		}
+	}
+
+	return nil
+}
+
+// replace replaces the line being edited with 'line', and redraws it.
+func (editor *LineEditor) replace(line string) error {
+	editor.buffer = append(editor.buffer[:0], line...)
+	editor.cursor = len(editor.buffer)
+
+	return editor.refresh()
+}
@@ -0,0 +1,364 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"strings"
+
+	"testing"
+)
+
+func TestLineEditor(t *testing.T) {
+
+	tests := []struct {
+		Width       int
+		History     []string
+		ClientSends string
+		Expected    string
+		ExpectedOut string
+	}{
+		{
+			ClientSends: "ls\r\n",
+			Expected:    "ls",
+			ExpectedOut: "> ls\r\n",
+		},
+		{
+			ClientSends: "ls\r\x00",
+			Expected:    "ls",
+			ExpectedOut: "> ls\r\n",
+		},
+		{
+			ClientSends: "ls\n",
+			Expected:    "ls",
+			ExpectedOut: "> ls\r\n",
+		},
+		{
+			ClientSends: "lx\x7fs\r",
+			Expected:    "ls",
+			ExpectedOut: "> lx\b \bs\r\n",
+		},
+		{
+			ClientSends: "l\x08\x08s\r",
+			Expected:    "s",
+			ExpectedOut: "> l\b \bs\r\n",
+		},
+		{
+			ClientSends: "h\xc3\xa9\x7f\r", // Backspacing removes the whole "é".
+			Expected:    "h",
+			ExpectedOut: "> h\xc3\xa9\b \b\r\n",
+		},
+
+		{
+			History:     []string{"one", "two"},
+			ClientSends: "\x1b[A\r",
+			Expected:    "two",
+			ExpectedOut: "> \r\x1b[J> two\r\n",
+		},
+		{
+			History:     []string{"one", "two"},
+			ClientSends: "\x1bOA\x1bOA\r", // Some clients send ESC O, rather than ESC [.
+			Expected:    "one",
+			ExpectedOut: "> \r\x1b[J> two\r\x1b[J> one\r\n",
+		},
+		{
+			History:     []string{"one", "two"},
+			ClientSends: "xy\x1b[A\x1b[B\r",
+			Expected:    "xy",
+			ExpectedOut: "> xy\r\x1b[J> two\r\x1b[J> xy\r\n",
+		},
+		{
+			History:     []string{"one"},
+			ClientSends: "\x1b[A\x1b[A\x1b[1;5C\r", // Nothing older; and other sequences are ignored.
+			Expected:    "one",
+			ExpectedOut: "> \r\x1b[J> one\r\n",
+		},
+		{
+			History:     []string{"a\xffb"}, // Escaping the IAC is up to the telnet.Conn.
+			ClientSends: "\x1b[A\r",
+			Expected:    "a\xffb",
+			ExpectedOut: "> \r\x1b[J> a\xffb\r\n",
+		},
+
+		{
+			Width:       4,
+			History:     []string{"one", "ab"},
+			ClientSends: "123456\x1b[A\r", // "> 123456" is 8 columns; so the cursor ends up 2 rows down.
+			Expected:    "ab",
+			ExpectedOut: "> 12\r\n3456\r\n\r\x1b[2A\x1b[J> ab\r\n",
+		},
+		{
+			Width:       4,
+			History:     []string{"one", "ab"},
+			ClientSends: "12345\x1b[A\x1b[A\r",
+			Expected:    "one",
+			ExpectedOut: "> 12\r\n345\r\x1b[1A\x1b[J> ab\r\n\r\x1b[1A\x1b[J> one\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		editor := NewLineEditor(&buffer)
+		editor.SetHistory(10, test.History)
+		editor.Echo = func() bool { return true }
+		editor.Width = func() int { return test.Width }
+
+		if err := editor.ShowPrompt("> "); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		var lines []string
+		for _, b := range []byte(test.ClientSends) {
+			line, ok, err := editor.Feed(b)
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+				continue
+			}
+			if ok {
+				lines = append(lines, line)
+			}
+		}
+
+		if expected, actual := 1, len(lines); expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+		if expected, actual := test.Expected, lines[0]; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+		if expected, actual := test.ExpectedOut, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestLineEditorNoEcho(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	// By default the line editor does not echo (and so, the client must be echoing).
+	editor := NewLineEditor(&buffer)
+	editor.SetHistory(10, []string{"one"})
+
+	var lines []string
+	for _, b := range []byte("\x1b[Alx\x7fs\r\n") {
+		line, ok, err := editor.Feed(b)
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		if ok {
+			lines = append(lines, line)
+		}
+	}
+
+	if expected, actual := []string{"ls"}, lines; len(expected) != len(actual) || expected[0] != actual[0] {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if expected, actual := "", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestLineEditorMaxLength(t *testing.T) {
+
+	tests := []struct {
+		Echo          bool
+		ClientSends   string
+		ExpectedLines []string
+		ExpectedErrs  int
+		ExpectedOut   string
+	}{
+		{
+			Echo:          true,
+			ClientSends:   "abcd\r\n",
+			ExpectedLines: []string{"abcd"},
+			ExpectedOut:   "> abcd\r\n> ",
+		},
+		{
+			Echo:          true,
+			ClientSends:   "abcdef\r\nls\r\n",
+			ExpectedLines: []string{"ls"},
+			ExpectedErrs:  1,
+			ExpectedOut:   "> abcd\a\r\n> ls\r\n> ",
+		},
+		{
+			// The rest of the line is thrown away; and not run as a line of its own.
+			ClientSends:   "abcdefgh\r\nls\r\n",
+			ExpectedLines: []string{"ls"},
+			ExpectedErrs:  1,
+			ExpectedOut:   "> > > ",
+		},
+		{
+			ClientSends:  "abcde\nabcde\n",
+			ExpectedErrs: 2,
+			ExpectedOut:  "> > > ",
+		},
+	}
+
+	for testNumber, test := range tests {
+		var buffer bytes.Buffer
+
+		editor := NewLineEditor(&buffer)
+		editor.MaxLength = 4
+		editor.Echo = func() bool { return test.Echo }
+
+		editor.ShowPrompt("> ")
+
+		var lines []string
+		var errs int
+		for _, b := range []byte(test.ClientSends) {
+			line, ok, err := editor.Feed(b)
+			if telnet.ErrLineTooLong == err {
+				errs++
+				editor.ShowPrompt("> ")
+				continue
+			}
+			if nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+				continue
+			}
+			if ok {
+				lines = append(lines, line)
+				editor.ShowPrompt("> ")
+			}
+		}
+
+		if expected, actual := strings.Join(test.ExpectedLines, "|"), strings.Join(lines, "|"); expected != actual {
+			t.Errorf("For test #%d, expected lines %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedErrs, errs; expected != actual {
+			t.Errorf("For test #%d, expected %d ErrLineTooLong, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedOut, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestLineEditorEditing(t *testing.T) {
+
+	tests := []struct {
+		Width       int
+		ClientSends string
+		Expected    string
+		ExpectedOut string
+	}{
+		{
+			ClientSends: "abc\x01X\r", // Ctrl-A
+			Expected:    "Xabc",
+			ExpectedOut: "> abc\r\x1b[2C\r\x1b[J> Xabc\r\x1b[3C\r\x1b[6C\r\n",
+		},
+		{
+			ClientSends: "abc\x02\x02\x06Z\r", // Ctrl-B, Ctrl-F
+			Expected:    "abZc",
+		},
+		{
+			ClientSends: "a\x1b[1~b\x1b[4~c\r", // Home, End
+			Expected:    "bac",
+		},
+		{
+			ClientSends: "a\x1b[Hb\x1b[Fc\r", // Home, End
+			Expected:    "bac",
+		},
+		{
+			ClientSends: "abc\x1b[H\x1b[C\x7f\r", // Home, Right
+			Expected:    "bc",
+		},
+		{
+			ClientSends: "abc\x1b[D\x1b[D\x0b\r", // Left, Ctrl-K
+			Expected:    "a",
+		},
+		{
+			ClientSends: "abc\x1b[D\x1b[3~\r", // Left, Delete
+			Expected:    "ab",
+		},
+		{
+			ClientSends: "abc\x01\x04\x04\r", // Ctrl-A, Ctrl-D
+			Expected:    "c",
+		},
+		{
+			ClientSends: "foo  bar\x17\r", // Ctrl-W
+			Expected:    "foo  ",
+		},
+		{
+			ClientSends: "foo  bar\x17\x17\r", // Ctrl-W
+			Expected:    "",
+		},
+		{
+			ClientSends: "foo bar\x1b[D\x1b[D\x15\r", // Ctrl-U
+			Expected:    "ar",
+		},
+		{
+			ClientSends: "h\xc3\xa9\x02x\r",
+			Expected:    "hx\xc3\xa9",
+		},
+
+		{
+			Width:       4,
+			ClientSends: "12345\x01\r",
+			Expected:    "12345",
+			ExpectedOut: "> 12\r\n345\x1b[1A\r\x1b[2C\x1b[1B\r\x1b[3C\r\n",
+		},
+		{
+			Width:       4,
+			ClientSends: "12\x7f\r", // Backspacing of a wrapped line.
+			Expected:    "1",
+			ExpectedOut: "> 12\r\n\r\x1b[1A\x1b[J> 1\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var buffer bytes.Buffer
+
+		editor := NewLineEditor(&buffer)
+		editor.Echo = func() bool { return true }
+		editor.Width = func() int { return test.Width }
+
+		editor.ShowPrompt("> ")
+
+		line, err := editor.ReadLine(strings.NewReader(test.ClientSends))
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.Expected, line; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+		if expected, actual := test.ExpectedOut, buffer.String(); "" != expected && expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestLineEditorRedraw(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	editor := NewLineEditor(&buffer)
+	editor.Echo = func() bool { return true }
+
+	editor.ShowPrompt("> ")
+	for _, b := range []byte("abc\x1b[D") {
+		editor.Feed(b)
+	}
+
+	// Something else gets written, in the middle of the line being edited.
+	buffer.WriteString("\r\nHello!\r\n")
+
+	if err := editor.Redraw(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "> abc\r\x1b[4C\r\nHello!\r\n> abc\r\x1b[4C", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,148 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+	"golang.org/x/crypto/bcrypt"
+
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	defaultLoginTries     = 3
+	defaultLoginDelay     = time.Second
+	defaultUsernamePrompt = "login: "
+	defaultPasswordPrompt = "Password: "
+	defaultLoginIncorrect = "Login incorrect\r\n"
+)
+
+// dummyBcryptHash is what BcryptAuthenticate checks the password against, for a username it does not
+// have a hash for; so that it takes just as long to turn away an unknown username as a wrong
+// password (and so does not give away which usernames there are).
+const dummyBcryptHash = "$2a$10$uj7EmW/J.a1Ojgyi3EbOY.lG1ytbqkqXNQ.y6Fvt0j/bLgcqNeRNK"
+
+var (
+	errLoginIncorrect    = errors.New("Login incorrect")
+	errTooManyLoginTries = errors.New("Too many login tries")
+)
+
+// BcryptAuthenticate returns a func that can be used as a ShellHandler's Authenticate;
+// which checks the password against the bcrypt hash (in 'hashes') for the username.
+//
+// (An unknown username is checked against a dummy hash; so that it takes just as long as a
+// wrong password.)
+//
+// For example:
+//
+//	shellHandler.Authenticate = telsh.BcryptAuthenticate(map[string]string{
+//		"joeblow": "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+//	})
+func BcryptAuthenticate(hashes map[string]string) func(ctx Context, username string, password string, tries int) (string, error) {
+	return func(ctx Context, username string, password string, tries int) (string, error) {
+		hash, ok := hashes[username]
+		if !ok {
+			bcrypt.CompareHashAndPassword([]byte(dummyBcryptHash), []byte(password))
+			return "", errLoginIncorrect
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); nil != err {
+			return "", errLoginIncorrect
+		}
+
+		return username, nil
+	}
+}
+
+// login asks for a username and password (until Authenticate accepts them, or there
+// have been too many tries), and returns the (logged in) user.
+func (telnetHandler *ShellHandler) login(ctx Context, writer io.Writer, reader io.Reader, editor *LineEditor, echo *internalEcho) (string, error) {
+
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	maxTries := telnetHandler.LoginTries
+	if maxTries <= 0 {
+		maxTries = defaultLoginTries
+	}
+
+	for tries := 1; tries <= maxTries; tries++ {
+
+		if err := editor.ShowPrompt(telnetHandler.UsernamePrompt); nil != err {
+			return "", err
+		}
+		username, err := editor.ReadLine(reader)
+		if nil != err {
+			return "", err
+		}
+
+		if err := editor.ShowPrompt(telnetHandler.PasswordPrompt); nil != err {
+			return "", err
+		}
+		password, err := telnetHandler.readPassword(reader, editor, echo)
+		if nil != err {
+			return "", err
+		}
+
+		user, err := telnetHandler.Authenticate(ctx, username, password, tries)
+		if nil == err {
+			logger.Debugf("Logged in %q (as %q), on try #%d.", username, user, tries)
+			return user, nil
+		}
+		logger.Debugf("Did not log in %q, on try #%d: %v", username, tries, err)
+
+		if _, err := oi.LongWriteString(writer, defaultLoginIncorrect); nil != err {
+			return "", err
+		}
+
+		if tries < maxTries && 0 < telnetHandler.LoginDelay {
+			timer := time.NewTimer(telnetHandler.LoginDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return "", ctx.Err()
+			}
+		}
+	}
+
+	return "", errTooManyLoginTries
+}
+
+// readPassword reads a password; turning (client-side) echoing off while doing so, if
+// the shell is not already doing the echoing.
+func (telnetHandler *ShellHandler) readPassword(reader io.Reader, editor *LineEditor, echo *internalEcho) (string, error) {
+
+	if nil != echo && nil == editor.Echo {
+		if err := echo.sender.EnableLocal(); nil != err {
+			return "", err
+		}
+
+		editor.Echo = echo.Enabled
+		defer func() {
+			editor.Echo = nil
+			echo.sender.DisableLocal()
+		}()
+	}
+
+	return editor.ReadPassword(reader)
+}
+
+// loginEcho registers (if it is not already) the ECHO option; so that echoing can be
+// turned off while reading a password.
+func (telnetHandler *ShellHandler) loginEcho(logger telnet.Logger, conn *telnet.Conn, echo *internalEcho) *internalEcho {
+	if nil != echo || nil == conn {
+		return echo
+	}
+
+	echo = &internalEcho{}
+	if err := conn.RegisterOption(telnet.OptEcho, echo); nil != err {
+		logger.Warnf("Problem registering ECHO option: %v", err)
+		return nil
+	}
+
+	return echo
+}
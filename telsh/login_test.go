@@ -0,0 +1,204 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+	"golang.org/x/crypto/bcrypt"
+
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func testLoginShellHandler(t *testing.T) *ShellHandler {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	shellHandler := NewShellHandler()
+	shellHandler.LoginDelay = 0
+	shellHandler.Authenticate = BcryptAuthenticate(map[string]string{
+		"joeblow": string(hash),
+	})
+	shellHandler.MustRegister("whoami", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			oi.LongWriteString(stdout, ctx.(Context).User()+"\r\n")
+			return nil
+		})
+	}))
+
+	return shellHandler
+}
+
+func TestServeTELNETAuthenticate(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "joeblow\r\ns3cret\r\nwhoami\r\n",
+			Expected:    "login: Password: " + defaultWelcomeMessage + defaultPrompt + "joeblow\r\n" + defaultPrompt + defaultExitMessage,
+		},
+		{
+			ClientSends: "joeblow\r\nwrong\r\njoeblow\r\ns3cret\r\nwhoami\r\n",
+			Expected:    "login: Password: Login incorrect\r\nlogin: Password: " + defaultWelcomeMessage + defaultPrompt + "joeblow\r\n" + defaultPrompt + defaultExitMessage,
+		},
+		{
+			ClientSends: "janedoe\r\ns3cret\r\njoeblow\r\n\r\njoeblow\r\nS3CRET\r\nwhoami\r\n",
+			Expected:    "login: Password: Login incorrect\r\nlogin: Password: Login incorrect\r\nlogin: Password: Login incorrect\r\n",
+		},
+		{
+			ClientSends: "joeblow\r\n",
+			Expected:    "login: Password: ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := testLoginShellHandler(t)
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETAuthenticateEcho(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, testLoginShellHandler(t))
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	expect := func(expected string) {
+		t.Helper()
+
+		var received bytes.Buffer
+		p := make([]byte, 1)
+		for !strings.HasSuffix(received.String(), expected) {
+			client.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := client.Read(p)
+			received.Write(p[:n])
+			if nil != err {
+				t.Fatalf("Expected to receive %q, but actually got %q.", expected, received.String())
+			}
+		}
+	}
+
+	expect("login: ")
+	client.Write([]byte("joeblow\r\n"))
+
+	// Echoing is turned off (by the shell saying it will echo) while the password is typed...
+	expect("Password: " + string([]byte{telnet.IAC, telnet.WILL, telnet.OptEcho}))
+	client.Write([]byte{telnet.IAC, telnet.DO, telnet.OptEcho})
+	client.Write([]byte("s3cret\r\n"))
+
+	// ... and turned back on after.
+	expect("\r\n" + string([]byte{telnet.IAC, telnet.WONT, telnet.OptEcho}))
+	client.Write([]byte{telnet.IAC, telnet.DONT, telnet.OptEcho})
+
+	expect(defaultWelcomeMessage + defaultPrompt)
+	client.Write([]byte("whoami\r\n"))
+	expect("joeblow\r\n" + defaultPrompt)
+}
+
+func TestServeTELNETAuthenticateCloseReason(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	reasons := make(chan telnet.CloseReason, 1)
+	server := &telnet.Server{
+		Handler: testLoginShellHandler(t),
+		OnDisconnect: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+			reasons <- reason
+		},
+	}
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	client.Write([]byte("janedoe\r\nwrong\r\njanedoe\r\nwrong\r\njanedoe\r\nwrong\r\n"))
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	io.Copy(io.Discard, client)
+
+	select {
+	case reason := <-reasons:
+		if expected, actual := telnet.CloseAuthFailed, reason; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for OnDisconnect to be called.")
+	}
+}
+
+func TestServeTELNETAuthenticateLoginDelayCancelled(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	shellHandler := testLoginShellHandler(t)
+	shellHandler.LoginDelay = time.Hour
+
+	disconnected := make(chan struct{})
+	server := &telnet.Server{
+		Handler: shellHandler,
+		OnDisconnect: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+			close(disconnected)
+		},
+	}
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	client.Write([]byte("joeblow\r\nwrong\r\n"))
+	p := make([]byte, len("login: Password: Login incorrect\r\n"))
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(client, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (The shell is now waiting out the LoginDelay; which the client hanging up cuts short.)
+	client.Close()
+
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for the session to end.")
+	}
+}
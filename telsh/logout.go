@@ -0,0 +1,67 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+)
+
+// exitCommandNames returns the names of the built-in commands that end the session.
+func (telnetHandler *ShellHandler) exitCommandNames() []string {
+	names := []string{"quit", "logout"}
+	if name := telnetHandler.ExitCommandName; "" != name {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// isExit returns whether 'name' is (once expanded) one of the built-in commands that
+// end the session; and has not been overridden by registering a command with that name.
+func (telnetHandler *ShellHandler) isExit(name string) bool {
+
+	telnetHandler.muxtex.RLock()
+	_, isProducer := telnetHandler.producers[name]
+	telnetHandler.muxtex.RUnlock()
+
+	if isProducer {
+		return false
+	}
+
+	for _, exitName := range telnetHandler.exitCommandNames() {
+		if exitName == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logout ends the session the user asked to end: it writes the exit message, lets the
+// client know (if SendLogout) with IAC WILL LOGOUT, cancels the session's Context (so
+// anything a command started in the background stops), and then closes the connection.
+func (telnetHandler *ShellHandler) logout(logger telnet.Logger, ctx *internalContext, writer telnet.Writer) {
+
+	if _, err := oi.LongWriteString(writer, telnetHandler.ExitMessage); nil != err {
+		logger.Warnf("Problem long writing exit message: %v", err)
+	}
+
+	conn := ctx.conn
+
+	if nil != conn && telnetHandler.SendLogout {
+		support := telnet.OptionSupport{
+			Local:        true,
+			RequestLocal: true,
+		}
+		if err := conn.RegisterOption(telnet.OptLogout, telnet.SimpleOption(support)); nil != err {
+			logger.Warnf("Problem registering LOGOUT option: %v", err)
+		}
+	}
+
+	ctx.cancel()
+
+	if nil != conn {
+		if err := conn.CloseWithReason(telnet.CloseUserRequested, "user requested"); nil != err {
+			logger.Debugf("Problem closing connection: %v", err)
+		}
+	}
+}
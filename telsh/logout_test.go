@@ -0,0 +1,144 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestServeTELNETExit(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "exit\r\necho nope\r\n",
+			Expected:    "§ exit: command not found\r\n§ nope\r\n§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "quit\r\necho nope\r\n",
+			Expected:    "§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "logout\r\necho nope\r\n",
+			Expected:    "§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "\x04echo nope\r\n",
+			Expected:    "§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "echo yes\r\n\x04echo nope\r\n",
+			Expected:    "§ yes\r\n§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "bye\r\necho nope\r\n",
+			Expected:    "§ \r\nGoodbye!\r\n",
+		},
+		{
+			ClientSends: "leave\r\necho nope\r\n",
+			Expected:    "§ Leaving?\r\n§ nope\r\n§ \r\nGoodbye!\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+		shellHandler.WelcomeMessage = ""
+		shellHandler.ExitCommandName = "leave"
+		shellHandler.MustRegisterHandlerFunc("echo", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			io.WriteString(stdout, strings.Join(args, " ")+"\r\n")
+			return nil
+		})
+		shellHandler.MustRegisterHandlerFunc("leave", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			io.WriteString(stdout, "Leaving?\r\n")
+			return nil
+		})
+		shellHandler.MustAlias("bye", "logout")
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETLogout(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	cancelled := make(chan struct{})
+
+	shellHandler := NewShellHandler()
+	shellHandler.SendLogout = true
+	shellHandler.MustRegister("watch", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		shellCtx := ctx.(Context)
+		go func() {
+			<-shellCtx.Done()
+			close(cancelled)
+		}()
+
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			return nil
+		})
+	}))
+
+	type disconnect struct {
+		Reason telnet.CloseReason
+		Msg    string
+	}
+	disconnects := make(chan disconnect, 1)
+
+	server := &telnet.Server{
+		Handler: shellHandler,
+		OnDisconnect: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+			disconnects <- disconnect{Reason: reason, Msg: msg}
+		},
+	}
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	client.Write([]byte("watch\r\nlogout\r\n"))
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received, _ := io.ReadAll(client)
+
+	if expected, actual := defaultExitMessage+string([]byte{telnet.IAC, telnet.WILL, telnet.OptLogout}), string(received); !strings.HasSuffix(actual, expected) {
+		t.Errorf("Expected to receive (at the end) %q, but actually got %q.", expected, actual)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for the session's Context to be cancelled.")
+	}
+
+	select {
+	case actual := <-disconnects:
+		if expected := (disconnect{Reason: telnet.CloseUserRequested, Msg: "user requested"}); expected != actual {
+			t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for OnDisconnect to be called.")
+	}
+}
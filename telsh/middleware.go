@@ -0,0 +1,63 @@
+package telsh
+
+// A Middleware wraps a Producer (i.e., a shell "command") with another Producer.
+//
+// Middleware is registered with a ShellHandler (using Use), and then wraps every command;
+// so that things such as logging, authorization checks, and timing, can be added to all
+// the commands, without changing each of them.
+//
+// As the wrapping Producer's Produce method is given the session's Context, the command
+// name, and the (parsed) arguments, a Middleware can:
+//
+// • deny running the command, by returning its own Handler (rather than calling next's Produce method),
+//
+// • decorate the command's stdin, stdout, and stderr, by wrapping the Handler next's Produce method returns,
+//
+// • record metrics, by wrapping the Handler's Run method.
+//
+// For example:
+//
+//	shellHandler.Use(func(next telsh.Producer) telsh.Producer {
+//		return telsh.ProducerFunc(func(ctx telnet.Context, name string, args ...string) telsh.Handler {
+//			if "reload" == name && "admin" != ctx.(telsh.Context).User() {
+//				return telsh.PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+//					oi.LongWriteString(stderr, "reload: permission denied\r\n")
+//					return nil
+//				})
+//			}
+//
+//			return next.Produce(ctx, name, args...)
+//		})
+//	})
+//
+// If a Middleware panics, then (like if a command panics) the session is ended, and the
+// panic is logged (by the telnet.Server); which closes the connection, with
+// telnet.CloseHandlerPanic as the reason given to its OnDisconnect.
+type Middleware func(next Producer) Producer
+
+// Use adds Middleware, which will wrap every command.
+//
+// Middleware wraps commands in the order it was added; i.e., the first Middleware added
+// is the outermost one, and so is the first to be called.
+func (telnetHandler *ShellHandler) Use(middleware ...Middleware) *ShellHandler {
+
+	telnetHandler.muxtex.Lock()
+	telnetHandler.middleware = append(telnetHandler.middleware, middleware...)
+	telnetHandler.muxtex.Unlock()
+
+	return telnetHandler
+}
+
+// wrap wraps 'producer' with all the Middleware.
+func (telnetHandler *ShellHandler) wrap(producer Producer) Producer {
+
+	telnetHandler.muxtex.RLock()
+	middleware := telnetHandler.middleware
+	telnetHandler.muxtex.RUnlock()
+
+	for i := len(middleware) - 1; 0 <= i; i-- {
+		producer = middleware[i](producer)
+	}
+
+	return producer
+}
@@ -0,0 +1,216 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+// testLoggingMiddleware is a sample Middleware, that logs every command that is run,
+// along with how long it took.
+func testLoggingMiddleware(log io.Writer) Middleware {
+	return func(next Producer) Producer {
+		return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			handler := next.Produce(ctx, name, args...)
+			if nil == handler {
+				return nil
+			}
+
+			return testLoggingHandler{Handler: handler, log: log, name: name, args: args}
+		})
+	}
+}
+
+type testLoggingHandler struct {
+	Handler
+
+	log  io.Writer
+	name string
+	args []string
+}
+
+func (handler testLoggingHandler) Run() error {
+	begin := time.Now()
+	err := handler.Handler.Run()
+	fmt.Fprintf(handler.log, "ran %s %q; err=%v; took %v\n", handler.name, handler.args, err, time.Since(begin))
+
+	return err
+}
+
+// testUpperHandler decorates the stdout of a Handler, by upper casing it.
+type testUpperHandler struct {
+	Handler
+}
+
+func (handler testUpperHandler) StdoutPipe() (io.ReadCloser, error) {
+	stdout, err := handler.Handler.StdoutPipe()
+	if nil != err {
+		return stdout, err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		p := make([]byte, 256)
+		for {
+			n, err := stdout.Read(p)
+			pipeWriter.Write(bytes.ToUpper(p[:n]))
+			if nil != err {
+				pipeWriter.Close()
+				return
+			}
+		}
+	}()
+
+	return pipeReader, nil
+}
+
+func TestShellHandlerUse(t *testing.T) {
+
+	var log bytes.Buffer
+	var order []string
+
+	shellHandler := NewShellHandler()
+	shellHandler.MustRegisterHandlerFunc("echo", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		oi.LongWriteString(stdout, strings.Join(args, " ")+"\r\n")
+		return nil
+	})
+	shellHandler.MustRegisterHandlerFunc("reload", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		t.Errorf("Did not expect reload to be run, but it was.")
+		return nil
+	})
+
+	shellHandler.Use(testLoggingMiddleware(&log))
+	shellHandler.Use(
+		func(next Producer) Producer {
+			return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+				order = append(order, "first")
+				return next.Produce(ctx, name, args...)
+			})
+		},
+		func(next Producer) Producer {
+			return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+				order = append(order, "second")
+
+				if _, ok := ctx.(Context); !ok {
+					t.Errorf("Expected the context to be a telsh.Context, but actually got %T.", ctx)
+				}
+
+				// Deny.
+				if "reload" == name {
+					return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+						oi.LongWriteString(stderr, "reload: permission denied\r\n")
+						return nil
+					})
+				}
+
+				// Decorate.
+				if 0 < len(args) && "--shout" == args[0] {
+					return testUpperHandler{Handler: next.Produce(ctx, name, args[1:]...)}
+				}
+
+				return next.Produce(ctx, name, args...)
+			})
+		},
+	)
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("echo hello\r\nreload\r\necho --shout hello\r\n"))
+
+	expected := shellHandler.WelcomeMessage +
+		shellHandler.Prompt + "hello\r\n" +
+		shellHandler.Prompt + "reload: permission denied\r\n" +
+		shellHandler.Prompt + "HELLO\r\n" +
+		shellHandler.Prompt + shellHandler.ExitMessage
+	if actual := buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := []string{"ran echo [\"hello\"]; err=<nil>; took ", "ran reload []; err=<nil>; took ", "ran echo [\"--shout\" \"hello\"]; err=<nil>; took "}, strings.Split(strings.TrimSuffix(log.String(), "\n"), "\n"); len(expected) != len(actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	} else {
+		for i := range expected {
+			if !strings.HasPrefix(actual[i], expected[i]) {
+				t.Errorf("Expected %q, but actually got %q.", expected[i], actual[i])
+			}
+		}
+	}
+
+	if expected, actual := "first second first second first second", strings.Join(order, " "); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestShellHandlerUsePanic(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	shellHandler := NewShellHandler()
+	shellHandler.MustRegisterHandlerFunc("boom", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		t.Errorf("Did not expect boom to be run, but it was.")
+		return nil
+	})
+	shellHandler.Use(func(next Producer) Producer {
+		return ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			if "boom" == name {
+				panic("boom")
+			}
+			return next.Produce(ctx, name, args...)
+		})
+	})
+
+	conns := make(chan *telnet.Conn, 1)
+	disconnects := make(chan telnet.CloseReason, 1)
+	server := &telnet.Server{
+		Handler: shellHandler,
+		OnConnect: func(conn *telnet.Conn) {
+			conns <- conn
+		},
+		OnDisconnect: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+			disconnects <- reason
+		},
+	}
+	go server.Serve(listener)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	client.Write([]byte("boom\r\n"))
+
+	// (The session is ended; so the client sees the connection closed.)
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(client); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	select {
+	case actual := <-disconnects:
+		if expected := telnet.CloseHandlerPanic; expected != actual {
+			t.Errorf("Expected %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for OnDisconnect to be called.")
+	}
+
+	conn := <-conns
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for Done to be closed.")
+	}
+}
@@ -0,0 +1,317 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPagerHeight = 24
+	pagerPrompt        = "--More--"
+	pagerErasePrompt   = "\r\x1b[K"
+
+	terminalUsage = "terminal length [<lines>] | terminal color [on|off|auto]"
+)
+
+const (
+	colorAuto = iota
+	colorOn
+	colorOff
+)
+
+// internalTerminalSettings are the (per session) settings for the client's terminal; which
+// start out as the ShellHandler's, and can be changed with the built-in terminal command.
+type internalTerminalSettings struct {
+	pager       bool
+	pagerLength int // Zero means the height of the client's terminal (from NAWS).
+	color       int // Whether to use ANSI escape sequences; see Style.
+}
+
+// pagerHeight returns the height of the page; or zero if the output should not be paged.
+//
+// If the pager is enabled but no length was set, then the height of the client's terminal
+// (from NAWS) is used. If the client never negotiated NAWS, then the output is not paged.
+func (ctx *internalContext) pagerHeight() int {
+	settings := ctx.terminal
+	if nil == settings || !settings.pager {
+		return 0
+	}
+
+	if 0 < settings.pagerLength {
+		return settings.pagerLength
+	}
+
+	if !ctx.windowSize {
+		return 0
+	}
+	if _, negotiated := ctx.conn.OptionEnabled(telnet.OptNAWS); !negotiated {
+		return 0
+	}
+
+	_, height := ctx.WindowSize()
+	if height <= 0 {
+		height = defaultPagerHeight
+	}
+
+	return height
+}
+
+// page is like connect, except that it pauses every height-1 lines with a --More--
+// prompt, and waits for the user to press:
+//
+//	SPACE  show the next page
+//	ENTER  show the next line
+//	q      stop; which cancels the command (with 'cancel') and closes 'pipe'
+//
+// The keys are read from 'keys'. (Which is safe, as the shell does not read from the
+// client while a command is running.)
+//
+// Lines that are wider than the client's terminal (not counting any ANSI escape sequences)
+// count as however many lines they wrap onto.
+func page(ctx telnet.Context, writer io.Writer, pipe io.ReadCloser, keys io.Reader, editor *LineEditor, height int, cancel context.CancelFunc) <-chan struct{} {
+
+	logger := ctx.Logger()
+	if nil == logger {
+		logger = internalDiscardLogger{}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		limit := height - 1
+		var lines int
+
+		counter := internalRowCounter{width: editor.width()}
+
+		var buffer [1]byte
+		p := buffer[:]
+
+		for {
+			n, err := pipe.Read(p)
+			if n <= 0 && nil == err {
+				continue
+			} else if n <= 0 && nil != err {
+				break
+			}
+
+			if !counter.feed(p[0]) {
+				oi.LongWrite(writer, p)
+				continue
+			}
+
+			lines++
+			if limit <= lines {
+				oi.LongWriteString(writer, pagerPrompt)
+				key := readPagerKey(keys, editor)
+				oi.LongWriteString(writer, pagerErasePrompt)
+
+				switch key {
+				case ' ':
+					limit = height - 1
+				case 'q', 'Q', 0:
+					logger.Debugf("Pager stopped the command.")
+					cancel()
+					pipe.Close()
+					return
+				default:
+					limit = 1
+				}
+				lines = 0
+			}
+
+			oi.LongWrite(writer, p)
+		}
+	}()
+
+	return done
+}
+
+// internalRowCounter keeps track of which row (of the client's terminal) output is on.
+type internalRowCounter struct {
+	width    int // Zero means unknown; i.e., lines are not wrapped.
+	column   int
+	newline  bool
+	stripper telnet.WriteFilter // (A telnet.StripANSI; so that escape sequences split across feeds are skipped.)
+}
+
+// feed returns whether 'b' is the first byte on a new row; which is the case for the byte
+// after a "\n", and for a character that does not fit on the (current) row.
+//
+// ANSI escape sequences do not take up any columns.
+func (counter *internalRowCounter) feed(b byte) bool {
+	startsRow := counter.newline
+	counter.newline = false
+	if startsRow {
+		counter.column = 0
+	}
+
+	if nil == counter.stripper {
+		counter.stripper = telnet.StripANSI()
+	}
+	if 0 == len(counter.stripper.Write([]byte{b})) {
+		return startsRow
+	}
+
+	switch {
+	case '\n' == b:
+		counter.newline = true
+	case '\r' == b:
+		counter.column = 0
+	case asciiBS == b:
+		if 0 < counter.column {
+			counter.column--
+		}
+	case b < 0x20, asciiDEL == b, 0x80 <= b && b < 0xC0: // Control characters, and UTF-8 continuation bytes.
+	default:
+		if 0 < counter.width && counter.width <= counter.column {
+			startsRow = true
+			counter.column = 0
+		}
+		counter.column++
+	}
+
+	return startsRow
+}
+
+// readPagerKey reads the key that the user pressed at the --More-- prompt; which is ' ',
+// '\r', or 'q' (any other key is ignored). If the client closed the connection, then zero
+// is returned.
+//
+// If the client is not in character mode, then the user has to press ENTER after the key,
+// so a whole line is read; and its first character is the key (or '\r' for an empty line).
+func readPagerKey(reader io.Reader, editor *LineEditor) byte {
+
+	var buffer [1]byte
+	p := buffer[:]
+
+	var key byte
+	for {
+		n, err := reader.Read(p)
+		if n <= 0 && nil == err {
+			continue
+		} else if n <= 0 && nil != err {
+			return 0
+		}
+
+		b := p[0]
+
+		lastWasCR := editor.lastWasCR
+		editor.lastWasCR = asciiCR == b
+
+		switch b {
+		case asciiLF, asciiNUL:
+			if lastWasCR {
+				continue
+			}
+			b = asciiCR
+		}
+
+		if !editor.echo() {
+			if asciiCR != b {
+				if 0 == key {
+					key = b
+				}
+				continue
+			}
+			if 0 == key {
+				key = asciiCR
+			}
+			b = key
+		}
+
+		switch b {
+		case ' ', asciiCR, 'q', 'Q':
+			return b
+		}
+		key = 0
+	}
+}
+
+type internalTerminalProducer struct{}
+
+func (internalTerminalProducer) Produce(ctx telnet.Context, name string, args ...string) Handler {
+	return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		shellCtx, ok := ctx.(*internalContext)
+		if !ok || nil == shellCtx.terminal {
+			oi.LongWriteString(stderr, name+": not supported\r\n")
+			return nil
+		}
+		settings := shellCtx.terminal
+
+		if len(args) <= 0 || 2 < len(args) {
+			oi.LongWriteString(stderr, "Usage: "+terminalUsage+"\r\n")
+			return nil
+		}
+
+		switch args[0] {
+		case "length":
+			if 1 == len(args) {
+				oi.LongWriteString(stdout, strconv.Itoa(shellCtx.pagerHeight())+"\r\n")
+				return nil
+			}
+
+			length, err := strconv.Atoi(args[1])
+			if nil != err || length < 0 {
+				oi.LongWriteString(stderr, name+": invalid length: "+args[1]+"\r\n")
+				return nil
+			}
+
+			settings.pager = 0 != length
+			settings.pagerLength = length
+		case "color":
+			if 1 == len(args) {
+				enabled := "off"
+				if shellCtx.Style().Enabled() {
+					enabled = "on"
+				}
+				if colorAuto == settings.color {
+					enabled = "auto (" + enabled + ")"
+				}
+				oi.LongWriteString(stdout, enabled+"\r\n")
+				return nil
+			}
+
+			switch args[1] {
+			case "auto":
+				settings.color = colorAuto
+			case "on":
+				settings.color = colorOn
+			case "off":
+				settings.color = colorOff
+			default:
+				oi.LongWriteString(stderr, name+": invalid color: "+args[1]+"\r\n")
+			}
+		default:
+			oi.LongWriteString(stderr, "Usage: "+terminalUsage+"\r\n")
+		}
+
+		return nil
+	}, args...)
+}
+
+// Complete completes the arguments of the terminal command.
+func (internalTerminalProducer) Complete(args []string, prefix string) []string {
+	var words []string
+	switch {
+	case 0 == len(args):
+		words = []string{"color", "length"}
+	case 1 == len(args) && "color" == args[0]:
+		words = []string{"auto", "off", "on"}
+	}
+
+	var completions []string
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			completions = append(completions, word)
+		}
+	}
+
+	return completions
+}
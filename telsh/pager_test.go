@@ -0,0 +1,252 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func testPagerShellHandler(t *testing.T, cancelled *error) *ShellHandler {
+	shellHandler := NewShellHandler()
+	shellHandler.WelcomeMessage = ""
+	shellHandler.ExitMessage = ""
+
+	shellHandler.MustRegisterHandlerFunc("seq", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		n, _ := strconv.Atoi(args[0])
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(stdout, "%d\r\n", i)
+		}
+		return nil
+	})
+
+	shellHandler.MustRegister("yes", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		shellCtx := ctx.(Context)
+
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			for nil == shellCtx.Err() {
+				if _, err := io.WriteString(stdout, "y\r\n"); nil != err {
+					break
+				}
+			}
+			*cancelled = shellCtx.Err()
+			return nil
+		})
+	}))
+
+	return shellHandler
+}
+
+func TestServeTELNETPager(t *testing.T) {
+
+	tests := []struct {
+		Pager       bool
+		PagerLength int
+		ClientSends string
+		Expected    string
+	}{
+		{
+			// No NAWS, and no length; so not paged.
+			Pager:       true,
+			ClientSends: "seq 5\r\n",
+			Expected:    "§ 1\r\n2\r\n3\r\n4\r\n5\r\n§ ",
+		},
+		{
+			Pager:       false,
+			PagerLength: 3,
+			ClientSends: "seq 5\r\n",
+			Expected:    "§ 1\r\n2\r\n3\r\n4\r\n5\r\n§ ",
+		},
+		{
+			Pager:       true,
+			PagerLength: 3,
+			ClientSends: "seq 5\r\n \r\n \r\n",
+			Expected:    "§ 1\r\n2\r\n--More--\r\x1b[K3\r\n4\r\n--More--\r\x1b[K5\r\n§ ",
+		},
+		{
+			Pager:       true,
+			PagerLength: 3,
+			ClientSends: "seq 5\r\n\r\n\r\n\r\n",
+			Expected:    "§ 1\r\n2\r\n--More--\r\x1b[K3\r\n--More--\r\x1b[K4\r\n--More--\r\x1b[K5\r\n§ ",
+		},
+		{
+			Pager:       true,
+			PagerLength: 3,
+			ClientSends: "seq 2\r\nseq 1\r\n",
+			Expected:    "§ 1\r\n2\r\n§ 1\r\n§ ",
+		},
+		{
+			Pager:       true,
+			PagerLength: 3,
+			ClientSends: "seq 5\r\nx\r\nq\r\nseq 1\r\n",
+			Expected:    "§ 1\r\n2\r\n--More--\r\x1b[K§ 1\r\n§ ",
+		},
+		{
+			ClientSends: "terminal length 3\r\nterminal length\r\nseq 5\r\nq\r\nterminal length 0\r\nseq 3\r\n",
+			Expected:    "§ § 3\r\n§ 1\r\n2\r\n--More--\r\x1b[K§ § 1\r\n2\r\n3\r\n§ ",
+		},
+		{
+			ClientSends: "terminal length x\r\nterminal\r\n",
+			Expected:    "§ terminal: invalid length: x\r\n§ Usage: terminal length [<lines>] | terminal color [on|off|auto]\r\n§ ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		var cancelled error
+		shellHandler := testPagerShellHandler(t, &cancelled)
+		shellHandler.Pager = test.Pager
+		shellHandler.PagerLength = test.PagerLength
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETPagerCancel(t *testing.T) {
+
+	var cancelled error
+	shellHandler := testPagerShellHandler(t, &cancelled)
+	shellHandler.Pager = true
+	shellHandler.PagerLength = 4
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("yes\r\n\r\nq\r\n"))
+
+	if expected, actual := "§ y\r\ny\r\ny\r\n--More--\r\x1b[Ky\r\n--More--\r\x1b[K§ ", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := context.Canceled, cancelled; expected != actual {
+		t.Errorf("Expected the command's Context to have been cancelled, with %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestServeTELNETPagerWindowSize(t *testing.T) {
+
+	var cancelled error
+	shellHandler := testPagerShellHandler(t, &cancelled)
+	shellHandler.CharacterMode = true
+	shellHandler.Pager = true
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, shellHandler)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	expect := func(expected string) {
+		t.Helper()
+
+		var received bytes.Buffer
+		p := make([]byte, 1)
+		for !strings.HasSuffix(received.String(), expected) {
+			client.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := client.Read(p)
+			received.Write(p[:n])
+			if nil != err {
+				t.Fatalf("Expected to receive %q, but actually got %q.", expected, received.String())
+			}
+		}
+	}
+
+	// Say the client's terminal is 3 lines high; so pages are 2 lines.
+	request := []byte{
+		telnet.IAC, telnet.WILL, telnet.OptNAWS,
+		telnet.IAC, telnet.SB, telnet.OptNAWS, 0, 80, 0, 3, telnet.IAC, telnet.SE,
+		telnet.IAC, telnet.DO, telnet.OptSuppressGoAhead,
+		telnet.IAC, telnet.DO, telnet.OptEcho,
+	}
+	client.Write(request)
+	client.Write([]byte("seq 4\r\x00"))
+
+	expect("seq 4\r\n1\r\n2\r\n--More--")
+	client.Write([]byte(" "))
+	expect("\r\x1b[K3\r\n4\r\n" + shellHandler.Prompt)
+}
+
+func TestRowCounter(t *testing.T) {
+
+	tests := []struct {
+		Width    int
+		Output   string
+		Expected int
+	}{
+		{
+			Width:    0,
+			Output:   "1\r\n2\r\n3",
+			Expected: 2,
+		},
+		{
+			Width:    0,
+			Output:   "abcdefghij\r\nk",
+			Expected: 1,
+		},
+		{
+			Width:    4,
+			Output:   "abcdefghij\r\nk",
+			Expected: 3,
+		},
+		{
+			// Exactly fills the row; so the "\r\n" does not start another.
+			Width:    4,
+			Output:   "abcd\r\nk",
+			Expected: 1,
+		},
+		{
+			// Escape sequences take up no columns.
+			Width:    4,
+			Output:   "\x1b[31mabcd\x1b[0m\r\nk",
+			Expected: 1,
+		},
+		{
+			Width:    4,
+			Output:   "\x1b(Babcd\x1bP1$r0m\x1b\\\r\nk",
+			Expected: 1,
+		},
+		{
+			Width:    4,
+			Output:   "caf\xc3\xa9\r\nk",
+			Expected: 1,
+		},
+	}
+
+	for testNumber, test := range tests {
+		counter := internalRowCounter{width: test.Width}
+
+		var actual int
+		for _, b := range []byte(test.Output) {
+			if counter.feed(b) {
+				actual++
+			}
+		}
+
+		if expected := test.Expected; expected != actual {
+			t.Errorf("For test #%d, expected %d, but actually got %d; for output: %q", testNumber, expected, actual, test.Output)
+			continue
+		}
+	}
+}
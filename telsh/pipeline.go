@@ -0,0 +1,359 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// runPipeline runs the commands of a pipeline (which might be just the one command); with
+// the stdout of each command connected to the stdin of the next, and the stdout of the last
+// command (and the stderr of all of them) going to the client.
+//
+// All the commands run at the same time, and share the one Context; which is cancelled if
+// they are stopped, or once the last command is done. They are stopped if any of them fails,
+// if they time out (see ShellHandler.CommandTimeout), if the user interrupts them (or stops
+// them from the pager), or if the session ends. When the Context is cancelled, the pipes
+// between the commands are closed; so that any command still reading (or writing) them stops
+// too. (Their stderr is still written to the client, until they are done.)
+//
+// If the commands are not done within the ShellHandler's CommandGracePeriod of being stopped,
+// then they are abandoned; i.e., runPipeline returns without waiting for them, and anything
+// more they write is thrown away.
+//
+// Only the first error (from any of the commands) is reported.
+func (telnetHandler *ShellHandler) runPipeline(logger telnet.Logger, shellCtx *internalContext, writer telnet.Writer, input *internalInput, editor *LineEditor, stages [][]string) {
+
+	// The commands time out together; i.e., with whichever of their timeouts is the shortest.
+	// (Which is the command that is said to have timed out.)
+	var timeout time.Duration
+	var timeoutName string
+	for _, fields := range stages {
+		if t := telnetHandler.timeout(fields[0]); 0 < t && (0 == timeout || t < timeout) {
+			timeout, timeoutName = t, fields[0]
+		}
+	}
+
+	// stopCtx is cancelled when the commands are stopped; and commandCtx (which is what the
+	// commands get) is, in addition to that, cancelled once the last command is done.
+	stopCtx := shellCtx.command(timeout)
+	defer stopCtx.cancel()
+	commandCtx := stopCtx.command(0)
+	defer commandCtx.cancel()
+
+	input.interruptWith(func() {
+		logger.Debugf("Interrupted.")
+		stopCtx.cancel()
+	}, editor.echo())
+	defer input.interruptWith(nil, false)
+
+	// None of the commands are produced, unless the user is allowed to run all of them.
+	producers := make([]Producer, len(stages))
+	for i, fields := range stages {
+		name := fields[0]
+
+		if 1 < len(stages) && telnetHandler.isExit(name) {
+			oi.LongWriteString(writer, name+": cannot be used in a pipeline\r\n")
+			return
+		}
+
+		producer := telnetHandler.producer(name)
+		if nil == producer {
+			oi.LongWriteString(writer, name+": command not found\r\n")
+			return
+		}
+
+		if err := telnetHandler.authorize(commandCtx, name); nil != err {
+			logger.Debugf("Not authorized to run %q: %v", name, err)
+			oi.LongWriteString(writer, name+": "+err.Error()+"\r\n")
+			return
+		}
+
+		producers[i] = producer
+	}
+
+	handlers := make([]Handler, len(stages))
+	for i, fields := range stages {
+		name := fields[0]
+
+		handler := telnetHandler.wrap(producers[i]).Produce(commandCtx, name, fields[1:]...)
+		if nil == handler {
+			//@TODO: Need to use a different error message.
+			oi.LongWriteString(writer, name+": command not found\r\n")
+			return
+		}
+
+		handlers[i] = handler
+	}
+
+	last := len(handlers) - 1
+
+	stdins := make([]io.WriteCloser, len(handlers))
+	for i, handler := range handlers {
+		if stdinPipe, err := handler.StdinPipe(); nil == err {
+			stdins[i] = stdinPipe
+		}
+	}
+
+	// The first command does not have any input.
+	if nil != stdins[0] {
+		stdins[0].Close()
+	}
+
+	// Everything the commands write to the client goes through output; so that it is written
+	// with the render lock (see LineEditor.AsyncWriter) held, and so that, if the commands are
+	// abandoned, anything more they write can be thrown away.
+	locked := editor.output()
+	output := &internalDetachableWriter{writer: locked}
+
+	var connected []<-chan struct{}
+	var closers []io.Closer
+	var outputs []io.Closer
+	stderrs := make([]io.ReadCloser, len(handlers))
+
+	for i, handler := range handlers {
+
+		if stderrPipe, err := handler.StderrPipe(); nil != err {
+			//@TODO:
+		} else if nil == stderrPipe {
+			//@TODO:
+		} else {
+			stderrs[i] = stderrPipe
+			outputs = append(outputs, stderrPipe)
+			connected = append(connected, connect(shellCtx, output, stderrPipe))
+		}
+
+		stdoutPipe, err := handler.StdoutPipe()
+		if nil != err {
+			stdoutPipe = nil
+		}
+
+		switch {
+		case i < last:
+			next := stdins[i+1]
+			if nil != next {
+				closers = append(closers, next)
+			}
+			if nil == stdoutPipe {
+				if nil != next {
+					next.Close()
+				}
+				continue
+			}
+			closers = append(closers, stdoutPipe)
+			connected = append(connected, pipe(next, stdoutPipe))
+		case nil == stdoutPipe:
+			//@TODO:
+		default:
+			outputs = append(outputs, stdoutPipe)
+			if height := commandCtx.pagerHeight(); 1 < height {
+				connected = append(connected, page(shellCtx, output, stdoutPipe, input.until(stopCtx), editor, height, stopCtx.cancel))
+			} else {
+				connected = append(connected, connect(shellCtx, output, stdoutPipe))
+			}
+		}
+	}
+
+	go func() {
+		<-commandCtx.Done()
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+
+	var once sync.Once
+	var failure string
+	fail := func(name string, err error) {
+		if nil == err || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+		once.Do(func() {
+			logger.Debugf("Command %q failed: %v", name, err)
+			failure = name + ": " + err.Error() + "\r\n"
+			stopCtx.cancel()
+		})
+	}
+
+	var mutex sync.Mutex
+	running := make([]bool, len(handlers))
+
+	var panicked interface{}
+	var waitGroup sync.WaitGroup
+	for i := range handlers {
+		running[i] = true
+		waitGroup.Add(1)
+		go func(i int, name string, handler Handler, stderr io.Closer) {
+			defer waitGroup.Done()
+			defer func() {
+				mutex.Lock()
+				running[i] = false
+				mutex.Unlock()
+			}()
+			defer func() {
+				if r := recover(); nil != r {
+					// As the command did not get to close its stderr, nothing else will.
+					if nil != stderr {
+						stderr.Close()
+					}
+					once.Do(func() {
+						panicked = r
+						stopCtx.cancel()
+					})
+				}
+			}()
+
+			fail(name, handler.Run())
+
+			// Nothing more can come out of the pipeline, once the last command is done.
+			if last == i {
+				commandCtx.cancel()
+			}
+		}(i, stages[i][0], handlers[i], stderrs[i])
+	}
+
+	handlersDone := make(chan struct{})
+	go func() {
+		defer close(handlersDone)
+		waitGroup.Wait()
+	}()
+
+	// Wait for all the output of the commands to be written, so that it comes before
+	// the prompt.
+	outputsDone := make(chan struct{})
+	go func() {
+		defer close(outputsDone)
+		for _, done := range connected {
+			<-done
+		}
+	}()
+
+	grace := telnetHandler.CommandGracePeriod
+	if grace <= 0 {
+		grace = defaultCommandGracePeriod
+	}
+
+	// The commands get the grace period from when they are cancelled. But, once they are
+	// done, what they wrote is waited for for as long as it takes (as the user might be
+	// paging through it); unless they were stopped.
+	var timer *time.Timer
+	var expired <-chan time.Time
+	for nil != handlersDone || nil != outputsDone {
+		var cancelled <-chan struct{}
+		switch {
+		case nil != timer:
+		case nil != handlersDone:
+			cancelled = commandCtx.Done()
+		default:
+			cancelled = stopCtx.Done()
+		}
+
+		select {
+		case <-handlersDone:
+			handlersDone = nil
+			if nil != timer && nil == stopCtx.Err() {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+		case <-outputsDone:
+			outputsDone = nil
+		case <-cancelled:
+			timer = time.NewTimer(grace)
+			expired = timer.C
+		case <-expired:
+			output.detach()
+			for _, closer := range outputs {
+				closer.Close()
+			}
+
+			name := stages[last][0]
+			mutex.Lock()
+			for i, isRunning := range running {
+				if isRunning {
+					name = stages[i][0]
+					break
+				}
+			}
+			mutex.Unlock()
+
+			logger.Warnf("Abandoned command %q, as it did not stop within %v.", name, grace)
+			oi.LongWriteString(locked, name+": command abandoned\r\n")
+			return
+		}
+	}
+	if nil != timer {
+		timer.Stop()
+	}
+
+	if nil != panicked {
+		panic(panicked)
+	}
+
+	switch {
+	case "" != failure:
+		oi.LongWriteString(locked, failure)
+	case errors.Is(stopCtx.Err(), context.DeadlineExceeded):
+		logger.Debugf("Command %q timed out, after %v.", timeoutName, timeout)
+		oi.LongWriteString(locked, timeoutName+": timed out\r\n")
+	}
+}
+
+// internalDetachableWriter writes to 'writer'; until it is detached, after which anything
+// written to it is thrown away.
+type internalDetachableWriter struct {
+	mutex    sync.Mutex
+	writer   io.Writer
+	detached bool
+}
+
+func (writer *internalDetachableWriter) Write(p []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.detached {
+		return len(p), nil
+	}
+
+	return writer.writer.Write(p)
+}
+
+func (writer *internalDetachableWriter) detach() {
+	writer.mutex.Lock()
+	writer.detached = true
+	writer.mutex.Unlock()
+}
+
+// pipe copies (in the background) everything read from 'reader' to 'writer', and then closes
+// 'writer'; so that the command whose stdin 'writer' is sees the end of its input.
+//
+// If 'writer' is nil, then what is read is thrown away.
+//
+// If 'writer' stops taking what is written to it (because its command is done), then
+// 'reader' is closed; so that the command writing to it stops too.
+func pipe(writer io.WriteCloser, reader io.ReadCloser) <-chan struct{} {
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		var w io.Writer = writer
+		if nil == writer {
+			w = io.Discard
+		}
+
+		if _, err := io.Copy(w, reader); nil != err {
+			reader.Close()
+		}
+
+		if nil != writer {
+			writer.Close()
+		}
+	}()
+
+	return done
+}
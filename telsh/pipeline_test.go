@@ -0,0 +1,354 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func testPipelineShellHandler() *ShellHandler {
+	shellHandler := NewShellHandler()
+	shellHandler.WelcomeMessage = ""
+	shellHandler.ExitMessage = ""
+
+	shellHandler.MustRegisterHandlerFunc("sessions", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		io.WriteString(stdout, "1 admin  \x1b[32mactive\x1b[0m\r\n")
+		io.WriteString(stdout, "2 joe    idle\r\n")
+		io.WriteString(stdout, "3 Admin  idle\r\n")
+		io.WriteString(stdout, "4 root   active\r\n")
+		return nil
+	})
+
+	// yes ignores its Context, and only stops once what it writes is not being read.
+	shellHandler.MustRegisterHandlerFunc("yes", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		for {
+			if _, err := io.WriteString(stdout, "y\r\n"); nil != err {
+				return err
+			}
+		}
+	})
+
+	shellHandler.MustRegisterHandlerFunc("fail", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		return errors.New("boom")
+	})
+
+	// wait waits until its Context is cancelled.
+	shellHandler.MustRegister("wait", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			<-ctx.(Context).Done()
+			io.WriteString(stderr, "wait: cancelled\r\n")
+			return ctx.(Context).Err()
+		})
+	}))
+
+	return shellHandler
+}
+
+func TestServeTELNETPipeline(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "sessions | grep admin\r\n",
+			Expected:    "§ 1 admin  \x1b[32mactive\x1b[0m\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | grep -i admin\r\n",
+			Expected:    "§ 1 admin  \x1b[32mactive\x1b[0m\r\n3 Admin  idle\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | grep 'active$' | count\r\n",
+			Expected:    "§ 2\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | grep -v \"admin|root\"\r\n",
+			Expected:    "§ 2 joe    idle\r\n3 Admin  idle\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | head -n 2 | count\r\nsessions | head -1\r\n",
+			Expected:    "§ 2\r\n§ 1 admin  \x1b[32mactive\x1b[0m\r\n§ ",
+		},
+		{
+			ClientSends: "yes | head -3\r\n",
+			Expected:    "§ y\r\ny\r\ny\r\n§ ",
+		},
+		{
+			ClientSends: "yes | grep y | head -n 2\r\n",
+			Expected:    "§ y\r\ny\r\n§ ",
+		},
+		{
+			ClientSends: "wait | head -n 0\r\n",
+			Expected:    "§ wait: cancelled\r\n§ ",
+		},
+		{
+			ClientSends: "grep y\r\ncount\r\n",
+			Expected:    "§ § 0\r\n§ ",
+		},
+		{
+			ClientSends: "fail | count\r\n",
+			Expected:    "§ 0\r\nfail: boom\r\n§ ",
+		},
+		{
+			ClientSends: "fail | fail | fail\r\n",
+			Expected:    "§ fail: boom\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | grep (\r\n",
+			Expected:    "§ grep: error parsing regexp: missing closing ): `(`\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | grep\r\n",
+			Expected:    "§ grep: usage: grep [-i] [-v] <pattern>\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | nope | count\r\n",
+			Expected:    "§ nope: command not found\r\n§ ",
+		},
+		{
+			ClientSends: "sessions |\r\n",
+			Expected:    "§ syntax error: missing command in pipeline\r\n§ ",
+		},
+		{
+			ClientSends: "sessions | exit\r\n",
+			Expected:    "§ exit: cannot be used in a pipeline\r\n§ ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := testPipelineShellHandler()
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETPipelinePanic(t *testing.T) {
+
+	shellHandler := testPipelineShellHandler()
+	shellHandler.MustRegisterHandlerFunc("panic", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		panic("apple")
+	})
+
+	defer func() {
+		if expected, actual := "apple", recover(); expected != actual {
+			t.Errorf("Expected the panic %q (from the command) to end the session, but actually got %v.", expected, actual)
+		}
+	}()
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("sessions | panic | count\r\n"))
+}
+
+func TestServeTELNETCommandTimeout(t *testing.T) {
+
+	tests := []struct {
+		CommandTimeout time.Duration
+		Timeout        time.Duration
+		ClientSends    string
+		Expected       string
+	}{
+		{
+			CommandTimeout: 10 * time.Millisecond,
+			ClientSends:    "wait\r\n",
+			Expected:       "§ wait: cancelled\r\nwait: timed out\r\n§ ",
+		},
+		{
+			CommandTimeout: 10 * time.Millisecond,
+			ClientSends:    "wait | head\r\n",
+			Expected:       "§ wait: cancelled\r\nwait: timed out\r\n§ ",
+		},
+		{
+			CommandTimeout: time.Minute,
+			Timeout:        10 * time.Millisecond,
+			ClientSends:    "head | nap\r\n",
+			Expected:       "§ nap: cancelled\r\nnap: timed out\r\n§ ",
+		},
+		{
+			Timeout:     10 * time.Millisecond,
+			ClientSends: "nap\r\n",
+			Expected:    "§ nap: cancelled\r\nnap: timed out\r\n§ ",
+		},
+		{
+			CommandTimeout: 10 * time.Millisecond,
+			Timeout:        -1,
+			ClientSends:    "nap\r\n",
+			Expected:       "§ nap: rested\r\n§ ",
+		},
+		{
+			CommandTimeout: time.Minute,
+			ClientSends:    "sessions | count\r\n",
+			Expected:       "§ 4\r\n§ ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := testPipelineShellHandler()
+		shellHandler.CommandTimeout = test.CommandTimeout
+
+		// nap waits (a little while) for its Context to be cancelled.
+		shellHandler.MustRegisterCommand("nap", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+				select {
+				case <-ctx.(Context).Done():
+					io.WriteString(stderr, "nap: cancelled\r\n")
+					return ctx.(Context).Err()
+				case <-time.After(50 * time.Millisecond):
+					io.WriteString(stdout, "nap: rested\r\n")
+					return nil
+				}
+			})
+		}), CommandInfo{Timeout: test.Timeout})
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETCommandAbandoned(t *testing.T) {
+
+	released := make(chan struct{})
+	defer close(released)
+
+	shellHandler := testPipelineShellHandler()
+	shellHandler.CommandTimeout = 10 * time.Millisecond
+	shellHandler.CommandGracePeriod = 10 * time.Millisecond
+
+	// hang ignores its Context; and, once released, writes more output.
+	shellHandler.MustRegisterHandlerFunc("hang", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		io.WriteString(stdout, "hanging\r\n")
+		<-released
+		io.WriteString(stdout, "too late\r\n")
+		return nil
+	})
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("hang | head\r\nsessions | count\r\n"))
+
+	if expected, actual := "§ hanging\r\nhang: command abandoned\r\n§ 4\r\n§ ", buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServeTELNETInterrupt(t *testing.T) {
+
+	tests := []struct {
+		CharacterMode     bool
+		InterruptCommands []byte
+		Request           []byte
+		Interrupt         []byte
+	}{
+		{
+			Interrupt: []byte{telnet.IAC, telnet.IP},
+		},
+		{
+			InterruptCommands: []byte{telnet.SUSP, telnet.ABORT},
+			Interrupt:         []byte{telnet.IAC, telnet.SUSP},
+		},
+		{
+			InterruptCommands: []byte{telnet.SUSP, telnet.ABORT},
+			Interrupt:         []byte{telnet.IAC, telnet.ABORT},
+		},
+		{
+			CharacterMode: true,
+			Request: []byte{
+				telnet.IAC, telnet.DO, telnet.OptSuppressGoAhead,
+				telnet.IAC, telnet.DO, telnet.OptEcho,
+			},
+			Interrupt: []byte{0x03},
+		},
+		{
+			CharacterMode: true,
+			Request: []byte{
+				telnet.IAC, telnet.DO, telnet.OptSuppressGoAhead,
+				telnet.IAC, telnet.DO, telnet.OptEcho,
+			},
+			Interrupt: []byte{telnet.IAC, telnet.IP},
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := testPipelineShellHandler()
+		shellHandler.CharacterMode = test.CharacterMode
+		shellHandler.InterruptCommands = test.InterruptCommands
+
+		// block is like wait, except that it first says that it is running.
+		shellHandler.MustRegister("block", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+			return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+				io.WriteString(stdout, "blocking\r\n")
+				<-ctx.(Context).Done()
+				io.WriteString(stdout, "block: cancelled\r\n")
+				return ctx.(Context).Err()
+			})
+		}))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		defer listener.Close()
+
+		go telnet.Serve(listener, shellHandler)
+
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		defer client.Close()
+
+		var received bytes.Buffer
+		expect := func(expected string) bool {
+			t.Helper()
+
+			p := make([]byte, 1)
+			for !strings.HasSuffix(received.String(), expected) {
+				client.SetReadDeadline(time.Now().Add(time.Second))
+				n, err := client.Read(p)
+				received.Write(p[:n])
+				if nil != err {
+					t.Errorf("For test #%d, expected to receive %q, but actually got %q.", testNumber, expected, received.String())
+					return false
+				}
+			}
+			return true
+		}
+
+		client.Write(test.Request)
+		client.Write([]byte("block\r\n"))
+		if !expect("blocking\r\n") {
+			continue
+		}
+
+		// Typed while the command is running; so read once it is done.
+		client.Write([]byte("sessions | count\r\n"))
+
+		client.Write(test.Interrupt)
+		expect("block: cancelled\r\n" + shellHandler.Prompt)
+		expect("4\r\n" + shellHandler.Prompt)
+	}
+}
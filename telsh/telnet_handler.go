@@ -5,9 +5,10 @@ import (
 	"github.com/wouteroostervld/go-telnet"
 
 	"bytes"
+	"context"
 	"io"
-	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -15,17 +16,175 @@ const (
 	defaultPrompt          = "§ "
 	defaultWelcomeMessage  = "\r\nWelcome!\r\n"
 	defaultExitMessage     = "\r\nGoodbye!\r\n"
+	defaultHistorySize     = 100
+
+	defaultCommandGracePeriod = 2 * time.Second
 )
 
 type ShellHandler struct {
 	muxtex       sync.RWMutex
 	producers    map[string]Producer
 	elseProducer Producer
-
+	completers   map[string]Completer
+	infos        map[string]CommandInfo
+	aliases      map[string]string
+	middleware   []Middleware
+
+	// ExitCommandName is the name of the built-in command that ends the session; in
+	// addition to "quit" and "logout", which always do. (Registering a command with one
+	// of these names overrides it.)
+	//
+	// Typing Ctrl-D at an empty prompt also ends the session.
+	//
+	// When the session is ended this way, the ExitMessage is written, and the connection
+	// is closed with the reason telnet.CloseUserRequested (see telnet.Server.OnDisconnect).
 	ExitCommandName string
 	Prompt          string
 	WelcomeMessage  string
 	ExitMessage     string
+
+	// SendLogout is whether to send IAC WILL LOGOUT (RFC 727) to the client, before
+	// closing the connection, when the user ends the session.
+	SendLogout bool
+
+	// PromptFunc, if not nil, is called each time the prompt is shown, and what it
+	// returns is used as the prompt (instead of Prompt). So the prompt can change from
+	// one command to the next. For example:
+	//
+	//	shellHandler.PromptFunc = func(ctx telsh.Context) string {
+	//		return currentDirectory(ctx) + "> "
+	//	}
+	PromptFunc func(ctx Context) string
+
+	// AbbreviationMatching is whether a command can be run by typing just the start
+	// of its name; so long as no other command's name starts the same way. For example,
+	// "sh" for "show".
+	//
+	// If more than one command's name starts that way, then they are listed.
+	AbbreviationMatching bool
+
+	// Authenticate, if not nil, makes users log in before they can run any commands.
+	//
+	// The shell asks for a username and password (with echoing turned off while the
+	// password is typed), and then calls Authenticate with them. 'tries' is which try
+	// this is (starting at 1).
+	//
+	// If Authenticate returns a nil error, then the user is logged in as 'user'; which
+	// commands can get (from the Context) with User.
+	//
+	// If Authenticate returns an error, then the shell waits for LoginDelay, and asks
+	// again. After LoginTries tries, it gives up and closes the connection (with
+	// telnet.CloseAuthFailed as the reason given to the telnet.Server's OnDisconnect).
+	//
+	// It is given the username and password the shell read, rather than the Conn to read
+	// them from itself; so that the prompting (and turning echoing off for the password) is
+	// done the same way for every Authenticate. (The Conn is still there, as ctx.Conn().)
+	//
+	// (BcryptAuthenticate can be used to check passwords against a map of bcrypt hashes.)
+	Authenticate func(ctx Context, username string, password string, tries int) (user string, err error)
+
+	// Authorizer decides whether the user is allowed to run a command; going by the Permission
+	// the command was registered with (see CommandInfo), and the Permissions of the session (see
+	// Context.SetPermissions, which is typically called by Authenticate).
+	//
+	// If a user is not allowed to run a command, then the command is not run (its Producer is
+	// not even called), and "<command>: permission denied" is written instead.
+	//
+	// If Authorizer is nil, then LevelAuthorizer is used.
+	Authorizer Authorizer
+
+	// HideUnauthorized is whether to leave the commands the user is not allowed to run out of
+	// the help (and out of what TAB completes to). If it is false, then they are in the help,
+	// but marked as not permitted.
+	HideUnauthorized bool
+
+	// LoginTries is how many tries a user gets at logging in. Zero means the default (3).
+	LoginTries int
+
+	// LoginDelay is how long to wait after a failed try at logging in.
+	LoginDelay time.Duration
+
+	UsernamePrompt string
+	PasswordPrompt string
+
+	// CharacterMode is whether to ask the client to let the shell do the echoing
+	// (IAC WILL ECHO) and to suppress go-aheads (IAC WILL SUPPRESS-GO-AHEAD); so that the
+	// client sends each key as it is typed, rather than a line at a time.
+	//
+	// If the client agrees, then the shell does its own line editing, which includes
+	// recalling previous commands (from the history) with the up and down arrow keys.
+	//
+	// If the client does not agree (or CharacterMode is false) the shell still works,
+	// but without the history being recallable.
+	//
+	// The shell also asks the client for the size of its terminal (NAWS), for its
+	// terminal type (TERMINAL-TYPE), which is what Style goes by, and for its environment
+	// variables (NEW-ENVIRON), which become the session's Variables. (With
+	// telnet.Conn.RequestEnvironment; so, for as long as the session lasts, the Conn's
+	// OnEnvironment is the shell's.)
+	CharacterMode bool
+
+	// Pager is whether to page the output of commands; pausing (with a --More-- prompt)
+	// each time a screenful has been written, until the user presses SPACE (for the next
+	// page), ENTER (for the next line), or q (to stop the command).
+	//
+	// How many lines a screenful is comes from PagerLength; or, if PagerLength is zero,
+	// from the height of the client's terminal (from NAWS, so only in CharacterMode). If
+	// the client never said what the height of its terminal is, then nothing is paged.
+	//
+	// Users can change this for their own session with the built-in command:
+	//
+	//	terminal length <lines>
+	//
+	// (Where a length of 0 turns the pager off.)
+	Pager bool
+
+	// PagerLength is how many lines a screenful is, for the pager. Zero means the height
+	// of the client's terminal.
+	PagerLength int
+
+	// CommandTimeout is how long a command can run for, before it is stopped (i.e., before
+	// the context.Context it was given, see Context, is cancelled). The CommandInfo.Timeout
+	// a command was registered with overrides this.
+	//
+	// Zero means commands can run for as long as they like.
+	//
+	// A command is also stopped when the user interrupts it; with Ctrl-C (in CharacterMode),
+	// or with IAC IP (which is what most telnet clients send for "send ip"). (Or with any of
+	// the InterruptCommands.)
+	CommandTimeout time.Duration
+
+	// InterruptCommands are more TELNET commands (besides IAC IP) that interrupt (i.e., stop)
+	// the command that is running; such as telnet.SUSP, and telnet.ABORT (from RFC 1184). For
+	// example:
+	//
+	//	shellHandler.InterruptCommands = []byte{telnet.SUSP, telnet.ABORT}
+	//
+	// The default is none.
+	InterruptCommands []byte
+
+	// CommandGracePeriod is how long to wait for a command that was stopped (see CommandTimeout)
+	// to be done. If it is still not done by then, the shell stops waiting for it (and throws
+	// away any more output from it), writes "command abandoned", and shows the prompt.
+	//
+	// Zero means the default (2 seconds).
+	CommandGracePeriod time.Duration
+
+	// HistorySize is the (maximum) number of commands kept in each session's history.
+	//
+	// Zero means no history is kept.
+	HistorySize int
+
+	// LoadHistory, if not nil, is called at the start of each session to get the
+	// (previously saved) history for 'user'.
+	//
+	// (If users do not log in, see Authenticate, then 'user' is always the empty string.)
+	LoadHistory func(user string) []string
+
+	// SaveHistory, if not nil, is called at the end of each session with the history
+	// for 'user', oldest command first; so that it can be persisted, and loaded again
+	// (with LoadHistory) in a later session.
+	SaveHistory func(user string, history []string)
 }
 
 func NewShellHandler() *ShellHandler {
@@ -38,6 +197,10 @@ func NewShellHandler() *ShellHandler {
 		ExitCommandName: defaultExitCommandName,
 		WelcomeMessage:  defaultWelcomeMessage,
 		ExitMessage:     defaultExitMessage,
+		HistorySize:     defaultHistorySize,
+		LoginDelay:      defaultLoginDelay,
+		UsernamePrompt:  defaultUsernamePrompt,
+		PasswordPrompt:  defaultPasswordPrompt,
 	}
 
 	return &telnetHandler
@@ -96,162 +259,260 @@ func (telnetHandler *ShellHandler) MustRegisterElse(producer Producer) *ShellHan
 	return telnetHandler
 }
 
+// ServeTELNET serves a shell session. (It is the ShellHandler as a telnet.Handler; see
+// ContextHandler.)
 func (telnetHandler *ShellHandler) ServeTELNET(ctx telnet.Context, writer telnet.Writer, reader telnet.Reader) {
+	telnetHandler.serve(context.Background(), ctx, writer, reader)
+}
+
+// ContextHandler returns the ShellHandler, as a telnet.ContextHandler; which is what a
+// telnet.Server serves it as. (See telnet.AdaptHandler.) As one, the Context of the session (see
+// Context) is cancelled once the context of the connection is; such as when the connection drops.
+func (telnetHandler *ShellHandler) ContextHandler() telnet.ContextHandler {
+	return internalShellContextHandler{shellHandler: telnetHandler}
+}
+
+// internalShellContextHandler is a ShellHandler, as a telnet.ContextHandler.
+type internalShellContextHandler struct {
+	shellHandler *ShellHandler
+}
+
+func (handler internalShellContextHandler) ServeTELNET(ctx context.Context, conn *telnet.Conn) {
+	var telnetCtx telnet.Context = telnet.NewContext().InjectLogger(conn.Logger())
+
+	handler.shellHandler.serve(ctx, telnetCtx, conn, conn)
+}
+
+// serve serves a shell session; whose Context is cancelled once 'parent' is (if not before).
+func (telnetHandler *ShellHandler) serve(parent context.Context, ctx telnet.Context, writer telnet.Writer, reader telnet.Reader) {
 
 	logger := ctx.Logger()
 	if nil == logger {
 		logger = internalDiscardLogger{}
 	}
 
-	colonSpaceCommandNotFoundEL := []byte(": command not found\r\n")
-
-	var prompt bytes.Buffer
-	var exitCommandName string
 	var welcomeMessage string
 	var exitMessage string
 
-	prompt.WriteString(telnetHandler.Prompt)
-
-	promptBytes := prompt.Bytes()
-
-	exitCommandName = telnetHandler.ExitCommandName
 	welcomeMessage = telnetHandler.WelcomeMessage
 	exitMessage = telnetHandler.ExitMessage
 
+	editor := NewLineEditor(writer)
+	conn, _ := writer.(*telnet.Conn)
+	shellCtx := newContext(parent, ctx, conn, telnetHandler)
+	shellCtx.editor = editor
+	defer shellCtx.cancel()
+
+	editor.Complete = func(line string, cursor int) []string {
+		return telnetHandler.complete(shellCtx, line, cursor)
+	}
+	if nil != conn {
+		editor.MaxLength = conn.InputLimits().MaxLineLength
+		editor.LineTooLong = conn.LineTooLong
+	}
+
+	var echo *internalEcho
+	if nil != conn && telnetHandler.CharacterMode {
+		echo = telnetHandler.characterMode(logger, shellCtx, editor)
+		defer conn.OnEnvironment(nil)
+	}
+
+	input := newInput(reader)
+	if nil != conn {
+		conn.OnCommand(func(cmd byte) {
+			if telnet.IP == cmd || 0 <= bytes.IndexByte(telnetHandler.InterruptCommands, cmd) {
+				logger.Debugf("Received IAC %s.", telnet.CommandName(cmd))
+				input.interrupted()
+			}
+		})
+		defer conn.OnCommand(nil)
+	}
+
+	if nil != telnetHandler.Authenticate {
+		echo = telnetHandler.loginEcho(logger, conn, echo)
+
+		user, err := telnetHandler.login(shellCtx, writer, input, editor, echo)
+		if nil != err {
+			logger.Warnf("Closing connection, because did not log in: %v", err)
+			if nil != conn && errTooManyLoginTries == err {
+				conn.CloseWithReason(telnet.CloseAuthFailed, "too many tries")
+			}
+			return
+		}
+		shellCtx.user = user
+	}
+
+	var loaded []string
+	if nil != telnetHandler.LoadHistory {
+		loaded = telnetHandler.LoadHistory(shellCtx.user)
+	}
+	editor.SetHistory(telnetHandler.HistorySize, loaded)
+
+	if nil != telnetHandler.SaveHistory {
+		defer func() {
+			telnetHandler.SaveHistory(shellCtx.user, editor.History())
+		}()
+	}
+
 	if _, err := oi.LongWriteString(writer, welcomeMessage); nil != err {
 		logger.Errorf("Problem long writing welcome message: %v", err)
 		return
 	}
 	logger.Debugf("Wrote welcome message: %q.", welcomeMessage)
-	if _, err := oi.LongWrite(writer, promptBytes); nil != err {
+	if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
 		logger.Errorf("Problem long writing prompt: %v", err)
 		return
 	}
-	logger.Debugf("Wrote prompt: %q.", promptBytes)
+	logger.Debugf("Wrote prompt: %q.", editor.prompt)
 
 	var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
 	p := buffer[:]
 
-	var line bytes.Buffer
-
 	for {
 		// Read 1 byte.
-		n, err := reader.Read(p)
+		n, err := input.Read(p)
 		if n <= 0 && nil == err {
 			continue
 		} else if n <= 0 && nil != err {
 			break
 		}
 
-		line.WriteByte(p[0])
 		//logger.Tracef("Received: %q (%d).", p[0], p[0])
 
-		if '\n' == p[0] {
-			lineString := line.String()
-
-			if "\r\n" == lineString {
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
-				}
-				continue
+		lineString, ok, err := editor.Feed(p[0])
+		if io.EOF == err {
+			logger.Debugf("Received end-of-file (Ctrl-D).")
+			telnetHandler.logout(logger, shellCtx, writer)
+			return
+		}
+		if telnet.ErrLineTooLong == err {
+			if conn.InputLimits().DisconnectOnLongLine {
+				logger.Warnf("Closing connection, because received a line that is too long.")
+				return
 			}
-
-			//@TODO: support piping.
-			fields := strings.Fields(lineString)
-			logger.Debugf("Have %d tokens.", len(fields))
-			logger.Tracef("Tokens: %v", fields)
-			if len(fields) <= 0 {
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
-				}
-				continue
+			logger.Debugf("Received a line that is too long.")
+			oi.LongWriteString(writer, "line too long\r\n")
+			if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
+				return
 			}
+			continue
+		}
+		if nil != err {
+			logger.Errorf("Problem echoing: %v", err)
+			return
+		}
+		if !ok {
+			continue
+		}
 
-			field0 := fields[0]
+		stages, err := TokenizePipeline(lineString)
+		if nil != err {
+			oi.LongWriteString(writer, "syntax error: "+err.Error()+"\r\n")
+			if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
+				return
+			}
+			continue
+		}
+		logger.Debugf("Have %d commands.", len(stages))
+		logger.Tracef("Commands: %v", stages)
 
-			if exitCommandName == field0 {
-				oi.LongWriteString(writer, exitMessage)
+		expanded, err := telnetHandler.expandPipeline(stages)
+		if nil != err {
+			oi.LongWriteString(writer, err.Error()+"\r\n")
+			if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
+				return
+			}
+			continue
+		}
+		stages = expanded
+		if len(stages) <= 0 {
+			if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
 				return
 			}
+			continue
+		}
 
-			var producer Producer
+		if 1 == len(stages) && telnetHandler.isExit(stages[0][0]) {
+			telnetHandler.logout(logger, shellCtx, writer)
+			return
+		}
 
-			telnetHandler.muxtex.RLock()
-			var ok bool
-			producer, ok = telnetHandler.producers[field0]
-			telnetHandler.muxtex.RUnlock()
+		telnetHandler.runPipeline(logger, shellCtx, writer, input, editor, stages)
 
-			if !ok {
-				telnetHandler.muxtex.RLock()
-				producer = telnetHandler.elseProducer
-				telnetHandler.muxtex.RUnlock()
-			}
+		if err := editor.ShowPrompt(telnetHandler.prompt(shellCtx)); nil != err {
+			return
+		}
+	}
 
-			if nil == producer {
-				//@TODO: Don't convert that to []byte! think this creates "garbage" (for collector).
-				oi.LongWrite(writer, []byte(field0))
-				oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
-				line.Reset()
-				if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-					return
-				}
-				continue
-			}
+	oi.LongWriteString(writer, exitMessage)
+	return
+}
 
-			handler := producer.Produce(ctx, field0, fields[1:]...)
-			if nil == handler {
-				oi.LongWrite(writer, []byte(field0))
-				//@TODO: Need to use a different error message.
-				oi.LongWrite(writer, colonSpaceCommandNotFoundEL)
-				line.Reset()
-				oi.LongWrite(writer, promptBytes)
-				continue
-			}
+// prompt returns the prompt to show.
+func (telnetHandler *ShellHandler) prompt(ctx Context) string {
+	if fn := telnetHandler.PromptFunc; nil != fn {
+		return fn(ctx)
+	}
 
-			//@TODO: Wire up the stdin, stdout, stderr of the handler.
+	return telnetHandler.Prompt
+}
 
-			if stdoutPipe, err := handler.StdoutPipe(); nil != err {
-				//@TODO:
-			} else if nil == stdoutPipe {
-				//@TODO:
-			} else {
-				connect(ctx, writer, stdoutPipe)
-			}
+// characterMode asks the client to let the shell echo (and to suppress go-aheads), and
+// has the line editor echo whenever the client has agreed to that.
+func (telnetHandler *ShellHandler) characterMode(logger telnet.Logger, ctx *internalContext, editor *LineEditor) *internalEcho {
 
-			if stderrPipe, err := handler.StderrPipe(); nil != err {
-				//@TODO:
-			} else if nil == stderrPipe {
-				//@TODO:
-			} else {
-				connect(ctx, writer, stderrPipe)
-			}
+	conn := ctx.conn
 
-			if err := handler.Run(); nil != err {
-				//@TODO:
-			}
-			line.Reset()
-			if _, err := oi.LongWrite(writer, promptBytes); nil != err {
-				return
-			}
+	if err := conn.RequestWindowSize(); nil != err {
+		logger.Warnf("Problem registering NAWS option: %v", err)
+	} else {
+		editor.Width = func() int {
+			width, _, _ := conn.PeerWindowSize()
+			return width
 		}
+		ctx.windowSize = true
+	}
 
-		//@TODO: Are there any special errors we should be dealing with separately?
-		if nil != err {
-			break
-		}
+	if err := conn.RequestTerminalType(); nil != err {
+		logger.Warnf("Problem registering TERMINAL-TYPE option: %v", err)
+	} else {
+		ctx.terminalType = true
 	}
 
-	oi.LongWriteString(writer, exitMessage)
-	return
+	if err := seedVariables(conn, ctx.variables); nil != err {
+		logger.Warnf("Problem registering NEW-ENVIRON option: %v", err)
+	}
+
+	support := telnet.OptionSupport{
+		Local:        true,
+		RequestLocal: true,
+	}
+	if err := conn.RegisterOption(telnet.OptSuppressGoAhead, telnet.SimpleOption(support)); nil != err {
+		logger.Warnf("Problem registering SUPPRESS-GO-AHEAD option: %v", err)
+	}
+
+	echo := internalEcho{request: true}
+	if err := conn.RegisterOption(telnet.OptEcho, &echo); nil != err {
+		logger.Warnf("Problem registering ECHO option: %v", err)
+		return nil
+	}
+
+	editor.Echo = echo.Enabled
+
+	return &echo
 }
 
-func connect(ctx telnet.Context, writer io.Writer, reader io.Reader) {
+// connect copies (in the background) everything read from 'reader' to 'writer'.
+//
+// The returned channel is closed once 'reader' has been read to the end.
+func connect(ctx telnet.Context, writer io.Writer, reader io.Reader) <-chan struct{} {
 
 	logger := ctx.Logger()
 
+	done := make(chan struct{})
+
 	go func(logger telnet.Logger) {
+		defer close(done)
 
 		var buffer [1]byte // Seems like the length of the buffer needs to be small, otherwise will have to wait for buffer to fill up.
 		p := buffer[:]
@@ -271,4 +532,6 @@ func connect(ctx telnet.Context, writer io.Writer, reader io.Reader) {
 			//logger.Tracef("Sent: %q.", p)
 		}
 	}(logger)
+
+	return done
 }
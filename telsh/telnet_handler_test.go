@@ -1,10 +1,16 @@
 package telsh
 
 import (
+	"github.com/reiver/go-oi"
 	"github.com/wouteroostervld/go-telnet"
 
 	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"testing"
 )
@@ -94,3 +100,216 @@ func TestServeTELNETCommandNotFound(t *testing.T) {
 		}
 	}
 }
+
+func TestServeTELNETHistory(t *testing.T) {
+
+	saved := make(chan []string, 1)
+
+	shellHandler := NewShellHandler()
+	shellHandler.CharacterMode = true
+	shellHandler.LoadHistory = func(user string) []string {
+		return []string{"a\xffb"}
+	}
+	shellHandler.SaveHistory = func(user string, history []string) {
+		saved <- history
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, shellHandler)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	// Agree to the shell echoing; recall the (loaded) history; and then run it.
+	request := []byte{
+		telnet.IAC, telnet.WONT, telnet.OptNAWS,
+		telnet.IAC, telnet.DO, telnet.OptSuppressGoAhead,
+		telnet.IAC, telnet.DO, telnet.OptEcho,
+		0x1B, '[', 'A',
+		'\r', 0,
+	}
+	if _, err := client.Write(request); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// The IAC in the recalled line must be escaped.
+	expected := "\r\x1b[J" + shellHandler.Prompt + "a\xff\xffb\r\n" + "a\xff\xffb: command not found\r\n" + shellHandler.Prompt
+
+	var received bytes.Buffer
+	p := make([]byte, 256)
+	for !strings.HasSuffix(received.String(), expected) {
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := client.Read(p)
+		received.Write(p[:n])
+		if nil != err {
+			t.Fatalf("Expected to receive %q, but actually got %q.", expected, received.String())
+		}
+	}
+
+	client.Close()
+
+	select {
+	case history := <-saved:
+		if expected, actual := []string{"a\xffb"}, history; len(expected) != len(actual) || expected[0] != actual[0] {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the history to be saved, but it was not.")
+	}
+}
+
+func TestServeTELNETPromptFunc(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+
+	var renders int
+	shellHandler.PromptFunc = func(ctx Context) string {
+		if shellHandler != ctx.ShellHandler() {
+			t.Errorf("Expected the ShellHandler to be %p, but actually got %p.", shellHandler, ctx.ShellHandler())
+		}
+
+		renders++
+		return "[" + strconv.Itoa(renders) + "]> "
+	}
+
+	shellHandler.MustRegisterHandlerFunc("five", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		oi.LongWriteString(stdout, "5\r\n")
+		return nil
+	})
+
+	var buffer bytes.Buffer
+
+	shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader("five\r\n\r\nfive\r\n"))
+
+	// The output of each command must come before the prompt after it.
+	if expected, actual := shellHandler.WelcomeMessage+"[1]> 5\r\n[2]> [3]> 5\r\n[4]> "+shellHandler.ExitMessage, buffer.String(); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServeTELNETQuoting(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "set motd \"Welcome to the lab\"\r\n",
+			Expected:    "[\"motd\" \"Welcome to the lab\"]\r\n",
+		},
+		{
+			ClientSends: "'set' a\\ b ''\r\n",
+			Expected:    "[\"a b\" \"\"]\r\n",
+		},
+		{
+			ClientSends: "set motd \"Welcome to the lab\r\n",
+			Expected:    "syntax error: unterminated double quote\r\n",
+		},
+		{
+			ClientSends: "'apple pie'\r\n",
+			Expected:    "apple pie: command not found\r\n",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+		shellHandler.MustRegisterHandlerFunc("set", func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			oi.LongWriteString(stdout, fmt.Sprintf("%q\r\n", args))
+			return nil
+		})
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := shellHandler.WelcomeMessage+shellHandler.Prompt+test.Expected+shellHandler.Prompt+shellHandler.ExitMessage, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETLineTooLong(t *testing.T) {
+
+	tests := []struct {
+		DisconnectOnLongLine bool
+		ExpectedReason       telnet.CloseReason
+		Expected             string
+	}{
+		{
+			DisconnectOnLongLine: false,
+			ExpectedReason:       telnet.ClosePeerClosed,
+			Expected:             "line too long\r\n" + defaultPrompt + "echo: command not found\r\n" + defaultPrompt,
+		},
+		{
+			DisconnectOnLongLine: true,
+			ExpectedReason:       telnet.CloseLineTooLong,
+			Expected:             "",
+		},
+	}
+
+	for testNumber, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		reasons := make(chan telnet.CloseReason, 1)
+
+		server := &telnet.Server{
+			Handler: NewShellHandler(),
+			InputLimits: telnet.InputLimits{
+				MaxLineLength:        16,
+				DisconnectOnLongLine: test.DisconnectOnLongLine,
+			},
+			OnDisconnect: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+				reasons <- reason
+			},
+		}
+		go server.Serve(listener)
+
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+
+		client.Write([]byte(strings.Repeat("x", 100) + "\r\necho\r\n"))
+
+		expected := defaultWelcomeMessage + defaultPrompt + test.Expected
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		received := make([]byte, len(expected))
+		if test.DisconnectOnLongLine {
+			received, _ = io.ReadAll(client)
+		} else {
+			io.ReadFull(client, received)
+		}
+		client.Close()
+
+		if actual := string(received); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		select {
+		case reason := <-reasons:
+			if expected, actual := test.ExpectedReason, reason; expected != actual {
+				t.Errorf("For test #%d, expected close reason %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("For test #%d, timed out waiting for OnDisconnect to be called.", testNumber)
+		}
+		if expected, actual := uint64(1), server.InputViolations().LineTooLong; expected != actual {
+			t.Errorf("For test #%d, expected %d violations, but actually got %d.", testNumber, expected, actual)
+		}
+
+		listener.Close()
+	}
+}
@@ -0,0 +1,56 @@
+package telsh
+
+import (
+	"strconv"
+	"strings"
+)
+
+// mttsANSI is the bit (of the "MTTS <bits>" terminal type) that says the client supports ANSI
+// escape sequences. (Clients that support MTTS, the "Mud Terminal Type Standard", answer with
+// their name, then their terminal type, and then "MTTS <bits>".)
+const mttsANSI = 1
+
+// ansiTerminalTypes are (the starts of) the terminal types of terminals that support ANSI
+// escape sequences.
+var ansiTerminalTypes = []string{
+	"ansi",
+	"cygwin",
+	"eterm",
+	"konsole",
+	"linux",
+	"putty",
+	"rxvt",
+	"screen",
+	"tmux",
+	"xterm",
+}
+
+// ansiTerminal returns whether the client (going by the terminal types it told, with
+// TERMINAL-TYPE; see telnet.Conn.RequestTerminalType) supports ANSI escape sequences.
+//
+// What the client says with MTTS takes precedence over its (other) terminal types.
+func ansiTerminal(types []string) bool {
+
+	for _, name := range types {
+		if !strings.HasPrefix(strings.ToUpper(name), "MTTS ") {
+			continue
+		}
+
+		bits, err := strconv.Atoi(strings.TrimSpace(name[len("MTTS "):]))
+		if nil == err {
+			return 0 != bits&mttsANSI
+		}
+	}
+
+	for _, name := range types {
+		name = strings.ToLower(name)
+
+		for _, ansi := range ansiTerminalTypes {
+			if strings.HasPrefix(name, ansi) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
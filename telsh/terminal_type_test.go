@@ -0,0 +1,116 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestANSITerminal(t *testing.T) {
+
+	tests := []struct {
+		Types    []string
+		Expected bool
+	}{
+		{
+			Types:    nil,
+			Expected: false,
+		},
+		{
+			Types:    []string{"DUMB"},
+			Expected: false,
+		},
+		{
+			Types:    []string{"XTERM-256COLOR"},
+			Expected: true,
+		},
+		{
+			Types:    []string{"MUDLET", "ANSI-256COLOR", "MTTS 137"},
+			Expected: true,
+		},
+		{
+			// MTTS takes precedence.
+			Types:    []string{"SOMECLIENT", "XTERM", "MTTS 0"},
+			Expected: false,
+		},
+		{
+			Types:    []string{"TINTIN++", "MTTS 1"},
+			Expected: true,
+		},
+		{
+			// Not MTTS bits.
+			Types:    []string{"VT100", "MTTS x"},
+			Expected: false,
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, ansiTerminal(test.Types); expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t; for terminal types: %q", testNumber, expected, actual, test.Types)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETTerminalType(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+	shellHandler.CharacterMode = true
+	shellHandler.MustRegister("status", ProducerFunc(func(ctx telnet.Context, name string, args ...string) Handler {
+		style := ctx.(Context).Style()
+
+		return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+			io.WriteString(stdout, "eth0 "+style.Red("DOWN")+"\r\n")
+			return nil
+		})
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, shellHandler)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	expect := func(expected string) string {
+		t.Helper()
+
+		var received bytes.Buffer
+		p := make([]byte, 1)
+		for !strings.HasSuffix(received.String(), expected) {
+			client.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := client.Read(p)
+			received.Write(p[:n])
+			if nil != err {
+				t.Fatalf("Expected to receive %q, but actually got %q.", expected, received.String())
+			}
+		}
+		return received.String()
+	}
+
+	send := string([]byte{telnet.IAC, telnet.SB, telnet.OptTerminalType, 1, telnet.IAC, telnet.SE}) // (1 is SEND.)
+	is := append([]byte{telnet.IAC, telnet.SB, telnet.OptTerminalType, 0}, "XTERM"...)              // (0 is IS.)
+	is = append(is, telnet.IAC, telnet.SE)
+
+	client.Write([]byte{telnet.IAC, telnet.WILL, telnet.OptTerminalType})
+	expect(send)
+	client.Write(is)
+	expect(send)
+	client.Write(is)
+
+	client.Write([]byte("status\r\n"))
+	expect("eth0 \x1b[31mDOWN\x1b[0m\r\n" + shellHandler.Prompt)
+}
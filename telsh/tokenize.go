@@ -0,0 +1,154 @@
+package telsh
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errTrailingBackslash       = errors.New("trailing backslash")
+	errUnterminatedDoubleQuote = errors.New("unterminated double quote")
+	errUnterminatedSingleQuote = errors.New("unterminated single quote")
+	errMissingPipelineCommand  = errors.New("missing command in pipeline")
+)
+
+// Tokenize splits a command line into its arguments; roughly the way a POSIX shell would
+// (but without any expansions).
+//
+// Arguments are separated by (unquoted) spaces and tabs. And:
+//
+//	'...'   single quotes keep everything between them as is
+//	"..."   double quotes keep everything between them as is, except that \" and \\ can be used for " and \
+//	\c      a backslash (outside of quotes) keeps the character after it as is
+//
+// Quoted (and unquoted) text right next to each other is all part of the same argument.
+// So, for example:
+//
+//	set motd "Welcome to the lab"
+//
+// ... is split into:
+//
+//	[]string{"set", "motd", "Welcome to the lab"}
+//
+// And:
+//
+//	a"b c"d '' \"
+//
+// ... is split into:
+//
+//	[]string{"ab cd", "", "\""}
+//
+// An unterminated quote, or a backslash at the very end of the command line, is an error.
+//
+// (A "|" is not special to Tokenize; see TokenizePipeline.)
+func Tokenize(line string) ([]string, error) {
+	stages, err := tokenize(line, false)
+
+	return stages[0], err
+}
+
+// TokenizePipeline is like Tokenize, except that the command line is also split into the
+// commands of a pipeline, at each (unquoted) "|". So, for example:
+//
+//	show sessions | grep "admin|root" | count
+//
+// ... is split into:
+//
+//	[][]string{
+//		{"show", "sessions"},
+//		{"grep", "admin|root"},
+//		{"count"},
+//	}
+//
+// A "|" without a command before (or after) it is an error.
+func TokenizePipeline(line string) ([][]string, error) {
+	stages, err := tokenize(line, true)
+	if nil != err {
+		return stages, err
+	}
+
+	if 1 < len(stages) {
+		for _, stage := range stages {
+			if len(stage) <= 0 {
+				return stages, errMissingPipelineCommand
+			}
+		}
+	}
+
+	return stages, nil
+}
+
+// tokenize splits 'line' into its arguments; and, if 'pipes', into the commands of a pipeline
+// (at each unquoted "|").
+//
+// There is always at least one (possibly empty) command returned.
+func tokenize(line string, pipes bool) ([][]string, error) {
+
+	var stages [][]string
+	var tokens []string
+
+	var token strings.Builder
+	var inToken bool
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		case '|':
+			if !pipes {
+				token.WriteByte(c)
+				inToken = true
+				break
+			}
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+			stages = append(stages, tokens)
+			tokens = nil
+		case '\\':
+			i++
+			if len(line) <= i {
+				return append(stages, tokens), errTrailingBackslash
+			}
+			token.WriteByte(line[i])
+			inToken = true
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return append(stages, tokens), errUnterminatedSingleQuote
+			}
+			token.WriteString(line[i+1 : i+1+end])
+			i += 1 + end
+			inToken = true
+		case '"':
+			i++
+			for ; i < len(line) && '"' != line[i]; i++ {
+				if '\\' == line[i] && i+1 < len(line) && ('"' == line[i+1] || '\\' == line[i+1]) {
+					i++
+				}
+				token.WriteByte(line[i])
+			}
+			if len(line) <= i {
+				return append(stages, tokens), errUnterminatedDoubleQuote
+			}
+			inToken = true
+		default:
+			token.WriteByte(c)
+			inToken = true
+		}
+	}
+
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+
+	return append(stages, tokens), nil
+}
@@ -0,0 +1,211 @@
+package telsh
+
+import (
+	"strings"
+
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+
+	tests := []struct {
+		Line          string
+		Expected      []string
+		ExpectedError error
+	}{
+		{
+			Line:     "",
+			Expected: []string{},
+		},
+		{
+			Line:     " \t ",
+			Expected: []string{},
+		},
+		{
+			Line:     "ls -alF",
+			Expected: []string{"ls", "-alF"},
+		},
+		{
+			Line:     "  ls \t -alF  ",
+			Expected: []string{"ls", "-alF"},
+		},
+		{
+			Line:     `set motd "Welcome to the lab"`,
+			Expected: []string{"set", "motd", "Welcome to the lab"},
+		},
+		{
+			Line:     `set motd 'Welcome to the lab'`,
+			Expected: []string{"set", "motd", "Welcome to the lab"},
+		},
+		{
+			Line:     `a"b c"d`,
+			Expected: []string{"ab cd"},
+		},
+		{
+			Line:     `a'b c'd"e f"g`,
+			Expected: []string{"ab cde fg"},
+		},
+		{
+			Line:     `""`,
+			Expected: []string{""},
+		},
+		{
+			Line:     `''`,
+			Expected: []string{""},
+		},
+		{
+			Line:     `a "" b '' c`,
+			Expected: []string{"a", "", "b", "", "c"},
+		},
+		{
+			Line:     `a""`,
+			Expected: []string{"a"},
+		},
+		{
+			Line:     `a\ b`,
+			Expected: []string{"a b"},
+		},
+		{
+			Line:     `\"a\"`,
+			Expected: []string{`"a"`},
+		},
+		{
+			Line:     `\\`,
+			Expected: []string{`\`},
+		},
+		{
+			Line:     `"a\"b\\c\d"`,
+			Expected: []string{`a"b\c\d`},
+		},
+		{
+			Line:     `'a\"b\\c'`,
+			Expected: []string{`a\"b\\c`},
+		},
+		{
+			Line:     `"it's"`,
+			Expected: []string{`it's`},
+		},
+		{
+			Line:     `'say "hi"'`,
+			Expected: []string{`say "hi"`},
+		},
+		{
+			Line:     "\"a\tb\"",
+			Expected: []string{"a\tb"},
+		},
+		{
+			Line:     "a|b | c",
+			Expected: []string{"a|b", "|", "c"},
+		},
+
+		{
+			Line:          `a\`,
+			ExpectedError: errTrailingBackslash,
+		},
+		{
+			Line:          `echo "Welcome to the lab`,
+			ExpectedError: errUnterminatedDoubleQuote,
+		},
+		{
+			Line:          `echo "a\"`,
+			ExpectedError: errUnterminatedDoubleQuote,
+		},
+		{
+			Line:          `echo 'it`,
+			ExpectedError: errUnterminatedSingleQuote,
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		actual, err := Tokenize(test.Line)
+		if expected := test.ExpectedError; expected != err {
+			t.Errorf("For test #%d, expected error %v, but actually got %v; for line %q.", testNumber, expected, err, test.Line)
+			continue
+		}
+		if nil != err {
+			continue
+		}
+
+		if expected := test.Expected; len(expected) != len(actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, expected, actual, test.Line)
+			continue
+		}
+		for i := range actual {
+			if expected := test.Expected[i]; expected != actual[i] {
+				t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, test.Expected, actual, test.Line)
+				break
+			}
+		}
+	}
+}
+
+func TestTokenizePipeline(t *testing.T) {
+
+	tests := []struct {
+		Line          string
+		Expected      [][]string
+		ExpectedError error
+	}{
+		{
+			Line:     "",
+			Expected: [][]string{{}},
+		},
+		{
+			Line:     "show sessions",
+			Expected: [][]string{{"show", "sessions"}},
+		},
+		{
+			Line:     "show sessions | grep admin | count",
+			Expected: [][]string{{"show", "sessions"}, {"grep", "admin"}, {"count"}},
+		},
+		{
+			Line:     "a|b|c",
+			Expected: [][]string{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			Line:     `show | grep "admin|root" | grep 'a|b' | grep a\|b`,
+			Expected: [][]string{{"show"}, {"grep", "admin|root"}, {"grep", "a|b"}, {"grep", "a|b"}},
+		},
+
+		{
+			Line:          "show |",
+			ExpectedError: errMissingPipelineCommand,
+		},
+		{
+			Line:          "| count",
+			ExpectedError: errMissingPipelineCommand,
+		},
+		{
+			Line:          "show || count",
+			ExpectedError: errMissingPipelineCommand,
+		},
+		{
+			Line:          "show | \"count",
+			ExpectedError: errUnterminatedDoubleQuote,
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		actual, err := TokenizePipeline(test.Line)
+		if expected := test.ExpectedError; expected != err {
+			t.Errorf("For test #%d, expected error %v, but actually got %v; for line %q.", testNumber, expected, err, test.Line)
+			continue
+		}
+		if nil != err {
+			continue
+		}
+
+		if expected := test.Expected; len(expected) != len(actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, expected, actual, test.Line)
+			continue
+		}
+		for i := range actual {
+			if expected := strings.Join(test.Expected[i], "\x00"); expected != strings.Join(actual[i], "\x00") {
+				t.Errorf("For test #%d, expected %q, but actually got %q; for line %q.", testNumber, test.Expected, actual, test.Line)
+				break
+			}
+		}
+	}
+}
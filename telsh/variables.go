@@ -0,0 +1,181 @@
+package telsh
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Variables are the (per session) variables of a shell session; which commands (and the
+// PromptFunc) can get at with:
+//
+//	variables := ctx.(telsh.Context).Variables()
+//
+// The variables start out as whatever environment variables the client sent (with
+// NEW-ENVIRON, when in CharacterMode). Users can change them with the built-in commands:
+//
+//	set <name> <value>
+//	unset <name>...
+//	env
+//
+// Setting a variable is seen by every command run after it (and by the prompt). The
+// variables only last as long as the session does; i.e., they are gone once the
+// connection is closed.
+//
+// Variables is safe to use from more than one goroutine at once.
+type Variables struct {
+	mutex  sync.RWMutex
+	values map[string]string
+}
+
+// Get returns the value of the variable 'name'; and whether that variable is set.
+func (variables *Variables) Get(name string) (string, bool) {
+	variables.mutex.RLock()
+	defer variables.mutex.RUnlock()
+
+	value, ok := variables.values[name]
+	return value, ok
+}
+
+// Set sets the variable 'name' to 'value'.
+func (variables *Variables) Set(name string, value string) {
+	variables.mutex.Lock()
+	defer variables.mutex.Unlock()
+
+	if nil == variables.values {
+		variables.values = map[string]string{}
+	}
+	variables.values[name] = value
+}
+
+// Unset removes the variable 'name'.
+func (variables *Variables) Unset(name string) {
+	variables.mutex.Lock()
+	defer variables.mutex.Unlock()
+
+	delete(variables.values, name)
+}
+
+// Names returns the names of all the variables that are set, sorted.
+func (variables *Variables) Names() []string {
+	variables.mutex.RLock()
+	defer variables.mutex.RUnlock()
+
+	names := make([]string, 0, len(variables.values))
+	for name := range variables.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// String returns the value of the variable 'name'; or 'defaultValue' if it is not set.
+func (variables *Variables) String(name string, defaultValue string) string {
+	if value, ok := variables.Get(name); ok {
+		return value
+	}
+
+	return defaultValue
+}
+
+// Int returns the value of the variable 'name' as an int; or 'defaultValue' if it is not
+// set, or is not an int.
+func (variables *Variables) Int(name string, defaultValue int) int {
+	value, ok := variables.Get(name)
+	if !ok {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if nil != err {
+		return defaultValue
+	}
+
+	return i
+}
+
+// Bool returns the value of the variable 'name' as a bool; or 'defaultValue' if it is not
+// set, or is not a bool.
+//
+// As well as what strconv.ParseBool accepts, "on", "yes", "off", and "no" are accepted.
+func (variables *Variables) Bool(name string, defaultValue bool) bool {
+	value, ok := variables.Get(name)
+	if !ok {
+		return defaultValue
+	}
+
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "yes":
+		return true
+	case "off", "no":
+		return false
+	}
+
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if nil != err {
+		return defaultValue
+	}
+
+	return b
+}
+
+// Duration returns the value of the variable 'name' as a time.Duration (such as "1m30s");
+// or 'defaultValue' if it is not set, or is not a time.Duration.
+func (variables *Variables) Duration(name string, defaultValue time.Duration) time.Duration {
+	value, ok := variables.Get(name)
+	if !ok {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if nil != err {
+		return defaultValue
+	}
+
+	return duration
+}
+
+// internalVariablesProducer is the Producer for the built-in set, unset, and env commands.
+type internalVariablesProducer struct{}
+
+func (internalVariablesProducer) Produce(ctx telnet.Context, name string, args ...string) Handler {
+	return PromoteHandlerFunc(func(stdin io.ReadCloser, stdout io.WriteCloser, stderr io.WriteCloser, args ...string) error {
+		shellCtx, ok := ctx.(Context)
+		if !ok {
+			oi.LongWriteString(stderr, name+": not supported\r\n")
+			return nil
+		}
+		variables := shellCtx.Variables()
+
+		switch {
+		case "unset" == name:
+			for _, arg := range args {
+				variables.Unset(arg)
+			}
+		case "set" == name && 0 < len(args):
+			variable, value := args[0], strings.Join(args[1:], " ")
+			if i := strings.IndexByte(variable, '='); 0 <= i && 1 == len(args) {
+				variable, value = variable[:i], variable[i+1:]
+			}
+			if "" == variable || strings.ContainsRune(variable, '=') {
+				oi.LongWriteString(stderr, name+": invalid variable name: "+args[0]+"\r\n")
+				return nil
+			}
+			variables.Set(variable, value)
+		default: // "env", or "set" without any arguments.
+			for _, variable := range variables.Names() {
+				value, _ := variables.Get(variable)
+				oi.LongWriteString(stdout, variable+"="+value+"\r\n")
+			}
+		}
+
+		return nil
+	}, args...)
+}
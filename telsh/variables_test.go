@@ -0,0 +1,159 @@
+package telsh
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bytes"
+	"net"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+
+	var variables Variables
+	variables.Set("length", " 42 ")
+	variables.Set("pager", "off")
+	variables.Set("verbose", "true")
+	variables.Set("timeout", "1m30s")
+	variables.Set("junk", "apple")
+
+	if expected, actual := 42, variables.Int("length", 24); expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := 24, variables.Int("junk", 24); expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := 24, variables.Int("missing", 24); expected != actual {
+		t.Errorf("Expected %d, but actually got %d.", expected, actual)
+	}
+
+	if expected, actual := false, variables.Bool("pager", true); expected != actual {
+		t.Errorf("Expected %t, but actually got %t.", expected, actual)
+	}
+	if expected, actual := true, variables.Bool("verbose", false); expected != actual {
+		t.Errorf("Expected %t, but actually got %t.", expected, actual)
+	}
+	if expected, actual := true, variables.Bool("junk", true); expected != actual {
+		t.Errorf("Expected %t, but actually got %t.", expected, actual)
+	}
+
+	if expected, actual := 90*time.Second, variables.Duration("timeout", time.Second); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+	if expected, actual := time.Second, variables.Duration("junk", time.Second); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	variables.Unset("junk")
+	if _, ok := variables.Get("junk"); ok {
+		t.Errorf("Expected \"junk\" to have been unset, but it was not.")
+	}
+	if expected, actual := "length pager timeout verbose", strings.Join(variables.Names(), " "); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServeTELNETVariables(t *testing.T) {
+
+	tests := []struct {
+		ClientSends string
+		Expected    string
+	}{
+		{
+			ClientSends: "set context router1\r\n",
+			Expected:    "> router1> ",
+		},
+		{
+			ClientSends: "set context router1\r\nunset context\r\n",
+			Expected:    "> router1> > ",
+		},
+		{
+			ClientSends: "set context=\"core switch\"\r\nset motd hello  there\r\nenv\r\n",
+			Expected:    "> core switch> core switch> context=core switch\r\nmotd=hello there\r\ncore switch> ",
+		},
+		{
+			ClientSends: "set =x\r\n",
+			Expected:    "> set: invalid variable name: =x\r\n> ",
+		},
+	}
+
+	for testNumber, test := range tests {
+
+		shellHandler := NewShellHandler()
+		shellHandler.WelcomeMessage = ""
+		shellHandler.ExitMessage = ""
+		shellHandler.PromptFunc = func(ctx Context) string {
+			return ctx.Variables().String("context", "") + "> "
+		}
+
+		var buffer bytes.Buffer
+
+		shellHandler.ServeTELNET(telnet.NewContext(), &buffer, strings.NewReader(test.ClientSends))
+
+		if expected, actual := test.Expected, buffer.String(); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q; for client sent: %q", testNumber, expected, actual, test.ClientSends)
+			continue
+		}
+	}
+}
+
+func TestServeTELNETEnviron(t *testing.T) {
+
+	shellHandler := NewShellHandler()
+	shellHandler.CharacterMode = true
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	go telnet.Serve(listener, shellHandler)
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+
+	expect := func(expected string) string {
+		t.Helper()
+
+		var received bytes.Buffer
+		p := make([]byte, 1)
+		for !strings.HasSuffix(received.String(), expected) {
+			client.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := client.Read(p)
+			received.Write(p[:n])
+			if nil != err {
+				t.Fatalf("Expected to receive %q, but actually got %q.", expected, received.String())
+			}
+		}
+		return received.String()
+	}
+
+	client.Write([]byte{telnet.IAC, telnet.WILL, telnet.OptNewEnviron})
+	expect(string([]byte{telnet.IAC, telnet.SB, telnet.OptNewEnviron, 1, telnet.IAC, telnet.SE})) // (1 is SEND.)
+
+	reply := []byte{telnet.IAC, telnet.SB, telnet.OptNewEnviron, 0} // (0 is IS.)
+	reply = append(reply, "\x00USER\x01joeblow\x03ROLE\x01admin"...)
+	reply = append(reply, telnet.IAC, telnet.SE)
+	client.Write(reply)
+
+	client.Write([]byte("env\r\n"))
+	expect("ROLE=admin\r\nUSER=joeblow\r\n" + shellHandler.Prompt)
+
+	// A variable the client undefines (with an INFO) is unset.
+	info := []byte{telnet.IAC, telnet.SB, telnet.OptNewEnviron, 2} // (2 is INFO.)
+	info = append(info, "\x03ROLE"...)
+	info = append(info, telnet.IAC, telnet.SE)
+	client.Write(info)
+
+	client.Write([]byte("env\r\n"))
+	if expected, actual := "USER=joeblow\r\n"+shellHandler.Prompt, expect(shellHandler.Prompt); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
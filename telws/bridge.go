@@ -0,0 +1,373 @@
+package telws
+
+import (
+	"github.com/reiver/go-oi"
+	"github.com/wouteroostervld/go-telnet"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBufferSize  = 64 * 1024
+	defaultDialTimeout = 10 * time.Second
+)
+
+// ErrUnauthorized is returned by a Bridge's Route func when the request is not (or not properly)
+// authenticated; which the Bridge answers with 401 Unauthorized, rather than 403 Forbidden.
+var ErrUnauthorized = errors.New("telws: unauthorized")
+
+// Backpressure is what a Bridge does when the target sends output faster than the browser takes it.
+type Backpressure int
+
+const (
+	// BackpressureBlock stops reading from the target, once the buffer is full, until the
+	// browser has caught up. (This is the default.)
+	BackpressureBlock Backpressure = iota
+
+	// BackpressureDrop throws away the output of the target that does not fit in the buffer.
+	BackpressureDrop
+)
+
+// String returns "block" or "drop".
+func (backpressure Backpressure) String() string {
+	switch backpressure {
+	case BackpressureBlock:
+		return "block"
+	case BackpressureDrop:
+		return "drop"
+	default:
+		return "unknown"
+	}
+}
+
+// Bridge is an http.Handler, which upgrades each request to a WebSocket, connects to a TELNET
+// server (the target), and relays between the two. (So that a terminal in a browser, such as
+// xterm.js, can be used with the target.)
+//
+// For a simple example:
+//
+//	bridge := &telws.Bridge{
+//		Target: "10.0.0.7:23",
+//	}
+//
+//	http.Handle("/telnet", bridge)
+//
+// The client sends what the user types as binary messages; which are written to the target as
+// TELNET data (i.e., with any IAC escaped). And the (un-escaped) data the target sends is sent
+// to the client as binary messages.
+//
+// Text messages from the client are control messages, in JSON. There is (so far) just the one:
+//
+//	{"type": "resize", "cols": 80, "rows": 24}
+//
+// ... which tells the Bridge the size of the terminal; which it tells the target with NAWS.
+// (The Bridge offers NAWS to the target once it is first told the size; see
+// telnet.Conn.SetWindowSize.) Any other control message is ignored.
+//
+// When either the client, or the target, closes the connection, the other one is closed too.
+type Bridge struct {
+	// Target is the (TCP) address of the TELNET server to connect to; unless Route is not nil.
+	Target string
+
+	// Route, if not nil, is called (before the request is upgraded) to choose the target for
+	// the request; such as by what is in its URL. It can also be used to authenticate the
+	// request; such as by a cookie, or a token in the URL. For example:
+	//
+	//	Route: func(r *http.Request) (string, error) {
+	//		user, err := sessions.User(r)
+	//		if nil != err {
+	//			return "", telws.ErrUnauthorized
+	//		}
+	//
+	//		device := r.URL.Query().Get("device")
+	//		if !user.MayUse(device) {
+	//			return "", errors.New("not allowed to use " + device)
+	//		}
+	//
+	//		return devices.Address(device), nil
+	//	}
+	//
+	// If Route returns ErrUnauthorized (or an error that wraps it), then the request gets a
+	// 401 Unauthorized; for any other error, a 403 Forbidden.
+	Route func(r *http.Request) (string, error)
+
+	// Dial, if not nil, is used to connect to the target; instead of telnet.Dialer.DialToContext.
+	// (Such as to connect with TLS.) It should give up once 'ctx' is done; which it is when the
+	// request is (such as when the browser goes away), or when DialTimeout is up.
+	Dial func(ctx context.Context, addr string) (*telnet.Conn, error)
+
+	// DialTimeout is how long connecting to the target can take; before the request gets a
+	// 502 Bad Gateway. The default is 10 seconds.
+	DialTimeout time.Duration
+
+	// CheckOrigin, if not nil, is called to decide whether to allow a request, by its Origin
+	// header.
+	//
+	// By default, a request with an Origin header is only allowed if its origin is on the same
+	// host as the request is to. (So that other web sites cannot use the Bridge, with the
+	// cookies of the user.)
+	CheckOrigin func(r *http.Request) bool
+
+	// Backpressure is what to do when the browser is slower than the target. The default is
+	// BackpressureBlock.
+	Backpressure Backpressure
+
+	// BufferSize is how much (in bytes) of the output of the target is held, for a browser that
+	// is slower than the target, before Backpressure kicks in. The default is 64 KiB.
+	BufferSize int
+
+	Logger telnet.Logger
+}
+
+type internalControlMessage struct {
+	Type string `json:"type"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// ServeHTTP upgrades the request to a WebSocket, connects to the target, and then relays between
+// the two; until either of them closes the connection.
+func (bridge *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	logger := bridge.logger()
+
+	if http.MethodGet != r.Method {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !isUpgrade(r) {
+		http.Error(w, "Expected a WebSocket upgrade.", http.StatusBadRequest)
+		return
+	}
+	if "13" != r.Header.Get("Sec-WebSocket-Version") {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version.", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if "" == key {
+		http.Error(w, "Missing Sec-WebSocket-Key.", http.StatusBadRequest)
+		return
+	}
+
+	if !bridge.checkOrigin(r) {
+		logger.Debugf("Refused origin %q.", r.Header.Get("Origin"))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	target := bridge.Target
+	if route := bridge.Route; nil != route {
+		var err error
+		target, err = route(r)
+		switch {
+		case errors.Is(err, ErrUnauthorized):
+			logger.Debugf("Unauthorized request from %q: %v", r.RemoteAddr, err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		case nil != err:
+			logger.Debugf("Forbidden request from %q: %v", r.RemoteAddr, err)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+	if "" == target {
+		logger.Errorf("No target for request from %q.", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := bridge.dial(r.Context(), target)
+	if nil != err {
+		logger.Errorf("Problem connecting to %q: %v", target, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+	logger.Debugf("Connected to %q, for %q.", target, r.RemoteAddr)
+
+	ws, err := upgrade(w, r, key)
+	if nil != err {
+		logger.Errorf("Problem upgrading request from %q: %v", r.RemoteAddr, err)
+		return
+	}
+
+	bridge.relay(logger, ws, conn)
+}
+
+// dial connects to the target; giving up once 'ctx' is done, or DialTimeout is up.
+func (bridge *Bridge) dial(ctx context.Context, target string) (*telnet.Conn, error) {
+
+	timeout := bridge.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dial := bridge.Dial
+	if nil == dial {
+		dial = (&telnet.Dialer{}).DialToContext
+	}
+
+	return dial(ctx, target)
+}
+
+// relay relays between the WebSocket 'ws', and the TELNET connection 'conn'.
+func (bridge *Bridge) relay(logger telnet.Logger, ws *internalWebSocket, conn *telnet.Conn) {
+
+	size := bridge.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	queue := newOutputQueue(size, BackpressureDrop == bridge.Backpressure)
+
+	// From the target, to the queue.
+	go func() {
+		defer queue.close()
+
+		var buffer [4096]byte
+		p := buffer[:]
+
+		var dropping bool
+		for {
+			n, err := conn.Read(p)
+			if 0 < n {
+				dropped := queue.push(p[:n])
+				if 0 < dropped && !dropping {
+					logger.Warnf("Dropping output of the target, as the client is not keeping up.")
+				}
+				dropping = 0 < dropped
+			}
+
+			if io.EOF == err {
+				logger.Debugf("Target closed the connection.")
+				return
+			}
+			if nil != err {
+				if !errors.Is(err, net.ErrClosed) {
+					logger.Errorf("Problem reading from the target: %v", err)
+				}
+				return
+			}
+		}
+	}()
+
+	// From the queue, to the client.
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+
+		for {
+			p, ok := queue.pop()
+			if !ok {
+				ws.close(closeNormal, "")
+				return
+			}
+
+			if err := ws.writeFrame(opBinary, p); nil != err {
+				if !errors.Is(err, net.ErrClosed) {
+					logger.Errorf("Problem writing to the client: %v", err)
+				}
+				queue.close()
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	// From the client, to the target.
+	code := closeNormal
+	for {
+		opcode, payload, err := ws.readMessage()
+		if nil != err {
+			switch {
+			case errors.Is(err, errProtocol):
+				code = closeProtocolError
+			case errors.Is(err, errMessageTooBig):
+				code = closeMessageTooBig
+			}
+			if io.EOF != err && !errors.Is(err, net.ErrClosed) {
+				logger.Errorf("Problem reading from the client: %v", err)
+			}
+			break
+		}
+
+		if opClose == opcode {
+			logger.Debugf("Client closed the connection.")
+			break
+		}
+
+		if opText == opcode {
+			bridge.control(logger, payload, conn)
+			continue
+		}
+
+		if _, err := oi.LongWrite(conn, payload); nil != err {
+			logger.Errorf("Problem writing to the target: %v", err)
+			code = closeInternalError
+			break
+		}
+	}
+
+	conn.Close()
+	queue.close()
+	ws.close(code, "")
+	<-written
+}
+
+// control handles a control message from the client.
+func (bridge *Bridge) control(logger telnet.Logger, payload []byte, conn *telnet.Conn) {
+
+	var message internalControlMessage
+	if err := json.Unmarshal(payload, &message); nil != err {
+		logger.Debugf("Ignoring control message that is not JSON: %v", err)
+		return
+	}
+
+	switch message.Type {
+	case "resize":
+		logger.Tracef("Resized to %dx%d.", message.Cols, message.Rows)
+		if err := conn.SetWindowSize(message.Cols, message.Rows); nil != err {
+			logger.Errorf("Problem sending the window size to the target: %v", err)
+		}
+	default:
+		logger.Debugf("Ignoring control message of type %q.", message.Type)
+	}
+}
+
+func (bridge *Bridge) checkOrigin(r *http.Request) bool {
+	if fn := bridge.CheckOrigin; nil != fn {
+		return fn(r)
+	}
+
+	origin := r.Header.Get("Origin")
+	if "" == origin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if nil != err {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func (bridge *Bridge) logger() telnet.Logger {
+	if logger := bridge.Logger; nil != logger {
+		return logger
+	}
+
+	return internalDiscardLogger{}
+}
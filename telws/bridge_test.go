@@ -0,0 +1,441 @@
+package telws
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+// testListen returns a listener, for a target.
+func testListen(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	return listener
+}
+
+// testAccept accepts the connection (from the Bridge) to the target.
+func testAccept(t *testing.T, listener net.Listener) net.Conn {
+	t.Helper()
+
+	conn, err := listener.Accept()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	return conn
+}
+
+// testRequest sends a WebSocket opening handshake to 'server', with the (additional) headers
+// 'header'; and returns the connection, and the response.
+func testRequest(t *testing.T, server *httptest.Server, method string, path string, header map[string]string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	r, err := http.NewRequest(method, server.URL+path, nil)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, value := range header {
+		if "" == value {
+			r.Header.Del(name)
+			continue
+		}
+		r.Header.Set(name, value)
+	}
+
+	if err := r.Write(conn); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, r)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return conn, reader, response
+}
+
+// testConnect connects (with a WebSocket) to the Bridge 'bridge'.
+func testConnect(t *testing.T, bridge *Bridge) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	server := httptest.NewServer(bridge)
+	t.Cleanup(server.Close)
+
+	conn, reader, response := testRequest(t, server, http.MethodGet, "/", nil)
+	if expected, actual := http.StatusSwitchingProtocols, response.StatusCode; expected != actual {
+		t.Fatalf("Expected status %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"); expected != actual {
+		t.Errorf("Expected Sec-WebSocket-Accept %q, but actually got %q.", expected, actual)
+	}
+
+	return conn, reader
+}
+
+// testReadBinary reads binary messages, until it has gotten 'n' bytes.
+func testReadBinary(t *testing.T, reader io.Reader, n int) []byte {
+	t.Helper()
+
+	var p []byte
+	for len(p) < n {
+		opcode, payload := testReadFrame(t, reader)
+		if opBinary != opcode {
+			t.Fatalf("Expected a binary message, but actually got opcode %d: %q", opcode, payload)
+		}
+		p = append(p, payload...)
+	}
+
+	return p
+}
+
+func TestBridgeEcho(t *testing.T) {
+
+	listener := testListen(t)
+	go telnet.Serve(listener, telnet.EchoHandler)
+
+	conn, reader := testConnect(t, &Bridge{Target: listener.Addr().String()})
+
+	// (The IAC gets to the echo handler escaped; and comes back un-escaped.)
+	message := "hello \xff world\r\n"
+	conn.Write(testFrame(true, opBinary, []byte(message)))
+
+	if expected, actual := message, string(testReadBinary(t, reader, len(message))); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestBridgeData(t *testing.T) {
+
+	listener := testListen(t)
+
+	conn, reader := testConnect(t, &Bridge{Target: listener.Addr().String()})
+	target := testAccept(t, listener)
+
+	conn.Write(testFrame(true, opBinary, []byte{'a', 0xFF, 'b'}))
+
+	expected := []byte{'a', telnet.IAC, telnet.IAC, 'b'}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+
+	// The commands (and the escaping) are taken out of what the target sends.
+	target.Write([]byte{'c', telnet.IAC, telnet.IAC, telnet.IAC, telnet.NOP, 'd'})
+
+	if expected, actual := "c\xffd", string(testReadBinary(t, reader, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestBridgeResize(t *testing.T) {
+
+	listener := testListen(t)
+
+	conn, _ := testConnect(t, &Bridge{Target: listener.Addr().String()})
+	target := testAccept(t, listener)
+
+	// NAWS is offered once the size is known; and the size is sent once the target agrees to it.
+	conn.Write(testFrame(true, opText, []byte(`{"type":"resize","cols":100,"rows":30}`)))
+
+	expected := []byte{telnet.IAC, telnet.WILL, telnet.OptNAWS}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+
+	target.Write([]byte{telnet.IAC, telnet.DO, telnet.OptNAWS})
+
+	expected = []byte{telnet.IAC, telnet.SB, telnet.OptNAWS, 0, 100, 0, 30, telnet.IAC, telnet.SE}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+
+	// Unknown control messages are ignored.
+	conn.Write(testFrame(true, opText, []byte(`{"type":"bell"}`)))
+	conn.Write(testFrame(true, opText, []byte(`not json`)))
+	conn.Write(testFrame(true, opText, []byte(`{"type":"resize","cols":255,"rows":24}`)))
+
+	expected = []byte{telnet.IAC, telnet.SB, telnet.OptNAWS, 0, telnet.IAC, telnet.IAC, 0, 24, telnet.IAC, telnet.SE}
+	if actual := testReadExactly(t, target, len(expected)); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected the target to get %v, but actually got %v.", expected, actual)
+	}
+}
+
+func TestBridgeClose(t *testing.T) {
+
+	t.Run("target", func(t *testing.T) {
+		listener := testListen(t)
+
+		_, reader := testConnect(t, &Bridge{Target: listener.Addr().String()})
+		target := testAccept(t, listener)
+
+		target.Close()
+
+		opcode, payload := testReadFrame(t, reader)
+		if expected, actual := opClose, opcode; expected != actual {
+			t.Errorf("Expected opcode %d, but actually got %d.", expected, actual)
+		}
+		if expected, actual := []byte{0x03, 0xE8}, payload; !bytes.Equal(expected, actual) {
+			t.Errorf("Expected close payload %v, but actually got %v.", expected, actual)
+		}
+	})
+
+	t.Run("client", func(t *testing.T) {
+		listener := testListen(t)
+
+		conn, _ := testConnect(t, &Bridge{Target: listener.Addr().String()})
+		target := testAccept(t, listener)
+
+		conn.Write(testFrame(true, opClose, []byte{0x03, 0xE8}))
+
+		if _, err := target.Read(make([]byte, 1)); io.EOF != err {
+			t.Errorf("Expected the target to be closed, but actually got: (%T) %v", err, err)
+		}
+	})
+}
+
+func TestBridgeRequest(t *testing.T) {
+
+	listener := testListen(t)
+	go telnet.Serve(listener, telnet.EchoHandler)
+
+	refused := testListen(t)
+	refusedAddr := refused.Addr().String()
+	refused.Close()
+
+	bridge := &Bridge{
+		Route: func(r *http.Request) (string, error) {
+			switch r.URL.Query().Get("device") {
+			case "echo":
+				return listener.Addr().String(), nil
+			case "down":
+				return refusedAddr, nil
+			case "secret":
+				return "", errors.New("not allowed")
+			default:
+				return "", ErrUnauthorized
+			}
+		},
+	}
+
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	tests := []struct {
+		Method   string
+		Path     string
+		Header   map[string]string
+		Expected int
+	}{
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Expected: http.StatusSwitchingProtocols,
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/?device=echo",
+			Expected: http.StatusMethodNotAllowed,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Header:   map[string]string{"Upgrade": ""},
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Header:   map[string]string{"Sec-WebSocket-Version": "8"},
+			Expected: http.StatusUpgradeRequired,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Header:   map[string]string{"Sec-WebSocket-Key": ""},
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Header:   map[string]string{"Origin": "https://evil.example.com"},
+			Expected: http.StatusForbidden,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=echo",
+			Header:   map[string]string{"Origin": "http://" + server.Listener.Addr().String()},
+			Expected: http.StatusSwitchingProtocols,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/",
+			Expected: http.StatusUnauthorized,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=secret",
+			Expected: http.StatusForbidden,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/?device=down",
+			Expected: http.StatusBadGateway,
+		},
+	}
+
+	for testNumber, test := range tests {
+		_, _, response := testRequest(t, server, test.Method, test.Path, test.Header)
+
+		if expected, actual := test.Expected, response.StatusCode; expected != actual {
+			t.Errorf("For test #%d, expected status %d, but actually got %d; for %s %s %v", testNumber, expected, actual, test.Method, test.Path, test.Header)
+		}
+	}
+}
+
+func TestBridgeDialTimeout(t *testing.T) {
+
+	bridge := &Bridge{
+		Target: "10.255.255.1:23",
+		Dial: func(ctx context.Context, addr string) (*telnet.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		DialTimeout: 50 * time.Millisecond,
+	}
+
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	_, _, response := testRequest(t, server, http.MethodGet, "/", nil)
+	if expected, actual := http.StatusBadGateway, response.StatusCode; expected != actual {
+		t.Errorf("Expected status %d, but actually got %d.", expected, actual)
+	}
+}
+
+func TestBridgeDialCancelled(t *testing.T) {
+
+	dialing := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	bridge := &Bridge{
+		Target: "10.255.255.1:23",
+		Dial: func(ctx context.Context, addr string) (*telnet.Conn, error) {
+			close(dialing)
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		},
+		DialTimeout: time.Minute,
+	}
+
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	select {
+	case <-dialing:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the Bridge to dial the target, but it did not.")
+	}
+
+	// The browser going away cancels the dial.
+	conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the dial to be cancelled, but it was not.")
+	}
+}
+
+func TestBridgeBackpressure(t *testing.T) {
+
+	listener := testListen(t)
+
+	conn, reader := testConnect(t, &Bridge{
+		Target:       listener.Addr().String(),
+		Backpressure: BackpressureDrop,
+		BufferSize:   16,
+	})
+	target := testAccept(t, listener)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	target.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// While the client is not reading, the target can keep on sending (without being blocked);
+	// and what the client does not keep up with is dropped.
+	const total = 8 << 20
+	chunk := []byte(strings.Repeat("x", 4096))
+	for sent := 0; sent < total; sent += len(chunk) {
+		if _, err := target.Write(chunk); nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+	}
+	target.Write([]byte("!"))
+	target.Close()
+
+	var received int
+	for {
+		opcode, payload := testReadFrame(t, reader)
+		if opClose == opcode {
+			break
+		}
+		received += len(payload)
+	}
+
+	if total <= received {
+		t.Errorf("Expected some of the %d bytes to be dropped, but actually got %d.", total, received)
+	}
+}
+
+func testReadExactly(t *testing.T, conn net.Conn, n int) []byte {
+	t.Helper()
+
+	p := make([]byte, n)
+	if _, err := io.ReadFull(conn, p); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return p
+}
@@ -0,0 +1,41 @@
+/*
+Package telws provides a bridge (for the telnet package) between WebSockets and TELNET; so that
+a terminal in a web browser (such as xterm.js) can be used with a TELNET server.
+
+# Bridge
+
+Here is an example usage:
+
+	package main
+
+	import (
+		"github.com/wouteroostervld/go-telnet/telws"
+
+		"net/http"
+	)
+
+	func main() {
+
+		bridge := &telws.Bridge{
+			Target: "10.0.0.7:23",
+		}
+
+		http.Handle("/telnet", bridge)
+
+		if err := http.ListenAndServe(":8080", nil); nil != err {
+			panic(err)
+		}
+	}
+
+And, in the browser, with xterm.js:
+
+	const socket = new WebSocket("wss://" + location.host + "/telnet");
+	socket.binaryType = "arraybuffer";
+
+	socket.onmessage = (event) => term.write(new Uint8Array(event.data));
+	term.onData((data) => socket.send(new TextEncoder().encode(data)));
+	term.onResize(({cols, rows}) => socket.send(JSON.stringify({type: "resize", cols, rows})));
+
+Note that what the user types is sent as binary messages; as text messages are control messages.
+*/
+package telws
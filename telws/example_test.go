@@ -0,0 +1,29 @@
+package telws_test
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+	"github.com/wouteroostervld/go-telnet/telws"
+
+	"net"
+	"net/http"
+)
+
+// This has (WebSocket) clients of /telnet talk to the echo handler. (Which is handy for trying
+// out a web UI.)
+func ExampleBridge() {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		panic(err)
+	}
+	go telnet.Serve(listener, telnet.EchoHandler)
+
+	http.Handle("/telnet", &telws.Bridge{
+		Target:       listener.Addr().String(),
+		Backpressure: telws.BackpressureDrop,
+	})
+
+	if err := http.ListenAndServe(":8080", nil); nil != err {
+		panic(err)
+	}
+}
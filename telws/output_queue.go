@@ -0,0 +1,108 @@
+package telws
+
+import (
+	"sync"
+)
+
+// internalOutputQueue holds the output of the target, until it is written to the client; up to
+// 'limit' bytes of it.
+//
+// What happens when the queue is full (i.e., when the client is slower than the target) depends
+// on 'drop'. If 'drop' is false, then push waits until there is room; so that the target is not
+// read from until the client catches up. If 'drop' is true, then what does not fit is thrown away.
+//
+// (If nothing is waiting to be written, then whatever is pushed is taken whole; even if it is
+// bigger than 'limit'.)
+type internalOutputQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	size   int
+	limit  int
+	drop   bool
+	closed bool
+}
+
+func newOutputQueue(limit int, drop bool) *internalOutputQueue {
+	queue := internalOutputQueue{
+		limit: limit,
+		drop:  drop,
+	}
+	queue.cond = sync.NewCond(&queue.mutex)
+
+	return &queue
+}
+
+// push adds (a copy of) 'p' to the queue. It returns how many bytes (of 'p') were thrown away.
+func (queue *internalOutputQueue) push(p []byte) int {
+	if len(p) <= 0 {
+		return 0
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for !queue.closed && 0 < queue.size && queue.limit < queue.size+len(p) {
+		// (All of 'p' is thrown away, rather than just what does not fit; as whatever the
+		// target sent in one go, such as an escape sequence, is more likely to make sense whole.)
+		if queue.drop {
+			return len(p)
+		}
+
+		queue.cond.Wait()
+	}
+	if queue.closed {
+		return 0
+	}
+
+	queue.add(p)
+	return 0
+}
+
+func (queue *internalOutputQueue) add(p []byte) {
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+
+	queue.chunks = append(queue.chunks, chunk)
+	queue.size += len(chunk)
+	queue.cond.Broadcast()
+}
+
+// pop takes everything that is in the queue; waiting for there to be something, if there is not
+// yet. Once the queue is closed (and empty), pop returns false.
+func (queue *internalOutputQueue) pop() ([]byte, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	for 0 == len(queue.chunks) && !queue.closed {
+		queue.cond.Wait()
+	}
+	if 0 == len(queue.chunks) {
+		return nil, false
+	}
+
+	var p []byte
+	if 1 == len(queue.chunks) {
+		p = queue.chunks[0]
+	} else {
+		p = make([]byte, 0, queue.size)
+		for _, chunk := range queue.chunks {
+			p = append(p, chunk...)
+		}
+	}
+
+	queue.chunks = nil
+	queue.size = 0
+	queue.cond.Broadcast()
+
+	return p, true
+}
+
+// close closes the queue; after which pop returns whatever is left, and push does not add anything
+// (nor wait) anymore.
+func (queue *internalOutputQueue) close() {
+	queue.mutex.Lock()
+	queue.closed = true
+	queue.cond.Broadcast()
+	queue.mutex.Unlock()
+}
@@ -0,0 +1,99 @@
+package telws
+
+import (
+	"time"
+
+	"testing"
+)
+
+func TestOutputQueueDrop(t *testing.T) {
+
+	queue := newOutputQueue(8, true)
+
+	tests := []struct {
+		Pushed          string
+		ExpectedDropped int
+	}{
+		{
+			Pushed:          "abcde",
+			ExpectedDropped: 0,
+		},
+		{
+			Pushed:          "fgh",
+			ExpectedDropped: 0,
+		},
+		{
+			Pushed:          "i",
+			ExpectedDropped: 1,
+		},
+		{
+			Pushed:          "jklmno",
+			ExpectedDropped: 6,
+		},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.ExpectedDropped, queue.push([]byte(test.Pushed)); expected != actual {
+			t.Errorf("For test #%d, expected %d bytes to be dropped, but actually got %d; for pushed: %q", testNumber, expected, actual, test.Pushed)
+		}
+	}
+
+	if p, ok := queue.pop(); !ok || "abcdefgh" != string(p) {
+		t.Errorf("Expected %q, but actually got %q (%t).", "abcdefgh", p, ok)
+	}
+
+	// Once there is room again, nothing is dropped. (Not even what is bigger than the limit.)
+	if expected, actual := 0, queue.push([]byte("0123456789")); expected != actual {
+		t.Errorf("Expected %d bytes to be dropped, but actually got %d.", expected, actual)
+	}
+
+	queue.close()
+
+	if p, ok := queue.pop(); !ok || "0123456789" != string(p) {
+		t.Errorf("Expected %q, but actually got %q (%t).", "0123456789", p, ok)
+	}
+	if p, ok := queue.pop(); ok {
+		t.Errorf("Expected the queue to be done, but actually got %q.", p)
+	}
+}
+
+func TestOutputQueueBlock(t *testing.T) {
+
+	queue := newOutputQueue(8, false)
+
+	queue.push([]byte("abcdefgh"))
+
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		queue.push([]byte("ijk"))
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatalf("Expected the push to wait for room, but it did not.")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if p, ok := queue.pop(); !ok || "abcdefgh" != string(p) {
+		t.Errorf("Expected %q, but actually got %q (%t).", "abcdefgh", p, ok)
+	}
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the push to be done, once there was room, but it was not.")
+	}
+
+	if p, ok := queue.pop(); !ok || "ijk" != string(p) {
+		t.Errorf("Expected %q, but actually got %q (%t).", "ijk", p, ok)
+	}
+
+	// Closing the queue stops a push from waiting.
+	queue.push([]byte("abcdefgh"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		queue.close()
+	}()
+	queue.push([]byte("ijk"))
+}
@@ -0,0 +1,253 @@
+package telws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is what the Sec-WebSocket-Key of the client is combined with, to get the
+// Sec-WebSocket-Accept of the server. (See RFC 6455, section 1.3.)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes. (See RFC 6455, section 5.2.)
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// WebSocket close codes. (See RFC 6455, section 7.4.1.)
+const (
+	closeNormal          = 1000
+	closeProtocolError   = 1002
+	closeMessageTooBig   = 1009
+	closeInternalError   = 1011
+	maxControlPayloadLen = 125
+)
+
+// maxMessageSize is the largest message accepted from the client.
+const maxMessageSize = 1 << 20
+
+// closeTimeout is how long to wait for the close frame to be written, when closing. (This also
+// stops a write that is stuck, waiting on a client that is not reading.)
+const closeTimeout = time.Second
+
+var (
+	errProtocol      = errors.New("telws: websocket protocol error")
+	errMessageTooBig = errors.New("telws: websocket message too big")
+	errNotHijackable = errors.New("telws: http.ResponseWriter cannot be hijacked")
+)
+
+// acceptKey returns the Sec-WebSocket-Accept for the Sec-WebSocket-Key 'key'.
+func acceptKey(key string) string {
+	digest := sha1.Sum([]byte(key + websocketGUID))
+
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// headerHas returns whether the (comma separated) header 'name' has the token 'token' in it,
+// ignoring case.
+func headerHas(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(token, strings.TrimSpace(field)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isUpgrade returns whether 'r' asks to be upgraded to a WebSocket.
+func isUpgrade(r *http.Request) bool {
+	return headerHas(r.Header, "Connection", "upgrade") && headerHas(r.Header, "Upgrade", "websocket")
+}
+
+// internalWebSocket is the server side of a WebSocket connection; just as much of RFC 6455
+// as the Bridge needs. (No extensions, and no subprotocols.)
+type internalWebSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+}
+
+// upgrade hijacks the connection of 'r', and finishes (the server side of) the WebSocket
+// opening handshake on it. 'key' is the Sec-WebSocket-Key of 'r'.
+func upgrade(w http.ResponseWriter, r *http.Request, key string) (*internalWebSocket, error) {
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errNotHijackable
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if nil != err {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n" +
+		"\r\n"
+	if _, err := io.WriteString(conn, response); nil != err {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := internalWebSocket{
+		conn:   conn,
+		reader: buffered.Reader,
+	}
+
+	return &ws, nil
+}
+
+// readMessage reads the next (data) message from the client; putting together its fragments,
+// answering any pings that come along the way, and skipping any pongs.
+//
+// If the client closes the WebSocket, then readMessage returns opClose (and the payload of the
+// close frame).
+func (ws *internalWebSocket) readMessage() (byte, []byte, error) {
+
+	var opcode byte
+	var message []byte
+
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(ws.reader, header[:]); nil != err {
+			return 0, nil, err
+		}
+
+		fin := 0 != header[0]&0x80
+		op := header[0] & 0x0F
+		masked := 0 != header[1]&0x80
+		length := uint64(header[1] & 0x7F)
+
+		// There are no extensions, so none of the RSV bits can be set. And everything
+		// a client sends must be masked.
+		if 0 != header[0]&0x70 || !masked {
+			return 0, nil, errProtocol
+		}
+
+		switch length {
+		case 126:
+			var extended [2]byte
+			if _, err := io.ReadFull(ws.reader, extended[:]); nil != err {
+				return 0, nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended[:]))
+		case 127:
+			var extended [8]byte
+			if _, err := io.ReadFull(ws.reader, extended[:]); nil != err {
+				return 0, nil, err
+			}
+			length = binary.BigEndian.Uint64(extended[:])
+		}
+
+		isControl := 0 != op&0x8
+		if isControl && (!fin || maxControlPayloadLen < length) {
+			return 0, nil, errProtocol
+		}
+		if uint64(maxMessageSize-len(message)) < length {
+			return 0, nil, errMessageTooBig
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(ws.reader, mask[:]); nil != err {
+			return 0, nil, err
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); nil != err {
+			return 0, nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); nil != err {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return opClose, payload, nil
+		case opContinuation:
+			if 0 == opcode {
+				return 0, nil, errProtocol
+			}
+			message = append(message, payload...)
+		case opText, opBinary:
+			if 0 != opcode {
+				return 0, nil, errProtocol
+			}
+			opcode, message = op, payload
+		default:
+			return 0, nil, errProtocol
+		}
+
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// writeFrame writes a (single, unfragmented) frame to the client.
+func (ws *internalWebSocket) writeFrame(opcode byte, payload []byte) error {
+
+	p := make([]byte, 0, 10+len(payload))
+
+	p = append(p, 0x80|opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		p = append(p, byte(length))
+	case length <= 0xFFFF:
+		p = append(p, 126, byte(length>>8), byte(length))
+	default:
+		p = append(p, 127)
+		p = binary.BigEndian.AppendUint64(p, uint64(length))
+	}
+	p = append(p, payload...)
+
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	_, err := ws.conn.Write(p)
+	return err
+}
+
+// close sends the client a close frame, with the close code 'code', and then closes the
+// connection. (Only the first call does anything.)
+func (ws *internalWebSocket) close(code int, reason string) {
+	ws.closeOnce.Do(func() {
+		if maxControlPayloadLen-2 < len(reason) {
+			reason = reason[:maxControlPayloadLen-2]
+		}
+
+		ws.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+
+		payload := append([]byte{byte(code >> 8), byte(code)}, reason...)
+		ws.writeFrame(opClose, payload)
+
+		ws.conn.Close()
+	})
+}
@@ -0,0 +1,335 @@
+package telws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+// testFrame returns a (masked) frame, like what a client sends.
+func testFrame(fin bool, opcode byte, payload []byte) []byte {
+	var p []byte
+
+	b := opcode
+	if fin {
+		b |= 0x80
+	}
+	p = append(p, b)
+
+	switch length := len(payload); {
+	case length <= 125:
+		p = append(p, 0x80|byte(length))
+	case length <= 0xFFFF:
+		p = append(p, 0x80|126, byte(length>>8), byte(length))
+	default:
+		p = append(p, 0x80|127)
+		p = binary.BigEndian.AppendUint64(p, uint64(length))
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	p = append(p, mask[:]...)
+	for i, datum := range payload {
+		p = append(p, datum^mask[i%4])
+	}
+
+	return p
+}
+
+// testReadFrame reads a (unmasked) frame, like what the server sends.
+func testReadFrame(t *testing.T, reader io.Reader) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if 0 == header[0]&0x80 {
+		t.Fatalf("Expected the frame to be the final fragment, but it was not: %v", header)
+	}
+	if 0 != header[1]&0x80 {
+		t.Fatalf("Expected the frame not to be masked, but it was: %v", header)
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		io.ReadFull(reader, extended[:])
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		io.ReadFull(reader, extended[:])
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	return header[0] & 0x0F, payload
+}
+
+func testWebSocketPipe(t *testing.T) (*internalWebSocket, net.Conn) {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	remote.SetDeadline(time.Now().Add(time.Second))
+
+	ws := internalWebSocket{
+		conn:   local,
+		reader: bufio.NewReader(local),
+	}
+
+	return &ws, remote
+}
+
+func TestAcceptKey(t *testing.T) {
+
+	// The example from RFC 6455, section 1.3.
+	if expected, actual := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestIsUpgrade(t *testing.T) {
+
+	tests := []struct {
+		Connection string
+		Upgrade    string
+		Expected   bool
+	}{
+		{
+			Connection: "Upgrade",
+			Upgrade:    "websocket",
+			Expected:   true,
+		},
+		{
+			Connection: "keep-alive, Upgrade",
+			Upgrade:    "WebSocket",
+			Expected:   true,
+		},
+		{
+			Connection: "keep-alive",
+			Upgrade:    "websocket",
+			Expected:   false,
+		},
+		{
+			Connection: "Upgrade",
+			Upgrade:    "h2c",
+			Expected:   false,
+		},
+	}
+
+	for testNumber, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.Header.Set("Connection", test.Connection)
+		r.Header.Set("Upgrade", test.Upgrade)
+
+		if expected, actual := test.Expected, isUpgrade(r); expected != actual {
+			t.Errorf("For test #%d, expected %t, but actually got %t; for Connection: %q, Upgrade: %q", testNumber, expected, actual, test.Connection, test.Upgrade)
+		}
+	}
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+
+	tests := []struct {
+		Frames          [][]byte
+		ExpectedOpcode  byte
+		ExpectedMessage string
+	}{
+		{
+			Frames: [][]byte{
+				testFrame(true, opBinary, []byte("hello")),
+			},
+			ExpectedOpcode:  opBinary,
+			ExpectedMessage: "hello",
+		},
+		{
+			Frames: [][]byte{
+				testFrame(true, opText, []byte(`{"type":"resize","cols":80,"rows":24}`)),
+			},
+			ExpectedOpcode:  opText,
+			ExpectedMessage: `{"type":"resize","cols":80,"rows":24}`,
+		},
+		{
+			Frames: [][]byte{
+				testFrame(true, opBinary, bytes.Repeat([]byte("x"), 300)),
+			},
+			ExpectedOpcode:  opBinary,
+			ExpectedMessage: strings.Repeat("x", 300),
+		},
+		{
+			Frames: [][]byte{
+				testFrame(true, opBinary, bytes.Repeat([]byte("y"), 70000)),
+			},
+			ExpectedOpcode:  opBinary,
+			ExpectedMessage: strings.Repeat("y", 70000),
+		},
+		{
+			Frames: [][]byte{
+				testFrame(false, opBinary, []byte("hel")),
+				testFrame(true, opPong, nil),
+				testFrame(false, opContinuation, []byte("lo ")),
+				testFrame(true, opContinuation, []byte("world")),
+			},
+			ExpectedOpcode:  opBinary,
+			ExpectedMessage: "hello world",
+		},
+		{
+			Frames: [][]byte{
+				testFrame(true, opClose, []byte{0x03, 0xE8}),
+			},
+			ExpectedOpcode:  opClose,
+			ExpectedMessage: "\x03\xE8",
+		},
+	}
+
+	for testNumber, test := range tests {
+		ws, remote := testWebSocketPipe(t)
+
+		go func() {
+			for _, frame := range test.Frames {
+				remote.Write(frame)
+			}
+		}()
+
+		opcode, message, err := ws.readMessage()
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected, actual := test.ExpectedOpcode, opcode; expected != actual {
+			t.Errorf("For test #%d, expected opcode %d, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := test.ExpectedMessage, string(message); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestWebSocketReadMessagePing(t *testing.T) {
+
+	ws, remote := testWebSocketPipe(t)
+
+	go remote.Write(testFrame(true, opPing, []byte("are you there")))
+
+	go ws.readMessage()
+
+	opcode, payload := testReadFrame(t, remote)
+	if expected, actual := opPong, opcode; expected != actual {
+		t.Errorf("Expected opcode %d, but actually got %d.", expected, actual)
+	}
+	if expected, actual := "are you there", string(payload); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestWebSocketReadMessageError(t *testing.T) {
+
+	unmasked := testFrame(true, opBinary, []byte("hi"))
+	unmasked[1] &^= 0x80
+	unmasked = append(unmasked[:2], []byte("hi")...)
+
+	tests := []struct {
+		Frames   [][]byte
+		Expected error
+	}{
+		{
+			Frames:   [][]byte{unmasked},
+			Expected: errProtocol,
+		},
+		{
+			Frames: [][]byte{
+				testFrame(true, opContinuation, []byte("lo")),
+			},
+			Expected: errProtocol,
+		},
+		{
+			Frames: [][]byte{
+				testFrame(false, opBinary, []byte("hel")),
+				testFrame(true, opBinary, []byte("lo")),
+			},
+			Expected: errProtocol,
+		},
+		{
+			Frames: [][]byte{
+				testFrame(false, opPing, []byte("hi")),
+			},
+			Expected: errProtocol,
+		},
+		{
+			Frames: [][]byte{
+				append([]byte{0x80 | opBinary, 0x80 | 127}, 0, 0, 0, 0, 0x10, 0, 0, 0),
+			},
+			Expected: errMessageTooBig,
+		},
+	}
+
+	for testNumber, test := range tests {
+		ws, remote := testWebSocketPipe(t)
+
+		go func() {
+			for _, frame := range test.Frames {
+				remote.Write(frame)
+			}
+		}()
+
+		if _, _, err := ws.readMessage(); test.Expected != err {
+			t.Errorf("For test #%d, expected error %v, but actually got: (%T) %v", testNumber, test.Expected, err, err)
+		}
+	}
+}
+
+func TestWebSocketWriteFrame(t *testing.T) {
+
+	tests := []struct {
+		Payload        []byte
+		ExpectedHeader []byte
+	}{
+		{
+			Payload:        []byte("hello"),
+			ExpectedHeader: []byte{0x82, 5},
+		},
+		{
+			Payload:        bytes.Repeat([]byte("x"), 300),
+			ExpectedHeader: []byte{0x82, 126, 0x01, 0x2C},
+		},
+		{
+			Payload:        bytes.Repeat([]byte("y"), 70000),
+			ExpectedHeader: []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x11, 0x70},
+		},
+	}
+
+	for testNumber, test := range tests {
+		ws, remote := testWebSocketPipe(t)
+
+		go ws.writeFrame(opBinary, test.Payload)
+
+		p := make([]byte, len(test.ExpectedHeader)+len(test.Payload))
+		if _, err := io.ReadFull(remote, p); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		if expected, actual := test.ExpectedHeader, p[:len(test.ExpectedHeader)]; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected header %v, but actually got %v.", testNumber, expected, actual)
+		}
+		if !bytes.Equal(test.Payload, p[len(test.ExpectedHeader):]) {
+			t.Errorf("For test #%d, expected the payload to be written as-is, but it was not.", testNumber)
+		}
+	}
+}
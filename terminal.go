@@ -0,0 +1,275 @@
+package telnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Color is one of the (8) colors of ANSI (i.e., ECMA-48) terminals; for Terminal.SetColor.
+// (ColorDefault is whatever color the terminal uses when none has been set.)
+type Color int
+
+const (
+	ColorDefault Color = iota
+	ColorBlack
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+)
+
+// code returns the SGR parameter for the Color; as a foreground color ("30" to "37", or "39"),
+// with 'base' 30, or as a background color ("40" to "47", or "49"), with 'base' 40.
+func (color Color) code(base int) string {
+	if color <= ColorDefault || ColorWhite < color {
+		return strconv.Itoa(base + 9)
+	}
+	return strconv.Itoa(base + int(color-ColorBlack))
+}
+
+// Style is the (SGR) attributes of text, for Terminal.SetStyle; which can be combined, such as:
+//
+//	StyleBold | StyleUnderline
+//
+// (StyleNormal is none of them.)
+type Style int
+
+const StyleNormal Style = 0
+
+const (
+	StyleBold Style = 1 << iota
+	StyleUnderline
+	StyleBlink
+	StyleReverse
+)
+
+// A Terminal writes to a Conn whose peer is an ANSI (i.e., ECMA-48, or VT100) terminal; with
+// methods for moving the cursor, clearing the screen, and setting colors, so that the escape
+// sequences for them do not have to be written by hand. For example:
+//
+//	terminal := telnet.NewTerminal(conn)
+//
+//	terminal.ClearScreen()
+//	terminal.SetColor(telnet.ColorWhite, telnet.ColorBlue)
+//	terminal.SetStyle(telnet.StyleBold)
+//	terminal.WriteString("Welcome!")
+//	terminal.Reset()
+//	terminal.MoveTo(3, 1)
+//
+// If the peer is a dumb terminal (i.e., one that does not understand escape sequences) then the
+// Terminal does not send any; its methods (other than Write, and WriteString) do nothing, and
+// whatever escape sequences are in what is written are stripped out (as with StripANSI). Whether
+// the peer is one is from the terminal type it told with TERMINAL-TYPE (see RequestTerminalType);
+// such as "DUMB", or "UNKNOWN". (Which can be overridden with SetDumb.) A peer that has not
+// told its terminal type is taken to be an ANSI terminal; as most telnet clients are.
+//
+// A Terminal is safe for concurrent use; although (as with Conn) what is written by more than one
+// call can be interleaved with what other goroutines write.
+type Terminal struct {
+	conn *Conn
+
+	mutex    sync.Mutex
+	dumb     *bool
+	stripper internalANSIStripper
+}
+
+// NewTerminal returns a Terminal that writes to 'conn'.
+func NewTerminal(conn *Conn) *Terminal {
+	return &Terminal{conn: conn}
+}
+
+// Conn returns the Conn the Terminal writes to.
+func (terminal *Terminal) Conn() *Conn {
+	return terminal.conn
+}
+
+// SetDumb overrides whether the peer is taken to be a dumb terminal; rather than going by the
+// terminal type it told, with TERMINAL-TYPE. (See Terminal.)
+func (terminal *Terminal) SetDumb(dumb bool) {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	terminal.dumb = &dumb
+}
+
+// Dumb returns whether the peer is taken to be a dumb terminal; in which case no escape sequences
+// are sent to it. (See Terminal.)
+func (terminal *Terminal) Dumb() bool {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	return terminal.dumbLocked()
+}
+
+// dumbLocked is Dumb; with the mutex held.
+func (terminal *Terminal) dumbLocked() bool {
+	if nil != terminal.dumb {
+		return *terminal.dumb
+	}
+
+	name, ok := terminal.conn.PeerTerminalType()
+	if !ok {
+		return false
+	}
+	return dumbTerminalType(name)
+}
+
+// dumbTerminalType returns whether the terminal type (as told with TERMINAL-TYPE) is that of a
+// terminal that does not understand escape sequences.
+func dumbTerminalType(name string) bool {
+	switch strings.ToUpper(name) {
+	case "DUMB", "UNKNOWN", "NETWORK", "GLASS":
+		return true
+	default:
+		return false
+	}
+}
+
+// Write writes 'p' to the Conn; with any escape sequences in it stripped out, if the peer is a
+// dumb terminal. (In which case 'n' is still len(p); if it was all written.)
+func (terminal *Terminal) Write(p []byte) (n int, err error) {
+	terminal.mutex.Lock()
+	dumb := terminal.dumbLocked()
+	stripped := p
+	if dumb {
+		stripped = terminal.stripper.Write(p)
+	}
+	terminal.mutex.Unlock()
+
+	if !dumb {
+		return terminal.conn.Write(p)
+	}
+
+	if _, err := terminal.conn.Write(stripped); nil != err {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteString is Write; for a string.
+func (terminal *Terminal) WriteString(s string) (n int, err error) {
+	return terminal.Write([]byte(s))
+}
+
+// control sends the escape sequence 's'; unless the peer is a dumb terminal.
+func (terminal *Terminal) control(s string) error {
+	if terminal.Dumb() {
+		return nil
+	}
+
+	_, err := terminal.conn.Write([]byte(s))
+	return err
+}
+
+// MoveTo moves the cursor to the 'row' and 'column'; where the top-left of the screen is row 1,
+// column 1. (CUP; ESC [ row ; column H.)
+func (terminal *Terminal) MoveTo(row int, column int) error {
+	if row < 1 {
+		row = 1
+	}
+	if column < 1 {
+		column = 1
+	}
+	return terminal.control(fmt.Sprintf("\x1b[%d;%dH", row, column))
+}
+
+// MoveUp moves the cursor up 'n' rows. (CUU.)
+func (terminal *Terminal) MoveUp(n int) error {
+	return terminal.move(n, 'A')
+}
+
+// MoveDown moves the cursor down 'n' rows. (CUD.)
+func (terminal *Terminal) MoveDown(n int) error {
+	return terminal.move(n, 'B')
+}
+
+// MoveRight moves the cursor right 'n' columns. (CUF.)
+func (terminal *Terminal) MoveRight(n int) error {
+	return terminal.move(n, 'C')
+}
+
+// MoveLeft moves the cursor left 'n' columns. (CUB.)
+func (terminal *Terminal) MoveLeft(n int) error {
+	return terminal.move(n, 'D')
+}
+
+func (terminal *Terminal) move(n int, final byte) error {
+	if n <= 0 {
+		return nil
+	}
+	return terminal.control(fmt.Sprintf("\x1b[%d%c", n, final))
+}
+
+// SaveCursor saves where the cursor is; for RestoreCursor. (DECSC; ESC 7.)
+func (terminal *Terminal) SaveCursor() error {
+	return terminal.control("\x1b7")
+}
+
+// RestoreCursor moves the cursor back to where it was when SaveCursor was called. (DECRC; ESC 8.)
+func (terminal *Terminal) RestoreCursor() error {
+	return terminal.control("\x1b8")
+}
+
+// HideCursor hides the cursor. (ESC [ ? 25 l.)
+func (terminal *Terminal) HideCursor() error {
+	return terminal.control("\x1b[?25l")
+}
+
+// ShowCursor shows the cursor; after HideCursor. (ESC [ ? 25 h.)
+func (terminal *Terminal) ShowCursor() error {
+	return terminal.control("\x1b[?25h")
+}
+
+// ClearScreen clears the (whole) screen, and moves the cursor to the top-left of it. (ED, then
+// CUP; ESC [ 2 J ESC [ H.)
+func (terminal *Terminal) ClearScreen() error {
+	return terminal.control("\x1b[2J\x1b[H")
+}
+
+// ClearLine clears the (whole) line the cursor is on; and moves the cursor to the start of it.
+// (EL; ESC [ 2 K, then a carriage return.)
+func (terminal *Terminal) ClearLine() error {
+	return terminal.control("\x1b[2K\r")
+}
+
+// ClearToEndOfLine clears the line from the cursor to the end of it. (EL; ESC [ K.)
+func (terminal *Terminal) ClearToEndOfLine() error {
+	return terminal.control("\x1b[K")
+}
+
+// SetColor sets the foreground, and background, colors of what is written after it. (SGR.)
+func (terminal *Terminal) SetColor(foreground Color, background Color) error {
+	return terminal.control("\x1b[" + foreground.code(30) + ";" + background.code(40) + "m")
+}
+
+// SetStyle sets the attributes (such as bold) of what is written after it; replacing those that
+// were set before, but leaving the colors alone. (SGR.)
+func (terminal *Terminal) SetStyle(style Style) error {
+	// (22 is neither bold nor faint, 24 is not underlined, 25 is not blinking, and 27 is not
+	// reversed.)
+	codes := []string{"22", "24", "25", "27"}
+	if 0 != style&StyleBold {
+		codes[0] = "1"
+	}
+	if 0 != style&StyleUnderline {
+		codes[1] = "4"
+	}
+	if 0 != style&StyleBlink {
+		codes[2] = "5"
+	}
+	if 0 != style&StyleReverse {
+		codes[3] = "7"
+	}
+	return terminal.control("\x1b[" + strings.Join(codes, ";") + "m")
+}
+
+// Reset sets the colors, and attributes, of what is written after it back to the terminal's
+// default. (SGR; ESC [ 0 m.)
+func (terminal *Terminal) Reset() error {
+	return terminal.control("\x1b[0m")
+}
@@ -0,0 +1,113 @@
+package telnet
+
+import (
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+
+	tests := []struct {
+		Dumb     bool
+		Fn       func(*Terminal) error
+		Expected string
+	}{
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.MoveTo(3, 12) },
+			Expected: "\x1b[3;12H",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.MoveUp(2) },
+			Expected: "\x1b[2A",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.MoveLeft(5) },
+			Expected: "\x1b[5D",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.ClearScreen() },
+			Expected: "\x1b[2J\x1b[H",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.ClearLine() },
+			Expected: "\x1b[2K\r",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.SetColor(ColorWhite, ColorBlue) },
+			Expected: "\x1b[37;44m",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.SetColor(ColorRed, ColorDefault) },
+			Expected: "\x1b[31;49m",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.SetStyle(StyleBold | StyleReverse) },
+			Expected: "\x1b[1;24;25;7m",
+		},
+		{
+			Fn:       func(terminal *Terminal) error { return terminal.Reset() },
+			Expected: "\x1b[0m",
+		},
+		{
+			Fn: func(terminal *Terminal) error {
+				_, err := terminal.WriteString("\x1b[1mhi\x1b[0m")
+				return err
+			},
+			Expected: "\x1b[1mhi\x1b[0m",
+		},
+		{
+			// (To a dumb terminal, no escape sequences are sent; and those written are stripped out.)
+			Dumb: true,
+			Fn: func(terminal *Terminal) error {
+				if err := terminal.ClearScreen(); nil != err {
+					return err
+				}
+				if err := terminal.SetColor(ColorRed, ColorBlack); nil != err {
+					return err
+				}
+				_, err := terminal.WriteString("\x1b[1mhi\x1b[0m")
+				return err
+			},
+			Expected: "hi",
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		terminal := NewTerminal(conn)
+		terminal.SetDumb(test.Dumb)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- test.Fn(terminal)
+		}()
+
+		if expected, actual := test.Expected, string(testReadExactly(t, remote, len(test.Expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if err := <-errs; nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+	}
+}
+
+func TestDumbTerminalType(t *testing.T) {
+
+	tests := []struct {
+		Name     string
+		Expected bool
+	}{
+		{Name: "DUMB", Expected: true},
+		{Name: "dumb", Expected: true},
+		{Name: "UNKNOWN", Expected: true},
+		{Name: "XTERM-256COLOR", Expected: false},
+		{Name: "VT100", Expected: false},
+		{Name: "ANSI", Expected: false},
+	}
+
+	for testNumber, test := range tests {
+		if expected, actual := test.Expected, dumbTerminalType(test.Name); expected != actual {
+			t.Errorf("For test #%d (%q), expected %t, but actually got %t.", testNumber, test.Name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package telnet
+
+import (
+	"sync"
+)
+
+// The TERMINAL-TYPE (RFC 1091) subnegotiation commands.
+const (
+	terminalTypeIS   = 0
+	terminalTypeSEND = 1
+
+	// terminalTypeLimit is how many terminal types RequestTerminalType asks for (at most); in case
+	// the peer never repeats itself, as it should, at the end of its list.
+	terminalTypeLimit = 8
+
+	// terminalTypeUnknown is what SetTerminalTypes answers with, if it was not given any.
+	terminalTypeUnknown = "UNKNOWN"
+)
+
+// SetTerminalTypes sets the terminal types (such as "xterm-256color", "xterm", "vt100") that are
+// told to the peer with the TERMINAL-TYPE option (RFC 1091); most preferred first. This is for
+// clients. For example:
+//
+//	conn.SetTerminalTypes("xterm-256color", "xterm", "vt100")
+//
+// Once the peer asks for (i.e., sends a DO for) TERMINAL-TYPE, it is agreed to; and each time the
+// peer asks for the terminal type, the next one is sent. After the last one it is sent again (which
+// is how the peer knows it has gotten to the end of the list); and then it starts over from the
+// first. (If it is not given any, then it sends "UNKNOWN".)
+//
+// (It registers an OptionHandler for TERMINAL-TYPE; replacing any that was registered before. Such
+// as by RequestTerminalType; which is for the other side.)
+func (clientConn *Conn) SetTerminalTypes(names ...string) error {
+	if len(names) <= 0 {
+		names = []string{terminalTypeUnknown}
+	}
+
+	clientConn.terminalTypeMutex.Lock()
+	clientConn.peerTerminalType = nil
+	clientConn.terminalTypeMutex.Unlock()
+
+	return clientConn.RegisterOption(OptTerminalType, &internalTerminalTypes{names: append([]string(nil), names...)})
+}
+
+// internalTerminalTypes is the (TERMINAL-TYPE) OptionHandler that SetTerminalTypes registers.
+type internalTerminalTypes struct {
+	mutex  sync.Mutex
+	sender OptionSender
+	names  []string
+
+	// next is the index of the terminal type to send next; which is len(names) when the last one
+	// has been sent (and so is sent again, to say it was the last).
+	next int
+}
+
+func (terminalTypes *internalTerminalTypes) Register(sender OptionSender) OptionSupport {
+	terminalTypes.mutex.Lock()
+	terminalTypes.sender = sender
+	terminalTypes.mutex.Unlock()
+
+	return OptionSupport{
+		Local: true,
+	}
+}
+
+func (terminalTypes *internalTerminalTypes) LocalChanged(bool) {
+	terminalTypes.mutex.Lock()
+	terminalTypes.next = 0
+	terminalTypes.mutex.Unlock()
+}
+
+func (*internalTerminalTypes) RemoteChanged(bool) {}
+
+// Subnegotiation handles:
+//
+//	IAC SB TERMINAL-TYPE SEND IAC SE
+//
+// by sending:
+//
+//	IAC SB TERMINAL-TYPE IS <terminal type> IAC SE
+func (terminalTypes *internalTerminalTypes) Subnegotiation(payload []byte) {
+	if len(payload) < 1 || terminalTypeSEND != payload[0] {
+		return
+	}
+
+	terminalTypes.mutex.Lock()
+	sender := terminalTypes.sender
+	var name string
+	if terminalTypes.next < len(terminalTypes.names) {
+		name = terminalTypes.names[terminalTypes.next]
+		terminalTypes.next++
+	} else {
+		name = terminalTypes.names[len(terminalTypes.names)-1]
+		terminalTypes.next = 0
+	}
+	terminalTypes.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	sender.SendSubnegotiation(append([]byte{terminalTypeIS}, name...))
+}
+
+// RequestTerminalType asks the peer what its terminal type is (or types are), with the
+// TERMINAL-TYPE option (RFC 1091); i.e., sends a DO for TERMINAL-TYPE. This is for servers. (Such
+// as for one that decides whether to send color codes.) For example:
+//
+//	conn.OnTerminalTypes(func(names []string) {
+//		//@TODO: Decide whether to send color codes, going by 'names'.
+//	})
+//
+//	if err := conn.RequestTerminalType(); nil != err {
+//		return err
+//	}
+//
+// Once the peer agrees to it, it asks for each of the terminal types the peer has (most preferred
+// first); until the peer repeats one (which is how it says it has gotten to the end of its list).
+// What the peer has said so far is what PeerTerminalTypes returns; and once it has gotten to the
+// end of the list, OnTerminalTypes is called.
+//
+// (It registers an OptionHandler for TERMINAL-TYPE; replacing any that was registered before. Such
+// as by SetTerminalTypes; which is for the other side.)
+func (clientConn *Conn) RequestTerminalType() error {
+	handler := &internalPeerTerminalType{told: make(chan struct{})}
+
+	clientConn.terminalTypeMutex.Lock()
+	clientConn.peerTerminalType = handler
+	clientConn.terminalTypeMutex.Unlock()
+
+	return clientConn.RegisterOption(OptTerminalType, handler)
+}
+
+// PeerTerminalType returns the (most preferred) terminal type of the peer; as it told it, with
+// TERMINAL-TYPE (see RequestTerminalType). 'ok' is false if it has not told it (or has since
+// disabled TERMINAL-TYPE).
+func (clientConn *Conn) PeerTerminalType() (name string, ok bool) {
+	names := clientConn.PeerTerminalTypes()
+	if len(names) <= 0 {
+		return "", false
+	}
+	return names[0], true
+}
+
+// PeerTerminalTypes returns the terminal types the peer has told so far, with TERMINAL-TYPE (see
+// RequestTerminalType); most preferred first. Or nil, if it has not told any.
+func (clientConn *Conn) PeerTerminalTypes() []string {
+	clientConn.terminalTypeMutex.Lock()
+	handler := clientConn.peerTerminalType
+	clientConn.terminalTypeMutex.Unlock()
+
+	if nil == handler {
+		return nil
+	}
+	return handler.types()
+}
+
+// OnTerminalTypes registers 'fn' to be called once the peer has told all of its terminal types,
+// with TERMINAL-TYPE (see RequestTerminalType); most preferred first. This replaces whatever was
+// registered before. Registering nil stops the calls.
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnTerminalTypes(fn func(names []string)) {
+	clientConn.terminalTypeMutex.Lock()
+	defer clientConn.terminalTypeMutex.Unlock()
+
+	clientConn.onTerminalTypes = fn
+}
+
+// internalPeerTerminalType is the (TERMINAL-TYPE) OptionHandler that RequestTerminalType registers.
+type internalPeerTerminalType struct {
+	mutex  sync.Mutex
+	sender OptionSender
+	names  []string
+	done   bool
+
+	// told is closed once the peer has told all of its terminal types; or has refused (or disabled)
+	// TERMINAL-TYPE. (closed is whether it has been.)
+	told   chan struct{}
+	closed bool
+}
+
+func (terminalType *internalPeerTerminalType) Register(sender OptionSender) OptionSupport {
+	terminalType.mutex.Lock()
+	terminalType.sender = sender
+	terminalType.mutex.Unlock()
+
+	return OptionSupport{
+		Remote:        true,
+		RequestRemote: true,
+	}
+}
+
+func (*internalPeerTerminalType) LocalChanged(bool) {}
+
+func (terminalType *internalPeerTerminalType) RemoteChanged(enabled bool) {
+	terminalType.mutex.Lock()
+	terminalType.names = nil
+	terminalType.done = !enabled
+	if !enabled {
+		terminalType.finish()
+	}
+	terminalType.mutex.Unlock()
+
+	if enabled {
+		terminalType.send()
+	}
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB TERMINAL-TYPE IS <terminal type> IAC SE
+func (terminalType *internalPeerTerminalType) Subnegotiation(payload []byte) {
+	if len(payload) < 1 || terminalTypeIS != payload[0] {
+		return
+	}
+	name := string(payload[1:])
+
+	terminalType.mutex.Lock()
+	if terminalType.done {
+		terminalType.mutex.Unlock()
+		return
+	}
+	if n := len(terminalType.names); 0 < n && (terminalType.names[n-1] == name || terminalType.names[0] == name) {
+		// (The peer repeated the last one; or, having not, started over from the first.)
+		terminalType.done = true
+	} else {
+		terminalType.names = append(terminalType.names, name)
+		terminalType.done = terminalTypeLimit <= len(terminalType.names)
+	}
+	done := terminalType.done
+	if done {
+		terminalType.finish()
+	}
+	names := append([]string(nil), terminalType.names...)
+	sender := terminalType.sender
+	terminalType.mutex.Unlock()
+
+	if !done {
+		terminalType.send()
+		return
+	}
+	if nil == sender {
+		return
+	}
+
+	conn := sender.Conn()
+	conn.terminalTypeMutex.Lock()
+	fn := conn.onTerminalTypes
+	conn.terminalTypeMutex.Unlock()
+
+	if nil != fn {
+		fn(names)
+	}
+}
+
+// send asks for (the next) terminal type:
+//
+//	IAC SB TERMINAL-TYPE SEND IAC SE
+func (terminalType *internalPeerTerminalType) send() {
+	terminalType.mutex.Lock()
+	sender := terminalType.sender
+	terminalType.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	sender.SendSubnegotiation([]byte{terminalTypeSEND})
+}
+
+// finish closes told; if it has not been closed already. (The mutex must be held.)
+func (terminalType *internalPeerTerminalType) finish() {
+	if terminalType.closed || nil == terminalType.told {
+		return
+	}
+	close(terminalType.told)
+	terminalType.closed = true
+}
+
+func (terminalType *internalPeerTerminalType) types() []string {
+	terminalType.mutex.Lock()
+	defer terminalType.mutex.Unlock()
+
+	return append([]string(nil), terminalType.names...)
+}
@@ -0,0 +1,140 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestConnRequestTerminalType(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	told := make(chan []string, 1)
+	conn.OnTerminalTypes(func(names []string) {
+		told <- names
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RequestTerminalType()
+	}()
+
+	if expected, actual := string([]byte{IAC, DO, OptTerminalType}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, ok := conn.PeerTerminalType(); ok {
+		t.Errorf("Expected the terminal type to not have been told yet, but actually it was.")
+	}
+
+	send := string([]byte{IAC, SB, OptTerminalType, terminalTypeSEND, IAC, SE})
+
+	remote.Write([]byte{IAC, WILL, OptTerminalType})
+	for testNumber, name := range []string{"XTERM-256COLOR", "XTERM", "XTERM"} {
+		if expected, actual := send, string(testReadExactly(t, remote, len(send))); expected != actual {
+			t.Fatalf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		remote.Write(append(append([]byte{IAC, SB, OptTerminalType, terminalTypeIS}, name...), IAC, SE))
+	}
+
+	select {
+	case actual := <-told:
+		if expected := []string{"XTERM-256COLOR", "XTERM"}; !testEqualStrings(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnTerminalTypes to be called, but actually it was not.")
+	}
+
+	if expected, actual := []string{"XTERM-256COLOR", "XTERM"}, conn.PeerTerminalTypes(); !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if name, ok := conn.PeerTerminalType(); !ok || "XTERM-256COLOR" != name {
+		t.Errorf("Expected %q (and ok), but actually got %q (and %t).", "XTERM-256COLOR", name, ok)
+	}
+}
+
+func TestConnSetTerminalTypes(t *testing.T) {
+
+	tests := []struct {
+		Names    []string
+		Expected []string
+	}{
+		{
+			Names:    []string{"xterm", "vt100"},
+			Expected: []string{"xterm", "vt100", "vt100", "xterm", "vt100"},
+		},
+		{
+			Names:    []string{"ansi"},
+			Expected: []string{"ansi", "ansi", "ansi"},
+		},
+		{
+			Names:    nil,
+			Expected: []string{"UNKNOWN", "UNKNOWN"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, remote := testPipe(t)
+
+		if err := conn.SetTerminalTypes(test.Names...); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+
+		remote.Write([]byte{IAC, DO, OptTerminalType})
+		if expected, actual := string([]byte{IAC, WILL, OptTerminalType}), string(testReadExactly(t, remote, 3)); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			continue
+		}
+
+		for _, name := range test.Expected {
+			remote.Write([]byte{IAC, SB, OptTerminalType, terminalTypeSEND, IAC, SE})
+
+			expected := string(append(append([]byte{IAC, SB, OptTerminalType, terminalTypeIS}, name...), IAC, SE))
+			if actual := string(testReadExactly(t, remote, len(expected))); expected != actual {
+				t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+			}
+		}
+	}
+}
+
+func TestConnTerminalTypeBetweenConns(t *testing.T) {
+
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	server := newConn(serverSide, nil)
+	client := newConn(clientSide, nil)
+	go io.Copy(io.Discard, server)
+	go io.Copy(io.Discard, client)
+
+	if err := client.SetTerminalTypes("xterm-256color", "xterm", "vt100"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	told := make(chan []string, 1)
+	server.OnTerminalTypes(func(names []string) {
+		told <- names
+	})
+	if err := server.RequestTerminalType(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	select {
+	case actual := <-told:
+		if expected := []string{"xterm-256color", "xterm", "vt100"}; !testEqualStrings(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnTerminalTypes to be called, but actually it was not.")
+	}
+}
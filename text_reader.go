@@ -0,0 +1,257 @@
+package telnet
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy is what ReadRune, ReadLine, and Text do with the (data) bytes that are not
+// (valid) UTF-8; such as from a peer that sends Latin-1, or that sends broken UTF-8.
+type InvalidUTF8Policy int
+
+const (
+	// InvalidUTF8Replace replaces each invalid byte with U+FFFD (the Unicode replacement
+	// character). This is the default.
+	InvalidUTF8Replace InvalidUTF8Policy = iota
+
+	// InvalidUTF8Raw passes each invalid byte through, as it is. (So ReadLine, and Text, return
+	// strings that are not valid UTF-8. ReadRune returns it as utf8.RuneError, with a size of 1;
+	// like ranging over such a string does.)
+	InvalidUTF8Raw
+
+	// InvalidUTF8Fallback decodes each invalid byte with the fallback charset; which is Latin-1,
+	// unless another is set with SetFallbackCharset.
+	InvalidUTF8Fallback
+)
+
+// String returns the name of the policy. (Ex: "replace".)
+func (policy InvalidUTF8Policy) String() string {
+	switch policy {
+	case InvalidUTF8Replace:
+		return "replace"
+	case InvalidUTF8Raw:
+		return "raw"
+	case InvalidUTF8Fallback:
+		return "fallback"
+	default:
+		return "unknown"
+	}
+}
+
+// SetInvalidUTF8Policy sets what ReadRune, ReadLine, and Text do with (data) bytes that are not
+// (valid) UTF-8. For example:
+//
+//	conn.SetInvalidUTF8Policy(telnet.InvalidUTF8Fallback)
+//
+// It can be changed at any time (such as once CHARSET negotiation has finished); and applies
+// from the next rune read on.
+func (clientConn *Conn) SetInvalidUTF8Policy(policy InvalidUTF8Policy) {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	clientConn.invalidUTF8 = policy
+}
+
+// InvalidUTF8Policy returns the policy set with SetInvalidUTF8Policy.
+func (clientConn *Conn) InvalidUTF8Policy() InvalidUTF8Policy {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	return clientConn.invalidUTF8
+}
+
+// ReadRune reads a single (UTF-8 encoded) rune of (TELNET) data; and returns it, and how many
+// bytes of data it was. The bytes that are not (valid) UTF-8 are dealt with according to the
+// policy set with SetInvalidUTF8Policy.
+//
+// A rune that is split up (such as across TCP segments) is put back together; ReadRune waits
+// for the rest of it.
+//
+// ReadRune makes Conn fit the io.RuneReader interface.
+func (clientConn *Conn) ReadRune() (r rune, size int, err error) {
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	// (That the start of the rune went to Text, means there is no rune left to return.)
+	if 0 < len(clientConn.textPending) {
+		size = len(clientConn.textPending)
+		clientConn.textPending = nil
+		return utf8.RuneError, size, nil
+	}
+
+	r, size, invalid, err := clientConn.decodeRune()
+	if nil != err {
+		return 0, 0, err
+	}
+	if !invalid {
+		return r, size, nil
+	}
+
+	policy, charset := clientConn.textPolicy()
+	switch policy {
+	case InvalidUTF8Fallback:
+		return charset.DecodeByte(byte(r)), 1, nil
+	default:
+		return utf8.RuneError, 1, nil
+	}
+}
+
+// ReadLine reads a line of (TELNET) data; and returns it (without the CR LF, or LF, at the end of
+// it) as a string. The bytes that are not (valid) UTF-8 are dealt with according to the policy
+// set with SetInvalidUTF8Policy.
+//
+// If reading fails before the end of the line, then ReadLine returns what it read, and why.
+func (clientConn *Conn) ReadLine() (string, error) {
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	var line strings.Builder
+	var buffer [utf8.UTFMax]byte
+	for {
+		p, err := clientConn.readText(buffer[:])
+		line.Write(p)
+		if nil != err {
+			return line.String(), err
+		}
+
+		if 1 == len(p) && '\n' == p[0] {
+			s := strings.TrimSuffix(line.String(), "\n")
+			return strings.TrimSuffix(s, "\r"), nil
+		}
+	}
+}
+
+// Text returns a reader of the (TELNET) data, as UTF-8; with the bytes that are not (valid) UTF-8
+// dealt with according to the policy set with SetInvalidUTF8Policy. This is for using things such
+// as bufio.Scanner on a Conn; i.e., so that they get the same text that ReadRune, and ReadLine, do.
+// For example:
+//
+//	scanner := bufio.NewScanner(conn.Text())
+//	for scanner.Scan() {
+//		line := scanner.Text()
+//
+//		//@TODO: Do something with 'line'.
+//	}
+//
+// (Reading from it, and reading from the Conn, can be mixed; as it never reads more data than it
+// returns. Except when what it reads into is too short for a whole rune; in which case the rest
+// of that rune is returned by the next read from it, or by ReadLine. So, if ReadRune is to be
+// used too, then do not read it into anything shorter than utf8.UTFMax.)
+func (clientConn *Conn) Text() io.Reader {
+	return internalTextReader{conn: clientConn}
+}
+
+type internalTextReader struct {
+	conn *Conn
+}
+
+func (reader internalTextReader) Read(p []byte) (int, error) {
+	clientConn := reader.conn
+
+	clientConn.readMutex.Lock()
+	defer clientConn.readMutex.Unlock()
+
+	if len(p) <= 0 {
+		return 0, nil
+	}
+
+	var n int
+	var buffer [utf8.UTFMax]byte
+	for n < len(p) {
+		if 0 < len(clientConn.textPending) {
+			m := copy(p[n:], clientConn.textPending)
+			clientConn.textPending = clientConn.textPending[m:]
+			n += m
+			continue
+		}
+
+		// Return what we have, rather than wait for more.
+		if 0 < n && clientConn.buffered() <= 0 {
+			break
+		}
+
+		encoded, err := clientConn.readText(buffer[:])
+		m := copy(p[n:], encoded)
+		n += m
+		if m < len(encoded) {
+			clientConn.textPending = append(clientConn.textPending, encoded[m:]...)
+		}
+		if nil != err {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// readText reads a single rune of data, and returns it (UTF-8 encoded, in 'buffer') according to
+// the invalid UTF-8 policy. (The readMutex must be held.)
+func (clientConn *Conn) readText(buffer []byte) ([]byte, error) {
+
+	if 0 < len(clientConn.textPending) {
+		n := copy(buffer, clientConn.textPending)
+		clientConn.textPending = nil
+		return buffer[:n], nil
+	}
+
+	r, size, invalid, err := clientConn.decodeRune()
+	if nil != err {
+		return nil, err
+	}
+	if !invalid {
+		return buffer[:utf8.EncodeRune(buffer, r)], nil
+	}
+
+	policy, charset := clientConn.textPolicy()
+	switch policy {
+	case InvalidUTF8Raw:
+		buffer[0] = byte(r)
+		return buffer[:size], nil
+	case InvalidUTF8Fallback:
+		return buffer[:utf8.EncodeRune(buffer, charset.DecodeByte(byte(r)))], nil
+	default:
+		return buffer[:utf8.EncodeRune(buffer, utf8.RuneError)], nil
+	}
+}
+
+// decodeRune reads a single (UTF-8 encoded) rune of data. If it is not valid UTF-8, then it reads
+// (only) a single byte; and returns that (as a rune), with 'invalid' being true. (The readMutex
+// must be held.)
+//
+// If reading fails part way through a rune, then nothing is read; so the next call picks up where
+// it left off. (Except at the end-of-file; where what there is of the rune is invalid.)
+func (clientConn *Conn) decodeRune() (r rune, size int, invalid bool, err error) {
+
+	if err := clientConn.fill(1); nil != err {
+		return 0, 0, false, err
+	}
+
+	for !utf8.FullRune(clientConn.peeked) {
+		err := clientConn.fill(len(clientConn.peeked) + 1)
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			return 0, 0, false, err
+		}
+	}
+
+	r, size = utf8.DecodeRune(clientConn.peeked)
+	if utf8.RuneError == r && size <= 1 {
+		r, invalid = rune(clientConn.peeked[0]), true
+	}
+
+	var discard [utf8.UTFMax]byte
+	clientConn.readPeeked(discard[:size])
+
+	return r, size, invalid, nil
+}
+
+// textPolicy returns the invalid UTF-8 policy, and the fallback charset.
+func (clientConn *Conn) textPolicy() (InvalidUTF8Policy, Charset) {
+	clientConn.textMutex.Lock()
+	defer clientConn.textMutex.Unlock()
+
+	return clientConn.invalidUTF8, clientConn.fallback()
+}
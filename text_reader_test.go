@@ -0,0 +1,172 @@
+package telnet
+
+import (
+	"bufio"
+	"io"
+	"testing/iotest"
+	"unicode/utf8"
+
+	"testing"
+)
+
+func TestConnReadRune(t *testing.T) {
+
+	tests := []struct {
+		Policy   InvalidUTF8Policy
+		Sends    [][]byte
+		Expected []rune
+	}{
+		{
+			// (The runes are split up, across reads.)
+			Sends:    [][]byte{[]byte("h\xc3"), []byte("\xa9llo")},
+			Expected: []rune("héllo"),
+		},
+		{
+			Sends:    [][]byte{{0xe2}, {0x82}, {0xac, '!'}},
+			Expected: []rune("€!"),
+		},
+		{
+			// (The TELNET commands, in the middle of a rune, are not part of it.)
+			Sends:    [][]byte{{'a', 0xc3, IAC, NOP}, {0xa9, IAC, IAC}},
+			Expected: []rune{'a', 'é', utf8.RuneError},
+		},
+		{
+			Policy:   InvalidUTF8Replace,
+			Sends:    [][]byte{[]byte("caf\xe9\r\n")},
+			Expected: []rune{'c', 'a', 'f', utf8.RuneError, '\r', '\n'},
+		},
+		{
+			Policy:   InvalidUTF8Raw,
+			Sends:    [][]byte{[]byte("caf\xe9\r\n")},
+			Expected: []rune{'c', 'a', 'f', utf8.RuneError, '\r', '\n'},
+		},
+		{
+			Policy:   InvalidUTF8Fallback,
+			Sends:    [][]byte{[]byte("caf\xe9"), []byte("\r\n")},
+			Expected: []rune("café\r\n"),
+		},
+		{
+			// (A valid rune, after the invalid start of one.)
+			Policy:   InvalidUTF8Fallback,
+			Sends:    [][]byte{{0xe9}, []byte("é")},
+			Expected: []rune("éé"),
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+		conn.SetInvalidUTF8Policy(test.Policy)
+
+		for _, p := range test.Sends {
+			send(p...)
+		}
+
+		for i, expected := range test.Expected {
+			actual, size, err := conn.ReadRune()
+			if nil != err {
+				t.Errorf("For test #%d and rune #%d, did not expect an error, but actually got one: (%T) %v", testNumber, i, err, err)
+				break
+			}
+			if expected != actual {
+				t.Errorf("For test #%d and rune #%d, expected %q, but actually got %q.", testNumber, i, expected, actual)
+			}
+			if size <= 0 || utf8.UTFMax < size {
+				t.Errorf("For test #%d and rune #%d, did not expect a size of %d.", testNumber, i, size)
+			}
+		}
+	}
+}
+
+func TestConnReadLine(t *testing.T) {
+
+	tests := []struct {
+		Sends    [][]byte
+		Policies []InvalidUTF8Policy // (One for each line; as the policy can be changed at any time.)
+		Expected []string
+	}{
+		{
+			Sends:    [][]byte{[]byte("hello\r\nw\xc3"), []byte("\xb6rld\n")},
+			Policies: []InvalidUTF8Policy{InvalidUTF8Replace, InvalidUTF8Replace},
+			Expected: []string{"hello", "wörld"},
+		},
+		{
+			Sends:    [][]byte{[]byte("caf\xe9\r\ncaf\xe9\r\n"), []byte("caf\xe9\r\n")},
+			Policies: []InvalidUTF8Policy{InvalidUTF8Replace, InvalidUTF8Raw, InvalidUTF8Fallback},
+			Expected: []string{"caf�", "caf\xe9", "café"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		conn, send := testPromptPipe(t)
+
+		for _, p := range test.Sends {
+			send(p...)
+		}
+
+		for i, expected := range test.Expected {
+			conn.SetInvalidUTF8Policy(test.Policies[i])
+
+			actual, err := conn.ReadLine()
+			if nil != err {
+				t.Errorf("For test #%d and line #%d, did not expect an error, but actually got one: (%T) %v", testNumber, i, err, err)
+				break
+			}
+			if expected != actual {
+				t.Errorf("For test #%d and line #%d, expected %q, but actually got %q.", testNumber, i, expected, actual)
+			}
+		}
+	}
+}
+
+func TestConnText(t *testing.T) {
+
+	tests := []struct {
+		Policy   InvalidUTF8Policy
+		Sends    [][]byte
+		Expected []string
+	}{
+		{
+			Policy:   InvalidUTF8Replace,
+			Sends:    [][]byte{[]byte("one \xe2\x82"), []byte("\xac\r\ntw\xf6\r\n")},
+			Expected: []string{"one €", "tw�"},
+		},
+		{
+			Policy:   InvalidUTF8Fallback,
+			Sends:    [][]byte{[]byte("one \xe2\x82"), []byte("\xac\r\ntw\xf6\r\n")},
+			Expected: []string{"one €", "twö"},
+		},
+		{
+			Policy:   InvalidUTF8Raw,
+			Sends:    [][]byte{[]byte("one \xe2\x82"), []byte("\xac\r\ntw\xf6\r\n")},
+			Expected: []string{"one €", "tw\xf6"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		for _, oneByte := range []bool{false, true} {
+			conn, send := testPromptPipe(t)
+			conn.SetInvalidUTF8Policy(test.Policy)
+
+			for _, p := range test.Sends {
+				send(p...)
+			}
+
+			// (Reading a byte at a time means the runes do not fit into what is being read into.)
+			var reader io.Reader = conn.Text()
+			if oneByte {
+				reader = iotest.OneByteReader(reader)
+			}
+
+			scanner := bufio.NewScanner(reader)
+			for i, expected := range test.Expected {
+				if !scanner.Scan() {
+					t.Errorf("For test #%d (one byte: %v) and line #%d, expected a line, but actually got: %v", testNumber, oneByte, i, scanner.Err())
+					break
+				}
+				if actual := scanner.Text(); expected != actual {
+					t.Errorf("For test #%d (one byte: %v) and line #%d, expected %q, but actually got %q.", testNumber, oneByte, i, expected, actual)
+				}
+			}
+		}
+	}
+}
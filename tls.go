@@ -3,6 +3,7 @@ package telnet
 import (
 	"crypto/tls"
 	"net"
+	"time"
 )
 
 // ListenAndServeTLS acts identically to ListenAndServe, except that it
@@ -33,8 +34,7 @@ import (
 //		}
 //	}
 func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Handler) error {
-	server := &Server{Addr: addr, Handler: handler}
-	return server.ListenAndServeTLS(certFile, keyFile)
+	return NewServer(addr, WithHandler(handler)).ListenAndServeTLS(certFile, keyFile)
 }
 
 // ListenAndServeTLS acts identically to ListenAndServe, except that it
@@ -42,6 +42,9 @@ func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Han
 //
 // From a TELNET protocol point-of-view, it allows for 'secured telnet', also known as TELNETS,
 // which by default listens to port 992.
+//
+// The certificate (and its key) are loaded from 'certFile' and 'keyFile'; unless they are both
+// empty, in which case 'server.TLSConfig' has to have the certificate(s). (See ServeTLS.)
 func (server *Server) ListenAndServeTLS(certFile string, keyFile string) error {
 
 	addr := server.Addr
@@ -49,53 +52,102 @@ func (server *Server) ListenAndServeTLS(certFile string, keyFile string) error {
 		addr = ":telnets"
 	}
 
-	listener, err := net.Listen("tcp", addr)
+	listener, err := net.Listen("tcp", resolvePort(addr))
 	if nil != err {
 		return err
 	}
 
-	// Apparently have to make a copy of the TLS config this way, rather than by
-	// simple assignment, to prevent some unexported fields from being copied over.
-	//
-	// It would be nice if tls.Config had a method that would do this "safely".
-	// (I.e., what happens if in the future more exported fields are added to
-	// tls.Config?)
-	var tlsConfig *tls.Config = nil
+	return server.ServeTLS(listener, certFile, keyFile)
+}
+
+// ServeTLS acts identically to Serve, except that it uses the TELNET protocol over TLS.
+//
+// 'server.TLSConfig' (if not nil) is used as it is; including its GetCertificate, and
+// GetConfigForClient, so that, for example, a different certificate can be used for each
+// SNI (server) name; and its MinVersion, and CipherSuites. For example:
+//
+//	server := &telnet.Server{
+//		Handler: handler,
+//		TLSConfig: &tls.Config{
+//			MinVersion: tls.VersionTLS12,
+//			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+//				//@TODO: Return the certificate for hello.ServerName.
+//			},
+//		},
+//	}
+//
+//	err := server.ServeTLS(listener, "", "")
+//
+// (The server name the client asked for is then available to the handler; see Conn.ServerName.)
+//
+// If 'certFile' and 'keyFile' are not empty (or if 'server.TLSConfig' does not have any way of
+// getting a certificate), then the certificate is loaded from them; and added to (a clone of)
+// 'server.TLSConfig'.
+func (server *Server) ServeTLS(listener net.Listener, certFile string, keyFile string) error {
+
+	// (Cloned, so that adding the certificate does not change server.TLSConfig.)
+	var tlsConfig *tls.Config
 	if nil == server.TLSConfig {
 		tlsConfig = &tls.Config{}
 	} else {
-		tlsConfig = &tls.Config{
-			Rand:                   server.TLSConfig.Rand,
-			Time:                   server.TLSConfig.Time,
-			Certificates:           server.TLSConfig.Certificates,
-			GetCertificate:         server.TLSConfig.GetCertificate,
-			RootCAs:                server.TLSConfig.RootCAs,
-			NextProtos:             server.TLSConfig.NextProtos,
-			ServerName:             server.TLSConfig.ServerName,
-			ClientAuth:             server.TLSConfig.ClientAuth,
-			ClientCAs:              server.TLSConfig.ClientCAs,
-			InsecureSkipVerify:     server.TLSConfig.InsecureSkipVerify,
-			CipherSuites:           server.TLSConfig.CipherSuites,
-			SessionTicketsDisabled: server.TLSConfig.SessionTicketsDisabled,
-			ClientSessionCache:     server.TLSConfig.ClientSessionCache,
-			MinVersion:             server.TLSConfig.MinVersion,
-			MaxVersion:             server.TLSConfig.MaxVersion,
-			CurvePreferences:       server.TLSConfig.CurvePreferences,
-		}
+		tlsConfig = server.TLSConfig.Clone()
 	}
 
-	tlsConfigHasCertificate := len(tlsConfig.Certificates) > 0 || nil != tlsConfig.GetCertificate
-	if certFile == "" || keyFile == "" || !tlsConfigHasCertificate {
-		tlsConfig.Certificates = make([]tls.Certificate, 1)
-
-		var err error
-		tlsConfig.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+	tlsConfigHasCertificate := len(tlsConfig.Certificates) > 0 || nil != tlsConfig.GetCertificate || nil != tlsConfig.GetConfigForClient
+	if certFile != "" || keyFile != "" || !tlsConfigHasCertificate {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
 		if nil != err {
+			listener.Close()
 			return err
 		}
+		tlsConfig.Certificates = append([]tls.Certificate{certificate}, tlsConfig.Certificates...)
 	}
 
 	tlsListener := tls.NewListener(listener, tlsConfig)
 
 	return server.Serve(tlsListener)
 }
+
+// tlsHandshakeTimeout is how long a (TELNETS) client has to finish the TLS handshake.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// handshake does the TLS handshake, if 'c' is a TLS connection; so that what was negotiated (such
+// as the server name) is known before the handler is called.
+func handshake(c net.Conn) error {
+	tlsConn, ok := c.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	defer tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn.Handshake()
+}
+
+// ServerName returns the server name (SNI) that the client asked for, in the TLS handshake; or ""
+// if there was not one (or this is not a TELNETS connection). This is for serving more than one
+// (say) tenant on one address; for example:
+//
+//	func (handler *tenantHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader) {
+//		name := w.(*telnet.Conn).ServerName()
+//
+//		//@TODO: Serve the tenant for 'name'.
+//	}
+func (clientConn *Conn) ServerName() string {
+	state, ok := clientConn.TLSConnectionState()
+	if !ok {
+		return ""
+	}
+	return state.ServerName
+}
+
+// TLSConnectionState returns the state of the TLS connection; and false if this is not a TELNETS
+// connection.
+func (clientConn *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	tlsConn, ok := clientConn.conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConn.ConnectionState(), true
+}
@@ -0,0 +1,159 @@
+package telnet_test
+
+import (
+	"github.com/wouteroostervld/go-telnet"
+	"github.com/wouteroostervld/go-telnet/telnettest"
+
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testServerNameHandler sends the client the server name it asked for (see Conn.ServerName).
+type testServerNameHandler struct{}
+
+func (testServerNameHandler) ServeTELNET(ctx telnet.Context, w telnet.Writer, r telnet.Reader) {
+	conn := w.(*telnet.Conn)
+	conn.Write([]byte(conn.ServerName() + "\r\n"))
+}
+
+func TestServerTLSServerName(t *testing.T) {
+
+	first, err := telnettest.NewCertificate("first.example")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	second, err := telnettest.NewCertificate("second.example")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	certificates := map[string]*tls.Certificate{
+		"first.example":  &first,
+		"second.example": &second,
+	}
+	getCertificate := func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if certificate, ok := certificates[hello.ServerName]; ok {
+			return certificate, nil
+		}
+		return nil, errors.New("no certificate for " + hello.ServerName)
+	}
+
+	configs := []*tls.Config{
+		{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: getCertificate,
+		},
+		{
+			// (GetConfigForClient is honored too.)
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				return &tls.Config{GetCertificate: getCertificate}, nil
+			},
+		},
+	}
+
+	for configNumber, config := range configs {
+		server := telnettest.NewUnstartedServer(testServerNameHandler{})
+		server.TLS = config
+		server.StartTLS()
+
+		for _, name := range []string{"first.example", "second.example"} {
+			conn, err := server.DialTLS(&tls.Config{
+				RootCAs:    telnettest.CertPool(first, second),
+				ServerName: name,
+			})
+			if nil != err {
+				t.Errorf("For config #%d and %q, did not expect an error, but actually got one: (%T) %v", configNumber, name, err, err)
+				continue
+			}
+
+			line, err := conn.ReadLine()
+			if nil != err {
+				t.Errorf("For config #%d and %q, did not expect an error, but actually got one: (%T) %v", configNumber, name, err, err)
+			}
+			if expected, actual := name, line; expected != actual {
+				t.Errorf("For config #%d, expected the server name %q, but actually got %q.", configNumber, expected, actual)
+			}
+
+			state, _ := conn.TLSConnectionState()
+			if expected, actual := certificates[name].Leaf, state.PeerCertificates[0]; !expected.Equal(actual) {
+				t.Errorf("For config #%d, expected the certificate for %q, but actually got the one for %q.", configNumber, name, actual.Subject.CommonName)
+			}
+
+			conn.Close()
+		}
+
+		server.Close()
+	}
+}
+
+func TestDialerWithTLSConfig(t *testing.T) {
+
+	certificate, err := telnettest.NewCertificate("dialer.example")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	server := telnettest.NewUnstartedServer(testServerNameHandler{})
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	server.StartTLS()
+	defer server.Close()
+
+	dialer := telnet.NewDialer(telnet.WithTLSConfig(&tls.Config{
+		RootCAs:    telnettest.CertPool(certificate),
+		ServerName: "dialer.example",
+	}))
+
+	conn, err := dialer.DialTo(server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.TLSConnectionState(); !ok {
+		t.Errorf("Expected the connection to be a TELNETS one, but actually it was not.")
+	}
+
+	line, err := conn.ReadLine()
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := "dialer.example", line; expected != actual {
+		t.Errorf("Expected the server name %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestServerTLSHandshakeFailed(t *testing.T) {
+
+	disconnects := make(chan telnet.CloseReason, 2)
+
+	server := telnettest.NewUnstartedServer(testServerNameHandler{})
+	server.Config.OnDisconnect = func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+		disconnects <- reason
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// (Not a TLS client hello; so the handshake fails.)
+	client, err := net.Dial("tcp", server.Addr)
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer client.Close()
+	client.Write([]byte("hello\r\n"))
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	io.Copy(io.Discard, client)
+
+	select {
+	case reason := <-disconnects:
+		if expected, actual := telnet.CloseTLSHandshakeFailed, reason; expected != actual {
+			t.Errorf("Expected OnDisconnect to be given %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for OnDisconnect to be called.")
+	}
+}
@@ -0,0 +1,120 @@
+package telnet
+
+import (
+	"net"
+)
+
+// Trace is a set of hooks, that are called at each stage of (dialing, or serving) a connection;
+// for logging, or for tracing, what happens to each connection. Any (or all) of them can be nil.
+// For example:
+//
+//	trace := &telnet.Trace{
+//		Connected: func(conn *telnet.Conn) {
+//			log.Printf("connected to %v", conn.RemoteAddr())
+//		},
+//		Negotiation: func(conn *telnet.Conn, event telnet.NegotiationEvent) {
+//			log.Printf("%v: %s %s %s", conn.RemoteAddr(), event.Direction, telnet.CommandName(event.Verb), telnet.OptionName(event.Option))
+//		},
+//		Closed: func(conn *telnet.Conn, reason telnet.CloseReason, msg string) {
+//			log.Printf("%v: closed (%v)", conn.RemoteAddr(), reason)
+//		},
+//	}
+//
+//	conn, err := telnet.NewDialer(telnet.WithTrace(trace)).DialTo("example.net:23")
+//
+// (See WithTrace; and Server.Trace, and Dialer.Trace.)
+type Trace struct {
+	// DialStart is called before dialing 'addr'; and DialDone once that is done (with the error,
+	// if it failed). (Only for a Dialer.)
+	DialStart func(addr string)
+	DialDone  func(addr string, err error)
+
+	// Accepted is called once a connection (from 'remoteAddr') has been accepted; before the
+	// TLS handshake (if there is one). (Only for a Server.)
+	Accepted func(remoteAddr net.Addr)
+
+	// Connected is called once the Conn has been made; before anything else is done with it.
+	Connected func(conn *Conn)
+
+	// Negotiation is called for each option negotiation (i.e., WILL, WONT, DO, and DONT) sent
+	// or received; like the function given to Conn.OnNegotiationEvent, which it does not replace.
+	Negotiation func(conn *Conn, event NegotiationEvent)
+
+	// Closed is called once the Conn has been closed, and torn down; with why it was closed.
+	// (See Conn.CloseReason, and Conn.Done.)
+	Closed func(conn *Conn, reason CloseReason, msg string)
+}
+
+func (trace *Trace) dialStart(addr string) {
+	if nil != trace && nil != trace.DialStart {
+		trace.DialStart(addr)
+	}
+}
+
+func (trace *Trace) dialDone(addr string, err error) {
+	if nil != trace && nil != trace.DialDone {
+		trace.DialDone(addr, err)
+	}
+}
+
+func (trace *Trace) accepted(remoteAddr net.Addr) {
+	if nil != trace && nil != trace.Accepted {
+		trace.Accepted(remoteAddr)
+	}
+}
+
+// Metrics is told of each connection that is opened, and closed; for counting them. (See
+// WithMetrics; and Server.Metrics, and Dialer.Metrics.)
+//
+// Its methods are called from whatever goroutine opened (or closed) the connection; so they have
+// to be safe to call concurrently.
+//
+// (A Metrics that is also a TrafficMetrics is told of what goes over each connection, too. See
+// Counters.)
+type Metrics interface {
+	// Opened is called once the Conn has been made.
+	Opened(conn *Conn)
+
+	// Closed is called once the Conn has been closed, and torn down; with why it was closed.
+	Closed(conn *Conn, reason CloseReason)
+}
+
+// instrument has the Conn (which was just made) call the hooks of 'trace', and tell 'metrics'.
+// (It must be called before the Conn is used.)
+func (clientConn *Conn) instrument(trace *Trace, metrics Metrics) {
+	if nil != trace && nil != trace.Negotiation {
+		clientConn.negotiator.mutex.Lock()
+		clientConn.negotiator.trace = func(event NegotiationEvent) {
+			trace.Negotiation(clientConn, event)
+		}
+		clientConn.negotiator.mutex.Unlock()
+	}
+
+	if traffic, ok := metrics.(TrafficMetrics); ok {
+		clientConn.traffic.metrics = traffic
+
+		clientConn.negotiator.mutex.Lock()
+		clientConn.negotiator.failed = func(option byte) {
+			traffic.NegotiationFailed(clientConn, option)
+		}
+		clientConn.negotiator.mutex.Unlock()
+	}
+
+	if (nil != trace && nil != trace.Closed) || nil != metrics {
+		clientConn.onTornDown = func(reason CloseReason, msg string) {
+			if nil != metrics {
+				metrics.Closed(clientConn, reason)
+			}
+			if nil != trace && nil != trace.Closed {
+				trace.Closed(clientConn, reason, msg)
+			}
+		}
+	}
+
+	if nil != metrics {
+		metrics.Opened(clientConn)
+	}
+	if nil != trace && nil != trace.Connected {
+		trace.Connected(clientConn)
+	}
+}
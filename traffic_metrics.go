@@ -0,0 +1,191 @@
+package telnet
+
+import (
+	"encoding/json"
+	"io"
+	"sync/atomic"
+)
+
+// TrafficMetrics is Metrics that is also told of what goes over each connection; the bytes sent,
+// and received, and the TELNET commands received. A Metrics (of a Server, or of a Dialer) that is
+// a TrafficMetrics is told of them. (Counters is one; for dashboards.)
+//
+// Like with Metrics, its methods have to be safe to call concurrently. They are called as the
+// Conn reads, and writes; so they should be quick. (Such as just adding to a counter.)
+type TrafficMetrics interface {
+	Metrics
+
+	// Received is called with how many bytes were received over the connection; and Sent with how
+	// many were sent. (That is, as they are on the wire: escaped, with the TELNET commands, and
+	// compressed, if they are; see OfferCompression.)
+	Received(conn *Conn, n int)
+	Sent(conn *Conn, n int)
+
+	// DataReceived is called with how many bytes of data were read; and DataSent with how many
+	// were written. (That is, as the data is before it is escaped, and without the TELNET
+	// commands.)
+	DataReceived(conn *Conn, n int)
+	DataSent(conn *Conn, n int)
+
+	// Command is called for each TELNET command received from the peer; with WILL, WONT, DO, or
+	// DONT for an option negotiation, and SB for a subnegotiation.
+	Command(conn *Conn, cmd byte)
+
+	// NegotiationFailed is called for each option negotiation that the Conn started, that did
+	// not go the way it asked for; because the peer refused, or did not answer in time (see
+	// SetNegotiationTimeout).
+	NegotiationFailed(conn *Conn, option byte)
+}
+
+// Counters is a TrafficMetrics that adds everything up; over all of the connections it is told
+// of. For example:
+//
+//	var counters telnet.Counters
+//
+//	server := telnet.NewServer(":5555",
+//		telnet.WithHandler(handler),
+//		telnet.WithMetrics(&counters),
+//	)
+//
+//	expvar.Publish("telnet", &counters)
+//
+// (Counters is an expvar.Var; its String is the JSON of its Snapshot.) The same Counters can be
+// given to more than one Server (or Dialer); such as to add up a gateway's clients, and the
+// servers it connects them to. A Counters must not be copied once it has been used.
+type Counters struct {
+	sessions       atomic.Int64
+	activeSessions atomic.Int64
+
+	bytesReceived atomic.Int64
+	bytesSent     atomic.Int64
+	dataReceived  atomic.Int64
+	dataSent      atomic.Int64
+
+	commands            atomic.Int64
+	negotiationFailures atomic.Int64
+}
+
+// CountersSnapshot is what a Counters had counted, at one moment. (See Counters.Snapshot.)
+type CountersSnapshot struct {
+	Sessions       int64 `json:"sessions"`        // How many connections were opened.
+	ActiveSessions int64 `json:"active_sessions"` // How many connections are open.
+
+	BytesReceived int64 `json:"bytes_received"` // (On the wire.)
+	BytesSent     int64 `json:"bytes_sent"`
+	DataReceived  int64 `json:"data_received"` // (Data; before it is escaped.)
+	DataSent      int64 `json:"data_sent"`
+
+	Commands            int64 `json:"commands"`             // TELNET commands received.
+	NegotiationFailures int64 `json:"negotiation_failures"` // Option negotiations refused, or timed out.
+}
+
+// Snapshot returns what has been counted so far.
+func (counters *Counters) Snapshot() CountersSnapshot {
+	return CountersSnapshot{
+		Sessions:            counters.sessions.Load(),
+		ActiveSessions:      counters.activeSessions.Load(),
+		BytesReceived:       counters.bytesReceived.Load(),
+		BytesSent:           counters.bytesSent.Load(),
+		DataReceived:        counters.dataReceived.Load(),
+		DataSent:            counters.dataSent.Load(),
+		Commands:            counters.commands.Load(),
+		NegotiationFailures: counters.negotiationFailures.Load(),
+	}
+}
+
+// String returns the Snapshot as JSON; such as:
+//
+//	{"sessions":12,"active_sessions":3,"bytes_received":5120, ...}
+//
+// String makes Counters fit the expvar.Var interface.
+func (counters *Counters) String() string {
+	p, err := json.Marshal(counters.Snapshot())
+	if nil != err {
+		return "{}"
+	}
+	return string(p)
+}
+
+func (counters *Counters) Opened(conn *Conn) {
+	counters.sessions.Add(1)
+	counters.activeSessions.Add(1)
+}
+
+func (counters *Counters) Closed(conn *Conn, reason CloseReason) {
+	counters.activeSessions.Add(-1)
+}
+
+func (counters *Counters) Received(conn *Conn, n int) {
+	counters.bytesReceived.Add(int64(n))
+}
+
+func (counters *Counters) Sent(conn *Conn, n int) {
+	counters.bytesSent.Add(int64(n))
+}
+
+func (counters *Counters) DataReceived(conn *Conn, n int) {
+	counters.dataReceived.Add(int64(n))
+}
+
+func (counters *Counters) DataSent(conn *Conn, n int) {
+	counters.dataSent.Add(int64(n))
+}
+
+func (counters *Counters) Command(conn *Conn, cmd byte) {
+	counters.commands.Add(1)
+}
+
+func (counters *Counters) NegotiationFailed(conn *Conn, option byte) {
+	counters.negotiationFailures.Add(1)
+}
+
+// internalTraffic tells the TrafficMetrics (if there is one) of what goes over the Conn. (It is
+// shared by what reads, and writes, the Conn; and is set up, by instrument, before the Conn is
+// used.) A nil *internalTraffic tells nothing.
+type internalTraffic struct {
+	conn    *Conn
+	metrics TrafficMetrics
+}
+
+func (traffic *internalTraffic) received(n int) {
+	if nil != traffic && nil != traffic.metrics && 0 < n {
+		traffic.metrics.Received(traffic.conn, n)
+	}
+}
+
+func (traffic *internalTraffic) sent(n int) {
+	if nil != traffic && nil != traffic.metrics && 0 < n {
+		traffic.metrics.Sent(traffic.conn, n)
+	}
+}
+
+func (traffic *internalTraffic) dataReceived(n int) {
+	if nil != traffic && nil != traffic.metrics && 0 < n {
+		traffic.metrics.DataReceived(traffic.conn, n)
+	}
+}
+
+func (traffic *internalTraffic) dataSent(n int) {
+	if nil != traffic && nil != traffic.metrics && 0 < n {
+		traffic.metrics.DataSent(traffic.conn, n)
+	}
+}
+
+func (traffic *internalTraffic) command(cmd byte) {
+	if nil != traffic && nil != traffic.metrics {
+		traffic.metrics.Command(traffic.conn, cmd)
+	}
+}
+
+// internalMeteredWriter tells the internalTraffic about everything sent to the peer.
+type internalMeteredWriter struct {
+	writer  io.Writer
+	traffic *internalTraffic
+}
+
+func (writer internalMeteredWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	writer.traffic.sent(n)
+
+	return n, err
+}
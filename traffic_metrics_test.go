@@ -0,0 +1,82 @@
+package telnet
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+func TestCounters(t *testing.T) {
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	var counters Counters
+	conn := newConn(local, nil)
+	conn.instrument(nil, &counters)
+	conn.SetNegotiationTimeout(20 * time.Millisecond)
+
+	// (The peer gets IAC DO ECHO, IAC DO SUPPRESS-GO-AHEAD, and then the data; "a" and an escaped IAC.)
+	sent := make(chan []byte, 1)
+	go func() {
+		p := make([]byte, 9)
+		io.ReadFull(remote, p)
+		sent <- p
+	}()
+
+	if err := conn.negotiator.request(OptEcho, false, true); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if err := conn.negotiator.request(OptSuppressGoAhead, false, true); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	// (The peer refuses ECHO; and never answers for SUPPRESS-GO-AHEAD, which times out.)
+	go remote.Write([]byte{IAC, AYT, IAC, WONT, OptEcho, 'h', 'i', IAC, IAC})
+	if _, err := io.ReadFull(conn, make([]byte, 3)); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := conn.Write([]byte{'a', IAC}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := string([]byte{IAC, DO, OptEcho, IAC, DO, OptSuppressGoAhead, 'a', IAC, IAC}), string(<-sent); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := (CountersSnapshot{
+		Sessions:            1,
+		ActiveSessions:      1,
+		BytesReceived:       9,
+		BytesSent:           9,
+		DataReceived:        3,
+		DataSent:            2,
+		Commands:            2,
+		NegotiationFailures: 2,
+	}), counters.Snapshot(); expected != actual {
+		t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+	}
+
+	conn.Close()
+	select {
+	case <-conn.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the Conn to be torn down, but it actually was not.")
+	}
+
+	// (Counters is an expvar.Var.)
+	var snapshot map[string]int64
+	if err := json.Unmarshal([]byte(counters.String()), &snapshot); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := int64(0), snapshot["active_sessions"]; expected != actual {
+		t.Errorf("Expected %d active sessions, but actually got %d.", expected, actual)
+	}
+	if expected, actual := int64(1), snapshot["sessions"]; expected != actual {
+		t.Errorf("Expected %d sessions, but actually got %d.", expected, actual)
+	}
+}
@@ -0,0 +1,236 @@
+package telnet
+
+import (
+	"sync"
+)
+
+// SetWindowSize sets the size of the (local) terminal, that is told to the peer with the NAWS
+// option (RFC 1073). This is for clients. For example:
+//
+//	conn.SetWindowSize(80, 24)
+//
+// The first time it is called it offers (i.e., sends a WILL for) NAWS; and, once the peer agrees
+// to it, sends the size. After that, each time it is called (such as when the terminal has been
+// resized) it sends the new size; if the peer (still) agrees to NAWS.
+//
+// (It registers an OptionHandler for NAWS; replacing any that was registered before. Such as by
+// RequestWindowSize; which is for the other side.)
+func (clientConn *Conn) SetWindowSize(width int, height int) error {
+	clientConn.windowSizeMutex.Lock()
+	handler := clientConn.windowSize
+	registered := nil != handler
+	if !registered {
+		handler = &internalWindowSize{}
+		clientConn.windowSize = handler
+		clientConn.peerWindowSize = nil
+	}
+	clientConn.windowSizeMutex.Unlock()
+
+	if !registered {
+		handler.set(width, height)
+		return clientConn.RegisterOption(OptNAWS, handler)
+	}
+
+	if !handler.set(width, height) {
+		return nil
+	}
+	return handler.send()
+}
+
+// internalWindowSize is the (NAWS) OptionHandler that SetWindowSize registers.
+type internalWindowSize struct {
+	mutex   sync.Mutex
+	sender  OptionSender
+	enabled bool
+	width   int
+	height  int
+}
+
+func (windowSize *internalWindowSize) Register(sender OptionSender) OptionSupport {
+	windowSize.mutex.Lock()
+	windowSize.sender = sender
+	windowSize.mutex.Unlock()
+
+	return OptionSupport{
+		Local:        true,
+		RequestLocal: true,
+	}
+}
+
+func (windowSize *internalWindowSize) LocalChanged(enabled bool) {
+	windowSize.mutex.Lock()
+	windowSize.enabled = enabled
+	windowSize.mutex.Unlock()
+
+	if enabled {
+		windowSize.send()
+	}
+}
+
+func (*internalWindowSize) RemoteChanged(bool) {}
+
+func (*internalWindowSize) Subnegotiation([]byte) {}
+
+// set sets the size; and returns whether it should be sent (i.e., whether NAWS is enabled).
+func (windowSize *internalWindowSize) set(width int, height int) bool {
+	windowSize.mutex.Lock()
+	defer windowSize.mutex.Unlock()
+
+	windowSize.width = width
+	windowSize.height = height
+
+	return windowSize.enabled
+}
+
+// send sends:
+//
+//	IAC SB NAWS <width (2 bytes)> <height (2 bytes)> IAC SE
+func (windowSize *internalWindowSize) send() error {
+	windowSize.mutex.Lock()
+	sender := windowSize.sender
+	width, height := clampWindowSize(windowSize.width), clampWindowSize(windowSize.height)
+	windowSize.mutex.Unlock()
+
+	if nil == sender {
+		return nil
+	}
+	return sender.SendSubnegotiation([]byte{byte(width >> 8), byte(width), byte(height >> 8), byte(height)})
+}
+
+// clampWindowSize returns 'n' as what fits in the 2 bytes NAWS has for it.
+func clampWindowSize(n int) int {
+	switch {
+	case n < 0:
+		return 0
+	case 0xffff < n:
+		return 0xffff
+	default:
+		return n
+	}
+}
+
+// RequestWindowSize asks the peer to tell the size of its terminal, with the NAWS option (RFC
+// 1073); i.e., sends a DO for NAWS. This is for servers. (Such as for one serving a full-screen
+// terminal UI.) For example:
+//
+//	conn.OnWindowSize(func(width int, height int) {
+//		//@TODO: Redraw the screen, for the new size.
+//	})
+//
+//	if err := conn.RequestWindowSize(); nil != err {
+//		return err
+//	}
+//
+// Once the peer agrees to it, it sends the size; and (again) each time its terminal is resized.
+// The last size it sent is what PeerWindowSize returns; and OnWindowSize is called for each.
+//
+// (It registers an OptionHandler for NAWS; replacing any that was registered before. Such as by
+// SetWindowSize; which is for the other side.)
+func (clientConn *Conn) RequestWindowSize() error {
+	handler := &internalPeerWindowSize{}
+
+	clientConn.windowSizeMutex.Lock()
+	clientConn.windowSize = nil
+	clientConn.peerWindowSize = handler
+	clientConn.windowSizeMutex.Unlock()
+
+	return clientConn.RegisterOption(OptNAWS, handler)
+}
+
+// PeerWindowSize returns the size of the peer's terminal; as the peer last told it, with NAWS (see
+// RequestWindowSize). 'ok' is false if it has not told it (or has since disabled NAWS). (A zero
+// width, or height, is one the peer does not know.)
+func (clientConn *Conn) PeerWindowSize() (width int, height int, ok bool) {
+	clientConn.windowSizeMutex.Lock()
+	handler := clientConn.peerWindowSize
+	clientConn.windowSizeMutex.Unlock()
+
+	if nil == handler {
+		return 0, 0, false
+	}
+	return handler.size()
+}
+
+// OnWindowSize registers 'fn' to be called each time the peer tells the size of its terminal, with
+// NAWS (see RequestWindowSize); such as when it has been resized. This replaces whatever was
+// registered before. Registering nil stops the calls.
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnWindowSize(fn func(width int, height int)) {
+	clientConn.windowSizeMutex.Lock()
+	defer clientConn.windowSizeMutex.Unlock()
+
+	clientConn.onWindowSize = fn
+}
+
+// internalPeerWindowSize is the (NAWS) OptionHandler that RequestWindowSize registers.
+type internalPeerWindowSize struct {
+	mutex  sync.Mutex
+	conn   *Conn
+	told   bool
+	width  int
+	height int
+}
+
+func (windowSize *internalPeerWindowSize) Register(sender OptionSender) OptionSupport {
+	windowSize.mutex.Lock()
+	windowSize.conn = sender.Conn()
+	windowSize.mutex.Unlock()
+
+	return OptionSupport{
+		Remote:        true,
+		RequestRemote: true,
+	}
+}
+
+func (*internalPeerWindowSize) LocalChanged(bool) {}
+
+func (windowSize *internalPeerWindowSize) RemoteChanged(enabled bool) {
+	if enabled {
+		return
+	}
+
+	windowSize.mutex.Lock()
+	windowSize.told = false
+	windowSize.width = 0
+	windowSize.height = 0
+	windowSize.mutex.Unlock()
+}
+
+// Subnegotiation handles:
+//
+//	IAC SB NAWS <width (2 bytes)> <height (2 bytes)> IAC SE
+func (windowSize *internalPeerWindowSize) Subnegotiation(payload []byte) {
+	if len(payload) < 4 {
+		return
+	}
+	width := int(payload[0])<<8 | int(payload[1])
+	height := int(payload[2])<<8 | int(payload[3])
+
+	windowSize.mutex.Lock()
+	windowSize.told = true
+	windowSize.width = width
+	windowSize.height = height
+	conn := windowSize.conn
+	windowSize.mutex.Unlock()
+
+	if nil == conn {
+		return
+	}
+
+	conn.windowSizeMutex.Lock()
+	fn := conn.onWindowSize
+	conn.windowSizeMutex.Unlock()
+
+	if nil != fn {
+		fn(width, height)
+	}
+}
+
+func (windowSize *internalPeerWindowSize) size() (int, int, bool) {
+	windowSize.mutex.Lock()
+	defer windowSize.mutex.Unlock()
+
+	return windowSize.width, windowSize.height, windowSize.told
+}
@@ -0,0 +1,146 @@
+package telnet
+
+import (
+	"time"
+
+	"testing"
+)
+
+func TestConnSetWindowSize(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.SetWindowSize(80, 24)
+	}()
+
+	if expected, actual := string([]byte{IAC, WILL, OptNAWS}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	remote.Write([]byte{IAC, DO, OptNAWS})
+
+	tests := []struct {
+		Width    int
+		Height   int
+		Expected []byte
+	}{
+		{
+			// (What was set before NAWS was agreed to.)
+			Expected: []byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE},
+		},
+		{
+			Width:    132,
+			Height:   0x1ff,
+			Expected: []byte{IAC, SB, OptNAWS, 0, 132, 1, IAC, IAC, IAC, SE},
+		},
+		{
+			Width:    100000,
+			Height:   -1,
+			Expected: []byte{IAC, SB, OptNAWS, IAC, IAC, IAC, IAC, 0, 0, IAC, SE},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if 0 < testNumber {
+			go func() {
+				errs <- conn.SetWindowSize(test.Width, test.Height)
+			}()
+		}
+
+		if expected, actual := string(test.Expected), string(testReadExactly(t, remote, len(test.Expected))); expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		if 0 < testNumber {
+			if err := <-errs; nil != err {
+				t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			}
+		}
+	}
+}
+
+func TestConnRequestWindowSize(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	resized := make(chan [2]int, 3)
+	conn.OnWindowSize(func(width int, height int) {
+		resized <- [2]int{width, height}
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.RequestWindowSize()
+	}()
+
+	if expected, actual := string([]byte{IAC, DO, OptNAWS}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if err := <-errs; nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if _, _, ok := conn.PeerWindowSize(); ok {
+		t.Errorf("Expected the window size to not have been told yet, but actually it was.")
+	}
+
+	remote.Write([]byte{IAC, WILL, OptNAWS})
+
+	tests := []struct {
+		Payload []byte
+		Width   int
+		Height  int
+	}{
+		{
+			Payload: []byte{IAC, SB, OptNAWS, 0, 80, 0, 24, IAC, SE},
+			Width:   80,
+			Height:  24,
+		},
+		{
+			Payload: []byte{IAC, SB, OptNAWS, 0, 132, 1, IAC, IAC, IAC, SE},
+			Width:   132,
+			Height:  0x1ff,
+		},
+		{
+			Payload: []byte{IAC, SB, OptNAWS, IAC, IAC, IAC, IAC, 0, 0, IAC, SE},
+			Width:   0xffff,
+			Height:  0,
+		},
+	}
+
+	for testNumber, test := range tests {
+		remote.Write(test.Payload)
+
+		select {
+		case actual := <-resized:
+			if expected := [2]int{test.Width, test.Height}; expected != actual {
+				t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, expected, actual)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("For test #%d, expected OnWindowSize to be called, but actually it was not.", testNumber)
+		}
+
+		width, height, ok := conn.PeerWindowSize()
+		if expected, actual := [2]int{test.Width, test.Height}, [2]int{width, height}; !ok || expected != actual {
+			t.Errorf("For test #%d, expected %v (and ok), but actually got %v (and %t).", testNumber, expected, actual, ok)
+		}
+	}
+
+	// (Which is answered with a DONT.)
+	remote.Write([]byte{IAC, WONT, OptNAWS})
+	if expected, actual := string([]byte{IAC, DONT, OptNAWS}), string(testReadExactly(t, remote, 3)); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, _, ok := conn.PeerWindowSize(); !ok {
+			return
+		}
+	}
+	t.Errorf("Expected the window size to be forgotten once NAWS was disabled, but actually it was not.")
+}
@@ -0,0 +1,207 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+// Unflushed returns how many of the bytes of data written to the Conn (with Write) have not been
+// sent yet; i.e., that are still buffered (such as because of SetWriteCoalescing, or because
+// sending them failed part way through). A byte 255 (i.e., IAC), which is sent as 2 bytes, is
+// only sent once both of them are.
+//
+// When Write returns an error, the 'n' it returns is how many of the bytes it was given were
+// taken; which includes the ones that are still buffered. So (what is left of) the data can be
+// written again, without any of it being sent twice, with:
+//
+//	n, err := conn.Write(p)
+//	if nil != err {
+//		unsent := conn.Unflushed() // (Of p[:n]; and of what was written before it.)
+//
+//		//@TODO: Write p[n:] again later; or give up on it (and the 'unsent').
+//	}
+//
+// ... and the buffered bytes go out with the next Flush (or Write) that succeeds; continuing from
+// exactly where sending them stopped (even part way through an escaped IAC).
+//
+// (What is being held back by a WriteFilter is not counted; nor are TELNET commands.)
+func (clientConn *Conn) Unflushed() int {
+	return int(clientConn.dataWriter.wrapped.unflushed.Load())
+}
+
+// internalWireBuffer is (like a bufio.Writer) a buffer of what is to be sent to the connection;
+// but it keeps track of which of the bytes in it complete a byte of data (rather than being the
+// first half of an escaped IAC, or being part of a TELNET command). So how many bytes of data
+// have not been sent yet is known exactly.
+//
+// Unlike with a bufio.Writer, an error (from the wrapped io.Writer) does not stick. The bytes that
+// were not written stay buffered; and the next Flush continues from there.
+type internalWireBuffer struct {
+	writer io.Writer
+	size   int
+
+	buffer []byte
+
+	// completes is (for each byte in the buffer) whether it is the last byte of a byte of data.
+	completes []bool
+
+	// unflushed is how many bytes of data are in the buffer (i.e., how many bytes in it complete one).
+	unflushed atomic.Int64
+}
+
+func newWireBuffer(writer io.Writer, size int) *internalWireBuffer {
+	return &internalWireBuffer{writer: writer, size: size}
+}
+
+// reset throws away what is buffered; and has the buffer write to 'writer' (with 'size') from now on.
+func (wire *internalWireBuffer) reset(writer io.Writer, size int) {
+	wire.writer = writer
+	wire.size = size
+	wire.buffer = nil
+	wire.completes = nil
+	wire.unflushed.Store(0)
+}
+
+// Size returns the size of the buffer.
+func (wire *internalWireBuffer) Size() int {
+	return wire.size
+}
+
+// Buffered returns how many bytes are buffered.
+func (wire *internalWireBuffer) Buffered() int {
+	return len(wire.buffer)
+}
+
+// Available returns how many more bytes fit in the buffer.
+func (wire *internalWireBuffer) Available() int {
+	return wire.size - len(wire.buffer)
+}
+
+// Write buffers 'p' as-is; i.e., as TELNET commands (or data that has already been escaped), none
+// of which completes a byte of data. It flushes first, if 'p' does not fit.
+func (wire *internalWireBuffer) Write(p []byte) (int, error) {
+	n := 0
+	for wire.Available() < len(p)-n && 0 < len(wire.buffer) {
+		k := wire.Available()
+		if k < 0 {
+			k = 0
+		}
+		wire.append(p[n:n+k], false)
+		n += k
+
+		if err := wire.Flush(); nil != err {
+			return n, err
+		}
+	}
+
+	wire.append(p[n:], false)
+	return len(p), nil
+}
+
+// writeData escapes, and buffers, as much of 'data' as fits; and returns how much of it did. (An
+// IAC is never split; but, if the buffer is empty, at least 1 byte of 'data' is taken.)
+//
+// It copies each run of bytes between the IACs in one go; and only escapes the IACs themselves.
+func (wire *internalWireBuffer) writeData(data []byte) int {
+	n := 0
+	for n < len(data) {
+		run := data[n:]
+		if i := indexIAC(run); 0 <= i {
+			run = run[:i]
+		}
+
+		if 0 < len(run) {
+			available := wire.Available()
+			if available < 1 && (0 < n || 0 < len(wire.buffer)) {
+				break
+			}
+			if available < 1 {
+				available = 1
+			}
+			if available < len(run) {
+				run = run[:available]
+			}
+
+			wire.append(run, true)
+			n += len(run)
+			continue
+		}
+
+		// (It is an IAC.)
+		if wire.Available() < 2 && (0 < n || 0 < len(wire.buffer)) {
+			break
+		}
+		wire.buffer = append(wire.buffer, IAC, IAC)
+		wire.completes = append(wire.completes, false, true)
+		n++
+	}
+
+	wire.unflushed.Add(int64(n))
+	return n
+}
+
+// indexIAC returns the index of the first IAC in 'p'; or -1 if there is none. (It looks at the
+// first few bytes itself; as, for data with a lot of IACs in it, that is faster than going through
+// bytes.IndexByte for each of them.)
+func indexIAC(p []byte) int {
+	const short = 8
+
+	for i := 0; i < len(p) && i < short; i++ {
+		if IAC == p[i] {
+			return i
+		}
+	}
+	if len(p) <= short {
+		return -1
+	}
+
+	i := bytes.IndexByte(p[short:], IAC)
+	if i < 0 {
+		return -1
+	}
+	return short + i
+}
+
+func (wire *internalWireBuffer) append(p []byte, completes bool) {
+	wire.buffer = append(wire.buffer, p...)
+
+	// (The compiler makes this grow the slice; without allocating the slice being appended.)
+	n := len(wire.completes)
+	wire.completes = append(wire.completes, make([]bool, len(p))...)
+	if completes {
+		added := wire.completes[n:]
+		for i := range added {
+			added[i] = true
+		}
+	}
+}
+
+// Flush writes what is buffered to the wrapped io.Writer. If that fails part way through, then
+// only the bytes that were written are taken out of the buffer.
+func (wire *internalWireBuffer) Flush() error {
+	if 0 == len(wire.buffer) {
+		return nil
+	}
+
+	n, err := wire.writer.Write(wire.buffer)
+	if n < 0 || len(wire.buffer) < n {
+		n = 0
+	}
+	if nil == err && n < len(wire.buffer) {
+		err = io.ErrShortWrite
+	}
+
+	completed := 0
+	for _, completes := range wire.completes[:n] {
+		if completes {
+			completed++
+		}
+	}
+	wire.unflushed.Add(-int64(completed))
+
+	wire.buffer = append(wire.buffer[:0], wire.buffer[n:]...)
+	wire.completes = append(wire.completes[:0], wire.completes[n:]...)
+
+	return err
+}
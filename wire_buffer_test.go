@@ -0,0 +1,212 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+
+	"testing"
+)
+
+var errTestFlaky = errors.New("flaky")
+
+// testFlakyConn is an (in memory) connection, that takes only 'allow' more bytes (and then fails,
+// with a short write) the next time it is written to; after which it takes everything.
+type testFlakyConn struct {
+	mutex   sync.Mutex
+	allow   int
+	failing bool
+	sent    bytes.Buffer
+}
+
+func (conn *testFlakyConn) FailAfter(allow int) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.allow, conn.failing = allow, true
+}
+
+func (*testFlakyConn) Read(p []byte) (int, error) { return 0, net.ErrClosed }
+func (conn *testFlakyConn) Write(p []byte) (int, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.failing && conn.allow < len(p) {
+		conn.failing = false
+		conn.sent.Write(p[:conn.allow])
+		return conn.allow, errTestFlaky
+	}
+	conn.allow -= len(p)
+	conn.sent.Write(p)
+	return len(p), nil
+}
+func (*testFlakyConn) Close() error         { return nil }
+func (*testFlakyConn) LocalAddr() net.Addr  { return nil }
+func (*testFlakyConn) RemoteAddr() net.Addr { return nil }
+
+func (conn *testFlakyConn) Sent() []byte {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	return append([]byte(nil), conn.sent.Bytes()...)
+}
+
+func TestConnUnflushedAfterShortWrite(t *testing.T) {
+
+	// (Escaped, this is: 'a', 'b', IAC, IAC, 'c', 'd'.)
+	data := []byte{'a', 'b', IAC, 'c', 'd'}
+
+	tests := []struct {
+		Allow     int
+		Sent      []byte
+		Unflushed int
+	}{
+		{
+			// (Failing before the escaped IAC.)
+			Allow:     2,
+			Sent:      []byte{'a', 'b'},
+			Unflushed: 3,
+		},
+		{
+			// (Failing between the 2 bytes of the escaped IAC.)
+			Allow:     3,
+			Sent:      []byte{'a', 'b', IAC},
+			Unflushed: 3,
+		},
+		{
+			// (Failing after the escaped IAC.)
+			Allow:     4,
+			Sent:      []byte{'a', 'b', IAC, IAC},
+			Unflushed: 2,
+		},
+		{
+			Allow:     0,
+			Sent:      []byte{},
+			Unflushed: 5,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var c testFlakyConn
+		conn := newConn(&c, nil)
+
+		c.FailAfter(test.Allow)
+
+		n, err := conn.Write(data)
+		if !errors.Is(err, errTestFlaky) {
+			t.Errorf("For test #%d, expected the error, but actually got: (%T) %v", testNumber, err, err)
+		}
+		// (All of it was taken; what was not sent is still buffered.)
+		if expected, actual := len(data), n; expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be written, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := test.Sent, c.Sent(); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v to be sent, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := test.Unflushed, conn.Unflushed(); expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be unflushed, but actually got %d.", testNumber, expected, actual)
+		}
+
+		// (Flushing again continues from where it stopped.)
+		if err := conn.Flush(); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := []byte{'a', 'b', IAC, IAC, 'c', 'd'}, c.Sent(); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v to be sent, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := 0, conn.Unflushed(); expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be unflushed, but actually got %d.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnWriteRetryAfterShortWrite(t *testing.T) {
+
+	data := []byte{'a', 'b', IAC, 'c', 'd', IAC}
+
+	tests := []struct {
+		Allow     int
+		N         int
+		Unflushed int
+	}{
+		{
+			// (The buffer (of 4 bytes) was full, so the rest was not taken.)
+			Allow:     2,
+			N:         3,
+			Unflushed: 1,
+		},
+		{
+			Allow:     3,
+			N:         3,
+			Unflushed: 1,
+		},
+		{
+			// (The first 4 bytes were sent; the rest was taken, but not sent.)
+			Allow:     4,
+			N:         6,
+			Unflushed: 3,
+		},
+	}
+
+	for testNumber, test := range tests {
+		var c testFlakyConn
+		conn := newConn(&c, nil)
+		conn.dataWriter.wrapped.size = 4
+
+		c.FailAfter(test.Allow)
+
+		n, err := conn.Write(data)
+		if !errors.Is(err, errTestFlaky) {
+			t.Errorf("For test #%d, expected the error, but actually got: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := test.N, n; expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be written, but actually got %d.", testNumber, expected, actual)
+		}
+		if expected, actual := test.Unflushed, conn.Unflushed(); expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be unflushed, but actually got %d.", testNumber, expected, actual)
+		}
+
+		// (Writing the rest again, and flushing, sends it all; once each.)
+		if m, err := conn.Write(data[n:]); nil != err || len(data)-n != m {
+			t.Errorf("For test #%d, expected %d byte(s) to be written (and no error), but actually got %d: %v", testNumber, len(data)-n, m, err)
+		}
+		if err := conn.Flush(); nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if expected, actual := []byte{'a', 'b', IAC, IAC, 'c', 'd', IAC, IAC}, c.Sent(); !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %v to be sent, but actually got %v.", testNumber, expected, actual)
+		}
+		if expected, actual := 0, conn.Unflushed(); expected != actual {
+			t.Errorf("For test #%d, expected %d byte(s) to be unflushed, but actually got %d.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnWriteRetryAfterShortWriteTranscoded(t *testing.T) {
+
+	var c testFlakyConn
+	conn := newConn(&c, nil)
+	conn.SetFallbackCharset(Latin1)
+	conn.SetFallbackTranscoding(true)
+	conn.dataWriter.wrapped.size = 1
+
+	c.FailAfter(0)
+
+	p := []byte("a\xc3\xa9b")
+	n, err := conn.Write(p)
+	if !errors.Is(err, errTestFlaky) {
+		t.Errorf("Expected the error, but actually got: (%T) %v", err, err)
+	}
+	// (Only the "a" was taken; as the buffer was full.)
+	if expected, actual := 1, n; expected != actual {
+		t.Errorf("Expected %d byte(s) to be written, but actually got %d.", expected, actual)
+	}
+
+	if m, err := conn.Write(p[n:]); nil != err || len(p)-n != m {
+		t.Errorf("Expected %d byte(s) to be written (and no error), but actually got %d: %v", len(p)-n, m, err)
+	}
+	if expected, actual := []byte("a\xe9b"), c.Sent(); !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %q to be sent, but actually got %q.", expected, actual)
+	}
+}
@@ -0,0 +1,229 @@
+package telnet
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolOverflow is what a Server (with a WorkerPool) does with a connection it accepts, while the
+// queue of the WorkerPool is full.
+type PoolOverflow int
+
+const (
+	// PoolOverflowBlock has the Server wait (before accepting any more connections) until there is
+	// room in the queue. This is the default.
+	PoolOverflowBlock PoolOverflow = iota
+
+	// PoolOverflowReject has the Server send the connection the Banner of the WorkerPool, and
+	// then close it.
+	PoolOverflowReject
+)
+
+// defaultWorkerPoolSize is how many workers a WorkerPool has, if its Size is not set.
+const defaultWorkerPoolSize = 64
+
+// defaultPoolBanner is what is sent to a connection that is rejected (with PoolOverflowReject),
+// if the Banner of the WorkerPool is not set.
+const defaultPoolBanner = "Too many connections; please try again later.\r\n"
+
+// WorkerPool has a Server serve the connections it accepts with a (bounded) pool of goroutines;
+// rather than with a goroutine for each connection. This is for servers that get spikes of (short)
+// connections (such as from monitoring sweeps); so that a spike does not (momentarily) start
+// thousands of goroutines. For example:
+//
+//	server := &telnet.Server{
+//		Addr:    ":5555",
+//		Handler: handler,
+//		WorkerPool: &telnet.WorkerPool{
+//			Size:        100,
+//			QueueLength: 1000,
+//			Overflow:    telnet.PoolOverflowReject,
+//			DetachAfter: 30 * time.Second,
+//		},
+//	}
+//
+// A long session would keep its worker busy for as long as it lasts; and enough of them would
+// starve the queue. So, once a session has lasted DetachAfter, it is detached; i.e., it no longer
+// counts as one of the workers, and another worker is started in its place.
+type WorkerPool struct {
+	// Size is how many workers (i.e., goroutines serving connections) there are. If zero, it is 64.
+	Size int
+
+	// QueueLength is how many accepted connections can wait for a worker.
+	QueueLength int
+
+	// Overflow is what is done with a connection that is accepted while the queue is full.
+	Overflow PoolOverflow
+
+	// Banner is what is sent to a connection that is rejected (with PoolOverflowReject). If empty,
+	// it is "Too many connections; please try again later.\r\n".
+	Banner string
+
+	// DetachAfter (if not zero) is how long a session can last, before it is detached from the
+	// pool (and another worker started in its place).
+	DetachAfter time.Duration
+}
+
+// PoolStats are how much of the WorkerPool of a Server is being used.
+type PoolStats struct {
+	Workers  int    // How many workers there are. (Not including the detached sessions.)
+	Busy     int    // How many of the workers are serving a connection.
+	Detached int    // How many sessions have been detached (and are still going).
+	Queued   int    // How many connections are waiting for a worker.
+	Served   uint64 // How many connections have been served (or are being served).
+	Rejected uint64 // How many connections have been rejected; because the queue was full.
+}
+
+// internalWorkerPool is (the running of) a WorkerPool; for one call of Serve.
+type internalWorkerPool struct {
+	config  WorkerPool
+	server  *Server
+	handler ContextHandler
+	queue   chan net.Conn
+
+	busy     atomic.Int64
+	detached atomic.Int64
+	served   atomic.Uint64
+	rejected atomic.Uint64
+}
+
+func newWorkerPool(config WorkerPool, server *Server, handler ContextHandler) *internalWorkerPool {
+	if config.Size <= 0 {
+		config.Size = defaultWorkerPoolSize
+	}
+	if config.QueueLength < 0 {
+		config.QueueLength = 0
+	}
+	if "" == config.Banner {
+		config.Banner = defaultPoolBanner
+	}
+
+	pool := internalWorkerPool{
+		config:  config,
+		server:  server,
+		handler: handler,
+		queue:   make(chan net.Conn, config.QueueLength),
+	}
+	for i := 0; i < config.Size; i++ {
+		go pool.work()
+	}
+
+	return &pool
+}
+
+// dispatch hands 'c' to a worker; or, if the queue is full, does what the Overflow says to.
+func (pool *internalWorkerPool) dispatch(c net.Conn) {
+	if PoolOverflowReject != pool.config.Overflow {
+		pool.queue <- c
+		return
+	}
+
+	select {
+	case pool.queue <- c:
+	default:
+		pool.reject(c)
+	}
+}
+
+// reject sends 'c' the banner, and closes it (with ClosePolicyRejected).
+func (pool *internalWorkerPool) reject(c net.Conn) {
+	pool.rejected.Add(1)
+
+	server := pool.server
+	server.logger().Debugf("Rejected connection from %q; the worker pool queue is full.", c.RemoteAddr())
+
+	// (It was counted (see ConnectionLimits), when it was accepted.)
+	server.connections.release(c.RemoteAddr())
+	server.reject(c, pool.config.Banner, "worker pool queue full")
+}
+
+// close has the workers stop, once they have served what is (still) queued.
+func (pool *internalWorkerPool) close() {
+	close(pool.queue)
+}
+
+// work serves the queued connections, one after another; until the pool is closed, or the
+// session it is serving is detached.
+func (pool *internalWorkerPool) work() {
+	for c := range pool.queue {
+		pool.served.Add(1)
+		pool.busy.Add(1)
+
+		if !pool.serve(c) {
+			// (This goroutine was the detached session; a replacement worker was started.)
+			pool.detached.Add(-1)
+			return
+		}
+
+		pool.busy.Add(-1)
+	}
+}
+
+// serve serves 'c'; and returns false if the session was detached (part way through).
+func (pool *internalWorkerPool) serve(c net.Conn) bool {
+	if pool.config.DetachAfter <= 0 {
+		pool.server.handle(c, pool.handler)
+		return true
+	}
+
+	const (
+		attached int32 = iota
+		detached
+		finished
+	)
+
+	var mutex sync.Mutex
+	state := attached
+
+	timer := time.AfterFunc(pool.config.DetachAfter, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if attached != state {
+			return
+		}
+		state = detached
+
+		pool.busy.Add(-1)
+		pool.detached.Add(1)
+		go pool.work()
+	})
+
+	pool.server.handle(c, pool.handler)
+	timer.Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if detached == state {
+		return false
+	}
+	state = finished
+	return true
+}
+
+func (pool *internalWorkerPool) stats() PoolStats {
+	return PoolStats{
+		Workers:  pool.config.Size,
+		Busy:     int(pool.busy.Load()),
+		Detached: int(pool.detached.Load()),
+		Queued:   len(pool.queue),
+		Served:   pool.served.Load(),
+		Rejected: pool.rejected.Load(),
+	}
+}
+
+// PoolStats returns how much of the WorkerPool is being used (by the latest call of Serve).
+// (If there is no WorkerPool, then it is the zero PoolStats.)
+func (server *Server) PoolStats() PoolStats {
+	server.poolMutex.Lock()
+	pool := server.pool
+	server.poolMutex.Unlock()
+
+	if nil == pool {
+		return PoolStats{}
+	}
+	return pool.stats()
+}
@@ -0,0 +1,194 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"testing"
+)
+
+// testPoolHandler tells (on 'started') when each session starts; and each session lasts until the
+// client sends something (or closes the connection).
+type testPoolHandler struct {
+	started chan struct{}
+}
+
+func (handler testPoolHandler) ServeTELNET(ctx Context, w Writer, r Reader) {
+	handler.started <- struct{}{}
+
+	var buffer [1]byte
+	r.Read(buffer[:])
+}
+
+// testWaitForPoolStats waits (for up to a second) for 'ok' to return true for the server's PoolStats.
+func testWaitForPoolStats(t *testing.T, server *Server, ok func(PoolStats) bool) PoolStats {
+	t.Helper()
+
+	var stats PoolStats
+	for begin := time.Now(); time.Since(begin) < time.Second; time.Sleep(5 * time.Millisecond) {
+		if stats = server.PoolStats(); ok(stats) {
+			return stats
+		}
+	}
+	t.Errorf("Timed out waiting for the pool stats; they are actually: %+v", stats)
+	return stats
+}
+
+func testDial(t *testing.T, listener net.Listener) net.Conn {
+	t.Helper()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	return client
+}
+
+func TestServerWorkerPool(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := testPoolHandler{started: make(chan struct{}, 8)}
+	server := &Server{
+		Handler: handler,
+		WorkerPool: &WorkerPool{
+			Size:        2,
+			QueueLength: 1,
+			Overflow:    PoolOverflowReject,
+		},
+	}
+	go server.Serve(listener)
+
+	// (The first 2 are served; the 3rd is queued; and the 4th is rejected.)
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		clients = append(clients, testDial(t, listener))
+		<-handler.started
+	}
+	clients = append(clients, testDial(t, listener))
+	testWaitForPoolStats(t, server, func(stats PoolStats) bool { return 1 == stats.Queued })
+
+	rejected := testDial(t, listener)
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	banner, err := io.ReadAll(rejected)
+	if nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := defaultPoolBanner, string(banner); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	expected := PoolStats{Workers: 2, Busy: 2, Queued: 1, Served: 2, Rejected: 1}
+	if actual := server.PoolStats(); expected != actual {
+		t.Errorf("Expected %+v, but actually got %+v.", expected, actual)
+	}
+
+	// Once a session ends, the queued connection is served.
+	clients[0].Write([]byte("q"))
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the queued connection to be served.")
+	}
+
+	expected = PoolStats{Workers: 2, Busy: 2, Served: 3, Rejected: 1}
+	testWaitForPoolStats(t, server, func(actual PoolStats) bool { return expected == actual })
+
+	for _, client := range clients[1:] {
+		client.Write([]byte("q"))
+	}
+	expected = PoolStats{Workers: 2, Served: 3, Rejected: 1}
+	testWaitForPoolStats(t, server, func(actual PoolStats) bool { return expected == actual })
+}
+
+func TestServerWorkerPoolDetach(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := testPoolHandler{started: make(chan struct{}, 8)}
+	server := &Server{
+		Handler: handler,
+		WorkerPool: &WorkerPool{
+			Size:        1,
+			QueueLength: 4,
+			DetachAfter: 50 * time.Millisecond,
+		},
+	}
+	go server.Serve(listener)
+
+	long := testDial(t, listener)
+	<-handler.started
+
+	// (With the only worker busy with the long session, this waits; until the long session is
+	// detached.)
+	begin := time.Now()
+	short := testDial(t, listener)
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the queued connection to be served.")
+	}
+	if elapsed := time.Since(begin); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the queued connection to wait about 50ms, but actually waited %v.", elapsed)
+	}
+
+	expected := PoolStats{Workers: 1, Busy: 1, Detached: 1, Served: 2}
+	testWaitForPoolStats(t, server, func(actual PoolStats) bool { return expected == actual })
+
+	short.Write([]byte("q"))
+	long.Write([]byte("q"))
+
+	expected = PoolStats{Workers: 1, Served: 2}
+	testWaitForPoolStats(t, server, func(actual PoolStats) bool { return expected == actual })
+}
+
+func TestServerWorkerPoolBlock(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	defer listener.Close()
+
+	handler := testPoolHandler{started: make(chan struct{}, 8)}
+	server := &Server{
+		Handler:    handler,
+		WorkerPool: &WorkerPool{Size: 1},
+	}
+	go server.Serve(listener)
+
+	first := testDial(t, listener)
+	<-handler.started
+
+	// (With PoolOverflowBlock, the connection is not rejected; it waits for the worker.)
+	second := testDial(t, listener)
+	select {
+	case <-handler.started:
+		t.Errorf("Did not expect the second connection to be served yet.")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Write([]byte("q"))
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the second connection to be served.")
+	}
+	second.Write([]byte("q"))
+
+	expected := PoolStats{Workers: 1, Served: 2}
+	testWaitForPoolStats(t, server, func(actual PoolStats) bool { return expected == actual })
+}
@@ -0,0 +1,129 @@
+package telnet
+
+import (
+	"time"
+)
+
+// SetWriteCoalescing sets writes (with Write) to be held back for (up to) 'delay', rather than be
+// sent right away; so that a burst of small writes (such as a prompt, a color code, some text, and
+// a reset) is sent together, in one TCP segment, rather than in one each. For example:
+//
+//	conn.SetWriteCoalescing(2*time.Millisecond, 0)
+//
+// What is written is sent once 'delay' has passed since the first write that has not been sent
+// yet; or once (at least) 'threshold' bytes are waiting to be sent; whichever comes first. (If
+// 'threshold' is zero, or more than the size of the write buffer (4096 bytes), then it is the
+// size of the write buffer.)
+//
+// Flush, (and Close, and sending a TELNET command) send what is being held back right away.
+//
+// A negative 'delay' holds back what is written until Flush is called (or until 'threshold' bytes
+// are waiting to be sent); for callers that would rather decide for themselves when it is sent.
+// For example:
+//
+//	conn.SetWriteCoalescing(-1, 0)
+//
+//	for _, line := range lines {
+//		conn.Write(line)
+//	}
+//	if err := conn.Flush(); nil != err {
+//		return err
+//	}
+//
+// A 'delay' of zero (the default) turns it off; which also sends what is being held back.
+func (clientConn *Conn) SetWriteCoalescing(delay time.Duration, threshold int) {
+	clientConn.dataWriter.setCoalescing(delay, threshold)
+}
+
+// Flush sends whatever has been written, but is still buffered (such as because of
+// SetWriteCoalescing), or held back by a WriteFilter (see AddWriteFilter); waiting for whatever
+// is being written right now to be done first.
+func (clientConn *Conn) Flush() error {
+	if err := clientConn.flushWriteFilters(); nil != err {
+		return err
+	}
+	return clientConn.dataWriter.flush()
+}
+
+// internalCoalescer holds off flushing an internalDataWriter; until a delay has passed (since the
+// first write that was not flushed), or enough is buffered. It (re)uses a single timer; rather
+// than a goroutine for each write.
+//
+// (The wireMutex of the internalDataWriter must be held, to use it.)
+type internalCoalescer struct {
+	delay     time.Duration
+	threshold int
+	timer     *time.Timer
+	armed     bool
+}
+
+// stop stops the timer; as what is buffered is being flushed (by something else).
+func (coalescer *internalCoalescer) stop() {
+	if !coalescer.armed {
+		return
+	}
+
+	coalescer.timer.Stop()
+	coalescer.armed = false
+}
+
+func (w *internalDataWriter) setCoalescing(delay time.Duration, threshold int) {
+	w.wireMutex.Lock()
+	defer w.unlockWire()
+
+	if threshold <= 0 || w.wrapped.Size() < threshold {
+		threshold = w.wrapped.Size()
+	}
+
+	w.coalescer.delay = delay
+	w.coalescer.threshold = threshold
+
+	if delay <= 0 {
+		w.coalescer.stop()
+	}
+	if 0 == delay {
+		w.wrapped.Flush()
+	}
+}
+
+// written is called once data has been written into the buffer; and flushes it, unless (write)
+// coalescing is on, in which case it might (instead) start the timer to flush it later. It
+// returns what flushing returned. (The wireMutex must be held.)
+func (w *internalDataWriter) written() error {
+	coalescer := &w.coalescer
+
+	if 0 == coalescer.delay || coalescer.threshold <= w.wrapped.Buffered() {
+		coalescer.stop()
+		return w.wrapped.Flush()
+	}
+	if coalescer.delay < 0 {
+		// (It waits for Flush.)
+		return nil
+	}
+
+	if coalescer.armed {
+		return nil
+	}
+	coalescer.armed = true
+
+	if nil == coalescer.timer {
+		coalescer.timer = time.AfterFunc(coalescer.delay, w.coalescedFlush)
+	} else {
+		coalescer.timer.Reset(coalescer.delay)
+	}
+	return nil
+}
+
+// coalescedFlush is called by the timer (see written).
+func (w *internalDataWriter) coalescedFlush() {
+	w.wireMutex.Lock()
+	defer w.unlockWire()
+
+	// (Something else flushed, after the timer went off, but before we got the mutex.)
+	if !w.coalescer.armed {
+		return
+	}
+	w.coalescer.armed = false
+
+	w.wrapped.Flush()
+}
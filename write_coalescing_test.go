@@ -0,0 +1,250 @@
+package telnet
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"testing"
+)
+
+// testSegmentConn is an (in memory) connection, that keeps each Write to it (i.e., each would-be
+// TCP segment) separate.
+type testSegmentConn struct {
+	mutex    sync.Mutex
+	segments [][]byte
+}
+
+func (*testSegmentConn) Read(p []byte) (int, error) { return 0, net.ErrClosed }
+func (conn *testSegmentConn) Write(p []byte) (int, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.segments = append(conn.segments, append([]byte(nil), p...))
+	return len(p), nil
+}
+func (*testSegmentConn) Close() error         { return nil }
+func (*testSegmentConn) LocalAddr() net.Addr  { return nil }
+func (*testSegmentConn) RemoteAddr() net.Addr { return nil }
+
+func (conn *testSegmentConn) Segments() []string {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	var segments []string
+	for _, segment := range conn.segments {
+		segments = append(segments, string(segment))
+	}
+	return segments
+}
+
+// testBurst is a (typical) burst of writes; a prompt, a color code, some text, and a reset.
+var testBurst = []string{"\r\n> ", "\x1b[1;32m", "ready \xff", "\x1b[0m"}
+
+func TestConnWriteCoalescing(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+	conn.SetWriteCoalescing(20*time.Millisecond, 0)
+
+	begin := time.Now()
+	for _, s := range testBurst {
+		conn.Write([]byte(s))
+	}
+
+	if actual := c.Segments(); 0 != len(actual) {
+		t.Errorf("Expected nothing to be sent yet, but actually got %q.", actual)
+	}
+
+	var actual []string
+	for time.Since(begin) < time.Second {
+		if actual = c.Segments(); 0 < len(actual) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(begin)
+
+	if expected := []string{"\r\n> \x1b[1;32mready \xff\xff\x1b[0m"}; !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+	if elapsed < 15*time.Millisecond || 500*time.Millisecond < elapsed {
+		t.Errorf("Expected it to be sent after about 20ms, but actually took %v.", elapsed)
+	}
+}
+
+func TestConnWriteCoalescingThreshold(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+	conn.SetWriteCoalescing(time.Hour, 10)
+
+	conn.Write([]byte("123456"))
+	conn.Write([]byte("789012"))
+	conn.Write([]byte("345"))
+
+	// (Once 10 bytes are waiting, they are sent right away.)
+	if expected, actual := []string{"123456789012"}, c.Segments(); !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWriteCoalescingFlush(t *testing.T) {
+
+	tests := []struct {
+		Flush    func(conn *Conn)
+		Expected []string
+	}{
+		{
+			Flush:    func(conn *Conn) { conn.Flush() },
+			Expected: []string{"hello"},
+		},
+		{
+			Flush:    func(conn *Conn) { conn.Close() },
+			Expected: []string{"hello"},
+		},
+		{
+			// (A TELNET command is not held back; nor is what was written before it.)
+			Flush:    func(conn *Conn) { conn.SendCommand(NOP) },
+			Expected: []string{"hello\xff\xf1"},
+		},
+		{
+			Flush:    func(conn *Conn) { conn.SetWriteCoalescing(0, 0) },
+			Expected: []string{"hello"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		var c testSegmentConn
+		conn := newConn(&c, nil)
+		conn.SetWriteCoalescing(30*time.Millisecond, 0)
+
+		conn.Write([]byte("hello"))
+		test.Flush(conn)
+
+		if actual := c.Segments(); !testEqualStrings(test.Expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, test.Expected, actual)
+		}
+
+		// (Nothing more is sent, once the delay has passed; the timer was stopped.)
+		time.Sleep(60 * time.Millisecond)
+		if actual := c.Segments(); !testEqualStrings(test.Expected, actual) {
+			t.Errorf("For test #%d, expected still %q, but actually got %q.", testNumber, test.Expected, actual)
+		}
+	}
+}
+
+func TestConnWriteCoalescingOff(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+
+	for _, s := range testBurst {
+		conn.Write([]byte(s))
+	}
+
+	// (Each write is sent right away; with an escaped IAC sent together, rather than split.)
+	if expected, actual := []string{"\r\n> ", "\x1b[1;32m", "ready \xff\xff", "\x1b[0m"}, c.Segments(); !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWriteCoalescingManual(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+	conn.SetWriteCoalescing(-1, 0)
+
+	for _, s := range testBurst {
+		n, err := conn.Write([]byte(s))
+		if nil != err {
+			t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+		}
+		if expected, actual := len(s), n; expected != actual {
+			t.Errorf("Expected %d, but actually got %d.", expected, actual)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if actual := c.Segments(); 0 != len(actual) {
+		t.Errorf("Expected nothing to be sent yet, but actually got %q.", actual)
+	}
+
+	if err := conn.Flush(); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []string{"\r\n> \x1b[1;32mready \xff\xff\x1b[0m"}, c.Segments(); !testEqualStrings(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func testEqualStrings(expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// testCountingConn is an (in memory) connection, that counts the Writes to it (i.e., the would-be
+// TCP segments), and throws away what is written to it.
+type testCountingConn struct {
+	mutex  sync.Mutex
+	writes int
+}
+
+func (*testCountingConn) Read(p []byte) (int, error) { return 0, net.ErrClosed }
+func (conn *testCountingConn) Write(p []byte) (int, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.writes++
+	return len(p), nil
+}
+func (*testCountingConn) Close() error         { return nil }
+func (*testCountingConn) LocalAddr() net.Addr  { return nil }
+func (*testCountingConn) RemoteAddr() net.Addr { return nil }
+
+func benchmarkConnWriteBurst(b *testing.B, delay time.Duration) {
+
+	var c testCountingConn
+	conn := newConn(&c, nil)
+	conn.SetWriteCoalescing(delay, 0)
+
+	burst := make([][]byte, len(testBurst))
+	for i, s := range testBurst {
+		burst[i] = []byte(s)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range burst {
+			conn.Write(p)
+		}
+	}
+	conn.Flush()
+	b.StopTimer()
+
+	c.mutex.Lock()
+	b.ReportMetric(float64(c.writes)/float64(b.N), "segments/op")
+	c.mutex.Unlock()
+}
+
+// BenchmarkConnWriteBurst writes bursts of small writes; with each write sent right away.
+func BenchmarkConnWriteBurst(b *testing.B) {
+	benchmarkConnWriteBurst(b, 0)
+}
+
+// BenchmarkConnWriteBurstCoalesced writes bursts of small writes; with write coalescing on.
+func BenchmarkConnWriteBurstCoalesced(b *testing.B) {
+	benchmarkConnWriteBurst(b, 2*time.Millisecond)
+}
+
+// BenchmarkConnWriteBurstManual writes bursts of small writes; with them held back until Flush.
+func BenchmarkConnWriteBurstManual(b *testing.B) {
+	benchmarkConnWriteBurst(b, -1)
+}
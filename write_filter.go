@@ -0,0 +1,242 @@
+package telnet
+
+// A WriteFilter transforms the data written to a Conn, before it is sent; such as to strip ANSI
+// escape sequences (see StripANSI), to redact secrets, or to translate newlines. (See
+// Conn.AddWriteFilter.)
+//
+// A WriteFilter is given the data as a stream; so what it is looking for (such as an escape
+// sequence, or a secret) can be split across (2 or more) calls of Write. Write can therefore hold
+// back (the start of) whatever it can not decide on yet; and return it from a later Write, or from
+// Flush. For a filter that does not need to (i.e., that only ever looks at a byte at a time, or
+// that does not mind splits), see WriteFilterFunc.
+//
+// Only data is given to a WriteFilter; not TELNET commands (nor option negotiations, nor
+// subnegotiations). It is given the data as written (i.e., as UTF-8, if the Conn is transcoding;
+// see SetFallbackTranscoding), before it is transcoded; and what it returns is escaped
+// afterwards, so it does not have to do with IAC (either).
+//
+// (Its methods are only called by 1 goroutine at a time.)
+type WriteFilter interface {
+	// Write returns what 'p' turns into. It must not change 'p', nor keep it (after it
+	// returns); but what it returns can be 'p' itself (or part of it), and only has to stay
+	// as it is until the next call of Write, or Flush.
+	Write(p []byte) []byte
+
+	// Flush returns whatever Write has been holding back. It is called by Conn.Flush (and
+	// CloseGracefully), and when the WriteFilter is removed.
+	Flush() []byte
+}
+
+// WriteFilterFunc adapts a (stateless) function into a WriteFilter; which never holds anything
+// back. For example:
+//
+//	conn.AddWriteFilter(telnet.WriteFilterFunc(func(p []byte) []byte {
+//		return bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))
+//	}))
+type WriteFilterFunc func(p []byte) []byte
+
+// Write calls the function.
+func (fn WriteFilterFunc) Write(p []byte) []byte {
+	return fn(p)
+}
+
+// Flush returns nothing; as nothing is held back.
+func (WriteFilterFunc) Flush() []byte {
+	return nil
+}
+
+// internalWriteFilter is a WriteFilter that was added (so it can be removed; even if the same
+// WriteFilter was added more than once).
+type internalWriteFilter struct {
+	filter WriteFilter
+}
+
+// AddWriteFilter has the data written to the Conn (see Write) be passed through 'filter' before
+// it is sent; after the WriteFilters added before it. It returns a function that removes
+// it again. For example:
+//
+//	remove := conn.AddWriteFilter(telnet.StripANSI())
+//	defer remove()
+//
+// Removing it sends whatever it was holding back (see WriteFilter.Flush); through the
+// WriteFilters added after it. (Calling the function more than once does nothing more.)
+//
+// What is broadcast (see Broadcaster) is not passed through it; as it is shared by the members.
+func (clientConn *Conn) AddWriteFilter(filter WriteFilter) (remove func()) {
+	added := &internalWriteFilter{filter: filter}
+
+	clientConn.transcodeMutex.Lock()
+	clientConn.writeFilters = append(clientConn.writeFilters, added)
+	clientConn.transcodeMutex.Unlock()
+
+	return func() {
+		clientConn.removeWriteFilter(added)
+	}
+}
+
+func (clientConn *Conn) removeWriteFilter(removed *internalWriteFilter) {
+	clientConn.transcodeMutex.Lock()
+	defer clientConn.transcodeMutex.Unlock()
+
+	for k, added := range clientConn.writeFilters {
+		if removed != added {
+			continue
+		}
+
+		clientConn.writeFilters = append(clientConn.writeFilters[:k], clientConn.writeFilters[k+1:]...)
+
+		data := removed.filter.Flush()
+		for _, later := range clientConn.writeFilters[k:] {
+			if 0 == len(data) {
+				break
+			}
+			data = later.filter.Write(data)
+		}
+		if 0 < len(data) && clientConn.translatingWritten() {
+			data = clientConn.translateWritten(data)
+		}
+		if 0 < len(data) {
+			if _, err := clientConn.writeTranscoded(data); nil != err {
+				clientConn.logger.Debugf("Problem writing what a write filter was holding back, when removing it: %v", err)
+			}
+		}
+		return
+	}
+}
+
+// filterWritten passes 'p' through the WriteFilters; in order. (The transcodeMutex must be held.)
+func (clientConn *Conn) filterWritten(p []byte) []byte {
+	for _, added := range clientConn.writeFilters {
+		if 0 == len(p) {
+			break
+		}
+		p = added.filter.Write(p)
+	}
+
+	return p
+}
+
+// flushWriteFilters writes whatever the WriteFilters have been holding back. (Each filter's is
+// passed through the ones after it.)
+func (clientConn *Conn) flushWriteFilters() error {
+	clientConn.transcodeMutex.Lock()
+	defer clientConn.transcodeMutex.Unlock()
+
+	if 0 == len(clientConn.writeFilters) {
+		return nil
+	}
+
+	var data []byte
+	for _, added := range clientConn.writeFilters {
+		if 0 < len(data) {
+			data = append([]byte(nil), added.filter.Write(data)...)
+		}
+		data = append(data, added.filter.Flush()...)
+	}
+	if 0 == len(data) {
+		return nil
+	}
+	if clientConn.translatingWritten() {
+		data = clientConn.translateWritten(data)
+	}
+
+	_, err := clientConn.writeTranscoded(data)
+	return err
+}
+
+// StripANSI returns a WriteFilter that removes ANSI (i.e., ECMA-48) escape sequences from the data;
+// such as color codes, and cursor movement. For example:
+//
+//	"\x1b[1;31mError:\x1b[0m file not found" -> "Error: file not found"
+//
+// This is for clients (such as dumb terminals, and scripts) that do not understand them. It
+// removes CSI sequences (ESC [ ... final byte), string sequences (such as OSC; ESC ] ... BEL, or
+// ESC \), and the other ESC sequences (ESC, any intermediate bytes, then a final byte); even
+// when they are split across writes. (As the data is UTF-8, the 8-bit (C1) forms are left alone.)
+//
+// Each Conn needs its own; as it keeps track of the escape sequence it is in the middle of.
+func StripANSI() WriteFilter {
+	return &internalANSIStripper{}
+}
+
+const (
+	ansiGround = iota
+	ansiEscape
+	ansiIntermediate
+	ansiCSI
+	ansiString
+	ansiStringEscape
+)
+
+const (
+	ansiESC = 0x1b
+	ansiBEL = 0x07
+)
+
+// internalANSIStripper is a state machine, of where in an escape sequence the data is. (It does
+// not need to hold anything back; as what is in an escape sequence is thrown away anyway.)
+type internalANSIStripper struct {
+	state int
+}
+
+func (stripper *internalANSIStripper) Write(p []byte) []byte {
+	filtered := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch stripper.state {
+		case ansiGround:
+			if ansiESC == b {
+				stripper.state = ansiEscape
+				continue
+			}
+			filtered = append(filtered, b)
+		case ansiEscape:
+			switch {
+			case '[' == b:
+				stripper.state = ansiCSI
+			case ']' == b || 'P' == b || 'X' == b || '^' == b || '_' == b:
+				stripper.state = ansiString
+			case ansiESC == b:
+				// (Still an escape.)
+			case 0x20 <= b && b <= 0x2f:
+				stripper.state = ansiIntermediate
+			default:
+				stripper.state = ansiGround
+			}
+		case ansiIntermediate:
+			if b < 0x20 || 0x2f < b {
+				stripper.state = ansiGround
+			}
+		case ansiCSI:
+			// (Parameter, and intermediate, bytes are 0x20 to 0x3f; the final byte is 0x40 to 0x7e.
+			// An ESC starts a new escape sequence.)
+			switch {
+			case 0x40 <= b && b <= 0x7e:
+				stripper.state = ansiGround
+			case ansiESC == b:
+				stripper.state = ansiEscape
+			}
+		case ansiString:
+			switch b {
+			case ansiBEL:
+				stripper.state = ansiGround
+			case ansiESC:
+				stripper.state = ansiStringEscape
+			}
+		case ansiStringEscape:
+			switch b {
+			case '\\':
+				stripper.state = ansiGround
+			case ansiESC:
+				// (Still an escape.)
+			default:
+				stripper.state = ansiString
+			}
+		}
+	}
+
+	return filtered
+}
+
+func (stripper *internalANSIStripper) Flush() []byte {
+	return nil
+}
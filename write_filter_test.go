@@ -0,0 +1,187 @@
+package telnet
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"testing"
+)
+
+// testHoldBackFilter redacts "secret"; holding back whatever could be the start of it.
+type testHoldBackFilter struct {
+	held []byte
+}
+
+func (filter *testHoldBackFilter) Write(p []byte) []byte {
+	data := bytes.ReplaceAll(append(filter.held, p...), []byte("secret"), []byte("******"))
+
+	filter.held = nil
+	for k := len("secret") - 1; 0 < k; k-- {
+		if k <= len(data) && bytes.HasSuffix(data, []byte("secret"[:k])) {
+			filter.held = append(filter.held, data[len(data)-k:]...)
+			data = data[:len(data)-k]
+			break
+		}
+	}
+
+	return data
+}
+
+func (filter *testHoldBackFilter) Flush() []byte {
+	held := filter.held
+	filter.held = nil
+	return held
+}
+
+func TestStripANSI(t *testing.T) {
+
+	tests := []struct {
+		Writes   []string
+		Expected string
+	}{
+		{
+			Writes:   []string{"plain text"},
+			Expected: "plain text",
+		},
+		{
+			Writes:   []string{"\x1b[1;31mError:\x1b[0m file not found"},
+			Expected: "Error: file not found",
+		},
+		{
+			// (A CSI sequence split across writes.)
+			Writes:   []string{"red \x1b", "[3", "1mtext\x1b[", "0m!"},
+			Expected: "red text!",
+		},
+		{
+			Writes:   []string{"a", "\x1b", "[", "2", "J", "b"},
+			Expected: "ab",
+		},
+		{
+			// (An OSC sequence; ended by BEL, or by ESC \.)
+			Writes:   []string{"\x1b]0;title\x07shell\x1b]2;other\x1b", "\\$ "},
+			Expected: "shell$ ",
+		},
+		{
+			// (Other escape sequences; with, and without, intermediate bytes.)
+			Writes:   []string{"\x1b(Bx\x1b7y\x1b8z\x1b=", "!"},
+			Expected: "xyz!",
+		},
+		{
+			// (An ESC, in the middle of a CSI sequence, starts a new one.)
+			Writes:   []string{"\x1b[1\x1b[0mok"},
+			Expected: "ok",
+		},
+		{
+			// (UTF-8 is left alone.)
+			Writes:   []string{"\x1b[1mcafé\xe2\x9b\x84\x1b[m"},
+			Expected: "café\xe2\x9b\x84",
+		},
+	}
+
+	for testNumber, test := range tests {
+		filter := StripANSI()
+
+		var actual []byte
+		for _, s := range test.Writes {
+			p := []byte(s)
+			actual = append(actual, filter.Write(p)...)
+
+			if expected := s; expected != string(p) {
+				t.Errorf("For test #%d, expected what was written to not be changed, but actually it became %q.", testNumber, p)
+			}
+		}
+		actual = append(actual, filter.Flush()...)
+
+		if expected := test.Expected; expected != string(actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestConnAddWriteFilter(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+
+	// (The filters are applied in the order they were added.)
+	conn.AddWriteFilter(StripANSI())
+	conn.AddWriteFilter(WriteFilterFunc(func(p []byte) []byte {
+		return bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))
+	}))
+	conn.AddWriteFilter(WriteFilterFunc(func(p []byte) []byte {
+		return bytes.ReplaceAll(p, []byte("\x1b"), []byte("<ESC>"))
+	}))
+
+	for _, s := range []string{"\x1b[1mbold\x1b", "[0m\n", "\xff\n"} {
+		if n, err := conn.Write([]byte(s)); nil != err || len(s) != n {
+			t.Errorf("Expected to write %d bytes (and no error), but actually wrote %d: %v", len(s), n, err)
+		}
+	}
+
+	// (What the filters return is escaped afterwards.)
+	if expected, actual := "bold\r\n\xff\xff\r\n", strings.Join(c.Segments(), ""); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnAddWriteFilterHoldsBack(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+
+	conn.AddWriteFilter(&testHoldBackFilter{})
+
+	for _, s := range []string{"the se", "cret is sec", "re"} {
+		conn.Write([]byte(s))
+	}
+
+	if expected, actual := "the ****** is ", strings.Join(c.Segments(), ""); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if err := conn.Flush(); nil != err {
+		t.Errorf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+
+	if expected, actual := "the ****** is secre", strings.Join(c.Segments(), ""); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnRemoveWriteFilter(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+
+	remove := conn.AddWriteFilter(&testHoldBackFilter{})
+	conn.AddWriteFilter(WriteFilterFunc(bytes.ToUpper))
+
+	conn.Write([]byte("a secret; a sec"))
+
+	// (What was held back is passed through the filters after it.)
+	remove()
+	remove()
+
+	conn.Write([]byte("ret"))
+
+	if expected, actual := "A ******; A SECRET", strings.Join(c.Segments(), ""); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
+
+func TestConnWriteFilterCloseGracefully(t *testing.T) {
+
+	var c testSegmentConn
+	conn := newConn(&c, nil)
+
+	conn.AddWriteFilter(&testHoldBackFilter{})
+	conn.SetGoodbye("bye, s")
+
+	conn.Write([]byte("s"))
+	conn.CloseGracefully(context.Background())
+
+	if expected, actual := "sbye, s", strings.Join(c.Segments(), ""); expected != actual {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+}
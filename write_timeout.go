@@ -0,0 +1,118 @@
+package telnet
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteTimeout is what writing returns once sending (some of) it to the peer took longer than
+// the write timeout (see SetWriteTimeout); as the peer is not reading (or not fast enough). The
+// Conn is then closed, with the CloseSlowClient reason.
+//
+// It is a timeout error; i.e., its Timeout method returns true, and errors.Is(err,
+// os.ErrDeadlineExceeded) is true for it.
+var ErrWriteTimeout error = internalWriteTimeoutError{}
+
+type internalWriteTimeoutError struct{}
+
+func (internalWriteTimeoutError) Error() string {
+	return "telnet: write timed out; the peer is not reading"
+}
+
+func (internalWriteTimeoutError) Timeout() bool {
+	return true
+}
+
+func (internalWriteTimeoutError) Temporary() bool {
+	return false
+}
+
+func (internalWriteTimeoutError) Unwrap() error {
+	return os.ErrDeadlineExceeded
+}
+
+// SetWriteTimeout sets how long sending what has been written (i.e., each flush of it to the
+// underlying connection) can take; after which the write fails (with ErrWriteTimeout), and the
+// Conn is closed (with the CloseSlowClient reason). This is so that a client that stops reading
+// (such as one on a congested link, once the kernel's send buffer is full) cannot block a Write
+// forever. For example:
+//
+//	conn.SetWriteTimeout(30*time.Second)
+//
+// The timeout starts over with each flush; it is not a deadline for the connection as a whole.
+// (Nor does waiting on the rate limit count towards it; see SetOutputRateLimit.) If the write
+// deadline (see SetWriteDeadline) is sooner, then that is what applies; and when it is what
+// passes, the Conn is not closed.
+//
+// A 'timeout' of zero (the default) turns it off. (See also Server.WriteTimeout.)
+func (clientConn *Conn) SetWriteTimeout(timeout time.Duration) {
+	clientConn.dataWriter.timeout.timeout.Store(int64(timeout))
+}
+
+// internalWriteTimeout is what an internalDataWriter flushes to; and sets a (rolling) write
+// deadline on the underlying connection, before each write to it.
+type internalWriteTimeout struct {
+	conn    internalConn
+	timeout atomic.Int64 // (A time.Duration.)
+
+	// limiter has the write deadline of the Conn. (See Conn.SetWriteDeadline.)
+	limiter *internalRateLimiter
+
+	// deadline (if not zero) is a (sooner) deadline, just for what is being written right now.
+	// (The wireMutex of the internalDataWriter must be held, to use it.)
+	deadline time.Time
+
+	// done is closed once the Conn has been closed; and timedOut closes it (for being slow).
+	done     <-chan struct{}
+	timedOut func()
+}
+
+func (w *internalWriteTimeout) Write(p []byte) (int, error) {
+	timeout := time.Duration(w.timeout.Load())
+	deadliner, ok := w.conn.(interface{ SetWriteDeadline(time.Time) error })
+	if timeout <= 0 || !ok || w.isClosed() {
+		return w.conn.Write(p)
+	}
+
+	deadline := time.Now().Add(timeout)
+	rolling := true
+	if previous := w.limiter.writeDeadline(); !previous.IsZero() && previous.Before(deadline) {
+		deadline, rolling = previous, false
+	}
+	if !w.deadline.IsZero() && w.deadline.Before(deadline) {
+		deadline, rolling = w.deadline, false
+	}
+	deadliner.SetWriteDeadline(deadline)
+
+	n, err := w.conn.Write(p)
+	if !rolling || !os.IsTimeout(err) || w.isClosed() {
+		return n, err
+	}
+	if previous := w.limiter.writeDeadline(); !previous.IsZero() && !time.Now().Before(previous) {
+		// (The write deadline was moved up while writing; such as by WriteContext.)
+		return n, err
+	}
+
+	if nil != w.timedOut {
+		w.timedOut()
+	}
+	return n, ErrWriteTimeout
+}
+
+// writeTimedOut is called once a flush took longer than the write timeout.
+func (clientConn *Conn) writeTimedOut() {
+	timeout := time.Duration(clientConn.dataWriter.timeout.timeout.Load())
+	clientConn.logger.Debugf("Closing connection to %q; a write did not complete within %v.", clientConn.RemoteAddr(), timeout)
+
+	clientConn.CloseWithReason(CloseSlowClient, "")
+}
+
+func (w *internalWriteTimeout) isClosed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
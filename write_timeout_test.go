@@ -0,0 +1,138 @@
+package telnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"testing"
+)
+
+func TestConnWriteTimeout(t *testing.T) {
+
+	tests := []struct {
+		Name      string
+		Reading   bool
+		RateLimit RateLimit
+		Deadline  time.Duration
+		Expected  error
+		Reason    CloseReason
+	}{
+		{
+			Name:     "peer stops reading",
+			Expected: ErrWriteTimeout,
+			Reason:   CloseSlowClient,
+		},
+		{
+			// (The rate limit makes the write take (about) 400ms; much longer than the timeout.
+			// But each flush is done well within it.)
+			Name:      "rate limited",
+			Reading:   true,
+			RateLimit: RateLimit{BytesPerSecond: 1000, Burst: 100},
+		},
+		{
+			// (The write deadline passes before the write timeout would.)
+			Name:     "write deadline",
+			Deadline: 20 * time.Millisecond,
+			Expected: os.ErrDeadlineExceeded,
+		},
+	}
+
+	for testNumber, test := range tests {
+		c, remote := net.Pipe()
+		defer remote.Close()
+		if test.Reading {
+			go io.Copy(io.Discard, remote)
+		}
+
+		conn := newConn(c, nil)
+		defer conn.Close()
+
+		conn.SetWriteTimeout(100 * time.Millisecond)
+		conn.SetOutputRateLimit(test.RateLimit)
+		if 0 < test.Deadline {
+			conn.SetWriteDeadline(time.Now().Add(test.Deadline))
+		}
+
+		begin := time.Now()
+		_, err := conn.Write([]byte(strings.Repeat("x", 500)))
+		elapsed := time.Since(begin)
+
+		switch expected := test.Expected; expected {
+		case nil:
+			if nil != err {
+				t.Errorf("For test #%d (%s), did not expect an error, but actually got one: (%T) %v", testNumber, test.Name, err, err)
+				continue
+			}
+		default:
+			if !errors.Is(err, expected) {
+				t.Errorf("For test #%d (%s), expected the error to be %v, but actually got: (%T) %v", testNumber, test.Name, expected, err, err)
+				continue
+			}
+			if !os.IsTimeout(err) {
+				t.Errorf("For test #%d (%s), expected a timeout error, but actually got: (%T) %v", testNumber, test.Name, err, err)
+			}
+			if time.Second < elapsed {
+				t.Errorf("For test #%d (%s), expected the write to time out quickly, but actually it took %v.", testNumber, test.Name, elapsed)
+			}
+		}
+
+		if expected, actual := CloseSlowClient == test.Reason, conn.isClosed(); expected != actual {
+			t.Errorf("For test #%d (%s), expected the Conn to be closed to be %t, but actually got %t.", testNumber, test.Name, expected, actual)
+			continue
+		}
+		if CloseSlowClient == test.Reason {
+			if expected, actual := test.Reason, testCloseReason(conn); expected != actual {
+				t.Errorf("For test #%d (%s), expected the CloseReason to be %v, but actually got %v.", testNumber, test.Name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestServerWriteTimeout(t *testing.T) {
+
+	reasons := make(chan CloseReason, 1)
+	errs := make(chan error, 1)
+
+	server := &Server{
+		Logger:       internalDiscardLogger{},
+		WriteTimeout: 100 * time.Millisecond,
+		ContextHandler: testContextHandler(func(ctx context.Context, conn *Conn) {
+			p := []byte(strings.Repeat("x", 64*1024))
+			for {
+				if _, err := conn.Write(p); nil != err {
+					errs <- err
+					return
+				}
+			}
+		}),
+		OnDisconnect: func(conn *Conn, reason CloseReason, msg string) {
+			reasons <- reason
+		},
+	}
+
+	// (The client never reads.)
+	testServe(t, server)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrWriteTimeout) {
+			t.Errorf("Expected the error to be ErrWriteTimeout, but actually got: (%T) %v", err, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the write to time out, but actually it did not.")
+	}
+
+	select {
+	case reason := <-reasons:
+		if expected, actual := CloseSlowClient, reason; expected != actual {
+			t.Errorf("Expected the CloseReason to be %v, but actually got %v.", expected, actual)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected OnDisconnect to be called, but actually it was not.")
+	}
+}
@@ -0,0 +1,265 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidZMP is returned by SendZMP when the command is empty, or when the command (or one of
+// its arguments) has a NUL in it; as ZMP has no way of sending that.
+var ErrInvalidZMP = errors.New("telnet: invalid ZMP command")
+
+// The ZMP commands that ZMPOption answers by itself. (See ZMPOption.)
+const (
+	zmpPing      = "zmp.ping"
+	zmpTime      = "zmp.time"
+	zmpIdent     = "zmp.ident"
+	zmpCheck     = "zmp.check"
+	zmpSupport   = "zmp.support"
+	zmpNoSupport = "zmp.no-support"
+	zmpInput     = "zmp.input"
+
+	// zmpTimeLayout is how zmp.time says what time it is (in UTC).
+	zmpTimeLayout = "2006-01-02 15:04:05"
+)
+
+// zmpBuiltins are the commands (of the "zmp." package) that ZMPOption supports; whether or not
+// something was registered for them with OnZMP.
+var zmpBuiltins = map[string]struct{}{
+	zmpPing:      {},
+	zmpTime:      {},
+	zmpIdent:     {},
+	zmpCheck:     {},
+	zmpSupport:   {},
+	zmpNoSupport: {},
+	zmpInput:     {},
+}
+
+// ZMPOption returns an OptionHandler for the ZMP option (the Zenith MUD Protocol); which sends
+// (and receives) commands, each of which is a command name (such as "zmp.ping") followed by any
+// number of arguments, as subnegotiations. For example:
+//
+//	conn.RegisterOption(telnet.OptZMP, telnet.ZMPOption("mymud 1.0"))
+//
+//	conn.OnZMP("color.use", func(args []string) {
+//		//@TODO: Use the colors in 'args'.
+//	})
+//
+// It offers (i.e., sends a WILL for) ZMP right away; as a MUD server does. Once ZMP is enabled, it
+// identifies itself to the peer, by sending zmp.ident with 'ident' (unless 'ident' is empty).
+//
+// It answers the handshake (i.e., the commands of the "zmp." package) by itself: zmp.check with
+// zmp.support (if the command, or package, that is asked about is one of those, or was registered
+// with OnZMP) or zmp.no-support; and zmp.ping with zmp.time. (What was registered with OnZMP for
+// any of them is also called.) Every other command is passed on to what was registered with OnZMP
+// for it; or (if nothing was) ignored.
+//
+// A subnegotiation that is not a ZMP command (i.e., its command is empty, or it does not end with
+// a NUL) is ignored.
+func ZMPOption(ident string) OptionHandler {
+	return &internalZMP{ident: ident}
+}
+
+type internalZMP struct {
+	ident string
+
+	mutex      sync.Mutex
+	sender     OptionSender
+	identified bool
+}
+
+func (zmp *internalZMP) Register(sender OptionSender) OptionSupport {
+	zmp.mutex.Lock()
+	zmp.sender = sender
+	zmp.mutex.Unlock()
+
+	return OptionSupport{Local: true, Remote: true, RequestLocal: true}
+}
+
+func (zmp *internalZMP) LocalChanged(enabled bool) {
+	if enabled {
+		zmp.identify()
+	}
+}
+
+func (zmp *internalZMP) RemoteChanged(enabled bool) {
+	if enabled {
+		zmp.identify()
+	}
+}
+
+func (zmp *internalZMP) Subnegotiation(payload []byte) {
+	zmp.mutex.Lock()
+	sender := zmp.sender
+	zmp.mutex.Unlock()
+
+	if nil == sender {
+		return
+	}
+	conn := sender.Conn()
+
+	command, args, ok := decodeZMP(payload)
+	if !ok {
+		conn.logger.Debugf("Ignoring ZMP subnegotiation, as it is not a ZMP command: %q", payload)
+		return
+	}
+	conn.logger.Tracef("Received ZMP %s %q", command, args)
+
+	switch command {
+	case zmpPing:
+		conn.SendZMP(zmpTime, time.Now().UTC().Format(zmpTimeLayout))
+	case zmpCheck:
+		if 1 <= len(args) {
+			answer := zmpNoSupport
+			if conn.supportsZMP(args[0]) {
+				answer = zmpSupport
+			}
+			conn.SendZMP(answer, args[0])
+		}
+	}
+
+	if fn := conn.zmpHandler(command); nil != fn {
+		fn(args)
+	}
+}
+
+// identify sends zmp.ident (with the ident); unless it has already been sent.
+func (zmp *internalZMP) identify() {
+	zmp.mutex.Lock()
+	sender := zmp.sender
+	identified := zmp.identified
+	zmp.identified = true
+	zmp.mutex.Unlock()
+
+	if nil == sender || identified || "" == zmp.ident {
+		return
+	}
+	sender.Conn().SendZMP(zmpIdent, zmp.ident)
+}
+
+// OnZMP registers 'fn' to be called for each ZMP command 'command' (such as "color.use") that the
+// peer sends; with the command's arguments. This replaces whatever was registered for 'command'
+// before. Registering nil stops the calls. (See ZMPOption; which has to be registered for ZMP
+// commands to be received.)
+//
+// Like with OnCommand, 'fn' is called by whatever goroutine is reading from the Conn, as part of
+// Read; and blocks the Conn from reading until it returns.
+func (clientConn *Conn) OnZMP(command string, fn func(args []string)) {
+	clientConn.zmpMutex.Lock()
+	defer clientConn.zmpMutex.Unlock()
+
+	if nil == fn {
+		delete(clientConn.zmpHandlers, command)
+		return
+	}
+
+	if nil == clientConn.zmpHandlers {
+		clientConn.zmpHandlers = map[string]func(args []string){}
+	}
+	clientConn.zmpHandlers[command] = fn
+}
+
+func (clientConn *Conn) zmpHandler(command string) func(args []string) {
+	clientConn.zmpMutex.RLock()
+	defer clientConn.zmpMutex.RUnlock()
+
+	return clientConn.zmpHandlers[command]
+}
+
+// supportsZMP returns whether the ZMP command 'name' (or, if it ends with a ".", the package) is
+// supported; i.e., is one of the "zmp." ones, or was registered with OnZMP (or, for a package,
+// one of its commands was).
+func (clientConn *Conn) supportsZMP(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		if _, ok := zmpBuiltins[name]; ok {
+			return true
+		}
+		return nil != clientConn.zmpHandler(name)
+	}
+
+	if "zmp." == name {
+		return true
+	}
+
+	clientConn.zmpMutex.RLock()
+	defer clientConn.zmpMutex.RUnlock()
+
+	for command := range clientConn.zmpHandlers {
+		if strings.HasPrefix(command, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SendZMP sends a ZMP command to the peer; i.e., the command 'command' (such as "color.define"),
+// with the arguments 'args'. For example:
+//
+//	err := conn.SendZMP("color.define", "1", "red")
+//
+// ... sends:
+//
+//	IAC SB ZMP 'color.define' NUL '1' NUL 'red' NUL IAC SE
+//
+// An argument can have any byte in it other than NUL (including IAC; which is escaped). It returns
+// ErrInvalidZMP if 'command' is empty, or if it (or one of 'args') has a NUL in it; and
+// ErrOptionNotEnabled if ZMP is not enabled (on either side). (See ZMPOption.)
+func (clientConn *Conn) SendZMP(command string, args ...string) error {
+	if local, remote := clientConn.OptionEnabled(OptZMP); !local && !remote {
+		return ErrOptionNotEnabled
+	}
+
+	payload, err := encodeZMP(command, args)
+	if nil != err {
+		return err
+	}
+
+	return clientConn.SendSubnegotiation(OptZMP, payload)
+}
+
+// encodeZMP returns the (ZMP) subnegotiation payload for the command 'command', with 'args'; i.e.,
+// each of them followed by a NUL. (The IACs in it are escaped when it is sent.)
+func encodeZMP(command string, args []string) ([]byte, error) {
+	if "" == command {
+		return nil, ErrInvalidZMP
+	}
+
+	size := len(command) + 1
+	for _, arg := range args {
+		size += len(arg) + 1
+	}
+
+	payload := make([]byte, 0, size)
+	for _, s := range append([]string{command}, args...) {
+		if 0 <= strings.IndexByte(s, 0) {
+			return nil, ErrInvalidZMP
+		}
+		payload = append(append(payload, s...), 0)
+	}
+
+	return payload, nil
+}
+
+// decodeZMP returns the command, and its arguments, that the (ZMP) subnegotiation payload
+// 'payload' is made out of. It returns false if 'payload' is not a ZMP command; i.e., its command
+// is empty, or it does not end with a NUL.
+func decodeZMP(payload []byte) (string, []string, bool) {
+	if len(payload) <= 0 || 0 != payload[len(payload)-1] {
+		return "", nil, false
+	}
+
+	fields := bytes.Split(payload[:len(payload)-1], []byte{0})
+	if len(fields[0]) <= 0 {
+		return "", nil, false
+	}
+
+	args := make([]string, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		args = append(args, string(field))
+	}
+
+	return string(fields[0]), args, true
+}
@@ -0,0 +1,257 @@
+package telnet
+
+import (
+	"bytes"
+	"time"
+
+	"testing"
+)
+
+// testZMPFrame returns the subnegotiation (with its IACs escaped) that 'payload' is sent as.
+func testZMPFrame(payload string) []byte {
+	frame := []byte{IAC, SB, OptZMP}
+	for _, b := range []byte(payload) {
+		if IAC == b {
+			frame = append(frame, IAC)
+		}
+		frame = append(frame, b)
+	}
+	return append(frame, IAC, SE)
+}
+
+func TestEncodeZMP(t *testing.T) {
+
+	tests := []struct {
+		Command  string
+		Args     []string
+		Expected []byte
+	}{
+		{
+			Command:  "zmp.ping",
+			Expected: []byte("zmp.ping\x00"),
+		},
+		{
+			Command:  "color.define",
+			Args:     []string{"1", "red"},
+			Expected: []byte("color.define\x001\x00red\x00"),
+		},
+		{
+			Command:  "msg.say",
+			Args:     []string{"", "\xff\xf0"},
+			Expected: []byte("msg.say\x00\x00\xff\xf0\x00"),
+		},
+	}
+
+	for testNumber, test := range tests {
+		actual, err := encodeZMP(test.Command, test.Args)
+		if nil != err {
+			t.Errorf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+			continue
+		}
+		if expected := test.Expected; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+
+		command, args, ok := decodeZMP(actual)
+		if !ok {
+			t.Errorf("For test #%d, expected it to be decoded, but it was not.", testNumber)
+			continue
+		}
+		if expected, actual := test.Command, command; expected != actual {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+		if expected, actual := test.Args, args; !testEqualStrings(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+}
+
+func TestEncodeZMPInvalid(t *testing.T) {
+
+	tests := []struct {
+		Command string
+		Args    []string
+	}{
+		{
+			Command: "",
+		},
+		{
+			Command: "zmp\x00ping",
+		},
+		{
+			Command: "color.define",
+			Args:    []string{"1", "r\x00ed"},
+		},
+	}
+
+	for testNumber, test := range tests {
+		if _, err := encodeZMP(test.Command, test.Args); ErrInvalidZMP != err {
+			t.Errorf("For test #%d, expected %v, but actually got %v.", testNumber, ErrInvalidZMP, err)
+		}
+	}
+}
+
+func TestDecodeZMPMalformed(t *testing.T) {
+
+	tests := []string{
+		"",
+		"zmp.ping",
+		"color.define\x001",
+		"\x00",
+		"\x001\x00",
+	}
+
+	for testNumber, payload := range tests {
+		if command, args, ok := decodeZMP([]byte(payload)); ok {
+			t.Errorf("For test #%d, expected it not to be decoded, but actually got %q %q.", testNumber, command, args)
+		}
+	}
+}
+
+func TestZMPOption(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	called := make(chan []string, 4)
+	conn.OnZMP("color.use", func(args []string) {
+		called <- args
+	})
+
+	offered := testReadLater(remote, 3)
+	if err := conn.RegisterOption(OptZMP, ZMPOption("mymud 1.0")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if expected, actual := []byte{IAC, WILL, OptZMP}, <-offered; !bytes.Equal(expected, actual) {
+		t.Fatalf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	// (Once ZMP is enabled, it identifies itself.)
+	expected := testZMPFrame("zmp.ident\x00mymud 1.0\x00")
+	identified := testReadLater(remote, len(expected))
+	if _, err := remote.Write([]byte{IAC, DO, OptZMP}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if actual := <-identified; !bytes.Equal(expected, actual) {
+		t.Fatalf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	// (What is not a ZMP command is ignored; and what comes after it is still read.)
+	var frames []byte
+	frames = append(frames, testZMPFrame("color.use")...)
+	frames = append(frames, testZMPFrame("\x00color.use\x00")...)
+	frames = append(frames, testZMPFrame("color.use\x001\x00\xff\x00")...)
+	if _, err := remote.Write(frames); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	select {
+	case args := <-called:
+		if expected, actual := []string{"1", "\xff"}, args; !testEqualStrings(expected, actual) {
+			t.Errorf("Expected %q, but actually got %q.", expected, actual)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for color.use.")
+	}
+	select {
+	case args := <-called:
+		t.Errorf("Expected color.use to be called once, but it was called again; with %q.", args)
+	default:
+	}
+
+	tests := []struct {
+		Request  string
+		Expected string
+	}{
+		{
+			Request:  "zmp.check\x00color.use\x00",
+			Expected: "zmp.support\x00color.use\x00",
+		},
+		{
+			Request:  "zmp.check\x00color.\x00",
+			Expected: "zmp.support\x00color.\x00",
+		},
+		{
+			Request:  "zmp.check\x00zmp.ping\x00",
+			Expected: "zmp.support\x00zmp.ping\x00",
+		},
+		{
+			Request:  "zmp.check\x00zmp.\x00",
+			Expected: "zmp.support\x00zmp.\x00",
+		},
+		{
+			Request:  "zmp.check\x00sound.play\x00",
+			Expected: "zmp.no-support\x00sound.play\x00",
+		},
+		{
+			Request:  "zmp.check\x00sound.\x00",
+			Expected: "zmp.no-support\x00sound.\x00",
+		},
+	}
+
+	for testNumber, test := range tests {
+		expected := testZMPFrame(test.Expected)
+		answered := testReadLater(remote, len(expected))
+		if _, err := remote.Write(testZMPFrame(test.Request)); nil != err {
+			t.Fatalf("For test #%d, did not expect an error, but actually got one: (%T) %v", testNumber, err, err)
+		}
+		if actual := <-answered; !bytes.Equal(expected, actual) {
+			t.Errorf("For test #%d, expected %q, but actually got %q.", testNumber, expected, actual)
+		}
+	}
+
+	// zmp.ping is answered with zmp.time; with what time it is (in UTC).
+	expectedPrefix := testZMPFrame("zmp.time\x00")
+	expectedPrefix = expectedPrefix[:len(expectedPrefix)-2]
+	answered := testReadLater(remote, len(expectedPrefix)+len(zmpTimeLayout)+3)
+	if _, err := remote.Write(testZMPFrame("zmp.ping\x00")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	actual := <-answered
+	if !bytes.HasPrefix(actual, expectedPrefix) {
+		t.Fatalf("Expected %q to start with %q, but it did not.", actual, expectedPrefix)
+	}
+	when, err := time.Parse(zmpTimeLayout, string(actual[len(expectedPrefix):len(actual)-3]))
+	if nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if d := time.Since(when); d < -time.Minute || time.Minute < d {
+		t.Errorf("Expected the time to be about now, but actually got %v.", when)
+	}
+}
+
+func TestConnSendZMP(t *testing.T) {
+
+	conn, remote := testPipe(t)
+
+	if expected, actual := ErrOptionNotEnabled, conn.SendZMP("zmp.ping"); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+
+	offered := testReadLater(remote, 3)
+	if err := conn.RegisterOption(OptZMP, ZMPOption("")); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	<-offered
+	if _, err := remote.Write([]byte{IAC, DO, OptZMP}); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	for i := 0; i < 100; i++ {
+		if local, _ := conn.OptionEnabled(OptZMP); local {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// (With no ident, nothing is sent, other than what SendZMP sends.)
+	expected := testZMPFrame("msg.say\x00\xffhi\x00")
+	sent := testReadLater(remote, len(expected))
+	if err := conn.SendZMP("msg.say", "\xffhi"); nil != err {
+		t.Fatalf("Did not expect an error, but actually got one: (%T) %v", err, err)
+	}
+	if actual := <-sent; !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %q, but actually got %q.", expected, actual)
+	}
+
+	if expected, actual := ErrInvalidZMP, conn.SendZMP(""); expected != actual {
+		t.Errorf("Expected %v, but actually got %v.", expected, actual)
+	}
+}